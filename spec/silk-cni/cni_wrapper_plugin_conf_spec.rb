@@ -55,19 +55,21 @@ module Bosh::Template::Test
         clientConfig = JSON.parse(template.render(merged_manifest_properties, spec: spec, consumes: links))
         expect(clientConfig).to eq({
           'name' => 'cni-wrapper',
-          'cniVersion' => '1.0.0',
-          'disableCheck' => true,
+          'cniVersion' => '1.1.0',
+          'disableCheck' => false,
           'plugins' => [{
             'type' => 'cni-wrapper-plugin',
             'datastore' => '/var/vcap/data/container-metadata/store.json',
             'datastore_file_owner' => 'vcap',
             'datastore_file_group' => 'vcap',
             'iptables_lock_file' => '/var/vcap/data/garden-cni/iptables.lock',
+            'firewall_backend' => 'iptables',
             'instance_address' => '111.11.11.1',
             'no_masquerade_cidr_range' => '222.22.0.0/16',
             'temporary_underlay_interface_names' => [],
             'underlay_ips' => ['192.74.65.4'],
             'iptables_asg_logging' => true,
+            'iptables_asg_ipsets' => false,
             'iptables_c2c_logging' => true,
             'iptables_denied_logs_per_sec' => 2,
             'iptables_accepted_udp_logs_per_sec' => 3,
@@ -83,7 +85,7 @@ module Bosh::Template::Test
               'staging' => ['3.3.3.3/32'],
             },
             'delegate' => {
-              'cniVersion' => '1.0.0',
+              'cniVersion' => '1.1.0',
               'name' => 'silk',
               'type' => 'silk-cni',
               'daemonPort' => 8080,
@@ -109,6 +111,17 @@ module Bosh::Template::Test
         })
       end
 
+      context 'when enable_cni_check is false' do
+        before do
+          merged_manifest_properties['enable_cni_check'] = false
+        end
+
+        it 'disables CNI CHECK' do
+          clientConfig = JSON.parse(template.render(merged_manifest_properties, spec: spec, consumes: links))
+          expect(clientConfig['disableCheck']).to eq(true)
+        end
+      end
+
       context 'when ips have leading 0s' do
         it 'no_masquerade_cidr_range fails with a nice message' do
           merged_manifest_properties['no_masquerade_cidr_range'] = '222.022.0.2/16'
@@ -198,6 +211,223 @@ module Bosh::Template::Test
         end
       end
 
+      context 'when the policy agent serves the force poll cycle over mutual tls' do
+        let(:links) {[
+          Link.new(
+            name: 'vpa',
+            properties: {
+              'force_policy_poll_cycle_port' => 5555,
+              'force_policy_poll_cycle_tls' => { 'ca_cert' => 'some-server-ca-cert' }
+            }
+          )
+        ]}
+        let(:tls_properties) do
+          {
+            'force_policy_poll_cycle_tls' => {
+              'ca_cert' => 'some-ca-cert',
+              'client_cert' => 'some-client-cert',
+              'client_key' => 'some-client-key'
+            }
+          }
+        end
+
+        it 'renders the policy agent tls files' do
+          contents = merged_manifest_properties.merge(tls_properties)
+          clientConfig = JSON.parse(template.render(contents, spec: spec, consumes: links))
+          expect(clientConfig['plugins'][0]['policy_agent_force_poll_ca_cert_file']).to eq('/var/vcap/jobs/silk-cni/config/certs/policy-agent/ca.crt')
+          expect(clientConfig['plugins'][0]['policy_agent_force_poll_cert_file']).to eq('/var/vcap/jobs/silk-cni/config/certs/policy-agent/client.crt')
+          expect(clientConfig['plugins'][0]['policy_agent_force_poll_key_file']).to eq('/var/vcap/jobs/silk-cni/config/certs/policy-agent/client.key')
+        end
+
+        it 'renders the certs' do
+          contents = merged_manifest_properties.merge(tls_properties)
+          expect(job.template('config/certs/policy-agent/ca.crt').render(contents)).to eq("\nsome-ca-cert\n\n")
+          expect(job.template('config/certs/policy-agent/client.crt').render(contents)).to eq("\nsome-client-cert\n\n")
+          expect(job.template('config/certs/policy-agent/client.key').render(contents)).to eq("\nsome-client-key\n\n")
+        end
+
+        context 'when the client certs are not set' do
+          it 'raises a descriptive error' do
+            expect {
+              template.render(merged_manifest_properties, spec: spec, consumes: links)
+            }.to raise_error('force_policy_poll_cycle_tls requires a ca_cert, client_cert and client_key, as the vxlan-policy-agent serves the force policy poll cycle over TLS')
+          end
+        end
+      end
+
+      context 'when the client certs are set but the policy agent does not serve tls' do
+        it 'raises a descriptive error' do
+          contents = merged_manifest_properties.merge('force_policy_poll_cycle_tls' => { 'ca_cert' => 'some-ca-cert' })
+          expect {
+            template.render(contents, spec: spec, consumes: links)
+          }.to raise_error('force_policy_poll_cycle_tls is set but the vxlan-policy-agent does not serve the force policy poll cycle over TLS')
+        end
+      end
+
+      context 'when the policy agent serves the force poll cycle on a unix socket' do
+        let(:links) {[
+          Link.new(
+            name: 'vpa',
+            properties: {
+              'force_policy_poll_cycle_port' => 5555,
+              'force_policy_poll_cycle_unix_socket' => true
+            }
+          )
+        ]}
+
+        it 'renders the policy agent socket' do
+          clientConfig = JSON.parse(template.render(merged_manifest_properties, spec: spec, consumes: links))
+          expect(clientConfig['plugins'][0]['policy_agent_force_poll_socket']).to eq('/var/vcap/data/garden-cni/vxlan-policy-agent.sock')
+        end
+      end
+
+      context 'when ipv6_overlay_network is set' do
+        it 'passes it to the delegate' do
+          contents = merged_manifest_properties.merge('ipv6_overlay_network' => 'fd00:5111::/96')
+          clientConfig = JSON.parse(template.render(contents, spec: spec, consumes: links))
+          expect(clientConfig['plugins'][0]['delegate']['ipv6OverlayNetwork']).to eq('fd00:5111::/96')
+        end
+
+        context 'when it is smaller than a /96' do
+          it 'raises a descriptive error' do
+            contents = merged_manifest_properties.merge('ipv6_overlay_network' => 'fd00:5111::/112')
+            expect {
+              template.render(contents, spec: spec, consumes: links)
+            }.to raise_error("Invalid ipv6_overlay_network 'fd00:5111::/112': must be an IPv6 network of /96 or larger")
+          end
+        end
+      end
+
+      context 'when container sysctls and route networks are allowed' do
+        it 'passes them to the delegate' do
+          contents = merged_manifest_properties.merge(
+            'allowed_container_sysctls' => ['net.core.somaxconn', 'net.ipv4.tcp_*'],
+            'allowed_container_route_networks' => ['10.10.0.0/16']
+          )
+          clientConfig = JSON.parse(template.render(contents, spec: spec, consumes: links))
+          expect(clientConfig['plugins'][0]['delegate']['allowedSysctls']).to eq(['net.core.somaxconn', 'net.ipv4.tcp_*'])
+          expect(clientConfig['plugins'][0]['delegate']['allowedRouteNetworks']).to eq(['10.10.0.0/16'])
+        end
+
+        context 'when a sysctl is not under net.' do
+          it 'raises a descriptive error' do
+            contents = merged_manifest_properties.merge('allowed_container_sysctls' => ['kernel.shmmax'])
+            expect {
+              template.render(contents, spec: spec, consumes: links)
+            }.to raise_error("Invalid allowed_container_sysctls entry 'kernel.shmmax': only sysctls under net. can be allowed")
+          end
+        end
+
+        context 'when a route network is IPv6' do
+          it 'raises a descriptive error' do
+            contents = merged_manifest_properties.merge('allowed_container_route_networks' => ['fd00::/64'])
+            expect {
+              template.render(contents, spec: spec, consumes: links)
+            }.to raise_error("Invalid allowed_container_route_networks entry 'fd00::/64': not an IPv4 network")
+          end
+        end
+      end
+
+      context 'when attachments are set' do
+        let(:attachments) do
+          [{
+            'ifname' => 'net1',
+            'delegate' => { 'cniVersion' => '1.0.0', 'name' => 'storage', 'type' => 'macvlan', 'master' => 'eth1' }
+          }]
+        end
+
+        it 'passes them to the wrapper' do
+          contents = merged_manifest_properties.merge('attachments' => attachments)
+          clientConfig = JSON.parse(template.render(contents, spec: spec, consumes: links))
+          expect(clientConfig['plugins'][0]['attachments']).to eq(attachments)
+        end
+
+        context 'when an ifname is used more than once' do
+          it 'raises a descriptive error' do
+            contents = merged_manifest_properties.merge('attachments' => attachments + attachments)
+            expect {
+              template.render(contents, spec: spec, consumes: links)
+            }.to raise_error("Invalid attachments[1]: ifname 'net1' is used more than once")
+          end
+        end
+
+        context 'when the delegate has no type' do
+          it 'raises a descriptive error' do
+            contents = merged_manifest_properties.merge('attachments' => [{ 'ifname' => 'net1', 'delegate' => {} }])
+            expect {
+              template.render(contents, spec: spec, consumes: links)
+            }.to raise_error('Invalid attachments[0]: missing delegate type')
+          end
+        end
+      end
+
+      context 'when additional overlays are set' do
+        let(:additional_overlays) do
+          [{ 'name' => 'isolated', 'network' => '10.254.0.0/16', 'vtep_name' => 'silk-vtep-iso' }]
+        end
+
+        it 'passes them to the wrapper and the org mapping to silk-cni' do
+          contents = merged_manifest_properties.merge(
+            'additional_overlays' => additional_overlays,
+            'org_overlays' => { 'some-org-guid' => 'isolated' }
+          )
+          clientConfig = JSON.parse(template.render(contents, spec: spec, consumes: links))
+          expect(clientConfig['plugins'][0]['additional_overlays']).to eq(additional_overlays)
+          expect(clientConfig['plugins'][0]['delegate']['orgOverlays']).to eq({ 'some-org-guid' => 'isolated' })
+        end
+
+        context 'when an org is mapped to an unknown overlay' do
+          it 'raises a descriptive error' do
+            contents = merged_manifest_properties.merge(
+              'additional_overlays' => additional_overlays,
+              'org_overlays' => { 'some-org-guid' => 'other' }
+            )
+            expect {
+              template.render(contents, spec: spec, consumes: links)
+            }.to raise_error("Invalid org_overlays entry 'some-org-guid': overlay 'other' is not in additional_overlays")
+          end
+        end
+
+        context 'when an overlay has no vtep_name' do
+          it 'raises a descriptive error' do
+            contents = merged_manifest_properties.merge('additional_overlays' => [{ 'name' => 'isolated', 'network' => '10.254.0.0/16' }])
+            expect {
+              template.render(contents, spec: spec, consumes: links)
+            }.to raise_error('Invalid additional_overlays[0]: missing vtep_name')
+          end
+        end
+      end
+
+      context 'when egress ips are set' do
+        let(:egress_ips) do
+          [{ 'ips' => ['10.0.16.20', '10.0.16.21'], 'space_guids' => ['some-space-guid'] }]
+        end
+
+        it 'passes them to the wrapper' do
+          contents = merged_manifest_properties.merge('egress_ips' => egress_ips)
+          clientConfig = JSON.parse(template.render(contents, spec: spec, consumes: links))
+          expect(clientConfig['plugins'][0]['egress_ips']).to eq(egress_ips)
+        end
+
+        context 'when an ip is not IPv4' do
+          it 'raises a descriptive error' do
+            contents = merged_manifest_properties.merge('egress_ips' => [{ 'ips' => ['2001:db8::1'], 'app_guids' => ['some-app-guid'] }])
+            expect {
+              template.render(contents, spec: spec, consumes: links)
+            }.to raise_error("Invalid egress_ips[0]: ip '2001:db8::1' is not an IPv4 address")
+          end
+        end
+
+        context 'when an entry selects no containers' do
+          it 'raises a descriptive error' do
+            contents = merged_manifest_properties.merge('egress_ips' => [{ 'ips' => ['10.0.16.20'] }])
+            expect {
+              template.render(contents, spec: spec, consumes: links)
+            }.to raise_error('Invalid egress_ips[0]: missing app_guids or space_guids')
+          end
+        end
+      end
+
       context 'when deny_networks are provided' do
         context 'when a destination is IPv6' do
           it 'raises a descriptive error' do
@@ -70,7 +70,10 @@ module Bosh::Template::Test
               'log_prefix' => 'cfnetworking',
               'log_level' => 'error',
               'vxlan_interface_name' => '',
-              'single_ip_only' => true
+              'single_ip_only' => true,
+              'static_leases_file' => '',
+              'diagnostic_server_port' => 0,
+              'diagnostic_server_token_file' => '/var/vcap/jobs/silk-daemon/config/diagnostics-token'
             })
           end
 
@@ -117,6 +120,106 @@ module Bosh::Template::Test
             end
           end
 
+          context 'when static_leases_file is set' do
+            let(:merged_manifest_properties) do
+              {
+                'static_leases_file' => '/var/vcap/data/silk/leases.yml'
+              }
+            end
+
+            it 'sets static_leases_file' do
+              clientConfig = JSON.parse(template.render(merged_manifest_properties, consumes: links))
+              expect(clientConfig['static_leases_file']).to eq('/var/vcap/data/silk/leases.yml')
+            end
+          end
+
+          context 'when the diagnostics port is set' do
+            let(:merged_manifest_properties) do
+              {
+                'diagnostics' => { 'listen_port' => 23955, 'token' => 'some-token' }
+              }
+            end
+
+            it 'sets the diagnostic server port and token file' do
+              clientConfig = JSON.parse(template.render(merged_manifest_properties, consumes: links))
+              expect(clientConfig['diagnostic_server_port']).to eq(23955)
+              expect(clientConfig['diagnostic_server_token_file']).to eq('/var/vcap/jobs/silk-daemon/config/diagnostics-token')
+            end
+
+            it 'renders the token file' do
+              tokenTemplate = job.template('config/diagnostics-token')
+              expect(tokenTemplate.render(merged_manifest_properties).strip).to eq('some-token')
+            end
+
+            context 'when the token is not set' do
+              let(:merged_manifest_properties) do
+                {
+                  'diagnostics' => { 'listen_port' => 23955 }
+                }
+              end
+
+              it 'throws a helpful error' do
+                expect {
+                  template.render(merged_manifest_properties, consumes: links)
+                }.to raise_error("'diagnostics.token' must be set when 'diagnostics.listen_port' is set")
+              end
+            end
+          end
+
+          context 'when additional_overlays are set' do
+            let(:merged_manifest_properties) do
+              {
+                'additional_overlays' => [{
+                  'name' => 'isolated',
+                  'vtep_name' => 'silk-vtep-iso',
+                  'vni' => 2,
+                  'overlay_network' => '10.254.0.0/16',
+                  'subnet_prefix_length' => 24
+                }, {
+                  'name' => 'other',
+                  'vtep_name' => 'silk-vtep-other',
+                  'vni' => 3,
+                  'overlay_network' => '10.253.0.0/16',
+                  'subnet_prefix_length' => 26,
+                  'connectivity_server_url' => 'https://other-controller:4103'
+                }]
+              }
+            end
+
+            it 'renders them, defaulting to the silk controller of the default overlay' do
+              clientConfig = JSON.parse(template.render(merged_manifest_properties, consumes: links))
+              expect(clientConfig['additional_overlays']).to eq([{
+                'name' => 'isolated',
+                'vtep_name' => 'silk-vtep-iso',
+                'vni' => 2,
+                'overlay_network' => '10.254.0.0/16',
+                'subnet_prefix_length' => 24,
+                'connectivity_server_url' => 'https://silk-controller.service.cf.internal:4103'
+              }, {
+                'name' => 'other',
+                'vtep_name' => 'silk-vtep-other',
+                'vni' => 3,
+                'overlay_network' => '10.253.0.0/16',
+                'subnet_prefix_length' => 26,
+                'connectivity_server_url' => 'https://other-controller:4103'
+              }])
+            end
+
+            context 'when an overlay has no vni' do
+              let(:merged_manifest_properties) do
+                {
+                  'additional_overlays' => [{ 'name' => 'isolated', 'vtep_name' => 'silk-vtep-iso', 'overlay_network' => '10.254.0.0/16', 'subnet_prefix_length' => 24 }]
+                }
+              end
+
+              it 'throws a helpful error' do
+                expect {
+                  template.render(merged_manifest_properties, consumes: links)
+                }.to raise_error('Invalid additional_overlays[0]: missing vni')
+              end
+            end
+          end
+
           context 'when logging.format.timestamp is set to an invalid value' do
             let(:merged_manifest_properties) do
               {
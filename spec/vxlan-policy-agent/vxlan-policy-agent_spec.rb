@@ -26,6 +26,8 @@ module Bosh::Template::Test
         }
       end
 
+      let(:additional_overlays) {[]}
+
       let(:links) do
         [
           Link.new(
@@ -40,6 +42,7 @@ module Bosh::Template::Test
             instances: [LinkInstance.new()],
             properties: {
               'iptables_logging' => true,
+              'iptables_asg_ipsets' => true,
               'iptables_denied_logs_per_sec' => 2,
               'deny_networks' => {
                 'always' => ['1.1.1.1/32'],
@@ -50,7 +53,8 @@ module Bosh::Template::Test
                 'limit' => true,
                 'burst' => 1000,
                 'rate_per_sec' => 100,
-              }
+              },
+              'additional_overlays' => additional_overlays,
             }
           )
         ]
@@ -75,6 +79,7 @@ module Bosh::Template::Test
               'cni_datastore_path' => '/var/vcap/data/container-metadata/store.json',
               'debug_server_host' => '127.0.0.1',
               'debug_server_port' => 8721,
+              'firewall_backend' => 'iptables',
               'iptables_accepted_udp_logs_per_sec' => 33,
               'iptables_c2c_logging' => true,
               'iptables_lock_file' => '/var/vcap/data/garden-cni/iptables.lock',
@@ -87,12 +92,17 @@ module Bosh::Template::Test
               'poll_interval' => 22,
               'enable_asg_syncing' => false,
               'asg_poll_interval' => 66,
+              'enable_asg_fqdns' => false,
+              'asg_fqdn_resolve_interval' => 60,
+              'asg_fqdn_ttl' => 300,
               'vni' => 1,
               'force_policy_poll_cycle_host' => '127.0.0.1',
               'force_policy_poll_cycle_port' => 8722,
               'disable_container_network_policy' => false,
               'overlay_network' => '10.255.0.0/16',
+              'additional_overlays' => [],
               'iptables_asg_logging' => true,
+              'iptables_asg_ipsets' => true,
               'iptables_denied_logs_per_sec' => 2,
               'deny_networks' => {
                 'always' => ['1.1.1.1/32'],
@@ -111,6 +121,98 @@ module Bosh::Template::Test
             })
           end
 
+          context 'when asg_fqdns are enabled' do
+            before do
+              merged_manifest_properties['asg_fqdns'] = {
+                'enabled' => true,
+                'resolve_interval_seconds' => 30,
+                'ttl_seconds' => 120,
+              }
+            end
+
+            it 'renders the asg fqdn config' do
+              renderedConfig = JSON.parse(template.render(merged_manifest_properties, consumes: links))
+              expect(renderedConfig['enable_asg_fqdns']).to eq(true)
+              expect(renderedConfig['asg_fqdn_resolve_interval']).to eq(30)
+              expect(renderedConfig['asg_fqdn_ttl']).to eq(120)
+            end
+          end
+
+          context 'when the cni config has additional overlays' do
+            let(:additional_overlays) {[
+              { 'name' => 'isolated', 'network' => '10.100.0.0/16', 'vtep_name' => 'silk-vtep-iso', 'org_guids' => ['ignored'] },
+            ]}
+
+            it 'renders the overlays' do
+              renderedConfig = JSON.parse(template.render(merged_manifest_properties, consumes: links))
+              expect(renderedConfig['additional_overlays']).to eq([
+                { 'name' => 'isolated', 'network' => '10.100.0.0/16', 'vtep_name' => 'silk-vtep-iso' },
+              ])
+            end
+          end
+
+          context 'when force_policy_poll_cycle_unix_socket is enabled' do
+            before do
+              merged_manifest_properties['force_policy_poll_cycle_unix_socket'] = true
+            end
+
+            it 'renders the force policy poll cycle socket' do
+              renderedConfig = JSON.parse(template.render(merged_manifest_properties, consumes: links))
+              expect(renderedConfig['force_policy_poll_cycle_socket']).to eq('/var/vcap/data/garden-cni/vxlan-policy-agent.sock')
+            end
+          end
+
+          context 'when force_policy_poll_cycle_tls is set' do
+            let(:ca_cert_template) {job.template('config/certs/force-policy-poll-cycle/ca.crt')}
+            let(:server_cert_template) {job.template('config/certs/force-policy-poll-cycle/server.crt')}
+            let(:server_key_template) {job.template('config/certs/force-policy-poll-cycle/server.key')}
+
+            before do
+              merged_manifest_properties['force_policy_poll_cycle_tls'] = {
+                'ca_cert' => 'some-ca-cert',
+                'server_cert' => 'some-server-cert',
+                'server_key' => 'some-server-key'
+              }
+            end
+
+            it 'renders the force policy poll cycle tls files' do
+              renderedConfig = JSON.parse(template.render(merged_manifest_properties, consumes: links))
+              expect(renderedConfig['force_policy_poll_cycle_ca_cert_file']).to eq('/var/vcap/jobs/vxlan-policy-agent/config/certs/force-policy-poll-cycle/ca.crt')
+              expect(renderedConfig['force_policy_poll_cycle_cert_file']).to eq('/var/vcap/jobs/vxlan-policy-agent/config/certs/force-policy-poll-cycle/server.crt')
+              expect(renderedConfig['force_policy_poll_cycle_key_file']).to eq('/var/vcap/jobs/vxlan-policy-agent/config/certs/force-policy-poll-cycle/server.key')
+            end
+
+            it 'renders the certs' do
+              expect(ca_cert_template.render(merged_manifest_properties)).to eq("\nsome-ca-cert\n\n")
+              expect(server_cert_template.render(merged_manifest_properties)).to eq("\nsome-server-cert\n\n")
+              expect(server_key_template.render(merged_manifest_properties)).to eq("\nsome-server-key\n\n")
+            end
+
+            context 'when the server key is missing' do
+              before do
+                merged_manifest_properties['force_policy_poll_cycle_tls'].delete('server_key')
+              end
+
+              it 'raises a descriptive error' do
+                expect {
+                  template.render(merged_manifest_properties, consumes: links)
+                }.to raise_error('force_policy_poll_cycle_tls requires a ca_cert, server_cert and server_key')
+              end
+            end
+
+            context 'when force_policy_poll_cycle_unix_socket is enabled too' do
+              before do
+                merged_manifest_properties['force_policy_poll_cycle_unix_socket'] = true
+              end
+
+              it 'raises a descriptive error' do
+                expect {
+                  template.render(merged_manifest_properties, consumes: links)
+                }.to raise_error('force_policy_poll_cycle_tls cannot be combined with force_policy_poll_cycle_unix_socket')
+              end
+            end
+          end
+
           context 'when loggregator.use_v2_api is true' do
             let(:ca_cert_template) {job.template('config/certs/loggregator/ca.crt')}
             let(:client_cert_template) {job.template('config/certs/loggregator/client.crt')}
@@ -23,6 +23,18 @@ type Delegator struct {
 		result1 types.Result
 		result2 error
 	}
+	DelegateCheckStub        func(string, []byte) error
+	delegateCheckMutex       sync.RWMutex
+	delegateCheckArgsForCall []struct {
+		arg1 string
+		arg2 []byte
+	}
+	delegateCheckReturns struct {
+		result1 error
+	}
+	delegateCheckReturnsOnCall map[int]struct {
+		result1 error
+	}
 	DelegateDelStub        func(string, []byte) error
 	delegateDelMutex       sync.RWMutex
 	delegateDelArgsForCall []struct {
@@ -35,6 +47,30 @@ type Delegator struct {
 	delegateDelReturnsOnCall map[int]struct {
 		result1 error
 	}
+	DelegateGCStub        func(string, []byte) error
+	delegateGCMutex       sync.RWMutex
+	delegateGCArgsForCall []struct {
+		arg1 string
+		arg2 []byte
+	}
+	delegateGCReturns struct {
+		result1 error
+	}
+	delegateGCReturnsOnCall map[int]struct {
+		result1 error
+	}
+	DelegateStatusStub        func(string, []byte) error
+	delegateStatusMutex       sync.RWMutex
+	delegateStatusArgsForCall []struct {
+		arg1 string
+		arg2 []byte
+	}
+	delegateStatusReturns struct {
+		result1 error
+	}
+	delegateStatusReturnsOnCall map[int]struct {
+		result1 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -109,6 +145,73 @@ func (fake *Delegator) DelegateAddReturnsOnCall(i int, result1 types.Result, res
 	}{result1, result2}
 }
 
+func (fake *Delegator) DelegateCheck(arg1 string, arg2 []byte) error {
+	var arg2Copy []byte
+	if arg2 != nil {
+		arg2Copy = make([]byte, len(arg2))
+		copy(arg2Copy, arg2)
+	}
+	fake.delegateCheckMutex.Lock()
+	ret, specificReturn := fake.delegateCheckReturnsOnCall[len(fake.delegateCheckArgsForCall)]
+	fake.delegateCheckArgsForCall = append(fake.delegateCheckArgsForCall, struct {
+		arg1 string
+		arg2 []byte
+	}{arg1, arg2Copy})
+	stub := fake.DelegateCheckStub
+	fakeReturns := fake.delegateCheckReturns
+	fake.recordInvocation("DelegateCheck", []interface{}{arg1, arg2Copy})
+	fake.delegateCheckMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *Delegator) DelegateCheckCallCount() int {
+	fake.delegateCheckMutex.RLock()
+	defer fake.delegateCheckMutex.RUnlock()
+	return len(fake.delegateCheckArgsForCall)
+}
+
+func (fake *Delegator) DelegateCheckCalls(stub func(string, []byte) error) {
+	fake.delegateCheckMutex.Lock()
+	defer fake.delegateCheckMutex.Unlock()
+	fake.DelegateCheckStub = stub
+}
+
+func (fake *Delegator) DelegateCheckArgsForCall(i int) (string, []byte) {
+	fake.delegateCheckMutex.RLock()
+	defer fake.delegateCheckMutex.RUnlock()
+	argsForCall := fake.delegateCheckArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *Delegator) DelegateCheckReturns(result1 error) {
+	fake.delegateCheckMutex.Lock()
+	defer fake.delegateCheckMutex.Unlock()
+	fake.DelegateCheckStub = nil
+	fake.delegateCheckReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Delegator) DelegateCheckReturnsOnCall(i int, result1 error) {
+	fake.delegateCheckMutex.Lock()
+	defer fake.delegateCheckMutex.Unlock()
+	fake.DelegateCheckStub = nil
+	if fake.delegateCheckReturnsOnCall == nil {
+		fake.delegateCheckReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.delegateCheckReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *Delegator) DelegateDel(arg1 string, arg2 []byte) error {
 	var arg2Copy []byte
 	if arg2 != nil {
@@ -176,13 +279,153 @@ func (fake *Delegator) DelegateDelReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *Delegator) DelegateGC(arg1 string, arg2 []byte) error {
+	var arg2Copy []byte
+	if arg2 != nil {
+		arg2Copy = make([]byte, len(arg2))
+		copy(arg2Copy, arg2)
+	}
+	fake.delegateGCMutex.Lock()
+	ret, specificReturn := fake.delegateGCReturnsOnCall[len(fake.delegateGCArgsForCall)]
+	fake.delegateGCArgsForCall = append(fake.delegateGCArgsForCall, struct {
+		arg1 string
+		arg2 []byte
+	}{arg1, arg2Copy})
+	stub := fake.DelegateGCStub
+	fakeReturns := fake.delegateGCReturns
+	fake.recordInvocation("DelegateGC", []interface{}{arg1, arg2Copy})
+	fake.delegateGCMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *Delegator) DelegateGCCallCount() int {
+	fake.delegateGCMutex.RLock()
+	defer fake.delegateGCMutex.RUnlock()
+	return len(fake.delegateGCArgsForCall)
+}
+
+func (fake *Delegator) DelegateGCCalls(stub func(string, []byte) error) {
+	fake.delegateGCMutex.Lock()
+	defer fake.delegateGCMutex.Unlock()
+	fake.DelegateGCStub = stub
+}
+
+func (fake *Delegator) DelegateGCArgsForCall(i int) (string, []byte) {
+	fake.delegateGCMutex.RLock()
+	defer fake.delegateGCMutex.RUnlock()
+	argsForCall := fake.delegateGCArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *Delegator) DelegateGCReturns(result1 error) {
+	fake.delegateGCMutex.Lock()
+	defer fake.delegateGCMutex.Unlock()
+	fake.DelegateGCStub = nil
+	fake.delegateGCReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Delegator) DelegateGCReturnsOnCall(i int, result1 error) {
+	fake.delegateGCMutex.Lock()
+	defer fake.delegateGCMutex.Unlock()
+	fake.DelegateGCStub = nil
+	if fake.delegateGCReturnsOnCall == nil {
+		fake.delegateGCReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.delegateGCReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Delegator) DelegateStatus(arg1 string, arg2 []byte) error {
+	var arg2Copy []byte
+	if arg2 != nil {
+		arg2Copy = make([]byte, len(arg2))
+		copy(arg2Copy, arg2)
+	}
+	fake.delegateStatusMutex.Lock()
+	ret, specificReturn := fake.delegateStatusReturnsOnCall[len(fake.delegateStatusArgsForCall)]
+	fake.delegateStatusArgsForCall = append(fake.delegateStatusArgsForCall, struct {
+		arg1 string
+		arg2 []byte
+	}{arg1, arg2Copy})
+	stub := fake.DelegateStatusStub
+	fakeReturns := fake.delegateStatusReturns
+	fake.recordInvocation("DelegateStatus", []interface{}{arg1, arg2Copy})
+	fake.delegateStatusMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *Delegator) DelegateStatusCallCount() int {
+	fake.delegateStatusMutex.RLock()
+	defer fake.delegateStatusMutex.RUnlock()
+	return len(fake.delegateStatusArgsForCall)
+}
+
+func (fake *Delegator) DelegateStatusCalls(stub func(string, []byte) error) {
+	fake.delegateStatusMutex.Lock()
+	defer fake.delegateStatusMutex.Unlock()
+	fake.DelegateStatusStub = stub
+}
+
+func (fake *Delegator) DelegateStatusArgsForCall(i int) (string, []byte) {
+	fake.delegateStatusMutex.RLock()
+	defer fake.delegateStatusMutex.RUnlock()
+	argsForCall := fake.delegateStatusArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *Delegator) DelegateStatusReturns(result1 error) {
+	fake.delegateStatusMutex.Lock()
+	defer fake.delegateStatusMutex.Unlock()
+	fake.DelegateStatusStub = nil
+	fake.delegateStatusReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Delegator) DelegateStatusReturnsOnCall(i int, result1 error) {
+	fake.delegateStatusMutex.Lock()
+	defer fake.delegateStatusMutex.Unlock()
+	fake.DelegateStatusStub = nil
+	if fake.delegateStatusReturnsOnCall == nil {
+		fake.delegateStatusReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.delegateStatusReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *Delegator) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
 	fake.delegateAddMutex.RLock()
 	defer fake.delegateAddMutex.RUnlock()
+	fake.delegateCheckMutex.RLock()
+	defer fake.delegateCheckMutex.RUnlock()
 	fake.delegateDelMutex.RLock()
 	defer fake.delegateDelMutex.RUnlock()
+	fake.delegateGCMutex.RLock()
+	defer fake.delegateGCMutex.RUnlock()
+	fake.delegateStatusMutex.RLock()
+	defer fake.delegateStatusMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -0,0 +1,116 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/cni-wrapper-plugin/netrules"
+)
+
+type FQDNSets struct {
+	EnsureStub        func(string) (string, error)
+	ensureMutex       sync.RWMutex
+	ensureArgsForCall []struct {
+		arg1 string
+	}
+	ensureReturns struct {
+		result1 string
+		result2 error
+	}
+	ensureReturnsOnCall map[int]struct {
+		result1 string
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FQDNSets) Ensure(arg1 string) (string, error) {
+	fake.ensureMutex.Lock()
+	ret, specificReturn := fake.ensureReturnsOnCall[len(fake.ensureArgsForCall)]
+	fake.ensureArgsForCall = append(fake.ensureArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.EnsureStub
+	fakeReturns := fake.ensureReturns
+	fake.recordInvocation("Ensure", []interface{}{arg1})
+	fake.ensureMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FQDNSets) EnsureCallCount() int {
+	fake.ensureMutex.RLock()
+	defer fake.ensureMutex.RUnlock()
+	return len(fake.ensureArgsForCall)
+}
+
+func (fake *FQDNSets) EnsureCalls(stub func(string) (string, error)) {
+	fake.ensureMutex.Lock()
+	defer fake.ensureMutex.Unlock()
+	fake.EnsureStub = stub
+}
+
+func (fake *FQDNSets) EnsureArgsForCall(i int) string {
+	fake.ensureMutex.RLock()
+	defer fake.ensureMutex.RUnlock()
+	argsForCall := fake.ensureArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FQDNSets) EnsureReturns(result1 string, result2 error) {
+	fake.ensureMutex.Lock()
+	defer fake.ensureMutex.Unlock()
+	fake.EnsureStub = nil
+	fake.ensureReturns = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FQDNSets) EnsureReturnsOnCall(i int, result1 string, result2 error) {
+	fake.ensureMutex.Lock()
+	defer fake.ensureMutex.Unlock()
+	fake.EnsureStub = nil
+	if fake.ensureReturnsOnCall == nil {
+		fake.ensureReturnsOnCall = make(map[int]struct {
+			result1 string
+			result2 error
+		})
+	}
+	fake.ensureReturnsOnCall[i] = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FQDNSets) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.ensureMutex.RLock()
+	defer fake.ensureMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FQDNSets) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ netrules.FQDNSets = new(FQDNSets)
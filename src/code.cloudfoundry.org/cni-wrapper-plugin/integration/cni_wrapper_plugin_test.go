@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"code.cloudfoundry.org/cni-wrapper-plugin/lib"
+	"code.cloudfoundry.org/cni-wrapper-plugin/netrules"
 
 	"code.cloudfoundry.org/garden"
 
@@ -34,6 +35,9 @@ type InputStruct struct {
 	Type       string                 `json:"type"`
 	Delegate   map[string]interface{} `json:"delegate"`
 	Metadata   map[string]interface{} `json:"metadata"`
+	PrevResult map[string]interface{} `json:"prevResult,omitempty"`
+
+	ValidAttachments []map[string]string `json:"cni.dev/valid-attachments,omitempty"`
 	lib.WrapperConfig
 }
 
@@ -180,7 +184,7 @@ var _ = Describe("CniWrapperPlugin", func() {
 				IPTablesAcceptedUDPLogsPerSec: 7,
 				PolicyAgentForcePollAddress:   policyAgentAddress,
 				RuntimeConfig: lib.RuntimeConfig{
-					PortMappings: []garden.NetIn{
+					PortMappings: []lib.PortMapping{
 						{
 							HostPort:      1000,
 							ContainerPort: 1001,
@@ -190,8 +194,8 @@ var _ = Describe("CniWrapperPlugin", func() {
 							ContainerPort: 2001,
 						},
 					},
-					NetOutRules: []garden.NetOutRule{
-						{
+					NetOutRules: []netrules.RuntimeNetOutRule{
+						{NetOutRule: garden.NetOutRule{
 							Protocol: garden.ProtocolAll,
 							Networks: []garden.IPRange{
 								{
@@ -199,8 +203,8 @@ var _ = Describe("CniWrapperPlugin", func() {
 									End:   net.ParseIP("4.4.4.4"),
 								},
 							},
-						},
-						{
+						}},
+						{NetOutRule: garden.NetOutRule{
 							Protocol: garden.ProtocolTCP,
 							Networks: []garden.IPRange{
 								{
@@ -214,8 +218,8 @@ var _ = Describe("CniWrapperPlugin", func() {
 									End:   54,
 								},
 							},
-						},
-						{
+						}},
+						{NetOutRule: garden.NetOutRule{
 							Protocol: garden.ProtocolUDP,
 							Networks: []garden.IPRange{
 								{
@@ -229,8 +233,8 @@ var _ = Describe("CniWrapperPlugin", func() {
 									End:   54,
 								},
 							},
-						},
-						{
+						}},
+						{NetOutRule: garden.NetOutRule{
 							Protocol: garden.ProtocolICMP,
 							Networks: []garden.IPRange{
 								{
@@ -242,7 +246,7 @@ var _ = Describe("CniWrapperPlugin", func() {
 								Type: 8,
 								Code: &code,
 							},
-						},
+						}},
 					},
 				},
 				OutConn: lib.OutConnConfig{
@@ -380,6 +384,244 @@ var _ = Describe("CniWrapperPlugin", func() {
 					}`))
 		})
 
+		Context("when the runtime config limits the bandwidth", func() {
+			BeforeEach(func() {
+				inputStruct.RuntimeConfig.Bandwidth = &lib.BandwidthConfig{
+					IngressRate:  1000,
+					IngressBurst: 2000,
+					EgressRate:   3000,
+					EgressBurst:  4000,
+				}
+				input = GetInput(inputStruct)
+
+				cmd = cniCommand("ADD", input)
+			})
+
+			It("passes the limits to the delegate plugin", func() {
+				session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(0))
+
+				debug, err := noop_debug.ReadDebug(debugFileName)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(debug.CmdArgs.StdinData).To(MatchJSON(`{
+						"cniVersion": "1.0.0",
+						"type": "noop",
+						"some": "other data",
+						"name": "name",
+						"runtimeConfig": {
+							"bandwidth": {
+								"ingressRate": 1000,
+								"ingressBurst": 2000,
+								"egressRate": 3000,
+								"egressBurst": 4000
+							}
+						}
+					}`))
+			})
+		})
+
+		Context("when the runtime config requests sysctls and routes", func() {
+			BeforeEach(func() {
+				inputStruct.RuntimeConfig.Sysctls = map[string]string{"net.core.somaxconn": "1024"}
+				inputStruct.RuntimeConfig.Routes = []string{"10.10.4.0/24"}
+				input = GetInput(inputStruct)
+
+				cmd = cniCommand("ADD", input)
+			})
+
+			It("passes them to the delegate plugin", func() {
+				session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(0))
+
+				debug, err := noop_debug.ReadDebug(debugFileName)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(debug.CmdArgs.StdinData).To(MatchJSON(`{
+						"cniVersion": "1.0.0",
+						"type": "noop",
+						"some": "other data",
+						"name": "name",
+						"runtimeConfig": {
+							"sysctls": {"net.core.somaxconn": "1024"},
+							"routes": ["10.10.4.0/24"]
+						}
+					}`))
+			})
+		})
+
+		Context("when the runtime config requests an ip", func() {
+			BeforeEach(func() {
+				inputStruct.RuntimeConfig.IPs = []string{"10.255.30.9/24"}
+				input = GetInput(inputStruct)
+
+				cmd = cniCommand("ADD", input)
+			})
+
+			It("passes the ip to the delegate plugin", func() {
+				session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(0))
+
+				debug, err := noop_debug.ReadDebug(debugFileName)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(debug.CmdArgs.StdinData).To(MatchJSON(`{
+						"cniVersion": "1.0.0",
+						"type": "noop",
+						"some": "other data",
+						"name": "name",
+						"runtimeConfig": {
+							"ips": ["10.255.30.9/24"]
+						}
+					}`))
+			})
+		})
+
+		Context("when the metadata limits the bandwidth", func() {
+			BeforeEach(func() {
+				inputStruct.Metadata["silk_ingress_rate"] = "1000"
+				inputStruct.Metadata["silk_ingress_burst"] = "2000"
+				input = GetInput(inputStruct)
+
+				cmd = cniCommand("ADD", input)
+			})
+
+			It("passes the metadata to the delegate plugin", func() {
+				session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(0))
+
+				debug, err := noop_debug.ReadDebug(debugFileName)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(debug.CmdArgs.StdinData).To(MatchJSON(`{
+						"cniVersion": "1.0.0",
+						"type": "noop",
+						"some": "other data",
+						"name": "name",
+						"metadata": {
+							"key1": "value1",
+							"key2": ["some", "data"],
+							"silk_ingress_rate": "1000",
+							"silk_ingress_burst": "2000"
+						}
+					}`))
+			})
+		})
+
+		Context("when secondary attachments are configured", func() {
+			BeforeEach(func() {
+				inputStruct.WrapperConfig.Attachments = []lib.AttachmentConfig{{
+					IfName: "net1",
+					Delegate: map[string]interface{}{
+						"type":       "noop",
+						"name":       "secondary",
+						"cniVersion": "1.0.0",
+					},
+				}}
+				input = GetInput(inputStruct)
+
+				cmd = cniCommand("ADD", input)
+			})
+
+			It("calls the attachment delegate with its interface name", func() {
+				session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(0))
+
+				debug, err := noop_debug.ReadDebug(debugFileName)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(debug.Command).To(Equal("ADD"))
+				Expect(debug.CmdArgs.IfName).To(Equal("net1"))
+				Expect(debug.CmdArgs.StdinData).To(MatchJSON(`{
+						"cniVersion": "1.0.0",
+						"type": "noop",
+						"name": "secondary"
+					}`))
+			})
+
+			It("stores the attachment in the container metadata", func() {
+				session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(0))
+
+				stateFileBytes, err := os.ReadFile(datastorePath)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(stateFileBytes)).To(ContainSubstring(`"ifname":"net1"`))
+			})
+
+			It("calls the attachment delegate on DEL", func() {
+				session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(0))
+
+				cmd = cniCommand("DEL", input)
+				session, err = gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(0))
+
+				debug, err := noop_debug.ReadDebug(debugFileName)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(debug.Command).To(Equal("DEL"))
+				Expect(debug.CmdArgs.IfName).To(Equal("net1"))
+			})
+
+			It("calls the attachment delegate recorded on ADD on DEL, after the config changed", func() {
+				session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(0))
+
+				inputStruct.WrapperConfig.Attachments = nil
+				cmd = cniCommand("DEL", GetInput(inputStruct))
+				session, err = gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(0))
+
+				debug, err := noop_debug.ReadDebug(debugFileName)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(debug.Command).To(Equal("DEL"))
+				Expect(debug.CmdArgs.IfName).To(Equal("net1"))
+				Expect(debug.CmdArgs.StdinData).To(MatchJSON(`{
+						"cniVersion": "1.0.0",
+						"type": "noop",
+						"name": "secondary"
+					}`))
+			})
+
+			It("calls the attachment delegate with its part of the prevResult on CHECK", func() {
+				session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(0))
+
+				inputStruct.PrevResult = map[string]interface{}{
+					"cniVersion": "1.0.0",
+					"interfaces": []map[string]interface{}{{"name": "some-eth0"}, {"name": "net1"}},
+					"ips": []map[string]interface{}{
+						{"address": "1.2.3.4/32", "interface": 0},
+						{"address": "192.168.1.5/24", "interface": 1},
+					},
+				}
+				cmd = cniCommand("CHECK", GetInput(inputStruct))
+				session, err = gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(0))
+
+				debug, err := noop_debug.ReadDebug(debugFileName)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(debug.Command).To(Equal("CHECK"))
+				Expect(debug.CmdArgs.IfName).To(Equal("net1"))
+				Expect(debug.CmdArgs.StdinData).To(MatchJSON(`{
+						"cniVersion": "1.0.0",
+						"type": "noop",
+						"name": "secondary",
+						"prevResult": {
+							"cniVersion": "1.0.0",
+							"interfaces": [{"name": "net1"}],
+							"ips": [{"address": "192.168.1.5/24", "interface": 0}]
+						}
+					}`))
+			})
+		})
+
 		It("ensures the container masquerade rule is created", func() {
 			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
 			Expect(err).NotTo(HaveOccurred())
@@ -729,7 +971,7 @@ var _ = Describe("CniWrapperPlugin", func() {
 
 			Context("when a port mapping with hostport 0 is given", func() {
 				BeforeEach(func() {
-					inputStruct.WrapperConfig.RuntimeConfig.PortMappings = []garden.NetIn{
+					inputStruct.WrapperConfig.RuntimeConfig.PortMappings = []lib.PortMapping{
 						{
 							HostPort:      0,
 							ContainerPort: 1001,
@@ -756,7 +998,66 @@ var _ = Describe("CniWrapperPlugin", func() {
 					session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
 					Expect(err).NotTo(HaveOccurred())
 					Eventually(session).Should(gexec.Exit(1))
-					Expect(session.Out.Contents()).To(MatchJSON(`{ "code": 999, "msg": "adding netin rule: invalid ip: asdf" }`))
+					Expect(session.Out.Contents()).To(MatchJSON(`{
+						"code": 999,
+						"msg": "adding netin rule: invalid ip: asdf",
+						"details": "rolled back: store add, delegate add"
+					}`))
+				})
+
+				It("removes what ADD created before the failure", func() {
+					cmd = cniCommand("ADD", input)
+					session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+					Expect(err).NotTo(HaveOccurred())
+					Eventually(session).Should(gexec.Exit(1))
+
+					By("removing the chains")
+					Expect(AllIPTablesRules("filter")).NotTo(ContainElement(ContainSubstring(netoutChainName)))
+					Expect(AllIPTablesRules("nat")).NotTo(ContainElement(ContainSubstring(netinChainName)))
+
+					By("removing the datastore entry")
+					stateFileBytes, err := os.ReadFile(datastorePath)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(stateFileBytes)).NotTo(ContainSubstring("1.2.3.4"))
+
+					By("calling DEL on the delegate")
+					debug, err := noop_debug.ReadDebug(debugFileName)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(debug.Command).To(Equal("DEL"))
+				})
+			})
+		})
+
+		Describe("EgressIPs", func() {
+			BeforeEach(func() {
+				inputStruct.Metadata["app_id"] = "some-app-guid"
+				inputStruct.WrapperConfig.EgressIPs = []lib.EgressIPConfig{
+					{IPs: []string{underlayIpAddr1}, AppGUIDs: []string{"some-app-guid"}},
+				}
+				input = GetInput(inputStruct)
+				cmd = cniCommand("ADD", input)
+			})
+
+			It("SNATs the container's egress to the egress ip", func() {
+				session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(0))
+
+				Expect(AllIPTablesRules("nat")).To(ContainElement("-A POSTROUTING -s 1.2.3.4/32 ! -d 10.255.0.0/16 ! -o some-device -j SNAT --to-source " + underlayIpAddr1))
+			})
+
+			Context("when the egress ip is not an address of the cell", func() {
+				BeforeEach(func() {
+					inputStruct.WrapperConfig.EgressIPs[0].IPs = []string{"192.0.2.55"}
+					input = GetInput(inputStruct)
+					cmd = cniCommand("ADD", input)
+				})
+
+				It("fails", func() {
+					session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+					Expect(err).NotTo(HaveOccurred())
+					Eventually(session).Should(gexec.Exit(1))
+					Expect(session.Out).Should(gbytes.Say("egress ip 192.0.2.55 is not an address of this cell"))
 				})
 			})
 		})
@@ -796,6 +1097,42 @@ var _ = Describe("CniWrapperPlugin", func() {
 
 			})
 
+			Context("when a rule denies traffic", func() {
+				BeforeEach(func() {
+					inputStruct.WrapperConfig.RuntimeConfig.NetOutRules[1].Action = "deny"
+					inputStruct.WrapperConfig.RuntimeConfig.NetOutRules[1].Priority = 10
+					input = GetInput(inputStruct)
+					cmd = cniCommand("ADD", input)
+				})
+
+				It("rejects the traffic ahead of the rules of lower priority", func() {
+					session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+					Expect(err).NotTo(HaveOccurred())
+					Eventually(session).Should(gexec.Exit(0))
+
+					Expect(AllIPTablesRules("filter")).To(gomegamatchers.ContainSequence([]string{
+						`-A ` + netoutChainName + ` -p tcp -m state --state INVALID -j DROP`,
+						`-A ` + netoutChainName + ` -p tcp -m iprange --dst-range 8.8.8.8-9.9.9.9 -m tcp --dport 53:54 -j REJECT --reject-with icmp-port-unreachable`,
+						`-A ` + netoutChainName + ` -p icmp -m iprange --dst-range 5.5.5.5-6.6.6.6 -m icmp --icmp-type 8/0 -j ACCEPT`,
+					}))
+				})
+			})
+
+			Context("when a rule has an invalid action", func() {
+				BeforeEach(func() {
+					inputStruct.WrapperConfig.RuntimeConfig.NetOutRules[1].Action = "drop"
+					input = GetInput(inputStruct)
+					cmd = cniCommand("ADD", input)
+				})
+
+				It("fails", func() {
+					session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+					Expect(err).NotTo(HaveOccurred())
+					Eventually(session).Should(gexec.Exit(1))
+					Expect(session.Out).Should(gbytes.Say("net out rules: action must be allow or deny"))
+				})
+			})
+
 			Context("when iptables_c2c_logging is enabled", func() {
 				BeforeEach(func() {
 					inputStruct.WrapperConfig.IPTablesC2CLogging = true
@@ -1107,7 +1444,11 @@ var _ = Describe("CniWrapperPlugin", func() {
 				Expect(err).NotTo(HaveOccurred())
 				Eventually(session).Should(gexec.Exit(1))
 
-				Expect(session.Out.Contents()).To(MatchJSON(`{ "code": 999, "msg": "store add: decoding file: invalid character 'b' looking for beginning of value" }`))
+				Expect(session.Out.Contents()).To(MatchJSON(`{
+					"code": 999,
+					"msg": "store add: decoding file: invalid character 'b' looking for beginning of value",
+					"details": "rolled back: delegate add"
+				}`))
 			})
 
 			It("does not leave any iptables rules behind", func() {
@@ -1120,6 +1461,219 @@ var _ = Describe("CniWrapperPlugin", func() {
 		})
 	})
 
+	Context("When call with command CHECK", func() {
+		var checkInput string
+
+		BeforeEach(func() {
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+
+			inputStruct.PrevResult = map[string]interface{}{
+				"cniVersion": "1.0.0",
+				"ips":        []map[string]interface{}{{"address": "1.2.3.4/32"}},
+			}
+			checkInput = GetInput(inputStruct)
+			cmd = cniCommand("CHECK", checkInput)
+		})
+
+		It("succeeds when everything created by ADD is present", func() {
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+			Expect(session.Out.Contents()).To(BeEmpty())
+		})
+
+		It("passes the prevResult to the delegate plugin", func() {
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+
+			debug, err := noop_debug.ReadDebug(debugFileName)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(debug.Command).To(Equal("CHECK"))
+
+			Expect(debug.CmdArgs.StdinData).To(MatchJSON(`{
+						"cniVersion": "1.0.0",
+						"type": "noop",
+						"some": "other data",
+						"name": "name",
+						"prevResult": {
+							"cniVersion": "1.0.0",
+							"ips": [{ "address": "1.2.3.4/32" }]
+						}
+					}`))
+		})
+
+		Context("when the runtime config has sysctls", func() {
+			BeforeEach(func() {
+				inputStruct.RuntimeConfig.Sysctls = map[string]string{"net.core.somaxconn": "1024"}
+				cmd = cniCommand("CHECK", GetInput(inputStruct))
+			})
+
+			It("passes them to the delegate plugin to check", func() {
+				session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(0))
+
+				debug, err := noop_debug.ReadDebug(debugFileName)
+				Expect(err).NotTo(HaveOccurred())
+				var delegateInput map[string]interface{}
+				Expect(json.Unmarshal([]byte(debug.CmdArgs.StdinData), &delegateInput)).To(Succeed())
+				Expect(delegateInput["runtimeConfig"]).To(Equal(map[string]interface{}{
+					"sysctls": map[string]interface{}{"net.core.somaxconn": "1024"},
+				}))
+			})
+		})
+
+		Context("when the prevResult is missing", func() {
+			BeforeEach(func() {
+				inputStruct.PrevResult = nil
+				cmd = cniCommand("CHECK", GetInput(inputStruct))
+			})
+
+			It("returns an error", func() {
+				session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(1))
+				Expect(session.Out.Contents()).To(MatchJSON(`{
+					"code": 7,
+					"msg": "missing prevResult"
+				}`))
+			})
+		})
+
+		Context("when the delegate plugin returns an error", func() {
+			BeforeEach(func() {
+				debug.ReportError = "banana"
+				Expect(debug.WriteDebug(debugFileName)).To(Succeed())
+			})
+
+			It("returns the error", func() {
+				session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(1))
+				Expect(session.Out.Contents()).To(ContainSubstring("banana"))
+			})
+		})
+
+		Context("when the container is not in the datastore", func() {
+			BeforeEach(func() {
+				cmd.Env[1] = "CNI_CONTAINERID=some-other-container-id"
+			})
+
+			It("returns an unknown container error", func() {
+				session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(1))
+				Expect(session.Out.Contents()).To(MatchJSON(`{
+					"code": 3,
+					"msg": "container not found in datastore",
+					"details": "some-other-container-id"
+				}`))
+			})
+		})
+
+		Context("when a netout jump rule is missing", func() {
+			BeforeEach(func() {
+				session, err := gexec.Start(exec.Command("iptables", "-w", "-D", "INPUT", "-s", "1.2.3.4", "-j", inputChainName), GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(0))
+			})
+
+			AfterEach(func() {
+				session, err := gexec.Start(exec.Command("iptables", "-w", "-A", "INPUT", "-s", "1.2.3.4", "-j", inputChainName), GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(0))
+			})
+
+			It("reports the missing rule", func() {
+				session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(1))
+				Expect(session.Out.Contents()).To(MatchJSON(fmt.Sprintf(`{
+					"code": 111,
+					"msg": "check net out",
+					"details": "missing jump rule [-s 1.2.3.4 --jump %s] in filter/INPUT"
+				}`, inputChainName)))
+			})
+		})
+
+		Context("when a netin chain is missing", func() {
+			BeforeEach(func() {
+				for _, args := range [][]string{
+					{"-t", "mangle", "-D", "PREROUTING", "-j", netinChainName},
+					{"-t", "mangle", "-F", netinChainName},
+					{"-t", "mangle", "-X", netinChainName},
+				} {
+					session, err := gexec.Start(exec.Command("iptables", append([]string{"-w"}, args...)...), GinkgoWriter, GinkgoWriter)
+					Expect(err).NotTo(HaveOccurred())
+					Eventually(session).Should(gexec.Exit(0))
+				}
+			})
+
+			AfterEach(func() {
+				for _, args := range [][]string{
+					{"-t", "mangle", "-N", netinChainName},
+					{"-t", "mangle", "-A", "PREROUTING", "-j", netinChainName},
+				} {
+					session, err := gexec.Start(exec.Command("iptables", append([]string{"-w"}, args...)...), GinkgoWriter, GinkgoWriter)
+					Expect(err).NotTo(HaveOccurred())
+					Eventually(session).Should(gexec.Exit(0))
+				}
+			})
+
+			It("reports the missing chain", func() {
+				session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(1))
+				Expect(session.Out.Contents()).To(MatchJSON(fmt.Sprintf(`{
+					"code": 112,
+					"msg": "check net in",
+					"details": "missing chain %s in table mangle"
+				}`, netinChainName)))
+			})
+		})
+
+		Context("when a port forwarding rule is missing", func() {
+			BeforeEach(func() {
+				session, err := gexec.Start(exec.Command("iptables", "-w", "-t", "nat", "-F", netinChainName), GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(0))
+			})
+
+			It("reports the missing rule", func() {
+				session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(1))
+				Expect(session.Out.Contents()).To(MatchJSON(fmt.Sprintf(`{
+					"code": 112,
+					"msg": "check net in",
+					"details": "missing rule [-d 10.244.2.3 -p tcp -m tcp --dport 1000 -j DNAT --to-destination 1.2.3.4:1001] in nat/%s"
+				}`, netinChainName)))
+			})
+		})
+
+		Context("when the ip masquerade rule is missing", func() {
+			BeforeEach(func() {
+				session, err := gexec.Start(exec.Command("iptables", "-w", "-t", "nat", "-D", "POSTROUTING", "-s", "1.2.3.4/32", "!", "-d", "10.255.0.0/16", "!", "-o", "some-device", "-j", "MASQUERADE"), GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(0))
+			})
+
+			It("reports the missing rule", func() {
+				session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(1))
+				Expect(session.Out.Contents()).To(MatchJSON(`{
+					"code": 113,
+					"msg": "check ip masquerade",
+					"details": "missing rule [--source 1.2.3.4 ! -o some-device ! --destination 10.255.0.0/16 --jump MASQUERADE] in nat/POSTROUTING"
+				}`))
+			})
+		})
+	})
+
 	Context("When call with command DEL", func() {
 		BeforeEach(func() {
 			cmd.Env[0] = "CNI_COMMAND=DEL"
@@ -1245,6 +1799,175 @@ var _ = Describe("CniWrapperPlugin", func() {
 
 	})
 
+	Context("When call with command GC", func() {
+		BeforeEach(func() {
+			debug.ReportVersionSupport = []string{"1.0.0", "1.1.0"}
+			Expect(debug.WriteDebug(debugFileName)).To(Succeed())
+
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+
+			inputStruct.CNIVersion = "1.1.0"
+		})
+
+		It("passes the valid attachments to the delegate plugin", func() {
+			inputStruct.ValidAttachments = []map[string]string{{"containerID": containerID, "ifname": "some-eth0"}}
+			session, err := gexec.Start(cniCommand("GC", GetInput(inputStruct)), GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+
+			debug, err := noop_debug.ReadDebug(debugFileName)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(debug.Command).To(Equal("GC"))
+
+			Expect(debug.CmdArgs.StdinData).To(MatchJSON(fmt.Sprintf(`{
+						"cniVersion": "1.1.0",
+						"type": "noop",
+						"some": "other data",
+						"name": "name",
+						"cni.dev/valid-attachments": [{"containerID": "%s", "ifname": "some-eth0"}]
+					}`, containerID)))
+		})
+
+		It("keeps the state of the valid attachments", func() {
+			inputStruct.ValidAttachments = []map[string]string{{"containerID": containerID, "ifname": "some-eth0"}}
+			session, err := gexec.Start(cniCommand("GC", GetInput(inputStruct)), GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+
+			stateFileBytes, err := os.ReadFile(datastorePath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(stateFileBytes)).To(ContainSubstring("1.2.3.4"))
+
+			Expect(AllIPTablesRules("nat")).To(ContainElement("-A POSTROUTING -s 1.2.3.4/32 ! -d 10.255.0.0/16 ! -o some-device -j MASQUERADE"))
+			Expect(AllIPTablesRules("mangle")).To(ContainElement(`-N ` + netinChainName))
+			Expect(AllIPTablesRules("filter")).To(ContainElement(`-N ` + netoutChainName))
+			Expect(policyAgentServer.CleanupOrphanedASGsEndpointCallCount).To(Equal(0))
+		})
+
+		It("removes the state of the attachments that are no longer valid", func() {
+			session, err := gexec.Start(cniCommand("GC", GetInput(inputStruct)), GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+
+			stateFileBytes, err := os.ReadFile(datastorePath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(stateFileBytes)).NotTo(ContainSubstring("1.2.3.4"))
+
+			Expect(AllIPTablesRules("nat")).NotTo(ContainElement("-A POSTROUTING -s 1.2.3.4/32 ! -d 10.255.0.0/16 ! -o some-device -j MASQUERADE"))
+			Expect(AllIPTablesRules("mangle")).NotTo(ContainElement(ContainSubstring(netinChainName)))
+			Expect(AllIPTablesRules("filter")).NotTo(ContainElement(ContainSubstring(netoutChainName)))
+			Expect(AllIPTablesRules("filter")).NotTo(ContainElement(ContainSubstring(inputChainName)))
+
+			Expect(policyAgentServer.CleanupOrphanedASGsEndpointCallCount).To(Equal(1))
+			Expect(policyAgentServer.CleanupOrphanedASGsEndpointContainerRequested).To(Equal(containerID))
+		})
+
+		Context("when secondary attachments are configured", func() {
+			BeforeEach(func() {
+				inputStruct.WrapperConfig.Attachments = []lib.AttachmentConfig{{
+					IfName: "net1",
+					Delegate: map[string]interface{}{
+						"type": "noop",
+						"name": "secondary",
+					},
+				}}
+			})
+
+			It("passes the valid attachments on the interface of the attachment to its delegate", func() {
+				inputStruct.ValidAttachments = []map[string]string{{"containerID": containerID, "ifname": "some-eth0"}}
+				session, err := gexec.Start(cniCommand("GC", GetInput(inputStruct)), GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(0))
+
+				debug, err := noop_debug.ReadDebug(debugFileName)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(debug.Command).To(Equal("GC"))
+				Expect(debug.CmdArgs.StdinData).To(MatchJSON(fmt.Sprintf(`{
+						"cniVersion": "1.1.0",
+						"type": "noop",
+						"name": "secondary",
+						"cni.dev/valid-attachments": [{"containerID": "%s", "ifname": "net1"}]
+					}`, containerID)))
+			})
+		})
+
+		Context("when the delegate plugin returns an error", func() {
+			BeforeEach(func() {
+				debug.ReportError = "banana"
+				Expect(debug.WriteDebug(debugFileName)).To(Succeed())
+			})
+
+			It("still removes the state of the attachments that are no longer valid and returns the error", func() {
+				session, err := gexec.Start(cniCommand("GC", GetInput(inputStruct)), GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(1))
+				Expect(session.Out.Contents()).To(ContainSubstring("banana"))
+
+				Expect(AllIPTablesRules("nat")).NotTo(ContainElement("-A POSTROUTING -s 1.2.3.4/32 ! -d 10.255.0.0/16 ! -o some-device -j MASQUERADE"))
+			})
+		})
+	})
+
+	Context("When call with command STATUS", func() {
+		BeforeEach(func() {
+			debug.ReportVersionSupport = []string{"1.0.0", "1.1.0"}
+			Expect(debug.WriteDebug(debugFileName)).To(Succeed())
+
+			inputStruct.CNIVersion = "1.1.0"
+		})
+
+		It("asks the delegate plugin for its status", func() {
+			session, err := gexec.Start(cniCommand("STATUS", GetInput(inputStruct)), GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+			Expect(session.Out.Contents()).To(BeEmpty())
+
+			debug, err := noop_debug.ReadDebug(debugFileName)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(debug.Command).To(Equal("STATUS"))
+			Expect(debug.CmdArgs.StdinData).To(MatchJSON(`{
+						"cniVersion": "1.1.0",
+						"type": "noop",
+						"some": "other data",
+						"name": "name"
+					}`))
+		})
+
+		Context("when the delegate plugin returns an error", func() {
+			BeforeEach(func() {
+				debug.ReportError = "banana"
+				Expect(debug.WriteDebug(debugFileName)).To(Succeed())
+			})
+
+			It("returns the error", func() {
+				session, err := gexec.Start(cniCommand("STATUS", GetInput(inputStruct)), GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(1))
+				Expect(session.Out.Contents()).To(ContainSubstring("banana"))
+			})
+		})
+
+		Context("when the policy agent is unreachable", func() {
+			BeforeEach(func() {
+				inputStruct.WrapperConfig.PolicyAgentForcePollAddress = fmt.Sprintf("127.0.0.1:%v", ports.PickAPort())
+			})
+
+			It("reports that the plugin is not available", func() {
+				session, err := gexec.Start(cniCommand("STATUS", GetInput(inputStruct)), GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(1))
+
+				var cniErr map[string]interface{}
+				Expect(json.Unmarshal(session.Out.Contents(), &cniErr)).To(Succeed())
+				Expect(cniErr["code"]).To(BeEquivalentTo(50))
+				Expect(cniErr["msg"]).To(Equal("policy agent force poll endpoint is unreachable"))
+				Expect(cniErr["details"]).To(ContainSubstring("connection refused"))
+			})
+		})
+	})
+
 })
 
 type mockPolicyAgentServer struct {
@@ -11,6 +11,9 @@ import (
 type Delegator interface {
 	DelegateAdd(delegatePlugin string, netconf []byte) (types.Result, error)
 	DelegateDel(delegatePlugin string, netconf []byte) error
+	DelegateCheck(delegatePlugin string, netconf []byte) error
+	DelegateGC(delegatePlugin string, netconf []byte) error
+	DelegateStatus(delegatePlugin string, netconf []byte) error
 }
 
 type delegator struct{}
@@ -23,4 +26,16 @@ func (*delegator) DelegateDel(delegatePlugin string, netconf []byte) error {
 	return invoke.DelegateDel(context.Background(), delegatePlugin, netconf, nil)
 }
 
+func (*delegator) DelegateCheck(delegatePlugin string, netconf []byte) error {
+	return invoke.DelegateCheck(context.Background(), delegatePlugin, netconf, nil)
+}
+
+func (*delegator) DelegateGC(delegatePlugin string, netconf []byte) error {
+	return invoke.DelegateGC(context.Background(), delegatePlugin, netconf, nil)
+}
+
+func (*delegator) DelegateStatus(delegatePlugin string, netconf []byte) error {
+	return invoke.DelegateStatus(context.Background(), delegatePlugin, netconf, nil)
+}
+
 func NewDelegator() Delegator { return &delegator{} }
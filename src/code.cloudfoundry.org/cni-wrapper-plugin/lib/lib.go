@@ -3,18 +3,89 @@ package lib
 import (
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
+	"net"
+	"os"
+	"slices"
 
+	"code.cloudfoundry.org/cni-wrapper-plugin/netrules"
 	"code.cloudfoundry.org/lib/rules"
 
-	"code.cloudfoundry.org/garden"
-
 	"github.com/containernetworking/cni/pkg/types"
+	current "github.com/containernetworking/cni/pkg/types/100"
 	"gopkg.in/validator.v2"
 )
 
 type RuntimeConfig struct {
-	PortMappings []garden.NetIn      `json:"portMappings"`
-	NetOutRules  []garden.NetOutRule `json:"netOutRules"`
+	PortMappings []PortMapping                `json:"portMappings"`
+	NetOutRules  []netrules.RuntimeNetOutRule `json:"netOutRules"`
+	Bandwidth    *BandwidthConfig             `json:"bandwidth,omitempty"`
+
+	// IPs is the ips capability of the CNI runtime config. It is passed on
+	// to the delegate, which allocates the requested IP to the container.
+	IPs []string `json:"ips,omitempty"`
+
+	// Sysctls and Routes are passed on to the delegate, which sets the
+	// sysctls and adds the routes in the container if they are allowed.
+	Sysctls map[string]string `json:"sysctls,omitempty"`
+	Routes  []string          `json:"routes,omitempty"`
+}
+
+// PortMapping is a garden.NetIn that may also select the protocol, tcp or
+// udp, and forward a range of host ports from HostPort to HostPortEnd to the
+// same ports of the container.
+type PortMapping struct {
+	HostPort      uint32 `json:"host_port"`
+	HostPortEnd   uint32 `json:"host_port_end,omitempty"`
+	ContainerPort uint32 `json:"container_port"`
+	Protocol      string `json:"protocol,omitempty"`
+}
+
+// BandwidthConfig is the bandwidth capability of the CNI runtime config.
+// It is passed on to the delegate, which limits the container traffic.
+type BandwidthConfig struct {
+	IngressRate  uint64 `json:"ingressRate"`
+	IngressBurst uint64 `json:"ingressBurst"`
+	EgressRate   uint64 `json:"egressRate"`
+	EgressBurst  uint64 `json:"egressBurst"`
+}
+
+// BandwidthMetadataKeys are the metadata keys the delegate reads the
+// bandwidth limits of a container from, when there is no runtime config.
+var BandwidthMetadataKeys = []string{
+	"silk_ingress_rate",
+	"silk_ingress_burst",
+	"silk_egress_rate",
+	"silk_egress_burst",
+}
+
+// HasBandwidthMetadata reports whether the metadata sets any bandwidth limit.
+func HasBandwidthMetadata(metadata map[string]interface{}) bool {
+	for _, key := range BandwidthMetadataKeys {
+		if _, ok := metadata[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainerOptionsMetadataKeys are the metadata keys the delegate reads the
+// sysctls and extra routes of a container from, when there is no runtime
+// config.
+var ContainerOptionsMetadataKeys = []string{
+	"silk_sysctls",
+	"silk_routes",
+}
+
+// HasContainerOptionsMetadata reports whether the metadata requests any
+// sysctls or extra routes.
+func HasContainerOptionsMetadata(metadata map[string]interface{}) bool {
+	for _, key := range ContainerOptionsMetadataKeys {
+		if _, ok := metadata[key]; ok {
+			return true
+		}
+	}
+	return false
 }
 
 type DenyNetworksConfig struct {
@@ -31,12 +102,82 @@ type OutConnConfig struct {
 	DryRun     bool `json:"dry_run"`
 }
 
+// OverlayConfig describes an additional silk overlay. Containers whose IP is
+// inside Network are attached to the VTEP named VTEPName.
+type OverlayConfig struct {
+	Name     string `json:"name"`
+	Network  string `json:"network"`
+	VTEPName string `json:"vtep_name"`
+}
+
+// AttachmentConfig is a secondary network attachment that every container
+// gets next to its silk interface, e.g. onto a VLAN or macvlan network.
+// The delegate is invoked with IfName as the interface name.
+type AttachmentConfig struct {
+	IfName   string                 `json:"ifname"`
+	Delegate map[string]interface{} `json:"delegate"`
+}
+
+// RecordedAttachments returns the attachments that ADD recorded in the
+// "attachments" metadata of a container, so that DEL, CHECK and GC use the
+// delegates the container was created with rather than the current config.
+func RecordedAttachments(metadata map[string]interface{}) ([]AttachmentConfig, error) {
+	recorded, ok := metadata["attachments"]
+	if !ok {
+		return nil, nil
+	}
+
+	recordedBytes, err := json.Marshal(recorded)
+	if err != nil {
+		return nil, fmt.Errorf("marshal attachments: %s", err) // not tested
+	}
+	var attachments []AttachmentConfig
+	if err := json.Unmarshal(recordedBytes, &attachments); err != nil {
+		return nil, fmt.Errorf("unmarshal attachments: %s", err)
+	}
+	return attachments, nil
+}
+
+// AttachmentResult returns the part of the result of ADD that the delegate
+// of the attachment on ifName created: its interfaces and their IPs.
+func AttachmentResult(result *current.Result, ifName string) *current.Result {
+	attachmentResult := &current.Result{CNIVersion: result.CNIVersion}
+	indexes := map[int]int{}
+	for i, iface := range result.Interfaces {
+		if iface.Name == ifName {
+			indexes[i] = len(attachmentResult.Interfaces)
+			attachmentResult.Interfaces = append(attachmentResult.Interfaces, iface)
+		}
+	}
+	for _, ip := range result.IPs {
+		if ip.Interface == nil {
+			continue
+		}
+		if index, ok := indexes[*ip.Interface]; ok {
+			attachmentIP := *ip
+			attachmentIP.Interface = current.Int(index)
+			attachmentResult.IPs = append(attachmentResult.IPs, &attachmentIP)
+		}
+	}
+	return attachmentResult
+}
+
+// EgressIPConfig selects containers by app or space GUID whose egress is
+// SNATed to one of IPs instead of the underlay IP of the cell. The IPs must
+// be secondary IPs of the cell.
+type EgressIPConfig struct {
+	IPs        []string `json:"ips"`
+	AppGUIDs   []string `json:"app_guids"`
+	SpaceGUIDs []string `json:"space_guids"`
+}
+
 type WrapperConfig struct {
 	CNIVersion                      string                 `json:"cniVersion"`
 	Datastore                       string                 `json:"datastore"`
 	DatastoreFileOwner              string                 `json:"datastore_file_owner"`
 	DatastoreFileGroup              string                 `json:"datastore_file_group"`
 	IPTablesLockFile                string                 `json:"iptables_lock_file"`
+	FirewallBackend                 string                 `json:"firewall_backend"`
 	Delegate                        map[string]interface{} `json:"delegate"`
 	InstanceAddress                 string                 `json:"instance_address"`
 	NoMasqueradeCIDRRange           string                 `json:"no_masquerade_cidr_range"`
@@ -47,14 +188,75 @@ type WrapperConfig struct {
 	UnderlayIPs                     []string               `json:"underlay_ips"`
 	TemporaryUnderlayInterfaceNames []string               `json:"temporary_underlay_interface_names"`
 	IPTablesASGLogging              bool                   `json:"iptables_asg_logging"`
+	IPTablesASGIPSets               bool                   `json:"iptables_asg_ipsets"`
 	IPTablesC2CLogging              bool                   `json:"iptables_c2c_logging"`
 	IPTablesDeniedLogsPerSec        int                    `json:"iptables_denied_logs_per_sec" validate:"min=1"`
 	IPTablesAcceptedUDPLogsPerSec   int                    `json:"iptables_accepted_udp_logs_per_sec" validate:"min=1"`
 	IngressTag                      string                 `json:"ingress_tag"`
 	VTEPName                        string                 `json:"vtep_name"`
 	RuntimeConfig                   RuntimeConfig          `json:"runtimeConfig,omitempty"`
-	PolicyAgentForcePollAddress     string                 `json:"policy_agent_force_poll_address" validate:"nonzero"`
+	PolicyAgentForcePollAddress     string                 `json:"policy_agent_force_poll_address"`
+	PolicyAgentForcePollSocket      string                 `json:"policy_agent_force_poll_socket"`
+	PolicyAgentForcePollCACertFile  string                 `json:"policy_agent_force_poll_ca_cert_file"`
+	PolicyAgentForcePollCertFile    string                 `json:"policy_agent_force_poll_cert_file"`
+	PolicyAgentForcePollKeyFile     string                 `json:"policy_agent_force_poll_key_file"`
 	OutConn                         OutConnConfig          `json:"outbound_connections"`
+	AdditionalOverlays              []OverlayConfig        `json:"additional_overlays"`
+	Attachments                     []AttachmentConfig     `json:"attachments"`
+	EgressIPs                       []EgressIPConfig       `json:"egress_ips"`
+}
+
+// VTEPNameForIP returns the name of the VTEP for the overlay that contains the
+// given container IP. Containers outside of all additional overlays are on the
+// default overlay.
+func (c *WrapperConfig) VTEPNameForIP(ip net.IP) string {
+	for _, overlay := range c.AdditionalOverlays {
+		_, network, err := net.ParseCIDR(overlay.Network)
+		if err != nil {
+			continue // validated in LoadWrapperConfig
+		}
+		if network.Contains(ip) {
+			return overlay.VTEPName
+		}
+	}
+	return c.VTEPName
+}
+
+// EgressIPForContainer returns the egress IP of the container, or "" when
+// its egress is masqueraded. A match on the app GUID wins over a match on the
+// space GUID. Apps sharing a config are spread over its IPs by app GUID, so
+// that all instances of an app keep the same IP.
+func (c *WrapperConfig) EgressIPForContainer(metadata map[string]interface{}) string {
+	appGUID, _ := metadata["app_id"].(string)
+	spaceGUID, _ := metadata["space_id"].(string)
+
+	key := appGUID
+	if key == "" {
+		key = spaceGUID
+	}
+
+	if appGUID != "" {
+		for _, egressIP := range c.EgressIPs {
+			if slices.Contains(egressIP.AppGUIDs, appGUID) {
+				return pickEgressIP(egressIP.IPs, key)
+			}
+		}
+	}
+	if spaceGUID != "" {
+		for _, egressIP := range c.EgressIPs {
+			if slices.Contains(egressIP.SpaceGUIDs, spaceGUID) {
+				return pickEgressIP(egressIP.IPs, key)
+			}
+		}
+	}
+	return ""
+}
+
+func pickEgressIP(ips []string, key string) string {
+	h := fnv.New32a()
+	// #nosec G104 - writing to a hash never fails
+	h.Write([]byte(key))
+	return ips[h.Sum32()%uint32(len(ips))]
 }
 
 func LoadWrapperConfig(bytes []byte) (*WrapperConfig, error) {
@@ -71,6 +273,14 @@ func LoadWrapperConfig(bytes []byte) (*WrapperConfig, error) {
 		return nil, fmt.Errorf("missing iptables lock file path")
 	}
 
+	if err := rules.ValidateBackend(n.FirewallBackend); err != nil {
+		return nil, err
+	}
+
+	if err := rules.ValidateIPSets(n.FirewallBackend, n.IPTablesASGIPSets); err != nil {
+		return nil, err
+	}
+
 	if n.InstanceAddress == "" {
 		return nil, fmt.Errorf("missing instance address")
 	}
@@ -87,6 +297,18 @@ func LoadWrapperConfig(bytes []byte) (*WrapperConfig, error) {
 		return nil, fmt.Errorf("missing vtep device name")
 	}
 
+	if n.PolicyAgentForcePollAddress == "" && n.PolicyAgentForcePollSocket == "" {
+		return nil, fmt.Errorf("missing policy agent force poll address or socket")
+	}
+
+	tlsFiles := []string{n.PolicyAgentForcePollCACertFile, n.PolicyAgentForcePollCertFile, n.PolicyAgentForcePollKeyFile}
+	if slices.Contains(tlsFiles, "") && slices.ContainsFunc(tlsFiles, func(f string) bool { return f != "" }) {
+		return nil, fmt.Errorf("policy agent force poll tls requires a ca cert, cert and key file")
+	}
+	if n.PolicyAgentForcePollSocket != "" && n.PolicyAgentForcePollCACertFile != "" {
+		return nil, fmt.Errorf("policy agent force poll socket cannot be combined with tls")
+	}
+
 	if n.IPTablesDeniedLogsPerSec <= 0 {
 		return nil, fmt.Errorf("invalid denied logs per sec")
 	}
@@ -107,6 +329,50 @@ func LoadWrapperConfig(bytes []byte) (*WrapperConfig, error) {
 		return nil, fmt.Errorf("invalid outbound connection rate")
 	}
 
+	for _, overlay := range n.AdditionalOverlays {
+		if overlay.VTEPName == "" {
+			return nil, fmt.Errorf("missing vtep device name for overlay %s", overlay.Name)
+		}
+		if _, _, err := net.ParseCIDR(overlay.Network); err != nil {
+			return nil, fmt.Errorf("invalid network for overlay %s: %s", overlay.Name, err)
+		}
+	}
+
+	ifNames := map[string]bool{}
+	for i, attachment := range n.Attachments {
+		if attachment.IfName == "" {
+			return nil, fmt.Errorf("missing interface name for attachment %d", i)
+		}
+		if len(attachment.IfName) > 15 {
+			return nil, fmt.Errorf("interface name %s of attachment %d is longer than 15 characters", attachment.IfName, i)
+		}
+		if ifNames[attachment.IfName] {
+			return nil, fmt.Errorf("duplicate interface name %s for attachment %d", attachment.IfName, i)
+		}
+		ifNames[attachment.IfName] = true
+
+		if _, ok := attachment.Delegate["type"].(string); !ok {
+			return nil, fmt.Errorf("missing delegate type for attachment %s", attachment.IfName)
+		}
+		if _, ok := attachment.Delegate["cniVersion"]; !ok {
+			attachment.Delegate["cniVersion"] = "1.0.0"
+		}
+	}
+
+	for i, egressIP := range n.EgressIPs {
+		if len(egressIP.IPs) == 0 {
+			return nil, fmt.Errorf("missing ips for egress ip %d", i)
+		}
+		for _, ip := range egressIP.IPs {
+			if parsed := net.ParseIP(ip); parsed == nil || parsed.To4() == nil {
+				return nil, fmt.Errorf("invalid ip %s for egress ip %d", ip, i)
+			}
+		}
+		if len(egressIP.AppGUIDs) == 0 && len(egressIP.SpaceGUIDs) == 0 {
+			return nil, fmt.Errorf("missing app or space guids for egress ip %d", i)
+		}
+	}
+
 	err := validator.Validate(n)
 	if err != nil {
 		return nil, fmt.Errorf("validator: %s", err)
@@ -115,9 +381,24 @@ func LoadWrapperConfig(bytes []byte) (*WrapperConfig, error) {
 	return n, nil
 }
 
+// Error codes returned by CHECK when the host state of a container no longer
+// matches what ADD created. Codes 101-105 are used by the silk delegate.
+const (
+	ErrMetadataDrift     uint = 110
+	ErrNetOutDrift       uint = 111
+	ErrNetInDrift        uint = 112
+	ErrIPMasqueradeDrift uint = 113
+)
+
+// ErrPluginNotAvailable is returned by STATUS when containers cannot be added.
+// The vendored CNI types do not define the STATUS error codes yet.
+const ErrPluginNotAvailable uint = 50
+
 type PluginController struct {
 	Delegator Delegator
 	IPTables  rules.IPTablesAdapter
+	// IPSets is nil unless ASG ipsets are enabled.
+	IPSets rules.IPSetAdapter
 }
 
 func getDelegateParams(netconf map[string]interface{}) (string, []byte, error) {
@@ -152,8 +433,77 @@ func (c *PluginController) DelegateDel(netconf map[string]interface{}) error {
 	return c.Delegator.DelegateDel(delegateType, netconfBytes)
 }
 
-func (c *PluginController) AddIPMasq(ip, noMasqueradeCIDRRange, deviceName string) error {
-	rule := rules.NewDefaultEgressRule(ip, noMasqueradeCIDRRange, deviceName)
+func (c *PluginController) DelegateCheck(netconf map[string]interface{}) error {
+	delegateType, netconfBytes, err := getDelegateParams(netconf)
+	if err != nil {
+		return err
+	}
+
+	return c.Delegator.DelegateCheck(delegateType, netconfBytes)
+}
+
+func (c *PluginController) DelegateGC(netconf map[string]interface{}) error {
+	delegateType, netconfBytes, err := getDelegateParams(netconf)
+	if err != nil {
+		return err
+	}
+
+	return c.Delegator.DelegateGC(delegateType, netconfBytes)
+}
+
+func (c *PluginController) DelegateStatus(netconf map[string]interface{}) error {
+	delegateType, netconfBytes, err := getDelegateParams(netconf)
+	if err != nil {
+		return err
+	}
+
+	return c.Delegator.DelegateStatus(delegateType, netconfBytes)
+}
+
+// DelegateAddAttachment invokes the delegate of a secondary attachment with
+// the interface name of the attachment.
+func (c *PluginController) DelegateAddAttachment(attachment AttachmentConfig) (types.Result, error) {
+	defer withIfName(attachment.IfName)()
+	return c.DelegateAdd(attachment.Delegate)
+}
+
+// DelegateDelAttachment invokes the delegate of a secondary attachment with
+// the interface name of the attachment.
+func (c *PluginController) DelegateDelAttachment(attachment AttachmentConfig) error {
+	defer withIfName(attachment.IfName)()
+	return c.DelegateDel(attachment.Delegate)
+}
+
+// DelegateCheckAttachment invokes the delegate of a secondary attachment
+// with the interface name of the attachment.
+func (c *PluginController) DelegateCheckAttachment(attachment AttachmentConfig) error {
+	defer withIfName(attachment.IfName)()
+	return c.DelegateCheck(attachment.Delegate)
+}
+
+// withIfName sets the interface name that delegates inherit from the
+// environment, and returns a func that restores the previous one.
+func withIfName(ifName string) func() {
+	previous := os.Getenv("CNI_IFNAME")
+	// #nosec G104 - setting an environment variable only fails for invalid names
+	os.Setenv("CNI_IFNAME", ifName)
+	return func() {
+		// #nosec G104 - see above
+		os.Setenv("CNI_IFNAME", previous)
+	}
+}
+
+// egressRule masquerades the container's egress, or SNATs it when the
+// container has an egress IP.
+func egressRule(ip, noMasqueradeCIDRRange, deviceName, egressIP string) rules.IPTablesRule {
+	if egressIP != "" {
+		return rules.NewEgressSNATRule(ip, noMasqueradeCIDRRange, deviceName, egressIP)
+	}
+	return rules.NewDefaultEgressRule(ip, noMasqueradeCIDRRange, deviceName)
+}
+
+func (c *PluginController) AddIPMasq(ip, noMasqueradeCIDRRange, deviceName, egressIP string) error {
+	rule := egressRule(ip, noMasqueradeCIDRRange, deviceName, egressIP)
 
 	if err := c.IPTables.BulkAppend("nat", "POSTROUTING", rule); err != nil {
 		return err
@@ -162,8 +512,8 @@ func (c *PluginController) AddIPMasq(ip, noMasqueradeCIDRRange, deviceName strin
 	return nil
 }
 
-func (c *PluginController) DelIPMasq(ip, noMasqueradeCIDRRange, deviceName string) error {
-	rule := rules.NewDefaultEgressRule(ip, noMasqueradeCIDRRange, deviceName)
+func (c *PluginController) DelIPMasq(ip, noMasqueradeCIDRRange, deviceName, egressIP string) error {
+	rule := egressRule(ip, noMasqueradeCIDRRange, deviceName, egressIP)
 
 	if err := c.IPTables.Delete("nat", "POSTROUTING", rule); err != nil {
 		return err
@@ -171,3 +521,17 @@ func (c *PluginController) DelIPMasq(ip, noMasqueradeCIDRRange, deviceName strin
 
 	return nil
 }
+
+func (c *PluginController) CheckIPMasq(ip, noMasqueradeCIDRRange, deviceName, egressIP string) error {
+	rule := egressRule(ip, noMasqueradeCIDRRange, deviceName, egressIP)
+
+	exists, err := c.IPTables.Exists("nat", "POSTROUTING", rule)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("missing rule %v in nat/POSTROUTING", rule)
+	}
+
+	return nil
+}
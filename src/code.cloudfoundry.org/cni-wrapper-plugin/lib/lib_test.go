@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
+	"os"
 
 	"code.cloudfoundry.org/cni-wrapper-plugin/fakes"
 	"code.cloudfoundry.org/cni-wrapper-plugin/lib"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/containernetworking/cni/pkg/types"
 	types020 "github.com/containernetworking/cni/pkg/types/020"
+	current "github.com/containernetworking/cni/pkg/types/100"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -30,6 +32,7 @@ var _ = Describe("LoadWrapperConfig", func() {
 			"underlay_ips": ["10.244.20.1", "10.244.20.2"],
 			"temporary_underlay_interface_names": ["some-temporary-underlay-interface-name"],
 			"iptables_asg_logging": true,
+			"iptables_asg_ipsets": true,
 			"ingress_tag": "ffaa0000",
 			"vtep_name": "some-device",
 			"delegate": {
@@ -62,6 +65,7 @@ var _ = Describe("LoadWrapperConfig", func() {
 			TemporaryUnderlayInterfaceNames: []string{"some-temporary-underlay-interface-name"},
 			PolicyAgentForcePollAddress:     "http://127.0.0.1:1234",
 			IPTablesASGLogging:              true,
+			IPTablesASGIPSets:               true,
 			Delegate: map[string]interface{}{
 				"cniVersion": "1.0.0",
 				"some":       "info",
@@ -143,6 +147,7 @@ var _ = Describe("LoadWrapperConfig", func() {
 		Entry("vtep device name", "vtep_name", "missing vtep device name"),
 		Entry("denied logs per sec", "iptables_denied_logs_per_sec", "invalid denied logs per sec"),
 		Entry("accepted udp logs per sec", "iptables_accepted_udp_logs_per_sec", "invalid accepted udp logs per sec"),
+		Entry("policy agent force poll address", "policy_agent_force_poll_address", "missing policy agent force poll address or socket"),
 	)
 
 	DescribeTable("invalid value for field", func(field string, value interface{}, errMessage string) {
@@ -156,11 +161,208 @@ var _ = Describe("LoadWrapperConfig", func() {
 		_, err = lib.LoadWrapperConfig(input)
 		Expect(err).To(MatchError(errMessage))
 	},
+		Entry("firewall backend", "firewall_backend", "ebtables", `invalid firewall backend "ebtables": must be iptables or nftables`),
+		Entry("asg ipsets with nftables", "firewall_backend", "nftables", "ASG ipsets require the iptables firewall backend"),
 		Entry("denied logs per sec", "iptables_denied_logs_per_sec", -1, "invalid denied logs per sec"),
 		Entry("accepted udp logs per sec", "iptables_accepted_udp_logs_per_sec", -1, "invalid accepted udp logs per sec"),
 		Entry("out conn burst", "outbound_connections", map[string]interface{}{"burst": -1}, "invalid outbound connection burst"),
 		Entry("out conn rate", "outbound_connections", map[string]interface{}{"burst": 1, "rate_per_sec": -1}, "invalid outbound connection rate"),
+		Entry("overlay vtep name", "additional_overlays", []map[string]interface{}{{"name": "isolated", "network": "10.254.0.0/16"}}, "missing vtep device name for overlay isolated"),
+		Entry("overlay network", "additional_overlays", []map[string]interface{}{{"name": "isolated", "network": "banana", "vtep_name": "silk-vtep-iso"}}, "invalid network for overlay isolated: invalid CIDR address: banana"),
+		Entry("attachment ifname", "attachments", []map[string]interface{}{{"delegate": map[string]interface{}{"type": "macvlan"}}}, "missing interface name for attachment 0"),
+		Entry("attachment ifname length", "attachments", []map[string]interface{}{{"ifname": "some-very-long-name", "delegate": map[string]interface{}{"type": "macvlan"}}}, "interface name some-very-long-name of attachment 0 is longer than 15 characters"),
+		Entry("attachment duplicate ifname", "attachments", []map[string]interface{}{
+			{"ifname": "net1", "delegate": map[string]interface{}{"type": "macvlan"}},
+			{"ifname": "net1", "delegate": map[string]interface{}{"type": "vlan"}},
+		}, "duplicate interface name net1 for attachment 1"),
+		Entry("policy agent tls without a key", "policy_agent_force_poll_ca_cert_file", "/some/ca.crt", "policy agent force poll tls requires a ca cert, cert and key file"),
+		Entry("egress ips without ips", "egress_ips", []map[string]interface{}{{"space_guids": []string{"some-space-guid"}}}, "missing ips for egress ip 0"),
+		Entry("egress ips with an invalid ip", "egress_ips", []map[string]interface{}{{"ips": []string{"banana"}, "space_guids": []string{"some-space-guid"}}}, "invalid ip banana for egress ip 0"),
+		Entry("egress ips without guids", "egress_ips", []map[string]interface{}{{"ips": []string{"10.0.16.20"}}}, "missing app or space guids for egress ip 0"),
+		Entry("attachment delegate type", "attachments", []map[string]interface{}{{"ifname": "net1", "delegate": map[string]interface{}{"some": "info"}}}, "missing delegate type for attachment net1"),
 	)
+
+	Describe("attachments", func() {
+		BeforeEach(func() {
+			var config map[string]interface{}
+			Expect(json.Unmarshal(input, &config)).To(Succeed())
+			config["attachments"] = []map[string]interface{}{
+				{"ifname": "net1", "delegate": map[string]interface{}{"type": "macvlan"}},
+				{"ifname": "net2", "delegate": map[string]interface{}{"type": "vlan", "cniVersion": "0.4.0"}},
+			}
+
+			var err error
+			input, err = json.Marshal(config)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("parses the attachments in order and defaults their cniVersion", func() {
+			conf, err := lib.LoadWrapperConfig(input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(conf.Attachments).To(Equal([]lib.AttachmentConfig{
+				{IfName: "net1", Delegate: map[string]interface{}{"type": "macvlan", "cniVersion": "1.0.0"}},
+				{IfName: "net2", Delegate: map[string]interface{}{"type": "vlan", "cniVersion": "0.4.0"}},
+			}))
+		})
+	})
+
+	Describe("EgressIPForContainer", func() {
+		var conf *lib.WrapperConfig
+
+		BeforeEach(func() {
+			var config map[string]interface{}
+			Expect(json.Unmarshal(input, &config)).To(Succeed())
+			config["egress_ips"] = []map[string]interface{}{
+				{"ips": []string{"10.0.16.20", "10.0.16.21"}, "space_guids": []string{"some-space-guid"}},
+				{"ips": []string{"10.0.16.30"}, "app_guids": []string{"some-app-guid"}},
+			}
+
+			var err error
+			input, err = json.Marshal(config)
+			Expect(err).NotTo(HaveOccurred())
+			conf, err = lib.LoadWrapperConfig(input)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("returns one of the ips selected by the space guid", func() {
+			egressIP := conf.EgressIPForContainer(map[string]interface{}{"space_id": "some-space-guid"})
+			Expect(egressIP).To(BeElementOf("10.0.16.20", "10.0.16.21"))
+			Expect(conf.EgressIPForContainer(map[string]interface{}{"space_id": "some-space-guid"})).To(Equal(egressIP))
+		})
+
+		It("picks the ip by app guid, so that all instances of an app share it", func() {
+			ipsByApp := map[string]string{}
+			for _, appGUID := range []string{"app-1", "app-2", "app-3", "app-4", "app-5", "app-6"} {
+				ipsByApp[appGUID] = conf.EgressIPForContainer(map[string]interface{}{
+					"app_id":   appGUID,
+					"space_id": "some-space-guid",
+				})
+				Expect(conf.EgressIPForContainer(map[string]interface{}{
+					"app_id":        appGUID,
+					"space_id":      "some-space-guid",
+					"instance_guid": "another-instance",
+				})).To(Equal(ipsByApp[appGUID]))
+			}
+			Expect(ipsByApp).To(ContainElements("10.0.16.20", "10.0.16.21"))
+		})
+
+		It("prefers a match on the app guid", func() {
+			Expect(conf.EgressIPForContainer(map[string]interface{}{
+				"app_id":   "some-app-guid",
+				"space_id": "some-space-guid",
+			})).To(Equal("10.0.16.30"))
+		})
+
+		It("returns no ip for other containers", func() {
+			Expect(conf.EgressIPForContainer(map[string]interface{}{"space_id": "other-space-guid"})).To(BeEmpty())
+			Expect(conf.EgressIPForContainer(nil)).To(BeEmpty())
+		})
+	})
+
+	Describe("VTEPNameForIP", func() {
+		BeforeEach(func() {
+			var config map[string]interface{}
+			Expect(json.Unmarshal(input, &config)).To(Succeed())
+			config["additional_overlays"] = []map[string]interface{}{
+				{"name": "isolated", "network": "10.254.0.0/16", "vtep_name": "silk-vtep-iso"},
+			}
+
+			var err error
+			input, err = json.Marshal(config)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("returns the vtep of the overlay containing the ip", func() {
+			conf, err := lib.LoadWrapperConfig(input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(conf.VTEPNameForIP(net.ParseIP("10.254.3.4"))).To(Equal("silk-vtep-iso"))
+		})
+
+		It("returns the default vtep for other ips", func() {
+			conf, err := lib.LoadWrapperConfig(input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(conf.VTEPNameForIP(net.ParseIP("10.255.3.4"))).To(Equal("some-device"))
+		})
+	})
+
+	Describe("runtime config port mappings", func() {
+		BeforeEach(func() {
+			var config map[string]interface{}
+			Expect(json.Unmarshal(input, &config)).To(Succeed())
+			config["runtimeConfig"] = map[string]interface{}{
+				"portMappings": []map[string]interface{}{
+					{"host_port": 1000, "container_port": 8080},
+					{"host_port": 2000, "host_port_end": 2010, "container_port": 2000, "protocol": "udp"},
+				},
+			}
+
+			var err error
+			input, err = json.Marshal(config)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("parses the protocols and port ranges", func() {
+			conf, err := lib.LoadWrapperConfig(input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(conf.RuntimeConfig.PortMappings).To(Equal([]lib.PortMapping{
+				{HostPort: 1000, ContainerPort: 8080},
+				{HostPort: 2000, HostPortEnd: 2010, ContainerPort: 2000, Protocol: "udp"},
+			}))
+		})
+	})
+
+	Describe("runtime config bandwidth", func() {
+		BeforeEach(func() {
+			var config map[string]interface{}
+			Expect(json.Unmarshal(input, &config)).To(Succeed())
+			config["runtimeConfig"] = map[string]interface{}{
+				"bandwidth": map[string]interface{}{
+					"ingressRate":  1000,
+					"ingressBurst": 2000,
+					"egressRate":   3000,
+					"egressBurst":  4000,
+				},
+			}
+
+			var err error
+			input, err = json.Marshal(config)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("parses the bandwidth limits", func() {
+			conf, err := lib.LoadWrapperConfig(input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(conf.RuntimeConfig.Bandwidth).To(Equal(&lib.BandwidthConfig{
+				IngressRate:  1000,
+				IngressBurst: 2000,
+				EgressRate:   3000,
+				EgressBurst:  4000,
+			}))
+		})
+	})
+})
+
+var _ = Describe("HasBandwidthMetadata", func() {
+	It("returns true when the metadata sets a bandwidth limit", func() {
+		Expect(lib.HasBandwidthMetadata(map[string]interface{}{"silk_egress_rate": "1000"})).To(BeTrue())
+	})
+
+	It("returns false otherwise", func() {
+		Expect(lib.HasBandwidthMetadata(map[string]interface{}{"app_id": "some-app"})).To(BeFalse())
+		Expect(lib.HasBandwidthMetadata(nil)).To(BeFalse())
+	})
+})
+
+var _ = Describe("HasContainerOptionsMetadata", func() {
+	It("returns true when the metadata requests sysctls or routes", func() {
+		Expect(lib.HasContainerOptionsMetadata(map[string]interface{}{"silk_sysctls": map[string]interface{}{}})).To(BeTrue())
+		Expect(lib.HasContainerOptionsMetadata(map[string]interface{}{"silk_routes": []interface{}{}})).To(BeTrue())
+	})
+
+	It("returns false otherwise", func() {
+		Expect(lib.HasContainerOptionsMetadata(map[string]interface{}{"app_id": "some-app"})).To(BeFalse())
+		Expect(lib.HasContainerOptionsMetadata(nil)).To(BeFalse())
+	})
 })
 
 var _ = Describe("DelegateAdd", func() {
@@ -302,6 +504,301 @@ var _ = Describe("DelegateDel", func() {
 	})
 })
 
+var _ = Describe("Attachments", func() {
+	var (
+		attachment       lib.AttachmentConfig
+		pluginController *lib.PluginController
+		fakeDelegator    *fakes.Delegator
+		ifNameOnCall     string
+	)
+
+	BeforeEach(func() {
+		os.Setenv("CNI_IFNAME", "eth0")
+		fakeDelegator = &fakes.Delegator{}
+		fakeDelegator.DelegateAddStub = func(string, []byte) (types.Result, error) {
+			ifNameOnCall = os.Getenv("CNI_IFNAME")
+			return &types020.Result{}, nil
+		}
+		fakeDelegator.DelegateDelStub = func(string, []byte) error {
+			ifNameOnCall = os.Getenv("CNI_IFNAME")
+			return nil
+		}
+		pluginController = &lib.PluginController{
+			Delegator: fakeDelegator,
+		}
+
+		attachment = lib.AttachmentConfig{
+			IfName:   "net1",
+			Delegate: map[string]interface{}{"type": "macvlan"},
+		}
+	})
+
+	AfterEach(func() {
+		os.Unsetenv("CNI_IFNAME")
+	})
+
+	Describe("DelegateAddAttachment", func() {
+		It("calls the delegate with the interface name of the attachment", func() {
+			_, err := pluginController.DelegateAddAttachment(attachment)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeDelegator.DelegateAddCallCount()).To(Equal(1))
+			delegatePlugin, _ := fakeDelegator.DelegateAddArgsForCall(0)
+			Expect(delegatePlugin).To(Equal("macvlan"))
+			Expect(ifNameOnCall).To(Equal("net1"))
+		})
+
+		It("restores the interface name afterwards", func() {
+			_, err := pluginController.DelegateAddAttachment(attachment)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.Getenv("CNI_IFNAME")).To(Equal("eth0"))
+		})
+	})
+
+	Describe("DelegateDelAttachment", func() {
+		It("calls the delegate with the interface name of the attachment", func() {
+			err := pluginController.DelegateDelAttachment(attachment)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeDelegator.DelegateDelCallCount()).To(Equal(1))
+			Expect(ifNameOnCall).To(Equal("net1"))
+			Expect(os.Getenv("CNI_IFNAME")).To(Equal("eth0"))
+		})
+	})
+
+	Describe("DelegateCheckAttachment", func() {
+		BeforeEach(func() {
+			fakeDelegator.DelegateCheckStub = func(string, []byte) error {
+				ifNameOnCall = os.Getenv("CNI_IFNAME")
+				return nil
+			}
+		})
+
+		It("calls the delegate with the interface name of the attachment", func() {
+			err := pluginController.DelegateCheckAttachment(attachment)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeDelegator.DelegateCheckCallCount()).To(Equal(1))
+			delegatePlugin, _ := fakeDelegator.DelegateCheckArgsForCall(0)
+			Expect(delegatePlugin).To(Equal("macvlan"))
+			Expect(ifNameOnCall).To(Equal("net1"))
+			Expect(os.Getenv("CNI_IFNAME")).To(Equal("eth0"))
+		})
+	})
+
+	Describe("RecordedAttachments", func() {
+		It("returns the attachments recorded in the metadata read from the datastore", func() {
+			var metadata map[string]interface{}
+			Expect(json.Unmarshal([]byte(`{
+				"attachments": [{"ifname": "net1", "ip": "192.168.1.5", "delegate": {"type": "macvlan", "master": "eth1"}}]
+			}`), &metadata)).To(Succeed())
+
+			attachments, err := lib.RecordedAttachments(metadata)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(attachments).To(Equal([]lib.AttachmentConfig{{
+				IfName:   "net1",
+				Delegate: map[string]interface{}{"type": "macvlan", "master": "eth1"},
+			}}))
+		})
+
+		It("returns no attachments when none were recorded", func() {
+			attachments, err := lib.RecordedAttachments(map[string]interface{}{"app_id": "some-app"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(attachments).To(BeEmpty())
+		})
+
+		Context("when the recorded attachments are malformed", func() {
+			It("returns an error", func() {
+				_, err := lib.RecordedAttachments(map[string]interface{}{"attachments": "banana"})
+				Expect(err).To(MatchError(ContainSubstring("unmarshal attachments")))
+			})
+		})
+	})
+
+	Describe("AttachmentResult", func() {
+		It("returns the interfaces and ips of the attachment", func() {
+			result := &current.Result{
+				CNIVersion: "1.0.0",
+				Interfaces: []*current.Interface{
+					{Name: "eth0", Sandbox: "/some/netns"},
+					{Name: "net1", Sandbox: "/some/netns"},
+				},
+				IPs: []*current.IPConfig{
+					{Interface: current.Int(0), Address: net.IPNet{IP: net.ParseIP("10.255.0.2"), Mask: net.CIDRMask(32, 32)}},
+					{Interface: current.Int(1), Address: net.IPNet{IP: net.ParseIP("192.168.1.5"), Mask: net.CIDRMask(24, 32)}},
+				},
+			}
+
+			Expect(lib.AttachmentResult(result, "net1")).To(Equal(&current.Result{
+				CNIVersion: "1.0.0",
+				Interfaces: []*current.Interface{{Name: "net1", Sandbox: "/some/netns"}},
+				IPs: []*current.IPConfig{
+					{Interface: current.Int(0), Address: net.IPNet{IP: net.ParseIP("192.168.1.5"), Mask: net.CIDRMask(24, 32)}},
+				},
+			}))
+		})
+	})
+})
+
+var _ = Describe("DelegateCheck", func() {
+	var (
+		input            map[string]interface{}
+		pluginController *lib.PluginController
+		fakeDelegator    *fakes.Delegator
+	)
+
+	BeforeEach(func() {
+		fakeDelegator = &fakes.Delegator{}
+		pluginController = &lib.PluginController{
+			Delegator: fakeDelegator,
+		}
+
+		input = map[string]interface{}{
+			"type": "something",
+		}
+	})
+
+	It("should call the plugin specified by the type", func() {
+		err := pluginController.DelegateCheck(input)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(fakeDelegator.DelegateCheckCallCount()).To(Equal(1))
+		delegateType, netconf := fakeDelegator.DelegateCheckArgsForCall(0)
+		Expect(delegateType).To(Equal("something"))
+		Expect(netconf).To(MatchJSON(`{"type": "something"}`))
+	})
+
+	Context("when the delegator returns an error", func() {
+		BeforeEach(func() {
+			fakeDelegator.DelegateCheckReturns(fmt.Errorf("patato"))
+		})
+
+		It("should return the error", func() {
+			err := pluginController.DelegateCheck(input)
+			Expect(err).To(MatchError("patato"))
+		})
+	})
+
+	Context("when the input type is missing", func() {
+		BeforeEach(func() {
+			input = map[string]interface{}{
+				"notype": "shoudbemissing",
+			}
+		})
+
+		It("should return a useful error", func() {
+			err := pluginController.DelegateCheck(input)
+			Expect(err).To(MatchError("delegate config is missing type"))
+		})
+	})
+})
+
+var _ = Describe("DelegateGC", func() {
+	var (
+		input            map[string]interface{}
+		pluginController *lib.PluginController
+		fakeDelegator    *fakes.Delegator
+	)
+
+	BeforeEach(func() {
+		fakeDelegator = &fakes.Delegator{}
+		pluginController = &lib.PluginController{
+			Delegator: fakeDelegator,
+		}
+
+		input = map[string]interface{}{
+			"type": "something",
+		}
+	})
+
+	It("should call the plugin specified by the type", func() {
+		err := pluginController.DelegateGC(input)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(fakeDelegator.DelegateGCCallCount()).To(Equal(1))
+		delegateType, netconf := fakeDelegator.DelegateGCArgsForCall(0)
+		Expect(delegateType).To(Equal("something"))
+		Expect(netconf).To(MatchJSON(`{"type": "something"}`))
+	})
+
+	Context("when the delegator returns an error", func() {
+		BeforeEach(func() {
+			fakeDelegator.DelegateGCReturns(fmt.Errorf("patato"))
+		})
+
+		It("should return the error", func() {
+			err := pluginController.DelegateGC(input)
+			Expect(err).To(MatchError("patato"))
+		})
+	})
+
+	Context("when the input type is missing", func() {
+		BeforeEach(func() {
+			input = map[string]interface{}{
+				"notype": "shoudbemissing",
+			}
+		})
+
+		It("should return a useful error", func() {
+			err := pluginController.DelegateGC(input)
+			Expect(err).To(MatchError("delegate config is missing type"))
+		})
+	})
+})
+
+var _ = Describe("DelegateStatus", func() {
+	var (
+		input            map[string]interface{}
+		pluginController *lib.PluginController
+		fakeDelegator    *fakes.Delegator
+	)
+
+	BeforeEach(func() {
+		fakeDelegator = &fakes.Delegator{}
+		pluginController = &lib.PluginController{
+			Delegator: fakeDelegator,
+		}
+
+		input = map[string]interface{}{
+			"type": "something",
+		}
+	})
+
+	It("should call the plugin specified by the type", func() {
+		err := pluginController.DelegateStatus(input)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(fakeDelegator.DelegateStatusCallCount()).To(Equal(1))
+		delegateType, netconf := fakeDelegator.DelegateStatusArgsForCall(0)
+		Expect(delegateType).To(Equal("something"))
+		Expect(netconf).To(MatchJSON(`{"type": "something"}`))
+	})
+
+	Context("when the delegator returns an error", func() {
+		BeforeEach(func() {
+			fakeDelegator.DelegateStatusReturns(fmt.Errorf("patato"))
+		})
+
+		It("should return the error", func() {
+			err := pluginController.DelegateStatus(input)
+			Expect(err).To(MatchError("patato"))
+		})
+	})
+
+	Context("when the input type is missing", func() {
+		BeforeEach(func() {
+			input = map[string]interface{}{
+				"notype": "shoudbemissing",
+			}
+		})
+
+		It("should return a useful error", func() {
+			err := pluginController.DelegateStatus(input)
+			Expect(err).To(MatchError("delegate config is missing type"))
+		})
+	})
+})
+
 var _ = Describe("AddIPMasq", func() {
 	var (
 		pluginController *lib.PluginController
@@ -317,7 +814,7 @@ var _ = Describe("AddIPMasq", func() {
 	})
 
 	It("should add the ip masquerade rules for egress traffic", func() {
-		err := pluginController.AddIPMasq("10.255.5.5/32", "10.255.0.0/16", "silk-vtep")
+		err := pluginController.AddIPMasq("10.255.5.5/32", "10.255.0.0/16", "silk-vtep", "")
 		Expect(err).NotTo(HaveOccurred())
 
 		tableName, chainName, iptablesRule := fakeIPTablesAdapter.BulkAppendArgsForCall(0)
@@ -325,6 +822,18 @@ var _ = Describe("AddIPMasq", func() {
 		Expect(chainName).To(Equal("POSTROUTING"))
 		Expect(iptablesRule).To(ContainElement(rules.NewDefaultEgressRule("10.255.5.5/32", "10.255.0.0/16", "silk-vtep")))
 	})
+
+	Context("when the container has an egress ip", func() {
+		It("should add a rule that SNATs egress traffic to the egress ip", func() {
+			err := pluginController.AddIPMasq("10.255.5.5/32", "10.255.0.0/16", "silk-vtep", "10.0.16.20")
+			Expect(err).NotTo(HaveOccurred())
+
+			tableName, chainName, iptablesRule := fakeIPTablesAdapter.BulkAppendArgsForCall(0)
+			Expect(tableName).To(Equal("nat"))
+			Expect(chainName).To(Equal("POSTROUTING"))
+			Expect(iptablesRule).To(ContainElement(rules.NewEgressSNATRule("10.255.5.5/32", "10.255.0.0/16", "silk-vtep", "10.0.16.20")))
+		})
+	})
 })
 
 var _ = Describe("DelIPMasq", func() {
@@ -342,7 +851,7 @@ var _ = Describe("DelIPMasq", func() {
 	})
 
 	It("should delete the ip masquerade rules for egress traffic", func() {
-		err := pluginController.DelIPMasq("10.255.5.5/32", "10.255.0.0/16", "silk-vtep")
+		err := pluginController.DelIPMasq("10.255.5.5/32", "10.255.0.0/16", "silk-vtep", "")
 		Expect(err).NotTo(HaveOccurred())
 
 		tableName, chainName, iptablesRule := fakeIPTablesAdapter.DeleteArgsForCall(0)
@@ -350,4 +859,63 @@ var _ = Describe("DelIPMasq", func() {
 		Expect(chainName).To(Equal("POSTROUTING"))
 		Expect(iptablesRule).To(Equal(rules.NewDefaultEgressRule("10.255.5.5/32", "10.255.0.0/16", "silk-vtep")))
 	})
+
+	Context("when the container has an egress ip", func() {
+		It("should delete the SNAT rule for egress traffic", func() {
+			err := pluginController.DelIPMasq("10.255.5.5/32", "10.255.0.0/16", "silk-vtep", "10.0.16.20")
+			Expect(err).NotTo(HaveOccurred())
+
+			_, _, iptablesRule := fakeIPTablesAdapter.DeleteArgsForCall(0)
+			Expect(iptablesRule).To(Equal(rules.NewEgressSNATRule("10.255.5.5/32", "10.255.0.0/16", "silk-vtep", "10.0.16.20")))
+		})
+	})
+})
+
+var _ = Describe("CheckIPMasq", func() {
+	var (
+		pluginController *lib.PluginController
+
+		fakeIPTablesAdapter *lib_fakes.IPTablesAdapter
+	)
+
+	BeforeEach(func() {
+		fakeIPTablesAdapter = &lib_fakes.IPTablesAdapter{}
+		fakeIPTablesAdapter.ExistsReturns(true, nil)
+		pluginController = &lib.PluginController{
+			IPTables: fakeIPTablesAdapter,
+		}
+	})
+
+	It("should check for the ip masquerade rule for egress traffic", func() {
+		err := pluginController.CheckIPMasq("10.255.5.5", "10.255.0.0/16", "silk-vtep", "")
+		Expect(err).NotTo(HaveOccurred())
+
+		tableName, chainName, iptablesRule := fakeIPTablesAdapter.ExistsArgsForCall(0)
+		Expect(tableName).To(Equal("nat"))
+		Expect(chainName).To(Equal("POSTROUTING"))
+		Expect(iptablesRule).To(Equal(rules.NewDefaultEgressRule("10.255.5.5", "10.255.0.0/16", "silk-vtep")))
+	})
+
+	Context("when the rule is missing", func() {
+		BeforeEach(func() {
+			fakeIPTablesAdapter.ExistsReturns(false, nil)
+		})
+
+		It("should return an error naming the rule", func() {
+			err := pluginController.CheckIPMasq("10.255.5.5", "10.255.0.0/16", "silk-vtep", "")
+			Expect(err).To(MatchError(HavePrefix("missing rule [-s 10.255.5.5")))
+			Expect(err).To(MatchError(HaveSuffix("in nat/POSTROUTING")))
+		})
+	})
+
+	Context("when checking the rule fails", func() {
+		BeforeEach(func() {
+			fakeIPTablesAdapter.ExistsReturns(false, fmt.Errorf("patato"))
+		})
+
+		It("should return the error", func() {
+			err := pluginController.CheckIPMasq("10.255.5.5", "10.255.0.0/16", "silk-vtep", "")
+			Expect(err).To(MatchError("patato"))
+		})
+	})
 })
@@ -0,0 +1,99 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/cf-networking-helpers/mutualtls"
+)
+
+const (
+	policyAgentTimeout       = 5 * time.Second
+	policyAgentAttempts      = 3
+	policyAgentRetryInterval = 500 * time.Millisecond
+)
+
+// PolicyAgentClient calls the force update server of the policy agent over
+// its unix socket, over mutual TLS or over plain HTTP. Failed requests are
+// retried, but all attempts of a request share one timeout so that the
+// policy agent cannot hold up the runtime for long.
+type PolicyAgentClient struct {
+	httpClient    *http.Client
+	baseURL       string
+	network       string
+	address       string
+	timeout       time.Duration
+	attempts      int
+	retryInterval time.Duration
+}
+
+func NewPolicyAgentClient(cfg *WrapperConfig) (*PolicyAgentClient, error) {
+	c := &PolicyAgentClient{
+		network:       "tcp",
+		address:       cfg.PolicyAgentForcePollAddress,
+		timeout:       policyAgentTimeout,
+		attempts:      policyAgentAttempts,
+		retryInterval: policyAgentRetryInterval,
+	}
+	dialer := &net.Dialer{Timeout: policyAgentTimeout}
+	transport := &http.Transport{DialContext: dialer.DialContext}
+
+	switch {
+	case cfg.PolicyAgentForcePollSocket != "":
+		c.network = "unix"
+		c.address = cfg.PolicyAgentForcePollSocket
+		c.baseURL = "http://policy-agent"
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", cfg.PolicyAgentForcePollSocket)
+		}
+	case cfg.PolicyAgentForcePollCACertFile != "":
+		tlsConfig, err := mutualtls.NewClientTLSConfig(cfg.PolicyAgentForcePollCertFile, cfg.PolicyAgentForcePollKeyFile, cfg.PolicyAgentForcePollCACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("policy agent tls config: %s", err)
+		}
+		transport.TLSClientConfig = tlsConfig
+		c.baseURL = "https://" + cfg.PolicyAgentForcePollAddress
+	default:
+		c.baseURL = "http://" + cfg.PolicyAgentForcePollAddress
+	}
+
+	c.httpClient = &http.Client{Transport: transport}
+	return c, nil
+}
+
+// Get requests the path, e.g. /force-policy-poll-cycle, from the policy
+// agent.
+func (c *PolicyAgentClient) Get(path string) (*http.Response, error) {
+	deadline := time.Now().Add(c.timeout)
+	var err error
+	attempt := 1
+	for ; ; attempt++ {
+		client := *c.httpClient
+		client.Timeout = time.Until(deadline)
+
+		var resp *http.Response
+		resp, err = client.Get(c.baseURL + path)
+		if err == nil {
+			return resp, nil
+		}
+		if attempt == c.attempts || time.Until(deadline) <= c.retryInterval {
+			break
+		}
+		time.Sleep(c.retryInterval)
+	}
+	return nil, fmt.Errorf("policy agent request failed after %d attempts: %s", attempt, err)
+}
+
+// Ping reports whether the policy agent accepts connections.
+func (c *PolicyAgentClient) Ping() error {
+	conn, err := net.DialTimeout(c.network, c.address, c.timeout)
+	if err != nil {
+		return err
+	}
+	// #nosec G104 - the connection is only opened to confirm the policy agent is listening
+	conn.Close()
+	return nil
+}
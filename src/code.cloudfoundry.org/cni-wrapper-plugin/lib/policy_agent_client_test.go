@@ -0,0 +1,133 @@
+package lib_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/cf-networking-helpers/mutualtls"
+	"code.cloudfoundry.org/cf-networking-helpers/testsupport"
+	"code.cloudfoundry.org/cni-wrapper-plugin/lib"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PolicyAgentClient", func() {
+	var (
+		cfg     *lib.WrapperConfig
+		handler http.HandlerFunc
+		paths   []string
+	)
+
+	BeforeEach(func() {
+		cfg = &lib.WrapperConfig{}
+		paths = nil
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			paths = append(paths, r.URL.RequestURI())
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+
+	get := func(path string) {
+		client, err := lib.NewPolicyAgentClient(cfg)
+		Expect(err).NotTo(HaveOccurred())
+
+		resp, err := client.Get(path)
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(paths).To(Equal([]string{path}))
+		Expect(client.Ping()).To(Succeed())
+	}
+
+	Context("when the policy agent listens on tcp", func() {
+		It("calls it over plain http", func() {
+			server := httptest.NewServer(handler)
+			defer server.Close()
+			cfg.PolicyAgentForcePollAddress = strings.TrimPrefix(server.URL, "http://")
+
+			get("/force-policy-poll-cycle")
+		})
+	})
+
+	Context("when the policy agent listens on a unix socket", func() {
+		It("calls it over the socket", func() {
+			cfg.PolicyAgentForcePollSocket = filepath.Join(GinkgoT().TempDir(), "policy-agent.sock")
+			listener, err := net.Listen("unix", cfg.PolicyAgentForcePollSocket)
+			Expect(err).NotTo(HaveOccurred())
+			server := httptest.NewUnstartedServer(handler)
+			server.Listener = listener
+			server.Start()
+			defer server.Close()
+
+			get("/force-asgs-for-container?container=some-handle")
+		})
+	})
+
+	Context("when the policy agent requires mutual tls", func() {
+		It("calls it with the client certificate", func() {
+			certDir := GinkgoT().TempDir()
+			certWriter, err := testsupport.NewCertWriter(certDir)
+			Expect(err).NotTo(HaveOccurred())
+			caFile, err := certWriter.WriteCA("ca")
+			Expect(err).NotTo(HaveOccurred())
+			serverCertFile, serverKeyFile, err := certWriter.WriteAndSign("server", "ca")
+			Expect(err).NotTo(HaveOccurred())
+			cfg.PolicyAgentForcePollCertFile, cfg.PolicyAgentForcePollKeyFile, err = certWriter.WriteAndSign("client", "ca")
+			Expect(err).NotTo(HaveOccurred())
+			cfg.PolicyAgentForcePollCACertFile = caFile
+
+			server := httptest.NewUnstartedServer(handler)
+			server.TLS, err = mutualtls.NewServerTLSConfig(serverCertFile, serverKeyFile, caFile)
+			Expect(err).NotTo(HaveOccurred())
+			server.StartTLS()
+			defer server.Close()
+			cfg.PolicyAgentForcePollAddress = strings.TrimPrefix(server.URL, "https://")
+
+			get("/force-policy-poll-cycle")
+		})
+
+		Context("when the certificates cannot be loaded", func() {
+			It("returns an error", func() {
+				cfg.PolicyAgentForcePollCACertFile = "/does/not/exist"
+				_, err := lib.NewPolicyAgentClient(cfg)
+				Expect(err).To(MatchError(HavePrefix("policy agent tls config:")))
+			})
+		})
+	})
+
+	Context("when the policy agent is not listening", func() {
+		It("retries and returns the last error", func() {
+			cfg.PolicyAgentForcePollSocket = filepath.Join(GinkgoT().TempDir(), "policy-agent.sock")
+			client, err := lib.NewPolicyAgentClient(cfg)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = client.Get("/force-policy-poll-cycle")
+			Expect(err).To(MatchError(ContainSubstring("policy agent request failed after 3 attempts")))
+			Expect(client.Ping()).NotTo(Succeed())
+		})
+	})
+
+	Context("when the policy agent does not respond", func() {
+		It("gives up after a total of 5 seconds", func() {
+			release := make(chan struct{})
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				<-release
+			}))
+			defer server.Close()
+			defer close(release)
+			cfg.PolicyAgentForcePollAddress = strings.TrimPrefix(server.URL, "http://")
+			client, err := lib.NewPolicyAgentClient(cfg)
+			Expect(err).NotTo(HaveOccurred())
+
+			start := time.Now()
+			_, err = client.Get("/force-policy-poll-cycle")
+			Expect(err).To(MatchError(ContainSubstring("policy agent request failed after 1 attempts")))
+			Expect(time.Since(start)).To(BeNumerically("<", 6*time.Second))
+		})
+	})
+})
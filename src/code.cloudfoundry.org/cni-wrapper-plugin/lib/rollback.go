@@ -0,0 +1,88 @@
+package lib
+
+import (
+	"fmt"
+	"strings"
+
+	"code.cloudfoundry.org/lib/rules"
+	"github.com/containernetworking/cni/pkg/types"
+)
+
+// Rollback records how to undo each step of an ADD, so that a failed ADD
+// does not leave chains, IPAM allocations or datastore entries behind.
+type Rollback struct {
+	steps []rollbackStep
+}
+
+type rollbackStep struct {
+	name string
+	undo func() error
+}
+
+// Record adds the undo action of a step. Steps that can partially succeed
+// should be recorded before they run.
+func (r *Rollback) Record(name string, undo func() error) {
+	r.steps = append(r.steps, rollbackStep{name: name, undo: undo})
+}
+
+// Fail undoes the recorded steps in reverse order and returns err as a CNI
+// error, with the outcome of the rollback as its details. Every undo action
+// runs, even when an earlier one fails. err is returned unchanged when no
+// step was recorded.
+func (r *Rollback) Fail(err error) error {
+	if len(r.steps) == 0 {
+		return err
+	}
+
+	var undone, failures []string
+	for i := len(r.steps) - 1; i >= 0; i-- {
+		step := r.steps[i]
+		if undoErr := step.undo(); undoErr != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", step.name, undoErr))
+		} else {
+			undone = append(undone, step.name)
+		}
+	}
+	r.steps = nil
+
+	details := fmt.Sprintf("rolled back: %s", strings.Join(undone, ", "))
+	if len(failures) > 0 {
+		details = fmt.Sprintf("rollback failed: %s", strings.Join(failures, "; "))
+	}
+
+	if cniErr, ok := err.(*types.Error); ok {
+		return types.NewError(cniErr.Code, cniErr.Msg, details)
+	}
+	return types.NewError(types.ErrInternal, err.Error(), details)
+}
+
+// RollbackIPSets records a step that destroys each ipset as soon as it is
+// created, so that sets created for rules that are never applied do not
+// outlive a failed ADD. Sets still used by other containers are kept.
+type RollbackIPSets struct {
+	rules.IPSetAdapter
+	Rollback *Rollback
+}
+
+func (s *RollbackIPSets) Ensure(set rules.IPSet) error {
+	if err := s.IPSetAdapter.Ensure(set); err != nil {
+		return err
+	}
+	s.Rollback.Record(fmt.Sprintf("ipset %s", set.Name), func() error {
+		return s.IPSetAdapter.Destroy(set.Name)
+	})
+	return nil
+}
+
+// StagedIPSets ensures each set as part of the transaction instead of right
+// away, so that the set is created under the same lock as the rules that
+// match it. Sets are still destroyed right away.
+type StagedIPSets struct {
+	rules.IPSetAdapter
+	Transaction *rules.RestoreTransaction
+}
+
+func (s *StagedIPSets) Ensure(set rules.IPSet) error {
+	s.Transaction.EnsureIPSet(set)
+	return nil
+}
@@ -0,0 +1,126 @@
+package lib_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/cni-wrapper-plugin/lib"
+	lib_fakes "code.cloudfoundry.org/lib/fakes"
+	"code.cloudfoundry.org/lib/rules"
+
+	"github.com/containernetworking/cni/pkg/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Rollback", func() {
+	var (
+		rollback *lib.Rollback
+		undone   []string
+	)
+
+	BeforeEach(func() {
+		rollback = &lib.Rollback{}
+		undone = nil
+	})
+
+	undo := func(name string, err error) func() error {
+		return func() error {
+			undone = append(undone, name)
+			return err
+		}
+	}
+
+	It("undoes the steps in reverse order", func() {
+		rollback.Record("delegate add", undo("delegate add", nil))
+		rollback.Record("store add", undo("store add", nil))
+
+		err := rollback.Fail(errors.New("banana"))
+		Expect(undone).To(Equal([]string{"store add", "delegate add"}))
+		Expect(err).To(Equal(types.NewError(types.ErrInternal, "banana", "rolled back: store add, delegate add")))
+	})
+
+	It("undoes the remaining steps when an undo action fails", func() {
+		rollback.Record("delegate add", undo("delegate add", nil))
+		rollback.Record("store add", undo("store add", errors.New("kiwi")))
+
+		err := rollback.Fail(errors.New("banana"))
+		Expect(undone).To(Equal([]string{"store add", "delegate add"}))
+		Expect(err).To(Equal(types.NewError(types.ErrInternal, "banana", "rollback failed: store add: kiwi")))
+	})
+
+	It("keeps the code of a CNI error", func() {
+		rollback.Record("delegate add", undo("delegate add", nil))
+
+		err := rollback.Fail(types.NewError(types.ErrInvalidNetworkConfig, "banana", ""))
+		Expect(err).To(Equal(types.NewError(types.ErrInvalidNetworkConfig, "banana", "rolled back: delegate add")))
+	})
+
+	It("undoes every step only once", func() {
+		rollback.Record("delegate add", undo("delegate add", nil))
+
+		rollback.Fail(errors.New("banana"))
+		rollback.Fail(errors.New("banana"))
+		Expect(undone).To(Equal([]string{"delegate add"}))
+	})
+
+	It("returns the error unchanged when no step was recorded", func() {
+		err := rollback.Fail(errors.New("banana"))
+		Expect(err).To(MatchError("banana"))
+		Expect(undone).To(BeEmpty())
+	})
+})
+
+var _ = Describe("RollbackIPSets", func() {
+	var (
+		rollback *lib.Rollback
+		adapter  *lib_fakes.IPSetAdapter
+		ipSets   *lib.RollbackIPSets
+		set      rules.IPSet
+	)
+
+	BeforeEach(func() {
+		rollback = &lib.Rollback{}
+		adapter = &lib_fakes.IPSetAdapter{}
+		ipSets = &lib.RollbackIPSets{IPSetAdapter: adapter, Rollback: rollback}
+		set = rules.NewIPSet([]string{"1.1.1.1", "2.2.2.2"})
+	})
+
+	It("destroys the sets it created when the ADD is rolled back", func() {
+		Expect(ipSets.Ensure(set)).To(Succeed())
+		Expect(adapter.EnsureArgsForCall(0)).To(Equal(set))
+		Expect(adapter.DestroyCallCount()).To(Equal(0))
+
+		err := rollback.Fail(errors.New("banana"))
+		Expect(err).To(Equal(types.NewError(types.ErrInternal, "banana", "rolled back: ipset "+set.Name)))
+		Expect(adapter.DestroyCallCount()).To(Equal(1))
+		Expect(adapter.DestroyArgsForCall(0)).To(Equal([]string{set.Name}))
+	})
+
+	Context("when the set can not be created", func() {
+		BeforeEach(func() {
+			adapter.EnsureReturns(errors.New("kiwi"))
+		})
+
+		It("records no step", func() {
+			Expect(ipSets.Ensure(set)).To(MatchError("kiwi"))
+			Expect(rollback.Fail(errors.New("banana"))).To(MatchError("banana"))
+			Expect(adapter.DestroyCallCount()).To(Equal(0))
+		})
+	})
+})
+
+var _ = Describe("StagedIPSets", func() {
+	It("ensures the sets as part of the transaction", func() {
+		adapter := &lib_fakes.IPSetAdapter{}
+		tx := &rules.RestoreTransaction{}
+		ipSets := &lib.StagedIPSets{IPSetAdapter: adapter, Transaction: tx}
+		set := rules.NewIPSet([]string{"1.1.1.1"})
+
+		Expect(ipSets.Ensure(set)).To(Succeed())
+		Expect(adapter.EnsureCallCount()).To(Equal(0))
+		Expect(tx.IPSets()).To(Equal([]rules.IPSet{set}))
+
+		Expect(ipSets.Destroy(set.Name)).To(Succeed())
+		Expect(adapter.DestroyArgsForCall(0)).To(Equal([]string{set.Name}))
+	})
+})
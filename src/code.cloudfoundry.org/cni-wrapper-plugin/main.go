@@ -23,9 +23,11 @@ import (
 
 	"code.cloudfoundry.org/filelock"
 	"github.com/containernetworking/cni/pkg/skel"
+	"github.com/containernetworking/cni/pkg/types"
 	current "github.com/containernetworking/cni/pkg/types/100"
 	"github.com/containernetworking/cni/pkg/version"
 	"github.com/coreos/go-iptables/iptables"
+	"github.com/hashicorp/go-multierror"
 )
 
 func cmdAdd(args *skel.CmdArgs) error {
@@ -39,19 +41,68 @@ func cmdAdd(args *skel.CmdArgs) error {
 		return err
 	}
 
+	var cniAddData struct {
+		Metadata map[string]interface{}
+	}
+	if err := json.Unmarshal(args.StdinData, &cniAddData); err != nil {
+		return err // not tested, this should be impossible
+	}
+
+	setDelegateContainerConfig(cfg, cniAddData.Metadata)
+
+	// every step records how to undo it, so that a failed ADD removes what
+	// it created before returning the error
+	rollback := &lib.Rollback{}
+
 	result, err := pluginController.DelegateAdd(cfg.Delegate)
 	if err != nil {
 		return fmt.Errorf("delegate call: %s", err)
 	}
+	rollback.Record("delegate add", func() error {
+		return pluginController.DelegateDel(cfg.Delegate)
+	})
 
 	resultActual, err := current.GetResult(result)
 	if err != nil {
-		return fmt.Errorf("converting result from delegate plugin: %s", err) // not tested
+		return rollback.Fail(fmt.Errorf("converting result from delegate plugin: %s", err)) // not tested
 	}
 
 	containerIP := resultActual.IPs[0].Address.IP
 	var containerWorkload string
 
+	attachments, err := addAttachments(cfg, pluginController, rollback, args.IfName, resultActual)
+	if err != nil {
+		return rollback.Fail(err)
+	}
+	if len(attachments) > 0 {
+		if cniAddData.Metadata == nil {
+			cniAddData.Metadata = map[string]interface{}{}
+		}
+		cniAddData.Metadata["attachments"] = attachments
+	}
+
+	vtepName := cfg.VTEPNameForIP(containerIP)
+	if vtepName != cfg.VTEPName {
+		if cniAddData.Metadata == nil {
+			cniAddData.Metadata = map[string]interface{}{}
+		}
+		cniAddData.Metadata["vtep_name"] = vtepName
+	}
+
+	interfaceNameLookup := interfacelookup.InterfaceNameLookup{
+		NetlinkAdapter: &adapter.NetlinkAdapter{},
+	}
+
+	// The egress IP is stored with the container so that DEL and CHECK find
+	// the same rule after the egress IP config changes.
+	egressIP := cfg.EgressIPForContainer(cniAddData.Metadata)
+	if egressIP != "" {
+		if _, err := interfaceNameLookup.GetNameFromIP(egressIP); err != nil {
+			return rollback.Fail(fmt.Errorf("egress ip %s is not an address of this cell: %s", egressIP, err))
+		}
+		cniAddData.Metadata["egress_ip"] = egressIP
+	}
+
 	// Add container metadata info
 	store := &datastore.Store{
 		Serializer: &serial.Serial{},
@@ -67,46 +118,37 @@ func cmdAdd(args *skel.CmdArgs) error {
 		CacheMutex:      new(sync.RWMutex),
 	}
 
-	var cniAddData struct {
-		Metadata map[string]interface{}
-	}
-	if err := json.Unmarshal(args.StdinData, &cniAddData); err != nil {
-		return err // not tested, this should be impossible
-	}
 	if workload, present := cniAddData.Metadata["container_workload"]; present {
 		containerWorkload, _ = workload.(string)
 	}
 
 	if err := store.Add(args.ContainerID, containerIP.String(), cniAddData.Metadata); err != nil {
-		storeErr := fmt.Errorf("store add: %s", err)
-		fmt.Fprintf(os.Stderr, "%s", storeErr)
-		fmt.Fprint(os.Stderr, "cleaning up from error")
-		err = pluginController.DelIPMasq(containerIP.String(), cfg.NoMasqueradeCIDRRange, cfg.VTEPName)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "during cleanup: removing IP masq: %s", err)
-		}
+		return rollback.Fail(fmt.Errorf("store add: %s", err))
+	}
+	rollback.Record("store add", func() error {
+		_, err := store.Delete(args.ContainerID)
+		return err
+	})
 
-		return storeErr
+	policyAgent, err := lib.NewPolicyAgentClient(cfg)
+	if err != nil {
+		return rollback.Fail(err)
 	}
 
-	resp, err := http.DefaultClient.Get(fmt.Sprintf("http://%s/force-policy-poll-cycle", cfg.PolicyAgentForcePollAddress))
+	resp, err := policyAgent.Get("/force-policy-poll-cycle")
 	if err != nil {
-		return err
+		return rollback.Fail(err)
 	}
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		// #nosec G104 - don't capture this error, as the one we generate below is more important to return
 		resp.Body.Close()
-		return fmt.Errorf("vpa response code: %v with message: %s", resp.StatusCode, body)
+		return rollback.Fail(fmt.Errorf("vpa response code: %v with message: %s", resp.StatusCode, body))
 	}
 
 	localDNSServers, err := getLocalDNSServers(cfg.DNSServers)
 	if err != nil {
-		return err
-	}
-
-	interfaceNameLookup := interfacelookup.InterfaceNameLookup{
-		NetlinkAdapter: &adapter.NetlinkAdapter{},
+		return rollback.Fail(err)
 	}
 
 	var interfaceNames []string
@@ -115,12 +157,12 @@ func cmdAdd(args *skel.CmdArgs) error {
 	} else {
 		interfaceNames, err = interfaceNameLookup.GetNamesFromIPs(cfg.UnderlayIPs)
 		if err != nil {
-			return fmt.Errorf("looking up interface names: %s", err) // not tested
+			return rollback.Fail(fmt.Errorf("looking up interface names: %s", err)) // not tested
 		}
 	}
 
 	if args.ContainerID == "" {
-		return fmt.Errorf("invalid Container ID")
+		return rollback.Fail(fmt.Errorf("invalid Container ID"))
 	}
 
 	chainNamer := &netrules.ChainNamer{
@@ -134,9 +176,19 @@ func cmdAdd(args *skel.CmdArgs) error {
 		DryRun:     cfg.OutConn.DryRun,
 	}
 
+	// The container's ipsets are created along with its rules, so that the
+	// policy agent can not destroy a shared set in between.
+	containerRules := &rules.RestoreTransaction{}
+	var ipSets rules.IPSetAdapter
+	if pluginController.IPSets != nil {
+		ipSets = &lib.RollbackIPSets{
+			IPSetAdapter: &lib.StagedIPSets{IPSetAdapter: pluginController.IPSets, Transaction: containerRules},
+			Rollback:     rollback,
+		}
+	}
 	netOutChain := &netrules.NetOutChain{
 		ChainNamer:       chainNamer,
-		Converter:        &netrules.RuleConverter{LogWriter: os.Stderr},
+		Converter:        &netrules.RuleConverter{LogWriter: os.Stderr, IPSets: ipSets},
 		ASGLogging:       cfg.IPTablesASGLogging,
 		DeniedLogsPerSec: cfg.IPTablesDeniedLogsPerSec,
 		DenyNetworks: netrules.DenyNetworks{
@@ -155,7 +207,7 @@ func cmdAdd(args *skel.CmdArgs) error {
 		DeniedLogsPerSec:      cfg.IPTablesDeniedLogsPerSec,
 		AcceptedUDPLogsPerSec: cfg.IPTablesAcceptedUDPLogsPerSec,
 		IngressTag:            cfg.IngressTag,
-		VTEPName:              cfg.VTEPName,
+		VTEPName:              vtepName,
 		HostInterfaceNames:    interfaceNames,
 		ContainerHandle:       args.ContainerID,
 		ContainerWorkload:     containerWorkload,
@@ -164,9 +216,10 @@ func cmdAdd(args *skel.CmdArgs) error {
 		HostUDPServices:       cfg.HostUDPServices,
 		DNSServers:            localDNSServers,
 		Conn:                  outConn,
+		IPSets:                ipSets,
 	}
-	if err := netOutProvider.Initialize(); err != nil {
-		return fmt.Errorf("initialize net out: %s", err)
+	if err := netOutProvider.StageInitialize(containerRules); err != nil {
+		return rollback.Fail(fmt.Errorf("initialize net out: %s", err))
 	}
 
 	netinProvider := netrules.NetIn{
@@ -177,30 +230,49 @@ func cmdAdd(args *skel.CmdArgs) error {
 		IngressTag:         cfg.IngressTag,
 		HostInterfaceNames: interfaceNames,
 	}
-	err = netinProvider.Initialize(args.ContainerID)
-	if err != nil {
-		return fmt.Errorf("initializing net in: %s", err)
-	}
+	netinProvider.StageInitialize(containerRules, args.ContainerID)
 
 	portMappings := cfg.RuntimeConfig.PortMappings
 	for _, netIn := range portMappings {
 		if netIn.HostPort <= 0 {
-			return fmt.Errorf("cannot allocate port %d", netIn.HostPort)
+			return rollback.Fail(fmt.Errorf("cannot allocate port %d", netIn.HostPort))
+		}
+		mapping := netrules.PortMapping{
+			Protocol:      netIn.Protocol,
+			HostPort:      int(netIn.HostPort),
+			HostPortEnd:   int(netIn.HostPortEnd),
+			ContainerPort: int(netIn.ContainerPort),
 		}
-		if err := netinProvider.AddRule(args.ContainerID, int(netIn.HostPort), int(netIn.ContainerPort), cfg.InstanceAddress, containerIP.String()); err != nil {
-			return fmt.Errorf("adding netin rule: %s", err)
+		if err := netinProvider.StageRule(containerRules, args.ContainerID, mapping, cfg.InstanceAddress, containerIP.String()); err != nil {
+			return rollback.Fail(fmt.Errorf("adding netin rule: %s", err))
 		}
 	}
 
-	resp, err = http.DefaultClient.Get(fmt.Sprintf("http://%s/force-asgs-for-container?container=%s", cfg.PolicyAgentForcePollAddress, args.ContainerID))
+	// All of the container's chains, jump rules and port mappings are applied
+	// in one iptables-restore call, so a failure leaves none of them behind.
+	if err := pluginController.IPTables.ApplyTransaction(containerRules); err != nil {
+		return rollback.Fail(fmt.Errorf("applying container rules: %s", err))
+	}
+	rollback.Record("net out", netOutProvider.Cleanup)
+	rollback.Record("net in", func() error {
+		return netinProvider.Cleanup(args.ContainerID)
+	})
+
+	rollback.Record("asg sync", func() error {
+		return forceOrphanedASGsCleanup(policyAgent, args.ContainerID)
+	})
+	resp, err = policyAgent.Get(fmt.Sprintf("/force-asgs-for-container?container=%s", args.ContainerID))
 	if err != nil {
-		return err
+		return rollback.Fail(err)
 	}
 
 	if resp.StatusCode == http.StatusMethodNotAllowed {
-		netOutRules := cfg.RuntimeConfig.NetOutRules
-		if err := netOutProvider.BulkInsertRules(netrules.NewRulesFromGardenNetOutRules(netOutRules)); err != nil {
-			return fmt.Errorf("bulk insert: %s", err) // not tested
+		netOutRules, err := netrules.NewRulesFromRuntimeNetOutRules(cfg.RuntimeConfig.NetOutRules)
+		if err != nil {
+			return rollback.Fail(fmt.Errorf("net out rules: %s", err))
+		}
+		if err := netOutProvider.BulkInsertRules(netOutRules); err != nil {
+			return rollback.Fail(fmt.Errorf("bulk insert: %s", err)) // not tested
 		}
 	}
 
@@ -208,25 +280,234 @@ func cmdAdd(args *skel.CmdArgs) error {
 		body, _ := io.ReadAll(resp.Body)
 		// #nosec G104 - don't capture this error, as the one we generate below is more important to return
 		resp.Body.Close()
-		return fmt.Errorf("asg sync returned %v with message: %s", resp.StatusCode, body)
+		return rollback.Fail(fmt.Errorf("asg sync returned %v with message: %s", resp.StatusCode, body))
 	}
 
-	err = pluginController.AddIPMasq(containerIP.String(), cfg.NoMasqueradeCIDRRange, cfg.VTEPName)
+	err = pluginController.AddIPMasq(containerIP.String(), cfg.NoMasqueradeCIDRRange, vtepName, egressIP)
 	if err != nil {
-		return fmt.Errorf("error setting up default ip masq rule: %s", err)
+		return rollback.Fail(fmt.Errorf("error setting up default ip masq rule: %s", err))
 	}
 
 	resultActual.DNS.Nameservers = cfg.DNSServers
 
 	resultVersioned, err := resultActual.GetAsVersion(cfg.CNIVersion)
 	if err != nil {
-		return fmt.Errorf("converting to CNI version %s: %s", cfg.CNIVersion, err)
+		return rollback.Fail(fmt.Errorf("converting to CNI version %s: %s", cfg.CNIVersion, err))
 	}
 	return resultVersioned.Print()
 }
 
 func cmdCheck(args *skel.CmdArgs) error {
-	return fmt.Errorf("Meow this isn't implemented yet")
+	cfg, err := lib.LoadWrapperConfig(args.StdinData)
+	if err != nil {
+		return err
+	}
+
+	pluginController, err := newPluginController(cfg)
+	if err != nil {
+		return err
+	}
+
+	var cniCheckData struct {
+		Metadata   map[string]interface{}
+		PrevResult map[string]interface{} `json:"prevResult"`
+	}
+	if err := json.Unmarshal(args.StdinData, &cniCheckData); err != nil {
+		return err // not tested, this should be impossible
+	}
+	if cniCheckData.PrevResult == nil {
+		return types.NewError(types.ErrInvalidNetworkConfig, "missing prevResult", "")
+	}
+
+	cfg.Delegate["prevResult"] = cniCheckData.PrevResult
+	setDelegateContainerConfig(cfg, cniCheckData.Metadata)
+
+	if err := pluginController.DelegateCheck(cfg.Delegate); err != nil {
+		if _, ok := err.(*types.Error); ok {
+			return err
+		}
+		return fmt.Errorf("delegate call: %s", err)
+	}
+
+	store := &datastore.Store{
+		Serializer: &serial.Serial{},
+		Locker: &filelock.Locker{
+			FileLocker: filelock.NewLocker(cfg.Datastore + "_lock"),
+			Mutex:      new(sync.Mutex),
+		},
+		DataFilePath:    cfg.Datastore,
+		VersionFilePath: cfg.Datastore + "_version",
+		CacheMutex:      new(sync.RWMutex),
+	}
+
+	containers, err := store.ReadAll()
+	if err != nil {
+		return types.NewError(types.ErrIOFailure, "read container metadata", err.Error())
+	}
+	container, ok := containers[args.ContainerID]
+	if !ok {
+		return types.NewError(types.ErrUnknownContainer, "container not found in datastore", args.ContainerID)
+	}
+
+	prevResultBytes, err := json.Marshal(cniCheckData.PrevResult)
+	if err != nil {
+		return err // not tested, this should be impossible
+	}
+	result, err := current.NewResult(prevResultBytes)
+	if err != nil {
+		return types.NewError(types.ErrDecodingFailure, "parse prevResult", err.Error())
+	}
+	prevResult, err := current.GetResult(result)
+	if err != nil {
+		return types.NewError(types.ErrDecodingFailure, "convert prevResult to current CNI version", err.Error())
+	}
+	if len(prevResult.IPs) == 0 {
+		return types.NewError(types.ErrInvalidNetworkConfig, "no IP address in prevResult", "")
+	}
+	if containerIP := prevResult.IPs[0].Address.IP.String(); container.IP != containerIP {
+		return types.NewError(lib.ErrMetadataDrift, "container metadata has a different ip", fmt.Sprintf("expected %s, found %s", containerIP, container.IP))
+	}
+
+	attachments, err := lib.RecordedAttachments(container.Metadata)
+	if err != nil {
+		return types.NewError(types.ErrDecodingFailure, "parse recorded attachments", err.Error())
+	}
+	for _, attachment := range attachments {
+		attachment.Delegate["prevResult"] = lib.AttachmentResult(prevResult, attachment.IfName)
+		if err := pluginController.DelegateCheckAttachment(attachment); err != nil {
+			if _, ok := err.(*types.Error); ok {
+				return err
+			}
+			return fmt.Errorf("delegate call for attachment %s: %s", attachment.IfName, err)
+		}
+	}
+
+	vtepName := cfg.VTEPName
+	if containerVTEPName, ok := container.Metadata["vtep_name"].(string); ok && containerVTEPName != "" {
+		vtepName = containerVTEPName
+	}
+
+	var containerWorkload string
+	if workload, present := container.Metadata["container_workload"]; present {
+		containerWorkload, _ = workload.(string)
+	}
+
+	interfaceNameLookup := interfacelookup.InterfaceNameLookup{
+		NetlinkAdapter: &adapter.NetlinkAdapter{},
+	}
+
+	var interfaceNames []string
+	if len(cfg.TemporaryUnderlayInterfaceNames) > 0 {
+		interfaceNames = cfg.TemporaryUnderlayInterfaceNames
+	} else {
+		interfaceNames, err = interfaceNameLookup.GetNamesFromIPs(cfg.UnderlayIPs)
+		if err != nil {
+			return fmt.Errorf("looking up interface names: %s", err) // not tested
+		}
+	}
+
+	chainNamer := &netrules.ChainNamer{
+		MaxLength: 28,
+	}
+	outConn := netrules.OutConn{
+		Limit:      cfg.OutConn.Limit,
+		Logging:    cfg.OutConn.Logging,
+		Burst:      cfg.OutConn.Burst,
+		RatePerSec: cfg.OutConn.RatePerSec,
+		DryRun:     cfg.OutConn.DryRun,
+	}
+
+	localDNSServers, err := getLocalDNSServers(cfg.DNSServers)
+	if err != nil {
+		return err
+	}
+
+	netOutChain := &netrules.NetOutChain{
+		ChainNamer:       chainNamer,
+		Converter:        &netrules.RuleConverter{LogWriter: os.Stderr},
+		ASGLogging:       cfg.IPTablesASGLogging,
+		DeniedLogsPerSec: cfg.IPTablesDeniedLogsPerSec,
+		Conn:             outConn,
+	}
+
+	netOutProvider := netrules.NetOut{
+		ChainNamer:            chainNamer,
+		IPTables:              pluginController.IPTables,
+		NetOutChain:           netOutChain,
+		C2CLogging:            cfg.IPTablesC2CLogging,
+		DeniedLogsPerSec:      cfg.IPTablesDeniedLogsPerSec,
+		AcceptedUDPLogsPerSec: cfg.IPTablesAcceptedUDPLogsPerSec,
+		IngressTag:            cfg.IngressTag,
+		VTEPName:              vtepName,
+		HostInterfaceNames:    interfaceNames,
+		ContainerHandle:       args.ContainerID,
+		ContainerWorkload:     containerWorkload,
+		ContainerIP:           container.IP,
+		HostTCPServices:       cfg.HostTCPServices,
+		HostUDPServices:       cfg.HostUDPServices,
+		DNSServers:            localDNSServers,
+		Conn:                  outConn,
+	}
+	if err := netOutProvider.Check(); err != nil {
+		return types.NewError(lib.ErrNetOutDrift, "check net out", err.Error())
+	}
+
+	netInProvider := netrules.NetIn{
+		ChainNamer: &netrules.ChainNamer{
+			MaxLength: 28,
+		},
+		IPTables:           pluginController.IPTables,
+		IngressTag:         cfg.IngressTag,
+		HostInterfaceNames: interfaceNames,
+	}
+	if err := netInProvider.Check(args.ContainerID); err != nil {
+		return types.NewError(lib.ErrNetInDrift, "check net in", err.Error())
+	}
+	for _, netIn := range cfg.RuntimeConfig.PortMappings {
+		mapping := netrules.PortMapping{
+			Protocol:      netIn.Protocol,
+			HostPort:      int(netIn.HostPort),
+			HostPortEnd:   int(netIn.HostPortEnd),
+			ContainerPort: int(netIn.ContainerPort),
+		}
+		if err := netInProvider.CheckRule(args.ContainerID, mapping, cfg.InstanceAddress, container.IP); err != nil {
+			return types.NewError(lib.ErrNetInDrift, "check net in", err.Error())
+		}
+	}
+
+	egressIP, _ := container.Metadata["egress_ip"].(string)
+	err = pluginController.CheckIPMasq(container.IP, cfg.NoMasqueradeCIDRRange, vtepName, egressIP)
+	if err != nil {
+		return types.NewError(lib.ErrIPMasqueradeDrift, "check ip masquerade", err.Error())
+	}
+
+	return nil
+}
+
+// setDelegateContainerConfig passes the metadata and runtime config the
+// delegate needs to set up, or check, the container. The delegate selects the
+// overlay, the bandwidth limits, the sysctls and the extra routes for the
+// container from its metadata.
+func setDelegateContainerConfig(cfg *lib.WrapperConfig, metadata map[string]interface{}) {
+	if (len(cfg.AdditionalOverlays) > 0 || lib.HasBandwidthMetadata(metadata) || lib.HasContainerOptionsMetadata(metadata)) && metadata != nil {
+		cfg.Delegate["metadata"] = metadata
+	}
+	delegateRuntimeConfig := map[string]interface{}{}
+	if cfg.RuntimeConfig.Bandwidth != nil {
+		delegateRuntimeConfig["bandwidth"] = cfg.RuntimeConfig.Bandwidth
+	}
+	if len(cfg.RuntimeConfig.IPs) > 0 {
+		delegateRuntimeConfig["ips"] = cfg.RuntimeConfig.IPs
+	}
+	if len(cfg.RuntimeConfig.Sysctls) > 0 {
+		delegateRuntimeConfig["sysctls"] = cfg.RuntimeConfig.Sysctls
+	}
+	if len(cfg.RuntimeConfig.Routes) > 0 {
+		delegateRuntimeConfig["routes"] = cfg.RuntimeConfig.Routes
+	}
+	if len(delegateRuntimeConfig) > 0 {
+		cfg.Delegate["runtimeConfig"] = delegateRuntimeConfig
+	}
 }
 
 func getLocalDNSServers(allDNSServers []string) ([]string, error) {
@@ -273,6 +554,81 @@ func cmdDel(args *skel.CmdArgs) error {
 		fmt.Fprintf(os.Stderr, "delegate delete: %s", err)
 	}
 
+	delAttachments(pluginController, container)
+
+	return cleanupContainer(cfg, pluginController, args.ContainerID, container)
+}
+
+// delAttachments invokes the delegates of the attachments recorded for the
+// container in the reverse order of ADD. Failures are logged, so that deletes
+// keep going.
+func delAttachments(pluginController *lib.PluginController, container datastore.Container) {
+	attachments, err := lib.RecordedAttachments(container.Metadata)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "recorded attachments: %s", err)
+		return
+	}
+
+	for i := len(attachments) - 1; i >= 0; i-- {
+		attachment := attachments[i]
+		if err := pluginController.DelegateDelAttachment(attachment); err != nil {
+			fmt.Fprintf(os.Stderr, "delegate delete for attachment %s: %s", attachment.IfName, err)
+		}
+	}
+}
+
+// addAttachments invokes the delegates of the secondary attachments in order
+// and merges their interfaces and IPs into the result of the primary
+// delegate. It returns the interface name and IP of every attachment for the
+// datastore entry of the container, together with the delegate config that
+// DEL, CHECK and GC use.
+func addAttachments(cfg *lib.WrapperConfig, pluginController *lib.PluginController, rollback *lib.Rollback, primaryIfName string, resultActual *current.Result) ([]map[string]interface{}, error) {
+	var attachments []map[string]interface{}
+	for _, attachment := range cfg.Attachments {
+		if attachment.IfName == primaryIfName {
+			return nil, fmt.Errorf("attachment %s: interface name is already used by the primary interface", attachment.IfName)
+		}
+
+		result, err := pluginController.DelegateAddAttachment(attachment)
+		if err != nil {
+			return nil, fmt.Errorf("delegate call for attachment %s: %s", attachment.IfName, err)
+		}
+		rollback.Record(fmt.Sprintf("delegate add for attachment %s", attachment.IfName), func() error {
+			return pluginController.DelegateDelAttachment(attachment)
+		})
+
+		attachmentResult, err := current.GetResult(result)
+		if err != nil {
+			return nil, fmt.Errorf("converting result from attachment %s: %s", attachment.IfName, err) // not tested
+		}
+
+		interfaceOffset := len(resultActual.Interfaces)
+		resultActual.Interfaces = append(resultActual.Interfaces, attachmentResult.Interfaces...)
+
+		var attachmentIP string
+		for _, ip := range attachmentResult.IPs {
+			if ip.Interface != nil {
+				index := *ip.Interface + interfaceOffset
+				ip.Interface = &index
+			}
+			resultActual.IPs = append(resultActual.IPs, ip)
+			if attachmentIP == "" {
+				attachmentIP = ip.Address.IP.String()
+			}
+		}
+
+		attachments = append(attachments, map[string]interface{}{
+			"ifname":   attachment.IfName,
+			"ip":       attachmentIP,
+			"delegate": attachment.Delegate,
+		})
+	}
+	return attachments, nil
+}
+
+// cleanupContainer removes the host rules that ADD created for the container
+// and asks the policy agent to remove its ASG rules.
+func cleanupContainer(cfg *lib.WrapperConfig, pluginController *lib.PluginController, containerHandle string, container datastore.Container) error {
 	netInProvider := netrules.NetIn{
 		ChainNamer: &netrules.ChainNamer{
 			MaxLength: 28,
@@ -281,7 +637,7 @@ func cmdDel(args *skel.CmdArgs) error {
 		IngressTag: cfg.IngressTag,
 	}
 
-	if err = netInProvider.Cleanup(args.ContainerID); err != nil {
+	if err := netInProvider.Cleanup(containerHandle); err != nil {
 		fmt.Fprintf(os.Stderr, "net in cleanup: %s", err)
 	}
 
@@ -290,6 +646,7 @@ func cmdDel(args *skel.CmdArgs) error {
 	}
 
 	var interfaceNames []string
+	var err error
 	if len(cfg.TemporaryUnderlayInterfaceNames) > 0 {
 		interfaceNames = cfg.TemporaryUnderlayInterfaceNames
 	} else {
@@ -318,22 +675,39 @@ func cmdDel(args *skel.CmdArgs) error {
 		ChainNamer:         chainNamer,
 		NetOutChain:        netOutChain,
 		IPTables:           pluginController.IPTables,
-		ContainerHandle:    args.ContainerID,
+		ContainerHandle:    containerHandle,
 		ContainerIP:        container.IP,
 		HostInterfaceNames: interfaceNames,
 		Conn:               outConn,
+		IPSets:             pluginController.IPSets,
 	}
 
 	if err = netOutProvider.Cleanup(); err != nil {
 		fmt.Fprintf(os.Stderr, "net out cleanup: %s", err)
 	}
 
-	err = pluginController.DelIPMasq(container.IP, cfg.NoMasqueradeCIDRRange, cfg.VTEPName)
+	vtepName := cfg.VTEPName
+	if containerVTEPName, ok := container.Metadata["vtep_name"].(string); ok && containerVTEPName != "" {
+		vtepName = containerVTEPName
+	}
+
+	egressIP, _ := container.Metadata["egress_ip"].(string)
+	err = pluginController.DelIPMasq(container.IP, cfg.NoMasqueradeCIDRRange, vtepName, egressIP)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "removing IP masq: %s", err)
 	}
 
-	resp, err := http.DefaultClient.Get(fmt.Sprintf("http://%s/force-orphaned-asgs-cleanup?container=%s", cfg.PolicyAgentForcePollAddress, args.ContainerID))
+	policyAgent, err := lib.NewPolicyAgentClient(cfg)
+	if err != nil {
+		return err
+	}
+	return forceOrphanedASGsCleanup(policyAgent, containerHandle)
+}
+
+// forceOrphanedASGsCleanup asks the policy agent to remove the ASG rules of
+// the container.
+func forceOrphanedASGsCleanup(policyAgent *lib.PolicyAgentClient, containerHandle string) error {
+	resp, err := policyAgent.Get(fmt.Sprintf("/force-orphaned-asgs-cleanup?container=%s", containerHandle))
 	if err != nil {
 		return err
 	}
@@ -347,6 +721,121 @@ func cmdDel(args *skel.CmdArgs) error {
 	return nil
 }
 
+func cmdGC(args *skel.CmdArgs) error {
+	cfg, err := lib.LoadWrapperConfig(args.StdinData)
+	if err != nil {
+		return err
+	}
+
+	pluginController, err := newPluginController(cfg)
+	if err != nil {
+		return err
+	}
+
+	var cniGCData struct {
+		ValidAttachments []types.GCAttachment `json:"cni.dev/valid-attachments"`
+	}
+	if err := json.Unmarshal(args.StdinData, &cniGCData); err != nil {
+		return err // not tested, this should be impossible
+	}
+
+	// GC is only defined from CNI 1.1.0, so the delegate must use the version of the request
+	cfg.Delegate["cniVersion"] = cfg.CNIVersion
+	cfg.Delegate["cni.dev/valid-attachments"] = cniGCData.ValidAttachments
+
+	var result error
+	if err := pluginController.DelegateGC(cfg.Delegate); err != nil {
+		result = multierror.Append(result, fmt.Errorf("delegate call: %s", err))
+	}
+
+	// the attachment delegates keep the state of the same containers, on the
+	// interface name of the attachment
+	for _, attachment := range cfg.Attachments {
+		validAttachments := make([]types.GCAttachment, 0, len(cniGCData.ValidAttachments))
+		for _, valid := range cniGCData.ValidAttachments {
+			validAttachments = append(validAttachments, types.GCAttachment{ContainerID: valid.ContainerID, IfName: attachment.IfName})
+		}
+		attachment.Delegate["cniVersion"] = cfg.CNIVersion
+		attachment.Delegate["cni.dev/valid-attachments"] = validAttachments
+		if err := pluginController.DelegateGC(attachment.Delegate); err != nil {
+			result = multierror.Append(result, fmt.Errorf("delegate call for attachment %s: %s", attachment.IfName, err))
+		}
+	}
+
+	store := &datastore.Store{
+		Serializer: &serial.Serial{},
+		Locker: &filelock.Locker{
+			FileLocker: filelock.NewLocker(cfg.Datastore + "_lock"),
+			Mutex:      new(sync.Mutex),
+		},
+		DataFilePath:    cfg.Datastore,
+		VersionFilePath: cfg.Datastore + "_version",
+		CacheMutex:      new(sync.RWMutex),
+	}
+
+	containers, err := store.ReadAll()
+	if err != nil {
+		return multierror.Append(result, fmt.Errorf("read container metadata: %s", err))
+	}
+
+	validContainers := map[string]bool{}
+	for _, attachment := range cniGCData.ValidAttachments {
+		validContainers[attachment.ContainerID] = true
+	}
+
+	for handle := range containers {
+		if validContainers[handle] {
+			continue
+		}
+
+		container, err := store.Delete(handle)
+		if err != nil {
+			result = multierror.Append(result, fmt.Errorf("store delete %s: %s", handle, err))
+			continue
+		}
+
+		delAttachments(pluginController, container)
+
+		if err := cleanupContainer(cfg, pluginController, handle, container); err != nil {
+			result = multierror.Append(result, fmt.Errorf("cleanup %s: %s", handle, err))
+		}
+	}
+
+	return result
+}
+
+func cmdStatus(args *skel.CmdArgs) error {
+	cfg, err := lib.LoadWrapperConfig(args.StdinData)
+	if err != nil {
+		return err
+	}
+
+	pluginController, err := newPluginController(cfg)
+	if err != nil {
+		return err
+	}
+
+	// STATUS is only defined from CNI 1.1.0, so the delegate must use the version of the request
+	cfg.Delegate["cniVersion"] = cfg.CNIVersion
+
+	if err := pluginController.DelegateStatus(cfg.Delegate); err != nil {
+		if _, ok := err.(*types.Error); ok {
+			return err
+		}
+		return types.NewError(lib.ErrPluginNotAvailable, "delegate call", err.Error())
+	}
+
+	policyAgent, err := lib.NewPolicyAgentClient(cfg)
+	if err != nil {
+		return types.NewError(lib.ErrPluginNotAvailable, "policy agent client", err.Error())
+	}
+	if err := policyAgent.Ping(); err != nil {
+		return types.NewError(lib.ErrPluginNotAvailable, "policy agent force poll endpoint is unreachable", err.Error())
+	}
+
+	return nil
+}
+
 func ensureIptablesFileOwnership(filePath, fileOwner, fileGroup string) error {
 	err := os.WriteFile(filePath, make([]byte, 0), 0600)
 	if err != nil {
@@ -394,12 +883,7 @@ func lookupFileOwnerUIDandGID(fileOwner, fileGroup string) (int, int, error) {
 }
 
 func newPluginController(config *lib.WrapperConfig) (*lib.PluginController, error) {
-	ipt, err := iptables.New()
-	if err != nil {
-		return nil, err
-	}
-
-	err = ensureIptablesFileOwnership(config.IPTablesLockFile, config.DatastoreFileOwner, config.DatastoreFileGroup)
+	err := ensureIptablesFileOwnership(config.IPTablesLockFile, config.DatastoreFileOwner, config.DatastoreFileGroup)
 	if err != nil {
 		return nil, err
 	}
@@ -408,27 +892,48 @@ func newPluginController(config *lib.WrapperConfig) (*lib.PluginController, erro
 		FileLocker: filelock.NewLocker(config.IPTablesLockFile),
 		Mutex:      &sync.Mutex{},
 	}
-	restorer := &rules.Restorer{}
-	lockedIPTables := &rules.LockedIPTables{
-		IPTables: ipt,
-		Locker:   iptLocker,
-		Restorer: restorer,
+
+	var ipTablesAdapter rules.IPTablesAdapter
+	var ipSets rules.IPSetAdapter
+	if config.FirewallBackend == rules.NFTablesBackend {
+		ipTablesAdapter, err = rules.NewNFTables(iptLocker)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		ipt, err := iptables.New()
+		if err != nil {
+			return nil, err
+		}
+		lockedIPTables := &rules.LockedIPTables{
+			IPTables: ipt,
+			Locker:   iptLocker,
+			Restorer: &rules.Restorer{},
+		}
+		if config.IPTablesASGIPSets {
+			lockedIPTables.IPSets = &rules.IPSets{}
+			ipSets = &rules.LockedIPSets{IPSets: &rules.IPSets{}, Locker: iptLocker}
+		}
+		ipTablesAdapter = lockedIPTables
 	}
 
 	pluginController := &lib.PluginController{
 		Delegator: lib.NewDelegator(),
-		IPTables:  lockedIPTables,
+		IPTables:  ipTablesAdapter,
+		IPSets:    ipSets,
 	}
 	return pluginController, nil
 }
 
 func main() {
-	supportedVersions := []string{"1.0.0"}
+	supportedVersions := []string{"1.0.0", "1.1.0"}
 
 	cniFuncs := skel.CNIFuncs{
-		Add:   cmdAdd,
-		Del:   cmdDel,
-		Check: cmdCheck,
+		Add:    cmdAdd,
+		Del:    cmdDel,
+		Check:  cmdCheck,
+		GC:     cmdGC,
+		Status: cmdStatus,
 	}
 	skel.PluginMainFuncs(cniFuncs, version.PluginSupports(supportedVersions...), "CNI Plugin silk-cni-wrapper-plugin")
 }
@@ -8,30 +8,29 @@ import (
 	multierror "github.com/hashicorp/go-multierror"
 )
 
-func initChains(iptables rules.IPTablesAdapter, fullRules []IpTablesFullChain) error {
+// stageChains adds the chains and their jump rules to tx before any of the
+// chains' own rules, so that rules jumping to a sibling chain find it.
+func stageChains(tx *rules.RestoreTransaction, fullRules []IpTablesFullChain) {
 	for _, rule := range fullRules {
-		err := iptables.NewChain(rule.Table, rule.ChainName)
-		if err != nil {
-			return fmt.Errorf("creating chain: %s", err)
-		}
+		tx.NewChain(rule.Table, rule.ChainName)
 
 		if rule.ParentChain != "" {
-			err = iptables.BulkAppend(rule.Table, rule.ParentChain, rule.JumpConditions...)
-			if err != nil {
-				return fmt.Errorf("appending rule to chain: %s", err)
-			}
+			tx.Append(rule.Table, rule.ParentChain, rule.JumpConditions...)
 		}
 	}
 
-	return nil
+	stageRules(tx, fullRules)
 }
 
-func applyRules(iptables rules.IPTablesAdapter, fullRules []IpTablesFullChain) error {
+func stageRules(tx *rules.RestoreTransaction, fullRules []IpTablesFullChain) {
 	for _, rule := range fullRules {
-		err := iptables.BulkAppend(rule.Table, rule.ChainName, rule.Rules...)
-		if err != nil {
-			return fmt.Errorf("appending rule: %s", err)
-		}
+		tx.Append(rule.Table, rule.ChainName, rule.Rules...)
+	}
+}
+
+func applyTransaction(iptables rules.IPTablesAdapter, tx *rules.RestoreTransaction) error {
+	if err := iptables.ApplyTransaction(tx); err != nil {
+		return fmt.Errorf("applying rules: %s", err)
 	}
 
 	return nil
@@ -66,3 +65,46 @@ func cleanupChains(args []IpTablesFullChain, iptables rules.IPTablesAdapter) err
 	}
 	return result
 }
+
+func checkChains(iptables rules.IPTablesAdapter, fullRules []IpTablesFullChain) error {
+	for _, rule := range fullRules {
+		exists, err := iptables.ChainExists(rule.Table, rule.ChainName)
+		if err != nil {
+			return fmt.Errorf("checking chain %s: %s", rule.ChainName, err)
+		}
+		if !exists {
+			return fmt.Errorf("missing chain %s in table %s", rule.ChainName, rule.Table)
+		}
+
+		if rule.ParentChain == "" {
+			continue
+		}
+		for _, condition := range rule.JumpConditions {
+			exists, err := iptables.Exists(rule.Table, rule.ParentChain, condition)
+			if err != nil {
+				return fmt.Errorf("checking jump rule to %s: %s", rule.ChainName, err)
+			}
+			if !exists {
+				return fmt.Errorf("missing jump rule %v in %s/%s", condition, rule.Table, rule.ParentChain)
+			}
+		}
+	}
+
+	return checkRules(iptables, fullRules)
+}
+
+func checkRules(iptables rules.IPTablesAdapter, fullRules []IpTablesFullChain) error {
+	for _, rule := range fullRules {
+		for _, r := range rule.Rules {
+			exists, err := iptables.Exists(rule.Table, rule.ChainName, r)
+			if err != nil {
+				return fmt.Errorf("checking rule in %s: %s", rule.ChainName, err)
+			}
+			if !exists {
+				return fmt.Errorf("missing rule %v in %s/%s", r, rule.Table, rule.ChainName)
+			}
+		}
+	}
+
+	return nil
+}
@@ -4,12 +4,23 @@ import (
 	"code.cloudfoundry.org/garden"
 )
 
+// RuntimeNetOutRule is a garden net out rule as the CNI runtime config passes
+// it, with the action and priority of the security group rule it came from.
+type RuntimeNetOutRule struct {
+	garden.NetOutRule
+	Action   string `json:"action,omitempty"`
+	Priority int    `json:"priority,omitempty"`
+}
+
 type gardenNetOutRule struct {
-	rule garden.NetOutRule
+	rule     garden.NetOutRule
+	action   Action
+	priority int
 }
 
+// NewRuleFromGardenNetOutRule returns an allow rule with priority 0.
 func NewRuleFromGardenNetOutRule(gardenRule garden.NetOutRule) Rule {
-	return &gardenNetOutRule{rule: gardenRule}
+	return &gardenNetOutRule{rule: gardenRule, action: ActionAllow}
 }
 
 func NewRulesFromGardenNetOutRules(gardenRules []garden.NetOutRule) []Rule {
@@ -20,6 +31,30 @@ func NewRulesFromGardenNetOutRules(gardenRules []garden.NetOutRule) []Rule {
 	return ruleSpec
 }
 
+func NewRulesFromRuntimeNetOutRules(runtimeRules []RuntimeNetOutRule) ([]Rule, error) {
+	ruleSpec := []Rule{}
+	for _, runtimeRule := range runtimeRules {
+		action, err := parseAction(runtimeRule.Action)
+		if err != nil {
+			return nil, err
+		}
+		ruleSpec = append(ruleSpec, &gardenNetOutRule{
+			rule:     runtimeRule.NetOutRule,
+			action:   action,
+			priority: runtimeRule.Priority,
+		})
+	}
+	return ruleSpec, nil
+}
+
+func (r *gardenNetOutRule) Action() Action {
+	return r.action
+}
+
+func (r *gardenNetOutRule) Priority() int {
+	return r.priority
+}
+
 func (r *gardenNetOutRule) Log() bool {
 	return r.rule.Log
 }
@@ -48,6 +83,10 @@ func (r *gardenNetOutRule) Networks() []IPRange {
 	return networks
 }
 
+func (r *gardenNetOutRule) FQDNs() []string {
+	return nil
+}
+
 func (r *gardenNetOutRule) Ports() []PortRange {
 	var ports []PortRange
 	for _, port := range r.rule.Ports {
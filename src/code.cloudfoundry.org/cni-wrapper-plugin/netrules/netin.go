@@ -11,6 +11,17 @@ import (
 
 const prefixNetIn = "netin"
 
+// PortMapping forwards the host ports from HostPort to HostPortEnd to the
+// container over Protocol, tcp or udp. Without HostPortEnd the single host
+// port is forwarded to ContainerPort. A range is forwarded to the same ports
+// of the container. The protocol defaults to tcp.
+type PortMapping struct {
+	Protocol      string
+	HostPort      int
+	HostPortEnd   int
+	ContainerPort int
+}
+
 type NetIn struct {
 	ChainNamer         chainNamer
 	IPTables           rules.IPTablesAdapter
@@ -19,7 +30,21 @@ type NetIn struct {
 }
 
 func (m *NetIn) Initialize(containerHandle string) error {
-	return initChains(m.IPTables, m.defaultNetInRules(containerHandle))
+	tx := &rules.RestoreTransaction{}
+	m.StageInitialize(tx, containerHandle)
+	return applyTransaction(m.IPTables, tx)
+}
+
+// StageInitialize adds the chains and jump rules created by Initialize to tx
+// without applying them.
+func (m *NetIn) StageInitialize(tx *rules.RestoreTransaction, containerHandle string) {
+	stageChains(tx, m.defaultNetInRules(containerHandle))
+}
+
+// Check verifies that the chains created by Initialize and their jump rules
+// are still present.
+func (m *NetIn) Check(containerHandle string) error {
+	return checkChains(m.IPTables, m.defaultNetInRules(containerHandle))
 }
 
 func (m *NetIn) defaultNetInRules(containerHandle string) []IpTablesFullChain {
@@ -31,7 +56,7 @@ func (m *NetIn) defaultNetInRules(containerHandle string) []IpTablesFullChain {
 			ParentChain: "PREROUTING",
 			ChainName:   chain,
 			JumpConditions: []rules.IPTablesRule{
-				{"--jump", chain},
+				rules.Rule{Jump: chain}.Args(),
 			},
 		},
 		{
@@ -39,7 +64,7 @@ func (m *NetIn) defaultNetInRules(containerHandle string) []IpTablesFullChain {
 			ParentChain: "PREROUTING",
 			ChainName:   chain,
 			JumpConditions: []rules.IPTablesRule{
-				{"--jump", chain},
+				rules.Rule{Jump: chain}.Args(),
 			},
 		},
 	}
@@ -58,35 +83,84 @@ func (m *NetIn) Cleanup(containerHandle string) error {
 	return result
 }
 
-func (m *NetIn) AddRule(containerHandle string, hostPort, containerPort int, hostIP, containerIP string) error {
+func (m *NetIn) AddRule(containerHandle string, mapping PortMapping, hostIP, containerIP string) error {
+	tx := &rules.RestoreTransaction{}
+	if err := m.StageRule(tx, containerHandle, mapping, hostIP, containerIP); err != nil {
+		return err
+	}
+
+	return applyTransaction(m.IPTables, tx)
+}
+
+// StageRule adds the port forwarding and mark rules created by AddRule to tx
+// without applying them.
+func (m *NetIn) StageRule(tx *rules.RestoreTransaction, containerHandle string, mapping PortMapping, hostIP, containerIP string) error {
+	containerIngressRules, err := m.ingressRules(containerHandle, mapping, hostIP, containerIP)
+	if err != nil {
+		return err
+	}
+
+	stageRules(tx, containerIngressRules)
+	return nil
+}
+
+// CheckRule verifies that the port forwarding and mark rules created by
+// AddRule are still present.
+func (m *NetIn) CheckRule(containerHandle string, mapping PortMapping, hostIP, containerIP string) error {
+	containerIngressRules, err := m.ingressRules(containerHandle, mapping, hostIP, containerIP)
+	if err != nil {
+		return err
+	}
+
+	return checkRules(m.IPTables, containerIngressRules)
+}
+
+func (m *NetIn) ingressRules(containerHandle string, mapping PortMapping, hostIP, containerIP string) ([]IpTablesFullChain, error) {
 	chain := m.ChainNamer.Prefix(prefixNetIn, containerHandle)
 
 	parsedIP := net.ParseIP(hostIP)
 	if parsedIP == nil {
-		return fmt.Errorf("invalid ip: %s", hostIP)
+		return nil, fmt.Errorf("invalid ip: %s", hostIP)
 	}
 
 	parsedIP = net.ParseIP(containerIP)
 	if parsedIP == nil {
-		return fmt.Errorf("invalid ip: %s", containerIP)
+		return nil, fmt.Errorf("invalid ip: %s", containerIP)
+	}
+
+	protocol := mapping.Protocol
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	if protocol != "tcp" && protocol != "udp" {
+		return nil, fmt.Errorf("invalid protocol: %s", protocol)
 	}
 
-	containerIngressRules := []IpTablesFullChain{
+	startPort, endPort := mapping.HostPort, mapping.HostPortEnd
+	if endPort == 0 {
+		endPort = startPort
+	}
+	if endPort < startPort {
+		return nil, fmt.Errorf("invalid port range: %d-%d", startPort, endPort)
+	}
+	if endPort != startPort && mapping.ContainerPort != 0 && mapping.ContainerPort != startPort {
+		return nil, fmt.Errorf("port range %d-%d must be forwarded to the same container ports", startPort, endPort)
+	}
+
+	return []IpTablesFullChain{
 		{
 			Table:       "nat",
 			ParentChain: "PREROUTING",
 			ChainName:   chain,
 			Rules: []rules.IPTablesRule{
-				rules.NewPortForwardingRule(hostPort, containerPort, hostIP, containerIP),
+				rules.NewPortForwardingRule(protocol, startPort, endPort, mapping.ContainerPort, hostIP, containerIP),
 			},
 		},
 		{
 			Table:       "mangle",
 			ParentChain: "PREROUTING",
 			ChainName:   chain,
-			Rules:       rules.NewIngressMarkRules(m.HostInterfaceNames, hostPort, hostIP, m.IngressTag),
+			Rules:       rules.NewIngressMarkRules(m.HostInterfaceNames, protocol, startPort, endPort, hostIP, m.IngressTag),
 		},
-	}
-
-	return applyRules(m.IPTables, containerIngressRules)
+	}, nil
 }
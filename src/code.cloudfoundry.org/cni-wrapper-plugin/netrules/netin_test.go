@@ -33,6 +33,11 @@ var _ = Describe("Netin", func() {
 		chainNamer.PrefixReturns("some-chain-name")
 	})
 
+	appliedTransaction := func() *rules.RestoreTransaction {
+		Expect(ipTables.ApplyTransactionCallCount()).To(Equal(1))
+		return ipTables.ApplyTransactionArgsForCall(0)
+	}
+
 	Describe("Initialize", func() {
 		It("creates the chain with the name from the chain namer in the nat and mangle tables", func() {
 			err := netIn.Initialize("some-container-handle")
@@ -43,49 +48,98 @@ var _ = Describe("Netin", func() {
 			Expect(prefix).To(Equal("netin"))
 			Expect(handle).To(Equal("some-container-handle"))
 
-			Expect(ipTables.NewChainCallCount()).To(Equal(2))
-			table, chain := ipTables.NewChainArgsForCall(0)
-			Expect(table).To(Equal("nat"))
-			Expect(chain).To(Equal("some-chain-name"))
-
-			table, chain = ipTables.NewChainArgsForCall(1)
-			Expect(table).To(Equal("mangle"))
-			Expect(chain).To(Equal("some-chain-name"))
+			tx := appliedTransaction()
+			Expect(tx.Tables()).To(Equal([]string{"nat", "mangle"}))
+			Expect(tx.Chains("nat")).To(Equal([]string{"some-chain-name"}))
+			Expect(tx.Chains("mangle")).To(Equal([]string{"some-chain-name"}))
 		})
 
 		It("adds a jump rule for the new chain", func() {
 			err := netIn.Initialize("some-container-handle")
 			Expect(err).NotTo(HaveOccurred())
 
-			Expect(ipTables.BulkAppendCallCount()).To(Equal(2))
-			table, chain, rulespec := ipTables.BulkAppendArgsForCall(0)
+			tx := appliedTransaction()
+			Expect(tx.Rules("nat", "PREROUTING")).To(Equal([]rules.IPTablesRule{{"-j", "some-chain-name"}}))
+			Expect(tx.Rules("mangle", "PREROUTING")).To(Equal([]rules.IPTablesRule{{"-j", "some-chain-name"}}))
+		})
+
+		Context("when applying the rules fails", func() {
+			BeforeEach(func() {
+				ipTables.ApplyTransactionReturns(errors.New("potato"))
+			})
+			It("returns an error", func() {
+				err := netIn.Initialize("some-container-handle")
+				Expect(err).To(MatchError("applying rules: potato"))
+			})
+		})
+	})
+
+	Describe("Check", func() {
+		BeforeEach(func() {
+			ipTables.ChainExistsReturns(true, nil)
+			ipTables.ExistsReturns(true, nil)
+		})
+
+		It("checks the chain and jump rule in the nat and mangle tables", func() {
+			err := netIn.Check("some-container-handle")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(ipTables.ChainExistsCallCount()).To(Equal(2))
+			table, chain := ipTables.ChainExistsArgsForCall(0)
 			Expect(table).To(Equal("nat"))
-			Expect(chain).To(Equal("PREROUTING"))
-			Expect(rulespec).To(Equal([]rules.IPTablesRule{{"--jump", "some-chain-name"}}))
+			Expect(chain).To(Equal("some-chain-name"))
+			table, chain = ipTables.ChainExistsArgsForCall(1)
+			Expect(table).To(Equal("mangle"))
+			Expect(chain).To(Equal("some-chain-name"))
 
-			table, chain, rulespec = ipTables.BulkAppendArgsForCall(1)
+			Expect(ipTables.ExistsCallCount()).To(Equal(2))
+			table, chain, rule := ipTables.ExistsArgsForCall(1)
 			Expect(table).To(Equal("mangle"))
 			Expect(chain).To(Equal("PREROUTING"))
-			Expect(rulespec).To(Equal([]rules.IPTablesRule{{"--jump", "some-chain-name"}}))
+			Expect(rule).To(Equal(rules.IPTablesRule{"-j", "some-chain-name"}))
 		})
 
-		Context("when creating a new chain fails", func() {
+		Context("when the jump rule is missing", func() {
 			BeforeEach(func() {
-				ipTables.NewChainReturns(errors.New("potato"))
+				ipTables.ExistsReturns(false, nil)
 			})
-			It("returns an error", func() {
-				err := netIn.Initialize("some-container-handle")
-				Expect(err).To(MatchError("creating chain: potato"))
+
+			It("returns an error naming the rule", func() {
+				err := netIn.Check("some-container-handle")
+				Expect(err).To(MatchError("missing jump rule [-j some-chain-name] in nat/PREROUTING"))
 			})
 		})
+	})
+
+	Describe("CheckRule", func() {
+		BeforeEach(func() {
+			ipTables.ExistsReturns(true, nil)
+		})
+
+		It("checks the port forwarding and mark rules", func() {
+			err := netIn.CheckRule("some-container-handle", netrules.PortMapping{HostPort: 1111, ContainerPort: 2222}, "1.2.3.4", "5.6.7.8")
+			Expect(err).NotTo(HaveOccurred())
 
-		Context("when adding the jump rule fails", func() {
+			Expect(ipTables.ExistsCallCount()).To(Equal(3))
+			table, chain, rule := ipTables.ExistsArgsForCall(0)
+			Expect(table).To(Equal("nat"))
+			Expect(chain).To(Equal("some-chain-name"))
+			Expect(rule).To(Equal(rules.IPTablesRule{"-d", "1.2.3.4", "-p", "tcp", "-m", "tcp", "--dport", "1111", "-j", "DNAT", "--to-destination", "5.6.7.8:2222"}))
+
+			table, chain, rule = ipTables.ExistsArgsForCall(2)
+			Expect(table).To(Equal("mangle"))
+			Expect(chain).To(Equal("some-chain-name"))
+			Expect(rule).To(Equal(rules.IPTablesRule{"-d", "1.2.3.4", "-i", "underlay2", "-p", "tcp", "-m", "tcp", "--dport", "1111", "-j", "MARK", "--set-mark", "0xfeedbeef"}))
+		})
+
+		Context("when a rule is missing", func() {
 			BeforeEach(func() {
-				ipTables.BulkAppendReturns(errors.New("sweet potato"))
+				ipTables.ExistsReturnsOnCall(1, false, nil)
 			})
-			It("returns an error", func() {
-				err := netIn.Initialize("some-container-handle")
-				Expect(err).To(MatchError("appending rule to chain: sweet potato"))
+
+			It("returns an error naming the rule", func() {
+				err := netIn.CheckRule("some-container-handle", netrules.PortMapping{HostPort: 1111, ContainerPort: 2222}, "1.2.3.4", "5.6.7.8")
+				Expect(err).To(MatchError("missing rule [-d 1.2.3.4 -i underlay1 -p tcp -m tcp --dport 1111 -j MARK --set-mark 0xfeedbeef] in mangle/some-chain-name"))
 			})
 		})
 	})
@@ -104,12 +158,12 @@ var _ = Describe("Netin", func() {
 			table, chain, extraArgs := ipTables.DeleteArgsForCall(0)
 			Expect(table).To(Equal("nat"))
 			Expect(chain).To(Equal("PREROUTING"))
-			Expect(extraArgs).To(Equal(rules.IPTablesRule{"--jump", "some-chain-name"}))
+			Expect(extraArgs).To(Equal(rules.IPTablesRule{"-j", "some-chain-name"}))
 
 			table, chain, extraArgs = ipTables.DeleteArgsForCall(1)
 			Expect(table).To(Equal("mangle"))
 			Expect(chain).To(Equal("PREROUTING"))
-			Expect(extraArgs).To(Equal(rules.IPTablesRule{"--jump", "some-chain-name"}))
+			Expect(extraArgs).To(Equal(rules.IPTablesRule{"-j", "some-chain-name"}))
 		})
 
 		It("clears the container chain", func() {
@@ -197,7 +251,7 @@ var _ = Describe("Netin", func() {
 
 	Describe("AddRule", func() {
 		It("creates and enforces a portforwarding and mark rule", func() {
-			err := netIn.AddRule("some-container-handle", 1111, 2222, "1.2.3.4", "5.6.7.8")
+			err := netIn.AddRule("some-container-handle", netrules.PortMapping{HostPort: 1111, ContainerPort: 2222}, "1.2.3.4", "5.6.7.8")
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(chainNamer.PrefixCallCount()).To(Equal(1))
@@ -205,54 +259,91 @@ var _ = Describe("Netin", func() {
 			Expect(prefix).To(Equal("netin"))
 			Expect(handle).To(Equal("some-container-handle"))
 
-			Expect(ipTables.BulkAppendCallCount()).To(Equal(2))
-			table, chain, rulespec := ipTables.BulkAppendArgsForCall(0)
-			Expect(table).To(Equal("nat"))
-			Expect(chain).To(Equal("some-chain-name"))
-			Expect(rulespec).To(Equal([]rules.IPTablesRule{{
-				"-d", "1.2.3.4", "-p", "tcp",
+			tx := appliedTransaction()
+			Expect(tx.Chains("nat")).To(BeEmpty())
+			Expect(tx.Rules("nat", "some-chain-name")).To(Equal([]rules.IPTablesRule{{
+				"-d", "1.2.3.4",
+				"-p", "tcp",
 				"-m", "tcp", "--dport", "1111",
-				"--jump", "DNAT",
-				"--to-destination", "5.6.7.8:2222",
+				"-j", "DNAT", "--to-destination", "5.6.7.8:2222",
 			}}))
 
-			table, chain, rulespec = ipTables.BulkAppendArgsForCall(1)
-			Expect(table).To(Equal("mangle"))
-			Expect(chain).To(Equal("some-chain-name"))
-			Expect(rulespec).To(Equal([]rules.IPTablesRule{{
-				"-i", "underlay1", "-d", "1.2.3.4", "-p", "tcp",
+			Expect(tx.Rules("mangle", "some-chain-name")).To(Equal([]rules.IPTablesRule{{
+				"-d", "1.2.3.4",
+				"-i", "underlay1",
+				"-p", "tcp",
 				"-m", "tcp", "--dport", "1111",
-				"--jump", "MARK",
-				"--set-mark", "0xFEEDBEEF",
+				"-j", "MARK", "--set-mark", "0xfeedbeef",
 			}, {
-				"-i", "underlay2", "-d", "1.2.3.4", "-p", "tcp",
+				"-d", "1.2.3.4",
+				"-i", "underlay2",
+				"-p", "tcp",
 				"-m", "tcp", "--dport", "1111",
-				"--jump", "MARK",
-				"--set-mark", "0xFEEDBEEF",
+				"-j", "MARK", "--set-mark", "0xfeedbeef",
 			},
 			}))
 		})
 
+		Context("when the mapping is a udp port range", func() {
+			It("forwards the range to the same container ports", func() {
+				err := netIn.AddRule("some-container-handle", netrules.PortMapping{Protocol: "udp", HostPort: 1111, HostPortEnd: 1115, ContainerPort: 1111}, "1.2.3.4", "5.6.7.8")
+				Expect(err).NotTo(HaveOccurred())
+
+				tx := appliedTransaction()
+				Expect(tx.Rules("nat", "some-chain-name")).To(Equal([]rules.IPTablesRule{{
+					"-d", "1.2.3.4",
+					"-p", "udp",
+					"-m", "udp", "--dport", "1111:1115",
+					"-j", "DNAT", "--to-destination", "5.6.7.8",
+				}}))
+
+				Expect(tx.Rules("mangle", "some-chain-name")).To(Equal([]rules.IPTablesRule{{
+					"-d", "1.2.3.4",
+					"-i", "underlay1",
+					"-p", "udp",
+					"-m", "udp", "--dport", "1111:1115",
+					"-j", "MARK", "--set-mark", "0xfeedbeef",
+				}, {
+					"-d", "1.2.3.4",
+					"-i", "underlay2",
+					"-p", "udp",
+					"-m", "udp", "--dport", "1111:1115",
+					"-j", "MARK", "--set-mark", "0xfeedbeef",
+				},
+				}))
+			})
+		})
+
+		DescribeTable("when the mapping is invalid", func(mapping netrules.PortMapping, errMessage string) {
+			err := netIn.AddRule("some-container-handle", mapping, "1.2.3.4", "5.6.7.8")
+			Expect(err).To(MatchError(errMessage))
+			Expect(ipTables.ApplyTransactionCallCount()).To(Equal(0))
+		},
+			Entry("protocol", netrules.PortMapping{Protocol: "icmp", HostPort: 1111}, "invalid protocol: icmp"),
+			Entry("port range", netrules.PortMapping{HostPort: 1111, HostPortEnd: 1110}, "invalid port range: 1111-1110"),
+			Entry("shifted port range", netrules.PortMapping{HostPort: 1111, HostPortEnd: 1115, ContainerPort: 2222}, "port range 1111-1115 must be forwarded to the same container ports"),
+		)
+
 		Context("when writing the netin rule fails", func() {
 			BeforeEach(func() {
-				ipTables.BulkAppendReturns(errors.New("blue potato"))
+				ipTables.ApplyTransactionReturns(errors.New("blue potato"))
 			})
 			It("returns an error", func() {
-				err := netIn.AddRule("some-container-handle", 1111, 2222, "1.2.3.4", "5.6.7.8")
-				Expect(err).To(MatchError("appending rule: blue potato"))
+				err := netIn.AddRule("some-container-handle", netrules.PortMapping{HostPort: 1111, ContainerPort: 2222}, "1.2.3.4", "5.6.7.8")
+				Expect(err).To(MatchError("applying rules: blue potato"))
 			})
 		})
 
 		Context("when the host ip is invalid", func() {
 			It("returns an error", func() {
-				err := netIn.AddRule("some-container-handle", 1111, 2222, "banana", "5.6.7.8")
+				err := netIn.AddRule("some-container-handle", netrules.PortMapping{HostPort: 1111, ContainerPort: 2222}, "banana", "5.6.7.8")
 				Expect(err).To(MatchError("invalid ip: banana"))
 			})
 		})
 
 		Context("when the container ip is invalid", func() {
 			It("returns an error", func() {
-				err := netIn.AddRule("some-container-handle", 1111, 2222, "5.6.7.8", "banana")
+				err := netIn.AddRule("some-container-handle", netrules.PortMapping{HostPort: 1111, ContainerPort: 2222}, "5.6.7.8", "banana")
 				Expect(err).To(MatchError("invalid ip: banana"))
 			})
 		})
@@ -6,6 +6,8 @@ import (
 	"strconv"
 
 	"code.cloudfoundry.org/lib/rules"
+
+	multierror "github.com/hashicorp/go-multierror"
 )
 
 const prefixInput = "input"
@@ -47,9 +49,23 @@ type NetOut struct {
 	DNSServers            []string
 	Conn                  OutConn
 	NetOutChain           *NetOutChain
+	// IPSets is optional. When set, Cleanup destroys the ipsets matched by
+	// the rules in the netout chain.
+	IPSets rules.IPSetAdapter
 }
 
 func (m *NetOut) Initialize() error {
+	tx := &rules.RestoreTransaction{}
+	if err := m.StageInitialize(tx); err != nil {
+		return err
+	}
+
+	return applyTransaction(m.IPTables, tx)
+}
+
+// StageInitialize adds the chains, jump rules and default rules created by
+// Initialize to tx without applying them.
+func (m *NetOut) StageInitialize(tx *rules.RestoreTransaction) error {
 	args, err := m.defaultNetOutRules()
 	if err != nil {
 		return err
@@ -70,12 +86,8 @@ func (m *NetOut) Initialize() error {
 		return fmt.Errorf("input rules: %s", err)
 	}
 
-	err = initChains(m.IPTables, args)
-	if err != nil {
-		return err
-	}
-
-	return applyRules(m.IPTables, args)
+	stageChains(tx, args)
+	return nil
 }
 
 func (m *NetOut) BulkInsertRules(ruleSpec []Rule) error {
@@ -98,7 +110,45 @@ func (m *NetOut) Cleanup() error {
 		return err
 	}
 
-	return cleanupChains(args, m.IPTables)
+	var ipSets []string
+	if m.IPSets != nil {
+		// The chain may already be gone, in which case it matches no sets.
+		chainRules, listErr := m.IPTables.List("filter", m.NetOutChain.Name(m.ContainerHandle))
+		if listErr == nil {
+			ipSets = rules.MatchedIPSets(chainRules)
+		}
+	}
+
+	result := cleanupChains(args, m.IPTables)
+
+	if len(ipSets) > 0 {
+		if err := m.IPSets.Destroy(ipSets...); err != nil {
+			result = multierror.Append(result, fmt.Errorf("destroy ipsets: %s", err))
+		}
+	}
+	return result
+}
+
+// Check verifies that the chains, jump rules and default rules created by
+// Initialize are still present. The ASG rules that the policy agent inserts
+// ahead of the default rules are not checked.
+func (m *NetOut) Check() error {
+	args, err := m.defaultNetOutRules()
+	if err != nil {
+		return err
+	}
+
+	args, err = m.appendInputRules(
+		args,
+		m.DNSServers,
+		m.HostTCPServices,
+		m.HostUDPServices,
+	)
+	if err != nil {
+		return fmt.Errorf("input rules: %s", err)
+	}
+
+	return checkChains(m.IPTables, args)
 }
 
 func (m *NetOut) defaultNetOutRules() ([]IpTablesFullChain, error) {
@@ -111,10 +161,9 @@ func (m *NetOut) defaultNetOutRules() ([]IpTablesFullChain, error) {
 			"filter",
 			"INPUT",
 			inputChainName,
-			[]rules.IPTablesRule{{
-				"-s", m.ContainerIP,
-				"--jump", inputChainName,
-			}},
+			[]rules.IPTablesRule{
+				rules.Rule{Source: rules.Match{Value: m.ContainerIP}, Jump: inputChainName}.Args(),
+			},
 			[]rules.IPTablesRule{
 				rules.NewInputRelatedEstablishedRule(),
 				rules.NewInputDefaultRejectRule(),
@@ -131,9 +180,9 @@ func (m *NetOut) defaultNetOutRules() ([]IpTablesFullChain, error) {
 			"filter",
 			"FORWARD",
 			overlayChain,
-			[]rules.IPTablesRule{{
-				"--jump", overlayChain,
-			}},
+			[]rules.IPTablesRule{
+				rules.Rule{Jump: overlayChain}.Args(),
+			},
 			[]rules.IPTablesRule{
 				rules.NewOverlayAllowEgress(m.VTEPName, m.ContainerIP),
 				rules.NewOverlayRelatedEstablishedRule(m.ContainerIP),
@@ -249,6 +298,6 @@ func (m *NetOut) netOutLogChain(forwardChainName, suffix string, logRules []rule
 		return IpTablesFullChain{}, err
 	}
 
-	jumpConditions := []rules.IPTablesRule{{"--jump", logChainName}}
+	jumpConditions := []rules.IPTablesRule{rules.Rule{Jump: logChainName}.Args()}
 	return IpTablesFullChain{"filter", "", logChainName, jumpConditions, logRules}, nil
 }
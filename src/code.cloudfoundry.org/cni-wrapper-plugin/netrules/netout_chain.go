@@ -1,9 +1,11 @@
 package netrules
 
 import (
+	"cmp"
 	"fmt"
 	"math"
 	"net"
+	"slices"
 	"strconv"
 
 	"code.cloudfoundry.org/lib/rules"
@@ -61,8 +63,14 @@ func (c *NetOutChain) IPTablesRules(containerHandle string, containerWorkload st
 		return nil, fmt.Errorf("getting chain name: %s", err)
 	}
 
-	iptablesRules := c.Converter.BulkConvert(ruleSpec, logChain, c.ASGLogging)
-	iptablesRules = c.Converter.DeduplicateRules(iptablesRules)
+	// The rules are returned in the reverse of the order they are matched in,
+	// so lower priorities come first. Each run of equal priority and action
+	// is deduplicated on its own so that no rule moves past a deny.
+	iptablesRules := []rules.IPTablesRule{}
+	for _, run := range byPriority(ruleSpec) {
+		runRules := c.Converter.BulkConvert(run, logChain, c.ASGLogging)
+		iptablesRules = append(iptablesRules, c.Converter.DeduplicateRules(runRules)...)
+	}
 
 	iptablesRules = append(iptablesRules, c.denyNetworksRules(containerWorkload)...)
 
@@ -83,6 +91,40 @@ func (c *NetOutChain) IPTablesRules(containerHandle string, containerWorkload st
 	return iptablesRules, nil
 }
 
+// byPriority sorts the rules by ascending priority, allow before deny, and
+// splits them into runs of equal priority and action.
+func byPriority(ruleSpec []Rule) [][]Rule {
+	sorted := slices.Clone(ruleSpec)
+	slices.SortStableFunc(sorted, compareRules)
+	if len(sorted) == 0 {
+		return [][]Rule{sorted}
+	}
+
+	runs := [][]Rule{}
+	start := 0
+	for i := 1; i <= len(sorted); i++ {
+		if i == len(sorted) || compareRules(sorted[start], sorted[i]) != 0 {
+			runs = append(runs, sorted[start:i])
+			start = i
+		}
+	}
+	return runs
+}
+
+func compareRules(a, b Rule) int {
+	if n := cmp.Compare(a.Priority(), b.Priority()); n != 0 {
+		return n
+	}
+	return cmp.Compare(actionRank(a.Action()), actionRank(b.Action()))
+}
+
+func actionRank(action Action) int {
+	if action == ActionDeny {
+		return 1
+	}
+	return 0
+}
+
 func (c *NetOutChain) denyNetworksRules(containerWorkload string) []rules.IPTablesRule {
 	denyRules := []rules.IPTablesRule{}
 
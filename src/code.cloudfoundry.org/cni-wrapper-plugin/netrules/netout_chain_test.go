@@ -9,6 +9,7 @@ import (
 	"code.cloudfoundry.org/garden"
 
 	"code.cloudfoundry.org/lib/rules"
+	"code.cloudfoundry.org/policy_client"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -43,7 +44,7 @@ var _ = Describe("NetOutChain", func() {
 			ruleSpec := netOutChain.DefaultRules("some-container-handle")
 
 			Expect(ruleSpec).To(Equal([]rules.IPTablesRule{
-				{"--jump", "REJECT", "--reject-with", "icmp-port-unreachable"},
+				{"-j", "REJECT", "--reject-with", "icmp-port-unreachable"},
 			}))
 		})
 
@@ -55,10 +56,13 @@ var _ = Describe("NetOutChain", func() {
 				ruleSpec := netOutChain.DefaultRules("some-container-handle")
 
 				Expect(ruleSpec).To(Equal([]rules.IPTablesRule{
-					{"-m", "limit", "--limit", "3/s", "--limit-burst", "3",
-						"--jump", "LOG", "--log-prefix", `"DENY_some-container-handle "`},
-					{"--jump", "REJECT",
-						"--reject-with", "icmp-port-unreachable"},
+					{
+						"-m", "limit", "--limit", "3/sec", "--limit-burst", "3",
+						"-j", "LOG", "--log-prefix", `"DENY_some-container-handle "`,
+					},
+					{
+						"-j", "REJECT", "--reject-with", "icmp-port-unreachable",
+					},
 				}))
 			})
 		})
@@ -134,6 +138,36 @@ var _ = Describe("NetOutChain", func() {
 			})
 		})
 
+		Context("when rules have priorities and actions", func() {
+			BeforeEach(func() {
+				netOutChain.Converter = &netrules.RuleConverter{}
+			})
+
+			It("returns lower priorities first and, at equal priority, allow rules before deny rules", func() {
+				ruleSpec, err := netrules.NewRulesFromSecurityGroupRules([]policy_client.SecurityGroupRule{
+					{Protocol: "all", Destination: "10.0.0.5", Action: "deny", Priority: 10},
+					{Protocol: "all", Destination: "10.0.0.0-10.0.0.255"},
+					{Protocol: "all", Destination: "10.0.0.7", Action: "deny"},
+					{Protocol: "all", Destination: "10.0.0.6", Action: "allow", Priority: 10},
+					{Protocol: "all", Destination: "10.0.0.0-10.0.0.255", Priority: 20},
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				iptablesRules, err := netOutChain.IPTablesRules("some-container-handle", "app", ruleSpec)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(iptablesRules).To(Equal([]rules.IPTablesRule{
+					{"-m", "iprange", "--dst-range", "10.0.0.0-10.0.0.255", "-j", "ACCEPT"},
+					{"-m", "iprange", "--dst-range", "10.0.0.7-10.0.0.7", "-j", "REJECT", "--reject-with", "icmp-port-unreachable"},
+					{"-m", "iprange", "--dst-range", "10.0.0.6-10.0.0.6", "-j", "ACCEPT"},
+					{"-m", "iprange", "--dst-range", "10.0.0.5-10.0.0.5", "-j", "REJECT", "--reject-with", "icmp-port-unreachable"},
+					{"-m", "iprange", "--dst-range", "10.0.0.0-10.0.0.255", "-j", "ACCEPT"},
+					{"-p", "tcp", "-m", "state", "--state", "INVALID", "-j", "DROP"},
+					{"-m", "state", "--state", "RELATED,ESTABLISHED", "-j", "ACCEPT"},
+				}))
+			})
+		})
+
 		Context("when deny networks are specified", func() {
 			BeforeEach(func() {
 				netOutChain.DenyNetworks = netrules.DenyNetworks{}
@@ -151,8 +185,8 @@ var _ = Describe("NetOutChain", func() {
 					rulesWithDenyNetworksAndDefaults := append(
 						genericRules,
 						[]rules.IPTablesRule{
-							{"-d", "172.16.0.0/12", "--jump", "REJECT", "--reject-with", "icmp-port-unreachable"},
-							{"-d", "192.168.0.0/16", "--jump", "REJECT", "--reject-with", "icmp-port-unreachable"},
+							{"-d", "172.16.0.0/12", "-j", "REJECT", "--reject-with", "icmp-port-unreachable"},
+							{"-d", "192.168.0.0/16", "-j", "REJECT", "--reject-with", "icmp-port-unreachable"},
 							{"-p", "tcp", "-m", "state", "--state", "INVALID", "-j", "DROP"},
 							{"-m", "state", "--state", "RELATED,ESTABLISHED", "-j", "ACCEPT"},
 						}...,
@@ -180,8 +214,8 @@ var _ = Describe("NetOutChain", func() {
 					rulesWithDenyNetworksAndDefaults := append(
 						genericRules,
 						[]rules.IPTablesRule{
-							{"-d", "1.1.1.1/32", "--jump", "REJECT", "--reject-with", "icmp-port-unreachable"},
-							{"-d", expectedDenyNetwork, "--jump", "REJECT", "--reject-with", "icmp-port-unreachable"},
+							{"-d", "1.1.1.1", "-j", "REJECT", "--reject-with", "icmp-port-unreachable"},
+							{"-d", expectedDenyNetwork, "-j", "REJECT", "--reject-with", "icmp-port-unreachable"},
 							{"-p", "tcp", "-m", "state", "--state", "INVALID", "-j", "DROP"},
 							{"-m", "state", "--state", "RELATED,ESTABLISHED", "-j", "ACCEPT"},
 						}...,
@@ -189,9 +223,9 @@ var _ = Describe("NetOutChain", func() {
 
 					Expect(iptablesRules).To(Equal(rulesWithDenyNetworksAndDefaults))
 				},
-				Entry("when the workload is an app", "app", "2.2.2.2/32"),
-				Entry("when the workload is a task", "task", "2.2.2.2/32"),
-				Entry("when the workload is staging", "staging", "3.3.3.3/32"),
+				Entry("when the workload is an app", "app", "2.2.2.2"),
+				Entry("when the workload is a task", "task", "2.2.2.2"),
+				Entry("when the workload is staging", "staging", "3.3.3.3"),
 			)
 		})
 
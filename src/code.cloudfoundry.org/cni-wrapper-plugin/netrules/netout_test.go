@@ -2,8 +2,11 @@ package netrules_test
 
 import (
 	"errors"
+	"net"
+	"strings"
 
 	"code.cloudfoundry.org/cni-wrapper-plugin/fakes"
+	"code.cloudfoundry.org/cni-wrapper-plugin/lib"
 	"code.cloudfoundry.org/cni-wrapper-plugin/netrules"
 	"code.cloudfoundry.org/garden"
 
@@ -54,6 +57,11 @@ var _ = Describe("Netout", func() {
 	})
 
 	Describe("Initialize", func() {
+		appliedTransaction := func() *rules.RestoreTransaction {
+			Expect(ipTables.ApplyTransactionCallCount()).To(Equal(1))
+			return ipTables.ApplyTransactionArgsForCall(0)
+		}
+
 		It("creates the input chain, netout forwarding chain, and the logging chain", func() {
 			err := netOut.Initialize()
 			Expect(err).NotTo(HaveOccurred())
@@ -76,101 +84,95 @@ var _ = Describe("Netout", func() {
 			Expect(body).To(Equal("netout-some-container-handle"))
 			Expect(suffix).To(Equal("log"))
 
-			Expect(ipTables.NewChainCallCount()).To(Equal(4))
-			table, chain := ipTables.NewChainArgsForCall(0)
-			Expect(table).To(Equal("filter"))
-			Expect(chain).To(Equal("input-some-container-handle"))
-			table, chain = ipTables.NewChainArgsForCall(1)
-			Expect(table).To(Equal("filter"))
-			Expect(chain).To(Equal("netout-some-container-handle"))
-			table, chain = ipTables.NewChainArgsForCall(2)
-			Expect(table).To(Equal("filter"))
-			Expect(chain).To(Equal("overlay-some-container-handle"))
-			table, chain = ipTables.NewChainArgsForCall(3)
-			Expect(table).To(Equal("filter"))
-			Expect(chain).To(Equal("some-other-chain-name"))
+			tx := appliedTransaction()
+			Expect(tx.Tables()).To(Equal([]string{"filter"}))
+			Expect(tx.Chains("filter")).To(Equal([]string{
+				"input-some-container-handle",
+				"netout-some-container-handle",
+				"overlay-some-container-handle",
+				"some-other-chain-name",
+			}))
 		})
 
 		It("writes the default netout and logging rules", func() {
 			err := netOut.Initialize()
 			Expect(err).NotTo(HaveOccurred())
 
-			Expect(ipTables.BulkAppendCallCount()).To(Equal(7))
-			table, chain, rulespec := ipTables.BulkAppendArgsForCall(0)
-			Expect(table).To(Equal("filter"))
-			Expect(chain).To(Equal("INPUT"))
-			Expect(rulespec).To(Equal([]rules.IPTablesRule{{"-s", "5.6.7.8", "--jump", "input-some-container-handle"}}))
+			tx := appliedTransaction()
+			Expect(tx.Rules("filter", "INPUT")).To(Equal([]rules.IPTablesRule{{"-s", "5.6.7.8", "-j", "input-some-container-handle"}}))
 
-			table, chain, rulespec = ipTables.BulkAppendArgsForCall(1)
-			Expect(table).To(Equal("filter"))
-			Expect(chain).To(Equal("FORWARD"))
-			Expect(rulespec).To(Equal([]rules.IPTablesRule{
-				{"-s", "5.6.7.8", "-o", "some-device", "--jump", "netout-some-container-handle"},
-				{"-s", "5.6.7.8", "-o", "eth0", "--jump", "netout-some-container-handle"},
+			Expect(tx.Rules("filter", "FORWARD")).To(Equal([]rules.IPTablesRule{
+				{"-s", "5.6.7.8", "-o", "some-device", "-j", "netout-some-container-handle"},
+				{"-s", "5.6.7.8", "-o", "eth0", "-j", "netout-some-container-handle"},
+				{"-j", "overlay-some-container-handle"},
 			}))
 
-			table, chain, rulespec = ipTables.BulkAppendArgsForCall(2)
-			Expect(table).To(Equal("filter"))
-			Expect(chain).To(Equal("FORWARD"))
-			Expect(rulespec).To(Equal([]rules.IPTablesRule{{"--jump", "overlay-some-container-handle"}}))
-
-			table, chain, rulespec = ipTables.BulkAppendArgsForCall(3)
-			Expect(table).To(Equal("filter"))
-			Expect(chain).To(Equal("input-some-container-handle"))
-			Expect(rulespec).To(Equal([]rules.IPTablesRule{
-				{"-m", "state", "--state", "RELATED,ESTABLISHED",
-					"--jump", "ACCEPT"},
-				{"--jump", "REJECT",
-					"--reject-with", "icmp-port-unreachable"},
+			Expect(tx.Rules("filter", "input-some-container-handle")).To(Equal([]rules.IPTablesRule{
+				{
+					"-m", "state", "--state", "RELATED,ESTABLISHED",
+					"-j", "ACCEPT",
+				},
+				{
+					"-j", "REJECT", "--reject-with", "icmp-port-unreachable",
+				},
 			}))
 
-			table, chain, rulespec = ipTables.BulkAppendArgsForCall(4)
-			Expect(table).To(Equal("filter"))
-			Expect(chain).To(Equal("netout-some-container-handle"))
-			Expect(rulespec).To(Equal([]rules.IPTablesRule{
-				{"--jump", "REJECT", "--reject-with", "icmp-port-unreachable"},
+			Expect(tx.Rules("filter", "netout-some-container-handle")).To(Equal([]rules.IPTablesRule{
+				{"-j", "REJECT", "--reject-with", "icmp-port-unreachable"},
 			}))
 
-			table, chain, rulespec = ipTables.BulkAppendArgsForCall(5)
-			Expect(table).To(Equal("filter"))
-			Expect(chain).To(Equal("overlay-some-container-handle"))
-			Expect(rulespec).To(Equal([]rules.IPTablesRule{
-				{"-s", "5.6.7.8",
+			Expect(tx.Rules("filter", "overlay-some-container-handle")).To(Equal([]rules.IPTablesRule{
+				{
+					"-s", "5.6.7.8",
 					"-o", "vtep-name",
-					"-m", "mark", "!", "--mark", "0x0",
-					"--jump", "ACCEPT"},
-				{"-d", "5.6.7.8",
+					"-m", "mark",
+					"!", "--mark", "0x0",
+					"-j", "ACCEPT",
+				},
+				{
+					"-d", "5.6.7.8",
 					"-m", "state", "--state", "RELATED,ESTABLISHED",
-					"--jump", "ACCEPT"},
-				{"-d", "5.6.7.8",
-					"-m", "mark", "--mark", "0xFEEDBEEF",
-					"--jump", "ACCEPT"},
-				{"-d", "5.6.7.8",
-					"--jump", "REJECT",
-					"--reject-with", "icmp-port-unreachable"},
+					"-j", "ACCEPT",
+				},
+				{
+					"-d", "5.6.7.8",
+					"-m", "mark", "--mark", "0xfeedbeef",
+					"-j", "ACCEPT",
+				},
+				{
+					"-d", "5.6.7.8",
+					"-j", "REJECT", "--reject-with", "icmp-port-unreachable",
+				},
 			}))
 
-			table, chain, rulespec = ipTables.BulkAppendArgsForCall(6)
-			Expect(table).To(Equal("filter"))
-			Expect(chain).To(Equal("some-other-chain-name"))
-			Expect(rulespec).To(Equal([]rules.IPTablesRule{
+			Expect(tx.Rules("filter", "some-other-chain-name")).To(Equal([]rules.IPTablesRule{
 				{"!", "-p", "udp",
 					"-m", "conntrack", "--ctstate", "INVALID,NEW,UNTRACKED",
 					"-j", "LOG", "--log-prefix", `"OK_some-container-handle "`},
-				{"-p", "udp",
-					"-m", "limit", "--limit", "6/s", "--limit-burst", "6",
-					"-j", "LOG", "--log-prefix", `"OK_some-container-handle "`},
-				{"--jump", "ACCEPT"},
+				{
+					"-p", "udp",
+					"-m", "limit", "--limit", "6/sec", "--limit-burst", "6",
+					"-j", "LOG", "--log-prefix", `"OK_some-container-handle "`,
+				},
+				{"-j", "ACCEPT"},
 			}))
 		})
 
-		Context("when creating a new chain fails", func() {
+		It("creates every chain before writing the chains' rules", func() {
+			err := netOut.Initialize()
+			Expect(err).NotTo(HaveOccurred())
+
+			payload := appliedTransaction().String()
+			Expect(strings.Index(payload, "-N some-other-chain-name\n")).To(BeNumerically("<", strings.Index(payload, "-A input-some-container-handle ")))
+		})
+
+		Context("when applying the rules fails", func() {
 			BeforeEach(func() {
-				ipTables.NewChainReturns(errors.New("potata"))
+				ipTables.ApplyTransactionReturns(errors.New("potato"))
 			})
 			It("returns the error", func() {
 				err := netOut.Initialize()
-				Expect(err).To(MatchError("creating chain: potata"))
+				Expect(err).To(MatchError("applying rules: potato"))
 			})
 		})
 
@@ -182,30 +184,10 @@ var _ = Describe("Netout", func() {
 				err := netOut.Initialize()
 				Expect(err).To(MatchError("getting chain name: banana"))
 			})
-		})
 
-		Context("when appending a new rule fails", func() {
-			BeforeEach(func() {
-				ipTables.BulkAppendReturns(errors.New("potato"))
-			})
-			It("returns the error", func() {
-				err := netOut.Initialize()
-				Expect(err).To(MatchError("appending rule to chain: potato"))
-			})
-		})
-
-		Context("when writing the netout rule fails", func() {
-			BeforeEach(func() {
-				ipTables.BulkAppendStub = func(table, chain string, rulespec ...rules.IPTablesRule) error {
-					if chain == "INPUT" || chain == "FORWARD" {
-						return nil
-					}
-					return errors.New("potato")
-				}
-			})
-			It("returns the error", func() {
-				err := netOut.Initialize()
-				Expect(err).To(MatchError("appending rule: potato"))
+			It("does not apply any rules", func() {
+				_ = netOut.Initialize()
+				Expect(ipTables.ApplyTransactionCallCount()).To(Equal(0))
 			})
 		})
 
@@ -217,28 +199,34 @@ var _ = Describe("Netout", func() {
 				err := netOut.Initialize()
 				Expect(err).NotTo(HaveOccurred())
 
-				Expect(ipTables.BulkAppendCallCount()).To(Equal(7))
-
-				table, chain, rulespec := ipTables.BulkAppendArgsForCall(5)
-				Expect(table).To(Equal("filter"))
-				Expect(chain).To(Equal("overlay-some-container-handle"))
-				Expect(rulespec).To(Equal([]rules.IPTablesRule{
-					{"-s", "5.6.7.8",
+				tx := appliedTransaction()
+				Expect(tx.Rules("filter", "overlay-some-container-handle")).To(Equal([]rules.IPTablesRule{
+					{
+						"-s", "5.6.7.8",
 						"-o", "vtep-name",
-						"-m", "mark", "!", "--mark", "0x0",
-						"--jump", "ACCEPT"},
-					{"-d", "5.6.7.8",
+						"-m", "mark",
+						"!", "--mark", "0x0",
+						"-j", "ACCEPT",
+					},
+					{
+						"-d", "5.6.7.8",
 						"-m", "state", "--state", "RELATED,ESTABLISHED",
-						"--jump", "ACCEPT"},
-					{"-d", "5.6.7.8",
-						"-m", "mark", "--mark", "0xFEEDBEEF",
-						"--jump", "ACCEPT"},
-					{"-d", "5.6.7.8",
-						"-m", "limit", "--limit", "3/s", "--limit-burst", "3",
-						"--jump", "LOG", "--log-prefix", `"DENY_C2C_some-container-hand "`},
-					{"-d", "5.6.7.8",
-						"--jump", "REJECT",
-						"--reject-with", "icmp-port-unreachable"},
+						"-j", "ACCEPT",
+					},
+					{
+						"-d", "5.6.7.8",
+						"-m", "mark", "--mark", "0xfeedbeef",
+						"-j", "ACCEPT",
+					},
+					{
+						"-d", "5.6.7.8",
+						"-m", "limit", "--limit", "3/sec", "--limit-burst", "3",
+						"-j", "LOG", "--log-prefix", `"DENY_C2C_some-container-hand "`,
+					},
+					{
+						"-d", "5.6.7.8",
+						"-j", "REJECT", "--reject-with", "icmp-port-unreachable",
+					},
 				}))
 			})
 		})
@@ -251,20 +239,16 @@ var _ = Describe("Netout", func() {
 			It("creates rules for the dns servers", func() {
 				err := netOut.Initialize()
 				Expect(err).NotTo(HaveOccurred())
-				Expect(ipTables.BulkAppendCallCount()).To(Equal(7))
+				tx := appliedTransaction()
+				Expect(tx.Rules("filter", "input-some-container-handle")).To(Equal([]rules.IPTablesRule{
+					{"-m", "state", "--state", "RELATED,ESTABLISHED", "-j", "ACCEPT"},
 
-				table, chain, rulespec := ipTables.BulkAppendArgsForCall(3)
-				Expect(table).To(Equal("filter"))
-				Expect(chain).To(Equal("input-some-container-handle"))
-				Expect(rulespec).To(Equal([]rules.IPTablesRule{
-					{"-m", "state", "--state", "RELATED,ESTABLISHED", "--jump", "ACCEPT"},
-
-					{"-p", "tcp", "-d", "8.8.4.4", "--destination-port", "53", "--jump", "ACCEPT"},
-					{"-p", "udp", "-d", "8.8.4.4", "--destination-port", "53", "--jump", "ACCEPT"},
-					{"-p", "tcp", "-d", "1.2.3.4", "--destination-port", "53", "--jump", "ACCEPT"},
-					{"-p", "udp", "-d", "1.2.3.4", "--destination-port", "53", "--jump", "ACCEPT"},
+					{"-d", "8.8.4.4", "-p", "tcp", "-m", "tcp", "--dport", "53", "-j", "ACCEPT"},
+					{"-d", "8.8.4.4", "-p", "udp", "-m", "udp", "--dport", "53", "-j", "ACCEPT"},
+					{"-d", "1.2.3.4", "-p", "tcp", "-m", "tcp", "--dport", "53", "-j", "ACCEPT"},
+					{"-d", "1.2.3.4", "-p", "udp", "-m", "udp", "--dport", "53", "-j", "ACCEPT"},
 
-					{"--jump", "REJECT", "--reject-with", "icmp-port-unreachable"},
+					{"-j", "REJECT", "--reject-with", "icmp-port-unreachable"},
 				}))
 			})
 
@@ -275,23 +259,20 @@ var _ = Describe("Netout", func() {
 				It("creates rules for both dns servers and the host TCP services", func() {
 					err := netOut.Initialize()
 					Expect(err).NotTo(HaveOccurred())
-					Expect(ipTables.BulkAppendCallCount()).To(Equal(7))
+					tx := appliedTransaction()
 
-					table, chain, rulespec := ipTables.BulkAppendArgsForCall(3)
-					Expect(table).To(Equal("filter"))
-					Expect(chain).To(Equal("input-some-container-handle"))
-					Expect(rulespec).To(Equal([]rules.IPTablesRule{
-						{"-m", "state", "--state", "RELATED,ESTABLISHED", "--jump", "ACCEPT"},
+					Expect(tx.Rules("filter", "input-some-container-handle")).To(Equal([]rules.IPTablesRule{
+						{"-m", "state", "--state", "RELATED,ESTABLISHED", "-j", "ACCEPT"},
 
-						{"-p", "tcp", "-d", "8.8.4.4", "--destination-port", "53", "--jump", "ACCEPT"},
-						{"-p", "udp", "-d", "8.8.4.4", "--destination-port", "53", "--jump", "ACCEPT"},
-						{"-p", "tcp", "-d", "1.2.3.4", "--destination-port", "53", "--jump", "ACCEPT"},
-						{"-p", "udp", "-d", "1.2.3.4", "--destination-port", "53", "--jump", "ACCEPT"},
+						{"-d", "8.8.4.4", "-p", "tcp", "-m", "tcp", "--dport", "53", "-j", "ACCEPT"},
+						{"-d", "8.8.4.4", "-p", "udp", "-m", "udp", "--dport", "53", "-j", "ACCEPT"},
+						{"-d", "1.2.3.4", "-p", "tcp", "-m", "tcp", "--dport", "53", "-j", "ACCEPT"},
+						{"-d", "1.2.3.4", "-p", "udp", "-m", "udp", "--dport", "53", "-j", "ACCEPT"},
 
-						{"-p", "tcp", "-d", "169.125.0.4", "--destination-port", "9001", "--jump", "ACCEPT"},
-						{"-p", "tcp", "-d", "169.125.0.9", "--destination-port", "8080", "--jump", "ACCEPT"},
+						{"-d", "169.125.0.4", "-p", "tcp", "-m", "tcp", "--dport", "9001", "-j", "ACCEPT"},
+						{"-d", "169.125.0.9", "-p", "tcp", "-m", "tcp", "--dport", "8080", "-j", "ACCEPT"},
 
-						{"--jump", "REJECT", "--reject-with", "icmp-port-unreachable"},
+						{"-j", "REJECT", "--reject-with", "icmp-port-unreachable"},
 					}))
 				})
 			})
@@ -303,23 +284,20 @@ var _ = Describe("Netout", func() {
 				It("creates rules for both dns servers and the host UDP services", func() {
 					err := netOut.Initialize()
 					Expect(err).NotTo(HaveOccurred())
-					Expect(ipTables.BulkAppendCallCount()).To(Equal(7))
+					tx := appliedTransaction()
 
-					table, chain, rulespec := ipTables.BulkAppendArgsForCall(3)
-					Expect(table).To(Equal("filter"))
-					Expect(chain).To(Equal("input-some-container-handle"))
-					Expect(rulespec).To(Equal([]rules.IPTablesRule{
-						{"-m", "state", "--state", "RELATED,ESTABLISHED", "--jump", "ACCEPT"},
+					Expect(tx.Rules("filter", "input-some-container-handle")).To(Equal([]rules.IPTablesRule{
+						{"-m", "state", "--state", "RELATED,ESTABLISHED", "-j", "ACCEPT"},
 
-						{"-p", "tcp", "-d", "8.8.4.4", "--destination-port", "53", "--jump", "ACCEPT"},
-						{"-p", "udp", "-d", "8.8.4.4", "--destination-port", "53", "--jump", "ACCEPT"},
-						{"-p", "tcp", "-d", "1.2.3.4", "--destination-port", "53", "--jump", "ACCEPT"},
-						{"-p", "udp", "-d", "1.2.3.4", "--destination-port", "53", "--jump", "ACCEPT"},
+						{"-d", "8.8.4.4", "-p", "tcp", "-m", "tcp", "--dport", "53", "-j", "ACCEPT"},
+						{"-d", "8.8.4.4", "-p", "udp", "-m", "udp", "--dport", "53", "-j", "ACCEPT"},
+						{"-d", "1.2.3.4", "-p", "tcp", "-m", "tcp", "--dport", "53", "-j", "ACCEPT"},
+						{"-d", "1.2.3.4", "-p", "udp", "-m", "udp", "--dport", "53", "-j", "ACCEPT"},
 
-						{"-p", "udp", "-d", "169.125.0.4", "--destination-port", "9001", "--jump", "ACCEPT"},
-						{"-p", "udp", "-d", "169.125.0.9", "--destination-port", "8080", "--jump", "ACCEPT"},
+						{"-d", "169.125.0.4", "-p", "udp", "-m", "udp", "--dport", "9001", "-j", "ACCEPT"},
+						{"-d", "169.125.0.9", "-p", "udp", "-m", "udp", "--dport", "8080", "-j", "ACCEPT"},
 
-						{"--jump", "REJECT", "--reject-with", "icmp-port-unreachable"},
+						{"-j", "REJECT", "--reject-with", "icmp-port-unreachable"},
 					}))
 				})
 			})
@@ -332,26 +310,23 @@ var _ = Describe("Netout", func() {
 				It("creates rules for dns servers, the host TCP services, and the host UDP services", func() {
 					err := netOut.Initialize()
 					Expect(err).NotTo(HaveOccurred())
-					Expect(ipTables.BulkAppendCallCount()).To(Equal(7))
+					tx := appliedTransaction()
 
-					table, chain, rulespec := ipTables.BulkAppendArgsForCall(3)
-					Expect(table).To(Equal("filter"))
-					Expect(chain).To(Equal("input-some-container-handle"))
-					Expect(rulespec).To(Equal([]rules.IPTablesRule{
-						{"-m", "state", "--state", "RELATED,ESTABLISHED", "--jump", "ACCEPT"},
+					Expect(tx.Rules("filter", "input-some-container-handle")).To(Equal([]rules.IPTablesRule{
+						{"-m", "state", "--state", "RELATED,ESTABLISHED", "-j", "ACCEPT"},
 
-						{"-p", "tcp", "-d", "8.8.4.4", "--destination-port", "53", "--jump", "ACCEPT"},
-						{"-p", "udp", "-d", "8.8.4.4", "--destination-port", "53", "--jump", "ACCEPT"},
-						{"-p", "tcp", "-d", "1.2.3.4", "--destination-port", "53", "--jump", "ACCEPT"},
-						{"-p", "udp", "-d", "1.2.3.4", "--destination-port", "53", "--jump", "ACCEPT"},
+						{"-d", "8.8.4.4", "-p", "tcp", "-m", "tcp", "--dport", "53", "-j", "ACCEPT"},
+						{"-d", "8.8.4.4", "-p", "udp", "-m", "udp", "--dport", "53", "-j", "ACCEPT"},
+						{"-d", "1.2.3.4", "-p", "tcp", "-m", "tcp", "--dport", "53", "-j", "ACCEPT"},
+						{"-d", "1.2.3.4", "-p", "udp", "-m", "udp", "--dport", "53", "-j", "ACCEPT"},
 
-						{"-p", "tcp", "-d", "169.125.0.4", "--destination-port", "9001", "--jump", "ACCEPT"},
-						{"-p", "tcp", "-d", "169.125.0.9", "--destination-port", "8080", "--jump", "ACCEPT"},
+						{"-d", "169.125.0.4", "-p", "tcp", "-m", "tcp", "--dport", "9001", "-j", "ACCEPT"},
+						{"-d", "169.125.0.9", "-p", "tcp", "-m", "tcp", "--dport", "8080", "-j", "ACCEPT"},
 
-						{"-p", "udp", "-d", "169.251.0.4", "--destination-port", "9001", "--jump", "ACCEPT"},
-						{"-p", "udp", "-d", "169.251.0.9", "--destination-port", "8080", "--jump", "ACCEPT"},
+						{"-d", "169.251.0.4", "-p", "udp", "-m", "udp", "--dport", "9001", "-j", "ACCEPT"},
+						{"-d", "169.251.0.9", "-p", "udp", "-m", "udp", "--dport", "8080", "-j", "ACCEPT"},
 
-						{"--jump", "REJECT", "--reject-with", "icmp-port-unreachable"},
+						{"-j", "REJECT", "--reject-with", "icmp-port-unreachable"},
 					}))
 				})
 			})
@@ -365,18 +340,14 @@ var _ = Describe("Netout", func() {
 			It("creates rules for the host TCP services", func() {
 				err := netOut.Initialize()
 				Expect(err).NotTo(HaveOccurred())
-				Expect(ipTables.BulkAppendCallCount()).To(Equal(7))
+				tx := appliedTransaction()
+				Expect(tx.Rules("filter", "input-some-container-handle")).To(Equal([]rules.IPTablesRule{
+					{"-m", "state", "--state", "RELATED,ESTABLISHED", "-j", "ACCEPT"},
 
-				table, chain, rulespec := ipTables.BulkAppendArgsForCall(3)
-				Expect(table).To(Equal("filter"))
-				Expect(chain).To(Equal("input-some-container-handle"))
-				Expect(rulespec).To(Equal([]rules.IPTablesRule{
-					{"-m", "state", "--state", "RELATED,ESTABLISHED", "--jump", "ACCEPT"},
-
-					{"-p", "tcp", "-d", "169.125.0.4", "--destination-port", "9001", "--jump", "ACCEPT"},
-					{"-p", "tcp", "-d", "169.125.0.9", "--destination-port", "8080", "--jump", "ACCEPT"},
+					{"-d", "169.125.0.4", "-p", "tcp", "-m", "tcp", "--dport", "9001", "-j", "ACCEPT"},
+					{"-d", "169.125.0.9", "-p", "tcp", "-m", "tcp", "--dport", "8080", "-j", "ACCEPT"},
 
-					{"--jump", "REJECT", "--reject-with", "icmp-port-unreachable"},
+					{"-j", "REJECT", "--reject-with", "icmp-port-unreachable"},
 				}))
 			})
 
@@ -399,18 +370,14 @@ var _ = Describe("Netout", func() {
 			It("creates rules for the host UDP services", func() {
 				err := netOut.Initialize()
 				Expect(err).NotTo(HaveOccurred())
-				Expect(ipTables.BulkAppendCallCount()).To(Equal(7))
-
-				table, chain, rulespec := ipTables.BulkAppendArgsForCall(3)
-				Expect(table).To(Equal("filter"))
-				Expect(chain).To(Equal("input-some-container-handle"))
-				Expect(rulespec).To(Equal([]rules.IPTablesRule{
-					{"-m", "state", "--state", "RELATED,ESTABLISHED", "--jump", "ACCEPT"},
+				tx := appliedTransaction()
+				Expect(tx.Rules("filter", "input-some-container-handle")).To(Equal([]rules.IPTablesRule{
+					{"-m", "state", "--state", "RELATED,ESTABLISHED", "-j", "ACCEPT"},
 
-					{"-p", "udp", "-d", "169.125.0.4", "--destination-port", "9001", "--jump", "ACCEPT"},
-					{"-p", "udp", "-d", "169.125.0.9", "--destination-port", "8080", "--jump", "ACCEPT"},
+					{"-d", "169.125.0.4", "-p", "udp", "-m", "udp", "--dport", "9001", "-j", "ACCEPT"},
+					{"-d", "169.125.0.9", "-p", "udp", "-m", "udp", "--dport", "8080", "-j", "ACCEPT"},
 
-					{"--jump", "REJECT", "--reject-with", "icmp-port-unreachable"},
+					{"-j", "REJECT", "--reject-with", "icmp-port-unreachable"},
 				}))
 			})
 
@@ -437,7 +404,160 @@ var _ = Describe("Netout", func() {
 			Expect(table).To(Equal("filter"))
 			Expect(chain).To(Equal("netout-some-container-handle"))
 			Expect(index).To(Equal(1))
-			Expect(iptablesRules).To(ContainElement(rules.IPTablesRule{"-d", "10.0.5.0/24", "--jump", "REJECT", "--reject-with", "icmp-port-unreachable"}))
+			Expect(iptablesRules).To(ContainElement(rules.IPTablesRule{"-d", "10.0.5.0/24", "-j", "REJECT", "--reject-with", "icmp-port-unreachable"}))
+		})
+
+		Context("when applying the rules fails after ipsets were created", func() {
+			var (
+				ipSets   *lib_fakes.IPSetAdapter
+				rollback *lib.Rollback
+			)
+
+			BeforeEach(func() {
+				ipSets = &lib_fakes.IPSetAdapter{}
+				rollback = &lib.Rollback{}
+				netOut.NetOutChain.Converter = &netrules.RuleConverter{
+					IPSets: &lib.RollbackIPSets{IPSetAdapter: ipSets, Rollback: rollback},
+				}
+				ipTables.BulkInsertReturns(errors.New("banana"))
+			})
+
+			It("destroys the ipsets when the ADD is rolled back", func() {
+				err := netOut.BulkInsertRules(netrules.NewRulesFromGardenNetOutRules([]garden.NetOutRule{{
+					Protocol: garden.ProtocolTCP,
+					Networks: []garden.IPRange{garden.IPRangeFromIP(net.ParseIP("1.1.1.1")), garden.IPRangeFromIP(net.ParseIP("2.2.2.2"))},
+					Ports:    []garden.PortRange{garden.PortRangeFromPort(443)},
+				}}))
+				Expect(err).To(MatchError(ContainSubstring("banana")))
+				Expect(ipSets.EnsureCallCount()).To(Equal(1))
+				set := ipSets.EnsureArgsForCall(0)
+
+				rollback.Fail(err)
+				Expect(ipSets.DestroyCallCount()).To(Equal(1))
+				Expect(ipSets.DestroyArgsForCall(0)).To(Equal([]string{set.Name}))
+			})
+		})
+	})
+
+	Describe("Check", func() {
+		BeforeEach(func() {
+			ipTables.ChainExistsReturns(true, nil)
+			ipTables.ExistsReturns(true, nil)
+		})
+
+		It("checks that every chain and jump rule exists", func() {
+			err := netOut.Check()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(ipTables.ChainExistsCallCount()).To(Equal(4))
+			table, chain := ipTables.ChainExistsArgsForCall(0)
+			Expect(table).To(Equal("filter"))
+			Expect(chain).To(Equal("input-some-container-handle"))
+
+			table, chain = ipTables.ChainExistsArgsForCall(3)
+			Expect(table).To(Equal("filter"))
+			Expect(chain).To(Equal("some-other-chain-name"))
+
+			table, chain, rule := ipTables.ExistsArgsForCall(0)
+			Expect(table).To(Equal("filter"))
+			Expect(chain).To(Equal("INPUT"))
+			Expect(rule).To(Equal(rules.IPTablesRule{"-s", "5.6.7.8", "-j", "input-some-container-handle"}))
+
+			table, chain, rule = ipTables.ExistsArgsForCall(2)
+			Expect(table).To(Equal("filter"))
+			Expect(chain).To(Equal("FORWARD"))
+			Expect(rule).To(Equal(rules.IPTablesRule{"-s", "5.6.7.8", "-o", "eth0", "-j", "netout-some-container-handle"}))
+		})
+
+		It("checks the default rules of every chain", func() {
+			err := netOut.Check()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(ipTables.ExistsCallCount()).To(Equal(14))
+			var checked []string
+			for i := 4; i < ipTables.ExistsCallCount(); i++ {
+				_, chain, rule := ipTables.ExistsArgsForCall(i)
+				checked = append(checked, chain+" "+strings.Join(rule, " "))
+			}
+			Expect(checked).To(ContainElements(
+				"input-some-container-handle -m state --state RELATED,ESTABLISHED -j ACCEPT",
+				"input-some-container-handle -j REJECT --reject-with icmp-port-unreachable",
+				"netout-some-container-handle -j REJECT --reject-with icmp-port-unreachable",
+				"overlay-some-container-handle -s 5.6.7.8 -o vtep-name -m mark ! --mark 0x0 -j ACCEPT",
+				"overlay-some-container-handle -d 5.6.7.8 -m mark --mark 0xfeedbeef -j ACCEPT",
+				"overlay-some-container-handle -d 5.6.7.8 -j REJECT --reject-with icmp-port-unreachable",
+			))
+		})
+
+		Context("when the container has dns servers and host services", func() {
+			BeforeEach(func() {
+				netOut.DNSServers = []string{"169.254.0.2"}
+				netOut.HostTCPServices = []string{"169.254.0.3:9001"}
+			})
+
+			It("checks their input rules", func() {
+				err := netOut.Check()
+				Expect(err).NotTo(HaveOccurred())
+
+				var checked []string
+				for i := 0; i < ipTables.ExistsCallCount(); i++ {
+					_, chain, rule := ipTables.ExistsArgsForCall(i)
+					checked = append(checked, chain+" "+strings.Join(rule, " "))
+				}
+				Expect(checked).To(ContainElements(
+					"input-some-container-handle -d 169.254.0.2 -p tcp -m tcp --dport 53 -j ACCEPT",
+					"input-some-container-handle -d 169.254.0.2 -p udp -m udp --dport 53 -j ACCEPT",
+					"input-some-container-handle -d 169.254.0.3 -p tcp -m tcp --dport 9001 -j ACCEPT",
+				))
+			})
+		})
+
+		Context("when a default rule is missing", func() {
+			BeforeEach(func() {
+				ipTables.ExistsStub = func(table, chain string, rule rules.IPTablesRule) (bool, error) {
+					return chain != "overlay-some-container-handle", nil
+				}
+			})
+
+			It("returns an error naming the rule", func() {
+				err := netOut.Check()
+				Expect(err).To(MatchError("missing rule [-s 5.6.7.8 -o vtep-name -m mark ! --mark 0x0 -j ACCEPT] in filter/overlay-some-container-handle"))
+			})
+		})
+
+		Context("when a chain is missing", func() {
+			BeforeEach(func() {
+				ipTables.ChainExistsStub = func(table, chain string) (bool, error) {
+					return chain != "overlay-some-container-handle", nil
+				}
+			})
+
+			It("returns an error naming the chain", func() {
+				err := netOut.Check()
+				Expect(err).To(MatchError("missing chain overlay-some-container-handle in table filter"))
+			})
+		})
+
+		Context("when a jump rule is missing", func() {
+			BeforeEach(func() {
+				ipTables.ExistsReturnsOnCall(1, false, nil)
+			})
+
+			It("returns an error naming the rule", func() {
+				err := netOut.Check()
+				Expect(err).To(MatchError("missing jump rule [-s 5.6.7.8 -o some-device -j netout-some-container-handle] in filter/FORWARD"))
+			})
+		})
+
+		Context("when checking a chain fails", func() {
+			BeforeEach(func() {
+				ipTables.ChainExistsReturns(false, errors.New("potato"))
+			})
+
+			It("returns the error", func() {
+				err := netOut.Check()
+				Expect(err).To(MatchError("checking chain input-some-container-handle: potato"))
+			})
 		})
 	})
 
@@ -468,22 +588,22 @@ var _ = Describe("Netout", func() {
 			table, chain, extraArgs := ipTables.DeleteArgsForCall(0)
 			Expect(table).To(Equal("filter"))
 			Expect(chain).To(Equal("INPUT"))
-			Expect(extraArgs).To(Equal(rules.IPTablesRule{"-s", "5.6.7.8", "--jump", "input-some-container-handle"}))
+			Expect(extraArgs).To(Equal(rules.IPTablesRule{"-s", "5.6.7.8", "-j", "input-some-container-handle"}))
 
 			table, chain, extraArgs = ipTables.DeleteArgsForCall(1)
 			Expect(table).To(Equal("filter"))
 			Expect(chain).To(Equal("FORWARD"))
-			Expect(extraArgs).To(Equal(rules.IPTablesRule{"-s", "5.6.7.8", "-o", "some-device", "--jump", "netout-some-container-handle"}))
+			Expect(extraArgs).To(Equal(rules.IPTablesRule{"-s", "5.6.7.8", "-o", "some-device", "-j", "netout-some-container-handle"}))
 
 			table, chain, extraArgs = ipTables.DeleteArgsForCall(2)
 			Expect(table).To(Equal("filter"))
 			Expect(chain).To(Equal("FORWARD"))
-			Expect(extraArgs).To(Equal(rules.IPTablesRule{"-s", "5.6.7.8", "-o", "eth0", "--jump", "netout-some-container-handle"}))
+			Expect(extraArgs).To(Equal(rules.IPTablesRule{"-s", "5.6.7.8", "-o", "eth0", "-j", "netout-some-container-handle"}))
 
 			table, chain, extraArgs = ipTables.DeleteArgsForCall(3)
 			Expect(table).To(Equal("filter"))
 			Expect(chain).To(Equal("FORWARD"))
-			Expect(extraArgs).To(Equal(rules.IPTablesRule{"--jump", "overlay-some-container-handle"}))
+			Expect(extraArgs).To(Equal(rules.IPTablesRule{"-j", "overlay-some-container-handle"}))
 		})
 
 		It("clears the container chain", func() {
@@ -586,6 +706,59 @@ var _ = Describe("Netout", func() {
 			})
 		})
 
+		Context("when IPSets is set", func() {
+			var ipSets *lib_fakes.IPSetAdapter
+
+			BeforeEach(func() {
+				ipSets = &lib_fakes.IPSetAdapter{}
+				netOut.IPSets = ipSets
+				ipTables.ListReturns([]string{
+					"-N netout-some-container-handle",
+					"-A netout-some-container-handle -m set --match-set silk-abc dst -p tcp -m tcp --dport 443 -j ACCEPT",
+					"-A netout-some-container-handle -m set --match-set silk-abc dst -j ACCEPT",
+					"-A netout-some-container-handle -m set --match-set silk-def dst -j ACCEPT",
+					"-A netout-some-container-handle -m set --match-set other-set dst -j ACCEPT",
+				}, nil)
+			})
+
+			It("destroys the silk ipsets matched by the container chain", func() {
+				err := netOut.Cleanup()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(ipTables.ListCallCount()).To(Equal(1))
+				table, chain := ipTables.ListArgsForCall(0)
+				Expect(table).To(Equal("filter"))
+				Expect(chain).To(Equal("netout-some-container-handle"))
+
+				Expect(ipSets.DestroyCallCount()).To(Equal(1))
+				Expect(ipSets.DestroyArgsForCall(0)).To(Equal([]string{"silk-abc", "silk-def"}))
+			})
+
+			Context("when listing the chain fails", func() {
+				BeforeEach(func() {
+					ipTables.ListReturns(nil, errors.New("no chain"))
+				})
+
+				It("still cleans up the chains and destroys no ipsets", func() {
+					err := netOut.Cleanup()
+					Expect(err).NotTo(HaveOccurred())
+					Expect(ipTables.DeleteChainCallCount()).To(Equal(4))
+					Expect(ipSets.DestroyCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("when destroying the ipsets fails", func() {
+				BeforeEach(func() {
+					ipSets.DestroyReturns(errors.New("russet potato"))
+				})
+
+				It("returns an error", func() {
+					err := netOut.Cleanup()
+					Expect(err).To(MatchError(ContainSubstring("destroy ipsets: russet potato")))
+				})
+			})
+		})
+
 		Context("when outbound container connection limiting is enabled", func() {
 			BeforeEach(func() {
 				netOut.Conn.Limit = true
@@ -36,20 +36,49 @@ type ICMPInfo struct {
 	Code garden.ICMPCode
 }
 
+// Action is what happens to the traffic a rule matches.
+type Action string
+
+const (
+	ActionAllow = Action("allow")
+	ActionDeny  = Action("deny")
+)
+
 type Rule interface {
+	Action() Action
+	// Priority orders rules in the chain. Rules with a higher priority are
+	// matched first and, at equal priority, deny rules before allow rules.
+	Priority() int
 	Log() bool
 	Protocol() Protocol
 	Networks() []IPRange
+	FQDNs() []string
 	Ports() []PortRange
 	ICMPInfo() *ICMPInfo
 }
 
+//go:generate counterfeiter -o ../fakes/fqdn_sets.go --fake-name FQDNSets . FQDNSets
+type FQDNSets interface {
+	// Ensure returns the name of the ipset that holds the addresses the DNS
+	// name resolves to.
+	Ensure(fqdn string) (string, error)
+}
+
 type RuleConverter struct {
 	Logger    lager.Logger // used by vxlan-policy-agent
 	LogWriter io.Writer    // used by cni-wrapper-plugin
+	// IPSets is optional. When set, BulkConvert matches destinations sharing
+	// protocol, ports and logging with one ipset instead of a rule per network.
+	IPSets rules.IPSetAdapter
+	// FQDNs is optional. Without it, DNS name destinations are skipped.
+	FQDNs FQDNSets
 }
 
 func (c *RuleConverter) BulkConvert(ruleSpec []Rule, logChainName string, globalLogging bool) []rules.IPTablesRule {
+	if c.IPSets != nil {
+		return c.bulkConvertWithIPSets(ruleSpec, logChainName, globalLogging)
+	}
+
 	iptablesRules := []rules.IPTablesRule{}
 	for _, rule := range ruleSpec {
 		iptablesRules = append(iptablesRules, c.Convert(rule, logChainName, globalLogging)...)
@@ -78,7 +107,7 @@ func (c *RuleConverter) Convert(rule Rule, logChainName string, globalLogging bo
 	for _, network := range rule.Networks() {
 		startIP, endIP := network.Start.String(), network.End.String()
 		protocol := rule.Protocol()
-		log := rule.Log() || globalLogging
+		log := logsAccepted(rule, globalLogging)
 		ports := rule.Ports()
 		switch protocol {
 		case ProtocolTCP:
@@ -124,7 +153,225 @@ func (c *RuleConverter) Convert(rule Rule, logChainName string, globalLogging bo
 			}
 		}
 	}
-	return ruleSpec
+	return append(rejectDenied(rule, ruleSpec), c.convertFQDNs(rule, logChainName, globalLogging)...)
+}
+
+// logsAccepted reports whether traffic the rule accepts goes through the log
+// chain. Deny rules reject without logging.
+func logsAccepted(rule Rule, globalLogging bool) bool {
+	return rule.Action() != ActionDeny && (rule.Log() || globalLogging)
+}
+
+// rejectDenied makes the converted rules of a deny rule reject the traffic
+// they match.
+func rejectDenied(rule Rule, iptablesRules []rules.IPTablesRule) []rules.IPTablesRule {
+	if rule.Action() != ActionDeny {
+		return iptablesRules
+	}
+	rejected := make([]rules.IPTablesRule, len(iptablesRules))
+	for i, iptablesRule := range iptablesRules {
+		rejected[i] = rules.Reject(iptablesRule)
+	}
+	return rejected
+}
+
+// convertFQDNs matches each of the rule's DNS names with the ipset that
+// holds the addresses it resolves to.
+func (c *RuleConverter) convertFQDNs(rule Rule, logChainName string, globalLogging bool) []rules.IPTablesRule {
+	fqdns := rule.FQDNs()
+	if len(fqdns) == 0 {
+		return nil
+	}
+	if c.FQDNs == nil {
+		c.log("invalid-rule", "DNS name destinations are not enabled: %+v\n", rule)
+		return nil
+	}
+
+	groups, ok := splitIntoGroups(rule, logsAccepted(rule, globalLogging))
+	if !ok {
+		c.log("invalid-rule", "rule with DNS name destinations is invalid: %+v\n", rule)
+		return nil
+	}
+
+	ruleSpec := []rules.IPTablesRule{}
+	for _, fqdn := range fqdns {
+		setName, err := c.FQDNs.Ensure(fqdn)
+		if err != nil {
+			c.log("ensure-fqdn-ipset", "creating ipset for %s: %s\n", fqdn, err)
+			continue
+		}
+		for _, group := range groups {
+			ruleSpec = append(ruleSpec, c.setRule(group, setName, logChainName))
+		}
+	}
+	return rejectDenied(rule, ruleSpec)
+}
+
+// destinationGroup is the part of one or more rules with a single port range
+// or ICMP type, whose networks can be matched by one ipset.
+type destinationGroup struct {
+	action   Action
+	priority int
+	log      bool
+	protocol Protocol
+	ports    []PortRange
+	icmpInfo *ICMPInfo
+	networks []IPRange
+}
+
+func (g *destinationGroup) Action() Action      { return g.action }
+func (g *destinationGroup) Priority() int       { return g.priority }
+func (g *destinationGroup) Log() bool           { return g.log }
+func (g *destinationGroup) Protocol() Protocol  { return g.protocol }
+func (g *destinationGroup) Networks() []IPRange { return g.networks }
+func (g *destinationGroup) FQDNs() []string     { return nil }
+func (g *destinationGroup) Ports() []PortRange  { return g.ports }
+func (g *destinationGroup) ICMPInfo() *ICMPInfo { return g.icmpInfo }
+
+func (c *RuleConverter) bulkConvertWithIPSets(ruleSpec []Rule, logChainName string, globalLogging bool) []rules.IPTablesRule {
+	iptablesRules := []rules.IPTablesRule{}
+	groupsByKey := map[string]*destinationGroup{}
+	groups := []*destinationGroup{}
+
+	for _, rule := range ruleSpec {
+		parts, ok := splitIntoGroups(rule, logsAccepted(rule, globalLogging))
+		if !ok {
+			// Convert logs invalid rules and skips them.
+			iptablesRules = append(iptablesRules, c.Convert(rule, logChainName, globalLogging)...)
+			continue
+		}
+
+		iptablesRules = append(iptablesRules, c.convertFQDNs(rule, logChainName, globalLogging)...)
+		for _, part := range parts {
+			key := fmt.Sprintf("%s %d %s %t %v %v", part.action, part.priority, part.protocol, part.log, part.ports, part.icmpInfo)
+			group, ok := groupsByKey[key]
+			if !ok {
+				group = part
+				groupsByKey[key] = group
+				groups = append(groups, group)
+				continue
+			}
+			group.networks = append(group.networks, part.networks...)
+		}
+	}
+
+	for _, group := range groups {
+		iptablesRules = append(iptablesRules, c.convertGroup(group, logChainName)...)
+	}
+	return iptablesRules
+}
+
+func splitIntoGroups(rule Rule, log bool) ([]*destinationGroup, bool) {
+	protocol := rule.Protocol()
+	ports := rule.Ports()
+
+	switch protocol {
+	case ProtocolTCP, ProtocolUDP:
+		if len(ports) == 0 {
+			return nil, false
+		}
+		groups := []*destinationGroup{}
+		for _, portRange := range ports {
+			groups = append(groups, &destinationGroup{
+				action:   rule.Action(),
+				priority: rule.Priority(),
+				log:      log,
+				protocol: protocol,
+				ports:    []PortRange{portRange},
+				networks: append([]IPRange{}, rule.Networks()...),
+			})
+		}
+		return groups, true
+	case ProtocolICMP:
+		icmpInfo := rule.ICMPInfo()
+		if icmpInfo == nil || len(ports) > 0 {
+			return nil, false
+		}
+		return []*destinationGroup{{
+			action:   rule.Action(),
+			priority: rule.Priority(),
+			log:      log,
+			protocol: protocol,
+			icmpInfo: &ICMPInfo{Type: icmpInfo.Type, Code: icmpInfo.Code},
+			networks: append([]IPRange{}, rule.Networks()...),
+		}}, true
+	case ProtocolAll:
+		if len(ports) > 0 {
+			return nil, false
+		}
+		return []*destinationGroup{{
+			action:   rule.Action(),
+			priority: rule.Priority(),
+			log:      log,
+			protocol: protocol,
+			networks: append([]IPRange{}, rule.Networks()...),
+		}}, true
+	}
+	return nil, false
+}
+
+// convertGroup matches the group's networks with an ipset. Networks that
+// hash:net cannot hold stay as per-network rules, as does the whole group
+// when it is too small for a set or the set cannot be created.
+func (c *RuleConverter) convertGroup(group *destinationGroup, logChainName string) []rules.IPTablesRule {
+	var members []string
+	var unsetNetworks []IPRange
+	for _, network := range group.networks {
+		if coversAllAddresses(network) {
+			unsetNetworks = append(unsetNetworks, network)
+			continue
+		}
+		if network.Start.Equal(network.End) {
+			members = append(members, network.Start.String())
+		} else {
+			members = append(members, fmt.Sprintf("%s-%s", network.Start, network.End))
+		}
+	}
+
+	if len(members) < 2 {
+		return c.Convert(group, logChainName, false)
+	}
+
+	set := rules.NewIPSet(members)
+	if err := c.IPSets.Ensure(set); err != nil {
+		c.log("ensure-ipset", "creating ipset %s: %s\n", set.Name, err)
+		return c.Convert(group, logChainName, false)
+	}
+
+	iptablesRules := rejectDenied(group, []rules.IPTablesRule{c.setRule(group, set.Name, logChainName)})
+	if len(unsetNetworks) > 0 {
+		rest := *group
+		rest.networks = unsetNetworks
+		iptablesRules = append(iptablesRules, c.Convert(&rest, logChainName, false)...)
+	}
+	return iptablesRules
+}
+
+func (c *RuleConverter) setRule(group *destinationGroup, setName, logChainName string) rules.IPTablesRule {
+	switch group.protocol {
+	case ProtocolTCP, ProtocolUDP:
+		startPort, endPort := int(group.ports[0].Start), int(group.ports[0].End)
+		if group.log {
+			return rules.NewNetOutSetWithPortsLogRule(setName, startPort, endPort, string(group.protocol), logChainName)
+		}
+		return rules.NewNetOutSetWithPortsRule(setName, startPort, endPort, string(group.protocol))
+	case ProtocolICMP:
+		if group.log {
+			return rules.NewNetOutSetICMPLogRule(setName, group.icmpInfo.Type, group.icmpInfo.Code, logChainName)
+		}
+		return rules.NewNetOutSetICMPRule(setName, group.icmpInfo.Type, group.icmpInfo.Code)
+	default:
+		if group.log {
+			return rules.NewNetOutSetLogRule(setName, logChainName)
+		}
+		return rules.NewNetOutSetRule(setName)
+	}
+}
+
+// coversAllAddresses reports whether the network is 0.0.0.0/0, which a
+// hash:net set cannot hold.
+func coversAllAddresses(network IPRange) bool {
+	return network.Start.Equal(net.IPv4zero) && network.End.Equal(net.IPv4bcast)
 }
 
 func (c *RuleConverter) log(component, message string, args ...interface{}) {
@@ -2,10 +2,14 @@ package netrules_test
 
 import (
 	"bytes"
+	"errors"
 	"net"
 
+	"code.cloudfoundry.org/cni-wrapper-plugin/fakes"
 	"code.cloudfoundry.org/cni-wrapper-plugin/netrules"
+	lib_fakes "code.cloudfoundry.org/lib/fakes"
 	"code.cloudfoundry.org/lib/rules"
+	"code.cloudfoundry.org/policy_client"
 
 	"code.cloudfoundry.org/garden"
 
@@ -45,22 +49,30 @@ var _ = Describe("RuleConverter", func() {
 			It("converts a netout rule to a list of iptables rules", func() {
 				ruleSpec := converter.Convert(netrules.NewRuleFromGardenNetOutRule(netOutRule), logChainName, false)
 				Expect(ruleSpec).To(ConsistOf(
-					rules.IPTablesRule{"-m", "iprange", "-p", "tcp",
-						"--dst-range", "1.1.1.1-2.2.2.2",
-						"-m", "tcp", "--destination-port", "9000:9999",
-						"--jump", "ACCEPT"},
-					rules.IPTablesRule{"-m", "iprange", "-p", "tcp",
-						"--dst-range", "1.1.1.1-2.2.2.2",
-						"-m", "tcp", "--destination-port", "1111:2222",
-						"--jump", "ACCEPT"},
-					rules.IPTablesRule{"-m", "iprange", "-p", "tcp",
-						"--dst-range", "3.3.3.3-4.4.4.4",
-						"-m", "tcp", "--destination-port", "9000:9999",
-						"--jump", "ACCEPT"},
-					rules.IPTablesRule{"-m", "iprange", "-p", "tcp",
-						"--dst-range", "3.3.3.3-4.4.4.4",
-						"-m", "tcp", "--destination-port", "1111:2222",
-						"--jump", "ACCEPT"},
+					rules.IPTablesRule{
+						"-p", "tcp",
+						"-m", "tcp", "--dport", "9000:9999",
+						"-m", "iprange", "--dst-range", "1.1.1.1-2.2.2.2",
+						"-j", "ACCEPT",
+					},
+					rules.IPTablesRule{
+						"-p", "tcp",
+						"-m", "tcp", "--dport", "1111:2222",
+						"-m", "iprange", "--dst-range", "1.1.1.1-2.2.2.2",
+						"-j", "ACCEPT",
+					},
+					rules.IPTablesRule{
+						"-p", "tcp",
+						"-m", "tcp", "--dport", "9000:9999",
+						"-m", "iprange", "--dst-range", "3.3.3.3-4.4.4.4",
+						"-j", "ACCEPT",
+					},
+					rules.IPTablesRule{
+						"-p", "tcp",
+						"-m", "tcp", "--dport", "1111:2222",
+						"-m", "iprange", "--dst-range", "3.3.3.3-4.4.4.4",
+						"-j", "ACCEPT",
+					},
 				))
 			})
 
@@ -68,21 +80,17 @@ var _ = Describe("RuleConverter", func() {
 				It("returns iptables rules that goto the log chain", func() {
 					ruleSpec := converter.Convert(netrules.NewRuleFromGardenNetOutRule(netOutRule), logChainName, true)
 					Expect(ruleSpec).To(Equal([]rules.IPTablesRule{
-						rules.IPTablesRule{"-m", "iprange", "-p", "tcp",
-							"--dst-range", "1.1.1.1-2.2.2.2",
-							"-m", "tcp", "--destination-port", "9000:9999",
+						rules.IPTablesRule{"-p", "tcp", "-m", "tcp", "--dport", "9000:9999",
+							"-m", "iprange", "--dst-range", "1.1.1.1-2.2.2.2",
 							"-g", logChainName},
-						rules.IPTablesRule{"-m", "iprange", "-p", "tcp",
-							"--dst-range", "1.1.1.1-2.2.2.2",
-							"-m", "tcp", "--destination-port", "1111:2222",
+						rules.IPTablesRule{"-p", "tcp", "-m", "tcp", "--dport", "1111:2222",
+							"-m", "iprange", "--dst-range", "1.1.1.1-2.2.2.2",
 							"-g", logChainName},
-						rules.IPTablesRule{"-m", "iprange", "-p", "tcp",
-							"--dst-range", "3.3.3.3-4.4.4.4",
-							"-m", "tcp", "--destination-port", "9000:9999",
+						rules.IPTablesRule{"-p", "tcp", "-m", "tcp", "--dport", "9000:9999",
+							"-m", "iprange", "--dst-range", "3.3.3.3-4.4.4.4",
 							"-g", logChainName},
-						rules.IPTablesRule{"-m", "iprange", "-p", "tcp",
-							"--dst-range", "3.3.3.3-4.4.4.4",
-							"-m", "tcp", "--destination-port", "1111:2222",
+						rules.IPTablesRule{"-p", "tcp", "-m", "tcp", "--dport", "1111:2222",
+							"-m", "iprange", "--dst-range", "3.3.3.3-4.4.4.4",
 							"-g", logChainName},
 					}))
 				})
@@ -95,22 +103,17 @@ var _ = Describe("RuleConverter", func() {
 				It("returns iptables rules that goto the log chain", func() {
 					ruleSpec := converter.Convert(netrules.NewRuleFromGardenNetOutRule(netOutRule), logChainName, true)
 					Expect(ruleSpec).To(ConsistOf(
-						rules.IPTablesRule{
-							"-m", "iprange", "-p", "tcp",
-							"--dst-range", "1.1.1.1-2.2.2.2",
-							"-m", "tcp", "--destination-port", "9000:9999",
+						rules.IPTablesRule{"-p", "tcp", "-m", "tcp", "--dport", "9000:9999",
+							"-m", "iprange", "--dst-range", "1.1.1.1-2.2.2.2",
 							"-g", logChainName},
-						rules.IPTablesRule{"-m", "iprange", "-p", "tcp",
-							"--dst-range", "1.1.1.1-2.2.2.2",
-							"-m", "tcp", "--destination-port", "1111:2222",
+						rules.IPTablesRule{"-p", "tcp", "-m", "tcp", "--dport", "1111:2222",
+							"-m", "iprange", "--dst-range", "1.1.1.1-2.2.2.2",
 							"-g", logChainName},
-						rules.IPTablesRule{"-m", "iprange", "-p", "tcp",
-							"--dst-range", "3.3.3.3-4.4.4.4",
-							"-m", "tcp", "--destination-port", "9000:9999",
+						rules.IPTablesRule{"-p", "tcp", "-m", "tcp", "--dport", "9000:9999",
+							"-m", "iprange", "--dst-range", "3.3.3.3-4.4.4.4",
 							"-g", logChainName},
-						rules.IPTablesRule{"-m", "iprange", "-p", "tcp",
-							"--dst-range", "3.3.3.3-4.4.4.4",
-							"-m", "tcp", "--destination-port", "1111:2222",
+						rules.IPTablesRule{"-p", "tcp", "-m", "tcp", "--dport", "1111:2222",
+							"-m", "iprange", "--dst-range", "3.3.3.3-4.4.4.4",
 							"-g", logChainName},
 					))
 				})
@@ -156,14 +159,18 @@ var _ = Describe("RuleConverter", func() {
 			It("converts a netout rule to a list of iptables rules", func() {
 				ruleSpec := converter.Convert(netrules.NewRuleFromGardenNetOutRule(netOutRule), logChainName, false)
 				Expect(ruleSpec).To(ConsistOf(
-					rules.IPTablesRule{"-m", "iprange",
+					rules.IPTablesRule{
 						"-p", "icmp",
-						"--dst-range", "3.3.3.3-4.4.4.4", "-m", "icmp", "--icmp-type", "8/0",
-						"--jump", "ACCEPT"},
-					rules.IPTablesRule{"-m", "iprange",
+						"-m", "icmp", "--icmp-type", "8/0",
+						"-m", "iprange", "--dst-range", "3.3.3.3-4.4.4.4",
+						"-j", "ACCEPT",
+					},
+					rules.IPTablesRule{
 						"-p", "icmp",
-						"--dst-range", "5.5.5.5-6.6.6.6", "-m", "icmp", "--icmp-type", "8/0",
-						"--jump", "ACCEPT"},
+						"-m", "icmp", "--icmp-type", "8/0",
+						"-m", "iprange", "--dst-range", "5.5.5.5-6.6.6.6",
+						"-j", "ACCEPT",
+					},
 				))
 			})
 
@@ -171,14 +178,18 @@ var _ = Describe("RuleConverter", func() {
 				It("returns iptables rules that goto the log chain", func() {
 					ruleSpec := converter.Convert(netrules.NewRuleFromGardenNetOutRule(netOutRule), logChainName, true)
 					Expect(ruleSpec).To(ConsistOf(
-						rules.IPTablesRule{"-m", "iprange",
+						rules.IPTablesRule{
 							"-p", "icmp",
-							"--dst-range", "3.3.3.3-4.4.4.4", "-m", "icmp", "--icmp-type", "8/0",
-							"-g", "some-chain"},
-						rules.IPTablesRule{"-m", "iprange",
+							"-m", "icmp", "--icmp-type", "8/0",
+							"-m", "iprange", "--dst-range", "3.3.3.3-4.4.4.4",
+							"-g", "some-chain",
+						},
+						rules.IPTablesRule{
 							"-p", "icmp",
-							"--dst-range", "5.5.5.5-6.6.6.6", "-m", "icmp", "--icmp-type", "8/0",
-							"-g", "some-chain"},
+							"-m", "icmp", "--icmp-type", "8/0",
+							"-m", "iprange", "--dst-range", "5.5.5.5-6.6.6.6",
+							"-g", "some-chain",
+						},
 					))
 				})
 			})
@@ -190,14 +201,18 @@ var _ = Describe("RuleConverter", func() {
 				It("returns iptables rules that goto the log chain", func() {
 					ruleSpec := converter.Convert(netrules.NewRuleFromGardenNetOutRule(netOutRule), logChainName, false)
 					Expect(ruleSpec).To(ConsistOf(
-						rules.IPTablesRule{"-m", "iprange",
+						rules.IPTablesRule{
 							"-p", "icmp",
-							"--dst-range", "3.3.3.3-4.4.4.4", "-m", "icmp", "--icmp-type", "8/0",
-							"-g", "some-chain"},
-						rules.IPTablesRule{"-m", "iprange",
+							"-m", "icmp", "--icmp-type", "8/0",
+							"-m", "iprange", "--dst-range", "3.3.3.3-4.4.4.4",
+							"-g", "some-chain",
+						},
+						rules.IPTablesRule{
 							"-p", "icmp",
-							"--dst-range", "5.5.5.5-6.6.6.6", "-m", "icmp", "--icmp-type", "8/0",
-							"-g", "some-chain"},
+							"-m", "icmp", "--icmp-type", "8/0",
+							"-m", "iprange", "--dst-range", "5.5.5.5-6.6.6.6",
+							"-g", "some-chain",
+						},
 					))
 				})
 			})
@@ -262,12 +277,14 @@ var _ = Describe("RuleConverter", func() {
 			It("converts a netout rule to a list of iptables rules", func() {
 				ruleSpec := converter.Convert(netrules.NewRuleFromGardenNetOutRule(netOutRule), logChainName, false)
 				Expect(ruleSpec).To(ConsistOf(
-					rules.IPTablesRule{"-m", "iprange",
-						"--dst-range", "1.1.1.1-2.2.2.2",
-						"--jump", "ACCEPT"},
-					rules.IPTablesRule{"-m", "iprange",
-						"--dst-range", "3.3.3.3-4.4.4.4",
-						"--jump", "ACCEPT"},
+					rules.IPTablesRule{
+						"-m", "iprange", "--dst-range", "1.1.1.1-2.2.2.2",
+						"-j", "ACCEPT",
+					},
+					rules.IPTablesRule{
+						"-m", "iprange", "--dst-range", "3.3.3.3-4.4.4.4",
+						"-j", "ACCEPT",
+					},
 				))
 			})
 
@@ -323,6 +340,35 @@ var _ = Describe("RuleConverter", func() {
 
 	})
 
+	Describe("deny rules", func() {
+		BeforeEach(func() {
+			netOutRule = garden.NetOutRule{
+				Protocol: garden.ProtocolTCP,
+				Networks: []garden.IPRange{
+					{Start: net.ParseIP("1.1.1.1"), End: net.ParseIP("2.2.2.2")},
+				},
+				Ports: []garden.PortRange{{Start: 9000, End: 9999}},
+				Log:   true,
+			}
+		})
+
+		It("rejects the matched traffic without logging it", func() {
+			denyRules, err := netrules.NewRulesFromRuntimeNetOutRules([]netrules.RuntimeNetOutRule{
+				{NetOutRule: netOutRule, Action: "deny"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			ruleSpec := converter.Convert(denyRules[0], logChainName, true)
+			Expect(ruleSpec).To(Equal([]rules.IPTablesRule{
+				{
+					"-p", "tcp",
+					"-m", "tcp", "--dport", "9000:9999",
+					"-m", "iprange", "--dst-range", "1.1.1.1-2.2.2.2",
+					"-j", "REJECT", "--reject-with", "icmp-port-unreachable",
+				},
+			}))
+		})
+	})
+
 	Describe("BulkConvert", func() {
 		var netOutRules []garden.NetOutRule
 		Context("converts multiple net out rules to generic rules", func() {
@@ -352,28 +398,38 @@ var _ = Describe("RuleConverter", func() {
 				ruleSpec := converter.BulkConvert(netrules.NewRulesFromGardenNetOutRules(netOutRules), logChainName, false)
 
 				Expect(ruleSpec).To(ConsistOf(
-					rules.IPTablesRule{"-m", "iprange", "-p", "tcp",
-						"--dst-range", "1.1.1.1-2.2.2.2",
-						"-m", "tcp", "--destination-port", "9000:9999",
-						"--jump", "ACCEPT"},
-					rules.IPTablesRule{"-m", "iprange", "-p", "tcp",
-						"--dst-range", "1.1.1.1-2.2.2.2",
-						"-m", "tcp", "--destination-port", "1111:2222",
-						"--jump", "ACCEPT"},
-					rules.IPTablesRule{"-m", "iprange", "-p", "tcp",
-						"--dst-range", "3.3.3.3-4.4.4.4",
-						"-m", "tcp", "--destination-port", "9000:9999",
-						"--jump", "ACCEPT"},
-					rules.IPTablesRule{"-m", "iprange", "-p", "tcp",
-						"--dst-range", "3.3.3.3-4.4.4.4",
-						"-m", "tcp", "--destination-port", "1111:2222",
-						"--jump", "ACCEPT"},
-					rules.IPTablesRule{"-m", "iprange",
-						"--dst-range", "5.5.5.5-6.6.6.6",
-						"--jump", "ACCEPT"},
-					rules.IPTablesRule{"-m", "iprange",
-						"--dst-range", "7.7.7.7-8.8.8.8",
-						"--jump", "ACCEPT"},
+					rules.IPTablesRule{
+						"-p", "tcp",
+						"-m", "tcp", "--dport", "9000:9999",
+						"-m", "iprange", "--dst-range", "1.1.1.1-2.2.2.2",
+						"-j", "ACCEPT",
+					},
+					rules.IPTablesRule{
+						"-p", "tcp",
+						"-m", "tcp", "--dport", "1111:2222",
+						"-m", "iprange", "--dst-range", "1.1.1.1-2.2.2.2",
+						"-j", "ACCEPT",
+					},
+					rules.IPTablesRule{
+						"-p", "tcp",
+						"-m", "tcp", "--dport", "9000:9999",
+						"-m", "iprange", "--dst-range", "3.3.3.3-4.4.4.4",
+						"-j", "ACCEPT",
+					},
+					rules.IPTablesRule{
+						"-p", "tcp",
+						"-m", "tcp", "--dport", "1111:2222",
+						"-m", "iprange", "--dst-range", "3.3.3.3-4.4.4.4",
+						"-j", "ACCEPT",
+					},
+					rules.IPTablesRule{
+						"-m", "iprange", "--dst-range", "5.5.5.5-6.6.6.6",
+						"-j", "ACCEPT",
+					},
+					rules.IPTablesRule{
+						"-m", "iprange", "--dst-range", "7.7.7.7-8.8.8.8",
+						"-j", "ACCEPT",
+					},
 				))
 			})
 		})
@@ -405,26 +461,200 @@ var _ = Describe("RuleConverter", func() {
 				ruleSpec := converter.BulkConvert(netrules.NewRulesFromGardenNetOutRules(netOutRules), logChainName, false)
 
 				Expect(ruleSpec).To(ConsistOf(
-					rules.IPTablesRule{"-m", "iprange", "-p", "tcp",
-						"--dst-range", "1.1.1.1-2.2.2.2",
-						"-m", "tcp", "--destination-port", "9000:9999",
-						"--jump", "ACCEPT"},
-					rules.IPTablesRule{"-m", "iprange", "-p", "tcp",
-						"--dst-range", "1.1.1.1-2.2.2.2",
-						"-m", "tcp", "--destination-port", "1111:2222",
-						"--jump", "ACCEPT"},
-					rules.IPTablesRule{"-m", "iprange", "-p", "tcp",
-						"--dst-range", "3.3.3.3-4.4.4.4",
-						"-m", "tcp", "--destination-port", "9000:9999",
-						"--jump", "ACCEPT"},
-					rules.IPTablesRule{"-m", "iprange", "-p", "tcp",
-						"--dst-range", "3.3.3.3-4.4.4.4",
-						"-m", "tcp", "--destination-port", "1111:2222",
-						"--jump", "ACCEPT"},
+					rules.IPTablesRule{
+						"-p", "tcp",
+						"-m", "tcp", "--dport", "9000:9999",
+						"-m", "iprange", "--dst-range", "1.1.1.1-2.2.2.2",
+						"-j", "ACCEPT",
+					},
+					rules.IPTablesRule{
+						"-p", "tcp",
+						"-m", "tcp", "--dport", "1111:2222",
+						"-m", "iprange", "--dst-range", "1.1.1.1-2.2.2.2",
+						"-j", "ACCEPT",
+					},
+					rules.IPTablesRule{
+						"-p", "tcp",
+						"-m", "tcp", "--dport", "9000:9999",
+						"-m", "iprange", "--dst-range", "3.3.3.3-4.4.4.4",
+						"-j", "ACCEPT",
+					},
+					rules.IPTablesRule{
+						"-p", "tcp",
+						"-m", "tcp", "--dport", "1111:2222",
+						"-m", "iprange", "--dst-range", "3.3.3.3-4.4.4.4",
+						"-j", "ACCEPT",
+					},
 				))
 			})
 		})
 
+		Context("when IPSets is set", func() {
+			var ipSets *lib_fakes.IPSetAdapter
+
+			BeforeEach(func() {
+				ipSets = &lib_fakes.IPSetAdapter{}
+				converter.IPSets = ipSets
+
+				netOutRules = []garden.NetOutRule{
+					{
+						Protocol: garden.ProtocolTCP,
+						Networks: []garden.IPRange{
+							{Start: net.ParseIP("3.3.3.3"), End: net.ParseIP("4.4.4.4")},
+							{Start: net.ParseIP("1.1.1.1"), End: net.ParseIP("1.1.1.1")},
+						},
+						Ports: []garden.PortRange{{Start: 443, End: 443}},
+					},
+					{
+						Protocol: garden.ProtocolTCP,
+						Networks: []garden.IPRange{
+							{Start: net.ParseIP("5.5.5.5"), End: net.ParseIP("6.6.6.6")},
+						},
+						Ports: []garden.PortRange{{Start: 443, End: 443}},
+					},
+					{
+						Protocol: garden.ProtocolAll,
+						Networks: []garden.IPRange{
+							{Start: net.ParseIP("7.7.7.7"), End: net.ParseIP("8.8.8.8")},
+						},
+					},
+				}
+			})
+
+			It("matches networks sharing protocol and ports with one ipset", func() {
+				ruleSpec := converter.BulkConvert(netrules.NewRulesFromGardenNetOutRules(netOutRules), logChainName, false)
+
+				Expect(ipSets.EnsureCallCount()).To(Equal(1))
+				set := ipSets.EnsureArgsForCall(0)
+				Expect(set).To(Equal(rules.NewIPSet([]string{"1.1.1.1", "3.3.3.3-4.4.4.4", "5.5.5.5-6.6.6.6"})))
+				Expect(set.Name).To(HavePrefix("silk-"))
+
+				Expect(ruleSpec).To(ConsistOf(
+					rules.IPTablesRule{
+						"-p", "tcp", "-m", "tcp", "--dport", "443",
+						"-m", "set", "--match-set", set.Name, "dst",
+						"-j", "ACCEPT",
+					},
+					rules.IPTablesRule{
+						"-m", "iprange", "--dst-range", "7.7.7.7-8.8.8.8",
+						"-j", "ACCEPT",
+					},
+				))
+			})
+
+			Context("when logging is enabled", func() {
+				It("goes to the log chain from the ipset rule", func() {
+					ruleSpec := converter.BulkConvert(netrules.NewRulesFromGardenNetOutRules(netOutRules), logChainName, true)
+
+					set := ipSets.EnsureArgsForCall(0)
+					Expect(ruleSpec).To(ContainElement(
+						rules.IPTablesRule{
+							"-p", "tcp", "-m", "tcp", "--dport", "443",
+							"-m", "set", "--match-set", set.Name, "dst",
+							"-g", logChainName,
+						},
+					))
+				})
+			})
+
+			Context("when rules differ in action", func() {
+				It("matches the allowed and the denied networks with separate ipsets", func() {
+					ruleSpec, err := netrules.NewRulesFromRuntimeNetOutRules([]netrules.RuntimeNetOutRule{
+						{NetOutRule: netOutRules[0]},
+						{NetOutRule: netOutRules[1], Action: "deny"},
+						{NetOutRule: netOutRules[2]},
+						{
+							NetOutRule: garden.NetOutRule{
+								Protocol: garden.ProtocolTCP,
+								Networks: []garden.IPRange{
+									{Start: net.ParseIP("9.9.9.9"), End: net.ParseIP("9.9.9.9")},
+								},
+								Ports: []garden.PortRange{{Start: 443, End: 443}},
+							},
+							Action: "deny",
+						},
+					})
+					Expect(err).NotTo(HaveOccurred())
+
+					iptablesRules := converter.BulkConvert(ruleSpec, logChainName, true)
+
+					Expect(ipSets.EnsureCallCount()).To(Equal(2))
+					allowed := ipSets.EnsureArgsForCall(0)
+					Expect(allowed).To(Equal(rules.NewIPSet([]string{"1.1.1.1", "3.3.3.3-4.4.4.4"})))
+					denied := ipSets.EnsureArgsForCall(1)
+					Expect(denied).To(Equal(rules.NewIPSet([]string{"5.5.5.5-6.6.6.6", "9.9.9.9"})))
+
+					Expect(iptablesRules).To(ContainElements(
+						rules.IPTablesRule{
+							"-p", "tcp", "-m", "tcp", "--dport", "443",
+							"-m", "set", "--match-set", allowed.Name, "dst",
+							"-g", logChainName,
+						},
+						rules.IPTablesRule{
+							"-p", "tcp", "-m", "tcp", "--dport", "443",
+							"-m", "set", "--match-set", denied.Name, "dst",
+							"-j", "REJECT", "--reject-with", "icmp-port-unreachable",
+						},
+					))
+				})
+			})
+
+			Context("when a group includes all addresses", func() {
+				BeforeEach(func() {
+					netOutRules[1].Networks = append(netOutRules[1].Networks,
+						garden.IPRange{Start: net.ParseIP("0.0.0.0"), End: net.ParseIP("255.255.255.255")})
+				})
+
+				It("keeps an iprange rule for them, since hash:net sets cannot hold them", func() {
+					ruleSpec := converter.BulkConvert(netrules.NewRulesFromGardenNetOutRules(netOutRules), logChainName, false)
+
+					set := ipSets.EnsureArgsForCall(0)
+					Expect(set.Members).NotTo(ContainElement(ContainSubstring("0.0.0.0")))
+					Expect(ruleSpec).To(ContainElement(
+						rules.IPTablesRule{
+							"-p", "tcp",
+							"-m", "tcp", "--dport", "443",
+							"-m", "iprange", "--dst-range", "0.0.0.0-255.255.255.255",
+							"-j", "ACCEPT",
+						},
+					))
+				})
+			})
+
+			Context("when ensuring the ipset fails", func() {
+				BeforeEach(func() {
+					ipSets.EnsureReturns(errors.New("potato"))
+				})
+
+				It("falls back to a rule per network and logs the error", func() {
+					ruleSpec := converter.BulkConvert(netrules.NewRulesFromGardenNetOutRules(netOutRules), logChainName, false)
+
+					Expect(ruleSpec).To(HaveLen(4))
+					Expect(ruleSpec).To(ContainElement(
+						rules.IPTablesRule{
+							"-p", "tcp",
+							"-m", "tcp", "--dport", "443",
+							"-m", "iprange", "--dst-range", "5.5.5.5-6.6.6.6",
+							"-j", "ACCEPT",
+						},
+					))
+					Expect(logger.String()).To(ContainSubstring("potato"))
+				})
+			})
+
+			Context("when a net out rule is invalid", func() {
+				BeforeEach(func() {
+					netOutRules[2].Ports = []garden.PortRange{{Start: 80, End: 80}}
+				})
+
+				It("skips it and logs the warning", func() {
+					ruleSpec := converter.BulkConvert(netrules.NewRulesFromGardenNetOutRules(netOutRules), logChainName, false)
+
+					Expect(ruleSpec).To(HaveLen(1))
+					Expect(logger.String()).To(ContainSubstring("Rule for all protocols (TCP/UDP/ICMP) must not specify ports"))
+				})
+			})
+		})
 	})
 	Describe("DeduplicateRules", func() {
 		var unfilteredRules []rules.IPTablesRule
@@ -432,34 +662,34 @@ var _ = Describe("RuleConverter", func() {
 			BeforeEach(func() {
 				unfilteredRules = []rules.IPTablesRule{
 					{
-						"-m", "iprange", "-p", "tcp",
-						"--dst-range", "1.1.1.1-2.2.2.2",
-						"-m", "tcp", "--destination-port", "9000:9999",
-						"--jump", "ACCEPT",
+						"-p", "tcp",
+						"-m", "tcp", "--dport", "9000:9999",
+						"-m", "iprange", "--dst-range", "1.1.1.1-2.2.2.2",
+						"-j", "ACCEPT",
 					},
 					{
-						"-m", "iprange", "-p", "tcp",
-						"--dst-range", "1.1.1.1-2.2.2.2",
-						"-m", "tcp", "--destination-port", "9000:9999",
-						"--jump", "ACCEPT",
+						"-p", "tcp",
+						"-m", "tcp", "--dport", "9000:9999",
+						"-m", "iprange", "--dst-range", "1.1.1.1-2.2.2.2",
+						"-j", "ACCEPT",
 					},
 					{
-						"-m", "iprange", "-p", "tcp",
-						"--dst-range", "1.1.1.1-2.2.2.2",
-						"-m", "tcp", "--destination-port", "9000:9999",
-						"--jump", "ACCEPT",
+						"-p", "tcp",
+						"-m", "tcp", "--dport", "9000:9999",
+						"-m", "iprange", "--dst-range", "1.1.1.1-2.2.2.2",
+						"-j", "ACCEPT",
 					},
 					{
-						"-m", "iprange", "-p", "tcp",
-						"--dst-range", "3.3.3.3-4.4.4.4",
-						"-m", "tcp", "--destination-port", "9000:9999",
-						"--jump", "ACCEPT",
+						"-p", "tcp",
+						"-m", "tcp", "--dport", "9000:9999",
+						"-m", "iprange", "--dst-range", "3.3.3.3-4.4.4.4",
+						"-j", "ACCEPT",
 					},
 					{
-						"-m", "iprange", "-p", "tcp",
-						"--dst-range", "4.4.4.4-5.5.5.5",
-						"-m", "tcp", "--destination-port", "9000:9999",
-						"--jump", "ACCEPT",
+						"-p", "tcp",
+						"-m", "tcp", "--dport", "9000:9999",
+						"-m", "iprange", "--dst-range", "4.4.4.4-5.5.5.5",
+						"-j", "ACCEPT",
 					},
 				}
 			})
@@ -468,20 +698,104 @@ var _ = Describe("RuleConverter", func() {
 				dedupedRules := converter.DeduplicateRules(unfilteredRules)
 
 				Expect(dedupedRules).To(ConsistOf(
-					rules.IPTablesRule{"-m", "iprange", "-p", "tcp",
-						"--dst-range", "1.1.1.1-2.2.2.2",
-						"-m", "tcp", "--destination-port", "9000:9999",
-						"--jump", "ACCEPT"},
-					rules.IPTablesRule{"-m", "iprange", "-p", "tcp",
-						"--dst-range", "4.4.4.4-5.5.5.5",
-						"-m", "tcp", "--destination-port", "9000:9999",
-						"--jump", "ACCEPT"},
-					rules.IPTablesRule{"-m", "iprange", "-p", "tcp",
-						"--dst-range", "3.3.3.3-4.4.4.4",
-						"-m", "tcp", "--destination-port", "9000:9999",
-						"--jump", "ACCEPT"},
+					rules.IPTablesRule{
+						"-p", "tcp",
+						"-m", "tcp", "--dport", "9000:9999",
+						"-m", "iprange", "--dst-range", "1.1.1.1-2.2.2.2",
+						"-j", "ACCEPT",
+					},
+					rules.IPTablesRule{
+						"-p", "tcp",
+						"-m", "tcp", "--dport", "9000:9999",
+						"-m", "iprange", "--dst-range", "4.4.4.4-5.5.5.5",
+						"-j", "ACCEPT",
+					},
+					rules.IPTablesRule{
+						"-p", "tcp",
+						"-m", "tcp", "--dport", "9000:9999",
+						"-m", "iprange", "--dst-range", "3.3.3.3-4.4.4.4",
+						"-j", "ACCEPT",
+					},
+				))
+			})
+		})
+	})
+
+	Describe("DNS name destinations", func() {
+		var (
+			fqdnSets *fakes.FQDNSets
+			rule     netrules.Rule
+		)
+
+		BeforeEach(func() {
+			fqdnSets = &fakes.FQDNSets{}
+			fqdnSets.EnsureStub = func(fqdn string) (string, error) {
+				return "silk-" + fqdn, nil
+			}
+			converter.FQDNs = fqdnSets
+
+			var err error
+			rule, err = netrules.NewRuleFromSecurityGroupRule(policy_client.SecurityGroupRule{
+				Protocol:    "tcp",
+				Destination: "10.0.0.1,api.example.com",
+				Ports:       "443,8000-8080",
+			})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("matches each name with its ipset", func() {
+			Expect(converter.BulkConvert([]netrules.Rule{rule}, logChainName, false)).To(Equal([]rules.IPTablesRule{
+				rules.NewNetOutWithPortsRule("10.0.0.1", "10.0.0.1", 443, 443, "tcp"),
+				rules.NewNetOutWithPortsRule("10.0.0.1", "10.0.0.1", 8000, 8080, "tcp"),
+				rules.NewNetOutSetWithPortsRule("silk-api.example.com", 443, 443, "tcp"),
+				rules.NewNetOutSetWithPortsRule("silk-api.example.com", 8000, 8080, "tcp"),
+			}))
+			Expect(fqdnSets.EnsureCallCount()).To(Equal(1))
+			Expect(fqdnSets.EnsureArgsForCall(0)).To(Equal("api.example.com"))
+		})
+
+		It("logs to the log chain when logging is enabled", func() {
+			Expect(converter.BulkConvert([]netrules.Rule{rule}, logChainName, true)).To(ContainElement(
+				rules.NewNetOutSetWithPortsLogRule("silk-api.example.com", 443, 443, "tcp", logChainName),
+			))
+		})
+
+		Context("when IPSets is set", func() {
+			BeforeEach(func() {
+				converter.IPSets = &lib_fakes.IPSetAdapter{}
+			})
+
+			It("matches each name with its ipset", func() {
+				Expect(converter.BulkConvert([]netrules.Rule{rule}, logChainName, false)).To(ConsistOf(
+					rules.NewNetOutWithPortsRule("10.0.0.1", "10.0.0.1", 443, 443, "tcp"),
+					rules.NewNetOutWithPortsRule("10.0.0.1", "10.0.0.1", 8000, 8080, "tcp"),
+					rules.NewNetOutSetWithPortsRule("silk-api.example.com", 443, 443, "tcp"),
+					rules.NewNetOutSetWithPortsRule("silk-api.example.com", 8000, 8080, "tcp"),
 				))
 			})
 		})
+
+		Context("when DNS names are not enabled", func() {
+			BeforeEach(func() {
+				converter.FQDNs = nil
+			})
+
+			It("skips the names and logs", func() {
+				Expect(converter.BulkConvert([]netrules.Rule{rule}, logChainName, false)).To(HaveLen(2))
+				Expect(logger.String()).To(ContainSubstring("DNS name destinations are not enabled"))
+			})
+		})
+
+		Context("when ensuring the ipset fails", func() {
+			BeforeEach(func() {
+				fqdnSets.EnsureStub = nil
+				fqdnSets.EnsureReturns("", errors.New("banana"))
+			})
+
+			It("skips the name and logs", func() {
+				Expect(converter.BulkConvert([]netrules.Rule{rule}, logChainName, false)).To(HaveLen(2))
+				Expect(logger.String()).To(ContainSubstring("creating ipset for api.example.com: banana"))
+			})
+		})
 	})
 })
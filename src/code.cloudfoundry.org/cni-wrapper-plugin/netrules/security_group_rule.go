@@ -3,6 +3,7 @@ package netrules
 import (
 	"errors"
 	"net"
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -12,24 +13,48 @@ import (
 
 var ErrIPRangeConversionFailed = errors.New("failed to convert destination to ip range")
 
+var ErrWildcardDNSName = errors.New("wildcard DNS names are not supported as destinations")
+
+var ErrInvalidAction = errors.New("action must be allow or deny")
+
+var dnsLabelRegex = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+
 type securityGroupRule struct {
 	rule     policy_client.SecurityGroupRule
 	networks []IPRange
+	fqdns    []string
+	action   Action
 }
 
+// NewRuleFromSecurityGroupRule accepts addresses, CIDRs, ranges and DNS names
+// as destinations. Wildcard names are not supported.
 func NewRuleFromSecurityGroupRule(sgRule policy_client.SecurityGroupRule) (Rule, error) {
+	action, err := parseAction(sgRule.Action)
+	if err != nil {
+		return nil, err
+	}
+
 	networks := []IPRange{}
+	var fqdns []string
 
 	destinations := strings.Split(sgRule.Destination, ",")
 	for _, dest := range destinations {
 		ipRange, err := toIPRange(dest)
-		if err != nil {
+		if err == nil {
+			networks = append(networks, ipRange)
+			continue
+		}
+		if strings.Contains(dest, "*") {
+			return nil, ErrWildcardDNSName
+		}
+		fqdn, ok := toFQDN(dest)
+		if !ok {
 			return nil, err
 		}
-		networks = append(networks, ipRange)
+		fqdns = append(fqdns, fqdn)
 	}
 
-	return &securityGroupRule{rule: sgRule, networks: networks}, nil
+	return &securityGroupRule{rule: sgRule, networks: networks, fqdns: fqdns, action: action}, nil
 }
 
 func NewRulesFromSecurityGroupRules(securityGroupRules []policy_client.SecurityGroupRule) ([]Rule, error) {
@@ -44,6 +69,14 @@ func NewRulesFromSecurityGroupRules(securityGroupRules []policy_client.SecurityG
 	return ruleSpec, nil
 }
 
+func (r *securityGroupRule) Action() Action {
+	return r.action
+}
+
+func (r *securityGroupRule) Priority() int {
+	return r.rule.Priority
+}
+
 func (r *securityGroupRule) Log() bool {
 	return r.rule.Log
 }
@@ -56,6 +89,10 @@ func (r *securityGroupRule) Networks() []IPRange {
 	return r.networks
 }
 
+func (r *securityGroupRule) FQDNs() []string {
+	return r.fqdns
+}
+
 func (r *securityGroupRule) Ports() []PortRange {
 	portRangeStrs := strings.Split(r.rule.Ports, ",")
 	var portRanges []PortRange
@@ -89,6 +126,43 @@ func (r *securityGroupRule) ICMPInfo() *ICMPInfo {
 	}
 }
 
+// parseAction defaults to allow, since rules written before ASGs had actions
+// have none.
+func parseAction(action string) (Action, error) {
+	switch Action(action) {
+	case "", ActionAllow:
+		return ActionAllow, nil
+	case ActionDeny:
+		return ActionDeny, nil
+	default:
+		return "", ErrInvalidAction
+	}
+}
+
+// toFQDN returns the destination as a lower case DNS name of at least two
+// labels. A top-level label that starts with a digit is a malformed address
+// or range instead.
+func toFQDN(dest string) (string, bool) {
+	name := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(dest), "."))
+	if len(name) > 253 {
+		return "", false
+	}
+
+	labels := strings.Split(name, ".")
+	if len(labels) < 2 {
+		return "", false
+	}
+	for _, label := range labels {
+		if !dnsLabelRegex.MatchString(label) {
+			return "", false
+		}
+	}
+	if tld := labels[len(labels)-1]; tld[0] < 'a' || tld[0] > 'z' {
+		return "", false
+	}
+	return name, true
+}
+
 func toIPRange(dest string) (IPRange, error) {
 	idx := strings.IndexAny(dest, "-/")
 
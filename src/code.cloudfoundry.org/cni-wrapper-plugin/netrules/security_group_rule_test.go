@@ -39,6 +39,32 @@ var _ = Describe("SecurityGroupRule", func() {
 			Expect(rule.Networks()).To(Equal([]netrules.IPRange{{Start: net.IPv4(10, 0, 0, 1), End: net.IPv4(10, 0, 1, 10)}}))
 		})
 
+		It("parses a DNS name", func() {
+			securityGroupRule := policy_client.SecurityGroupRule{
+				Destination: "10.0.0.1,API.Example.com.",
+			}
+			rule, err := netrules.NewRuleFromSecurityGroupRule(securityGroupRule)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rule.Networks()).To(Equal([]netrules.IPRange{{Start: net.IPv4(10, 0, 0, 1), End: net.IPv4(10, 0, 0, 1)}}))
+			Expect(rule.FQDNs()).To(Equal([]string{"api.example.com"}))
+		})
+
+		It("raises an error for wildcard DNS names", func() {
+			securityGroupRule := policy_client.SecurityGroupRule{
+				Destination: "10.0.0.1,*.s3.amazonaws.com",
+			}
+			_, err := netrules.NewRuleFromSecurityGroupRule(securityGroupRule)
+			Expect(err).To(Equal(netrules.ErrWildcardDNSName))
+		})
+
+		It("raises an error for malformed addresses", func() {
+			securityGroupRule := policy_client.SecurityGroupRule{
+				Destination: "10.0.0.256",
+			}
+			_, err := netrules.NewRuleFromSecurityGroupRule(securityGroupRule)
+			Expect(err).To(Equal(netrules.ErrIPRangeConversionFailed))
+		})
+
 		It("raises an error when network is invalid", func() {
 			securityGroupRule := policy_client.SecurityGroupRule{
 				Destination: "invalid",
@@ -136,6 +162,37 @@ var _ = Describe("SecurityGroupRule", func() {
 		})
 	})
 
+	Describe("Action and Priority", func() {
+		It("parses a deny rule and its priority", func() {
+			securityGroupRule := policy_client.SecurityGroupRule{
+				Destination: "10.0.0.1",
+				Action:      "deny",
+				Priority:    10,
+			}
+			rule, err := netrules.NewRuleFromSecurityGroupRule(securityGroupRule)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rule.Action()).To(Equal(netrules.ActionDeny))
+			Expect(rule.Priority()).To(Equal(10))
+		})
+		It("allows with priority 0 when neither is set", func() {
+			securityGroupRule := policy_client.SecurityGroupRule{
+				Destination: "10.0.0.1",
+			}
+			rule, err := netrules.NewRuleFromSecurityGroupRule(securityGroupRule)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rule.Action()).To(Equal(netrules.ActionAllow))
+			Expect(rule.Priority()).To(Equal(0))
+		})
+		It("raises an error for an unknown action", func() {
+			securityGroupRule := policy_client.SecurityGroupRule{
+				Destination: "10.0.0.1",
+				Action:      "drop",
+			}
+			_, err := netrules.NewRuleFromSecurityGroupRule(securityGroupRule)
+			Expect(err).To(Equal(netrules.ErrInvalidAction))
+		})
+	})
+
 	Describe("Ports", func() {
 		It("parses 1 port", func() {
 			securityGroupRule := policy_client.SecurityGroupRule{
@@ -0,0 +1,185 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/lib/rules"
+)
+
+type IPSetAdapter struct {
+	DestroyStub        func(...string) error
+	destroyMutex       sync.RWMutex
+	destroyArgsForCall []struct {
+		arg1 []string
+	}
+	destroyReturns struct {
+		result1 error
+	}
+	destroyReturnsOnCall map[int]struct {
+		result1 error
+	}
+	EnsureStub        func(rules.IPSet) error
+	ensureMutex       sync.RWMutex
+	ensureArgsForCall []struct {
+		arg1 rules.IPSet
+	}
+	ensureReturns struct {
+		result1 error
+	}
+	ensureReturnsOnCall map[int]struct {
+		result1 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *IPSetAdapter) Destroy(arg1 ...string) error {
+	fake.destroyMutex.Lock()
+	ret, specificReturn := fake.destroyReturnsOnCall[len(fake.destroyArgsForCall)]
+	fake.destroyArgsForCall = append(fake.destroyArgsForCall, struct {
+		arg1 []string
+	}{arg1})
+	stub := fake.DestroyStub
+	fakeReturns := fake.destroyReturns
+	fake.recordInvocation("Destroy", []interface{}{arg1})
+	fake.destroyMutex.Unlock()
+	if stub != nil {
+		return stub(arg1...)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *IPSetAdapter) DestroyCallCount() int {
+	fake.destroyMutex.RLock()
+	defer fake.destroyMutex.RUnlock()
+	return len(fake.destroyArgsForCall)
+}
+
+func (fake *IPSetAdapter) DestroyCalls(stub func(...string) error) {
+	fake.destroyMutex.Lock()
+	defer fake.destroyMutex.Unlock()
+	fake.DestroyStub = stub
+}
+
+func (fake *IPSetAdapter) DestroyArgsForCall(i int) []string {
+	fake.destroyMutex.RLock()
+	defer fake.destroyMutex.RUnlock()
+	argsForCall := fake.destroyArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *IPSetAdapter) DestroyReturns(result1 error) {
+	fake.destroyMutex.Lock()
+	defer fake.destroyMutex.Unlock()
+	fake.DestroyStub = nil
+	fake.destroyReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *IPSetAdapter) DestroyReturnsOnCall(i int, result1 error) {
+	fake.destroyMutex.Lock()
+	defer fake.destroyMutex.Unlock()
+	fake.DestroyStub = nil
+	if fake.destroyReturnsOnCall == nil {
+		fake.destroyReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.destroyReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *IPSetAdapter) Ensure(arg1 rules.IPSet) error {
+	fake.ensureMutex.Lock()
+	ret, specificReturn := fake.ensureReturnsOnCall[len(fake.ensureArgsForCall)]
+	fake.ensureArgsForCall = append(fake.ensureArgsForCall, struct {
+		arg1 rules.IPSet
+	}{arg1})
+	stub := fake.EnsureStub
+	fakeReturns := fake.ensureReturns
+	fake.recordInvocation("Ensure", []interface{}{arg1})
+	fake.ensureMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *IPSetAdapter) EnsureCallCount() int {
+	fake.ensureMutex.RLock()
+	defer fake.ensureMutex.RUnlock()
+	return len(fake.ensureArgsForCall)
+}
+
+func (fake *IPSetAdapter) EnsureCalls(stub func(rules.IPSet) error) {
+	fake.ensureMutex.Lock()
+	defer fake.ensureMutex.Unlock()
+	fake.EnsureStub = stub
+}
+
+func (fake *IPSetAdapter) EnsureArgsForCall(i int) rules.IPSet {
+	fake.ensureMutex.RLock()
+	defer fake.ensureMutex.RUnlock()
+	argsForCall := fake.ensureArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *IPSetAdapter) EnsureReturns(result1 error) {
+	fake.ensureMutex.Lock()
+	defer fake.ensureMutex.Unlock()
+	fake.EnsureStub = nil
+	fake.ensureReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *IPSetAdapter) EnsureReturnsOnCall(i int, result1 error) {
+	fake.ensureMutex.Lock()
+	defer fake.ensureMutex.Unlock()
+	fake.EnsureStub = nil
+	if fake.ensureReturnsOnCall == nil {
+		fake.ensureReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.ensureReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *IPSetAdapter) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.destroyMutex.RLock()
+	defer fake.destroyMutex.RUnlock()
+	fake.ensureMutex.RLock()
+	defer fake.ensureMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *IPSetAdapter) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ rules.IPSetAdapter = new(IPSetAdapter)
@@ -19,6 +19,17 @@ type IPTablesAdapter struct {
 	allowTrafficForRangeReturnsOnCall map[int]struct {
 		result1 error
 	}
+	ApplyTransactionStub        func(*rules.RestoreTransaction) error
+	applyTransactionMutex       sync.RWMutex
+	applyTransactionArgsForCall []struct {
+		arg1 *rules.RestoreTransaction
+	}
+	applyTransactionReturns struct {
+		result1 error
+	}
+	applyTransactionReturnsOnCall map[int]struct {
+		result1 error
+	}
 	BulkAppendStub        func(string, string, ...rules.IPTablesRule) error
 	bulkAppendMutex       sync.RWMutex
 	bulkAppendArgsForCall []struct {
@@ -123,6 +134,19 @@ type IPTablesAdapter struct {
 	deleteChainReturnsOnCall map[int]struct {
 		result1 error
 	}
+	DeleteRuleNumStub        func(string, string, int) error
+	deleteRuleNumMutex       sync.RWMutex
+	deleteRuleNumArgsForCall []struct {
+		arg1 string
+		arg2 string
+		arg3 int
+	}
+	deleteRuleNumReturns struct {
+		result1 error
+	}
+	deleteRuleNumReturnsOnCall map[int]struct {
+		result1 error
+	}
 	ExistsStub        func(string, string, rules.IPTablesRule) (bool, error)
 	existsMutex       sync.RWMutex
 	existsArgsForCall []struct {
@@ -279,6 +303,67 @@ func (fake *IPTablesAdapter) AllowTrafficForRangeReturnsOnCall(i int, result1 er
 	}{result1}
 }
 
+func (fake *IPTablesAdapter) ApplyTransaction(arg1 *rules.RestoreTransaction) error {
+	fake.applyTransactionMutex.Lock()
+	ret, specificReturn := fake.applyTransactionReturnsOnCall[len(fake.applyTransactionArgsForCall)]
+	fake.applyTransactionArgsForCall = append(fake.applyTransactionArgsForCall, struct {
+		arg1 *rules.RestoreTransaction
+	}{arg1})
+	stub := fake.ApplyTransactionStub
+	fakeReturns := fake.applyTransactionReturns
+	fake.recordInvocation("ApplyTransaction", []interface{}{arg1})
+	fake.applyTransactionMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *IPTablesAdapter) ApplyTransactionCallCount() int {
+	fake.applyTransactionMutex.RLock()
+	defer fake.applyTransactionMutex.RUnlock()
+	return len(fake.applyTransactionArgsForCall)
+}
+
+func (fake *IPTablesAdapter) ApplyTransactionCalls(stub func(*rules.RestoreTransaction) error) {
+	fake.applyTransactionMutex.Lock()
+	defer fake.applyTransactionMutex.Unlock()
+	fake.ApplyTransactionStub = stub
+}
+
+func (fake *IPTablesAdapter) ApplyTransactionArgsForCall(i int) *rules.RestoreTransaction {
+	fake.applyTransactionMutex.RLock()
+	defer fake.applyTransactionMutex.RUnlock()
+	argsForCall := fake.applyTransactionArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *IPTablesAdapter) ApplyTransactionReturns(result1 error) {
+	fake.applyTransactionMutex.Lock()
+	defer fake.applyTransactionMutex.Unlock()
+	fake.ApplyTransactionStub = nil
+	fake.applyTransactionReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *IPTablesAdapter) ApplyTransactionReturnsOnCall(i int, result1 error) {
+	fake.applyTransactionMutex.Lock()
+	defer fake.applyTransactionMutex.Unlock()
+	fake.ApplyTransactionStub = nil
+	if fake.applyTransactionReturnsOnCall == nil {
+		fake.applyTransactionReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.applyTransactionReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *IPTablesAdapter) BulkAppend(arg1 string, arg2 string, arg3 ...rules.IPTablesRule) error {
 	fake.bulkAppendMutex.Lock()
 	ret, specificReturn := fake.bulkAppendReturnsOnCall[len(fake.bulkAppendArgsForCall)]
@@ -784,6 +869,69 @@ func (fake *IPTablesAdapter) DeleteChainReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *IPTablesAdapter) DeleteRuleNum(arg1 string, arg2 string, arg3 int) error {
+	fake.deleteRuleNumMutex.Lock()
+	ret, specificReturn := fake.deleteRuleNumReturnsOnCall[len(fake.deleteRuleNumArgsForCall)]
+	fake.deleteRuleNumArgsForCall = append(fake.deleteRuleNumArgsForCall, struct {
+		arg1 string
+		arg2 string
+		arg3 int
+	}{arg1, arg2, arg3})
+	stub := fake.DeleteRuleNumStub
+	fakeReturns := fake.deleteRuleNumReturns
+	fake.recordInvocation("DeleteRuleNum", []interface{}{arg1, arg2, arg3})
+	fake.deleteRuleNumMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *IPTablesAdapter) DeleteRuleNumCallCount() int {
+	fake.deleteRuleNumMutex.RLock()
+	defer fake.deleteRuleNumMutex.RUnlock()
+	return len(fake.deleteRuleNumArgsForCall)
+}
+
+func (fake *IPTablesAdapter) DeleteRuleNumCalls(stub func(string, string, int) error) {
+	fake.deleteRuleNumMutex.Lock()
+	defer fake.deleteRuleNumMutex.Unlock()
+	fake.DeleteRuleNumStub = stub
+}
+
+func (fake *IPTablesAdapter) DeleteRuleNumArgsForCall(i int) (string, string, int) {
+	fake.deleteRuleNumMutex.RLock()
+	defer fake.deleteRuleNumMutex.RUnlock()
+	argsForCall := fake.deleteRuleNumArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *IPTablesAdapter) DeleteRuleNumReturns(result1 error) {
+	fake.deleteRuleNumMutex.Lock()
+	defer fake.deleteRuleNumMutex.Unlock()
+	fake.DeleteRuleNumStub = nil
+	fake.deleteRuleNumReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *IPTablesAdapter) DeleteRuleNumReturnsOnCall(i int, result1 error) {
+	fake.deleteRuleNumMutex.Lock()
+	defer fake.deleteRuleNumMutex.Unlock()
+	fake.DeleteRuleNumStub = nil
+	if fake.deleteRuleNumReturnsOnCall == nil {
+		fake.deleteRuleNumReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.deleteRuleNumReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *IPTablesAdapter) Exists(arg1 string, arg2 string, arg3 rules.IPTablesRule) (bool, error) {
 	fake.existsMutex.Lock()
 	ret, specificReturn := fake.existsReturnsOnCall[len(fake.existsArgsForCall)]
@@ -1234,6 +1382,8 @@ func (fake *IPTablesAdapter) Invocations() map[string][][]interface{} {
 	defer fake.invocationsMutex.RUnlock()
 	fake.allowTrafficForRangeMutex.RLock()
 	defer fake.allowTrafficForRangeMutex.RUnlock()
+	fake.applyTransactionMutex.RLock()
+	defer fake.applyTransactionMutex.RUnlock()
 	fake.bulkAppendMutex.RLock()
 	defer fake.bulkAppendMutex.RUnlock()
 	fake.bulkInsertMutex.RLock()
@@ -1250,6 +1400,8 @@ func (fake *IPTablesAdapter) Invocations() map[string][][]interface{} {
 	defer fake.deleteAfterRuleNumKeepRejectMutex.RUnlock()
 	fake.deleteChainMutex.RLock()
 	defer fake.deleteChainMutex.RUnlock()
+	fake.deleteRuleNumMutex.RLock()
+	defer fake.deleteRuleNumMutex.RUnlock()
 	fake.existsMutex.RLock()
 	defer fake.existsMutex.RUnlock()
 	fake.flushAndRestoreMutex.RLock()
@@ -0,0 +1,114 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/shlex"
+)
+
+// RuleChange is one step that turns a live chain into the desired chain.
+// Index is the 1-based rule number the step inserts at or deletes, counted
+// after the steps before it have been applied.
+type RuleChange struct {
+	Delete bool
+	Index  int
+	Rule   IPTablesRule
+}
+
+// ChainRules returns the rules of a chain as listed by iptables -S. The
+// lines that create the chain or set its policy are skipped.
+func ChainRules(lines []string) ([]IPTablesRule, error) {
+	var chainRules []IPTablesRule
+	for _, line := range lines {
+		args, err := shlex.Split(line)
+		if err != nil {
+			return nil, fmt.Errorf("split line %s: %s", line, err)
+		}
+		if len(args) < 2 {
+			return nil, fmt.Errorf("invalid line: %s", line)
+		}
+
+		switch args[0] {
+		case "-N", "--new-chain", "-P", "--policy":
+		case "-A", "--append":
+			chainRules = append(chainRules, IPTablesRule(args[2:]))
+		default:
+			return nil, fmt.Errorf("unsupported command %s: %s", args[0], line)
+		}
+	}
+	return chainRules, nil
+}
+
+// DiffRules returns the fewest inserts and deletes that turn the live rules
+// into the desired rules while keeping the rules both share in place. Rules
+// are compared regardless of the order of their options.
+func DiffRules(live, desired []IPTablesRule) []RuleChange {
+	liveKeys := diffKeys(live)
+	desiredKeys := diffKeys(desired)
+
+	// common[i][j] is the length of the longest common subsequence of
+	// live[i:] and desired[j:].
+	common := make([][]int, len(live)+1)
+	for i := range common {
+		common[i] = make([]int, len(desired)+1)
+	}
+	for i := len(live) - 1; i >= 0; i-- {
+		for j := len(desired) - 1; j >= 0; j-- {
+			if liveKeys[i] == desiredKeys[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	var changes []RuleChange
+	i, j, index := 0, 0, 1
+	for i < len(live) || j < len(desired) {
+		switch {
+		case i < len(live) && j < len(desired) && liveKeys[i] == desiredKeys[j]:
+			i++
+			j++
+			index++
+		case j == len(desired) || (i < len(live) && common[i+1][j] >= common[i][j+1]):
+			changes = append(changes, RuleChange{Delete: true, Index: index, Rule: live[i]})
+			i++
+		default:
+			changes = append(changes, RuleChange{Index: index, Rule: desired[j]})
+			j++
+			index++
+		}
+	}
+	return changes
+}
+
+// ApplyRuleChanges applies the changes to the chain in order, in a single
+// transaction, so the chain is never left half changed.
+func ApplyRuleChanges(ipt IPTablesAdapter, table, chain string, changes []RuleChange) error {
+	tx := &RestoreTransaction{}
+	for _, change := range changes {
+		if change.Delete {
+			tx.Delete(table, chain, change.Index)
+			continue
+		}
+		tx.Insert(table, chain, change.Index, change.Rule)
+	}
+	if err := ipt.ApplyTransaction(tx); err != nil {
+		return fmt.Errorf("apply rule changes to %s/%s: %s", table, chain, err)
+	}
+	return nil
+}
+
+func diffKeys(rules []IPTablesRule) []string {
+	keys := make([]string, len(rules))
+	for i, rule := range rules {
+		parsed, err := ParseRule(rule)
+		if err != nil {
+			keys[i] = "raw " + strings.Join(rule, " ")
+			continue
+		}
+		keys[i] = strings.Join(parsed.Args(), " ")
+	}
+	return keys
+}
@@ -0,0 +1,158 @@
+package rules_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/lib/fakes"
+	"code.cloudfoundry.org/lib/rules"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Diff", func() {
+	var (
+		ruleA rules.IPTablesRule
+		ruleB rules.IPTablesRule
+		ruleC rules.IPTablesRule
+		ruleD rules.IPTablesRule
+	)
+
+	BeforeEach(func() {
+		ruleA = rules.IPTablesRule{"-d", "1.1.1.1", "-j", "ACCEPT"}
+		ruleB = rules.IPTablesRule{"-d", "2.2.2.2", "-j", "ACCEPT"}
+		ruleC = rules.IPTablesRule{"-d", "3.3.3.3", "-j", "ACCEPT"}
+		ruleD = rules.IPTablesRule{"-d", "4.4.4.4", "-j", "ACCEPT"}
+	})
+
+	Describe("ChainRules", func() {
+		It("returns the rules of the chain", func() {
+			chainRules, err := rules.ChainRules([]string{
+				"-N some-chain",
+				"-A some-chain -d 1.1.1.1/32 -j ACCEPT",
+				`-A some-chain -j LOG --log-prefix "DENY_some-handle "`,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(chainRules).To(Equal([]rules.IPTablesRule{
+				{"-d", "1.1.1.1/32", "-j", "ACCEPT"},
+				{"-j", "LOG", "--log-prefix", "DENY_some-handle "},
+			}))
+		})
+
+		It("skips the policy of built-in chains", func() {
+			chainRules, err := rules.ChainRules([]string{"-P FORWARD ACCEPT", "-A FORWARD -j vpa--123"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(chainRules).To(Equal([]rules.IPTablesRule{{"-j", "vpa--123"}}))
+		})
+
+		It("returns an error for other commands", func() {
+			_, err := rules.ChainRules([]string{"-I some-chain 1 -j ACCEPT"})
+			Expect(err).To(MatchError("unsupported command -I: -I some-chain 1 -j ACCEPT"))
+		})
+	})
+
+	Describe("DiffRules", func() {
+		It("returns no changes when the rules are the same", func() {
+			live := []rules.IPTablesRule{{"--destination", "1.1.1.1/32", "--jump", "ACCEPT"}, ruleB}
+			Expect(rules.DiffRules(live, []rules.IPTablesRule{ruleA, ruleB})).To(BeEmpty())
+		})
+
+		It("inserts the new rules in place", func() {
+			Expect(rules.DiffRules(
+				[]rules.IPTablesRule{ruleA, ruleC},
+				[]rules.IPTablesRule{ruleA, ruleB, ruleC, ruleD},
+			)).To(Equal([]rules.RuleChange{
+				{Index: 2, Rule: ruleB},
+				{Index: 4, Rule: ruleD},
+			}))
+		})
+
+		It("deletes the rules that are no longer desired", func() {
+			Expect(rules.DiffRules(
+				[]rules.IPTablesRule{ruleA, ruleB, ruleC, ruleD},
+				[]rules.IPTablesRule{ruleA, ruleD},
+			)).To(Equal([]rules.RuleChange{
+				{Delete: true, Index: 2, Rule: ruleB},
+				{Delete: true, Index: 2, Rule: ruleC},
+			}))
+		})
+
+		It("moves a rule by deleting and inserting it", func() {
+			Expect(rules.DiffRules(
+				[]rules.IPTablesRule{ruleA, ruleB, ruleC},
+				[]rules.IPTablesRule{ruleB, ruleC, ruleA},
+			)).To(Equal([]rules.RuleChange{
+				{Delete: true, Index: 1, Rule: ruleA},
+				{Index: 3, Rule: ruleA},
+			}))
+		})
+
+		It("matches the rules iptables -S lists to the rules silk builds", func() {
+			live, err := rules.ChainRules([]string{
+				"-N vpa--1234",
+				"-A vpa--1234 -s 10.255.1.2/32 -m comment --comment src:some-app-guid -j MARK --set-xmark 0xaa/0xffffffff",
+				"-A vpa--1234 -d 10.255.1.3/32 -p tcp -m tcp --dport 8080 -m mark --mark 0xaa -m comment --comment src:some-app-guid_dst:other-app-guid -j ACCEPT",
+				"-A vpa--1234 -d 10.255.1.3/32 -p tcp -m tcp --dport 9090 -m mark --mark 0xbb -m comment --comment src:gone-app-guid_dst:other-app-guid -j ACCEPT",
+				"-A vpa--1234 -i underlay -j MARK --set-xmark 0xfeedbeef/0xffffffff",
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			desired := []rules.IPTablesRule{
+				rules.NewMarkSetRule("10.255.1.2", "AA", "some-app-guid"),
+				rules.NewMarkAllowRule("10.255.1.3", "tcp", 8080, 8080, "AA", "some-app-guid", "other-app-guid"),
+				{"-i", "underlay", "-j", "MARK", "--set-mark", "0xFEEDBEEF"},
+			}
+
+			Expect(rules.DiffRules(live, desired)).To(Equal([]rules.RuleChange{
+				{Delete: true, Index: 3, Rule: live[2]},
+			}))
+		})
+
+		It("compares rules it cannot parse argument by argument", func() {
+			Expect(rules.DiffRules(
+				[]rules.IPTablesRule{{"rule1"}, {"rule2"}},
+				[]rules.IPTablesRule{{"rule1"}, {"rule3"}},
+			)).To(Equal([]rules.RuleChange{
+				{Delete: true, Index: 2, Rule: rules.IPTablesRule{"rule2"}},
+				{Index: 2, Rule: rules.IPTablesRule{"rule3"}},
+			}))
+		})
+	})
+
+	Describe("ApplyRuleChanges", func() {
+		var ipt *fakes.IPTablesAdapter
+
+		BeforeEach(func() {
+			ipt = &fakes.IPTablesAdapter{}
+		})
+
+		It("applies the changes in order in a single transaction", func() {
+			err := rules.ApplyRuleChanges(ipt, "filter", "some-chain", []rules.RuleChange{
+				{Delete: true, Index: 2, Rule: ruleB},
+				{Index: 3, Rule: ruleD},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(ipt.ApplyTransactionCallCount()).To(Equal(1))
+			Expect(ipt.ApplyTransactionArgsForCall(0).String()).To(Equal("*filter\n" +
+				"-D some-chain 2\n" +
+				"-I some-chain 3 -d 4.4.4.4 -j ACCEPT\n" +
+				"COMMIT\n"))
+			Expect(ipt.DeleteRuleNumCallCount()).To(Equal(0))
+			Expect(ipt.BulkInsertCallCount()).To(Equal(0))
+		})
+
+		Context("when the transaction fails", func() {
+			BeforeEach(func() {
+				ipt.ApplyTransactionReturns(errors.New("banana"))
+			})
+
+			It("returns the error", func() {
+				err := rules.ApplyRuleChanges(ipt, "filter", "some-chain", []rules.RuleChange{
+					{Delete: true, Index: 2, Rule: ruleB},
+				})
+				Expect(err).To(MatchError("apply rule changes to filter/some-chain: banana"))
+			})
+		})
+	})
+})
@@ -0,0 +1,166 @@
+package rules
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+
+	multierror "github.com/hashicorp/go-multierror"
+)
+
+const IPSetPrefix = "silk-"
+
+var matchSetRegex = regexp.MustCompile(`--match-set\s+(\S+)`)
+
+// ValidateIPSets rejects ipsets with the nftables backend, which cannot
+// translate set matches.
+func ValidateIPSets(backend string, enabled bool) error {
+	if enabled && backend == NFTablesBackend {
+		return fmt.Errorf("ASG ipsets require the %s firewall backend", IPTablesBackend)
+	}
+	return nil
+}
+
+// IPSet is a hash:net ipset of destination networks. Members may be
+// addresses, CIDRs or start-end ranges. When Timeout is set, members expire
+// unless they are added again within that many seconds. MemberTimeout
+// overrides it for the members added by one Ensure.
+type IPSet struct {
+	Name          string
+	Members       []string
+	Timeout       int
+	MemberTimeout int
+}
+
+// NewIPSet names the set after a digest of its members, so containers with
+// the same destinations share one set and its contents never change.
+func NewIPSet(members []string) IPSet {
+	sorted := append([]string{}, members...)
+	sort.Strings(sorted)
+
+	digest := sha256.Sum256([]byte(strings.Join(sorted, "\n")))
+	return IPSet{
+		Name:    fmt.Sprintf("%s%x", IPSetPrefix, digest[:8]),
+		Members: sorted,
+	}
+}
+
+// NewFQDNIPSet names the set after a digest of the DNS name, so containers
+// with the same name share one set while its addresses come and go.
+func NewFQDNIPSet(fqdn string, addresses []string, timeout int) IPSet {
+	digest := sha256.Sum256([]byte("fqdn\n" + strings.ToLower(fqdn)))
+	return IPSet{
+		Name:    fmt.Sprintf("%s%x", IPSetPrefix, digest[:8]),
+		Members: addresses,
+		Timeout: timeout,
+	}
+}
+
+// MatchedIPSets returns the silk ipsets referenced by rules listed in
+// iptables -S format.
+func MatchedIPSets(rules []string) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, rule := range rules {
+		for _, matches := range matchSetRegex.FindAllStringSubmatch(rule, -1) {
+			name := matches[1]
+			if strings.HasPrefix(name, IPSetPrefix) && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+//go:generate counterfeiter -o ../fakes/ipset_adapter.go --fake-name IPSetAdapter . IPSetAdapter
+type IPSetAdapter interface {
+	Ensure(set IPSet) error
+	Destroy(names ...string) error
+}
+
+type IPSets struct{}
+
+// Ensure creates the set unless it already exists and adds its members.
+// Adding a member again restarts its timeout.
+func (s *IPSets) Ensure(set IPSet) error {
+	timeout := ""
+	if set.Timeout > 0 {
+		timeout = fmt.Sprintf(" timeout %d", set.Timeout)
+	}
+
+	memberTimeout := timeout
+	if set.Timeout > 0 && set.MemberTimeout > 0 {
+		memberTimeout = fmt.Sprintf(" timeout %d", set.MemberTimeout)
+	}
+
+	input := []string{fmt.Sprintf("create %s hash:net family inet%s\n", set.Name, timeout)}
+	for _, member := range set.Members {
+		input = append(input, fmt.Sprintf("add %s %s%s\n", set.Name, member, memberTimeout))
+	}
+
+	return runIPSet(strings.Join(input, ""), "restore", "-exist")
+}
+
+// Destroy destroys the sets. Sets that are gone or still referenced by
+// another container's rules are skipped.
+func (s *IPSets) Destroy(names ...string) error {
+	var result error
+	for _, name := range names {
+		err := runIPSet("", "destroy", name)
+		if err == nil {
+			continue
+		}
+		if strings.Contains(err.Error(), "in use by a kernel component") || strings.Contains(err.Error(), "does not exist") {
+			continue
+		}
+		result = multierror.Append(result, err)
+	}
+	return result
+}
+
+// LockedIPSets takes the iptables lock around every call, so that a set is
+// never destroyed between another process creating it and restoring the
+// rules that match it.
+type LockedIPSets struct {
+	IPSets IPSetAdapter
+	Locker locker
+}
+
+func (s *LockedIPSets) Ensure(set IPSet) error {
+	if err := s.Locker.Lock(); err != nil {
+		return fmt.Errorf("lock: %s", err)
+	}
+
+	if err := s.IPSets.Ensure(set); err != nil {
+		return handleIPTablesError(err, s.Locker.Unlock())
+	}
+
+	return s.Locker.Unlock()
+}
+
+func (s *LockedIPSets) Destroy(names ...string) error {
+	if err := s.Locker.Lock(); err != nil {
+		return fmt.Errorf("lock: %s", err)
+	}
+
+	if err := s.IPSets.Destroy(names...); err != nil {
+		return handleIPTablesError(err, s.Locker.Unlock())
+	}
+
+	return s.Locker.Unlock()
+}
+
+func runIPSet(input string, args ...string) error {
+	cmd := exec.Command("ipset", args...)
+	cmd.Stdin = strings.NewReader(input)
+
+	bytes, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ipset %s error: %s combined output: %s", args[0], err, string(bytes))
+	}
+	return nil
+}
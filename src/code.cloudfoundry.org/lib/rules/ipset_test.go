@@ -0,0 +1,137 @@
+package rules_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/lib/fakes"
+	"code.cloudfoundry.org/lib/rules"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("IPSets", func() {
+	Describe("NewIPSet", func() {
+		It("sorts the members and names the set after them", func() {
+			set := rules.NewIPSet([]string{"3.3.3.3-4.4.4.4", "1.1.1.1"})
+
+			Expect(set.Members).To(Equal([]string{"1.1.1.1", "3.3.3.3-4.4.4.4"}))
+			Expect(set.Name).To(MatchRegexp(`^silk-[0-9a-f]{16}$`))
+		})
+
+		It("gives the same members the same name regardless of order", func() {
+			Expect(rules.NewIPSet([]string{"1.1.1.1", "2.2.2.2"}).Name).To(Equal(rules.NewIPSet([]string{"2.2.2.2", "1.1.1.1"}).Name))
+			Expect(rules.NewIPSet([]string{"1.1.1.1", "2.2.2.2"}).Name).NotTo(Equal(rules.NewIPSet([]string{"1.1.1.1", "2.2.2.3"}).Name))
+		})
+
+		It("keeps the name within the ipset name limit", func() {
+			Expect(len(rules.NewIPSet([]string{"1.1.1.1"}).Name)).To(BeNumerically("<=", 31))
+		})
+	})
+
+	Describe("NewFQDNIPSet", func() {
+		It("names the set after the DNS name", func() {
+			set := rules.NewFQDNIPSet("Example.com", []string{"1.1.1.1"}, 300)
+
+			Expect(set.Name).To(MatchRegexp(`^silk-[0-9a-f]{16}$`))
+			Expect(set.Name).To(Equal(rules.NewFQDNIPSet("example.com", nil, 300).Name))
+			Expect(set.Name).NotTo(Equal(rules.NewFQDNIPSet("example.org", nil, 300).Name))
+			Expect(set.Members).To(Equal([]string{"1.1.1.1"}))
+			Expect(set.Timeout).To(Equal(300))
+		})
+	})
+
+	Describe("MatchedIPSets", func() {
+		It("returns each silk ipset matched by the rules once", func() {
+			Expect(rules.MatchedIPSets([]string{
+				"-N netout--handle",
+				"-A netout--handle -m set --match-set silk-abc dst -j ACCEPT",
+				"-A netout--handle -m set --match-set silk-abc dst -p tcp -m tcp --dport 80 -j ACCEPT",
+				"-A netout--handle -m set --match-set other dst -j ACCEPT",
+				"-A netout--handle -m set --match-set silk-def dst -g netout--handle--log",
+			})).To(Equal([]string{"silk-abc", "silk-def"}))
+		})
+	})
+
+	Describe("ValidateIPSets", func() {
+		It("allows ipsets with the iptables backend", func() {
+			Expect(rules.ValidateIPSets("", true)).To(Succeed())
+			Expect(rules.ValidateIPSets("iptables", true)).To(Succeed())
+			Expect(rules.ValidateIPSets("nftables", false)).To(Succeed())
+		})
+
+		It("rejects ipsets with the nftables backend", func() {
+			Expect(rules.ValidateIPSets("nftables", true)).To(MatchError("ASG ipsets require the iptables firewall backend"))
+		})
+	})
+
+	Describe("set rules", func() {
+		It("matches the set as the destination", func() {
+			Expect(rules.NewNetOutSetWithPortsRule("silk-abc", 80, 90, "tcp")).To(Equal(rules.IPTablesRule{
+				"-p", "tcp", "-m", "tcp", "--dport", "80:90",
+				"-m", "set", "--match-set", "silk-abc", "dst",
+				"-j", "ACCEPT",
+			}))
+			Expect(rules.NewNetOutSetICMPLogRule("silk-abc", 8, 0, "some-log-chain")).To(Equal(rules.IPTablesRule{
+				"-p", "icmp", "-m", "icmp", "--icmp-type", "8/0",
+				"-m", "set", "--match-set", "silk-abc", "dst",
+				"-g", "some-log-chain",
+			}))
+		})
+	})
+
+	Describe("LockedIPSets", func() {
+		var (
+			ipSets       *fakes.IPSetAdapter
+			lock         *fakes.Locker
+			lockedIPSets *rules.LockedIPSets
+			lockedCalls  []bool
+		)
+
+		BeforeEach(func() {
+			ipSets = &fakes.IPSetAdapter{}
+			lock = &fakes.Locker{}
+			lockedIPSets = &rules.LockedIPSets{IPSets: ipSets, Locker: lock}
+
+			lockedCalls = nil
+			record := func() {
+				lockedCalls = append(lockedCalls, lock.LockCallCount() > lock.UnlockCallCount())
+			}
+			ipSets.EnsureStub = func(rules.IPSet) error { record(); return nil }
+			ipSets.DestroyStub = func(...string) error { record(); return nil }
+		})
+
+		It("holds the lock while ensuring and destroying sets", func() {
+			Expect(lockedIPSets.Ensure(rules.IPSet{Name: "silk-abc"})).To(Succeed())
+			Expect(lockedIPSets.Destroy("silk-abc", "silk-def")).To(Succeed())
+
+			Expect(ipSets.EnsureArgsForCall(0)).To(Equal(rules.IPSet{Name: "silk-abc"}))
+			Expect(ipSets.DestroyArgsForCall(0)).To(Equal([]string{"silk-abc", "silk-def"}))
+			Expect(lockedCalls).To(Equal([]bool{true, true}))
+			Expect(lock.UnlockCallCount()).To(Equal(2))
+		})
+
+		Context("when the lock fails", func() {
+			BeforeEach(func() {
+				lock.LockReturns(errors.New("banana"))
+			})
+
+			It("does not touch the sets", func() {
+				Expect(lockedIPSets.Destroy("silk-abc")).To(MatchError("lock: banana"))
+				Expect(ipSets.DestroyCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when destroying fails", func() {
+			BeforeEach(func() {
+				ipSets.DestroyStub = nil
+				ipSets.DestroyReturns(errors.New("potato"))
+			})
+
+			It("unlocks and returns the error", func() {
+				Expect(lockedIPSets.Destroy("silk-abc")).To(MatchError("iptables call: potato and unlock: <nil>"))
+				Expect(lock.UnlockCallCount()).To(Equal(1))
+			})
+		})
+	})
+})
@@ -26,9 +26,11 @@ type iptables interface {
 //go:generate counterfeiter -o ../fakes/iptables_extended.go --fake-name IPTablesAdapter . IPTablesAdapter
 type IPTablesAdapter interface {
 	FlushAndRestore(rawInput string) error
+	ApplyTransaction(tx *RestoreTransaction) error
 	Exists(table, chain string, rulespec IPTablesRule) (bool, error)
 	ChainExists(table, chain string) (bool, error)
 	Delete(table, chain string, rulespec IPTablesRule) error
+	DeleteRuleNum(table, chain string, ruleNum int) error
 	DeleteAfterRuleNum(table, chain string, ruleNum int) error
 	DeleteAfterRuleNumKeepReject(table, chain string, ruleNum int) error
 	List(table, chain string) ([]string, error)
@@ -82,6 +84,9 @@ type LockedIPTables struct {
 	Locker         locker
 	Restorer       restorer
 	IPTablesRunner commandRunner
+	// IPSets creates the sets a transaction ensures. It runs while the lock
+	// is held, so it must not take the lock itself.
+	IPSets IPSetAdapter
 }
 
 func handleIPTablesError(err1, err2 error) error {
@@ -101,6 +106,32 @@ func (l *LockedIPTables) FlushAndRestore(rawInput string) error {
 	return l.Locker.Unlock()
 }
 
+// ApplyTransaction applies every table of the transaction with a single
+// iptables-restore --noflush call, so none of it is applied on failure. The
+// ipsets of the transaction are ensured under the same lock.
+func (l *LockedIPTables) ApplyTransaction(tx *RestoreTransaction) error {
+	if len(tx.IPSets()) > 0 && l.IPSets == nil {
+		return fmt.Errorf("transaction ensures ipsets but no ipset adapter is configured")
+	}
+
+	if err := l.Locker.Lock(); err != nil {
+		return fmt.Errorf("lock: %s", err)
+	}
+
+	for _, set := range tx.IPSets() {
+		if err := l.IPSets.Ensure(set); err != nil {
+			return handleIPTablesError(fmt.Errorf("ensure ipset %s: %s", set.Name, err), l.Locker.Unlock())
+		}
+	}
+
+	err := l.Restorer.Restore(tx.String())
+	if err != nil {
+		return handleIPTablesError(err, l.Locker.Unlock())
+	}
+
+	return l.Locker.Unlock()
+}
+
 func (l *LockedIPTables) Exists(table, chain string, rulespec IPTablesRule) (bool, error) {
 	if err := l.Locker.Lock(); err != nil {
 		return false, fmt.Errorf("lock: %s", err)
@@ -172,6 +203,19 @@ func (l *LockedIPTables) Delete(table, chain string, rulespec IPTablesRule) erro
 	return l.Locker.Unlock()
 }
 
+func (l *LockedIPTables) DeleteRuleNum(table, chain string, ruleNum int) error {
+	if err := l.Locker.Lock(); err != nil {
+		return fmt.Errorf("lock: %s", err)
+	}
+
+	err := l.IPTables.Delete(table, chain, fmt.Sprintf("%d", ruleNum), "--wait")
+	if err != nil {
+		return handleIPTablesError(err, l.Locker.Unlock())
+	}
+
+	return l.Locker.Unlock()
+}
+
 func (l *LockedIPTables) DeleteAfterRuleNum(table, chain string, ruleNum int) error {
 	if err := l.Locker.Lock(); err != nil {
 		return fmt.Errorf("lock: %s", err)
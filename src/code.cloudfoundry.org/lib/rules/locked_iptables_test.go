@@ -53,8 +53,8 @@ var _ = Describe("LockedIptables", func() {
 			Expect(restorer.RestoreCallCount()).To(Equal(1))
 			restoreInput := restorer.RestoreArgsForCall(0)
 			Expect(restoreInput).To(ContainSubstring("*some-table\n"))
-			Expect(restoreInput).To(ContainSubstring("-I some-chain 1 --source 1.2.3.4 --jump MARK --set-xmark 0xA -m comment --comment src:a-guid\n"))
-			Expect(restoreInput).To(ContainSubstring("-I some-chain 1 --source 2.2.2.2 --jump MARK --set-xmark 0xB -m comment --comment src:b-guid\n"))
+			Expect(restoreInput).To(ContainSubstring("-I some-chain 1 -s 1.2.3.4 -m comment --comment src:a-guid -j MARK --set-xmark 0xa\n"))
+			Expect(restoreInput).To(ContainSubstring("-I some-chain 1 -s 2.2.2.2 -m comment --comment src:b-guid -j MARK --set-xmark 0xb\n"))
 			Expect(restoreInput).To(ContainSubstring("COMMIT\n"))
 		})
 		Context("when the lock fails", func() {
@@ -116,8 +116,8 @@ var _ = Describe("LockedIptables", func() {
 			Expect(restorer.RestoreCallCount()).To(Equal(1))
 			restoreInput := restorer.RestoreArgsForCall(0)
 			Expect(restoreInput).To(ContainSubstring("*some-table\n"))
-			Expect(restoreInput).To(ContainSubstring("-A some-chain --source 1.2.3.4 --jump MARK --set-xmark 0xA -m comment --comment src:a-guid\n"))
-			Expect(restoreInput).To(ContainSubstring("-A some-chain --source 2.2.2.2 --jump MARK --set-xmark 0xB -m comment --comment src:b-guid\n"))
+			Expect(restoreInput).To(ContainSubstring("-A some-chain -s 1.2.3.4 -m comment --comment src:a-guid -j MARK --set-xmark 0xa\n"))
+			Expect(restoreInput).To(ContainSubstring("-A some-chain -s 2.2.2.2 -m comment --comment src:b-guid -j MARK --set-xmark 0xb\n"))
 			Expect(restoreInput).To(ContainSubstring("COMMIT\n"))
 		})
 		Context("when the lock fails", func() {
@@ -161,6 +161,108 @@ var _ = Describe("LockedIptables", func() {
 		})
 	})
 
+	Describe("ApplyTransaction", func() {
+		var tx *rules.RestoreTransaction
+		BeforeEach(func() {
+			tx = &rules.RestoreTransaction{}
+			tx.NewChain("filter", "some-chain")
+			tx.Append("filter", "FORWARD", rules.IPTablesRule{"--jump", "some-chain"})
+			tx.Append("filter", "some-chain", rules.NewAcceptRule())
+			tx.NewChain("nat", "other-chain")
+			tx.Append("filter", "some-chain", rules.IPTablesRule{"-j", "LOG", "--log-prefix", `"OK_some-guid "`})
+		})
+
+		It("applies every table in a single noflush restore", func() {
+			err := lockedIPT.ApplyTransaction(tx)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(lock.LockCallCount()).To(Equal(1))
+			Expect(lock.UnlockCallCount()).To(Equal(1))
+			Expect(restorer.RestoreCallCount()).To(Equal(1))
+			Expect(restorer.RestoreArgsForCall(0)).To(Equal("*filter\n" +
+				"-N some-chain\n" +
+				"-A FORWARD --jump some-chain\n" +
+				"-A some-chain -j ACCEPT\n" +
+				"-A some-chain -j LOG --log-prefix \"OK_some-guid \"\n" +
+				"COMMIT\n" +
+				"*nat\n" +
+				"-N other-chain\n" +
+				"COMMIT\n"))
+		})
+
+		Context("when the transaction ensures ipsets", func() {
+			var ipSets *fakes.IPSetAdapter
+
+			BeforeEach(func() {
+				ipSets = &fakes.IPSetAdapter{}
+				lockedIPT.IPSets = ipSets
+				tx.EnsureIPSet(rules.IPSet{Name: "silk-abc", Members: []string{"1.1.1.1"}})
+			})
+
+			It("ensures them under the lock before restoring the rules", func() {
+				ipSets.EnsureStub = func(rules.IPSet) error {
+					Expect(lock.LockCallCount()).To(Equal(1))
+					Expect(lock.UnlockCallCount()).To(Equal(0))
+					Expect(restorer.RestoreCallCount()).To(Equal(0))
+					return nil
+				}
+
+				Expect(lockedIPT.ApplyTransaction(tx)).To(Succeed())
+				Expect(ipSets.EnsureCallCount()).To(Equal(1))
+				Expect(ipSets.EnsureArgsForCall(0)).To(Equal(rules.IPSet{Name: "silk-abc", Members: []string{"1.1.1.1"}}))
+				Expect(restorer.RestoreCallCount()).To(Equal(1))
+				Expect(lock.UnlockCallCount()).To(Equal(1))
+			})
+
+			Context("when ensuring a set fails", func() {
+				BeforeEach(func() {
+					ipSets.EnsureReturns(errors.New("potato"))
+				})
+
+				It("does not restore the rules", func() {
+					err := lockedIPT.ApplyTransaction(tx)
+					Expect(err).To(MatchError("iptables call: ensure ipset silk-abc: potato and unlock: <nil>"))
+					Expect(restorer.RestoreCallCount()).To(Equal(0))
+					Expect(lock.UnlockCallCount()).To(Equal(1))
+				})
+			})
+
+			Context("when there is no ipset adapter", func() {
+				BeforeEach(func() {
+					lockedIPT.IPSets = nil
+				})
+
+				It("returns an error without taking the lock", func() {
+					err := lockedIPT.ApplyTransaction(tx)
+					Expect(err).To(MatchError("transaction ensures ipsets but no ipset adapter is configured"))
+					Expect(lock.LockCallCount()).To(Equal(0))
+				})
+			})
+		})
+
+		Context("when the lock fails", func() {
+			BeforeEach(func() {
+				lock.LockReturns(errors.New("banana"))
+			})
+			It("should return an error", func() {
+				err := lockedIPT.ApplyTransaction(tx)
+				Expect(err).To(MatchError("lock: banana"))
+				Expect(restorer.RestoreCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the restorer fails and then the unlock fails", func() {
+			BeforeEach(func() {
+				lock.UnlockReturns(errors.New("banana"))
+				restorer.RestoreReturns(fmt.Errorf("patato"))
+			})
+			It("should return an error", func() {
+				err := lockedIPT.ApplyTransaction(tx)
+				Expect(err).To(MatchError("iptables call: patato and unlock: banana"))
+			})
+		})
+	})
+
 	Describe("Exists", func() {
 		BeforeEach(func() {
 			ipt.ExistsReturns(true, nil)
@@ -211,6 +313,30 @@ var _ = Describe("LockedIptables", func() {
 		})
 	})
 
+	Describe("DeleteRuleNum", func() {
+		It("locks and deletes the rule by its number", func() {
+			err := lockedIPT.DeleteRuleNum("some-table", "some-chain", 3)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(lock.LockCallCount()).To(Equal(1))
+			Expect(lock.UnlockCallCount()).To(Equal(1))
+			Expect(ipt.DeleteCallCount()).To(Equal(1))
+			table, chain, ruleNum := ipt.DeleteArgsForCall(0)
+			Expect(table).To(Equal("some-table"))
+			Expect(chain).To(Equal("some-chain"))
+			Expect(ruleNum).To(Equal([]string{"3", "--wait"}))
+		})
+		Context("when iptables delete fails", func() {
+			BeforeEach(func() {
+				ipt.DeleteReturns(errors.New("banana"))
+			})
+			It("returns an error", func() {
+				err := lockedIPT.DeleteRuleNum("some-table", "some-chain", 3)
+				Expect(err).To(MatchError("iptables call: banana and unlock: <nil>"))
+			})
+		})
+	})
+
 	Describe("DeleteAfterRuleNum", func() {
 		BeforeEach(func() {
 			ipt.ListReturns([]string{"-N some-chain", "-A some-chain rule-1", "-A some-chain rule-2", "-A some-chain rule-3"}, nil)
@@ -330,7 +456,7 @@ var _ = Describe("LockedIptables", func() {
 			table, chain, rules := ipt.AppendUniqueArgsForCall(0)
 			Expect(table).To(Equal("some-table"))
 			Expect(chain).To(Equal("some-chain"))
-			Expect(rules).To(Equal([]string{"--jump", "REJECT", "--reject-with", "icmp-port-unreachable"}))
+			Expect(rules).To(Equal([]string{"-j", "REJECT", "--reject-with", "icmp-port-unreachable"}))
 		})
 
 		Context("when locking fails", func() {
@@ -0,0 +1,951 @@
+package rules
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/cf-networking-helpers/runner"
+	"github.com/google/shlex"
+	"sigs.k8s.io/knftables"
+)
+
+const (
+	IPTablesBackend = "iptables"
+	NFTablesBackend = "nftables"
+
+	// NFTablesTablePrefix is prepended to the iptables table names to name
+	// the nftables tables that hold silk's rules.
+	NFTablesTablePrefix = "silk_"
+
+	// ChainDoesNotExistErrorText matches the error iptables reports for a
+	// missing chain so that callers can treat both backends the same way.
+	ChainDoesNotExistErrorText = "No chain/target/match by that name."
+)
+
+// ValidateBackend returns an error unless backend names a supported
+// firewall backend. An empty backend selects iptables.
+func ValidateBackend(backend string) error {
+	switch backend {
+	case "", IPTablesBackend, NFTablesBackend:
+		return nil
+	default:
+		return fmt.Errorf("invalid firewall backend %q: must be %s or %s", backend, IPTablesBackend, NFTablesBackend)
+	}
+}
+
+type baseChain struct {
+	name     string
+	hook     knftables.BaseChainHook
+	kind     knftables.BaseChainType
+	priority knftables.BaseChainPriority
+}
+
+var nftTables = []string{"filter", "nat", "mangle"}
+
+var nftBaseChains = map[string][]baseChain{
+	"filter": {
+		{"INPUT", knftables.InputHook, knftables.FilterType, knftables.FilterPriority},
+		{"FORWARD", knftables.ForwardHook, knftables.FilterType, knftables.FilterPriority},
+		{"OUTPUT", knftables.OutputHook, knftables.FilterType, knftables.FilterPriority},
+	},
+	"nat": {
+		{"PREROUTING", knftables.PreroutingHook, knftables.NATType, knftables.DNATPriority},
+		{"INPUT", knftables.InputHook, knftables.NATType, knftables.SNATPriority},
+		{"OUTPUT", knftables.OutputHook, knftables.NATType, knftables.DNATPriority},
+		{"POSTROUTING", knftables.PostroutingHook, knftables.NATType, knftables.SNATPriority},
+	},
+	"mangle": {
+		{"PREROUTING", knftables.PreroutingHook, knftables.FilterType, knftables.ManglePriority},
+		{"INPUT", knftables.InputHook, knftables.FilterType, knftables.ManglePriority},
+		{"FORWARD", knftables.ForwardHook, knftables.FilterType, knftables.ManglePriority},
+		{"OUTPUT", knftables.OutputHook, knftables.RouteType, knftables.ManglePriority},
+		{"POSTROUTING", knftables.PostroutingHook, knftables.FilterType, knftables.ManglePriority},
+	},
+}
+
+// NFTables implements IPTablesAdapter on top of nftables. Every iptables
+// table is rendered into its own nftables table and the iptables spec of
+// each rule is kept in the rule's comment.
+type NFTables struct {
+	Tables    map[string]knftables.Interface
+	Locker    locker
+	NFTRunner commandRunner
+}
+
+func NewNFTables(locker locker) (*NFTables, error) {
+	nftRunner, err := runner.NewCommandRunner("nft", true)
+	if err != nil {
+		return nil, err
+	}
+
+	tables := map[string]knftables.Interface{}
+	for _, table := range nftTables {
+		nft, err := knftables.New(knftables.IPv4Family, NFTablesTablePrefix+table)
+		if err != nil {
+			return nil, fmt.Errorf("nftables table %s: %s", table, err)
+		}
+		tables[table] = nft
+	}
+
+	return &NFTables{
+		Tables:    tables,
+		Locker:    locker,
+		NFTRunner: nftRunner,
+	}, nil
+}
+
+func handleNFTablesError(err1, err2 error) error {
+	return fmt.Errorf("nftables call: %+v and unlock: %+v", err1, err2)
+}
+
+func chainDoesNotExist(table, chain string) error {
+	return fmt.Errorf("chain %s in table %s: %s", chain, table, ChainDoesNotExistErrorText)
+}
+
+func (n *NFTables) locked(action func() error) error {
+	if err := n.Locker.Lock(); err != nil {
+		return fmt.Errorf("lock: %s", err)
+	}
+
+	if err := action(); err != nil {
+		return handleNFTablesError(err, n.Locker.Unlock())
+	}
+
+	return n.Locker.Unlock()
+}
+
+func (n *NFTables) table(table string) (knftables.Interface, error) {
+	nft, ok := n.Tables[table]
+	if !ok {
+		return nil, fmt.Errorf("unsupported table %s", table)
+	}
+	return nft, nil
+}
+
+func findBaseChain(table, chain string) (baseChain, bool) {
+	for _, c := range nftBaseChains[table] {
+		if c.name == chain {
+			return c, true
+		}
+	}
+	return baseChain{}, false
+}
+
+// transaction starts a transaction that creates the table and the given
+// built-in chains if they do not exist yet, matching iptables where they
+// always exist.
+func (n *NFTables) transaction(table string, chains ...string) (knftables.Interface, *knftables.Transaction, error) {
+	nft, err := n.table(table)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tx := nft.NewTransaction()
+	tx.Add(&knftables.Table{})
+	for _, chain := range chains {
+		if c, ok := findBaseChain(table, chain); ok {
+			tx.Add(&knftables.Chain{
+				Name:     c.name,
+				Type:     knftables.PtrTo(c.kind),
+				Hook:     knftables.PtrTo(c.hook),
+				Priority: knftables.PtrTo(c.priority),
+			})
+		}
+	}
+	return nft, tx, nil
+}
+
+func (n *NFTables) listRules(table, chain string) ([]*knftables.Rule, error) {
+	nft, err := n.table(table)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := nft.ListRules(context.Background(), chain)
+	if knftables.IsNotFound(err) {
+		if _, ok := findBaseChain(table, chain); ok {
+			return nil, nil
+		}
+		return nil, chainDoesNotExist(table, chain)
+	}
+	return rules, err
+}
+
+func (n *NFTables) listChains(table string) ([]string, error) {
+	nft, err := n.table(table)
+	if err != nil {
+		return nil, err
+	}
+
+	chains, err := nft.List(context.Background(), "chains")
+	if knftables.IsNotFound(err) {
+		return nil, nil
+	}
+	return chains, err
+}
+
+func newNFTRule(chain string, spec IPTablesRule) (*knftables.Rule, error) {
+	rule, err := translateRule(spec)
+	if err != nil {
+		return nil, fmt.Errorf("translate rule %v: %s", spec, err)
+	}
+
+	return &knftables.Rule{
+		Chain:   chain,
+		Rule:    rule,
+		Comment: knftables.PtrTo(ruleKey(spec)),
+	}, nil
+}
+
+func findRuleByKey(rules []*knftables.Rule, key string) *knftables.Rule {
+	for _, r := range rules {
+		if r.Comment != nil && *r.Comment == key {
+			return r
+		}
+	}
+	return nil
+}
+
+func (n *NFTables) FlushAndRestore(rawInput string) error {
+	return n.locked(func() error {
+		return n.restore(rawInput)
+	})
+}
+
+// ApplyTransaction runs one nft transaction per table. Every rule is
+// translated before any table is changed, so an untranslatable rule leaves
+// all tables untouched. nft cannot change several tables atomically, so when
+// a table fails the tables applied before it are undone. Undoing removes the
+// rules and chains the transaction added, but does not bring back the rules
+// it deleted.
+func (n *NFTables) ApplyTransaction(tx *RestoreTransaction) error {
+	if len(tx.IPSets()) > 0 {
+		return fmt.Errorf("ipsets are not supported by the nftables backend")
+	}
+	return n.locked(func() error {
+		type pending struct {
+			table    string
+			nft      knftables.Interface
+			tx       *knftables.Transaction
+			existing map[string][]*knftables.Rule
+		}
+
+		var transactions []pending
+		for _, table := range tx.Tables() {
+			var chains []string
+			for _, entry := range tx.entries[table] {
+				chains = append(chains, entry.chain)
+			}
+
+			nft, nftTx, err := n.transaction(table, chains...)
+			if err != nil {
+				return err
+			}
+
+			positioned := map[string][]restoreEntry{}
+			var positionedChains []string
+			for _, entry := range tx.entries[table] {
+				switch entry.command {
+				case "-N":
+					nftTx.Create(&knftables.Chain{Name: entry.chain})
+				case "-A":
+					rule, err := newNFTRule(entry.chain, entry.rule)
+					if err != nil {
+						return err
+					}
+					nftTx.Add(rule)
+				default:
+					if _, ok := positioned[entry.chain]; !ok {
+						positionedChains = append(positionedChains, entry.chain)
+					}
+					positioned[entry.chain] = append(positioned[entry.chain], entry)
+				}
+			}
+			for _, chain := range positionedChains {
+				if err := n.stagePositionedChanges(table, chain, positioned[chain], tx, nftTx); err != nil {
+					return err
+				}
+			}
+			transactions = append(transactions, pending{table: table, nft: nft, tx: nftTx})
+		}
+
+		for i := range transactions {
+			t := &transactions[i]
+			t.existing = map[string][]*knftables.Rule{}
+			for _, entry := range tx.entries[t.table] {
+				if entry.command != "-N" && !slices.Contains(tx.Chains(t.table), entry.chain) {
+					t.existing[entry.chain], _ = t.nft.ListRules(context.Background(), entry.chain)
+				}
+			}
+		}
+
+		for i, t := range transactions {
+			if err := t.nft.Run(context.Background(), t.tx); err != nil {
+				err = fmt.Errorf("apply table %s: %s", t.table, err)
+				for j := i - 1; j >= 0; j-- {
+					applied := transactions[j]
+					if undoErr := undoTable(applied.nft, tx.Chains(applied.table), applied.existing); undoErr != nil {
+						return fmt.Errorf("%s: undo table %s: %s", err, applied.table, undoErr)
+					}
+				}
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// stagePositionedChanges turns the rule number based inserts and deletes of
+// a chain into changes relative to the handles of the rules the chain has
+// now. Inserted rules are placed before the next rule that is kept, or
+// appended when there is none.
+func (n *NFTables) stagePositionedChanges(table, chain string, entries []restoreEntry, tx *RestoreTransaction, nftTx *knftables.Transaction) error {
+	if slices.Contains(tx.Chains(table), chain) || len(tx.Rules(table, chain)) > 0 {
+		return fmt.Errorf("chain %s in table %s is created or appended to in the same transaction as it is changed by rule number", chain, table)
+	}
+
+	existing, err := n.listRules(table, chain)
+	if err != nil {
+		return err
+	}
+
+	// slots simulates the chain. Rules that are already there have a
+	// handle, rules that are inserted do not.
+	type slot struct {
+		handle *int
+		rule   *knftables.Rule
+	}
+	var slots []slot
+	for _, rule := range existing {
+		slots = append(slots, slot{handle: rule.Handle})
+	}
+
+	for _, entry := range entries {
+		index := entry.pos - 1
+		if entry.command == "-D" {
+			if index < 0 || index >= len(slots) {
+				return fmt.Errorf("delete rule %d from chain %s in table %s: Index of deletion too big", entry.pos, chain, table)
+			}
+			if slots[index].handle != nil {
+				nftTx.Delete(&knftables.Rule{Chain: chain, Handle: slots[index].handle})
+			}
+			slots = slices.Delete(slots, index, index+1)
+			continue
+		}
+
+		if index < 0 || index > len(slots) {
+			return fmt.Errorf("insert into chain %s in table %s: Index of insertion too big", chain, table)
+		}
+		rule, err := newNFTRule(chain, entry.rule)
+		if err != nil {
+			return err
+		}
+		slots = slices.Insert(slots, index, slot{rule: rule})
+	}
+
+	anchors := make([]*int, len(slots))
+	var next *int
+	for i := len(slots) - 1; i >= 0; i-- {
+		if slots[i].handle != nil {
+			next = slots[i].handle
+			continue
+		}
+		anchors[i] = next
+	}
+	for i, s := range slots {
+		if s.rule == nil {
+			continue
+		}
+		if anchors[i] == nil {
+			nftTx.Add(s.rule)
+			continue
+		}
+		s.rule.Handle = anchors[i]
+		nftTx.Insert(s.rule)
+	}
+	return nil
+}
+
+// undoTable removes the chains an applied transaction created and the rules
+// it appended to chains that existed before, which are the rules that were
+// not listed in existing.
+func undoTable(nft knftables.Interface, created []string, existing map[string][]*knftables.Rule) error {
+	undo := nft.NewTransaction()
+	for chain, before := range existing {
+		rules, err := nft.ListRules(context.Background(), chain)
+		if err != nil {
+			return err
+		}
+		for _, rule := range rules {
+			if rule.Handle != nil && findRuleByHandle(before, *rule.Handle) == nil {
+				undo.Delete(&knftables.Rule{Chain: chain, Handle: rule.Handle})
+			}
+		}
+	}
+	for _, chain := range created {
+		undo.Flush(&knftables.Chain{Name: chain})
+		undo.Delete(&knftables.Chain{Name: chain})
+	}
+	return nft.Run(context.Background(), undo)
+}
+
+func findRuleByHandle(rules []*knftables.Rule, handle int) *knftables.Rule {
+	for _, r := range rules {
+		if r.Handle != nil && *r.Handle == handle {
+			return r
+		}
+	}
+	return nil
+}
+
+type restoreSection struct {
+	table string
+	lines []string
+}
+
+func parseRestoreInput(rawInput string) ([]*restoreSection, error) {
+	var sections []*restoreSection
+	var current *restoreSection
+
+	scanner := bufio.NewScanner(strings.NewReader(rawInput))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "*"):
+			current = &restoreSection{table: strings.TrimPrefix(line, "*")}
+		case line == "COMMIT":
+			if current == nil {
+				return nil, fmt.Errorf("COMMIT without table")
+			}
+			sections = append(sections, current)
+			current = nil
+		default:
+			if current == nil {
+				return nil, fmt.Errorf("line outside of table: %s", line)
+			}
+			current.lines = append(current.lines, line)
+		}
+	}
+	if current != nil {
+		return nil, fmt.Errorf("table %s is missing COMMIT", current.table)
+	}
+
+	return sections, nil
+}
+
+// restore replaces each table in the iptables-restore input in its own
+// transaction, which is how iptables-restore behaves without --noflush.
+func (n *NFTables) restore(rawInput string) error {
+	sections, err := parseRestoreInput(rawInput)
+	if err != nil {
+		return err
+	}
+
+	for _, section := range sections {
+		nft, tx, err := n.transaction(section.table)
+		if err != nil {
+			return err
+		}
+		tx.Delete(&knftables.Table{})
+		tx.Add(&knftables.Table{})
+		if err := restoreSectionInto(tx, section); err != nil {
+			return err
+		}
+		if err := nft.Run(context.Background(), tx); err != nil {
+			return fmt.Errorf("restore table %s: %s", section.table, err)
+		}
+	}
+
+	return nil
+}
+
+func restoreSectionInto(tx *knftables.Transaction, section *restoreSection) error {
+	addChain := func(chain string) {
+		if c, ok := findBaseChain(section.table, chain); ok {
+			tx.Add(&knftables.Chain{
+				Name:     c.name,
+				Type:     knftables.PtrTo(c.kind),
+				Hook:     knftables.PtrTo(c.hook),
+				Priority: knftables.PtrTo(c.priority),
+			})
+			return
+		}
+		tx.Add(&knftables.Chain{Name: chain})
+	}
+
+	for _, line := range section.lines {
+		if strings.HasPrefix(line, ":") {
+			fields := strings.Fields(strings.TrimPrefix(line, ":"))
+			if len(fields) < 2 {
+				return fmt.Errorf("invalid chain line: %s", line)
+			}
+			if fields[1] != "ACCEPT" && fields[1] != "-" {
+				return fmt.Errorf("unsupported policy %s for chain %s", fields[1], fields[0])
+			}
+			addChain(fields[0])
+			continue
+		}
+
+		args, err := shlex.Split(line)
+		if err != nil {
+			return fmt.Errorf("parse line %s: %s", line, err)
+		}
+		if len(args) < 2 {
+			return fmt.Errorf("invalid line: %s", line)
+		}
+
+		command, chain, spec := args[0], args[1], args[2:]
+		switch command {
+		case "-N", "--new-chain":
+			addChain(chain)
+		case "-A", "--append":
+			addChain(chain)
+			rule, err := newNFTRule(chain, spec)
+			if err != nil {
+				return err
+			}
+			tx.Add(rule)
+		case "-I", "--insert":
+			addChain(chain)
+			if len(spec) > 0 {
+				if pos, err := strconv.Atoi(spec[0]); err == nil {
+					if pos != 1 {
+						return fmt.Errorf("unsupported insert position %d: %s", pos, line)
+					}
+					spec = spec[1:]
+				}
+			}
+			rule, err := newNFTRule(chain, spec)
+			if err != nil {
+				return err
+			}
+			tx.Insert(rule)
+		default:
+			return fmt.Errorf("unsupported command %s: %s", command, line)
+		}
+	}
+
+	return nil
+}
+
+func (n *NFTables) Exists(table, chain string, rulespec IPTablesRule) (bool, error) {
+	var exists bool
+	err := n.locked(func() error {
+		rules, err := n.listRules(table, chain)
+		if err != nil {
+			if strings.Contains(err.Error(), ChainDoesNotExistErrorText) {
+				return nil
+			}
+			return err
+		}
+
+		exists = findRuleByKey(rules, ruleKey(rulespec)) != nil
+		return nil
+	})
+	return exists, err
+}
+
+func (n *NFTables) ChainExists(table, chain string) (bool, error) {
+	if _, ok := findBaseChain(table, chain); ok {
+		return true, nil
+	}
+
+	var exists bool
+	err := n.locked(func() error {
+		chains, err := n.listChains(table)
+		if err != nil {
+			return err
+		}
+
+		for _, c := range chains {
+			if c == chain {
+				exists = true
+			}
+		}
+		return nil
+	})
+	return exists, err
+}
+
+func (n *NFTables) Delete(table, chain string, rulespec IPTablesRule) error {
+	return n.locked(func() error {
+		rules, err := n.listRules(table, chain)
+		if err != nil {
+			return err
+		}
+
+		rule := findRuleByKey(rules, ruleKey(rulespec))
+		if rule == nil {
+			return fmt.Errorf("delete rule %v from chain %s in table %s: Bad rule (does a matching rule exist in that chain?)", rulespec, chain, table)
+		}
+
+		nft, tx, err := n.transaction(table)
+		if err != nil {
+			return err
+		}
+		tx.Delete(&knftables.Rule{Chain: chain, Handle: rule.Handle})
+		return nft.Run(context.Background(), tx)
+	})
+}
+
+func (n *NFTables) DeleteRuleNum(table, chain string, ruleNum int) error {
+	return n.locked(func() error {
+		rules, err := n.listRules(table, chain)
+		if err != nil {
+			return err
+		}
+
+		// rule numbers are 1-indexed, like in iptables
+		if ruleNum < 1 || ruleNum > len(rules) {
+			return fmt.Errorf("delete rule %d from chain %s in table %s: Index of deletion too big", ruleNum, chain, table)
+		}
+
+		nft, tx, err := n.transaction(table)
+		if err != nil {
+			return err
+		}
+		tx.Delete(&knftables.Rule{Chain: chain, Handle: rules[ruleNum-1].Handle})
+		return nft.Run(context.Background(), tx)
+	})
+}
+
+func (n *NFTables) DeleteAfterRuleNum(table, chain string, ruleNum int) error {
+	return n.locked(func() error {
+		return n.deleteAfterRuleNum(table, chain, ruleNum, false)
+	})
+}
+
+func (n *NFTables) DeleteAfterRuleNumKeepReject(table, chain string, ruleNum int) error {
+	return n.locked(func() error {
+		return n.deleteAfterRuleNum(table, chain, ruleNum, true)
+	})
+}
+
+func (n *NFTables) deleteAfterRuleNum(table, chain string, ruleNum int, keepReject bool) error {
+	rules, err := n.listRules(table, chain)
+	if err != nil {
+		return err
+	}
+
+	nft, tx, err := n.transaction(table, chain)
+	if err != nil {
+		return err
+	}
+
+	// rule numbers are 1-indexed, like in iptables
+	kept := rules
+	if ruleNum-1 < len(rules) {
+		kept = rules[:ruleNum-1]
+		for _, rule := range rules[ruleNum-1:] {
+			tx.Delete(&knftables.Rule{Chain: chain, Handle: rule.Handle})
+		}
+	}
+
+	if keepReject && findRuleByKey(kept, ruleKey(NewInputDefaultRejectRule())) == nil {
+		reject, err := newNFTRule(chain, NewInputDefaultRejectRule())
+		if err != nil {
+			return err
+		}
+		tx.Add(reject)
+	}
+
+	return nft.Run(context.Background(), tx)
+}
+
+func (n *NFTables) List(table, chain string) ([]string, error) {
+	var lines []string
+	err := n.locked(func() error {
+		rules, err := n.listRules(table, chain)
+		if err != nil {
+			return err
+		}
+
+		if _, ok := findBaseChain(table, chain); ok {
+			lines = append(lines, fmt.Sprintf("-P %s ACCEPT", chain))
+		} else {
+			lines = append(lines, fmt.Sprintf("-N %s", chain))
+		}
+		for _, rule := range rules {
+			key := ""
+			if rule.Comment != nil {
+				key = *rule.Comment
+			}
+			lines = append(lines, strings.TrimSpace(fmt.Sprintf("-A %s %s", chain, key)))
+		}
+		return nil
+	})
+	return lines, err
+}
+
+func (n *NFTables) ListChains(table string) ([]string, error) {
+	var chains []string
+	err := n.locked(func() error {
+		existing, err := n.listChains(table)
+		if err != nil {
+			return err
+		}
+
+		var userChains []string
+		for _, chain := range existing {
+			if _, ok := findBaseChain(table, chain); !ok {
+				userChains = append(userChains, chain)
+			}
+		}
+		sort.Strings(userChains)
+
+		for _, c := range nftBaseChains[table] {
+			chains = append(chains, c.name)
+		}
+		chains = append(chains, userChains...)
+		return nil
+	})
+	return chains, err
+}
+
+func (n *NFTables) NewChain(table, chain string) error {
+	return n.locked(func() error {
+		nft, tx, err := n.transaction(table)
+		if err != nil {
+			return err
+		}
+		tx.Create(&knftables.Chain{Name: chain})
+		return nft.Run(context.Background(), tx)
+	})
+}
+
+func (n *NFTables) ClearChain(table, chain string) error {
+	return n.locked(func() error {
+		nft, tx, err := n.transaction(table, chain)
+		if err != nil {
+			return err
+		}
+		if _, ok := findBaseChain(table, chain); !ok {
+			tx.Add(&knftables.Chain{Name: chain})
+		}
+		tx.Flush(&knftables.Chain{Name: chain})
+		return nft.Run(context.Background(), tx)
+	})
+}
+
+func (n *NFTables) DeleteChain(table, chain string) error {
+	return n.locked(func() error {
+		nft, tx, err := n.transaction(table)
+		if err != nil {
+			return err
+		}
+		tx.Delete(&knftables.Chain{Name: chain})
+		err = nft.Run(context.Background(), tx)
+		if knftables.IsNotFound(err) {
+			return chainDoesNotExist(table, chain)
+		}
+		return err
+	})
+}
+
+// RenameChain renames the chain with the nft binary, which keeps the jumps
+// into the chain pointing at it, and then rewrites the comments of those
+// jumps so that they can still be found by their spec.
+func (n *NFTables) RenameChain(table, oldChain, newChain string) error {
+	return n.locked(func() error {
+		output, err := n.NFTRunner.CombinedOutput(runner.Command{
+			Args: []string{"rename", "chain", string(knftables.IPv4Family), NFTablesTablePrefix + table, oldChain, newChain},
+		})
+		if err != nil {
+			return fmt.Errorf("rename chain %s to %s: %s: %s", oldChain, newChain, err, strings.TrimSpace(string(output)))
+		}
+
+		nft, tx, err := n.transaction(table)
+		if err != nil {
+			return err
+		}
+
+		chains, err := n.listChains(table)
+		if err != nil {
+			return err
+		}
+		for _, chain := range chains {
+			rules, err := n.listRules(table, chain)
+			if err != nil {
+				return err
+			}
+			for _, rule := range rules {
+				if rule.Comment == nil {
+					continue
+				}
+				spec, err := specFromKey(*rule.Comment)
+				if err != nil {
+					if strings.HasSuffix(*rule.Comment, " "+oldChain) {
+						return fmt.Errorf("update reference to chain %s: %s", oldChain, err)
+					}
+					continue
+				}
+				target := ruleTarget(spec)
+				if target == nil || target[1] != oldChain {
+					continue
+				}
+				target[1] = newChain
+
+				replacement, err := newNFTRule(chain, spec)
+				if err != nil {
+					return err
+				}
+				replacement.Handle = rule.Handle
+				tx.Replace(replacement)
+			}
+		}
+
+		if tx.NumOperations() == 1 {
+			return nil
+		}
+		return nft.Run(context.Background(), tx)
+	})
+}
+
+func (n *NFTables) bulkAction(table, chain string, insertAt int, rulespec ...IPTablesRule) error {
+	return n.locked(func() error {
+		existing, err := n.listRules(table, chain)
+		if err != nil {
+			return err
+		}
+
+		nft, tx, err := n.transaction(table, chain)
+		if err != nil {
+			return err
+		}
+
+		count := len(existing)
+		index := insertAt - 1
+		if insertAt > 0 && index > count {
+			return fmt.Errorf("insert into chain %s in table %s: Index of insertion too big", chain, table)
+		}
+
+		for _, spec := range rulespec {
+			rule, err := newNFTRule(chain, spec)
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case insertAt <= 0 || index == count:
+				tx.Add(rule)
+			case index == 0:
+				tx.Insert(rule)
+			default:
+				rule.Index = knftables.PtrTo(index)
+				tx.Insert(rule)
+			}
+			count++
+		}
+
+		return nft.Run(context.Background(), tx)
+	})
+}
+
+// BulkInsert inserts every rule at pos, so like iptables-restore the rules
+// end up in reverse order.
+func (n *NFTables) BulkInsert(table, chain string, pos int, rulespec ...IPTablesRule) error {
+	return n.bulkAction(table, chain, pos, rulespec...)
+}
+
+func (n *NFTables) BulkAppend(table, chain string, rulespec ...IPTablesRule) error {
+	return n.bulkAction(table, chain, 0, rulespec...)
+}
+
+func (n *NFTables) AllowTrafficForRange(rulespec ...IPTablesRule) error {
+	return n.BulkInsert("filter", "FORWARD", 1, rulespec...)
+}
+
+// RuleCount counts the lines iptables -S would print for the table.
+func (n *NFTables) RuleCount(table string) (int, error) {
+	count := -1
+	err := n.locked(func() error {
+		nft, err := n.table(table)
+		if err != nil {
+			return err
+		}
+
+		chains, err := n.listChains(table)
+		if err != nil {
+			return err
+		}
+
+		rules, err := nft.ListRules(context.Background(), "")
+		if err != nil && !knftables.IsNotFound(err) {
+			return err
+		}
+
+		count = len(nftBaseChains[table]) + len(rules)
+		for _, chain := range chains {
+			if _, ok := findBaseChain(table, chain); !ok {
+				count++
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+// MigrateFromIPTables copies the state of every table that is not yet
+// managed in nftables out of iptables and then flushes it from iptables so
+// that packets are not filtered twice. Tables that already exist in
+// nftables are left alone, so this only has an effect on first start.
+func (n *NFTables) MigrateFromIPTables(saver commandRunner, restorer restorer) error {
+	return n.locked(func() error {
+		for _, table := range nftTables {
+			chains, err := n.listChains(table)
+			if err != nil {
+				return err
+			}
+			if len(chains) > 0 {
+				continue
+			}
+
+			output, err := saver.CombinedOutput(runner.Command{Args: []string{"-t", table}})
+			if err != nil {
+				return fmt.Errorf("iptables-save table %s: %s: %s", table, err, strings.TrimSpace(string(output)))
+			}
+
+			sections, err := parseRestoreInput(string(output))
+			if err != nil {
+				return fmt.Errorf("parse iptables-save table %s: %s", table, err)
+			}
+
+			section := &restoreSection{table: table}
+			for _, c := range nftBaseChains[table] {
+				section.lines = append(section.lines, fmt.Sprintf(":%s ACCEPT [0:0]", c.name))
+			}
+			for _, saved := range sections {
+				if saved.table == table {
+					section.lines = append(section.lines, saved.lines...)
+				}
+			}
+
+			nft, tx, err := n.transaction(table)
+			if err != nil {
+				return err
+			}
+			if err := restoreSectionInto(tx, section); err != nil {
+				return fmt.Errorf("migrate table %s: %s", table, err)
+			}
+			if err := nft.Run(context.Background(), tx); err != nil {
+				return fmt.Errorf("migrate table %s: %s", table, err)
+			}
+
+			flush := []string{fmt.Sprintf("*%s", table)}
+			for _, c := range nftBaseChains[table] {
+				flush = append(flush, fmt.Sprintf(":%s ACCEPT [0:0]", c.name))
+			}
+			flush = append(flush, "COMMIT", "")
+			if err := restorer.RestoreWithFlags(strings.Join(flush, "\n")); err != nil {
+				return fmt.Errorf("flush iptables table %s: %s", table, err)
+			}
+		}
+		return nil
+	})
+}
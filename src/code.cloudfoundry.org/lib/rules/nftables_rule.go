@@ -0,0 +1,460 @@
+package rules
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/shlex"
+	"sigs.k8s.io/knftables"
+)
+
+const digestPrefix = "sha256:"
+
+var shortOptions = map[string]string{
+	"--source":           "-s",
+	"--destination":      "-d",
+	"--protocol":         "-p",
+	"--in-interface":     "-i",
+	"--out-interface":    "-o",
+	"--jump":             "-j",
+	"--goto":             "-g",
+	"--destination-port": "--dport",
+	"--source-port":      "--sport",
+}
+
+// canonicalRule normalizes an iptables rulespec so that equivalent specs
+// written by different callers (or read back from iptables-save) compare
+// equal. Match modules are dropped since every option implies its module,
+// long options are shortened, bare addresses get a /32 mask, rates are
+// spelled like iptables-save does and the target
+// and comment are moved to the end.
+func canonicalRule(spec IPTablesRule) []string {
+	var matches, target, comment []string
+	current := &matches
+	for i := 0; i < len(spec); i++ {
+		token := strings.ReplaceAll(spec[i], `"`, "")
+		if token == "-m" || token == "--match" {
+			i++
+			continue
+		}
+		if short, ok := shortOptions[token]; ok {
+			token = short
+		}
+
+		if i+1 < len(spec) {
+			value := strings.ReplaceAll(spec[i+1], `"`, "")
+			switch token {
+			case "--comment":
+				comment = []string{token, quoteValue(value)}
+				i++
+				continue
+			case "-s", "-d":
+				if !strings.Contains(value, "/") {
+					value += "/32"
+				}
+				*current = append(*current, token, value)
+				i++
+				continue
+			case "--limit", "--hashlimit-above", "--hashlimit-upto":
+				*current = append(*current, token, iptablesRate(value))
+				i++
+				continue
+			case "-j", "-g":
+				current = &target
+			}
+		}
+		*current = append(*current, quoteValue(token))
+	}
+
+	return append(append(matches, target...), comment...)
+}
+
+// iptablesRate spells a rate the way iptables-save prints it.
+func iptablesRate(rate string) string {
+	count, unit, ok := strings.Cut(rate, "/")
+	if !ok {
+		return rate
+	}
+
+	switch unit {
+	case "s", "second":
+		unit = "sec"
+	case "m", "minute":
+		unit = "min"
+	case "h":
+		unit = "hour"
+	case "d":
+		unit = "day"
+	}
+	return fmt.Sprintf("%s/%s", count, unit)
+}
+
+func quoteValue(value string) string {
+	if strings.ContainsAny(value, " \t") {
+		return fmt.Sprintf("'%s'", value)
+	}
+	return value
+}
+
+// ruleKey is the identity of a rule. It is stored as the comment of the
+// nftables rule so that rules can be found again by their iptables spec.
+// Keys that do not fit into an nftables comment are replaced by a digest
+// which still carries the jump target for callers that parse List output.
+func ruleKey(spec IPTablesRule) string {
+	canonical := canonicalRule(spec)
+	key := strings.Join(canonical, " ")
+	if len(key) <= knftables.CommentLengthMax {
+		return key
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	digest := fmt.Sprintf("%s%x", digestPrefix, sum[:16])
+	if target := ruleTarget(canonical); target != nil {
+		digest = fmt.Sprintf("%s %s", digest, strings.Join(target, " "))
+	}
+	return digest
+}
+
+func ruleTarget(canonical []string) []string {
+	for i, token := range canonical {
+		if (token == "-j" || token == "-g") && i+1 < len(canonical) {
+			return canonical[i : i+2]
+		}
+	}
+	return nil
+}
+
+// specFromKey turns a rule key back into a rulespec. Digests cannot be
+// reversed.
+func specFromKey(key string) (IPTablesRule, error) {
+	if strings.HasPrefix(key, digestPrefix) {
+		return nil, fmt.Errorf("rule %s is stored as a digest", key)
+	}
+	args, err := shlex.Split(key)
+	return IPTablesRule(args), err
+}
+
+type hashLimit struct {
+	index  int
+	rate   string
+	above  bool
+	burst  string
+	mode   string
+	name   string
+	expire string
+}
+
+type ruleTranslation struct {
+	exprs      []string
+	protocol   string
+	limitIndex int
+	hashLimit  *hashLimit
+	target     string
+	targetOpts map[string]string
+	gotoTarget string
+	hasVerdict bool
+}
+
+// translateRule renders an iptables rulespec as the body of an nftables
+// rule. Only the options silk itself writes are supported.
+func translateRule(spec IPTablesRule) (string, error) {
+	tokens := canonicalRule(spec)
+	t := &ruleTranslation{limitIndex: -1, targetOpts: map[string]string{}}
+
+	negate := false
+	for i := 0; i < len(tokens); i++ {
+		option := tokens[i]
+		if option == "!" {
+			negate = true
+			continue
+		}
+		if i+1 >= len(tokens) {
+			return "", fmt.Errorf("missing value for iptables option %s", option)
+		}
+		value := strings.Trim(tokens[i+1], "'")
+		i++
+
+		if err := t.add(option, value, negate); err != nil {
+			return "", err
+		}
+		negate = false
+	}
+	if negate {
+		return "", fmt.Errorf("dangling negation in rule %v", spec)
+	}
+
+	return t.render()
+}
+
+func (t *ruleTranslation) add(option, value string, negate bool) error {
+	op := ""
+	if negate {
+		op = "!= "
+	}
+
+	if t.hasVerdict {
+		switch option {
+		case "--reject-with", "--log-prefix", "--to-destination", "--to-source", "--set-xmark", "--set-mark":
+			if negate {
+				return fmt.Errorf("unsupported negation of iptables option %s", option)
+			}
+			t.targetOpts[option] = value
+			return nil
+		case "--comment":
+			return nil
+		}
+		return fmt.Errorf("unsupported iptables option %s after target", option)
+	}
+
+	switch option {
+	case "-s":
+		t.exprs = append(t.exprs, "ip saddr "+op+value)
+	case "-d":
+		t.exprs = append(t.exprs, "ip daddr "+op+value)
+	case "--src-range":
+		t.exprs = append(t.exprs, "ip saddr "+op+value)
+	case "--dst-range":
+		t.exprs = append(t.exprs, "ip daddr "+op+value)
+	case "-i":
+		t.exprs = append(t.exprs, fmt.Sprintf("iifname %s%q", op, interfaceName(value)))
+	case "-o":
+		t.exprs = append(t.exprs, fmt.Sprintf("oifname %s%q", op, interfaceName(value)))
+	case "-p":
+		if value == "all" {
+			return nil
+		}
+		if !negate {
+			t.protocol = value
+		}
+		t.exprs = append(t.exprs, "meta l4proto "+op+value)
+	case "--dport", "--sport":
+		if t.protocol != "tcp" && t.protocol != "udp" {
+			return fmt.Errorf("iptables option %s requires protocol tcp or udp", option)
+		}
+		t.exprs = append(t.exprs, fmt.Sprintf("%s %s %s%s", t.protocol, strings.TrimPrefix(option, "--"), op, strings.Replace(value, ":", "-", 1)))
+	case "--icmp-type":
+		icmpType, icmpCode, hasCode := strings.Cut(value, "/")
+		t.exprs = append(t.exprs, "icmp type "+op+icmpType)
+		if hasCode {
+			t.exprs = append(t.exprs, "icmp code "+op+icmpCode)
+		}
+	case "--state", "--ctstate":
+		t.exprs = append(t.exprs, "ct state "+op+strings.ToLower(value))
+	case "--mark":
+		if mark, mask, ok := strings.Cut(value, "/"); ok {
+			cmp := "=="
+			if negate {
+				cmp = "!="
+			}
+			t.exprs = append(t.exprs, fmt.Sprintf("meta mark & %s %s %s", mask, cmp, mark))
+		} else {
+			t.exprs = append(t.exprs, "meta mark "+op+value)
+		}
+	case "--limit":
+		rate, err := nftRate(value)
+		if err != nil {
+			return err
+		}
+		t.exprs = append(t.exprs, "limit rate "+rate)
+		t.limitIndex = len(t.exprs) - 1
+	case "--limit-burst":
+		if t.limitIndex < 0 {
+			return fmt.Errorf("iptables option --limit-burst requires --limit")
+		}
+		t.exprs[t.limitIndex] += fmt.Sprintf(" burst %s packets", value)
+	case "--hashlimit-above", "--hashlimit-upto", "--hashlimit-burst", "--hashlimit-mode", "--hashlimit-name", "--hashlimit-htable-expire":
+		t.addHashLimit(option, value)
+	case "--comment":
+	case "-j":
+		t.target = value
+		t.hasVerdict = true
+	case "-g":
+		t.gotoTarget = value
+		t.hasVerdict = true
+	default:
+		return fmt.Errorf("unsupported iptables option %s", option)
+	}
+
+	if negate {
+		switch option {
+		case "-s", "-d", "--src-range", "--dst-range", "-i", "-o", "-p", "--dport", "--sport", "--icmp-type", "--state", "--ctstate", "--mark":
+		default:
+			return fmt.Errorf("unsupported negation of iptables option %s", option)
+		}
+	}
+	return nil
+}
+
+func (t *ruleTranslation) addHashLimit(option, value string) {
+	if t.hashLimit == nil {
+		t.hashLimit = &hashLimit{index: len(t.exprs)}
+		t.exprs = append(t.exprs, "")
+	}
+
+	switch option {
+	case "--hashlimit-above":
+		t.hashLimit.rate = value
+		t.hashLimit.above = true
+	case "--hashlimit-upto":
+		t.hashLimit.rate = value
+	case "--hashlimit-burst":
+		t.hashLimit.burst = value
+	case "--hashlimit-mode":
+		t.hashLimit.mode = value
+	case "--hashlimit-name":
+		t.hashLimit.name = value
+	case "--hashlimit-htable-expire":
+		t.hashLimit.expire = value
+	}
+}
+
+func (t *ruleTranslation) render() (string, error) {
+	if t.hashLimit != nil {
+		meter, err := t.hashLimit.render()
+		if err != nil {
+			return "", err
+		}
+		t.exprs[t.hashLimit.index] = meter
+	}
+
+	verdict, err := t.verdict()
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join(append(t.exprs, verdict), " "), nil
+}
+
+func (t *ruleTranslation) verdict() (string, error) {
+	if t.gotoTarget != "" {
+		return "goto " + t.gotoTarget, nil
+	}
+
+	switch t.target {
+	case "":
+		return "counter", nil
+	case "ACCEPT":
+		return "accept", nil
+	case "DROP":
+		return "drop", nil
+	case "RETURN":
+		return "return", nil
+	case "REJECT":
+		switch rejectWith := t.targetOpts["--reject-with"]; {
+		case rejectWith == "":
+			return "reject", nil
+		case rejectWith == "tcp-reset":
+			return "reject with tcp reset", nil
+		case strings.HasPrefix(rejectWith, "icmp-"):
+			return "reject with icmp type " + strings.TrimPrefix(rejectWith, "icmp-"), nil
+		default:
+			return "", fmt.Errorf("unsupported reject type %s", rejectWith)
+		}
+	case "LOG":
+		if prefix, ok := t.targetOpts["--log-prefix"]; ok {
+			return fmt.Sprintf("log prefix %q", prefix), nil
+		}
+		return "log", nil
+	case "MARK":
+		mark, ok := t.targetOpts["--set-mark"]
+		if !ok {
+			mark, ok = t.targetOpts["--set-xmark"]
+		}
+		if !ok {
+			return "", fmt.Errorf("MARK target requires --set-mark or --set-xmark")
+		}
+		if value, mask, hasMask := strings.Cut(mark, "/"); hasMask {
+			if mask != "0xffffffff" {
+				return "", fmt.Errorf("unsupported mark mask %s", mask)
+			}
+			mark = value
+		}
+		return "meta mark set " + mark, nil
+	case "DNAT":
+		return "dnat to " + t.targetOpts["--to-destination"], nil
+	case "SNAT":
+		return "snat to " + t.targetOpts["--to-source"], nil
+	case "MASQUERADE":
+		return "masquerade", nil
+	default:
+		return "jump " + t.target, nil
+	}
+}
+
+func (h *hashLimit) render() (string, error) {
+	if h.name == "" || h.rate == "" {
+		return "", fmt.Errorf("hashlimit requires --hashlimit-name and a rate")
+	}
+
+	var keys []string
+	for _, mode := range strings.Split(h.mode, ",") {
+		switch mode {
+		case "srcip":
+			keys = append(keys, "ip saddr")
+		case "dstip":
+			keys = append(keys, "ip daddr")
+		case "srcport":
+			keys = append(keys, "th sport")
+		case "dstport":
+			keys = append(keys, "th dport")
+		default:
+			return "", fmt.Errorf("unsupported hashlimit mode %s", mode)
+		}
+	}
+
+	rate, err := nftRate(h.rate)
+	if err != nil {
+		return "", err
+	}
+
+	meter := fmt.Sprintf("meter hashlimit-%s { %s", h.name, strings.Join(keys, " . "))
+	if h.expire != "" {
+		expire, err := strconv.Atoi(h.expire)
+		if err != nil {
+			return "", fmt.Errorf("invalid hashlimit expiry %s: %s", h.expire, err)
+		}
+		meter += fmt.Sprintf(" timeout %dms", expire)
+	}
+	meter += " limit rate "
+	if h.above {
+		meter += "over "
+	}
+	meter += rate
+	if h.burst != "" {
+		meter += fmt.Sprintf(" burst %s packets", h.burst)
+	}
+	return meter + " }", nil
+}
+
+func nftRate(rate string) (string, error) {
+	count, unit, ok := strings.Cut(rate, "/")
+	if !ok {
+		return "", fmt.Errorf("invalid rate %s", rate)
+	}
+	if _, err := strconv.Atoi(count); err != nil {
+		return "", fmt.Errorf("invalid rate %s", rate)
+	}
+
+	switch unit {
+	case "s", "sec", "second":
+		unit = "second"
+	case "m", "min", "minute":
+		unit = "minute"
+	case "h", "hour":
+		unit = "hour"
+	case "d", "day":
+		unit = "day"
+	default:
+		return "", fmt.Errorf("invalid rate %s", rate)
+	}
+	return fmt.Sprintf("%s/%s", count, unit), nil
+}
+
+func interfaceName(name string) string {
+	if strings.HasSuffix(name, "+") {
+		return strings.TrimSuffix(name, "+") + "*"
+	}
+	return name
+}
@@ -0,0 +1,648 @@
+package rules_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/cf-networking-helpers/runner"
+	"code.cloudfoundry.org/lib/fakes"
+	"code.cloudfoundry.org/lib/rules"
+	"sigs.k8s.io/knftables"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NFTables", func() {
+	var (
+		nft       *rules.NFTables
+		filter    *knftables.Fake
+		nat       *knftables.Fake
+		mangle    *knftables.Fake
+		lock      *fakes.Locker
+		nftRunner *fakes.CommandRunner
+	)
+
+	ruleTexts := func(fake *knftables.Fake, chain string) []string {
+		var texts []string
+		for _, r := range fake.Table.Chains[chain].Rules {
+			texts = append(texts, r.Rule)
+		}
+		return texts
+	}
+
+	BeforeEach(func() {
+		filter = knftables.NewFake(knftables.IPv4Family, "silk_filter")
+		nat = knftables.NewFake(knftables.IPv4Family, "silk_nat")
+		mangle = knftables.NewFake(knftables.IPv4Family, "silk_mangle")
+		lock = &fakes.Locker{}
+		nftRunner = &fakes.CommandRunner{}
+		nft = &rules.NFTables{
+			Tables: map[string]knftables.Interface{
+				"filter": filter,
+				"nat":    nat,
+				"mangle": mangle,
+			},
+			Locker:    lock,
+			NFTRunner: nftRunner,
+		}
+	})
+
+	Describe("ValidateBackend", func() {
+		It("accepts the supported backends", func() {
+			Expect(rules.ValidateBackend("")).To(Succeed())
+			Expect(rules.ValidateBackend("iptables")).To(Succeed())
+			Expect(rules.ValidateBackend("nftables")).To(Succeed())
+		})
+
+		It("rejects anything else", func() {
+			Expect(rules.ValidateBackend("ebtables")).To(MatchError(`invalid firewall backend "ebtables": must be iptables or nftables`))
+		})
+	})
+
+	Describe("BulkAppend", func() {
+		It("translates the rules into the base chain of the table", func() {
+			err := nft.BulkAppend("filter", "FORWARD",
+				rules.NewMarkSetRule("1.2.3.4", "A", "a-guid"),
+				rules.NewNetOutWithPortsRule("10.0.0.1", "10.0.0.9", 80, 90, "tcp"),
+				rules.NewInputDefaultRejectRule(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(lock.LockCallCount()).To(Equal(1))
+			Expect(lock.UnlockCallCount()).To(Equal(1))
+
+			chain := filter.Table.Chains["FORWARD"]
+			Expect(*chain.Hook).To(Equal(knftables.ForwardHook))
+			Expect(*chain.Type).To(Equal(knftables.FilterType))
+			Expect(ruleTexts(filter, "FORWARD")).To(Equal([]string{
+				"ip saddr 1.2.3.4/32 meta mark set 0xa",
+				"meta l4proto tcp tcp dport 80-90 ip daddr 10.0.0.1-10.0.0.9 accept",
+				"reject with icmp type port-unreachable",
+			}))
+		})
+
+		It("translates nat rules into a nat chain", func() {
+			err := nft.BulkAppend("nat", "POSTROUTING", rules.NewDefaultEgressRule("10.255.0.0/16", "", "silk-vtep"))
+			Expect(err).NotTo(HaveOccurred())
+
+			chain := nat.Table.Chains["POSTROUTING"]
+			Expect(*chain.Type).To(Equal(knftables.NATType))
+			Expect(*chain.Priority).To(Equal(knftables.SNATPriority))
+			Expect(ruleTexts(nat, "POSTROUTING")).To(Equal([]string{
+				`ip saddr 10.255.0.0/16 oifname != "silk-vtep" masquerade`,
+			}))
+		})
+
+		It("translates logging and rate limiting rules", func() {
+			Expect(nft.NewChain("filter", "netout--handle")).To(Succeed())
+			Expect(nft.NewChain("filter", "netout--handle--rl-log")).To(Succeed())
+			err := nft.BulkAppend("filter", "netout--handle",
+				rules.NewNetOutDefaultRejectLogRule("handle", 3),
+				rules.NewNetOutConnRateLimitRule("100/sec", "10", "handle", "5000", "netout--handle--rl-log"),
+				rules.NewOverlayAllowEgress("silk-vtep", "10.255.1.2"),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(ruleTexts(filter, "netout--handle")).To(Equal([]string{
+				`limit rate 3/second burst 3 packets log prefix "DENY_handle "`,
+				"meta l4proto tcp ct state new meter hashlimit-handle { ip daddr . th dport timeout 5000ms limit rate over 100/second burst 10 packets } jump netout--handle--rl-log",
+				`ip saddr 10.255.1.2/32 oifname "silk-vtep" meta mark != 0x0 accept`,
+			}))
+		})
+
+		It("translates every rule that silk writes without ipsets", func() {
+			Expect(nft.NewChain("filter", "some-chain")).To(Succeed())
+			Expect(nft.BulkAppend("filter", "some-chain",
+				rules.NewMarkAllowRuleNoComment("10.255.1.2", "tcp", 8080, "A"),
+				rules.NewMarkAllowRule("10.255.1.2", "udp", 8080, 8090, "A", "src-guid", "dst-guid"),
+				rules.NewMarkAllowLogRule("10.255.1.2", "tcp", 8080, 8090, "A", "dst-guid", 3),
+				rules.NewMarkSetRule("10.255.1.2", "A", "a-guid"),
+				rules.NewLogRule(rules.NewAcceptRule(), "some-name"),
+				rules.NewAcceptExistingLocalRule(),
+				rules.NewLogLocalRejectRule("10.255.1.0/24"),
+				rules.NewDefaultDenyLocalRule("10.255.1.0/24"),
+				rules.NewNetOutRule("10.0.0.1", "10.0.0.9"),
+				rules.NewNetOutWithPortsRule("10.0.0.1", "10.0.0.9", 80, 90, "udp"),
+				rules.NewNetOutICMPRule("10.0.0.1", "10.0.0.9", 8, 0),
+				rules.NewNetOutICMPLogRule("10.0.0.1", "10.0.0.9", 8, 0, "some-chain"),
+				rules.NewNetOutLogRule("10.0.0.1", "10.0.0.9", "some-chain"),
+				rules.NewNetOutWithPortsLogRule("10.0.0.1", "10.0.0.9", 80, 90, "tcp", "some-chain"),
+				rules.NewNetOutDefaultNonUDPLogRule("handle"),
+				rules.NewNetOutDefaultUDPLogRule("handle", 3),
+				rules.NewAcceptEverythingRule("10.255.0.0/16"),
+				rules.NewInputRelatedEstablishedRule(),
+				rules.NewInputAllowRule("tcp", "169.254.0.2", 53),
+				rules.NewInputRejectRule("169.254.0.2"),
+				rules.NewInputDefaultRejectRule(),
+				rules.NewNetOutInvalidRule(),
+				rules.NewNetOutRelatedEstablishedRule(),
+				rules.NewNetOutConnRateLimitRule("100/sec", "10", "handle", "5000", "some-chain"),
+				rules.NewOverlayTagAcceptRule("10.255.1.2", "A"),
+				rules.NewOverlayDefaultRejectRule("10.255.1.2"),
+				rules.NewOverlayDefaultRejectLogRule("handle", "10.255.1.2", 3),
+				rules.NewOverlayAllowEgress("silk-vtep", "10.255.1.2"),
+				rules.NewOverlayRelatedEstablishedRule("10.255.1.2"),
+				rules.NewNetOutDefaultRejectLogRule("handle", 3),
+				rules.NewNetOutConnRateLimitRejectLogRule("handle", 3),
+				rules.NewNetOutDefaultRejectRule(),
+				rules.NewOverlayAccessMarkRule("silk-vtep", "A"),
+			)).To(Succeed())
+			Expect(nft.BulkAppend("filter", "some-chain", rules.NewOverlayIsolationRules("10.255.0.0/16", "silk-vtep", []string{"10.100.0.0/16"})...)).To(Succeed())
+			Expect(nft.BulkAppend("filter", "some-chain", rules.NewNetOutJumpConditions([]string{"eth0"}, "10.255.1.2", "some-chain")...)).To(Succeed())
+
+			Expect(nft.NewChain("mangle", "some-chain")).To(Succeed())
+			Expect(nft.BulkAppend("mangle", "some-chain", rules.NewIngressMarkRules([]string{"eth0"}, "udp", 1000, 1010, "10.0.0.5", "A")...)).To(Succeed())
+
+			Expect(nft.NewChain("nat", "some-chain")).To(Succeed())
+			Expect(nft.BulkAppend("nat", "some-chain",
+				rules.NewPortForwardingRule("tcp", 1000, 1000, 8080, "10.0.0.5", "10.255.1.2"),
+				rules.NewPortForwardingRule("udp", 1000, 1010, 0, "10.0.0.5", "10.255.1.2"),
+				rules.NewDefaultEgressRule("10.255.1.0/24", "10.255.0.0/16", "silk-vtep"),
+				rules.NewEgressSNATRule("10.255.1.2", "10.255.0.0/16", "silk-vtep", "10.0.0.6"),
+			)).To(Succeed())
+		})
+
+		It("returns an error for the ipset rules of ASGs", func() {
+			err := nft.BulkAppend("filter", "FORWARD", rules.NewNetOutSetRule("some-set"))
+			Expect(err).To(MatchError(ContainSubstring("unsupported iptables option --match-set")))
+		})
+
+		It("returns an error for options it cannot translate", func() {
+			err := nft.BulkAppend("filter", "FORWARD", rules.IPTablesRule{"-m", "owner", "--uid-owner", "0", "-j", "ACCEPT"})
+			Expect(err).To(MatchError(ContainSubstring("unsupported iptables option --uid-owner")))
+			Expect(lock.UnlockCallCount()).To(Equal(1))
+		})
+
+		Context("when the lock fails", func() {
+			BeforeEach(func() {
+				lock.LockReturns(errors.New("banana"))
+			})
+
+			It("returns an error", func() {
+				err := nft.BulkAppend("filter", "FORWARD", rules.NewAcceptRule())
+				Expect(err).To(MatchError("lock: banana"))
+			})
+		})
+
+		Context("when the unlock fails", func() {
+			BeforeEach(func() {
+				lock.UnlockReturns(errors.New("banana"))
+			})
+
+			It("returns an error", func() {
+				err := nft.BulkAppend("filter", "FORWARD", rules.NewAcceptRule())
+				Expect(err).To(MatchError("banana"))
+			})
+		})
+	})
+
+	Describe("BulkInsert", func() {
+		BeforeEach(func() {
+			Expect(nft.BulkAppend("filter", "FORWARD", rules.NewAcceptEverythingRule("10.0.0.0/8"))).To(Succeed())
+		})
+
+		It("inserts the rules in reverse order like iptables-restore", func() {
+			err := nft.BulkInsert("filter", "FORWARD", 1,
+				rules.NewMarkSetRule("1.2.3.4", "A", "a-guid"),
+				rules.NewMarkSetRule("2.2.2.2", "B", "b-guid"),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(ruleTexts(filter, "FORWARD")).To(Equal([]string{
+				"ip saddr 2.2.2.2/32 meta mark set 0xb",
+				"ip saddr 1.2.3.4/32 meta mark set 0xa",
+				"ip saddr 10.0.0.0/8 ip daddr 10.0.0.0/8 accept",
+			}))
+		})
+
+		It("inserts after the earlier rules", func() {
+			err := nft.BulkInsert("filter", "FORWARD", 2,
+				rules.NewMarkSetRule("1.2.3.4", "A", "a-guid"),
+				rules.NewMarkSetRule("2.2.2.2", "B", "b-guid"),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(ruleTexts(filter, "FORWARD")).To(Equal([]string{
+				"ip saddr 10.0.0.0/8 ip daddr 10.0.0.0/8 accept",
+				"ip saddr 2.2.2.2/32 meta mark set 0xb",
+				"ip saddr 1.2.3.4/32 meta mark set 0xa",
+			}))
+		})
+
+		It("returns an error when the position is past the end of the chain", func() {
+			err := nft.BulkInsert("filter", "FORWARD", 3, rules.NewAcceptRule())
+			Expect(err).To(MatchError(ContainSubstring("Index of insertion too big")))
+		})
+	})
+
+	Describe("Exists and Delete", func() {
+		BeforeEach(func() {
+			Expect(nft.BulkAppend("filter", "FORWARD",
+				rules.NewMarkSetRule("1.2.3.4", "A", "a-guid"),
+				rules.NewAcceptRule(),
+			)).To(Succeed())
+		})
+
+		It("finds rules by their spec", func() {
+			exists, err := nft.Exists("filter", "FORWARD", rules.NewMarkSetRule("1.2.3.4", "A", "a-guid"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exists).To(BeTrue())
+
+			exists, err = nft.Exists("filter", "FORWARD", rules.IPTablesRule{"-j", "ACCEPT"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exists).To(BeTrue())
+
+			exists, err = nft.Exists("filter", "FORWARD", rules.NewMarkSetRule("1.2.3.4", "B", "a-guid"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exists).To(BeFalse())
+		})
+
+		It("reports rules in missing chains as not existing", func() {
+			exists, err := nft.Exists("filter", "some-chain", rules.NewAcceptRule())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exists).To(BeFalse())
+		})
+
+		It("deletes rules by their spec", func() {
+			Expect(nft.Delete("filter", "FORWARD", rules.NewMarkSetRule("1.2.3.4", "A", "a-guid"))).To(Succeed())
+			Expect(ruleTexts(filter, "FORWARD")).To(Equal([]string{"accept"}))
+		})
+
+		It("returns an error when the rule does not exist", func() {
+			err := nft.Delete("filter", "FORWARD", rules.NewInputDefaultRejectRule())
+			Expect(err).To(MatchError(ContainSubstring("Bad rule (does a matching rule exist in that chain?)")))
+		})
+
+		It("returns the iptables error text when the chain does not exist", func() {
+			err := nft.Delete("filter", "some-chain", rules.NewAcceptRule())
+			Expect(err).To(MatchError(ContainSubstring(rules.ChainDoesNotExistErrorText)))
+		})
+	})
+
+	Describe("chains", func() {
+		It("creates, lists, clears and deletes chains", func() {
+			Expect(nft.NewChain("filter", "asg-123")).To(Succeed())
+			Expect(nft.NewChain("filter", "asg-123")).NotTo(Succeed())
+
+			exists, err := nft.ChainExists("filter", "asg-123")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exists).To(BeTrue())
+
+			exists, err = nft.ChainExists("filter", "FORWARD")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exists).To(BeTrue())
+
+			chains, err := nft.ListChains("filter")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(chains).To(Equal([]string{"INPUT", "FORWARD", "OUTPUT", "asg-123"}))
+
+			Expect(nft.BulkAppend("filter", "asg-123", rules.NewAcceptRule())).To(Succeed())
+			Expect(nft.ClearChain("filter", "asg-123")).To(Succeed())
+			Expect(filter.Table.Chains["asg-123"].Rules).To(BeEmpty())
+
+			Expect(nft.DeleteChain("filter", "asg-123")).To(Succeed())
+			exists, err = nft.ChainExists("filter", "asg-123")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exists).To(BeFalse())
+
+			err = nft.DeleteChain("filter", "asg-123")
+			Expect(err).To(MatchError(ContainSubstring(rules.ChainDoesNotExistErrorText)))
+		})
+
+		It("rejects tables it does not manage", func() {
+			err := nft.NewChain("raw", "some-chain")
+			Expect(err).To(MatchError(ContainSubstring("unsupported table raw")))
+		})
+	})
+
+	Describe("List", func() {
+		It("lists the rules in iptables -S format", func() {
+			Expect(nft.NewChain("filter", "vpa--123")).To(Succeed())
+			Expect(nft.BulkInsert("filter", "FORWARD", 1, rules.IPTablesRule{"-j", "vpa--123"})).To(Succeed())
+
+			lines, err := nft.List("filter", "FORWARD")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(lines).To(Equal([]string{"-P FORWARD ACCEPT", "-A FORWARD -j vpa--123"}))
+
+			lines, err = nft.List("filter", "vpa--123")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(lines).To(Equal([]string{"-N vpa--123"}))
+		})
+
+		It("keeps the target of rules whose spec does not fit into a comment", func() {
+			Expect(nft.NewChain("filter", "netout--handle")).To(Succeed())
+			Expect(nft.NewChain("filter", "netout--handle--rl-log")).To(Succeed())
+			Expect(nft.BulkAppend("filter", "netout--handle",
+				rules.NewNetOutConnRateLimitRule("100/sec", "10", "a-very-long-container-handle-that-fills-the-comment", "5000", "netout--handle--rl-log"),
+			)).To(Succeed())
+
+			lines, err := nft.List("filter", "netout--handle")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(lines).To(HaveLen(2))
+			Expect(lines[1]).To(MatchRegexp(`^-A netout--handle sha256:[0-9a-f]{32} -j netout--handle--rl-log$`))
+		})
+	})
+
+	Describe("DeleteAfterRuleNum", func() {
+		BeforeEach(func() {
+			Expect(nft.NewChain("filter", "netout--handle")).To(Succeed())
+			Expect(nft.BulkAppend("filter", "netout--handle",
+				rules.IPTablesRule{"-s", "1.1.1.1", "-j", "ACCEPT"},
+				rules.IPTablesRule{"-s", "2.2.2.2", "-j", "ACCEPT"},
+				rules.IPTablesRule{"-s", "3.3.3.3", "-j", "ACCEPT"},
+			)).To(Succeed())
+		})
+
+		It("deletes the rule with the given rule number", func() {
+			Expect(nft.DeleteRuleNum("filter", "netout--handle", 2)).To(Succeed())
+			Expect(ruleTexts(filter, "netout--handle")).To(Equal([]string{"ip saddr 1.1.1.1/32 accept", "ip saddr 3.3.3.3/32 accept"}))
+
+			Expect(nft.DeleteRuleNum("filter", "netout--handle", 3)).To(MatchError(ContainSubstring("Index of deletion too big")))
+		})
+
+		It("deletes every rule from the given rule number", func() {
+			Expect(nft.DeleteAfterRuleNum("filter", "netout--handle", 2)).To(Succeed())
+			Expect(ruleTexts(filter, "netout--handle")).To(Equal([]string{"ip saddr 1.1.1.1/32 accept"}))
+		})
+
+		It("appends the default reject rule when asked to keep it", func() {
+			Expect(nft.DeleteAfterRuleNumKeepReject("filter", "netout--handle", 3)).To(Succeed())
+			Expect(ruleTexts(filter, "netout--handle")).To(Equal([]string{
+				"ip saddr 1.1.1.1/32 accept",
+				"ip saddr 2.2.2.2/32 accept",
+				"reject with icmp type port-unreachable",
+			}))
+
+			Expect(nft.DeleteAfterRuleNumKeepReject("filter", "netout--handle", 4)).To(Succeed())
+			Expect(ruleTexts(filter, "netout--handle")).To(HaveLen(3))
+		})
+	})
+
+	Describe("RenameChain", func() {
+		BeforeEach(func() {
+			Expect(nft.NewChain("filter", "netout--handle")).To(Succeed())
+			Expect(nft.NewChain("filter", "casg-123")).To(Succeed())
+			Expect(nft.BulkInsert("filter", "netout--handle", 1, rules.IPTablesRule{"-j", "casg-123"})).To(Succeed())
+			nftRunner.CombinedOutputStub = func(runner.Command) ([]byte, error) {
+				chain := filter.Table.Chains["casg-123"]
+				delete(filter.Table.Chains, "casg-123")
+				chain.Name = "asg-123"
+				filter.Table.Chains["asg-123"] = chain
+				return nil, nil
+			}
+		})
+
+		It("renames the chain with nft and updates the jumps into it", func() {
+			Expect(nft.RenameChain("filter", "casg-123", "asg-123")).To(Succeed())
+
+			Expect(nftRunner.CombinedOutputCallCount()).To(Equal(1))
+			Expect(nftRunner.CombinedOutputArgsForCall(0).Args).To(Equal([]string{"rename", "chain", "ip", "silk_filter", "casg-123", "asg-123"}))
+
+			exists, err := nft.Exists("filter", "netout--handle", rules.IPTablesRule{"-j", "asg-123"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exists).To(BeTrue())
+			Expect(ruleTexts(filter, "netout--handle")).To(Equal([]string{"jump asg-123"}))
+		})
+
+		Context("when nft fails", func() {
+			BeforeEach(func() {
+				nftRunner.CombinedOutputStub = nil
+				nftRunner.CombinedOutputReturns([]byte("some output"), errors.New("banana"))
+			})
+
+			It("returns an error", func() {
+				err := nft.RenameChain("filter", "casg-123", "asg-123")
+				Expect(err).To(MatchError(ContainSubstring("rename chain casg-123 to asg-123: banana: some output")))
+			})
+		})
+	})
+
+	Describe("RuleCount", func() {
+		It("counts the lines iptables -S would print", func() {
+			Expect(nft.NewChain("nat", "netin--handle")).To(Succeed())
+			Expect(nft.BulkAppend("nat", "PREROUTING", rules.IPTablesRule{"-j", "netin--handle"})).To(Succeed())
+
+			count, err := nft.RuleCount("nat")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(count).To(Equal(6))
+		})
+	})
+
+	Describe("FlushAndRestore", func() {
+		It("replaces the tables in the input", func() {
+			Expect(nft.BulkAppend("filter", "FORWARD", rules.NewAcceptRule())).To(Succeed())
+
+			err := nft.FlushAndRestore(`*filter
+:INPUT ACCEPT [0:0]
+:FORWARD ACCEPT [0:0]
+:OUTPUT ACCEPT [0:0]
+:istio-ingress - [0:0]
+-A FORWARD -j istio-ingress
+COMMIT
+*nat
+:PREROUTING ACCEPT [0:0]
+:INPUT ACCEPT [0:0]
+:OUTPUT ACCEPT [0:0]
+:POSTROUTING ACCEPT [0:0]
+COMMIT
+`)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(ruleTexts(filter, "FORWARD")).To(Equal([]string{"jump istio-ingress"}))
+			Expect(filter.Table.Chains).To(HaveKey("istio-ingress"))
+			Expect(nat.Table.Chains).To(HaveLen(4))
+		})
+
+		It("returns an error for policies nftables cannot express", func() {
+			err := nft.FlushAndRestore("*filter\n:INPUT DROP [0:0]\nCOMMIT\n")
+			Expect(err).To(MatchError(ContainSubstring("unsupported policy DROP for chain INPUT")))
+		})
+	})
+
+	Describe("ApplyTransaction", func() {
+		var tx *rules.RestoreTransaction
+		BeforeEach(func() {
+			tx = &rules.RestoreTransaction{}
+			tx.NewChain("filter", "netout--some-handle")
+			tx.Append("filter", "FORWARD", rules.IPTablesRule{"--jump", "netout--some-handle"})
+			tx.Append("filter", "netout--some-handle", rules.NewInputDefaultRejectRule())
+			tx.NewChain("nat", "netin--some-handle")
+			tx.Append("nat", "PREROUTING", rules.IPTablesRule{"--jump", "netin--some-handle"})
+		})
+
+		It("creates the chains and rules of every table", func() {
+			Expect(nft.ApplyTransaction(tx)).To(Succeed())
+
+			Expect(lock.LockCallCount()).To(Equal(1))
+			Expect(lock.UnlockCallCount()).To(Equal(1))
+			Expect(ruleTexts(filter, "FORWARD")).To(Equal([]string{"jump netout--some-handle"}))
+			Expect(ruleTexts(filter, "netout--some-handle")).To(Equal([]string{"reject with icmp type port-unreachable"}))
+			Expect(ruleTexts(nat, "PREROUTING")).To(Equal([]string{"jump netin--some-handle"}))
+			Expect(nat.Table.Chains).To(HaveKey("netin--some-handle"))
+		})
+
+		It("fails like iptables when a chain already exists", func() {
+			Expect(nft.NewChain("filter", "netout--some-handle")).To(Succeed())
+
+			Expect(nft.ApplyTransaction(tx)).NotTo(Succeed())
+		})
+
+		Context("when a later table fails", func() {
+			BeforeEach(func() {
+				Expect(nft.BulkAppend("filter", "FORWARD", rules.IPTablesRule{"-j", "ACCEPT"})).To(Succeed())
+				Expect(nft.NewChain("nat", "netin--some-handle")).To(Succeed())
+			})
+
+			It("undoes the tables applied before it", func() {
+				err := nft.ApplyTransaction(tx)
+				Expect(err).To(MatchError(ContainSubstring("apply table nat")))
+
+				Expect(ruleTexts(filter, "FORWARD")).To(Equal([]string{"accept"}))
+				Expect(filter.Table.Chains).NotTo(HaveKey("netout--some-handle"))
+				Expect(nat.Table.Chains).NotTo(HaveKey("PREROUTING"))
+			})
+		})
+
+		Context("when the transaction changes rules by number", func() {
+			BeforeEach(func() {
+				Expect(nft.NewChain("filter", "vpa--1234")).To(Succeed())
+				Expect(nft.BulkAppend("filter", "vpa--1234",
+					rules.IPTablesRule{"-d", "1.1.1.1", "-j", "ACCEPT"},
+					rules.IPTablesRule{"-d", "2.2.2.2", "-j", "ACCEPT"},
+					rules.IPTablesRule{"-d", "3.3.3.3", "-j", "ACCEPT"},
+				)).To(Succeed())
+
+				tx = &rules.RestoreTransaction{}
+			})
+
+			It("inserts and deletes the rules like iptables-restore would", func() {
+				changes := rules.DiffRules(
+					[]rules.IPTablesRule{
+						{"-d", "1.1.1.1", "-j", "ACCEPT"},
+						{"-d", "2.2.2.2", "-j", "ACCEPT"},
+						{"-d", "3.3.3.3", "-j", "ACCEPT"},
+					},
+					[]rules.IPTablesRule{
+						{"-d", "4.4.4.4", "-j", "ACCEPT"},
+						{"-d", "1.1.1.1", "-j", "ACCEPT"},
+						{"-d", "3.3.3.3", "-j", "ACCEPT"},
+						{"-d", "5.5.5.5", "-j", "ACCEPT"},
+						{"-d", "6.6.6.6", "-j", "ACCEPT"},
+					},
+				)
+				Expect(rules.ApplyRuleChanges(nft, "filter", "vpa--1234", changes)).To(Succeed())
+
+				Expect(ruleTexts(filter, "vpa--1234")).To(Equal([]string{
+					"ip daddr 4.4.4.4/32 accept",
+					"ip daddr 1.1.1.1/32 accept",
+					"ip daddr 3.3.3.3/32 accept",
+					"ip daddr 5.5.5.5/32 accept",
+					"ip daddr 6.6.6.6/32 accept",
+				}))
+			})
+
+			It("changes nothing when a rule number is past the end of the chain", func() {
+				tx.Delete("filter", "vpa--1234", 1)
+				tx.Delete("filter", "vpa--1234", 3)
+
+				err := nft.ApplyTransaction(tx)
+				Expect(err).To(MatchError(ContainSubstring("Index of deletion too big")))
+				Expect(ruleTexts(filter, "vpa--1234")).To(HaveLen(3))
+			})
+
+			It("rejects appending to the same chain", func() {
+				tx.Delete("filter", "vpa--1234", 1)
+				tx.Append("filter", "vpa--1234", rules.NewAcceptRule())
+
+				err := nft.ApplyTransaction(tx)
+				Expect(err).To(MatchError(ContainSubstring("changed by rule number")))
+			})
+		})
+
+		It("changes no table when a rule cannot be translated", func() {
+			tx.Append("nat", "netin--some-handle", rules.IPTablesRule{"--unknown-option", "1"})
+
+			err := nft.ApplyTransaction(tx)
+			Expect(err).To(MatchError(ContainSubstring("unsupported iptables option --unknown-option")))
+			Expect(filter.Table).To(BeNil())
+			Expect(nat.Table).To(BeNil())
+		})
+	})
+
+	Describe("MigrateFromIPTables", func() {
+		var (
+			saver    *fakes.CommandRunner
+			restorer *fakes.Restorer
+		)
+
+		BeforeEach(func() {
+			saver = &fakes.CommandRunner{}
+			restorer = &fakes.Restorer{}
+			saver.CombinedOutputStub = func(command runner.Command) ([]byte, error) {
+				switch command.Args[1] {
+				case "filter":
+					return []byte(`# Generated by iptables-save
+*filter
+:INPUT ACCEPT [0:0]
+:FORWARD ACCEPT [0:0]
+:OUTPUT ACCEPT [0:0]
+:netout--handle - [0:0]
+-A FORWARD -s 10.255.1.2/32 -o eth0 -j netout--handle
+-A netout--handle -m limit --limit 1/sec --limit-burst 1 -j LOG --log-prefix "DENY_handle "
+-A netout--handle -j REJECT --reject-with icmp-port-unreachable
+COMMIT
+`), nil
+				case "nat":
+					return []byte("*nat\n:PREROUTING ACCEPT [0:0]\n:POSTROUTING ACCEPT [0:0]\n-A POSTROUTING -s 10.255.1.0/24 ! -o silk-vtep -j MASQUERADE\nCOMMIT\n"), nil
+				default:
+					return nil, nil
+				}
+			}
+		})
+
+		It("copies the iptables state into nftables and flushes it from iptables", func() {
+			Expect(nft.MigrateFromIPTables(saver, restorer)).To(Succeed())
+
+			Expect(saver.CombinedOutputCallCount()).To(Equal(3))
+			Expect(saver.CombinedOutputArgsForCall(0).Args).To(Equal([]string{"-t", "filter"}))
+
+			Expect(ruleTexts(filter, "FORWARD")).To(Equal([]string{`ip saddr 10.255.1.2/32 oifname "eth0" jump netout--handle`}))
+			Expect(ruleTexts(filter, "netout--handle")).To(Equal([]string{
+				`limit rate 1/second burst 1 packets log prefix "DENY_handle "`,
+				"reject with icmp type port-unreachable",
+			}))
+			Expect(ruleTexts(nat, "POSTROUTING")).To(Equal([]string{`ip saddr 10.255.1.0/24 oifname != "silk-vtep" masquerade`}))
+			Expect(mangle.Table.Chains).To(HaveLen(5))
+
+			exists, err := nft.Exists("filter", "netout--handle", rules.NewNetOutDefaultRejectLogRule("handle", 1))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exists).To(BeTrue())
+
+			Expect(restorer.RestoreWithFlagsCallCount()).To(Equal(3))
+			input, flags := restorer.RestoreWithFlagsArgsForCall(0)
+			Expect(input).To(Equal("*filter\n:INPUT ACCEPT [0:0]\n:FORWARD ACCEPT [0:0]\n:OUTPUT ACCEPT [0:0]\nCOMMIT\n"))
+			Expect(flags).To(BeEmpty())
+		})
+
+		It("leaves tables that are already in nftables alone", func() {
+			Expect(nft.MigrateFromIPTables(saver, restorer)).To(Succeed())
+			Expect(nft.MigrateFromIPTables(saver, restorer)).To(Succeed())
+
+			Expect(saver.CombinedOutputCallCount()).To(Equal(3))
+			Expect(restorer.RestoreWithFlagsCallCount()).To(Equal(3))
+		})
+
+		Context("when iptables-save fails", func() {
+			BeforeEach(func() {
+				saver.CombinedOutputStub = nil
+				saver.CombinedOutputReturns([]byte("some output"), errors.New("banana"))
+			})
+
+			It("returns an error and leaves iptables alone", func() {
+				err := nft.MigrateFromIPTables(saver, restorer)
+				Expect(err).To(MatchError(ContainSubstring("iptables-save table filter: banana: some output")))
+				Expect(restorer.RestoreWithFlagsCallCount()).To(Equal(0))
+			})
+		})
+	})
+})
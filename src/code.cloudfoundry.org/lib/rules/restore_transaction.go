@@ -0,0 +1,122 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RestoreTransaction collects new chains and rule changes across tables so
+// that they can be applied in a single iptables-restore --noflush call.
+// Either every change in the transaction is applied or none are.
+type RestoreTransaction struct {
+	tables  []string
+	entries map[string][]restoreEntry
+	ipSets  []IPSet
+}
+
+type restoreEntry struct {
+	// command is -N, -A, -I or -D.
+	command string
+	chain   string
+	// pos is the rule number for -I and -D.
+	pos  int
+	rule IPTablesRule
+}
+
+func (t *RestoreTransaction) add(table string, entries ...restoreEntry) {
+	if t.entries == nil {
+		t.entries = map[string][]restoreEntry{}
+	}
+	if _, ok := t.entries[table]; !ok {
+		t.tables = append(t.tables, table)
+	}
+	t.entries[table] = append(t.entries[table], entries...)
+}
+
+// NewChain creates the chain. Like iptables -N, applying the transaction
+// fails if the chain already exists.
+func (t *RestoreTransaction) NewChain(table, chain string) {
+	t.add(table, restoreEntry{command: "-N", chain: chain})
+}
+
+// Append appends the rules to the chain, which must either already exist or
+// be created earlier in the transaction.
+func (t *RestoreTransaction) Append(table, chain string, rulespec ...IPTablesRule) {
+	for _, rule := range rulespec {
+		t.add(table, restoreEntry{command: "-A", chain: chain, rule: rule})
+	}
+}
+
+// Insert inserts the rule at the 1-based rule number, counted after the
+// changes before it in the transaction.
+func (t *RestoreTransaction) Insert(table, chain string, pos int, rule IPTablesRule) {
+	t.add(table, restoreEntry{command: "-I", chain: chain, pos: pos, rule: rule})
+}
+
+// Delete deletes the rule with the 1-based rule number, counted after the
+// changes before it in the transaction.
+func (t *RestoreTransaction) Delete(table, chain string, ruleNum int) {
+	t.add(table, restoreEntry{command: "-D", chain: chain, pos: ruleNum})
+}
+
+// EnsureIPSet creates the set, if needed, right before the rules are
+// restored, so that the set cannot be destroyed before the rules that match
+// it are in place.
+func (t *RestoreTransaction) EnsureIPSet(set IPSet) {
+	t.ipSets = append(t.ipSets, set)
+}
+
+// IPSets returns the sets the transaction ensures.
+func (t *RestoreTransaction) IPSets() []IPSet {
+	return t.ipSets
+}
+
+// Tables returns the tables touched by the transaction in the order they
+// were first used.
+func (t *RestoreTransaction) Tables() []string {
+	return t.tables
+}
+
+// Chains returns the chains the transaction creates in the table.
+func (t *RestoreTransaction) Chains(table string) []string {
+	var chains []string
+	for _, entry := range t.entries[table] {
+		if entry.command == "-N" {
+			chains = append(chains, entry.chain)
+		}
+	}
+	return chains
+}
+
+// Rules returns the rules the transaction appends to the chain.
+func (t *RestoreTransaction) Rules(table, chain string) []IPTablesRule {
+	var rules []IPTablesRule
+	for _, entry := range t.entries[table] {
+		if entry.command == "-A" && entry.chain == chain {
+			rules = append(rules, entry.rule)
+		}
+	}
+	return rules
+}
+
+// String renders the transaction as iptables-restore input.
+func (t *RestoreTransaction) String() string {
+	var input []string
+	for _, table := range t.tables {
+		input = append(input, fmt.Sprintf("*%s\n", table))
+		for _, entry := range t.entries[table] {
+			switch entry.command {
+			case "-N":
+				input = append(input, fmt.Sprintf("-N %s\n", entry.chain))
+			case "-A":
+				input = append(input, fmt.Sprintf("-A %s %s\n", entry.chain, strings.Join(entry.rule, " ")))
+			case "-I":
+				input = append(input, fmt.Sprintf("-I %s %d %s\n", entry.chain, entry.pos, strings.Join(entry.rule, " ")))
+			case "-D":
+				input = append(input, fmt.Sprintf("-D %s %d\n", entry.chain, entry.pos))
+			}
+		}
+		input = append(input, "COMMIT\n")
+	}
+	return strings.Join(input, "")
+}
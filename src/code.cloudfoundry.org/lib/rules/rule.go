@@ -0,0 +1,370 @@
+package rules
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Match is the value of a rule option. Negate inverts the match, like
+// prefixing the option with ! does.
+type Match struct {
+	Value  string
+	Negate bool
+}
+
+// Ports is a port or, when End is larger than Start, a range of ports.
+type Ports struct {
+	Start int
+	End   int
+}
+
+// Limit is a match of the limit module.
+type Limit struct {
+	Rate  string
+	Burst string
+}
+
+// HashLimit is a match of the hashlimit module. Above is the rate above
+// which packets match.
+type HashLimit struct {
+	Above        string
+	Burst        string
+	Mode         string
+	Name         string
+	HTableExpire string
+}
+
+// Rule is an iptables rulespec. Args renders it in a fixed order, so rules
+// that only differ in how their options were ordered render the same.
+type Rule struct {
+	Source       Match
+	Destination  Match
+	InInterface  Match
+	OutInterface Match
+	Protocol     Match
+
+	SourcePorts      *Ports
+	DestinationPorts *Ports
+	ICMPType         string
+
+	SourceRange      string
+	DestinationRange string
+	DestinationSet   string
+
+	State     string
+	ConnState string
+	Mark      Match
+	Limit     *Limit
+	HashLimit *HashLimit
+	Comment   string
+
+	Jump string
+	Goto string
+
+	RejectWith    string
+	LogPrefix     string
+	SetMark       string
+	SetXMark      string
+	ToDestination string
+	ToSource      string
+}
+
+// Args renders the rule as iptables arguments. Addresses lose a /32 mask,
+// and rates and marks are spelled like iptables -S prints them.
+func (r Rule) Args() IPTablesRule {
+	args := IPTablesRule{}
+	option := func(name string, m Match) {
+		if m.Value == "" {
+			return
+		}
+		if m.Negate {
+			args = append(args, "!")
+		}
+		args = append(args, name, m.Value)
+	}
+
+	option("-s", Match{Value: hostAddress(r.Source.Value), Negate: r.Source.Negate})
+	option("-d", Match{Value: hostAddress(r.Destination.Value), Negate: r.Destination.Negate})
+	option("-i", r.InInterface)
+	option("-o", r.OutInterface)
+	option("-p", r.Protocol)
+
+	if r.SourcePorts != nil || r.DestinationPorts != nil {
+		if r.Protocol.Value != "" {
+			args = append(args, "-m", r.Protocol.Value)
+		}
+		if r.SourcePorts != nil {
+			args = append(args, "--sport", r.SourcePorts.String())
+		}
+		if r.DestinationPorts != nil {
+			args = append(args, "--dport", r.DestinationPorts.String())
+		}
+	}
+	if r.ICMPType != "" {
+		args = append(args, "-m", "icmp", "--icmp-type", r.ICMPType)
+	}
+	if r.SourceRange != "" || r.DestinationRange != "" {
+		args = append(args, "-m", "iprange")
+		option("--src-range", Match{Value: r.SourceRange})
+		option("--dst-range", Match{Value: r.DestinationRange})
+	}
+	if r.DestinationSet != "" {
+		args = append(args, "-m", "set", "--match-set", r.DestinationSet, "dst")
+	}
+	if r.State != "" {
+		args = append(args, "-m", "state", "--state", r.State)
+	}
+	if r.ConnState != "" {
+		args = append(args, "-m", "conntrack", "--ctstate", r.ConnState)
+	}
+	if r.Mark.Value != "" {
+		args = append(args, "-m", "mark")
+		option("--mark", Match{Value: normalizeMark(r.Mark.Value), Negate: r.Mark.Negate})
+	}
+	if r.Limit != nil {
+		args = append(args, "-m", "limit", "--limit", iptablesRate(r.Limit.Rate))
+		option("--limit-burst", Match{Value: r.Limit.Burst})
+	}
+	if h := r.HashLimit; h != nil {
+		args = append(args, "-m", "hashlimit", "--hashlimit-above", iptablesRate(h.Above))
+		option("--hashlimit-burst", Match{Value: h.Burst})
+		option("--hashlimit-mode", Match{Value: h.Mode})
+		option("--hashlimit-name", Match{Value: h.Name})
+		option("--hashlimit-htable-expire", Match{Value: h.HTableExpire})
+	}
+	if r.Comment != "" {
+		args = append(args, "-m", "comment", "--comment", r.Comment)
+	}
+
+	option("-j", Match{Value: r.Jump})
+	option("-g", Match{Value: r.Goto})
+	option("--reject-with", Match{Value: r.RejectWith})
+	if r.LogPrefix != "" {
+		args = append(args, "--log-prefix", fmt.Sprintf(`"%s"`, r.LogPrefix))
+	}
+	option("--set-mark", Match{Value: normalizeMark(r.SetMark)})
+	option("--set-xmark", Match{Value: normalizeMark(r.SetXMark)})
+	option("--to-destination", Match{Value: r.ToDestination})
+	option("--to-source", Match{Value: r.ToSource})
+
+	return args
+}
+
+// Equal reports whether both rules render to the same arguments.
+func (r Rule) Equal(other Rule) bool {
+	return slices.Equal(r.Args(), other.Args())
+}
+
+func (p Ports) String() string {
+	if p.End <= p.Start {
+		return strconv.Itoa(p.Start)
+	}
+	return fmt.Sprintf("%d:%d", p.Start, p.End)
+}
+
+func hostAddress(address string) string {
+	return strings.TrimSuffix(address, "/32")
+}
+
+// ParseRule parses iptables arguments in any order. Match modules are
+// implied by their options and are skipped. Marks are normalized to how
+// iptables -S lists them.
+func ParseRule(args IPTablesRule) (Rule, error) {
+	var r Rule
+	negate := false
+	for i := 0; i < len(args); i++ {
+		name := args[i]
+		if name == "!" {
+			negate = true
+			continue
+		}
+		if short, ok := shortOptions[name]; ok {
+			name = short
+		}
+		if i+1 >= len(args) {
+			return Rule{}, fmt.Errorf("missing value for iptables option %s", name)
+		}
+		value := strings.Trim(args[i+1], `"`)
+		i++
+
+		if name == "-m" || name == "--match" {
+			continue
+		}
+		if name == "--match-set" {
+			if i+1 >= len(args) || args[i+1] != "dst" {
+				return Rule{}, fmt.Errorf("unsupported iptables option %s %s", name, value)
+			}
+			i++
+		}
+
+		if err := r.set(name, value, negate); err != nil {
+			return Rule{}, err
+		}
+		negate = false
+	}
+	if negate {
+		return Rule{}, fmt.Errorf("dangling negation in rule %v", args)
+	}
+	return r, nil
+}
+
+func (r *Rule) set(name, value string, negate bool) error {
+	match := Match{Value: value, Negate: negate}
+	switch name {
+	case "-s":
+		r.Source = Match{Value: hostAddress(value), Negate: negate}
+		return nil
+	case "-d":
+		r.Destination = Match{Value: hostAddress(value), Negate: negate}
+		return nil
+	case "-i":
+		r.InInterface = match
+		return nil
+	case "-o":
+		r.OutInterface = match
+		return nil
+	case "-p":
+		r.Protocol = match
+		return nil
+	case "--mark":
+		r.Mark = Match{Value: normalizeMark(value), Negate: negate}
+		return nil
+	}
+
+	if negate {
+		return fmt.Errorf("unsupported negation of iptables option %s", name)
+	}
+
+	var err error
+	switch name {
+	case "--sport":
+		r.SourcePorts, err = parsePorts(value)
+	case "--dport":
+		r.DestinationPorts, err = parsePorts(value)
+	case "--icmp-type":
+		r.ICMPType = value
+	case "--src-range":
+		r.SourceRange = value
+	case "--dst-range":
+		r.DestinationRange = value
+	case "--match-set":
+		r.DestinationSet = value
+	case "--state":
+		r.State = value
+	case "--ctstate":
+		r.ConnState = value
+	case "--limit":
+		r.limit().Rate = iptablesRate(value)
+	case "--limit-burst":
+		r.limit().Burst = value
+	case "--hashlimit-above":
+		r.hashLimit().Above = iptablesRate(value)
+	case "--hashlimit-burst":
+		r.hashLimit().Burst = value
+	case "--hashlimit-mode":
+		r.hashLimit().Mode = value
+	case "--hashlimit-name":
+		r.hashLimit().Name = value
+	case "--hashlimit-htable-expire":
+		r.hashLimit().HTableExpire = value
+	case "--comment":
+		r.Comment = value
+	case "-j":
+		r.Jump = value
+	case "-g":
+		r.Goto = value
+	case "--reject-with":
+		r.RejectWith = value
+	case "--log-prefix":
+		r.LogPrefix = value
+	case "--set-mark", "--set-xmark":
+		// --set-mark is --set-xmark with a full mask, which is how iptables
+		// -S lists it.
+		r.SetXMark = normalizeMark(value)
+	case "--to-destination":
+		r.ToDestination = value
+	case "--to-source":
+		r.ToSource = value
+	default:
+		return fmt.Errorf("unsupported iptables option %s", name)
+	}
+	return err
+}
+
+func (r *Rule) limit() *Limit {
+	if r.Limit == nil {
+		r.Limit = &Limit{}
+	}
+	return r.Limit
+}
+
+func (r *Rule) hashLimit() *HashLimit {
+	if r.HashLimit == nil {
+		r.HashLimit = &HashLimit{}
+	}
+	return r.HashLimit
+}
+
+// normalizeMark spells a mark like iptables -S does, in lowercase hex, and
+// drops a mask that matches every bit.
+func normalizeMark(value string) string {
+	mark, mask, hasMask := strings.Cut(value, "/")
+	mark = normalizeHex(mark)
+	if !hasMask {
+		return mark
+	}
+	mask = normalizeHex(mask)
+	if mask == "0xffffffff" {
+		return mark
+	}
+	return mark + "/" + mask
+}
+
+func normalizeHex(value string) string {
+	number, err := strconv.ParseUint(value, 0, 32)
+	if err != nil {
+		return strings.ToLower(value)
+	}
+	return fmt.Sprintf("0x%x", number)
+}
+
+func parsePorts(value string) (*Ports, error) {
+	start, end, isRange := strings.Cut(value, ":")
+	startPort, err := strconv.Atoi(start)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %s", value)
+	}
+	if !isRange {
+		return &Ports{Start: startPort, End: startPort}, nil
+	}
+	endPort, err := strconv.Atoi(end)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %s", value)
+	}
+	return &Ports{Start: startPort, End: endPort}, nil
+}
+
+// EqualRules reports whether two rulespecs are the same rule, regardless of
+// the order of their options. Rules that cannot be parsed are compared
+// argument by argument.
+func EqualRules(a, b IPTablesRule) bool {
+	parsedA, errA := ParseRule(a)
+	parsedB, errB := ParseRule(b)
+	if errA != nil || errB != nil {
+		return slices.Equal(a, b)
+	}
+	return parsedA.Equal(parsedB)
+}
+
+// modifyRule parses the rule and applies modify to it. Rules that cannot be
+// parsed are passed to fallback unchanged.
+func modifyRule(rule IPTablesRule, modify func(*Rule), fallback func(IPTablesRule) IPTablesRule) IPTablesRule {
+	parsed, err := ParseRule(rule)
+	if err != nil {
+		return fallback(rule)
+	}
+	modify(&parsed)
+	return parsed.Args()
+}
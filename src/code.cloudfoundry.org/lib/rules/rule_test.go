@@ -0,0 +1,114 @@
+package rules_test
+
+import (
+	"code.cloudfoundry.org/lib/rules"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Rule", func() {
+	Describe("Args", func() {
+		It("renders the options in a fixed order", func() {
+			rule := rules.Rule{
+				Jump:             "LOG",
+				LogPrefix:        "OK_some-handle ",
+				Limit:            &rules.Limit{Rate: "4/s", Burst: "4"},
+				Mark:             rules.Match{Value: "0x0", Negate: true},
+				DestinationPorts: &rules.Ports{Start: 80, End: 90},
+				Protocol:         rules.Match{Value: "udp"},
+				Destination:      rules.Match{Value: "10.255.0.1/32"},
+				Comment:          "src:some-guid",
+			}
+
+			Expect(rule.Args()).To(Equal(rules.IPTablesRule{
+				"-d", "10.255.0.1",
+				"-p", "udp",
+				"-m", "udp", "--dport", "80:90",
+				"-m", "mark", "!", "--mark", "0x0",
+				"-m", "limit", "--limit", "4/sec", "--limit-burst", "4",
+				"-m", "comment", "--comment", "src:some-guid",
+				"-j", "LOG", "--log-prefix", `"OK_some-handle "`,
+			}))
+		})
+
+		It("renders a single port without a range", func() {
+			rule := rules.Rule{Protocol: rules.Match{Value: "tcp"}, DestinationPorts: &rules.Ports{Start: 443, End: 443}}
+			Expect(rule.Args()).To(Equal(rules.IPTablesRule{"-p", "tcp", "-m", "tcp", "--dport", "443"}))
+		})
+	})
+
+	Describe("ParseRule", func() {
+		It("parses the options regardless of their order", func() {
+			a, err := rules.ParseRule(rules.IPTablesRule{
+				"-m", "iprange", "-p", "tcp", "--dst-range", "1.1.1.1-2.2.2.2",
+				"-m", "tcp", "--destination-port", "9000:9999", "--jump", "ACCEPT",
+			})
+			Expect(err).NotTo(HaveOccurred())
+			b, err := rules.ParseRule(rules.IPTablesRule{
+				"-p", "tcp", "-m", "tcp", "--dport", "9000:9999",
+				"-m", "iprange", "--dst-range", "1.1.1.1-2.2.2.2", "-j", "ACCEPT",
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(a).To(Equal(rules.Rule{
+				Protocol:         rules.Match{Value: "tcp"},
+				DestinationPorts: &rules.Ports{Start: 9000, End: 9999},
+				DestinationRange: "1.1.1.1-2.2.2.2",
+				Jump:             "ACCEPT",
+			}))
+			Expect(a.Equal(b)).To(BeTrue())
+		})
+
+		It("round trips the rules silk builds", func() {
+			for _, args := range []rules.IPTablesRule{
+				rules.NewMarkAllowRule("10.255.0.1", "tcp", 80, 90, "ff", "src-guid", "dst-guid"),
+				rules.NewMarkAllowLogRule("10.255.0.1", "udp", 80, 80, "ff", "dst-guid", 4),
+				rules.NewMarkSetRule("10.255.0.1", "ff", "some-guid"),
+				rules.NewDefaultEgressRule("10.255.0.0/24", "10.255.0.0/16", "silk-vtep"),
+				rules.NewNetOutConnRateLimitRule("100/sec", "900", "some-handle", "10000", "some-log-chain"),
+				rules.NewNetOutSetICMPLogRule("silk-abc", 8, 0, "some-log-chain"),
+				rules.NewOverlayAllowEgress("silk-vtep", "10.255.0.1"),
+				rules.NewPortForwardingRule("tcp", 61000, 61000, 8080, "10.0.0.1", "10.255.0.1"),
+				rules.NewPortForwardingRule("udp", 61000, 61010, 61000, "10.0.0.1", "10.255.0.1"),
+			} {
+				rule, err := rules.ParseRule(args)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(rule.Args()).To(Equal(args))
+			}
+		})
+
+		It("rejects options it does not know", func() {
+			_, err := rules.ParseRule(rules.IPTablesRule{"--physdev-in", "eth0", "-j", "ACCEPT"})
+			Expect(err).To(MatchError("unsupported iptables option --physdev-in"))
+		})
+
+		It("rejects negations of options that cannot be negated", func() {
+			_, err := rules.ParseRule(rules.IPTablesRule{"!", "--dport", "80"})
+			Expect(err).To(MatchError("unsupported negation of iptables option --dport"))
+		})
+
+		It("rejects options without a value", func() {
+			_, err := rules.ParseRule(rules.IPTablesRule{"-d"})
+			Expect(err).To(MatchError("missing value for iptables option -d"))
+		})
+	})
+
+	Describe("EqualRules", func() {
+		It("ignores the order of the options", func() {
+			Expect(rules.EqualRules(
+				rules.IPTablesRule{"--source", "10.0.0.1", "-m", "mark", "--mark", "0xff", "--jump", "ACCEPT"},
+				rules.IPTablesRule{"-m", "mark", "--mark", "0xff", "-s", "10.0.0.1/32", "-j", "ACCEPT"},
+			)).To(BeTrue())
+			Expect(rules.EqualRules(
+				rules.IPTablesRule{"-s", "10.0.0.1", "-j", "ACCEPT"},
+				rules.IPTablesRule{"-s", "10.0.0.2", "-j", "ACCEPT"},
+			)).To(BeFalse())
+		})
+
+		It("compares rules it cannot parse argument by argument", func() {
+			Expect(rules.EqualRules(rules.IPTablesRule{"rule1"}, rules.IPTablesRule{"rule1"})).To(BeTrue())
+			Expect(rules.EqualRules(rules.IPTablesRule{"rule1"}, rules.IPTablesRule{"rule2"})).To(BeFalse())
+		})
+	})
+})
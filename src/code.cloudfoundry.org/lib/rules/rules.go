@@ -6,42 +6,70 @@ import (
 	"strings"
 
 	"code.cloudfoundry.org/garden"
-	"github.com/google/shlex"
 )
 
+// IPTablesRule is a rule rendered as iptables arguments.
 type IPTablesRule []string
 
-func NewIPTablesRuleFromIPTablesLine(line string) (IPTablesRule, error) {
-	args, err := shlex.Split(line)
-	return IPTablesRule(args), err
-}
-
 func AppendComment(rule IPTablesRule, comment string) IPTablesRule {
 	comment = strings.Replace(comment, " ", "_", -1)
-	return IPTablesRule(
-		append(rule, "-m", "comment", "--comment", comment),
-	)
-}
-
-func NewPortForwardingRule(hostPort, containerPort int, hostIP, containerIP string) IPTablesRule {
-	return IPTablesRule{
-		"-d", hostIP, "-p", "tcp",
-		"-m", "tcp", "--dport", fmt.Sprintf("%d", hostPort),
-		"--jump", "DNAT",
-		"--to-destination", fmt.Sprintf("%s:%d", containerIP, containerPort),
-	}
-}
-
-func NewIngressMarkRules(hostInterfaceNames []string, hostPort int, hostIP, tag string) []IPTablesRule {
+	return modifyRule(rule, func(r *Rule) {
+		r.Comment = comment
+	}, func(rule IPTablesRule) IPTablesRule {
+		return IPTablesRule(append(rule, "-m", "comment", "--comment", comment))
+	})
+}
+
+// MatchInputInterface restricts the rule to packets arriving on the given device.
+func MatchInputInterface(rule IPTablesRule, deviceName string) IPTablesRule {
+	return modifyRule(rule, func(r *Rule) {
+		r.InInterface = Match{Value: deviceName}
+	}, func(rule IPTablesRule) IPTablesRule {
+		return IPTablesRule(append([]string{"-i", deviceName}, rule...))
+	})
+}
+
+// Reject makes the rule reject the traffic it matches instead of accepting
+// or logging it.
+func Reject(rule IPTablesRule) IPTablesRule {
+	return modifyRule(rule, func(r *Rule) {
+		r.Jump = "REJECT"
+		r.Goto = ""
+		r.RejectWith = "icmp-port-unreachable"
+	}, func(rule IPTablesRule) IPTablesRule {
+		return IPTablesRule(append(rule, "-j", "REJECT", "--reject-with", "icmp-port-unreachable"))
+	})
+}
+
+// NewPortForwardingRule forwards the host ports from startPort to endPort.
+// A single port is forwarded to containerPort, a range to the same ports of
+// the container.
+func NewPortForwardingRule(protocol string, startPort, endPort, containerPort int, hostIP, containerIP string) IPTablesRule {
+	toDestination := containerIP
+	if startPort == endPort {
+		toDestination = fmt.Sprintf("%s:%d", containerIP, containerPort)
+	}
+	return Rule{
+		Destination:      Match{Value: hostIP},
+		Protocol:         Match{Value: protocol},
+		DestinationPorts: &Ports{Start: startPort, End: endPort},
+		Jump:             "DNAT",
+		ToDestination:    toDestination,
+	}.Args()
+}
+
+func NewIngressMarkRules(hostInterfaceNames []string, protocol string, startPort, endPort int, hostIP, tag string) []IPTablesRule {
 	jumpConditions := make([]IPTablesRule, len(hostInterfaceNames))
 
 	for i, hostInterfaceName := range hostInterfaceNames {
-		jumpConditions[i] = IPTablesRule{
-			"-i", hostInterfaceName, "-d", hostIP, "-p", "tcp",
-			"-m", "tcp", "--dport", fmt.Sprintf("%d", hostPort),
-			"--jump", "MARK",
-			"--set-mark", fmt.Sprintf("0x%s", tag),
-		}
+		jumpConditions[i] = Rule{
+			InInterface:      Match{Value: hostInterfaceName},
+			Destination:      Match{Value: hostIP},
+			Protocol:         Match{Value: protocol},
+			DestinationPorts: &Ports{Start: startPort, End: endPort},
+			Jump:             "MARK",
+			SetMark:          fmt.Sprintf("0x%s", tag),
+		}.Args()
 	}
 
 	return jumpConditions
@@ -51,296 +79,359 @@ func NewNetOutJumpConditions(hostInterfaceNames []string, hostIP, forwardChainNa
 	jumpConditions := make([]IPTablesRule, len(hostInterfaceNames))
 
 	for i, hostInterfaceName := range hostInterfaceNames {
-		jumpConditions[i] = IPTablesRule{
-			"-s", hostIP,
-			"-o", hostInterfaceName,
-			"--jump", forwardChainName,
-		}
+		jumpConditions[i] = Rule{
+			Source:       Match{Value: hostIP},
+			OutInterface: Match{Value: hostInterfaceName},
+			Jump:         forwardChainName,
+		}.Args()
 	}
 
 	return jumpConditions
 }
 
 func NewMarkAllowRuleNoComment(destinationIP, protocol string, port int, tag string) IPTablesRule {
-	return IPTablesRule{
-		"-d", destinationIP,
-		"-p", protocol,
-		"-m", protocol, "--dport", fmt.Sprintf("%d", port),
-		"-m", "mark", "--mark", fmt.Sprintf("0x%s", tag),
-		"--jump", "ACCEPT",
-	}
+	return Rule{
+		Destination:      Match{Value: destinationIP},
+		Protocol:         Match{Value: protocol},
+		DestinationPorts: &Ports{Start: port, End: port},
+		Mark:             Match{Value: fmt.Sprintf("0x%s", tag)},
+		Jump:             "ACCEPT",
+	}.Args()
 }
 
 func NewMarkAllowRule(destinationIP, protocol string, startPort, endPort int, tag string, sourceAppGUID, destinationAppGUID string) IPTablesRule {
-	return AppendComment(IPTablesRule{
-		"-d", destinationIP,
-		"-p", protocol,
-		"--dport", fmt.Sprintf("%d:%d", startPort, endPort),
-		"-m", "mark", "--mark", fmt.Sprintf("0x%s", tag),
-		"--jump", "ACCEPT",
-	}, fmt.Sprintf("src:%s_dst:%s", sourceAppGUID, destinationAppGUID))
+	return AppendComment(Rule{
+		Destination:      Match{Value: destinationIP},
+		Protocol:         Match{Value: protocol},
+		DestinationPorts: &Ports{Start: startPort, End: endPort},
+		Mark:             Match{Value: fmt.Sprintf("0x%s", tag)},
+		Jump:             "ACCEPT",
+	}.Args(), fmt.Sprintf("src:%s_dst:%s", sourceAppGUID, destinationAppGUID))
 }
 
 func NewMarkAllowLogRule(destinationIP, protocol string, startPort, endPort int, tag string, destinationAppGUID string, acceptedUDPLogsPerSec int) IPTablesRule {
+	rule := Rule{
+		Destination:      Match{Value: destinationIP},
+		Protocol:         Match{Value: protocol},
+		DestinationPorts: &Ports{Start: startPort, End: endPort},
+		Mark:             Match{Value: fmt.Sprintf("0x%s", tag)},
+		Jump:             "LOG",
+		LogPrefix:        logPrefix(fmt.Sprintf("OK_%s_%s", tag, destinationAppGUID)),
+	}
 	if protocol != "udp" {
-		return IPTablesRule{
-			"-d", destinationIP,
-			"-p", protocol,
-			"--dport", fmt.Sprintf("%d:%d", startPort, endPort),
-			"-m", "mark", "--mark", fmt.Sprintf("0x%s", tag),
-			"-m", "conntrack", "--ctstate", "INVALID,NEW,UNTRACKED",
-			"--jump", "LOG", "--log-prefix",
-			trimAndPad(fmt.Sprintf("OK_%s_%s", tag, destinationAppGUID))}
+		rule.ConnState = "INVALID,NEW,UNTRACKED"
 	} else {
-		return IPTablesRule{
-			"-d", destinationIP,
-			"-p", protocol,
-			"--dport", fmt.Sprintf("%d:%d", startPort, endPort),
-			"-m", "mark", "--mark", fmt.Sprintf("0x%s", tag),
-			"-m", "limit",
-			"--limit", fmt.Sprintf("%d/s", acceptedUDPLogsPerSec),
-			"--limit-burst", strconv.Itoa(acceptedUDPLogsPerSec),
-			"--jump", "LOG", "--log-prefix",
-			trimAndPad(fmt.Sprintf("OK_%s_%s", tag, destinationAppGUID))}
+		rule.Limit = perSecondLimit(acceptedUDPLogsPerSec)
 	}
+	return rule.Args()
 }
 
 func NewMarkSetRule(sourceIP, tag, appGUID string) IPTablesRule {
-	return AppendComment(IPTablesRule{
-		"--source", sourceIP,
-		"--jump", "MARK", "--set-xmark", fmt.Sprintf("0x%s", tag),
-	}, fmt.Sprintf("src:%s", appGUID))
+	return AppendComment(Rule{
+		Source:   Match{Value: sourceIP},
+		Jump:     "MARK",
+		SetXMark: fmt.Sprintf("0x%s", tag),
+	}.Args(), fmt.Sprintf("src:%s", appGUID))
 }
 
 func NewDefaultEgressRule(localSubnet, noMasqueradeCIDRRange, deviceName string) IPTablesRule {
-	ipTablesRule := IPTablesRule{
-		"--source", localSubnet,
-		"!", "-o", deviceName,
+	return egressRule(localSubnet, noMasqueradeCIDRRange, deviceName).Args()
+}
+
+// NewEgressSNATRule matches the same traffic as NewDefaultEgressRule but
+// rewrites its source to the egress IP instead of the address of the
+// outgoing interface.
+func NewEgressSNATRule(localSubnet, noMasqueradeCIDRRange, deviceName, egressIP string) IPTablesRule {
+	rule := egressRule(localSubnet, noMasqueradeCIDRRange, deviceName)
+	rule.Jump = "SNAT"
+	rule.ToSource = egressIP
+	return rule.Args()
+}
+
+func egressRule(localSubnet, noMasqueradeCIDRRange, deviceName string) Rule {
+	rule := Rule{
+		Source:       Match{Value: localSubnet},
+		OutInterface: Match{Value: deviceName, Negate: true},
+		Jump:         "MASQUERADE",
 	}
 	if noMasqueradeCIDRRange != "" {
-		ipTablesRule = append(ipTablesRule, "!", "--destination", noMasqueradeCIDRRange)
+		rule.Destination = Match{Value: noMasqueradeCIDRRange, Negate: true}
 	}
-	ipTablesRule = append(ipTablesRule, "--jump", "MASQUERADE")
-	return ipTablesRule
+	return rule
 }
 
 func NewLogRule(rule IPTablesRule, name string) IPTablesRule {
-	return IPTablesRule(append(
-		rule, "-m", "limit", "--limit", "2/min",
-		"--jump", "LOG",
-		"--log-prefix", trimAndPad(name),
-	))
+	return modifyRule(rule, func(r *Rule) {
+		r.Limit = &Limit{Rate: "2/min"}
+		r.Jump = "LOG"
+		r.LogPrefix = logPrefix(name)
+	}, func(rule IPTablesRule) IPTablesRule {
+		return IPTablesRule(append(
+			rule, "-m", "limit", "--limit", "2/min",
+			"-j", "LOG",
+			"--log-prefix", fmt.Sprintf(`"%s"`, logPrefix(name)),
+		))
+	})
 }
 
 func NewAcceptExistingLocalRule() IPTablesRule {
-	return IPTablesRule{
-		"-m", "state", "--state", "ESTABLISHED,RELATED",
-		"--jump", "ACCEPT",
-	}
+	return Rule{
+		State: "ESTABLISHED,RELATED",
+		Jump:  "ACCEPT",
+	}.Args()
 }
 
 func NewLogLocalRejectRule(localSubnet string) IPTablesRule {
 	return NewLogRule(
-		IPTablesRule{
-			"-s", localSubnet,
-			"-d", localSubnet,
-		},
+		Rule{
+			Source:      Match{Value: localSubnet},
+			Destination: Match{Value: localSubnet},
+		}.Args(),
 		"REJECT_LOCAL: ",
 	)
 }
 
 func NewDefaultDenyLocalRule(localSubnet string) IPTablesRule {
-	return IPTablesRule{
-		"--source", localSubnet,
-		"-d", localSubnet,
-		"--jump", "REJECT",
-	}
+	return Rule{
+		Source:      Match{Value: localSubnet},
+		Destination: Match{Value: localSubnet},
+		Jump:        "REJECT",
+	}.Args()
 }
 
 func NewNetOutRule(startIP, endIP string) IPTablesRule {
-	return IPTablesRule{
-		"-m", "iprange",
-		"--dst-range", fmt.Sprintf("%s-%s", startIP, endIP),
-		"--jump", "ACCEPT",
-	}
+	return Rule{
+		DestinationRange: fmt.Sprintf("%s-%s", startIP, endIP),
+		Jump:             "ACCEPT",
+	}.Args()
 }
 
 func NewNetOutWithPortsRule(startIP, endIP string, startPort, endPort int, protocol string) IPTablesRule {
-	return IPTablesRule{
-		"-m", "iprange",
-		"-p", protocol,
-		"--dst-range", fmt.Sprintf("%s-%s", startIP, endIP),
-		"-m", protocol,
-		"--destination-port", fmt.Sprintf("%d:%d", startPort, endPort),
-		"--jump", "ACCEPT",
-	}
+	return Rule{
+		Protocol:         Match{Value: protocol},
+		DestinationPorts: &Ports{Start: startPort, End: endPort},
+		DestinationRange: fmt.Sprintf("%s-%s", startIP, endIP),
+		Jump:             "ACCEPT",
+	}.Args()
 }
 
 func NewNetOutICMPRule(startIP, endIP string, icmpType garden.ICMPType, icmpCode garden.ICMPCode) IPTablesRule {
-	return IPTablesRule{
-		"-m", "iprange",
-		"-p", "icmp",
-		"--dst-range", fmt.Sprintf("%s-%s", startIP, endIP),
-		"-m", "icmp",
-		"--icmp-type", fmt.Sprintf("%d/%d", icmpType, icmpCode),
-		"--jump", "ACCEPT",
-	}
+	return Rule{
+		Protocol:         Match{Value: "icmp"},
+		ICMPType:         fmt.Sprintf("%d/%d", icmpType, icmpCode),
+		DestinationRange: fmt.Sprintf("%s-%s", startIP, endIP),
+		Jump:             "ACCEPT",
+	}.Args()
 }
 
 func NewNetOutICMPLogRule(startIP, endIP string, icmpType garden.ICMPType, icmpCode garden.ICMPCode, chain string) IPTablesRule {
-	return IPTablesRule{
-		"-m", "iprange",
-		"-p", "icmp",
-		"--dst-range", fmt.Sprintf("%s-%s", startIP, endIP),
-		"-m", "icmp",
-		"--icmp-type", fmt.Sprintf("%d/%d", icmpType, icmpCode),
-		"-g", chain,
-	}
+	return Rule{
+		Protocol:         Match{Value: "icmp"},
+		ICMPType:         fmt.Sprintf("%d/%d", icmpType, icmpCode),
+		DestinationRange: fmt.Sprintf("%s-%s", startIP, endIP),
+		Goto:             chain,
+	}.Args()
 }
 
 func NewNetOutLogRule(startIP, endIP, chain string) IPTablesRule {
-	return IPTablesRule{
-		"-m", "iprange",
-		"--dst-range", fmt.Sprintf("%s-%s", startIP, endIP),
-		"-g", chain,
-	}
+	return Rule{
+		DestinationRange: fmt.Sprintf("%s-%s", startIP, endIP),
+		Goto:             chain,
+	}.Args()
 }
 
 func NewNetOutWithPortsLogRule(startIP, endIP string, startPort, endPort int, protocol, chain string) IPTablesRule {
-	return IPTablesRule{
-		"-m", "iprange",
-		"-p", protocol,
-		"--dst-range", fmt.Sprintf("%s-%s", startIP, endIP),
-		"-m", protocol,
-		"--destination-port", fmt.Sprintf("%d:%d", startPort, endPort),
-		"-g", chain,
-	}
+	return Rule{
+		Protocol:         Match{Value: protocol},
+		DestinationPorts: &Ports{Start: startPort, End: endPort},
+		DestinationRange: fmt.Sprintf("%s-%s", startIP, endIP),
+		Goto:             chain,
+	}.Args()
+}
+
+func NewNetOutSetRule(setName string) IPTablesRule {
+	return Rule{
+		DestinationSet: setName,
+		Jump:           "ACCEPT",
+	}.Args()
+}
+
+func NewNetOutSetWithPortsRule(setName string, startPort, endPort int, protocol string) IPTablesRule {
+	return Rule{
+		Protocol:         Match{Value: protocol},
+		DestinationPorts: &Ports{Start: startPort, End: endPort},
+		DestinationSet:   setName,
+		Jump:             "ACCEPT",
+	}.Args()
+}
+
+func NewNetOutSetICMPRule(setName string, icmpType garden.ICMPType, icmpCode garden.ICMPCode) IPTablesRule {
+	return Rule{
+		Protocol:       Match{Value: "icmp"},
+		ICMPType:       fmt.Sprintf("%d/%d", icmpType, icmpCode),
+		DestinationSet: setName,
+		Jump:           "ACCEPT",
+	}.Args()
+}
+
+func NewNetOutSetLogRule(setName, chain string) IPTablesRule {
+	return Rule{
+		DestinationSet: setName,
+		Goto:           chain,
+	}.Args()
+}
+
+func NewNetOutSetWithPortsLogRule(setName string, startPort, endPort int, protocol, chain string) IPTablesRule {
+	return Rule{
+		Protocol:         Match{Value: protocol},
+		DestinationPorts: &Ports{Start: startPort, End: endPort},
+		DestinationSet:   setName,
+		Goto:             chain,
+	}.Args()
+}
+
+func NewNetOutSetICMPLogRule(setName string, icmpType garden.ICMPType, icmpCode garden.ICMPCode, chain string) IPTablesRule {
+	return Rule{
+		Protocol:       Match{Value: "icmp"},
+		ICMPType:       fmt.Sprintf("%d/%d", icmpType, icmpCode),
+		DestinationSet: setName,
+		Goto:           chain,
+	}.Args()
 }
 
 func NewNetOutDefaultNonUDPLogRule(prefix string) IPTablesRule {
-	return IPTablesRule{
-		"!", "-p", "udp",
-		"-m", "conntrack", "--ctstate", "INVALID,NEW,UNTRACKED",
-		"-j", "LOG", "--log-prefix", trimAndPad(fmt.Sprintf("OK_%s", prefix)),
-	}
+	return Rule{
+		Protocol:  Match{Value: "udp", Negate: true},
+		ConnState: "INVALID,NEW,UNTRACKED",
+		Jump:      "LOG",
+		LogPrefix: logPrefix(fmt.Sprintf("OK_%s", prefix)),
+	}.Args()
 }
 
 func NewNetOutDefaultUDPLogRule(prefix string, acceptedUDPLogsPerSec int) IPTablesRule {
-	return IPTablesRule{
-		"-p", "udp",
-		"-m", "limit", "--limit", fmt.Sprintf("%d/s", acceptedUDPLogsPerSec),
-		"--limit-burst", strconv.Itoa(acceptedUDPLogsPerSec),
-		"-j", "LOG", "--log-prefix", trimAndPad(fmt.Sprintf("OK_%s", prefix)),
-	}
+	return Rule{
+		Protocol:  Match{Value: "udp"},
+		Limit:     perSecondLimit(acceptedUDPLogsPerSec),
+		Jump:      "LOG",
+		LogPrefix: logPrefix(fmt.Sprintf("OK_%s", prefix)),
+	}.Args()
 }
 
 func NewAcceptRule() IPTablesRule {
-	return IPTablesRule{
-		"--jump", "ACCEPT",
-	}
+	return Rule{
+		Jump: "ACCEPT",
+	}.Args()
 }
 
 func NewAcceptEverythingRule(ipRange string) IPTablesRule {
-	return IPTablesRule{
-		"-s", ipRange, "-d", ipRange, "-j", "ACCEPT",
-	}
+	return Rule{
+		Source:      Match{Value: ipRange},
+		Destination: Match{Value: ipRange},
+		Jump:        "ACCEPT",
+	}.Args()
 }
 
 func NewInputRelatedEstablishedRule() IPTablesRule {
-	return IPTablesRule{
-		"-m", "state", "--state", "RELATED,ESTABLISHED",
-		"--jump", "ACCEPT",
-	}
+	return Rule{
+		State: "RELATED,ESTABLISHED",
+		Jump:  "ACCEPT",
+	}.Args()
 }
 
 func NewInputAllowRule(protocol, destination string, destPort int) IPTablesRule {
-	return IPTablesRule{
-		"-p", protocol,
-		"-d", destination, "--destination-port", strconv.Itoa(destPort),
-		"--jump", "ACCEPT",
-	}
+	return Rule{
+		Destination:      Match{Value: destination},
+		Protocol:         Match{Value: protocol},
+		DestinationPorts: &Ports{Start: destPort, End: destPort},
+		Jump:             "ACCEPT",
+	}.Args()
 }
 
 func NewInputRejectRule(destinationIP string) IPTablesRule {
-	return IPTablesRule{
-		"-d", destinationIP,
-		"--jump", "REJECT",
-		"--reject-with", "icmp-port-unreachable",
-	}
+	return Rule{
+		Destination: Match{Value: destinationIP},
+		Jump:        "REJECT",
+		RejectWith:  "icmp-port-unreachable",
+	}.Args()
 }
 
 func NewInputDefaultRejectRule() IPTablesRule {
-	return IPTablesRule{
-		"--jump", "REJECT",
-		"--reject-with", "icmp-port-unreachable",
-	}
+	return Rule{
+		Jump:       "REJECT",
+		RejectWith: "icmp-port-unreachable",
+	}.Args()
 }
 
 func NewNetOutInvalidRule() IPTablesRule {
-	return IPTablesRule{
-		"-p", "tcp", "-m", "state", "--state", "INVALID",
-		"--jump", "DROP",
-	}
+	return Rule{
+		Protocol: Match{Value: "tcp"},
+		State:    "INVALID",
+		Jump:     "DROP",
+	}.Args()
 }
 
 func NewNetOutRelatedEstablishedRule() IPTablesRule {
-	return IPTablesRule{
-		"-m", "state", "--state", "RELATED,ESTABLISHED",
-		"--jump", "ACCEPT",
-	}
+	return Rule{
+		State: "RELATED,ESTABLISHED",
+		Jump:  "ACCEPT",
+	}.Args()
 }
 
 func NewNetOutConnRateLimitRule(rate, burst, containerHandle, expiryPeriod, rateLimitLogChainName string) IPTablesRule {
-	return IPTablesRule{
-		"-p", "tcp",
-		"-m", "conntrack", "--ctstate", "NEW",
-		"-m", "hashlimit", "--hashlimit-above", rate, "--hashlimit-burst", burst,
-		"--hashlimit-mode", "dstip,dstport", "--hashlimit-name", containerHandle,
-		"--hashlimit-htable-expire", expiryPeriod, "-j", rateLimitLogChainName,
-	}
+	return Rule{
+		Protocol:  Match{Value: "tcp"},
+		ConnState: "NEW",
+		HashLimit: &HashLimit{
+			Above:        rate,
+			Burst:        burst,
+			Mode:         "dstip,dstport",
+			Name:         containerHandle,
+			HTableExpire: expiryPeriod,
+		},
+		Jump: rateLimitLogChainName,
+	}.Args()
 }
 
 func NewOverlayTagAcceptRule(containerIP, tag string) IPTablesRule {
-	return IPTablesRule{
-		"-d", containerIP,
-		"-m", "mark", "--mark", fmt.Sprintf("0x%s", tag),
-		"--jump", "ACCEPT",
-	}
+	return Rule{
+		Destination: Match{Value: containerIP},
+		Mark:        Match{Value: fmt.Sprintf("0x%s", tag)},
+		Jump:        "ACCEPT",
+	}.Args()
 }
 
 func NewOverlayDefaultRejectRule(containerIP string) IPTablesRule {
-	return IPTablesRule{
-		"-d", containerIP,
-		"--jump", "REJECT",
-		"--reject-with", "icmp-port-unreachable",
-	}
+	return Rule{
+		Destination: Match{Value: containerIP},
+		Jump:        "REJECT",
+		RejectWith:  "icmp-port-unreachable",
+	}.Args()
 }
 
 func NewOverlayDefaultRejectLogRule(containerHandle, containerIP string, deniedLogsPerSec int) IPTablesRule {
-	return IPTablesRule{
-		"-d", containerIP,
-		"-m", "limit", "--limit", fmt.Sprintf("%d/s", deniedLogsPerSec),
-		"--limit-burst", strconv.Itoa(deniedLogsPerSec),
-		"--jump", "LOG",
-		"--log-prefix", trimAndPad(fmt.Sprintf("DENY_C2C_%s", containerHandle)),
-	}
+	return Rule{
+		Destination: Match{Value: containerIP},
+		Limit:       perSecondLimit(deniedLogsPerSec),
+		Jump:        "LOG",
+		LogPrefix:   logPrefix(fmt.Sprintf("DENY_C2C_%s", containerHandle)),
+	}.Args()
 }
 
 func NewOverlayAllowEgress(deviceName, containerIP string) IPTablesRule {
-	return IPTablesRule{
-		"-s", containerIP,
-		"-o", deviceName,
-		"-m", "mark", "!", "--mark", "0x0",
-		"--jump", "ACCEPT",
-	}
+	return Rule{
+		Source:       Match{Value: containerIP},
+		OutInterface: Match{Value: deviceName},
+		Mark:         Match{Value: "0x0", Negate: true},
+		Jump:         "ACCEPT",
+	}.Args()
 }
 
 func NewOverlayRelatedEstablishedRule(containerIP string) IPTablesRule {
-	return IPTablesRule{
-		"-d", containerIP,
-		"-m", "state", "--state", "RELATED,ESTABLISHED",
-		"--jump", "ACCEPT",
-	}
+	return Rule{
+		Destination: Match{Value: containerIP},
+		State:       "RELATED,ESTABLISHED",
+		Jump:        "ACCEPT",
+	}.Args()
 }
 
 func NewNetOutDefaultRejectLogRule(containerHandle string, deniedLogsPerSec int) IPTablesRule {
@@ -352,32 +443,70 @@ func NewNetOutConnRateLimitRejectLogRule(containerHandle string, deniedLogsPerSe
 }
 
 func NewNetOutDefaultRejectRule() IPTablesRule {
-	return IPTablesRule{
-		"--jump", "REJECT",
-		"--reject-with", "icmp-port-unreachable",
-	}
-}
-
-func NewOverlayAccessMarkRule(tag string) IPTablesRule {
-	return IPTablesRule{
-		"-o", "silk-vtep",
-		"-j", "MARK",
-		"--set-mark", fmt.Sprintf("0x%s", tag),
+	return Rule{
+		Jump:       "REJECT",
+		RejectWith: "icmp-port-unreachable",
+	}.Args()
+}
+
+func NewOverlayAccessMarkRule(vtepName, tag string) IPTablesRule {
+	return Rule{
+		OutInterface: Match{Value: vtepName},
+		Jump:         "MARK",
+		SetMark:      fmt.Sprintf("0x%s", tag),
+	}.Args()
+}
+
+// NewOverlayIsolationRules drops forwarded traffic that crosses from the
+// overlay network into another overlay. Only the overlay's own addresses may
+// leave through its VTEP, and traffic arriving on its VTEP may only reach the
+// overlay. The VTEP rules are skipped when vtepName is empty.
+func NewOverlayIsolationRules(network, vtepName string, otherNetworks []string) []IPTablesRule {
+	var isolation []IPTablesRule
+	if vtepName != "" {
+		isolation = append(isolation,
+			Rule{
+				InInterface: Match{Value: vtepName},
+				Destination: Match{Value: network, Negate: true},
+				Jump:        "DROP",
+			}.Args(),
+			Rule{
+				Source:       Match{Value: network, Negate: true},
+				OutInterface: Match{Value: vtepName},
+				Jump:         "DROP",
+			}.Args(),
+		)
+	}
+	for _, other := range otherNetworks {
+		isolation = append(isolation, Rule{
+			Source:      Match{Value: network},
+			Destination: Match{Value: other},
+			Jump:        "DROP",
+		}.Args())
+	}
+	return isolation
+}
+
+// logPrefix trims the name to fit the log prefix limit and pads it so that
+// the logged fields stay separated.
+func logPrefix(name string) string {
+	if len(name) > 28 {
+		name = name[:28]
 	}
+	return name + " "
 }
 
-func trimAndPad(name string) string {
-	if len(name) > 28 {
-		name = name[:28]
+func perSecondLimit(perSec int) *Limit {
+	return &Limit{
+		Rate:  fmt.Sprintf("%d/sec", perSec),
+		Burst: strconv.Itoa(perSec),
 	}
-	return fmt.Sprintf(`"%s "`, name)
 }
 
 func newNetOutRejectLogRule(containerHandle, prefix string, deniedLogsPerSec int) IPTablesRule {
-	return IPTablesRule{
-		"-m", "limit", "--limit", fmt.Sprintf("%d/s", deniedLogsPerSec),
-		"--limit-burst", strconv.Itoa(deniedLogsPerSec),
-		"--jump", "LOG",
-		"--log-prefix", trimAndPad(fmt.Sprintf("%s_%s", prefix, containerHandle)),
-	}
+	return Rule{
+		Limit:     perSecondLimit(deniedLogsPerSec),
+		Jump:      "LOG",
+		LogPrefix: logPrefix(fmt.Sprintf("%s_%s", prefix, containerHandle)),
+	}.Args()
 }
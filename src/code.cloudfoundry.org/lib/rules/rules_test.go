@@ -1,8 +1,6 @@
 package rules_test
 
 import (
-	"fmt"
-
 	"code.cloudfoundry.org/lib/rules"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -11,62 +9,118 @@ import (
 )
 
 var _ = Describe("Rules", func() {
-	Describe("NewIPTablesRuleFromIPTablesLine", func() {
-		It("parses rules properly", func() {
-			rule, err := rules.NewIPTablesRuleFromIPTablesLine(`-D netout--ee8fd40b-55f6-4522-5 -m limit --limit 1/sec --limit-burst 1 -j LOG --log-prefix "DENY_ee8fd40b-55f6-4522-51d9 "`)
-			Expect(err).ToNot(HaveOccurred())
+	Describe("AppendComment", func() {
+		It("adds the comment to the iptables rule, replacing spaces with underscores", func() {
+			rule := rules.AppendComment(rules.IPTablesRule{"-d", "1.2.3.4", "-j", "ACCEPT"}, `some:comment statement`)
 			Expect(rule).To(Equal(rules.IPTablesRule{
-				"-D", "netout--ee8fd40b-55f6-4522-5", "-m", "limit",
-				"--limit", "1/sec", "--limit-burst", "1", "-j", "LOG",
-				"--log-prefix", "DENY_ee8fd40b-55f6-4522-51d9 ",
+				"-d", "1.2.3.4", "-m", "comment", "--comment", `some:comment_statement`, "-j", "ACCEPT",
 			}))
 		})
-		It("throws errors for invalid strings", func() {
-			_, err := rules.NewIPTablesRuleFromIPTablesLine(`--log-prefix "DENY_ee8fd40b-55f6-4522-51d9 `)
-			Expect(err).To(HaveOccurred())
-			Expect(err).To(MatchError(fmt.Errorf("EOF found when expecting closing quote")))
+
+		Context("when the rule cannot be parsed", func() {
+			It("appends the comment", func() {
+				rule := rules.AppendComment(rules.IPTablesRule{"some", "rule"}, `some:comment statement`)
+				Expect(rule).To(Equal(rules.IPTablesRule{
+					"some", "rule", "-m", "comment", "--comment", `some:comment_statement`,
+				}))
+			})
 		})
 	})
-	Describe("AppendComment", func() {
-		var originalRule rules.IPTablesRule
-		BeforeEach(func() {
-			originalRule = rules.IPTablesRule{"some", "rule"}
+
+	Describe("MatchInputInterface", func() {
+		It("adds the input interface match to the iptables rule", func() {
+			rule := rules.MatchInputInterface(rules.IPTablesRule{"-d", "1.2.3.4", "-j", "ACCEPT"}, "some-device")
+			Expect(rule).To(Equal(rules.IPTablesRule{
+				"-d", "1.2.3.4", "-i", "some-device", "-j", "ACCEPT",
+			}))
+		})
+
+		Context("when the rule cannot be parsed", func() {
+			It("prepends the input interface match", func() {
+				rule := rules.MatchInputInterface(rules.IPTablesRule{"some", "rule"}, "some-device")
+				Expect(rule).To(Equal(rules.IPTablesRule{
+					"-i", "some-device", "some", "rule",
+				}))
+			})
 		})
-		It("appends the comment to the iptables rule, replacing spaces with underscores", func() {
-			rule := rules.AppendComment(originalRule, `some:comment statement`)
+	})
+
+	Describe("Reject", func() {
+		It("replaces the target with a reject", func() {
+			rule := rules.Reject(rules.IPTablesRule{"-m", "iprange", "--dst-range", "1.2.3.4-1.2.3.5", "-g", "some-log-chain"})
 			Expect(rule).To(Equal(rules.IPTablesRule{
-				"some", "rule", "-m", "comment", "--comment", `some:comment_statement`,
+				"-m", "iprange", "--dst-range", "1.2.3.4-1.2.3.5", "-j", "REJECT", "--reject-with", "icmp-port-unreachable",
 			}))
 		})
+
+		Context("when the rule cannot be parsed", func() {
+			It("appends the reject target", func() {
+				rule := rules.Reject(rules.IPTablesRule{"some", "rule"})
+				Expect(rule).To(Equal(rules.IPTablesRule{
+					"some", "rule", "-j", "REJECT", "--reject-with", "icmp-port-unreachable",
+				}))
+			})
+		})
 	})
 
 	Describe("NewDefaultEgressRule", func() {
 		It("should generate a new rule from the source, not to the CIDR range, not to the device which causes a MASQUERADE", func() {
 			rule := rules.NewDefaultEgressRule("10.255.27.5/32", "10.255.0.0/16", "silk-vtep")
+			Expect(parse(rule)).To(Equal(rules.Rule{
+				Source:       rules.Match{Value: "10.255.27.5"},
+				Destination:  rules.Match{Value: "10.255.0.0/16", Negate: true},
+				OutInterface: rules.Match{Value: "silk-vtep", Negate: true},
+				Jump:         "MASQUERADE",
+			}))
 			Expect(rule).To(Equal(rules.IPTablesRule{
-				"--source", "10.255.27.5/32",
+				"-s", "10.255.27.5",
+				"!", "-d", "10.255.0.0/16",
 				"!", "-o", "silk-vtep",
-				"!", "--destination", "10.255.0.0/16",
-				"--jump", "MASQUERADE",
+				"-j", "MASQUERADE",
 			}))
 		})
 
 		Context("when no masquerade cidr range is not provided", func() {
 			It("should generate a rule that does not check the destination for the no masquerade cidr range", func() {
 				rule := rules.NewDefaultEgressRule("10.255.27.5/32", "", "silk-vtep")
-				Expect(rule).To(Equal(rules.IPTablesRule{
-					"--source", "10.255.27.5/32",
-					"!", "-o", "silk-vtep",
-					"--jump", "MASQUERADE",
+				Expect(parse(rule)).To(Equal(rules.Rule{
+					Source:       rules.Match{Value: "10.255.27.5"},
+					OutInterface: rules.Match{Value: "silk-vtep", Negate: true},
+					Jump:         "MASQUERADE",
 				}))
 			})
 		})
 	})
 
+	Describe("NewEgressSNATRule", func() {
+		It("should generate a rule like the default egress rule that SNATs to the egress IP", func() {
+			rule := rules.NewEgressSNATRule("10.255.27.5/32", "10.255.0.0/16", "silk-vtep", "10.0.16.20")
+			Expect(parse(rule)).To(Equal(rules.Rule{
+				Source:       rules.Match{Value: "10.255.27.5"},
+				Destination:  rules.Match{Value: "10.255.0.0/16", Negate: true},
+				OutInterface: rules.Match{Value: "silk-vtep", Negate: true},
+				Jump:         "SNAT",
+				ToSource:     "10.0.16.20",
+			}))
+			Expect(rule).To(Equal(rules.IPTablesRule{
+				"-s", "10.255.27.5",
+				"!", "-d", "10.255.0.0/16",
+				"!", "-o", "silk-vtep",
+				"-j", "SNAT",
+				"--to-source", "10.0.16.20",
+			}))
+		})
+	})
+
 	Describe("NewLogRule", func() {
 		Context("when the log prefix is greater than 28 characters", func() {
 			It("shortens the log-prefix to 28 characters and adds a space", func() {
 				rule := rules.NewLogRule([]string{}, "some-very-very-very-long-app-guid")
+				Expect(parse(rule)).To(Equal(rules.Rule{
+					Limit:     &rules.Limit{Rate: "2/min"},
+					Jump:      "LOG",
+					LogPrefix: "some-very-very-very-long-app ",
+				}))
 				Expect(rule).To(ContainElement(`"some-very-very-very-long-app "`))
 			})
 		})
@@ -77,30 +131,28 @@ var _ = Describe("Rules", func() {
 			Context("when the protocol is not udp", func() {
 				It("shortens the log-prefix to 28 characters and adds a space", func() {
 					rule := rules.NewMarkAllowLogRule("10.255.0.1", "tcp", 80, 80, "0", "some-very-very-very-long-app-guid", -1)
-					Expect(rule).To(Equal(rules.IPTablesRule{
-						"-d", "10.255.0.1",
-						"-p", "tcp",
-						"--dport", "80:80",
-						"-m", "mark", "--mark", "0x0",
-						"-m", "conntrack", "--ctstate", "INVALID,NEW,UNTRACKED",
-						"--jump", "LOG", "--log-prefix",
-						`"OK_0_some-very-very-very-lon "`,
+					Expect(parse(rule)).To(Equal(rules.Rule{
+						Destination:      rules.Match{Value: "10.255.0.1"},
+						Protocol:         rules.Match{Value: "tcp"},
+						DestinationPorts: &rules.Ports{Start: 80, End: 80},
+						Mark:             rules.Match{Value: "0x0"},
+						ConnState:        "INVALID,NEW,UNTRACKED",
+						Jump:             "LOG",
+						LogPrefix:        "OK_0_some-very-very-very-lon ",
 					}))
 				})
 			})
 			Context("when the protocol is udp", func() {
 				It("does not use conntrack", func() {
 					rule := rules.NewMarkAllowLogRule("10.255.0.1", "udp", 80, 80, "0", "some-very-very-very-long-app-guid", 4)
-					Expect(rule).To(Equal(rules.IPTablesRule{
-						"-d", "10.255.0.1",
-						"-p", "udp",
-						"--dport", "80:80",
-						"-m", "mark", "--mark", "0x0",
-						"-m", "limit",
-						"--limit", "4/s",
-						"--limit-burst", "4",
-						"--jump", "LOG", "--log-prefix",
-						`"OK_0_some-very-very-very-lon "`,
+					Expect(parse(rule)).To(Equal(rules.Rule{
+						Destination:      rules.Match{Value: "10.255.0.1"},
+						Protocol:         rules.Match{Value: "udp"},
+						DestinationPorts: &rules.Ports{Start: 80, End: 80},
+						Mark:             rules.Match{Value: "0x0"},
+						Limit:            &rules.Limit{Rate: "4/sec", Burst: "4"},
+						Jump:             "LOG",
+						LogPrefix:        "OK_0_some-very-very-very-lon ",
 					}))
 				})
 
@@ -112,12 +164,12 @@ var _ = Describe("Rules", func() {
 		Context("when the log prefix is greater than 28 characters", func() {
 			It("shortens the log-prefix to 28 characters and adds a space", func() {
 				rule := rules.NewNetOutDefaultNonUDPLogRule("some-very-very-very-long-app-guid")
-				Expect(rule).To(Equal(rules.IPTablesRule{
-					"!", "-p", "udp",
-					"-m", "conntrack", "--ctstate", "INVALID,NEW,UNTRACKED",
-					"-j", "LOG", "--log-prefix", `"OK_some-very-very-very-long- "`,
-				},
-				))
+				Expect(parse(rule)).To(Equal(rules.Rule{
+					Protocol:  rules.Match{Value: "udp", Negate: true},
+					ConnState: "INVALID,NEW,UNTRACKED",
+					Jump:      "LOG",
+					LogPrefix: "OK_some-very-very-very-long- ",
+				}))
 			})
 		})
 	})
@@ -126,12 +178,11 @@ var _ = Describe("Rules", func() {
 		Context("when the log prefix is greater than 28 characters", func() {
 			It("shortens the log-prefix to 28 characters and adds a space", func() {
 				rule := rules.NewNetOutDefaultUDPLogRule("some-very-very-very-long-app-guid", 5)
-				Expect(rule).To(Equal(rules.IPTablesRule{
-					"-p", "udp",
-					"-m", "limit",
-					"--limit", "5/s",
-					"--limit-burst", "5",
-					"-j", "LOG", "--log-prefix", `"OK_some-very-very-very-long- "`,
+				Expect(parse(rule)).To(Equal(rules.Rule{
+					Protocol:  rules.Match{Value: "udp"},
+					Limit:     &rules.Limit{Rate: "5/sec", Burst: "5"},
+					Jump:      "LOG",
+					LogPrefix: "OK_some-very-very-very-long- ",
 				}))
 			})
 		})
@@ -142,7 +193,7 @@ var _ = Describe("Rules", func() {
 			It("shortens the log-prefix to 28 characters and adds a space", func() {
 				rule := rules.NewOverlayDefaultRejectLogRule("some-very-very-very-long-app-guid", "", 5)
 				Expect(rule).To(gomegamatchers.ContainSequence(rules.IPTablesRule{
-					"-m", "limit", "--limit", "5/s", "--limit-burst", "5",
+					"-m", "limit", "--limit", "5/sec", "--limit-burst", "5",
 				}))
 				Expect(rule).To(ContainElement(`"DENY_C2C_some-very-very-very "`))
 			})
@@ -154,7 +205,7 @@ var _ = Describe("Rules", func() {
 			It("shortens the log-prefix to 28 characters and adds a space", func() {
 				rule := rules.NewNetOutDefaultRejectLogRule("some-very-very-very-long-app-guid", 3)
 				Expect(rule).To(gomegamatchers.ContainSequence(rules.IPTablesRule{
-					"-m", "limit", "--limit", "3/s", "--limit-burst", "3",
+					"-m", "limit", "--limit", "3/sec", "--limit-burst", "3",
 				}))
 				Expect(rule).To(ContainElement(`"DENY_some-very-very-very-lon "`))
 			})
@@ -166,35 +217,66 @@ var _ = Describe("Rules", func() {
 			It("shortens the log-prefix to 28 characters and adds a space", func() {
 				rule := rules.NewNetOutConnRateLimitRejectLogRule("some-very-very-very-long-app-guid", 5)
 				Expect(rule).To(gomegamatchers.ContainSequence(rules.IPTablesRule{
-					"-m", "limit", "--limit", "5/s", "--limit-burst", "5",
+					"-m", "limit", "--limit", "5/sec", "--limit-burst", "5",
 				}))
 				Expect(rule).To(ContainElement(`"DENY_ORL_some-very-very-very "`))
 			})
 		})
 	})
 
+	Describe("NewPortForwardingRule", func() {
+		It("forwards a single host port to the container port", func() {
+			rule := rules.NewPortForwardingRule("tcp", 61000, 61000, 8080, "10.0.0.1", "10.255.0.1")
+			Expect(rule).To(Equal(rules.IPTablesRule{
+				"-d", "10.0.0.1", "-p", "tcp",
+				"-m", "tcp", "--dport", "61000",
+				"-j", "DNAT",
+				"--to-destination", "10.255.0.1:8080",
+			}))
+		})
+
+		It("forwards a range of host ports to the same container ports", func() {
+			rule := rules.NewPortForwardingRule("udp", 61000, 61010, 61000, "10.0.0.1", "10.255.0.1")
+			Expect(rule).To(Equal(rules.IPTablesRule{
+				"-d", "10.0.0.1", "-p", "udp",
+				"-m", "udp", "--dport", "61000:61010",
+				"-j", "DNAT",
+				"--to-destination", "10.255.0.1",
+			}))
+		})
+	})
+
 	Describe("NewIngressMarkRules", func() {
 		It("creates a jump rule when given one interface", func() {
-			jumpRule := rules.NewIngressMarkRules([]string{"eth0"}, 2000, "2.3.4.5", "1")
+			jumpRule := rules.NewIngressMarkRules([]string{"eth0"}, "tcp", 2000, 2000, "2.3.4.5", "1")
 			Expect(jumpRule[0]).To(Equal(rules.IPTablesRule{
-				"-i", "eth0", "-d", "2.3.4.5", "-p", "tcp",
+				"-d", "2.3.4.5", "-i", "eth0", "-p", "tcp",
 				"-m", "tcp", "--dport", "2000",
-				"--jump", "MARK",
+				"-j", "MARK",
+				"--set-mark", "0x1",
+			}))
+		})
+		It("matches the protocol and port range", func() {
+			jumpRule := rules.NewIngressMarkRules([]string{"eth0"}, "udp", 2000, 2010, "2.3.4.5", "1")
+			Expect(jumpRule[0]).To(Equal(rules.IPTablesRule{
+				"-d", "2.3.4.5", "-i", "eth0", "-p", "udp",
+				"-m", "udp", "--dport", "2000:2010",
+				"-j", "MARK",
 				"--set-mark", "0x1",
 			}))
 		})
 		It("creates the same jump rule for each interface", func() {
-			jumpRules := rules.NewIngressMarkRules([]string{"eth0", "gandalf"}, 2000, "2.3.4.5", "1")
+			jumpRules := rules.NewIngressMarkRules([]string{"eth0", "gandalf"}, "tcp", 2000, 2000, "2.3.4.5", "1")
 			Expect(jumpRules[0]).To(Equal(rules.IPTablesRule{
-				"-i", "eth0", "-d", "2.3.4.5", "-p", "tcp",
+				"-d", "2.3.4.5", "-i", "eth0", "-p", "tcp",
 				"-m", "tcp", "--dport", "2000",
-				"--jump", "MARK",
+				"-j", "MARK",
 				"--set-mark", "0x1",
 			}))
 			Expect(jumpRules[1]).To(Equal(rules.IPTablesRule{
-				"-i", "gandalf", "-d", "2.3.4.5", "-p", "tcp",
+				"-d", "2.3.4.5", "-i", "gandalf", "-p", "tcp",
 				"-m", "tcp", "--dport", "2000",
-				"--jump", "MARK",
+				"-j", "MARK",
 				"--set-mark", "0x1",
 			}))
 		})
@@ -206,7 +288,7 @@ var _ = Describe("Rules", func() {
 			Expect(jumpRule[0]).To(Equal(rules.IPTablesRule{
 				"-s", "1.2.3.4",
 				"-o", "eth0",
-				"--jump", "a-chain",
+				"-j", "a-chain",
 			}))
 		})
 
@@ -215,24 +297,50 @@ var _ = Describe("Rules", func() {
 			Expect(jumpRules[0]).To(Equal(rules.IPTablesRule{
 				"-s", "1.2.3.4",
 				"-o", "eth0",
-				"--jump", "side-chain",
+				"-j", "side-chain",
 			}))
 			Expect(jumpRules[1]).To(Equal(rules.IPTablesRule{
 				"-s", "1.2.3.4",
 				"-o", "dumbledore",
-				"--jump", "side-chain",
+				"-j", "side-chain",
 			}))
 		})
 	})
 
 	Describe("NewOverlayAccessMarkRule", func() {
 		It("create a overlay rule to mark the packet with a tag", func() {
-			rule := rules.NewOverlayAccessMarkRule("0009")
+			rule := rules.NewOverlayAccessMarkRule("silk-vtep-iso", "0009")
 			Expect(rule).To(Equal(rules.IPTablesRule{
-				"-o", "silk-vtep",
+				"-o", "silk-vtep-iso",
 				"-j", "MARK",
-				"--set-mark", "0x0009",
+				"--set-mark", "0x9",
 			}))
 		})
 	})
+
+	Describe("NewOverlayIsolationRules", func() {
+		It("drops traffic between the overlay and the other overlays", func() {
+			isolation := rules.NewOverlayIsolationRules("10.100.0.0/16", "silk-vtep-iso", []string{"10.255.0.0/16"})
+			Expect(isolation).To(Equal([]rules.IPTablesRule{
+				{"!", "-d", "10.100.0.0/16", "-i", "silk-vtep-iso", "-j", "DROP"},
+				{"!", "-s", "10.100.0.0/16", "-o", "silk-vtep-iso", "-j", "DROP"},
+				{"-s", "10.100.0.0/16", "-d", "10.255.0.0/16", "-j", "DROP"},
+			}))
+		})
+
+		Context("when the overlay has no VTEP name", func() {
+			It("only drops traffic to the other overlays", func() {
+				isolation := rules.NewOverlayIsolationRules("10.255.0.0/16", "", []string{"10.100.0.0/16"})
+				Expect(isolation).To(Equal([]rules.IPTablesRule{
+					{"-s", "10.255.0.0/16", "-d", "10.100.0.0/16", "-j", "DROP"},
+				}))
+			})
+		})
+	})
 })
+
+func parse(args rules.IPTablesRule) rules.Rule {
+	rule, err := rules.ParseRule(args)
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+	return rule
+}
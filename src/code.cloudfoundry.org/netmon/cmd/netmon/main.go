@@ -62,31 +62,38 @@ func main() {
 		pollInterval = time.Second
 	}
 
-	ipt, err := iptables.New()
-	if err != nil {
-		logger.Fatal("iptables-new", err)
-	}
-
 	iptLocker := &filelock.Locker{
 		FileLocker: filelock.NewLocker(conf.IPTablesLockFile),
 		Mutex:      &sync.Mutex{},
 	}
-	restorer := &rules.Restorer{}
 
-	executablePath, err := exec.LookPath("iptables")
-	if err != nil {
-		logger.Fatal("commandrunner-new", err)
-	}
+	var lockedIPTables rules.IPTablesAdapter
+	if conf.FirewallBackend == rules.NFTablesBackend {
+		lockedIPTables, err = rules.NewNFTables(iptLocker)
+		if err != nil {
+			logger.Fatal("nftables-new", err)
+		}
+	} else {
+		ipt, err := iptables.New()
+		if err != nil {
+			logger.Fatal("iptables-new", err)
+		}
 
-	iptablesCommandRunner := runner.CommandRunner{
-		Executable: executablePath,
-	}
+		executablePath, err := exec.LookPath("iptables")
+		if err != nil {
+			logger.Fatal("commandrunner-new", err)
+		}
 
-	lockedIPTables := &rules.LockedIPTables{
-		IPTables:       ipt,
-		Locker:         iptLocker,
-		Restorer:       restorer,
-		IPTablesRunner: iptablesCommandRunner,
+		iptablesCommandRunner := runner.CommandRunner{
+			Executable: executablePath,
+		}
+
+		lockedIPTables = &rules.LockedIPTables{
+			IPTables:       ipt,
+			Locker:         iptLocker,
+			Restorer:       &rules.Restorer{},
+			IPTablesRunner: iptablesCommandRunner,
+		}
 	}
 
 	err = dropsonde.Initialize(conf.MetronAddress, "netmon")
@@ -10,6 +10,7 @@ import (
 	"gopkg.in/validator.v2"
 
 	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lib/rules"
 )
 
 type Netmon struct {
@@ -19,6 +20,7 @@ type Netmon struct {
 	LogLevel          string `json:"log_level"`
 	LogPrefix         string `json:"log_prefix" validate:"nonzero"`
 	IPTablesLockFile  string `json:"iptables_lock_file" validate:"nonzero"`
+	FirewallBackend   string `json:"firewall_backend"`
 	TelemetryEnabled  bool   `json:"telemetry_enabled"`
 	TelemetryInterval int    `json:"telemetry_interval"`
 }
@@ -41,6 +43,9 @@ func (c *Netmon) Validate() error {
 	if c.TelemetryEnabled && c.TelemetryInterval <= 0 {
 		return errors.New("telemetry_interval must be set to a positive, non-zero value if telemetry_enabled is true")
 	}
+	if err := rules.ValidateBackend(c.FirewallBackend); err != nil {
+		return err
+	}
 	return validator.Validate(c)
 }
 
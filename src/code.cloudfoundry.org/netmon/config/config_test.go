@@ -61,6 +61,7 @@ var _ = Describe("Config", func() {
 					"log_level": "debug",
 					"log_prefix": "cfnetworking",
 					"iptables_lock_file": "iptables-lock-file",
+					"firewall_backend": "nftables",
 					"telemetry_enabled": true,
 					"telemetry_interval": 2345
 				}`)
@@ -72,6 +73,7 @@ var _ = Describe("Config", func() {
 				Expect(c.LogLevel).To(Equal("debug"))
 				Expect(c.LogPrefix).To(Equal("cfnetworking"))
 				Expect(c.IPTablesLockFile).To(Equal("iptables-lock-file"))
+				Expect(c.FirewallBackend).To(Equal("nftables"))
 				Expect(c.TelemetryEnabled).To(BeTrue())
 				Expect(c.TelemetryInterval).To(Equal(2345))
 			})
@@ -154,6 +156,25 @@ var _ = Describe("Config", func() {
 			})
 		})
 
+		Context("when the firewall backend is not supported", func() {
+			It("returns an error", func() {
+				allData := map[string]interface{}{
+					"poll_interval":      1234,
+					"metron_address":     "http://1.2.3.4:1234",
+					"interface_name":     "eth0",
+					"log_level":          "debug",
+					"log_prefix":         "cfnetworking",
+					"iptables_lock_file": "some-lockfile",
+					"firewall_backend":   "ebtables",
+				}
+
+				Expect(json.NewEncoder(file).Encode(allData)).To(Succeed())
+
+				_, err = config.New(file.Name())
+				Expect(err).To(MatchError(`invalid config: invalid firewall backend "ebtables": must be iptables or nftables`))
+			})
+		})
+
 		DescribeTable("when config file is missing a member",
 			func(missingFlag, errorMsg string) {
 				allData := map[string]interface{}{
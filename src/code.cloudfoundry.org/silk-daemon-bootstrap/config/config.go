@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+
+	"code.cloudfoundry.org/lib/rules"
 )
 
 type SilkDaemonBootstrap struct {
@@ -12,9 +14,16 @@ type SilkDaemonBootstrap struct {
 	PolicyClientCertFile   string `json:"policy_client_cert_file"`
 	PolicyClientKeyFile    string `json:"policy_client_key_file"`
 	IPTablesLockFile       string `json:"iptables_lock_file"`
+	FirewallBackend        string `json:"firewall_backend"`
 	SingleIPOnly           bool   `json:"single_ip_only"`
+
+	// VTEPNames are the VTEP devices of the overlays the daemon manages.
+	// Traffic to any of them gets the overlay access mark.
+	VTEPNames []string `json:"vtep_names"`
 }
 
+const DefaultVTEPName = "silk-vtep"
+
 func New(configFilePath string) (*SilkDaemonBootstrap, error) {
 	contents, err := os.ReadFile(configFilePath)
 	if err != nil {
@@ -27,5 +36,13 @@ func New(configFilePath string) (*SilkDaemonBootstrap, error) {
 		return nil, fmt.Errorf("parsing config: %s", err)
 	}
 
+	if err := rules.ValidateBackend(silkDaemonBootstrap.FirewallBackend); err != nil {
+		return nil, fmt.Errorf("invalid config: %s", err)
+	}
+
+	if len(silkDaemonBootstrap.VTEPNames) == 0 {
+		silkDaemonBootstrap.VTEPNames = []string{DefaultVTEPName}
+	}
+
 	return &silkDaemonBootstrap, nil
 }
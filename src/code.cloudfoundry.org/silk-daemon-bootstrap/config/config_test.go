@@ -29,7 +29,9 @@ var _ = Describe("Config", func() {
 					"policy_client_cert_file": "/some/client/cert/file",
 					"policy_client_key_file": "/some/client/key/file",
 					"iptables_lock_file":  "/var/vcap/data/lock",
-					"single_ip_only": true
+					"firewall_backend": "nftables",
+					"single_ip_only": true,
+					"vtep_names": ["silk-vtep", "silk-vtep-iso"]
 				}`)
 				c, err := config.New(file.Name())
 				Expect(err).NotTo(HaveOccurred())
@@ -38,7 +40,26 @@ var _ = Describe("Config", func() {
 				Expect(c.PolicyClientCertFile).To(Equal("/some/client/cert/file"))
 				Expect(c.PolicyClientKeyFile).To(Equal("/some/client/key/file"))
 				Expect(c.IPTablesLockFile).To(Equal("/var/vcap/data/lock"))
+				Expect(c.FirewallBackend).To(Equal("nftables"))
 				Expect(c.SingleIPOnly).To(Equal(true))
+				Expect(c.VTEPNames).To(Equal([]string{"silk-vtep", "silk-vtep-iso"}))
+			})
+		})
+
+		Context("when no vtep names are configured", func() {
+			It("defaults to the default vtep", func() {
+				file.WriteString(`{"single_ip_only": true}`)
+				c, err := config.New(file.Name())
+				Expect(err).NotTo(HaveOccurred())
+				Expect(c.VTEPNames).To(Equal([]string{"silk-vtep"}))
+			})
+		})
+
+		Context("when the firewall backend is not supported", func() {
+			It("returns the error", func() {
+				file.WriteString(`{"firewall_backend": "ebtables"}`)
+				_, err = config.New(file.Name())
+				Expect(err).To(MatchError(`invalid config: invalid firewall backend "ebtables": must be iptables or nftables`))
 			})
 		})
 
@@ -120,6 +120,25 @@ var _ = Describe("Bootstrap", func() {
 			})
 		})
 
+		Context("when the daemon manages additional overlays", func() {
+			BeforeEach(func() {
+				bootstrapConfig.VTEPNames = []string{"silk-vtep", "silk-vtep-iso"}
+			})
+
+			It("marks traffic to every vtep", func() {
+				session := runBootstrap(bootstrapConfig)
+				Eventually(session, DEFAULT_TIMEOUT).Should(gexec.Exit(0))
+
+				rules := AllIPTablesRules("filter")
+				Expect(rules).To(ContainElements(
+					"-A istio-ingress -o silk-vtep -j MARK --set-xmark 0x9/0xffffffff",
+					"-A istio-ingress -o silk-vtep -j ACCEPT",
+					"-A istio-ingress -o silk-vtep-iso -j MARK --set-xmark 0x9/0xffffffff",
+					"-A istio-ingress -o silk-vtep-iso -j ACCEPT",
+				))
+			})
+		})
+
 		Context("when the client has the different certs", func() {
 			BeforeEach(func() {
 				bootstrapConfig.PolicyServerCACertFile = paths.OtherServerCACertFile
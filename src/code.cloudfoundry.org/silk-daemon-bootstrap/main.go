@@ -55,7 +55,7 @@ func mainWithError() error {
 			return err
 		}
 
-		ipTablesAdapter, err := createIpTablesAdapter(bootstrapConfig.IPTablesLockFile)
+		ipTablesAdapter, err := createIpTablesAdapter(bootstrapConfig.IPTablesLockFile, bootstrapConfig.FirewallBackend)
 		if err != nil {
 			return err
 		}
@@ -65,7 +65,13 @@ func mainWithError() error {
 			return err
 		}
 
-		return addOverlayAccessMarkRule(ipTablesAdapter, tag)
+		for _, vtepName := range bootstrapConfig.VTEPNames {
+			err = addOverlayAccessMarkRule(ipTablesAdapter, vtepName, tag)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
 	}
 
 	return nil
@@ -115,17 +121,21 @@ func getTagFromPolicyServer(policyClient *policyClient.InternalClient) (string,
 	return tag, nil
 }
 
-func createIpTablesAdapter(iptablesLockFile string) (rules.IPTablesAdapter, error) {
-	ipt, err := iptables.New()
-	if err != nil {
-		return nil, err
-	}
-
+func createIpTablesAdapter(iptablesLockFile, firewallBackend string) (rules.IPTablesAdapter, error) {
 	iptLocker := &filelock.Locker{
 		FileLocker: filelock.NewLocker(iptablesLockFile),
 		Mutex:      &sync.Mutex{},
 	}
 
+	if firewallBackend == rules.NFTablesBackend {
+		return rules.NewNFTables(iptLocker)
+	}
+
+	ipt, err := iptables.New()
+	if err != nil {
+		return nil, err
+	}
+
 	tables := &rules.LockedIPTables{
 		IPTables: ipt,
 		Locker:   iptLocker,
@@ -135,8 +145,8 @@ func createIpTablesAdapter(iptablesLockFile string) (rules.IPTablesAdapter, erro
 	return tables, nil
 }
 
-func addOverlayAccessMarkRule(iptables rules.IPTablesAdapter, tag string) error {
-	overlayAccessMarkRule := rules.NewOverlayAccessMarkRule(tag)
+func addOverlayAccessMarkRule(iptables rules.IPTablesAdapter, vtepName, tag string) error {
+	overlayAccessMarkRule := rules.NewOverlayAccessMarkRule(vtepName, tag)
 	exists, err := iptables.Exists("filter", IngressChainName, overlayAccessMarkRule)
 	if err == nil && !exists {
 		err = iptables.BulkAppend("filter", IngressChainName, overlayAccessMarkRule)
@@ -144,7 +154,7 @@ func addOverlayAccessMarkRule(iptables rules.IPTablesAdapter, tag string) error
 			return err
 		}
 	}
-	overlayAccessAllowRule := rules.IPTablesRule{"-o", "silk-vtep", "-j", "ACCEPT"}
+	overlayAccessAllowRule := rules.IPTablesRule{"-o", vtepName, "-j", "ACCEPT"}
 	exists, err = iptables.Exists("filter", IngressChainName, overlayAccessAllowRule)
 	if err == nil && !exists {
 		err = iptables.BulkAppend("filter", IngressChainName, overlayAccessAllowRule)
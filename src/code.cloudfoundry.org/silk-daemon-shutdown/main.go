@@ -50,9 +50,14 @@ func mainWithError() error {
 	pingServerTimeout := flag.Int("pingServerTimeout", 600, "timeout (seconds) when pinging if server is up")
 
 	iptablesLockFile := flag.String("iptablesLockFile", "", "path to iptablesLockFile")
+	firewallBackend := flag.String("firewallBackend", rules.IPTablesBackend, "firewall backend: iptables or nftables")
 
 	flag.Parse()
 
+	if err := rules.ValidateBackend(*firewallBackend); err != nil {
+		return err
+	}
+
 	fileCheckMaxAttempts := *fileCheckTimeout / *fileCheckInterval
 
 	lagerConfig := lagerflags.LagerConfig{
@@ -109,20 +114,27 @@ func mainWithError() error {
 		return fmt.Errorf("Silk Daemon Server did not exit after %d ping attempts", silkDaemonMaxAttempts)
 	}
 
-	ipt, err := iptables.New()
-	if err != nil {
-		return err
-	}
-
 	iptLocker := &filelock.Locker{
 		FileLocker: filelock.NewLocker(*iptablesLockFile),
 		Mutex:      &sync.Mutex{},
 	}
-	restorer := &rules.Restorer{}
-	lockedIPTables := &rules.LockedIPTables{
-		IPTables: ipt,
-		Locker:   iptLocker,
-		Restorer: restorer,
+
+	var lockedIPTables rules.IPTablesAdapter
+	if *firewallBackend == rules.NFTablesBackend {
+		lockedIPTables, err = rules.NewNFTables(iptLocker)
+		if err != nil {
+			return err
+		}
+	} else {
+		ipt, err := iptables.New()
+		if err != nil {
+			return err
+		}
+		lockedIPTables = &rules.LockedIPTables{
+			IPTables: ipt,
+			Locker:   iptLocker,
+			Restorer: &rules.Restorer{},
+		}
 	}
 
 	err = flushAndDeleteChain(lockedIPTables)
@@ -136,7 +148,7 @@ func mainWithError() error {
 	return err
 }
 
-func flushAndDeleteChain(lockedIPTables *rules.LockedIPTables) error {
+func flushAndDeleteChain(lockedIPTables rules.IPTablesAdapter) error {
 	jumpRule := rules.IPTablesRule{
 		"-j", IngressChainName,
 	}
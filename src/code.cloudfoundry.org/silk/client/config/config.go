@@ -8,6 +8,8 @@ import (
 	"gopkg.in/validator.v2"
 )
 
+const DefaultReadinessIntervals = 3
+
 type Config struct {
 	UnderlayIP                string `json:"underlay_ip" validate:"nonzero"`
 	VxlanInterfaceName        string `json:"vxlan_interface_name"`
@@ -15,10 +17,10 @@ type Config struct {
 	OverlayNetwork            string `json:"overlay_network" validate:"nonzero"`
 	HealthCheckPort           uint16 `json:"health_check_port" validate:"nonzero"`
 	VTEPName                  string `json:"vtep_name" validate:"nonzero"`
-	ConnectivityServerURL     string `json:"connectivity_server_url" validate:"nonzero"`
-	ServerCACertFile          string `json:"ca_cert_file" validate:"nonzero"`
-	ClientCertFile            string `json:"client_cert_file" validate:"nonzero"`
-	ClientKeyFile             string `json:"client_key_file" validate:"nonzero"`
+	ConnectivityServerURL     string `json:"connectivity_server_url"`
+	ServerCACertFile          string `json:"ca_cert_file"`
+	ClientCertFile            string `json:"client_cert_file"`
+	ClientKeyFile             string `json:"client_key_file"`
 	VNI                       int    `json:"vni" validate:"nonzero"`
 	VTEPPort                  int    `json:"vtep_port" validate:"min=1"`
 	PollInterval              int    `json:"poll_interval" validate:"nonzero"`
@@ -30,6 +32,91 @@ type Config struct {
 	LogPrefix                 string `json:"log_prefix" validate:"nonzero"`
 	LogLevel                  string `json:"log_level"`
 	SingleIPOnly              bool   `json:"single_ip_only"`
+
+	// StaticLeasesFile runs the daemon without a silk-controller. The local
+	// lease and the peer leases are read from this JSON or YAML file instead.
+	StaticLeasesFile string `json:"static_leases_file"`
+
+	AdditionalOverlays []OverlayConfig `json:"additional_overlays"`
+
+	// ReadinessIntervals is the number of poll intervals after a successful
+	// converge during which the daemon reports ready. It defaults to 3.
+	ReadinessIntervals int `json:"readiness_intervals"`
+
+	// DiagnosticServerPort enables the local diagnostic API. Requests must carry
+	// the contents of DiagnosticServerTokenFile as a bearer token.
+	DiagnosticServerPort      uint16 `json:"diagnostic_server_port"`
+	DiagnosticServerTokenFile string `json:"diagnostic_server_token_file"`
+}
+
+// OverlayConfig describes an additional VXLAN overlay managed by the daemon
+// alongside the default one. Each overlay has its own VTEP, VNI and lease,
+// which is acquired from the silk-controller at ConnectivityServerURL.
+type OverlayConfig struct {
+	Name                  string `json:"name" validate:"nonzero"`
+	VTEPName              string `json:"vtep_name" validate:"nonzero"`
+	VNI                   int    `json:"vni" validate:"nonzero"`
+	OverlayNetwork        string `json:"overlay_network" validate:"nonzero"`
+	SubnetPrefixLength    int    `json:"subnet_prefix_length" validate:"nonzero"`
+	ConnectivityServerURL string `json:"connectivity_server_url" validate:"nonzero"`
+}
+
+// ForOverlay returns a copy of the config with the overlay specific fields
+// replaced by those of the given additional overlay.
+func (c Config) ForOverlay(overlay OverlayConfig) Config {
+	c.VTEPName = overlay.VTEPName
+	c.VNI = overlay.VNI
+	c.OverlayNetwork = overlay.OverlayNetwork
+	c.SubnetPrefixLength = overlay.SubnetPrefixLength
+	c.ConnectivityServerURL = overlay.ConnectivityServerURL
+	c.AdditionalOverlays = nil
+	return c
+}
+
+func (c Config) validateControllerFields() error {
+	if c.StaticLeasesFile != "" {
+		if len(c.AdditionalOverlays) > 0 {
+			return fmt.Errorf("additional overlays are not supported with a static leases file")
+		}
+		return nil
+	}
+
+	required := []struct {
+		name  string
+		value string
+	}{
+		{"connectivity_server_url", c.ConnectivityServerURL},
+		{"ca_cert_file", c.ServerCACertFile},
+		{"client_cert_file", c.ClientCertFile},
+		{"client_key_file", c.ClientKeyFile},
+	}
+	for _, field := range required {
+		if field.value == "" {
+			return fmt.Errorf("missing %s", field.name)
+		}
+	}
+	return nil
+}
+
+func (c Config) validateOverlays() error {
+	names := map[string]bool{}
+	vtepNames := map[string]bool{c.VTEPName: true}
+	vnis := map[int]bool{c.VNI: true}
+	for _, overlay := range c.AdditionalOverlays {
+		if names[overlay.Name] {
+			return fmt.Errorf("duplicate overlay name: %s", overlay.Name)
+		}
+		if vtepNames[overlay.VTEPName] {
+			return fmt.Errorf("duplicate vtep name: %s", overlay.VTEPName)
+		}
+		if vnis[overlay.VNI] {
+			return fmt.Errorf("duplicate vni: %d", overlay.VNI)
+		}
+		names[overlay.Name] = true
+		vtepNames[overlay.VTEPName] = true
+		vnis[overlay.VNI] = true
+	}
+	return nil
 }
 
 func LoadConfig(filePath string) (Config, error) {
@@ -47,5 +134,24 @@ func LoadConfig(filePath string) (Config, error) {
 	if err := validator.Validate(cfg); err != nil {
 		return cfg, fmt.Errorf("invalid config: %s", err)
 	}
+
+	if err := cfg.validateControllerFields(); err != nil {
+		return cfg, fmt.Errorf("invalid config: %s", err)
+	}
+
+	if err := cfg.validateOverlays(); err != nil {
+		return cfg, fmt.Errorf("invalid config: %s", err)
+	}
+
+	if cfg.ReadinessIntervals < 0 {
+		return cfg, fmt.Errorf("invalid config: invalid readiness_intervals: %d", cfg.ReadinessIntervals)
+	}
+	if cfg.ReadinessIntervals == 0 {
+		cfg.ReadinessIntervals = DefaultReadinessIntervals
+	}
+
+	if cfg.DiagnosticServerPort != 0 && cfg.DiagnosticServerTokenFile == "" {
+		return cfg, fmt.Errorf("invalid config: missing diagnostic_server_token_file")
+	}
 	return cfg, nil
 }
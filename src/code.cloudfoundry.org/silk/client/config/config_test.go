@@ -106,4 +106,184 @@ var _ = Describe("Config.LoadConfig", func() {
 			Expect(loadedConfig.VxlanInterfaceName).To(Equal("something"))
 		})
 	})
+
+	Context("when additional overlays are specified", func() {
+		var overlay map[string]interface{}
+
+		BeforeEach(func() {
+			overlay = map[string]interface{}{
+				"name":                    "isolated",
+				"vtep_name":               "silk-vtep-iso",
+				"vni":                     2,
+				"overlay_network":         "10.254.0.0/16",
+				"subnet_prefix_length":    24,
+				"connectivity_server_url": "https://silk-controller-isolated.something",
+			}
+		})
+
+		writeConfig := func(cfg map[string]interface{}) string {
+			file, err := os.CreateTemp(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+			return file.Name()
+		}
+
+		It("loads the overlays", func() {
+			cfg := cloneMap(requiredFields)
+			cfg["additional_overlays"] = []interface{}{overlay}
+
+			loadedConfig, err := config.LoadConfig(writeConfig(cfg))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loadedConfig.AdditionalOverlays).To(Equal([]config.OverlayConfig{{
+				Name:                  "isolated",
+				VTEPName:              "silk-vtep-iso",
+				VNI:                   2,
+				OverlayNetwork:        "10.254.0.0/16",
+				SubnetPrefixLength:    24,
+				ConnectivityServerURL: "https://silk-controller-isolated.something",
+			}}))
+		})
+
+		It("errors if a required overlay field is not set", func() {
+			for fieldName := range overlay {
+				invalidOverlay := cloneMap(overlay)
+				delete(invalidOverlay, fieldName)
+				cfg := cloneMap(requiredFields)
+				cfg["additional_overlays"] = []interface{}{invalidOverlay}
+
+				By(fmt.Sprintf("checking that %s is required", fieldName))
+				_, err := config.LoadConfig(writeConfig(cfg))
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(HavePrefix("invalid config:"))
+			}
+		})
+
+		DescribeTable("errors when the overlay collides with another one",
+			func(field string, value interface{}, expectedErr string) {
+				otherOverlay := cloneMap(overlay)
+				otherOverlay["name"] = "other"
+				otherOverlay["vtep_name"] = "silk-vtep-other"
+				otherOverlay["vni"] = 3
+				otherOverlay[field] = value
+				cfg := cloneMap(requiredFields)
+				cfg["additional_overlays"] = []interface{}{overlay, otherOverlay}
+
+				_, err := config.LoadConfig(writeConfig(cfg))
+				Expect(err).To(MatchError("invalid config: " + expectedErr))
+			},
+			Entry("same name", "name", "isolated", "duplicate overlay name: isolated"),
+			Entry("same vtep name", "vtep_name", "silk-vxlan", "duplicate vtep name: silk-vxlan"),
+			Entry("same vni", "vni", 44, "duplicate vni: 44"),
+		)
+
+		It("derives a config for the overlay", func() {
+			cfg := cloneMap(requiredFields)
+			cfg["additional_overlays"] = []interface{}{overlay}
+
+			loadedConfig, err := config.LoadConfig(writeConfig(cfg))
+			Expect(err).NotTo(HaveOccurred())
+
+			overlayConfig := loadedConfig.ForOverlay(loadedConfig.AdditionalOverlays[0])
+			Expect(overlayConfig.VTEPName).To(Equal("silk-vtep-iso"))
+			Expect(overlayConfig.VNI).To(Equal(2))
+			Expect(overlayConfig.OverlayNetwork).To(Equal("10.254.0.0/16"))
+			Expect(overlayConfig.SubnetPrefixLength).To(Equal(24))
+			Expect(overlayConfig.ConnectivityServerURL).To(Equal("https://silk-controller-isolated.something"))
+			Expect(overlayConfig.UnderlayIP).To(Equal("1.2.3.4"))
+			Expect(overlayConfig.Datastore).To(Equal("/some/data-store-file.json"))
+			Expect(overlayConfig.AdditionalOverlays).To(BeEmpty())
+		})
+	})
+
+	Context("when a static leases file is specified", func() {
+		It("does not require the silk-controller fields", func() {
+			cfg := cloneMap(requiredFields)
+			cfg["static_leases_file"] = "/some/leases.yml"
+			delete(cfg, "connectivity_server_url")
+			delete(cfg, "ca_cert_file")
+			delete(cfg, "client_cert_file")
+			delete(cfg, "client_key_file")
+
+			file, err := os.CreateTemp(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			loadedConfig, err := config.LoadConfig(file.Name())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loadedConfig.StaticLeasesFile).To(Equal("/some/leases.yml"))
+		})
+
+		It("does not support additional overlays", func() {
+			cfg := cloneMap(requiredFields)
+			cfg["static_leases_file"] = "/some/leases.yml"
+			cfg["additional_overlays"] = []interface{}{map[string]interface{}{
+				"name":                    "isolated",
+				"vtep_name":               "silk-vtep-iso",
+				"vni":                     2,
+				"overlay_network":         "10.254.0.0/16",
+				"subnet_prefix_length":    24,
+				"connectivity_server_url": "https://silk-controller-isolated.something",
+			}}
+
+			file, err := os.CreateTemp(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			_, err = config.LoadConfig(file.Name())
+			Expect(err).To(MatchError("invalid config: additional overlays are not supported with a static leases file"))
+		})
+	})
+
+	Context("when readiness intervals are not specified", func() {
+		It("defaults them", func() {
+			file, err := os.CreateTemp(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(json.NewEncoder(file).Encode(requiredFields)).To(Succeed())
+
+			loadedConfig, err := config.LoadConfig(file.Name())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loadedConfig.ReadinessIntervals).To(Equal(3))
+		})
+
+		It("errors when they are negative", func() {
+			cfg := cloneMap(requiredFields)
+			cfg["readiness_intervals"] = -1
+
+			file, err := os.CreateTemp(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			_, err = config.LoadConfig(file.Name())
+			Expect(err).To(MatchError("invalid config: invalid readiness_intervals: -1"))
+		})
+	})
+
+	Context("when the diagnostic server is enabled", func() {
+		It("loads the port and token file", func() {
+			cfg := cloneMap(requiredFields)
+			cfg["diagnostic_server_port"] = 8097
+			cfg["diagnostic_server_token_file"] = "/some/token"
+
+			file, err := os.CreateTemp(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			loadedConfig, err := config.LoadConfig(file.Name())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loadedConfig.DiagnosticServerPort).To(Equal(uint16(8097)))
+			Expect(loadedConfig.DiagnosticServerTokenFile).To(Equal("/some/token"))
+		})
+
+		It("requires a token file", func() {
+			cfg := cloneMap(requiredFields)
+			cfg["diagnostic_server_port"] = 8097
+
+			file, err := os.CreateTemp(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			_, err = config.LoadConfig(file.Name())
+			Expect(err).To(MatchError("invalid config: missing diagnostic_server_token_file"))
+		})
+	})
 })
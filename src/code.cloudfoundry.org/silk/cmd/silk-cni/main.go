@@ -1,13 +1,14 @@
 package main
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"time"
 
 	"gopkg.in/validator.v2"
 
@@ -17,13 +18,13 @@ import (
 
 	"code.cloudfoundry.org/silk/cni/adapter"
 	"code.cloudfoundry.org/silk/cni/config"
+	"code.cloudfoundry.org/silk/cni/ipam"
 	"code.cloudfoundry.org/silk/cni/lib"
 	"code.cloudfoundry.org/silk/cni/netinfo"
 	"code.cloudfoundry.org/silk/daemon"
 	libAdapter "code.cloudfoundry.org/silk/lib/adapter"
 	"code.cloudfoundry.org/silk/lib/datastore"
 	"code.cloudfoundry.org/silk/lib/serial"
-	"github.com/containernetworking/cni/pkg/invoke"
 	"github.com/containernetworking/cni/pkg/skel"
 	"github.com/containernetworking/cni/pkg/types"
 	current "github.com/containernetworking/cni/pkg/types/100"
@@ -33,19 +34,30 @@ import (
 )
 
 type CNIPlugin struct {
-	HostNSPath      string
-	HostNS          ns.NetNS
-	ConfigCreator   *config.ConfigCreator
-	VethPairCreator *lib.VethPairCreator
-	Host            *lib.Host
-	Container       *lib.Container
-	Store           *datastore.Store
-	Logger          lager.Logger
+	HostNSPath       string
+	HostNS           ns.NetNS
+	ConfigCreator    *config.ConfigCreator
+	VethPairCreator  *lib.VethPairCreator
+	Host             *lib.Host
+	Container        *lib.Container
+	Bandwidth        *lib.Bandwidth
+	Checker          *lib.Checker
+	IPAM             *ipam.Allocator
+	GarbageCollector *lib.GarbageCollector
+	Store            *datastore.Store
+	Logger           lager.Logger
 }
 
 const (
 	jobPrefix = "silk-cni"
 	logPrefix = "cfnetworking"
+
+	// allocations younger than this are never garbage collected, as the
+	// container datastore entry is only written at the end of ADD
+	ipamGracePeriod = 5 * time.Minute
+
+	// the vendored CNI types do not define the STATUS error codes yet
+	errPluginNotAvailable uint = 50
 )
 
 // used as a compile-time flag to disable logging during integration tests
@@ -93,6 +105,15 @@ func main() {
 		LockerNew:  filelock.NewLocker,
 	}
 
+	allocator := &ipam.Allocator{
+		Serializer:  &serial.Serial{},
+		LockerNew:   filelock.NewLocker,
+		Datastore:   store,
+		GracePeriod: ipamGracePeriod,
+		Now:         time.Now,
+		Logger:      logger.Session("ipam"),
+	}
+
 	plugin := &CNIPlugin{
 		HostNSPath: hostNS.Path(),
 		HostNS:     hostNS,
@@ -116,16 +137,36 @@ func main() {
 			LinkOperations: linkOperations,
 			Logger:         logger.Session("container-setup"),
 		},
+		Bandwidth: &lib.Bandwidth{
+			NetlinkAdapter: netlinkAdapter,
+			LinkOperations: linkOperations,
+			Logger:         logger.Session("bandwidth"),
+		},
+		Checker: &lib.Checker{
+			NetlinkAdapter: netlinkAdapter,
+			SysctlAdapter:  &adapter.SysctlAdapter{},
+			Logger:         logger.Session("checker"),
+		},
+		IPAM: allocator,
+		GarbageCollector: &lib.GarbageCollector{
+			IPAM:                allocator,
+			Store:               store,
+			LinkOperations:      linkOperations,
+			DeviceNameGenerator: &config.DeviceNameGenerator{},
+			Logger:              logger.Session("garbage-collector"),
+		},
 		Logger: logger,
 		Store:  store,
 	}
 
 	funcs := skel.CNIFuncs{
-		Add:   plugin.cmdAdd,
-		Check: plugin.cmdCheck,
-		Del:   plugin.cmdDel,
+		Add:    plugin.cmdAdd,
+		Check:  plugin.cmdCheck,
+		Del:    plugin.cmdDel,
+		GC:     plugin.cmdGC,
+		Status: plugin.cmdStatus,
 	}
-	skel.PluginMainFuncs(funcs, version.PluginSupports("1.0.0"), "CNI Plugin silk-cni")
+	skel.PluginMainFuncs(funcs, version.PluginSupports("1.0.0", "1.1.0"), "CNI Plugin silk-cni")
 }
 
 type NetConf struct {
@@ -135,20 +176,32 @@ type NetConf struct {
 	MTU        int    `json:"mtu" validate:"min=0"`
 	Datastore  string `json:"datastore"`
 	DaemonPort int    `json:"daemonPort"`
-}
 
-type HostLocalIPAM struct {
-	Type    string         `json:"type"`
-	Subnet  string         `json:"subnet"`
-	Gateway string         `json:"gateway"`
-	Routes  []*types.Route `json:"routes"`
-	DataDir string         `json:"dataDir"`
+	// IPv6OverlayNetwork enables dual-stack containers. The IPv4 address of
+	// each container is embedded in its last 32 bits, see config.IPv6Subnet.
+	IPv6OverlayNetwork string `json:"ipv6OverlayNetwork"`
+
+	// AllowedSysctls and AllowedRouteNetworks are the sysctls and extra
+	// route destinations containers may request, see config.Allowlist.
+	AllowedSysctls       []string `json:"allowedSysctls"`
+	AllowedRouteNetworks []string `json:"allowedRouteNetworks"`
+
+	// OrgOverlays maps org GUIDs to the additional overlay their containers join.
+	OrgOverlays map[string]string      `json:"orgOverlays"`
+	Metadata    map[string]interface{} `json:"metadata"`
+
+	RuntimeConfig struct {
+		Bandwidth *config.BandwidthLimits `json:"bandwidth,omitempty"`
+		IPs       []string                `json:"ips,omitempty"`
+		Sysctls   map[string]string       `json:"sysctls,omitempty"`
+		Routes    []string                `json:"routes,omitempty"`
+	} `json:"runtimeConfig"`
 }
 
-type ConfForHostLocal struct {
-	CNIVersion string        `json:"cniVersion"`
-	Name       string        `json:"name"`
-	IPAM       HostLocalIPAM `json:"ipam"`
+// IPAMArgs are the CNI_ARGS understood by silk-cni. Other args are ignored.
+type IPAMArgs struct {
+	types.CommonArgs
+	IP net.IP
 }
 
 func typedError(msg string, err error) *types.Error {
@@ -159,7 +212,7 @@ func typedError(msg string, err error) *types.Error {
 	}
 }
 
-func getNetworkInfo(netConf NetConf) (daemon.NetworkInfo, error) {
+func getNetworkInfo(netConf NetConf, overlay string) (daemon.NetworkInfo, error) {
 	err := validator.Validate(netConf)
 	if err != nil {
 		return daemon.NetworkInfo{}, fmt.Errorf("invalid config: %s", err)
@@ -171,12 +224,40 @@ func getNetworkInfo(netConf NetConf) (daemon.NetworkInfo, error) {
 			SubnetFilePath: netConf.SubnetFile,
 		}
 	} else {
-		jsonClient := json_client.New(lager.NewLogger(""), http.DefaultClient, fmt.Sprintf("http://127.0.0.1:%d", netConf.DaemonPort))
-		discoverer.NetInfo = &netinfo.Daemon{
-			JSONClient: jsonClient,
-		}
+		discoverer.NetInfo = daemonNetInfo(netConf.DaemonPort)
+	}
+	return discoverer.Discover(netConf.MTU, overlay)
+}
+
+func daemonNetInfo(daemonPort int) *netinfo.Daemon {
+	jsonClient := json_client.New(lager.NewLogger(""), http.DefaultClient, fmt.Sprintf("http://127.0.0.1:%d", daemonPort))
+	return &netinfo.Daemon{
+		JSONClient: jsonClient,
+	}
+}
+
+// selectContainerConfig returns the bandwidth limits, sysctls and extra
+// routes of the container from the runtime config and the metadata.
+func (p *CNIPlugin) selectContainerConfig(netConf NetConf) (config.BandwidthLimits, config.ContainerOptions, error) {
+	bandwidth, err := config.SelectBandwidth(netConf.RuntimeConfig.Bandwidth, netConf.Metadata)
+	if err != nil {
+		p.Logger.Error("select-bandwidth-failed", err)
+		return config.BandwidthLimits{}, config.ContainerOptions{}, types.NewError(types.ErrInvalidNetworkConfig, "invalid bandwidth limits", err.Error())
 	}
-	return discoverer.Discover(netConf.MTU)
+
+	allowlist, err := config.NewAllowlist(netConf.AllowedSysctls, netConf.AllowedRouteNetworks)
+	if err != nil {
+		p.Logger.Error("parse-allowlist-failed", err)
+		return config.BandwidthLimits{}, config.ContainerOptions{}, types.NewError(types.ErrInvalidNetworkConfig, "invalid allowlist", err.Error())
+	}
+
+	containerOptions, err := config.SelectContainerOptions(allowlist, netConf.RuntimeConfig.Sysctls, netConf.RuntimeConfig.Routes, netConf.Metadata)
+	if err != nil {
+		p.Logger.Error("select-container-options-failed", err)
+		return config.BandwidthLimits{}, config.ContainerOptions{}, types.NewError(types.ErrInvalidNetworkConfig, "invalid container sysctls or routes", err.Error())
+	}
+
+	return bandwidth, containerOptions, nil
 }
 
 func (p *CNIPlugin) cmdAdd(args *skel.CmdArgs) error {
@@ -190,34 +271,97 @@ func (p *CNIPlugin) cmdAdd(args *skel.CmdArgs) error {
 		return err // impossible, skel package asserts JSON is valid
 	}
 
-	p.Logger.Debug("getting-network-info", lager.Data{"netConf": netConf})
-	networkInfo, err := getNetworkInfo(netConf)
+	overlay, err := config.OverlaySelector{OrgOverlays: netConf.OrgOverlays}.Select(netConf.Metadata)
+	if err != nil {
+		p.Logger.Error("select-overlay-failed", err)
+		return types.NewError(types.ErrInvalidNetworkConfig, "invalid overlay", err.Error())
+	}
+
+	p.Logger.Debug("getting-network-info", lager.Data{"netConf": netConf, "overlay": overlay})
+	networkInfo, err := getNetworkInfo(netConf, overlay)
 	if err != nil {
 		p.Logger.Error("get-network-info-failed", err)
 		return typedError("discover network info", err)
 	}
 
-	p.Logger.Debug("generate-ipam-config", lager.Data{"overlaySubnet": networkInfo.OverlaySubnet, "name": netConf.Name, "dataDir": netConf.DataDir})
-	generator := config.IPAMConfigGenerator{}
-	ipamConfig, err := generator.GenerateConfig(networkInfo.OverlaySubnet, netConf.Name, netConf.DataDir)
+	ipamArgs := IPAMArgs{CommonArgs: types.CommonArgs{IgnoreUnknown: true}}
+	err = types.LoadArgs(args.Args, &ipamArgs)
 	if err != nil {
-		p.Logger.Error("generate-ipam-config-failed", err)
-		return typedError("generate ipam config", err)
+		p.Logger.Error("load-cni-args-failed", err)
+		return types.NewError(types.ErrInvalidNetworkConfig, "parse CNI args", err.Error())
 	}
-	ipamConfigBytes, _ := json.Marshal(ipamConfig) // untestable
 
-	p.Logger.Debug("host-local-ipam", lager.Data{"action": "add", "ipamConfig": string(ipamConfigBytes)})
-	result, err := invoke.DelegateAdd(context.Background(), "host-local", ipamConfigBytes, nil)
+	requestedIP, err := config.RequestedIP(ipamArgs.IP, netConf.RuntimeConfig.IPs)
 	if err != nil {
-		p.Logger.Error("host-local-ipam-failed", err)
-		return typedError("run ipam plugin", err)
+		p.Logger.Error("requested-ip-invalid", err)
+		return types.NewError(types.ErrInvalidNetworkConfig, "invalid requested ip", err.Error())
 	}
 
-	p.Logger.Debug("convert-ipam-result", lager.Data{"result": result})
-	cniResult, err := current.NewResultFromResult(result)
+	bandwidth, containerOptions, err := p.selectContainerConfig(netConf)
 	if err != nil {
-		p.Logger.Error("convert-ipam-result-failed", err)
-		return fmt.Errorf("convert result to current CNI version: %s", err) // not tested
+		return err
+	}
+
+	_, overlaySubnet, err := net.ParseCIDR(networkInfo.OverlaySubnet)
+	if err != nil {
+		p.Logger.Error("parse-overlay-subnet-failed", err)
+		return typedError("allocate ip", fmt.Errorf("invalid subnet: %s", err))
+	}
+
+	var ipv6Subnet *net.IPNet
+	if netConf.IPv6OverlayNetwork != "" {
+		_, ipv6Network, err := net.ParseCIDR(netConf.IPv6OverlayNetwork)
+		if err == nil {
+			ipv6Subnet, err = config.IPv6Subnet(ipv6Network, overlaySubnet)
+		}
+		if err != nil {
+			p.Logger.Error("parse-ipv6-overlay-network-failed", err)
+			return types.NewError(types.ErrInvalidNetworkConfig, "invalid ipv6 overlay network", err.Error())
+		}
+	}
+
+	network := ipam.Network{
+		Name:      config.IPAMNetworkName(netConf.Name, overlay),
+		DataDir:   netConf.DataDir,
+		Datastore: netConf.Datastore,
+	}
+	p.Logger.Debug("allocate-ip", lager.Data{"overlaySubnet": networkInfo.OverlaySubnet, "network": network, "requestedIP": requestedIP})
+	cniResult, err := p.IPAM.Allocate(network, overlaySubnet, args.ContainerID, args.IfName, requestedIP)
+	if err != nil {
+		p.Logger.Error("allocate-ip-failed", err)
+		switch err.(type) {
+		case *ipam.InvalidIPError:
+			return types.NewError(types.ErrInvalidNetworkConfig, "invalid requested ip", err.Error())
+		case *ipam.UnavailableIPError:
+			// the container that holds the ip may still be being deleted,
+			// e.g. when a workload is restarted on the same cell
+			return types.NewError(types.ErrTryAgainLater, "requested ip unavailable", err.Error())
+		}
+		return typedError("allocate ip", err)
+	}
+
+	// a failed ADD is not always followed by a DEL, so the allocation is
+	// released here rather than left to garbage collection
+	added := false
+	defer func() {
+		if added {
+			return
+		}
+		p.Logger.Debug("release-ip-after-failed-add", lager.Data{"network": network})
+		if err := p.IPAM.Release(network, args.ContainerID, args.IfName); err != nil {
+			p.Logger.Error("release-ip-after-failed-add-failed", err)
+		}
+	}()
+
+	if ipv6Subnet != nil {
+		containerIPv6, err := config.IPv6Address(ipv6Subnet, cniResult.IPs[0].Address.IP)
+		if err != nil {
+			p.Logger.Error("allocate-ipv6-failed", err)
+			return typedError("allocate ipv6", err)
+		}
+		cniResult.IPs = append(cniResult.IPs, &current.IPConfig{
+			Address: net.IPNet{IP: containerIPv6, Mask: net.CIDRMask(128, 128)},
+		})
 	}
 
 	p.Logger.Debug("create-config", lager.Data{"hostNamespace": p.HostNS, "args": args, "result": cniResult, "mtu": networkInfo.MTU})
@@ -226,6 +370,9 @@ func (p *CNIPlugin) cmdAdd(args *skel.CmdArgs) error {
 		p.Logger.Error("create-config-failed", err)
 		return typedError("create config", err)
 	}
+	cfg.Bandwidth = bandwidth
+	cfg.Container.Routes = append(cfg.Container.Routes, containerOptions.ExtraRoutes(cfg.Host.Address.IP)...)
+	cfg.Container.Sysctls = containerOptions.Sysctls
 
 	p.Logger.Debug("create-veth-pair", lager.Data{"cfg": cfg})
 	err = p.VethPairCreator.Create(cfg)
@@ -248,13 +395,26 @@ func (p *CNIPlugin) cmdAdd(args *skel.CmdArgs) error {
 		return typedError("set up container", err)
 	}
 
+	p.Logger.Debug("setup-bandwidth", lager.Data{"bandwidth": cfg.Bandwidth})
+	err = p.Bandwidth.Setup(cfg)
+	if err != nil {
+		p.Logger.Error("setup-bandwidth-failed", err)
+		return typedError("set up bandwidth limits", err)
+	}
+
+	var containerMetadata map[string]interface{}
+	if overlay != "" {
+		containerMetadata = map[string]interface{}{"overlay": overlay}
+	}
+
 	// use args.Netns as the 'handle' for now
-	p.Logger.Debug("write-container-metadata", lager.Data{"datastore": netConf.Datastore, "path": filepath.Base(args.Netns), "ip": cfg.Container.Address.IP.String()})
-	err = p.Store.Add(netConf.Datastore, filepath.Base(args.Netns), cfg.Container.Address.IP.String(), nil)
+	p.Logger.Debug("write-container-metadata", lager.Data{"datastore": netConf.Datastore, "path": filepath.Base(args.Netns), "ip": cfg.Container.Address.IP.String(), "metadata": containerMetadata})
+	err = p.Store.Add(netConf.Datastore, filepath.Base(args.Netns), cfg.Container.Address.IP.String(), containerMetadata)
 	if err != nil {
 		p.Logger.Error("write-container-metadata-failed", err)
 		return typedError("write container metadata", err)
 	}
+	added = true
 
 	p.Logger.Debug("print-cni-result", lager.Data{"cfg": cfg.AsCNIResult(), "cniVersion": netConf.CNIVersion})
 	err = types.PrintResult(cfg.AsCNIResult(), netConf.CNIVersion)
@@ -275,23 +435,35 @@ func (p *CNIPlugin) cmdDel(args *skel.CmdArgs) error {
 		return err // impossible, skel package asserts JSON is valid
 	}
 
-	p.Logger.Debug("generate-ipam-config", lager.Data{"name": netConf.Name, "dataDir": netConf.DataDir})
-	generator := config.IPAMConfigGenerator{}
-	// use 0.0.0.0/0 for the IPAM subnet during delete so we don't need to discover the subnet.
-	// this way, silk-daemon does not need to be up during deletes, and cleanup that takes place
-	// on startup, after the subnet may have changed, will succeed.
-	ipamConfig, err := generator.GenerateConfig("0.0.0.0/0", netConf.Name, netConf.DataDir)
+	container := p.containerEntry(netConf.Datastore, filepath.Base(args.Netns))
+	overlay, _ := container.Metadata["overlay"].(string)
+
+	// releasing does not need the subnet, so silk-daemon does not need to be up
+	// during deletes, and cleanup that takes place on startup, after the subnet
+	// may have changed, will succeed.
+	network := ipam.Network{
+		Name:    config.IPAMNetworkName(netConf.Name, overlay),
+		DataDir: netConf.DataDir,
+	}
+	p.Logger.Debug("release-ip", lager.Data{"network": network})
+	err = p.IPAM.Release(network, args.ContainerID, args.IfName)
 	if err != nil {
-		p.Logger.Error("generate-ipam-config-failed", err) // untestable
+		p.Logger.Error("release-ip-failed", err)
 		// continue, keep trying to cleanup
 	}
-	ipamConfigBytes, _ := json.Marshal(ipamConfig) // untestable
 
-	p.Logger.Debug("host-local-ipam", lager.Data{"action": "delete", "ipamConfig": string(ipamConfigBytes)})
-	err = invoke.DelegateDel(context.Background(), "host-local", ipamConfigBytes, nil)
-	if err != nil {
-		p.Logger.Error("host-local-ipam-failed", err)
-		// continue, keep trying to cleanup
+	// the ifb device lives in the host namespace, so it is deleted even when
+	// the container namespace is already gone
+	if containerIP := net.ParseIP(container.IP); containerIP != nil {
+		ifbDeviceName, err := p.ConfigCreator.DeviceNameGenerator.GenerateForHostIFB(containerIP)
+		if err == nil {
+			p.Logger.Debug("teardown-bandwidth", lager.Data{"device": ifbDeviceName})
+			err = p.Bandwidth.Teardown(p.HostNS, ifbDeviceName)
+		}
+		if err != nil {
+			p.Logger.Error("teardown-bandwidth-failed", err)
+			// continue, keep trying to cleanup
+		}
 	}
 
 	p.Logger.Debug("open-netns", lager.Data{"namespace": args.Netns})
@@ -317,6 +489,169 @@ func (p *CNIPlugin) cmdDel(args *skel.CmdArgs) error {
 	return nil
 }
 
+// containerEntry returns the datastore entry recorded for the container when it was added.
+// Failures are logged and treated as an empty entry, so that deletes keep going.
+func (p *CNIPlugin) containerEntry(datastorePath, handle string) datastore.Container {
+	containers, err := p.Store.ReadAll(datastorePath)
+	if err != nil {
+		p.Logger.Error("read-container-metadata-failed", err)
+		return datastore.Container{}
+	}
+	return containers[handle]
+}
+
 func (p *CNIPlugin) cmdCheck(args *skel.CmdArgs) error {
-	return fmt.Errorf("Meow this isn't implemented yet")
+	p.Logger = p.Logger.Session("plugin-check")
+
+	var netConf NetConf
+	p.Logger.Debug("json-unmarshal-stdin-as-netconf")
+	err := json.Unmarshal(args.StdinData, &netConf)
+	if err != nil {
+		p.Logger.Error("json-unmarshal-stdin-as-netconf-failed", err)
+		return err // impossible, skel package asserts JSON is valid
+	}
+
+	err = version.ParsePrevResult(&netConf.NetConf)
+	if err != nil {
+		p.Logger.Error("parse-prev-result-failed", err)
+		return types.NewError(types.ErrDecodingFailure, "parse prevResult", err.Error())
+	}
+	if netConf.PrevResult == nil {
+		return types.NewError(types.ErrInvalidNetworkConfig, "missing prevResult", "")
+	}
+	prevResult, err := current.NewResultFromResult(netConf.PrevResult)
+	if err != nil {
+		p.Logger.Error("convert-prev-result-failed", err)
+		return types.NewError(types.ErrDecodingFailure, "convert prevResult to current CNI version", err.Error())
+	}
+	if len(prevResult.IPs) == 0 {
+		return types.NewError(types.ErrInvalidNetworkConfig, "no IP address in prevResult", "")
+	}
+	containerIP := prevResult.IPs[0].Address.IP
+
+	bandwidth, containerOptions, err := p.selectContainerConfig(netConf)
+	if err != nil {
+		return err
+	}
+
+	containerNS, err := ns.GetNS(args.Netns)
+	if err != nil {
+		p.Logger.Error("open-netns-failed", err)
+		return types.NewError(types.ErrUnknownContainer, "open container namespace", err.Error())
+	}
+	// #nosec G104 - the namespace is only opened to confirm it exists
+	containerNS.Close()
+
+	handle := filepath.Base(args.Netns)
+	p.Logger.Debug("read-container-metadata", lager.Data{"datastore": netConf.Datastore, "handle": handle})
+	containers, err := p.Store.ReadAll(netConf.Datastore)
+	if err != nil {
+		p.Logger.Error("read-container-metadata-failed", err)
+		return types.NewError(types.ErrIOFailure, "read container metadata", err.Error())
+	}
+	container, ok := containers[handle]
+	if !ok {
+		return types.NewError(types.ErrUnknownContainer, "container not found in datastore", handle)
+	}
+	if container.IP != containerIP.String() {
+		return types.NewError(lib.ErrAllocationDrift, "container metadata has a different ip", fmt.Sprintf("expected %s, found %s", containerIP, container.IP))
+	}
+	overlay, _ := container.Metadata["overlay"].(string)
+
+	network := ipam.Network{
+		Name:    config.IPAMNetworkName(netConf.Name, overlay),
+		DataDir: netConf.DataDir,
+	}
+	p.Logger.Debug("check-ipam-allocation", lager.Data{"network": network, "ip": containerIP.String()})
+	allocationChecker := &lib.AllocationChecker{IPAM: p.IPAM}
+	err = allocationChecker.Check(network, args.ContainerID, containerIP)
+	if err != nil {
+		p.Logger.Error("check-ipam-allocation-failed", err)
+		return err
+	}
+
+	networkInfo, err := getNetworkInfo(netConf, overlay)
+	if err != nil {
+		p.Logger.Error("get-network-info-failed", err)
+		return typedError("discover network info", err)
+	}
+
+	cfg, err := p.ConfigCreator.Create(p.HostNS, args, prevResult, networkInfo.MTU)
+	if err != nil {
+		p.Logger.Error("create-config-failed", err)
+		return typedError("create config", err)
+	}
+	cfg.Bandwidth = bandwidth
+	cfg.Container.Routes = append(cfg.Container.Routes, containerOptions.ExtraRoutes(cfg.Host.Address.IP)...)
+	cfg.Container.Sysctls = containerOptions.Sysctls
+
+	p.Logger.Debug("check-network", lager.Data{"cfg": cfg})
+	err = p.Checker.Check(cfg)
+	if err != nil {
+		p.Logger.Error("check-network-failed", err)
+		return err
+	}
+
+	return nil
+}
+
+// cmdGC removes the allocations, datastore entries and host devices of the
+// attachments that are not in the valid attachments of the config.
+func (p *CNIPlugin) cmdGC(args *skel.CmdArgs) error {
+	p.Logger = p.Logger.Session("plugin-gc")
+
+	var netConf NetConf
+	p.Logger.Debug("json-unmarshal-stdin-as-netconf")
+	err := json.Unmarshal(args.StdinData, &netConf)
+	if err != nil {
+		p.Logger.Error("json-unmarshal-stdin-as-netconf-failed", err)
+		return err // impossible, skel package asserts JSON is valid
+	}
+
+	networks, err := ipam.Networks(netConf.DataDir, netConf.Name)
+	if err != nil {
+		p.Logger.Error("list-ipam-networks-failed", err)
+		return types.NewError(types.ErrIOFailure, "list ipam networks", err.Error())
+	}
+
+	p.Logger.Debug("garbage-collect", lager.Data{"networks": networks, "validAttachments": netConf.ValidAttachments})
+	err = p.GarbageCollector.Collect(networks, netConf.Datastore, netConf.ValidAttachments)
+	if err != nil {
+		p.Logger.Error("garbage-collect-failed", err)
+		return typedError("garbage collect", err)
+	}
+
+	return nil
+}
+
+// cmdStatus reports whether containers can be added, which requires the
+// network info of the cell: silk-daemon must be ready, or the subnet file
+// must be readable.
+func (p *CNIPlugin) cmdStatus(args *skel.CmdArgs) error {
+	p.Logger = p.Logger.Session("plugin-status")
+
+	var netConf NetConf
+	p.Logger.Debug("json-unmarshal-stdin-as-netconf")
+	err := json.Unmarshal(args.StdinData, &netConf)
+	if err != nil {
+		p.Logger.Error("json-unmarshal-stdin-as-netconf-failed", err)
+		return err // impossible, skel package asserts JSON is valid
+	}
+
+	if netConf.SubnetFile != "" {
+		_, err = getNetworkInfo(netConf, "")
+		if err != nil {
+			p.Logger.Error("get-network-info-failed", err)
+			return types.NewError(errPluginNotAvailable, "discover network info", err.Error())
+		}
+		return nil
+	}
+
+	err = daemonNetInfo(netConf.DaemonPort).Ready()
+	if err != nil {
+		p.Logger.Error("silk-daemon-not-ready", err)
+		return types.NewError(errPluginNotAvailable, "silk-daemon is not ready", err.Error())
+	}
+
+	return nil
 }
@@ -1,13 +1,14 @@
 package main
 
 import (
-	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"code.cloudfoundry.org/cf-networking-helpers/metrics"
@@ -20,7 +21,10 @@ import (
 	"code.cloudfoundry.org/silk/client/config"
 	"code.cloudfoundry.org/silk/controller"
 	"code.cloudfoundry.org/silk/daemon"
+	"code.cloudfoundry.org/silk/daemon/diagnostics"
+	"code.cloudfoundry.org/silk/daemon/health"
 	"code.cloudfoundry.org/silk/daemon/planner"
+	"code.cloudfoundry.org/silk/daemon/static"
 	"code.cloudfoundry.org/silk/daemon/vtep"
 	"code.cloudfoundry.org/silk/lib/adapter"
 	"code.cloudfoundry.org/silk/lib/datastore"
@@ -68,16 +72,19 @@ func mainWithError() error {
 	logger, reconfigurableSink := lagerflags.NewFromConfig(fmt.Sprintf("%s.%s", logPrefix, jobPrefix), getLagerConfig(logLevel))
 	logger.Info("starting")
 
-	tlsConfig, err := mutualtls.NewClientTLSConfig(cfg.ClientCertFile, cfg.ClientKeyFile, cfg.ServerCACertFile)
-	if err != nil {
-		return fmt.Errorf("create tls config: %s", err)
-	}
+	var httpClient *http.Client
+	if cfg.StaticLeasesFile == "" {
+		tlsConfig, err := mutualtls.NewClientTLSConfig(cfg.ClientCertFile, cfg.ClientKeyFile, cfg.ServerCACertFile)
+		if err != nil {
+			return fmt.Errorf("create tls config: %s", err)
+		}
 
-	httpClient := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: tlsConfig,
-		},
-		Timeout: time.Duration(cfg.ClientTimeoutSeconds) * time.Second,
+		httpClient = &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: tlsConfig,
+			},
+			Timeout: time.Duration(cfg.ClientTimeoutSeconds) * time.Second,
+		}
 	}
 
 	metronAddress := fmt.Sprintf("127.0.0.1:%d", cfg.MetronPort)
@@ -97,128 +104,355 @@ func mainWithError() error {
 		NetAdapter: &adapter.NetAdapter{},
 	}
 
-	client := controller.NewClient(logger, httpClient, cfg.ConnectivityServerURL)
-
 	store := &datastore.Store{
 		Serializer: &serial.Serial{},
 		LockerNew:  filelock.NewLocker,
 	}
 
+	var leases leaseClient
+	var lease controller.Lease
+	var migrationClient *controller.Client
+	if cfg.StaticLeasesFile != "" {
+		leaseFile := &static.LeaseFile{Path: cfg.StaticLeasesFile}
+		lease, err = establishStaticLease(logger, cfg, leaseFile, vtepConfigCreator, vtepFactory, store)
+		leases = leaseFile
+	} else {
+		client := controller.NewClient(logger, httpClient, cfg.ConnectivityServerURL)
+		lease, err = establishLease(logger, cfg, client, vtepConfigCreator, vtepFactory, store, "")
+		leases = client
+		migrationClient = client
+	}
+	if err != nil {
+		return err
+	}
+
+	debugServerAddress := fmt.Sprintf("127.0.0.1:%d", cfg.DebugServerPort)
+	networkInfo, err := getNetworkInfo(vtepFactory, cfg, lease)
+	if err != nil {
+		return fmt.Errorf("get network info: %s", err) // not tested
+	}
+
+	var diagnosticHandler *diagnostics.Handler
+	if cfg.DiagnosticServerPort != 0 {
+		token, err := os.ReadFile(cfg.DiagnosticServerTokenFile)
+		if err != nil {
+			return fmt.Errorf("read diagnostic server token: %s", err)
+		}
+		diagnosticHandler = &diagnostics.Handler{
+			State:  &diagnostics.State{},
+			Token:  strings.TrimSpace(string(token)),
+			Logger: logger.Session("diagnostic-server"),
+		}
+	}
+
+	vxlanPoller, readiness, err := newVXLANPoller(logger, cfg, leases, lease, metricSender, migrationClient, store, "", diagnosticHandler)
+	if err != nil {
+		return err
+	}
+	healthHandler := &health.Handler{}
+	healthHandler.Readiness = append(healthHandler.Readiness, readiness)
+
+	pollers := grouper.Members{
+		{Name: "vxlan-poller", Runner: vxlanPoller},
+	}
+
+	if cfg.StaticLeasesFile != "" {
+		// the watcher and the poller both converge, so they must take turns
+		cycleLock := &sync.Mutex{}
+		doCycle := vxlanPoller.SingleCycleFunc
+		vxlanPoller.SingleCycleFunc = func() error {
+			cycleLock.Lock()
+			defer cycleLock.Unlock()
+			return doCycle()
+		}
+
+		pollers = append(pollers, grouper.Member{Name: "lease-file-watcher", Runner: &static.Watcher{
+			Path:          cfg.StaticLeasesFile,
+			CheckInterval: time.Second,
+			OnChange:      vxlanPoller.SingleCycleFunc,
+			Logger:        logger.Session("lease-file-watcher"),
+		}})
+	}
+
+	for _, overlay := range cfg.AdditionalOverlays {
+		overlayCfg := cfg.ForOverlay(overlay)
+		overlayLogger := logger.Session("overlay", lager.Data{"name": overlay.Name})
+		overlayClient := controller.NewClient(overlayLogger, httpClient, overlayCfg.ConnectivityServerURL)
+
+		overlayLease, err := establishLease(overlayLogger, overlayCfg, overlayClient, vtepConfigCreator, vtepFactory, store, overlay.Name)
+		if err != nil {
+			return fmt.Errorf("overlay %s: %s", overlay.Name, err)
+		}
+
+		overlayNetworkInfo, err := getNetworkInfo(vtepFactory, overlayCfg, overlayLease)
+		if err != nil {
+			return fmt.Errorf("get network info for overlay %s: %s", overlay.Name, err) // not tested
+		}
+		if networkInfo.Overlays == nil {
+			networkInfo.Overlays = map[string]daemon.NetworkInfo{}
+		}
+		networkInfo.Overlays[overlay.Name] = overlayNetworkInfo
+
+		overlayPoller, overlayReadiness, err := newVXLANPoller(overlayLogger, overlayCfg, overlayClient, overlayLease, metricSender, overlayClient, store, overlay.Name, nil)
+		if err != nil {
+			return fmt.Errorf("overlay %s: %s", overlay.Name, err)
+		}
+		pollers = append(pollers, grouper.Member{Name: fmt.Sprintf("vxlan-poller-%s", overlay.Name), Runner: overlayPoller})
+		healthHandler.Readiness = append(healthHandler.Readiness, overlayReadiness)
+	}
+
+	healthHandler.NetworkInfo = networkInfo
+	healthCheckServer := http_server.New(fmt.Sprintf("127.0.0.1:%d", cfg.HealthCheckPort), healthHandler)
+
+	uptimeSource := metrics.NewUptimeSource()
+	metricsEmitter := metrics.NewMetricsEmitter(logger, 30*time.Second, uptimeSource)
+	members := grouper.Members{
+		{Name: "server", Runner: healthCheckServer},
+	}
+	members = append(members, pollers...)
+	if diagnosticHandler != nil {
+		members = append(members, grouper.Member{
+			Name:   "diagnostic-server",
+			Runner: http_server.New(fmt.Sprintf("127.0.0.1:%d", cfg.DiagnosticServerPort), diagnosticHandler),
+		})
+	}
+	members = append(members,
+		grouper.Member{Name: "debug-server", Runner: debugserver.Runner(debugServerAddress, reconfigurableSink)},
+		grouper.Member{Name: "metrics-emitter", Runner: metricsEmitter},
+	)
+	group := grouper.NewOrdered(os.Interrupt, members)
+	monitor := ifrit.Invoke(sigmon.New(group))
+
+	err = <-monitor.Wait()
+	return err
+}
+
+// establishLease discovers the lease held by the VTEP named in cfg and renews it,
+// or acquires a new one when there is none. The overlay name is used to count
+// the containers that would be affected by replacing the lease.
+func establishLease(logger lager.Logger, cfg config.Config, client *controller.Client, vtepConfigCreator *vtep.ConfigCreator, vtepFactory *vtep.Factory, store *datastore.Store, overlayName string) (controller.Lease, error) {
 	_, overlayNetwork, err := net.ParseCIDR(cfg.OverlayNetwork)
 	if err != nil {
-		return fmt.Errorf("parse overlay network CIDR: %s", err) //TODO add test coverage
+		return controller.Lease{}, fmt.Errorf("parse overlay network CIDR: %s", err) //TODO add test coverage
 	}
 
 	lease, err := discoverLocalLease(cfg, vtepFactory)
 	if err != nil {
-		lease, err = acquireLease(logger, client, vtepConfigCreator, vtepFactory, cfg)
+		return acquireLease(logger, client, vtepConfigCreator, vtepFactory, cfg)
+	}
+
+	_, localSubnet, err := net.ParseCIDR(lease.OverlaySubnet)
+	if err != nil {
+		return controller.Lease{}, fmt.Errorf("parse local subnet CIDR: %s", err) //TODO add test coverage
+	}
+
+	if !overlayNetwork.Contains(localSubnet.IP) {
+		logger.Error("network-contains-lease", fmt.Errorf("discovered lease is not in overlay network"), lager.Data{
+			"lease":   lease,
+			"network": cfg.OverlayNetwork,
+		})
+
+		containerCount, err := countContainers(store, cfg.Datastore, overlayName)
 		if err != nil {
-			return err
+			return controller.Lease{}, err
 		}
-	} else {
-		_, localSubnet, err := net.ParseCIDR(lease.OverlaySubnet)
+
+		if containerCount != 0 {
+			return controller.Lease{}, fmt.Errorf("discovered lease is not in overlay network and has containers: %d", containerCount)
+		}
+
+		lease, err = deleteAndAcquire(cfg, logger, client, vtepConfigCreator, vtepFactory)
 		if err != nil {
-			return fmt.Errorf("parse local subnet CIDR: %s", err) //TODO add test coverage
+			return controller.Lease{}, err
 		}
+	}
+
+	err = client.RenewSubnetLease(lease)
+	if err != nil {
+		logger.Error("renew-lease", err, lager.Data{"lease": lease})
 
-		if !overlayNetwork.Contains(localSubnet.IP) {
-			logger.Error("network-contains-lease", fmt.Errorf("discovered lease is not in overlay network"), lager.Data{
-				"lease":   lease,
-				"network": cfg.OverlayNetwork,
-			})
-
-			metadata, err := store.ReadAll(cfg.Datastore)
-			if err != nil {
-				return fmt.Errorf("read datastore: %s", err)
-			}
-
-			if len(metadata) != 0 {
-				return fmt.Errorf("discovered lease is not in overlay network and has containers: %d", len(metadata))
-			} else {
-				lease, err = deleteAndAcquire(cfg, logger, client, vtepConfigCreator, vtepFactory)
-				if err != nil {
-					return err
-				}
-			}
+		containerCount, err := countContainers(store, cfg.Datastore, overlayName)
+		if err != nil {
+			return controller.Lease{}, err
+		}
+
+		if containerCount != 0 {
+			return controller.Lease{}, fmt.Errorf("renew subnet lease with containers: %d", containerCount)
 		}
 
-		err = client.RenewSubnetLease(lease)
+		lease, err = deleteAndAcquire(cfg, logger, client, vtepConfigCreator, vtepFactory)
 		if err != nil {
-			logger.Error("renew-lease", err, lager.Data{"lease": lease})
-
-			metadata, err := store.ReadAll(cfg.Datastore)
-			if err != nil {
-				return fmt.Errorf("read datastore: %s", err)
-			}
-
-			if len(metadata) != 0 {
-				return fmt.Errorf("renew subnet lease with containers: %d", len(metadata))
-			} else {
-				lease, err = deleteAndAcquire(cfg, logger, client, vtepConfigCreator, vtepFactory)
-				if err != nil {
-					return err
-				}
-			}
+			return controller.Lease{}, err
 		}
-		logger.Info("renewed-lease", lager.Data{"lease": lease})
 	}
+	logger.Info("renewed-lease", lager.Data{"lease": lease})
 
-	debugServerAddress := fmt.Sprintf("127.0.0.1:%d", cfg.DebugServerPort)
-	networkInfo, err := getNetworkInfo(vtepFactory, cfg, lease)
+	return lease, nil
+}
+
+// countContainers returns the number of containers in the datastore that are
+// attached to the named overlay. Containers without an overlay belong to the
+// default overlay, which has an empty name.
+func countContainers(store *datastore.Store, datastorePath, overlayName string) (int, error) {
+	metadata, err := store.ReadAll(datastorePath)
 	if err != nil {
-		return fmt.Errorf("get network info: %s", err) // not tested
+		return 0, fmt.Errorf("read datastore: %s", err)
 	}
 
-	healthCheckServer, err := buildHealthCheckServer(cfg.HealthCheckPort, networkInfo)
+	count := 0
+	for _, container := range metadata {
+		containerOverlay, _ := container.Metadata["overlay"].(string)
+		if containerOverlay == overlayName {
+			count++
+		}
+	}
+	return count, nil
+}
+
+type leaseClient interface {
+	GetActiveLeases() ([]controller.Lease, error)
+	RenewSubnetLease(controller.Lease) error
+}
+
+// newVXLANPoller builds the poller that renews the lease and converges the VTEP.
+// When a migration client is given, the poller also follows changes of the
+// underlay IP by moving the lease and recreating the VTEP. When a diagnostic
+// handler is given, it is pointed at the state of the poller. The returned
+// readiness reports whether the poller has converged recently.
+func newVXLANPoller(logger lager.Logger, cfg config.Config, client leaseClient, lease controller.Lease, metricSender *metrics.MetricsSender, migrationClient *controller.Client, store *datastore.Store, overlayName string, diagnosticHandler *diagnostics.Handler) (*poller.Poller, *health.Readiness, error) {
+	_, overlayNetwork, err := net.ParseCIDR(cfg.OverlayNetwork)
 	if err != nil {
-		return fmt.Errorf("create health check server: %s", err) // not tested
+		return nil, nil, fmt.Errorf("parse overlay network CIDR: %s", err) //TODO add test coverage
 	}
 
 	_, localSubnet, err := net.ParseCIDR(lease.OverlaySubnet)
 	if err != nil {
-		return fmt.Errorf("parse local subnet CIDR: %s", err) //TODO add test coverage
+		return nil, nil, fmt.Errorf("parse local subnet CIDR: %s", err) //TODO add test coverage
 	}
 
 	vxlanIface, err := net.InterfaceByName(cfg.VTEPName)
 	if err != nil || vxlanIface == nil {
-		return fmt.Errorf("find local VTEP: %s", err) //TODO add test coverage
+		return nil, nil, fmt.Errorf("find local VTEP: %s", err) //TODO add test coverage
 	}
 
-	vxlanPoller := &poller.Poller{
-		Logger:                 logger,
-		PollInterval:           time.Duration(cfg.PollInterval) * time.Second,
-		RunBeforeFirstInterval: true,
-		SingleCycleFunc: (&planner.VXLANPlanner{
-			Logger:           logger,
-			ControllerClient: client,
-			Lease:            lease,
-			Converger: &vtep.Converger{
-				OverlayNetwork: overlayNetwork,
-				LocalSubnet:    localSubnet,
-				LocalVTEP:      *vxlanIface,
+	converger := &vtep.Converger{
+		OverlayNetwork: overlayNetwork,
+		LocalSubnet:    localSubnet,
+		LocalVTEP:      *vxlanIface,
+		NetlinkAdapter: &adapter.NetlinkAdapter{},
+		Logger:         logger,
+	}
+
+	errorDetector := planner.NewGracefulDetector(
+		time.Duration(cfg.PartitionToleranceSeconds) * time.Second,
+	)
+	vxlanPlanner := &planner.VXLANPlanner{
+		Logger:           logger,
+		ControllerClient: client,
+		Lease:            lease,
+		Converger:        converger,
+		ErrorDetector:    errorDetector,
+		MetricSender:     metricSender,
+	}
+
+	pollInterval := time.Duration(cfg.PollInterval) * time.Second
+	readiness := &health.Readiness{
+		MaxAge:        time.Duration(cfg.ReadinessIntervals) * pollInterval,
+		GraceDuration: time.Duration(cfg.PartitionToleranceSeconds) * time.Second,
+		Renewals:      errorDetector,
+	}
+	vxlanPlanner.ConvergeRecorders = append(vxlanPlanner.ConvergeRecorders, readiness)
+
+	if diagnosticHandler != nil {
+		vxlanPlanner.ConvergeRecorders = append(vxlanPlanner.ConvergeRecorders, diagnosticHandler.State)
+		diagnosticHandler.Renewals = errorDetector
+		diagnosticHandler.VTEP = converger
+	}
+
+	if migrationClient != nil {
+		vxlanPlanner.UnderlayMonitor = &vtep.UnderlayMonitor{
+			NetlinkAdapter: &adapter.NetlinkAdapter{},
+			VTEPName:       cfg.VTEPName,
+		}
+		vxlanPlanner.LeaseMigrator = &vtep.LeaseMigrator{
+			ControllerClient: migrationClient,
+			ConfigCreator:    &vtep.ConfigCreator{NetAdapter: &adapter.NetAdapter{}},
+			Factory: &vtep.Factory{
 				NetlinkAdapter: &adapter.NetlinkAdapter{},
 				Logger:         logger,
 			},
-			ErrorDetector: planner.NewGracefulDetector(
-				time.Duration(cfg.PartitionToleranceSeconds) * time.Second,
-			),
-			MetricSender: metricSender,
-		}).DoCycle,
+			NetAdapter:   &adapter.NetAdapter{},
+			Converger:    converger,
+			ClientConfig: cfg,
+			ContainerCount: func() (int, error) {
+				return countContainers(store, cfg.Datastore, overlayName)
+			},
+			Logger: logger,
+		}
 	}
 
-	uptimeSource := metrics.NewUptimeSource()
-	metricsEmitter := metrics.NewMetricsEmitter(logger, 30*time.Second, uptimeSource)
-	members := grouper.Members{
-		{Name: "server", Runner: healthCheckServer},
-		{Name: "vxlan-poller", Runner: vxlanPoller},
-		{Name: "debug-server", Runner: debugserver.Runner(debugServerAddress, reconfigurableSink)},
-		{Name: "metrics-emitter", Runner: metricsEmitter},
+	return &poller.Poller{
+		Logger:                 logger,
+		PollInterval:           pollInterval,
+		RunBeforeFirstInterval: true,
+		SingleCycleFunc:        vxlanPlanner.DoCycle,
+	}, readiness, nil
+}
+
+// establishStaticLease sets up the VTEP for the local lease in the static lease file.
+// An existing VTEP holding a different lease is only replaced when it has no containers.
+func establishStaticLease(logger lager.Logger, cfg config.Config, leaseFile *static.LeaseFile, vtepConfigCreator *vtep.ConfigCreator, vtepFactory *vtep.Factory, store *datastore.Store) (controller.Lease, error) {
+	lease, err := leaseFile.LocalLease()
+	if err != nil {
+		return controller.Lease{}, fmt.Errorf("read static lease file: %s", err)
 	}
-	group := grouper.NewOrdered(os.Interrupt, members)
-	monitor := ifrit.Invoke(sigmon.New(group))
 
-	err = <-monitor.Wait()
-	return err
+	if lease.UnderlayIP != cfg.UnderlayIP {
+		return controller.Lease{}, fmt.Errorf("static lease underlay ip %s does not match %s", lease.UnderlayIP, cfg.UnderlayIP)
+	}
+
+	discoveredLease, err := discoverLocalLease(cfg, vtepFactory)
+	if err == nil {
+		if discoveredLease == lease {
+			logger.Info("discovered-static-lease", lager.Data{"lease": lease})
+			return lease, nil
+		}
+
+		containerCount, err := countContainers(store, cfg.Datastore, "")
+		if err != nil {
+			return controller.Lease{}, err
+		}
+		if containerCount != 0 {
+			return controller.Lease{}, fmt.Errorf("discovered lease does not match static lease and has containers: %d", containerCount)
+		}
+
+		err = vtepFactory.DeleteVTEP(cfg.VTEPName)
+		if err != nil {
+			return controller.Lease{}, fmt.Errorf("delete vtep: %s", err) // not tested, should be impossible
+		}
+	}
+
+	err = createVTEP(cfg, lease, vtepConfigCreator, vtepFactory)
+	if err != nil {
+		return controller.Lease{}, err
+	}
+	logger.Info("static-lease", lager.Data{"lease": lease})
+
+	return lease, nil
+}
+
+func createVTEP(cfg config.Config, lease controller.Lease, vtepConfigCreator *vtep.ConfigCreator, vtepFactory *vtep.Factory) error {
+	vtepConf, err := vtepConfigCreator.Create(cfg, lease)
+	if err != nil {
+		return fmt.Errorf("create vtep config: %s", err) // not tested
+	}
+
+	err = vtepFactory.CreateVTEP(vtepConf)
+	if err != nil {
+		return fmt.Errorf("create vtep: %s", err) // not tested
+	}
+
+	return nil
 }
 
 func acquireLease(logger lager.Logger, client *controller.Client, vtepConfigCreator *vtep.ConfigCreator, vtepFactory *vtep.Factory, cfg config.Config) (controller.Lease, error) {
@@ -242,35 +476,14 @@ func acquireLease(logger lager.Logger, client *controller.Client, vtepConfigCrea
 		return controller.Lease{}, fmt.Errorf("invalid health check port: %d", cfg.HealthCheckPort)
 	}
 
-	vtepConf, err := vtepConfigCreator.Create(cfg, lease)
+	err := createVTEP(cfg, lease, vtepConfigCreator, vtepFactory)
 	if err != nil {
-		return controller.Lease{}, fmt.Errorf("create vtep config: %s", err) // not tested
-	}
-
-	err = vtepFactory.CreateVTEP(vtepConf)
-	if err != nil {
-		return controller.Lease{}, fmt.Errorf("create vtep: %s", err) // not tested
+		return controller.Lease{}, err
 	}
 
 	return lease, nil
 }
 
-func buildHealthCheckServer(healthCheckPort uint16, networkInfo daemon.NetworkInfo) (ifrit.Runner, error) {
-	networkBytes, err := json.Marshal(networkInfo)
-	if err != nil {
-		return nil, fmt.Errorf("unmarshaling network info: %s", err) // not possible
-	}
-
-	return http_server.New(
-		fmt.Sprintf("127.0.0.1:%d", healthCheckPort),
-		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(http.StatusOK)
-			// #nosec G104 - ignore errors when writing HTTP responses so we don't spam our logs during a DoS
-			w.Write(networkBytes)
-		}),
-	), nil
-}
-
 func discoverLocalLease(clientConfig config.Config, vtepFactory *vtep.Factory) (controller.Lease, error) {
 	overlayHwAddr, overlayIP, _, err := vtepFactory.GetVTEPState(clientConfig.VTEPName)
 	if err != nil {
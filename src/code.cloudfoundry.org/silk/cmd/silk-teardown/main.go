@@ -44,6 +44,19 @@ func mainWithError() error {
 	logger, _ := lagerflags.NewFromConfig(fmt.Sprintf("%s.%s", logPrefix, jobPrefix), getLagerConfig())
 	logger.Info("starting")
 
+	vtepFactory := &vtep.Factory{NetlinkAdapter: &adapter.NetlinkAdapter{}}
+
+	if cfg.StaticLeasesFile != "" {
+		// static leases are not held by a silk-controller, so there is nothing to release
+		var errList error
+		if err := vtepFactory.DeleteVTEP(cfg.VTEPName); err != nil {
+			errList = fmt.Errorf("delete vtep: %s", err)
+			logger.Error("delete-vtep", err, lager.Data{"vtep_name": cfg.VTEPName})
+		}
+		logger.Info("complete")
+		return errList
+	}
+
 	tlsConfig, err := mutualtls.NewClientTLSConfig(cfg.ClientCertFile, cfg.ClientKeyFile, cfg.ServerCACertFile)
 	if err != nil {
 		return fmt.Errorf("create tls config: %s", err)
@@ -53,6 +66,21 @@ func mainWithError() error {
 			TLSClientConfig: tlsConfig,
 		},
 	}
+
+	errList := teardownOverlay(logger, cfg, httpClient, vtepFactory)
+	for _, overlay := range cfg.AdditionalOverlays {
+		overlayLogger := logger.Session("overlay", lager.Data{"name": overlay.Name})
+		if err := teardownOverlay(overlayLogger, cfg.ForOverlay(overlay), httpClient, vtepFactory); err != nil {
+			errList = multierror.Append(errList, fmt.Errorf("overlay %s: %s", overlay.Name, err))
+		}
+	}
+
+	logger.Info("complete")
+
+	return errList
+}
+
+func teardownOverlay(logger lager.Logger, cfg config.Config, httpClient *http.Client, vtepFactory *vtep.Factory) error {
 	client := controller.NewClient(logger, httpClient, cfg.ConnectivityServerURL)
 
 	var errList error
@@ -61,14 +89,20 @@ func mainWithError() error {
 		logger.Error("release-subnet-lease", err, lager.Data{"underlay_ip": cfg.UnderlayIP})
 	}
 
-	vtepFactory := &vtep.Factory{NetlinkAdapter: &adapter.NetlinkAdapter{}}
+	// silk-daemon moves the lease when the underlay ip changes, so it may be held under the current ip
+	underlayMonitor := &vtep.UnderlayMonitor{NetlinkAdapter: vtepFactory.NetlinkAdapter, VTEPName: cfg.VTEPName}
+	if underlayIP, err := underlayMonitor.UnderlayIP(cfg.UnderlayIP); err == nil && underlayIP != cfg.UnderlayIP {
+		if err := client.ReleaseSubnetLease(underlayIP); err != nil {
+			errList = multierror.Append(errList, fmt.Errorf("release subnet lease: %s", err))
+			logger.Error("release-subnet-lease", err, lager.Data{"underlay_ip": underlayIP})
+		}
+	}
+
 	if err := vtepFactory.DeleteVTEP(cfg.VTEPName); err != nil {
 		errList = multierror.Append(errList, fmt.Errorf("delete vtep: %s", err))
 		logger.Error("delete-vtep", err, lager.Data{"vtep_name": cfg.VTEPName})
 	}
 
-	logger.Info("complete")
-
 	return errList
 }
 
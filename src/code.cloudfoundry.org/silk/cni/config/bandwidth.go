@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// CNI metadata keys a runtime can set to limit the bandwidth of a container
+// when it does not pass the bandwidth runtime config. Rates are in bits per
+// second and bursts in bits.
+const (
+	IngressRateMetadataKey  = "silk_ingress_rate"
+	IngressBurstMetadataKey = "silk_ingress_burst"
+	EgressRateMetadataKey   = "silk_egress_rate"
+	EgressBurstMetadataKey  = "silk_egress_burst"
+)
+
+// BandwidthLimits are the traffic limits of a container, as in the bandwidth
+// capability of the CNI runtime config. Ingress is traffic to the container
+// and egress is traffic from the container. A zero rate means no limit.
+type BandwidthLimits struct {
+	IngressRate  uint64 `json:"ingressRate"`
+	IngressBurst uint64 `json:"ingressBurst"`
+	EgressRate   uint64 `json:"egressRate"`
+	EgressBurst  uint64 `json:"egressBurst"`
+}
+
+// SelectBandwidth returns the limits from the runtime config, or from the
+// metadata when the runtime config has none.
+func SelectBandwidth(runtimeConfig *BandwidthLimits, metadata map[string]interface{}) (BandwidthLimits, error) {
+	var limits BandwidthLimits
+	if runtimeConfig != nil {
+		limits = *runtimeConfig
+	} else {
+		var err error
+		for key, value := range map[string]*uint64{
+			IngressRateMetadataKey:  &limits.IngressRate,
+			IngressBurstMetadataKey: &limits.IngressBurst,
+			EgressRateMetadataKey:   &limits.EgressRate,
+			EgressBurstMetadataKey:  &limits.EgressBurst,
+		} {
+			*value, err = metadataUint(metadata, key)
+			if err != nil {
+				return BandwidthLimits{}, err
+			}
+		}
+	}
+
+	if (limits.IngressRate == 0) != (limits.IngressBurst == 0) {
+		return BandwidthLimits{}, fmt.Errorf("ingress rate and burst must be set together")
+	}
+	if (limits.EgressRate == 0) != (limits.EgressBurst == 0) {
+		return BandwidthLimits{}, fmt.Errorf("egress rate and burst must be set together")
+	}
+	return limits, nil
+}
+
+func metadataUint(metadata map[string]interface{}, key string) (uint64, error) {
+	switch value := metadata[key].(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		if value < 0 || value != float64(uint64(value)) {
+			return 0, fmt.Errorf("invalid %s: %v", key, value)
+		}
+		return uint64(value), nil
+	case string:
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s: %s", key, err)
+		}
+		return parsed, nil
+	default:
+		return 0, fmt.Errorf("invalid %s: %v", key, value)
+	}
+}
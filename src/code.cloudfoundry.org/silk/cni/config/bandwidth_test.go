@@ -0,0 +1,66 @@
+package config_test
+
+import (
+	"code.cloudfoundry.org/silk/cni/config"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SelectBandwidth", func() {
+	It("selects the limits from the runtime config", func() {
+		limits, err := config.SelectBandwidth(&config.BandwidthLimits{
+			IngressRate:  1000,
+			IngressBurst: 2000,
+		}, map[string]interface{}{
+			"silk_egress_rate":  "3000",
+			"silk_egress_burst": "4000",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(limits).To(Equal(config.BandwidthLimits{
+			IngressRate:  1000,
+			IngressBurst: 2000,
+		}))
+	})
+
+	It("selects the limits from the metadata when there is no runtime config", func() {
+		limits, err := config.SelectBandwidth(nil, map[string]interface{}{
+			"silk_ingress_rate":  "1000",
+			"silk_ingress_burst": "2000",
+			"silk_egress_rate":   float64(3000),
+			"silk_egress_burst":  float64(4000),
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(limits).To(Equal(config.BandwidthLimits{
+			IngressRate:  1000,
+			IngressBurst: 2000,
+			EgressRate:   3000,
+			EgressBurst:  4000,
+		}))
+	})
+
+	It("selects no limits when none are set", func() {
+		limits, err := config.SelectBandwidth(nil, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(limits).To(Equal(config.BandwidthLimits{}))
+	})
+
+	It("returns an error when a metadata value is not a number", func() {
+		_, err := config.SelectBandwidth(nil, map[string]interface{}{
+			"silk_ingress_rate": "fast",
+		})
+		Expect(err).To(MatchError(ContainSubstring("invalid silk_ingress_rate")))
+
+		_, err = config.SelectBandwidth(nil, map[string]interface{}{
+			"silk_egress_burst": float64(-1),
+		})
+		Expect(err).To(MatchError("invalid silk_egress_burst: -1"))
+	})
+
+	It("returns an error when a rate is set without a burst", func() {
+		_, err := config.SelectBandwidth(&config.BandwidthLimits{IngressRate: 1000}, nil)
+		Expect(err).To(MatchError("ingress rate and burst must be set together"))
+
+		_, err = config.SelectBandwidth(&config.BandwidthLimits{EgressBurst: 1000}, nil)
+		Expect(err).To(MatchError("egress rate and burst must be set together"))
+	})
+})
@@ -16,6 +16,9 @@ type netNS interface {
 type DualAddress struct {
 	Hardware net.HardwareAddr
 	IP       net.IP
+
+	// IPv6 is only set on dual-stack networks
+	IPv6 net.IP
 }
 
 type Config struct {
@@ -26,16 +29,42 @@ type Config struct {
 		Address             DualAddress
 		MTU                 int
 		Routes              []*types.Route
+
+		// Sysctls are set in the container namespace after the routes
+		Sysctls map[string]string
 	}
 	Host struct {
-		DeviceName string
-		Namespace  netNS
-		Address    DualAddress
+		DeviceName    string
+		IFBDeviceName string
+		Namespace     netNS
+		Address       DualAddress
 	}
+	Bandwidth BandwidthLimits
 }
 
 func (c *Config) AsCNIResult() *current.Result {
 	ipInterface := 1
+	ips := []*current.IPConfig{
+		&current.IPConfig{
+			Interface: &ipInterface,
+			Address: net.IPNet{
+				IP:   c.Container.Address.IP,
+				Mask: []byte{255, 255, 255, 255},
+			},
+			Gateway: c.Host.Address.IP,
+		},
+	}
+	if c.Container.Address.IPv6 != nil {
+		ips = append(ips, &current.IPConfig{
+			Interface: &ipInterface,
+			Address: net.IPNet{
+				IP:   c.Container.Address.IPv6,
+				Mask: net.CIDRMask(128, 128),
+			},
+			Gateway: c.Host.Address.IPv6,
+		})
+	}
+
 	return &current.Result{
 		Interfaces: []*current.Interface{
 			&current.Interface{
@@ -49,16 +78,7 @@ func (c *Config) AsCNIResult() *current.Result {
 				Sandbox: c.Container.Namespace.Path(),
 			},
 		},
-		IPs: []*current.IPConfig{
-			&current.IPConfig{
-				Interface: &ipInterface,
-				Address: net.IPNet{
-					IP:   c.Container.Address.IP,
-					Mask: []byte{255, 255, 255, 255},
-				},
-				Gateway: c.Host.Address.IP,
-			},
-		},
+		IPs:    ips,
 		Routes: c.Container.Routes,
 		DNS:    types.DNS{},
 	}
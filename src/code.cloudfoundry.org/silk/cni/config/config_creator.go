@@ -22,6 +22,7 @@ type hardwareAddressGenerator interface {
 type deviceNameGenerator interface {
 	GenerateForHost(containerIP net.IP) (string, error)
 	GenerateTemporaryForContainer(containerIP net.IP) (string, error)
+	GenerateForHostIFB(containerIP net.IP) (string, error)
 }
 
 //go:generate counterfeiter -o fakes/namespaceAdapter.go --fake-name NamespaceAdapter . namespaceAdapter
@@ -60,7 +61,17 @@ func (c *ConfigCreator) Create(hostNS netNS, addCmdArgs *skel.CmdArgs, ipamResul
 	if len(ipamResult.IPs) == 0 {
 		return nil, errors.New("no IP address in IPAM result")
 	}
-	conf.Container.Address.IP = ipamResult.IPs[0].Address.IP
+	for _, ipConfig := range ipamResult.IPs {
+		ip := ipConfig.Address.IP
+		if ip.To4() != nil && conf.Container.Address.IP == nil {
+			conf.Container.Address.IP = ip
+		} else if ip.To4() == nil && conf.Container.Address.IPv6 == nil {
+			conf.Container.Address.IPv6 = ip
+		}
+	}
+	if conf.Container.Address.IP == nil {
+		return nil, errors.New("no IPv4 address in IPAM result")
+	}
 
 	conf.Container.TemporaryDeviceName, err = c.DeviceNameGenerator.GenerateTemporaryForContainer(conf.Container.Address.IP)
 	if err != nil {
@@ -78,6 +89,11 @@ func (c *ConfigCreator) Create(hostNS netNS, addCmdArgs *skel.CmdArgs, ipamResul
 		return nil, fmt.Errorf("generating host device name: %s", err)
 	}
 
+	conf.Host.IFBDeviceName, err = c.DeviceNameGenerator.GenerateForHostIFB(conf.Container.Address.IP)
+	if err != nil {
+		return nil, fmt.Errorf("generating host ifb device name: %s", err)
+	}
+
 	conf.Host.Namespace = hostNS
 	conf.Host.Address.IP = net.IP{169, 254, 0, 1}
 	conf.Host.Address.Hardware, err = c.HardwareAddressGenerator.GenerateForHost(conf.Container.Address.IP)
@@ -95,5 +111,16 @@ func (c *ConfigCreator) Create(hostNS netNS, addCmdArgs *skel.CmdArgs, ipamResul
 		},
 	}
 
+	if conf.Container.Address.IPv6 != nil {
+		conf.Host.Address.IPv6 = HostIPv6
+		conf.Container.Routes = append(conf.Container.Routes, &types.Route{
+			Dst: net.IPNet{
+				IP:   net.IPv6zero,
+				Mask: net.CIDRMask(0, 128),
+			},
+			GW: HostIPv6,
+		})
+	}
+
 	return &conf, nil
 }
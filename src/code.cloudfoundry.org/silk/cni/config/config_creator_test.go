@@ -60,6 +60,7 @@ var _ = Describe("ConfigCreator", func() {
 			fakeHardwareAddressGenerator.GenerateForHostReturns(hostMAC, nil)
 			fakeDeviceNameGenerator.GenerateForHostReturns("s-010255030004", nil)
 			fakeDeviceNameGenerator.GenerateTemporaryForContainerReturns("c-010255030004", nil)
+			fakeDeviceNameGenerator.GenerateForHostIFBReturns("i-010255030004", nil)
 			containerNS.PathReturns("/some/container/namespace")
 			configCreator = &config.ConfigCreator{
 				HardwareAddressGenerator: fakeHardwareAddressGenerator,
@@ -97,9 +98,67 @@ var _ = Describe("ConfigCreator", func() {
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(conf.Host.DeviceName).To(Equal("s-010255030004"))
+			Expect(conf.Host.IFBDeviceName).To(Equal("i-010255030004"))
 			Expect(conf.Host.Namespace).To(Equal(hostNS))
 			Expect(conf.Host.Address.IP).To(Equal(net.IP{169, 254, 0, 1}))
 			Expect(conf.Host.Address.Hardware).To(Equal(hostMAC))
+			Expect(conf.Host.Address.IPv6).To(BeNil())
+		})
+
+		Context("when the IPAM result has an IPv6 address", func() {
+			BeforeEach(func() {
+				ipamResult.IPs = append([]*current.IPConfig{
+					&current.IPConfig{
+						Address: net.IPNet{
+							IP:   net.ParseIP("fd00:5111::7b7c:7d7e"),
+							Mask: net.CIDRMask(128, 128),
+						},
+					},
+				}, ipamResult.IPs...)
+			})
+
+			It("creates a dual-stack config", func() {
+				conf, err := configCreator.Create(hostNS, addCmdArgs, ipamResult, 1450)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(conf.Container.Address.IP).To(Equal(net.IP{123, 124, 125, 126}))
+				Expect(conf.Container.Address.IPv6).To(Equal(net.ParseIP("fd00:5111::7b7c:7d7e")))
+				Expect(conf.Host.Address.IPv6).To(Equal(net.ParseIP("fe80::1")))
+				Expect(conf.Container.Routes).To(ConsistOf([]*types.Route{
+					&types.Route{
+						Dst: net.IPNet{
+							IP:   net.IPv4zero,
+							Mask: net.CIDRMask(0, 32),
+						},
+						GW: net.IP{169, 254, 0, 1},
+					},
+					&types.Route{
+						Dst: net.IPNet{
+							IP:   net.IPv6zero,
+							Mask: net.CIDRMask(0, 128),
+						},
+						GW: net.ParseIP("fe80::1"),
+					},
+				}))
+			})
+
+			It("derives the device names and hardware addresses from the IPv4 address", func() {
+				_, err := configCreator.Create(hostNS, addCmdArgs, ipamResult, 1450)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeDeviceNameGenerator.GenerateForHostArgsForCall(0)).To(Equal(net.IP{123, 124, 125, 126}))
+				Expect(fakeHardwareAddressGenerator.GenerateForContainerArgsForCall(0)).To(Equal(net.IP{123, 124, 125, 126}))
+			})
+		})
+
+		Context("when the IPAM result has no IPv4 address", func() {
+			BeforeEach(func() {
+				ipamResult.IPs[0].Address.IP = net.ParseIP("fd00:5111::7b7c:7d7e")
+			})
+			It("returns an error", func() {
+				_, err := configCreator.Create(hostNS, addCmdArgs, ipamResult, 1450)
+				Expect(err).To(MatchError("no IPv4 address in IPAM result"))
+			})
 		})
 
 		Context("when the args interface name is blank", func() {
@@ -162,6 +221,16 @@ var _ = Describe("ConfigCreator", func() {
 			})
 		})
 
+		Context("when the device name generator fails for the host ifb device", func() {
+			BeforeEach(func() {
+				fakeDeviceNameGenerator.GenerateForHostIFBReturns("", errors.New("potato"))
+			})
+			It("wraps and returns the error", func() {
+				_, err := configCreator.Create(hostNS, addCmdArgs, ipamResult, 1450)
+				Expect(err).To(MatchError("generating host ifb device name: potato"))
+			})
+		})
+
 		Context("when the device name generator fails for the container's temporary name", func() {
 			BeforeEach(func() {
 				fakeDeviceNameGenerator.GenerateTemporaryForContainerReturns("", errors.New("potato"))
@@ -65,5 +65,21 @@ var _ = Describe("Config", func() {
 
 			Expect(result.Routes).To(ConsistOf(cfg.Container.Routes))
 		})
+
+		Context("when the config is dual-stack", func() {
+			BeforeEach(func() {
+				cfg.Container.Address.IPv6 = net.ParseIP("fd00:5111::aff:1e05")
+				cfg.Host.Address.IPv6 = net.ParseIP("fe80::1")
+			})
+
+			It("reports both ips on the container device", func() {
+				result := cfg.AsCNIResult()
+				Expect(result.IPs).To(HaveLen(2))
+				Expect(result.IPs[0].Address.String()).To(Equal("10.255.30.5/32"))
+				Expect(*result.IPs[1].Interface).To(Equal(1))
+				Expect(result.IPs[1].Address.String()).To(Equal("fd00:5111::aff:1e05/128"))
+				Expect(result.IPs[1].Gateway.String()).To(Equal("fe80::1"))
+			})
+		})
 	})
 })
@@ -0,0 +1,180 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/containernetworking/cni/pkg/types"
+)
+
+// CNI metadata keys a runtime can set to tune a container when it does not
+// pass the sysctls or routes runtime config. Sysctls are an object of names
+// to values and routes a list of destination CIDRs.
+const (
+	SysctlsMetadataKey = "silk_sysctls"
+	RoutesMetadataKey  = "silk_routes"
+)
+
+// sysctls in the net tree are namespaced per network namespace, so they
+// only affect the container they are set in.
+var namespacedSysctl = regexp.MustCompile(`^net(\.[A-Za-z0-9_-]+)+$`)
+
+// Allowlist is the set of sysctls and route destinations the operator lets
+// containers request. Sysctls are names, or name prefixes ending in "*".
+// Route destinations must be inside one of the route networks.
+type Allowlist struct {
+	Sysctls       []string
+	RouteNetworks []*net.IPNet
+}
+
+// NewAllowlist validates the allowlist of the plugin conf.
+func NewAllowlist(sysctls, routeNetworks []string) (Allowlist, error) {
+	allowlist := Allowlist{Sysctls: sysctls}
+	for _, name := range sysctls {
+		if !namespacedSysctl.MatchString(strings.TrimSuffix(strings.TrimSuffix(name, "*"), ".")) {
+			return Allowlist{}, fmt.Errorf("sysctl %s is not a namespaced network sysctl", name)
+		}
+	}
+	for _, network := range routeNetworks {
+		_, ipNet, err := net.ParseCIDR(network)
+		if err != nil {
+			return Allowlist{}, fmt.Errorf("invalid route network: %s", err)
+		}
+		if ipNet.IP.To4() == nil {
+			return Allowlist{}, fmt.Errorf("route network %s is not an ipv4 network", network)
+		}
+		allowlist.RouteNetworks = append(allowlist.RouteNetworks, ipNet)
+	}
+	return allowlist, nil
+}
+
+func (a Allowlist) allowsSysctl(name string) bool {
+	for _, allowed := range a.Sysctls {
+		if prefix, ok := strings.CutSuffix(allowed, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func (a Allowlist) allowsRoute(dst *net.IPNet) bool {
+	dstOnes, _ := dst.Mask.Size()
+	for _, network := range a.RouteNetworks {
+		ones, _ := network.Mask.Size()
+		if network.Contains(dst.IP) && dstOnes >= ones {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainerOptions are the sysctls and extra routes a container requested.
+type ContainerOptions struct {
+	Sysctls map[string]string
+	Routes  []*net.IPNet
+}
+
+// SelectContainerOptions returns the sysctls and the routes from the runtime
+// config, or from the metadata when the runtime config has none, and
+// checks them against the allowlist.
+func SelectContainerOptions(allowlist Allowlist, runtimeSysctls map[string]string, runtimeRoutes []string, metadata map[string]interface{}) (ContainerOptions, error) {
+	var err error
+	sysctls := runtimeSysctls
+	if sysctls == nil {
+		sysctls, err = metadataSysctls(metadata)
+		if err != nil {
+			return ContainerOptions{}, err
+		}
+	}
+	routes := runtimeRoutes
+	if routes == nil {
+		routes, err = metadataRoutes(metadata)
+		if err != nil {
+			return ContainerOptions{}, err
+		}
+	}
+
+	options := ContainerOptions{}
+	for name, value := range sysctls {
+		if !namespacedSysctl.MatchString(name) {
+			return ContainerOptions{}, fmt.Errorf("sysctl %s is not a namespaced network sysctl", name)
+		}
+		if !allowlist.allowsSysctl(name) {
+			return ContainerOptions{}, fmt.Errorf("sysctl %s is not allowed", name)
+		}
+		if value == "" || strings.ContainsAny(value, "\n\x00") {
+			return ContainerOptions{}, fmt.Errorf("invalid value %q for sysctl %s", value, name)
+		}
+		if options.Sysctls == nil {
+			options.Sysctls = map[string]string{}
+		}
+		options.Sysctls[name] = value
+	}
+	for _, route := range routes {
+		_, dst, err := net.ParseCIDR(route)
+		if err != nil {
+			return ContainerOptions{}, fmt.Errorf("invalid route: %s", err)
+		}
+		if !allowlist.allowsRoute(dst) {
+			return ContainerOptions{}, fmt.Errorf("route to %s is not allowed", dst)
+		}
+		options.Routes = append(options.Routes, dst)
+	}
+	return options, nil
+}
+
+// ExtraRoutes returns the routes to the requested destinations via gateway.
+func (o ContainerOptions) ExtraRoutes(gateway net.IP) []*types.Route {
+	var routes []*types.Route
+	for _, dst := range o.Routes {
+		routes = append(routes, &types.Route{Dst: *dst, GW: gateway})
+	}
+	return routes
+}
+
+func metadataSysctls(metadata map[string]interface{}) (map[string]string, error) {
+	switch value := metadata[SysctlsMetadataKey].(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		sysctls := map[string]string{}
+		for name, v := range value {
+			switch v := v.(type) {
+			case string:
+				sysctls[name] = v
+			case float64:
+				sysctls[name] = fmt.Sprintf("%v", v)
+			default:
+				return nil, fmt.Errorf("invalid value for sysctl %s in metadata %s", name, SysctlsMetadataKey)
+			}
+		}
+		return sysctls, nil
+	default:
+		return nil, fmt.Errorf("invalid metadata %s: must be an object", SysctlsMetadataKey)
+	}
+}
+
+func metadataRoutes(metadata map[string]interface{}) ([]string, error) {
+	switch value := metadata[RoutesMetadataKey].(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		var routes []string
+		for _, v := range value {
+			route, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid metadata %s: must be a list of CIDRs", RoutesMetadataKey)
+			}
+			routes = append(routes, route)
+		}
+		return routes, nil
+	default:
+		return nil, fmt.Errorf("invalid metadata %s: must be a list of CIDRs", RoutesMetadataKey)
+	}
+}
@@ -0,0 +1,93 @@
+package config_test
+
+import (
+	"net"
+
+	"code.cloudfoundry.org/silk/cni/config"
+	"github.com/containernetworking/cni/pkg/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NewAllowlist", func() {
+	It("parses the route networks", func() {
+		allowlist, err := config.NewAllowlist([]string{"net.core.somaxconn", "net.ipv4.tcp_*"}, []string{"10.10.0.0/16"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(allowlist.Sysctls).To(Equal([]string{"net.core.somaxconn", "net.ipv4.tcp_*"}))
+		Expect(allowlist.RouteNetworks).To(HaveLen(1))
+		Expect(allowlist.RouteNetworks[0].String()).To(Equal("10.10.0.0/16"))
+	})
+
+	DescribeTable("errors on an invalid allowlist", func(sysctls, routeNetworks []string, errMessage string) {
+		_, err := config.NewAllowlist(sysctls, routeNetworks)
+		Expect(err).To(MatchError(errMessage))
+	},
+		Entry("a sysctl outside the net tree", []string{"kernel.shmmax"}, nil, "sysctl kernel.shmmax is not a namespaced network sysctl"),
+		Entry("all net sysctls", []string{"net.*"}, nil, "sysctl net.* is not a namespaced network sysctl"),
+		Entry("an invalid route network", nil, []string{"banana"}, "invalid route network: invalid CIDR address: banana"),
+		Entry("an ipv6 route network", nil, []string{"fd00::/64"}, "route network fd00::/64 is not an ipv4 network"),
+	)
+})
+
+var _ = Describe("SelectContainerOptions", func() {
+	var allowlist config.Allowlist
+
+	BeforeEach(func() {
+		var err error
+		allowlist, err = config.NewAllowlist([]string{"net.core.somaxconn", "net.ipv4.tcp_*"}, []string{"10.10.0.0/16"})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("selects the options from the runtime config", func() {
+		options, err := config.SelectContainerOptions(allowlist,
+			map[string]string{"net.core.somaxconn": "1024", "net.ipv4.tcp_keepalive_time": "600"},
+			[]string{"10.10.4.0/24"},
+			map[string]interface{}{"silk_sysctls": map[string]interface{}{"net.ipv4.tcp_fin_timeout": "30"}},
+		)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(options.Sysctls).To(Equal(map[string]string{"net.core.somaxconn": "1024", "net.ipv4.tcp_keepalive_time": "600"}))
+		Expect(options.Routes).To(HaveLen(1))
+		Expect(options.Routes[0].String()).To(Equal("10.10.4.0/24"))
+	})
+
+	It("selects the options from the metadata when there is no runtime config", func() {
+		options, err := config.SelectContainerOptions(allowlist, nil, nil, map[string]interface{}{
+			"silk_sysctls": map[string]interface{}{"net.core.somaxconn": float64(1024)},
+			"silk_routes":  []interface{}{"10.10.4.0/24"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(options.Sysctls).To(Equal(map[string]string{"net.core.somaxconn": "1024"}))
+		Expect(options.Routes).To(HaveLen(1))
+	})
+
+	It("returns no options when none are requested", func() {
+		options, err := config.SelectContainerOptions(allowlist, nil, nil, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(options).To(Equal(config.ContainerOptions{}))
+	})
+
+	It("builds the extra routes via the gateway", func() {
+		options, err := config.SelectContainerOptions(allowlist, nil, []string{"10.10.4.0/24"}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		routes := options.ExtraRoutes(net.ParseIP("169.254.0.1"))
+		Expect(routes).To(Equal([]*types.Route{{
+			Dst: net.IPNet{IP: net.IP{10, 10, 4, 0}, Mask: net.CIDRMask(24, 32)},
+			GW:  net.ParseIP("169.254.0.1"),
+		}}))
+	})
+
+	DescribeTable("errors on requests outside the allowlist", func(sysctls map[string]string, routes []string, metadata map[string]interface{}, errMessage string) {
+		_, err := config.SelectContainerOptions(allowlist, sysctls, routes, metadata)
+		Expect(err).To(MatchError(errMessage))
+	},
+		Entry("a sysctl that is not allowed", map[string]string{"net.ipv4.ip_forward": "1"}, nil, nil, "sysctl net.ipv4.ip_forward is not allowed"),
+		Entry("a sysctl outside the net tree", map[string]string{"kernel.shmmax": "1"}, nil, nil, "sysctl kernel.shmmax is not a namespaced network sysctl"),
+		Entry("a sysctl path", map[string]string{"net.ipv4.tcp_/../../kernel": "1"}, nil, nil, "sysctl net.ipv4.tcp_/../../kernel is not a namespaced network sysctl"),
+		Entry("an empty sysctl value", map[string]string{"net.core.somaxconn": ""}, nil, nil, `invalid value "" for sysctl net.core.somaxconn`),
+		Entry("a route outside the route networks", nil, []string{"10.11.0.0/24"}, nil, "route to 10.11.0.0/24 is not allowed"),
+		Entry("a route wider than the route networks", nil, []string{"10.0.0.0/8"}, nil, "route to 10.0.0.0/8 is not allowed"),
+		Entry("an invalid route", nil, []string{"banana"}, nil, "invalid route: invalid CIDR address: banana"),
+		Entry("invalid sysctls metadata", nil, nil, map[string]interface{}{"silk_sysctls": "net.core.somaxconn=1024"}, "invalid metadata silk_sysctls: must be an object"),
+		Entry("invalid routes metadata", nil, nil, map[string]interface{}{"silk_routes": "10.10.4.0/24"}, "invalid metadata silk_routes: must be a list of CIDRs"),
+	)
+})
@@ -20,6 +20,19 @@ type DeviceNameGenerator struct {
 		result1 string
 		result2 error
 	}
+	GenerateForHostIFBStub        func(net.IP) (string, error)
+	generateForHostIFBMutex       sync.RWMutex
+	generateForHostIFBArgsForCall []struct {
+		arg1 net.IP
+	}
+	generateForHostIFBReturns struct {
+		result1 string
+		result2 error
+	}
+	generateForHostIFBReturnsOnCall map[int]struct {
+		result1 string
+		result2 error
+	}
 	GenerateTemporaryForContainerStub        func(net.IP) (string, error)
 	generateTemporaryForContainerMutex       sync.RWMutex
 	generateTemporaryForContainerArgsForCall []struct {
@@ -101,6 +114,70 @@ func (fake *DeviceNameGenerator) GenerateForHostReturnsOnCall(i int, result1 str
 	}{result1, result2}
 }
 
+func (fake *DeviceNameGenerator) GenerateForHostIFB(arg1 net.IP) (string, error) {
+	fake.generateForHostIFBMutex.Lock()
+	ret, specificReturn := fake.generateForHostIFBReturnsOnCall[len(fake.generateForHostIFBArgsForCall)]
+	fake.generateForHostIFBArgsForCall = append(fake.generateForHostIFBArgsForCall, struct {
+		arg1 net.IP
+	}{arg1})
+	stub := fake.GenerateForHostIFBStub
+	fakeReturns := fake.generateForHostIFBReturns
+	fake.recordInvocation("GenerateForHostIFB", []interface{}{arg1})
+	fake.generateForHostIFBMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *DeviceNameGenerator) GenerateForHostIFBCallCount() int {
+	fake.generateForHostIFBMutex.RLock()
+	defer fake.generateForHostIFBMutex.RUnlock()
+	return len(fake.generateForHostIFBArgsForCall)
+}
+
+func (fake *DeviceNameGenerator) GenerateForHostIFBCalls(stub func(net.IP) (string, error)) {
+	fake.generateForHostIFBMutex.Lock()
+	defer fake.generateForHostIFBMutex.Unlock()
+	fake.GenerateForHostIFBStub = stub
+}
+
+func (fake *DeviceNameGenerator) GenerateForHostIFBArgsForCall(i int) net.IP {
+	fake.generateForHostIFBMutex.RLock()
+	defer fake.generateForHostIFBMutex.RUnlock()
+	argsForCall := fake.generateForHostIFBArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *DeviceNameGenerator) GenerateForHostIFBReturns(result1 string, result2 error) {
+	fake.generateForHostIFBMutex.Lock()
+	defer fake.generateForHostIFBMutex.Unlock()
+	fake.GenerateForHostIFBStub = nil
+	fake.generateForHostIFBReturns = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *DeviceNameGenerator) GenerateForHostIFBReturnsOnCall(i int, result1 string, result2 error) {
+	fake.generateForHostIFBMutex.Lock()
+	defer fake.generateForHostIFBMutex.Unlock()
+	fake.GenerateForHostIFBStub = nil
+	if fake.generateForHostIFBReturnsOnCall == nil {
+		fake.generateForHostIFBReturnsOnCall = make(map[int]struct {
+			result1 string
+			result2 error
+		})
+	}
+	fake.generateForHostIFBReturnsOnCall[i] = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *DeviceNameGenerator) GenerateTemporaryForContainer(arg1 net.IP) (string, error) {
 	fake.generateTemporaryForContainerMutex.Lock()
 	ret, specificReturn := fake.generateTemporaryForContainerReturnsOnCall[len(fake.generateTemporaryForContainerArgsForCall)]
@@ -170,6 +247,8 @@ func (fake *DeviceNameGenerator) Invocations() map[string][][]interface{} {
 	defer fake.invocationsMutex.RUnlock()
 	fake.generateForHostMutex.RLock()
 	defer fake.generateForHostMutex.RUnlock()
+	fake.generateForHostIFBMutex.RLock()
+	defer fake.generateForHostIFBMutex.RUnlock()
 	fake.generateTemporaryForContainerMutex.RLock()
 	defer fake.generateTemporaryForContainerMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"fmt"
+	"net"
+)
+
+// HostIPv6 is the gateway of the containers on dual-stack networks, the
+// IPv6 counterpart of 169.254.0.1. It is assigned to the host device of
+// every container.
+var HostIPv6 = net.ParseIP("fe80::1")
+
+// IPv6Subnet returns the IPv6 prefix of a cell. The IPv4 overlay subnet of
+// the cell is embedded in the last 32 bits of the IPv6 overlay network, so
+// the IPv6 overlay network must be a /96 or larger.
+func IPv6Subnet(ipv6Network, ipv4Subnet *net.IPNet) (*net.IPNet, error) {
+	networkOnes, networkBits := ipv6Network.Mask.Size()
+	if networkBits != 128 || ipv6Network.IP.To4() != nil {
+		return nil, fmt.Errorf("%s is not an IPv6 network", ipv6Network)
+	}
+	if networkOnes > 96 {
+		return nil, fmt.Errorf("%s is smaller than a /96", ipv6Network)
+	}
+	subnetOnes, subnetBits := ipv4Subnet.Mask.Size()
+	ipv4 := ipv4Subnet.IP.To4()
+	if subnetBits != 32 || ipv4 == nil {
+		return nil, fmt.Errorf("%s is not an IPv4 subnet", ipv4Subnet)
+	}
+
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, ipv6Network.IP.Mask(ipv6Network.Mask))
+	copy(ip[12:], ipv4)
+	return &net.IPNet{
+		IP:   ip,
+		Mask: net.CIDRMask(96+subnetOnes, 128),
+	}, nil
+}
+
+// IPv6Address returns the IPv6 address of a container, which embeds its
+// IPv4 address in the IPv6 prefix of its cell.
+func IPv6Address(ipv6Subnet *net.IPNet, ipv4 net.IP) (net.IP, error) {
+	if ipv4.To4() == nil {
+		return nil, fmt.Errorf("%s is not an IPv4 address", ipv4)
+	}
+
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, ipv6Subnet.IP)
+	copy(ip[12:], ipv4.To4())
+	if !ipv6Subnet.Contains(ip) {
+		return nil, fmt.Errorf("%s is not in the ipv4 subnet of %s", ipv4, ipv6Subnet)
+	}
+	return ip, nil
+}
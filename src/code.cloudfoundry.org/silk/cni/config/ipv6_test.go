@@ -0,0 +1,68 @@
+package config_test
+
+import (
+	"net"
+
+	"code.cloudfoundry.org/silk/cni/config"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("IPv6", func() {
+	var ipv6Network, ipv4Subnet *net.IPNet
+
+	BeforeEach(func() {
+		_, ipv6Network, _ = net.ParseCIDR("fd00:5111::/96")
+		_, ipv4Subnet, _ = net.ParseCIDR("10.255.30.0/24")
+	})
+
+	Describe("IPv6Subnet", func() {
+		It("embeds the ipv4 subnet in the ipv6 network", func() {
+			subnet, err := config.IPv6Subnet(ipv6Network, ipv4Subnet)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(subnet.String()).To(Equal("fd00:5111::aff:1e00/120"))
+		})
+
+		It("returns an error when the ipv6 network is smaller than a /96", func() {
+			_, ipv6Network, _ = net.ParseCIDR("fd00:5111::/112")
+			_, err := config.IPv6Subnet(ipv6Network, ipv4Subnet)
+			Expect(err).To(MatchError("fd00:5111::/112 is smaller than a /96"))
+		})
+
+		It("returns an error when the ipv6 network is not ipv6", func() {
+			_, err := config.IPv6Subnet(ipv4Subnet, ipv4Subnet)
+			Expect(err).To(MatchError("10.255.30.0/24 is not an IPv6 network"))
+		})
+
+		It("returns an error when the ipv4 subnet is not ipv4", func() {
+			_, err := config.IPv6Subnet(ipv6Network, ipv6Network)
+			Expect(err).To(MatchError("fd00:5111::/96 is not an IPv4 subnet"))
+		})
+	})
+
+	Describe("IPv6Address", func() {
+		var ipv6Subnet *net.IPNet
+
+		BeforeEach(func() {
+			var err error
+			ipv6Subnet, err = config.IPv6Subnet(ipv6Network, ipv4Subnet)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("embeds the ipv4 address in the ipv6 subnet", func() {
+			ip, err := config.IPv6Address(ipv6Subnet, net.ParseIP("10.255.30.4"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ip.String()).To(Equal("fd00:5111::aff:1e04"))
+		})
+
+		It("returns an error when the address is outside of the subnet", func() {
+			_, err := config.IPv6Address(ipv6Subnet, net.ParseIP("10.255.31.4"))
+			Expect(err).To(MatchError("10.255.31.4 is not in the ipv4 subnet of fd00:5111::aff:1e00/120"))
+		})
+
+		It("returns an error when the address is not ipv4", func() {
+			_, err := config.IPv6Address(ipv6Subnet, net.ParseIP("fd00::1"))
+			Expect(err).To(MatchError("fd00::1 is not an IPv4 address"))
+		})
+	})
+})
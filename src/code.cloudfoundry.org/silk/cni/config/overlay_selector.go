@@ -0,0 +1,34 @@
+package config
+
+import "fmt"
+
+// OverlayMetadataKey is the CNI metadata key a runtime can set to request
+// a specific overlay for a container. The request is only honoured when it
+// names the overlay configured for the container's org.
+const OverlayMetadataKey = "silk_overlay"
+
+// OverlaySelector picks the overlay a container joins based on its CNI metadata.
+// An empty overlay name refers to the default overlay.
+type OverlaySelector struct {
+	OrgOverlays map[string]string
+}
+
+func (s OverlaySelector) Select(metadata map[string]interface{}) (string, error) {
+	orgID, _ := metadata["org_id"].(string)
+	overlay := s.OrgOverlays[orgID]
+
+	if requested, ok := metadata[OverlayMetadataKey].(string); ok && requested != overlay {
+		return "", fmt.Errorf("overlay %q is not configured for org %q", requested, orgID)
+	}
+
+	return overlay, nil
+}
+
+// IPAMNetworkName returns the network name under which IPAM allocations
+// for the given overlay are stored.
+func IPAMNetworkName(network, overlay string) string {
+	if overlay == "" {
+		return network
+	}
+	return network + "-" + overlay
+}
@@ -0,0 +1,66 @@
+package config_test
+
+import (
+	"code.cloudfoundry.org/silk/cni/config"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OverlaySelector", func() {
+	var selector config.OverlaySelector
+
+	BeforeEach(func() {
+		selector = config.OverlaySelector{
+			OrgOverlays: map[string]string{
+				"some-org-guid": "isolated",
+			},
+		}
+	})
+
+	It("selects the overlay configured for the org", func() {
+		Expect(selector.Select(map[string]interface{}{
+			"org_id": "some-org-guid",
+		})).To(Equal("isolated"))
+	})
+
+	It("selects the default overlay for other orgs", func() {
+		Expect(selector.Select(map[string]interface{}{
+			"org_id": "other-org-guid",
+		})).To(BeEmpty())
+	})
+
+	It("selects the default overlay when there is no metadata", func() {
+		Expect(selector.Select(nil)).To(BeEmpty())
+	})
+
+	It("accepts a requested overlay that matches the org", func() {
+		Expect(selector.Select(map[string]interface{}{
+			"silk_overlay": "isolated",
+			"org_id":       "some-org-guid",
+		})).To(Equal("isolated"))
+	})
+
+	It("rejects a requested overlay that is not configured for the org", func() {
+		_, err := selector.Select(map[string]interface{}{
+			"silk_overlay": "requested",
+			"org_id":       "some-org-guid",
+		})
+		Expect(err).To(MatchError(`overlay "requested" is not configured for org "some-org-guid"`))
+
+		_, err = selector.Select(map[string]interface{}{
+			"silk_overlay": "isolated",
+			"org_id":       "other-org-guid",
+		})
+		Expect(err).To(MatchError(`overlay "isolated" is not configured for org "other-org-guid"`))
+	})
+})
+
+var _ = Describe("IPAMNetworkName", func() {
+	It("uses the network name for the default overlay", func() {
+		Expect(config.IPAMNetworkName("some-network", "")).To(Equal("some-network"))
+	})
+
+	It("suffixes the network name with the overlay name", func() {
+		Expect(config.IPAMNetworkName("some-network", "isolated")).To(Equal("some-network-isolated"))
+	})
+})
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"fmt"
+	"net"
+)
+
+// RequestedIP returns the IPv4 address a runtime requested for a container,
+// either with the IP CNI arg or with the ips capability of the runtime
+// config, or nil when it requested none. Entries of the runtime config may
+// be in CIDR notation; the prefix length is ignored, as containers always
+// get the subnet of the cell.
+func RequestedIP(argsIP net.IP, runtimeIPs []string) (net.IP, error) {
+	if len(runtimeIPs) > 1 {
+		return nil, fmt.Errorf("only one ip can be requested, got %d", len(runtimeIPs))
+	}
+
+	requested := argsIP
+	if len(runtimeIPs) == 1 {
+		ip, _, err := net.ParseCIDR(runtimeIPs[0])
+		if err != nil {
+			ip = net.ParseIP(runtimeIPs[0])
+		}
+		if ip == nil {
+			return nil, fmt.Errorf("invalid ip %q in runtime config", runtimeIPs[0])
+		}
+		if argsIP != nil && !argsIP.Equal(ip) {
+			return nil, fmt.Errorf("ip %s in CNI args does not match ip %s in runtime config", argsIP, ip)
+		}
+		requested = ip
+	}
+
+	if requested != nil && requested.To4() == nil {
+		return nil, fmt.Errorf("requested ip %s is not an ipv4 address", requested)
+	}
+	return requested, nil
+}
@@ -0,0 +1,49 @@
+package config_test
+
+import (
+	"net"
+
+	"code.cloudfoundry.org/silk/cni/config"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RequestedIP", func() {
+	It("returns nil when no ip is requested", func() {
+		ip, err := config.RequestedIP(nil, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ip).To(BeNil())
+	})
+
+	It("returns the ip from the CNI args", func() {
+		ip, err := config.RequestedIP(net.ParseIP("10.255.30.9"), nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ip.String()).To(Equal("10.255.30.9"))
+	})
+
+	DescribeTable("returns the ip from the runtime config", func(runtimeIP string) {
+		ip, err := config.RequestedIP(nil, []string{runtimeIP})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ip.String()).To(Equal("10.255.30.9"))
+	},
+		Entry("an ip", "10.255.30.9"),
+		Entry("an ip in CIDR notation", "10.255.30.9/24"),
+	)
+
+	It("accepts the same ip in the CNI args and the runtime config", func() {
+		ip, err := config.RequestedIP(net.ParseIP("10.255.30.9"), []string{"10.255.30.9/32"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ip.String()).To(Equal("10.255.30.9"))
+	})
+
+	DescribeTable("errors on invalid requests", func(argsIP net.IP, runtimeIPs []string, errMessage string) {
+		_, err := config.RequestedIP(argsIP, runtimeIPs)
+		Expect(err).To(MatchError(errMessage))
+	},
+		Entry("more than one ip", nil, []string{"10.255.30.9", "10.255.30.10"}, "only one ip can be requested, got 2"),
+		Entry("an invalid ip", nil, []string{"banana"}, `invalid ip "banana" in runtime config`),
+		Entry("different ips", net.ParseIP("10.255.30.8"), []string{"10.255.30.9"}, "ip 10.255.30.8 in CNI args does not match ip 10.255.30.9 in runtime config"),
+		Entry("an ipv6 address in the runtime config", nil, []string{"fd00::1/128"}, "requested ip fd00::1 is not an ipv4 address"),
+		Entry("an ipv6 address in the CNI args", net.ParseIP("fd00::1"), nil, "requested ip fd00::1 is not an ipv4 address"),
+	)
+})
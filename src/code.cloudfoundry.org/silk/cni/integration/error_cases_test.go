@@ -121,7 +121,7 @@ var _ = Describe("errors", func() {
 			})
 		})
 
-		Context("when the ipam plugin errors on add", func() {
+		Context("when the overlay subnet is invalid", func() {
 			BeforeEach(func() {
 				fakeServer = startFakeDaemonInHost(daemonPort, http.StatusOK, `{"overlay_subnet": "10.255.30.0/33", "mtu": 1350}`)
 				cniStdin = cniConfig(dataDir, datastorePath, daemonPort)
@@ -132,7 +132,7 @@ var _ = Describe("errors", func() {
 
 				Expect(session.Out.Contents()).To(MatchJSON(`{
 				"code": 100,
-				"msg": "generate ipam config",
+				"msg": "allocate ip",
 				"details": "invalid subnet: invalid CIDR address: 10.255.30.0/33"
 			}`))
 			})
@@ -151,6 +151,15 @@ var _ = Describe("errors", func() {
 				"details": "interface name should be less than 16 characters"
 			}`))
 			})
+
+			It("releases the allocated ip", func() {
+				cniEnv["CNI_IFNAME"] = "some-bad-eth-name"
+				cniStdin = cniConfig(dataDir, datastorePath, daemonPort)
+				session := startCommandInHost("ADD", cniStdin)
+				Eventually(session, cmdTimeout).Should(gexec.Exit(1))
+
+				Expect(ipamAllocations(dataDir, "my-silk-network")).To(BeEmpty())
+			})
 		})
 
 		Context("when the datastore is not specified", func() {
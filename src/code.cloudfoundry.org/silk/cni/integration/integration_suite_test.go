@@ -2,7 +2,6 @@ package integration_test
 
 import (
 	"encoding/json"
-	"fmt"
 	"math/rand"
 	"path"
 
@@ -40,15 +39,12 @@ var _ = SynchronizedBeforeSuite(func() []byte {
 	pathToSilkCNI, err := gexec.Build("code.cloudfoundry.org/silk/cmd/silk-cni", `-ldflags=-extldflags=-Wl,--allow-multiple-definition -X main.LoggingDevice=stderr`, "-race", "-buildvcs=false")
 	Expect(err).NotTo(HaveOccurred())
 
-	pathToIPAM, err := gexec.Build("github.com/containernetworking/plugins/plugins/ipam/host-local", "-race", "-buildvcs=false")
-	Expect(err).NotTo(HaveOccurred())
-
 	pathToFakeDaemon, err := gexec.Build("code.cloudfoundry.org/silk/cni/integration/fake_daemon", "-race", "-buildvcs=false")
 	Expect(err).NotTo(HaveOccurred())
 
 	paths = testPaths{
 		PathToPlugin:     pathToSilkCNI,
-		CNIPath:          fmt.Sprintf("%s:%s", path.Dir(pathToSilkCNI), path.Dir(pathToIPAM)),
+		CNIPath:          path.Dir(pathToSilkCNI),
 		PathToFakeDaemon: pathToFakeDaemon,
 	}
 
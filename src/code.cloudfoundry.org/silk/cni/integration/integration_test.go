@@ -186,7 +186,7 @@ var _ = Describe("Silk CNI Integration", func() {
 			sess := startCommandInHost("ADD", cniStdin)
 			Eventually(sess, cmdTimeout).Should(gexec.Exit(0))
 
-			Expect(filepath.Join(dataDir, "ipam/my-silk-network/10.255.30.2")).To(BeAnExistingFile())
+			Expect(ipamAllocations(dataDir, "my-silk-network")).To(HaveKey("10.255.30.2"))
 			fakeServer.Interrupt()
 			Eventually(fakeServer, "5s").Should(gexec.Exit())
 
@@ -194,7 +194,7 @@ var _ = Describe("Silk CNI Integration", func() {
 			Eventually(sess, cmdTimeout).Should(gexec.Exit(0))
 
 			By("checking that the ip reserved is freed")
-			Expect(filepath.Join(dataDir, "ipam/my-silk-network/10.255.30.2")).NotTo(BeAnExistingFile())
+			Expect(ipamAllocations(dataDir, "my-silk-network")).NotTo(HaveKey("10.255.30.2"))
 		})
 
 		hostLinkFromResult := func(cniResult []byte) netlink.Link {
@@ -472,12 +472,12 @@ var _ = Describe("Silk CNI Integration", func() {
 	})
 
 	Describe("CNI version support", func() {
-		It("only claims to support CNI spec version 0.3.1", func() {
+		It("claims to support CNI spec versions 1.0.0 and 1.1.0", func() {
 			sess := startCommandInHost("VERSION", "{}")
 			Eventually(sess, cmdTimeout).Should(gexec.Exit(0))
 			Expect(sess.Out.Contents()).To(MatchJSON(`{
           "cniVersion": "1.1.0",
-          "supportedVersions": [ "1.0.0" ]
+          "supportedVersions": [ "1.0.0", "1.1.0" ]
         }`))
 		})
 	})
@@ -500,9 +500,10 @@ var _ = Describe("Silk CNI Integration", func() {
 			Expect(result.IPs[0].Gateway.String()).To(Equal("169.254.0.1"))
 
 			By("checking that the ip is reserved for the correct container id")
-			bytes, err := os.ReadFile(filepath.Join(dataDir, "ipam/my-silk-network/10.255.30.2"))
-			Expect(err).NotTo(HaveOccurred())
-			Expect(string(bytes)).To(Equal(fmt.Sprintf("%s\r\neth0", containerID)))
+			allocations := ipamAllocations(dataDir, "my-silk-network")
+			Expect(allocations).To(HaveKey("10.255.30.2"))
+			Expect(allocations["10.255.30.2"]["container_id"]).To(Equal(containerID))
+			Expect(allocations["10.255.30.2"]["ifname"]).To(Equal("eth0"))
 
 			By("calling DEL")
 			sess = startCommandInHost("DEL", cniStdin)
@@ -510,7 +511,79 @@ var _ = Describe("Silk CNI Integration", func() {
 			Expect(sess.Out.Contents()).To(BeEmpty())
 
 			By("checking that the ip reserved is freed")
-			Expect(filepath.Join(dataDir, "ipam/my-silk-network/10.255.30.2")).NotTo(BeAnExistingFile())
+			Expect(ipamAllocations(dataDir, "my-silk-network")).NotTo(HaveKey("10.255.30.2"))
+		})
+
+		It("allocates the ip requested in the CNI args", func() {
+			cniEnv["CNI_ARGS"] = "IP=10.255.30.9;K8S_POD_NAME=ignored"
+			sess := startCommandInHost("ADD", cniStdin)
+			Eventually(sess, cmdTimeout).Should(gexec.Exit(0))
+
+			result := cniResultForCurrentVersion(sess.Out.Contents())
+			Expect(result.IPs[0].Address.String()).To(Equal("10.255.30.9/32"))
+			Expect(ipamAllocations(dataDir, "my-silk-network")).To(HaveKey("10.255.30.9"))
+
+			sess = startCommandInHost("DEL", cniStdin)
+			Eventually(sess, cmdTimeout).Should(gexec.Exit(0))
+		})
+
+		It("allocates the ip requested in the runtime config", func() {
+			cniStdin = cniConfigWithExtras(dataDir, datastorePath, daemonPort, map[string]interface{}{
+				"runtimeConfig": map[string]interface{}{
+					"ips": []string{"10.255.30.9/24"},
+				},
+			})
+			sess := startCommandInHost("ADD", cniStdin)
+			Eventually(sess, cmdTimeout).Should(gexec.Exit(0))
+
+			result := cniResultForCurrentVersion(sess.Out.Contents())
+			Expect(result.IPs[0].Address.String()).To(Equal("10.255.30.9/32"))
+
+			sess = startCommandInHost("DEL", cniStdin)
+			Eventually(sess, cmdTimeout).Should(gexec.Exit(0))
+		})
+
+		It("rejects a requested ip outside the subnet of the cell", func() {
+			cniEnv["CNI_ARGS"] = "IP=10.255.31.9"
+			sess := startCommandInHost("ADD", cniStdin)
+			Eventually(sess, cmdTimeout).Should(gexec.Exit(1))
+
+			var cniErr map[string]interface{}
+			Expect(json.Unmarshal(sess.Out.Contents(), &cniErr)).To(Succeed())
+			Expect(cniErr["code"]).To(BeEquivalentTo(7))
+			Expect(cniErr["msg"]).To(Equal("invalid requested ip"))
+			Expect(cniErr["details"]).To(Equal("requested ip 10.255.31.9 is not in subnet 10.255.30.0/24"))
+		})
+
+		It("asks to retry when the requested ip is held by another container", func() {
+			Expect(os.WriteFile(datastorePath, []byte(`{"other-container": {"handle": "other-container", "ip": "10.255.30.9"}}`), 0600)).To(Succeed())
+
+			cniEnv["CNI_ARGS"] = "IP=10.255.30.9"
+			sess := startCommandInHost("ADD", cniStdin)
+			Eventually(sess, cmdTimeout).Should(gexec.Exit(1))
+
+			var cniErr map[string]interface{}
+			Expect(json.Unmarshal(sess.Out.Contents(), &cniErr)).To(Succeed())
+			Expect(cniErr["code"]).To(BeEquivalentTo(11))
+			Expect(cniErr["msg"]).To(Equal("requested ip unavailable"))
+		})
+
+		It("keeps the ips reserved by host-local before the upgrade", func() {
+			ipamDir := filepath.Join(dataDir, "ipam", "my-silk-network")
+			Expect(os.MkdirAll(ipamDir, 0700)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(ipamDir, "10.255.30.2"), []byte("old-container\r\neth0"), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(ipamDir, "last_reserved_ip.0"), []byte("10.255.30.2"), 0600)).To(Succeed())
+
+			sess := startCommandInHost("ADD", cniStdin)
+			Eventually(sess, cmdTimeout).Should(gexec.Exit(0))
+
+			result := cniResultForCurrentVersion(sess.Out.Contents())
+			Expect(result.IPs[0].Address.String()).To(Equal("10.255.30.3/32"))
+			Expect(filepath.Join(ipamDir, "10.255.30.2")).NotTo(BeAnExistingFile())
+			Expect(ipamAllocations(dataDir, "my-silk-network")["10.255.30.2"]["container_id"]).To(Equal("old-container"))
+
+			sess = startCommandInHost("DEL", cniStdin)
+			Eventually(sess, cmdTimeout).Should(gexec.Exit(0))
 		})
 
 		It("writes and deletes container metadata", func() {
@@ -543,6 +616,299 @@ var _ = Describe("Silk CNI Integration", func() {
 		})
 	})
 
+	Describe("dual-stack", func() {
+		BeforeEach(func() {
+			cniStdin = cniConfigWithExtras(dataDir, datastorePath, daemonPort, map[string]interface{}{
+				"ipv6OverlayNetwork": "fd00:5111::/96",
+			})
+		})
+
+		It("reports both ips in the CNI result", func() {
+			sess := startCommandInHost("ADD", cniStdin)
+			Eventually(sess, cmdTimeout).Should(gexec.Exit(0))
+
+			result := cniResultForCurrentVersion(sess.Out.Contents())
+			Expect(result.IPs).To(HaveLen(2))
+			Expect(result.IPs[0].Address.String()).To(Equal("10.255.30.2/32"))
+			Expect(result.IPs[1].Address.String()).To(Equal("fd00:5111::aff:1e02/128"))
+			Expect(result.IPs[1].Gateway.String()).To(Equal("fe80::1"))
+			Expect(result.Routes).To(HaveLen(2))
+			Expect(result.Routes[1].Dst.String()).To(Equal("::/0"))
+			Expect(result.Routes[1].GW.String()).To(Equal("fe80::1"))
+		})
+
+		It("sets up IPv6 between the host and the container", func() {
+			sess := startCommandInHost("ADD", cniStdin)
+			Eventually(sess, cmdTimeout).Should(gexec.Exit(0))
+
+			By("checking the container address and default route")
+			Expect(mustSucceedInContainer("ip", "-6", "addr", "show", "dev", "eth0")).To(ContainSubstring("inet6 fd00:5111::aff:1e02 peer fe80::1/128"))
+			Expect(mustSucceedInContainer("ip", "-6", "route", "show", "default")).To(ContainSubstring("default via fe80::1 dev eth0"))
+			Expect(mustSucceedInContainer("ip", "-6", "neigh", "show", "dev", "eth0")).To(ContainSubstring("fe80::1 lladdr aa:aa:0a:ff:1e:02 PERMANENT"))
+
+			By("checking the host route and neighbor")
+			Expect(mustSucceedInFakeHost("ip", "-6", "route", "show", "dev", "s-010255030002")).To(ContainSubstring("fd00:5111::aff:1e02"))
+			Expect(mustSucceedInFakeHost("ip", "-6", "neigh", "show", "dev", "s-010255030002")).To(ContainSubstring("fd00:5111::aff:1e02 lladdr ee:ee:0a:ff:1e:02 PERMANENT"))
+		})
+
+		It("passes CHECK", func() {
+			sess := startCommandInHost("ADD", cniStdin)
+			Eventually(sess, cmdTimeout).Should(gexec.Exit(0))
+
+			var prevResult map[string]interface{}
+			Expect(json.Unmarshal(sess.Out.Contents(), &prevResult)).To(Succeed())
+			checkStdin := cniConfigWithExtras(dataDir, datastorePath, daemonPort, map[string]interface{}{
+				"ipv6OverlayNetwork": "fd00:5111::/96",
+				"prevResult":         prevResult,
+			})
+
+			sess = startCommandInHost("CHECK", checkStdin)
+			Eventually(sess, cmdTimeout).Should(gexec.Exit(0))
+		})
+
+		It("rejects an ipv6 overlay network smaller than a /96", func() {
+			cniStdin = cniConfigWithExtras(dataDir, datastorePath, daemonPort, map[string]interface{}{
+				"ipv6OverlayNetwork": "fd00:5111::/112",
+			})
+
+			sess := startCommandInHost("ADD", cniStdin)
+			Eventually(sess, cmdTimeout).Should(gexec.Exit(1))
+
+			var cniErr map[string]interface{}
+			Expect(json.Unmarshal(sess.Out.Contents(), &cniErr)).To(Succeed())
+			Expect(cniErr["code"]).To(BeEquivalentTo(7))
+			Expect(cniErr["msg"]).To(Equal("invalid ipv6 overlay network"))
+		})
+	})
+
+	Describe("container sysctls and routes", func() {
+		BeforeEach(func() {
+			cniStdin = cniConfigWithExtras(dataDir, datastorePath, daemonPort, map[string]interface{}{
+				"allowedSysctls":       []string{"net.core.somaxconn", "net.ipv4.tcp_*"},
+				"allowedRouteNetworks": []string{"10.10.0.0/16"},
+				"runtimeConfig": map[string]interface{}{
+					"sysctls": map[string]string{"net.core.somaxconn": "1234", "net.ipv4.tcp_keepalive_time": "321"},
+					"routes":  []string{"10.10.4.0/24"},
+				},
+			})
+		})
+
+		It("sets the sysctls and routes in the container", func() {
+			sess := startCommandInHost("ADD", cniStdin)
+			Eventually(sess, cmdTimeout).Should(gexec.Exit(0))
+
+			result := cniResultForCurrentVersion(sess.Out.Contents())
+			Expect(result.Routes).To(HaveLen(2))
+			Expect(result.Routes[1].Dst.String()).To(Equal("10.10.4.0/24"))
+			Expect(result.Routes[1].GW.String()).To(Equal("169.254.0.1"))
+
+			Expect(mustSucceedInContainer("cat", "/proc/sys/net/core/somaxconn")).To(Equal("1234\n"))
+			Expect(mustSucceedInContainer("cat", "/proc/sys/net/ipv4/tcp_keepalive_time")).To(Equal("321\n"))
+			Expect(mustSucceedInContainer("ip", "route", "show", "10.10.4.0/24")).To(ContainSubstring("10.10.4.0/24 via 169.254.0.1 dev eth0"))
+		})
+
+		It("rejects sysctls that are not allowed", func() {
+			cniStdin = cniConfigWithExtras(dataDir, datastorePath, daemonPort, map[string]interface{}{
+				"allowedSysctls": []string{"net.core.somaxconn"},
+				"metadata": map[string]interface{}{
+					"silk_sysctls": map[string]string{"net.ipv4.ip_forward": "1"},
+				},
+			})
+
+			sess := startCommandInHost("ADD", cniStdin)
+			Eventually(sess, cmdTimeout).Should(gexec.Exit(1))
+
+			var cniErr map[string]interface{}
+			Expect(json.Unmarshal(sess.Out.Contents(), &cniErr)).To(Succeed())
+			Expect(cniErr["code"]).To(BeEquivalentTo(7))
+			Expect(cniErr["msg"]).To(Equal("invalid container sysctls or routes"))
+			Expect(cniErr["details"]).To(Equal("sysctl net.ipv4.ip_forward is not allowed"))
+		})
+	})
+
+	Describe("CHECK", func() {
+		var checkStdin string
+
+		BeforeEach(func() {
+			cniStdin = cniConfig(dataDir, datastorePath, daemonPort)
+
+			sess := startCommandInHost("ADD", cniStdin)
+			Eventually(sess, cmdTimeout).Should(gexec.Exit(0))
+
+			var prevResult map[string]interface{}
+			Expect(json.Unmarshal(sess.Out.Contents(), &prevResult)).To(Succeed())
+			checkStdin = cniConfigWithExtras(dataDir, datastorePath, daemonPort, map[string]interface{}{
+				"prevResult": prevResult,
+			})
+		})
+
+		It("succeeds when the container network matches", func() {
+			sess := startCommandInHost("CHECK", checkStdin)
+			Eventually(sess, cmdTimeout).Should(gexec.Exit(0))
+		})
+
+		It("reports a missing ipam allocation", func() {
+			statePath := filepath.Join(dataDir, "ipam/my-silk-network/allocations.json")
+			Expect(os.WriteFile(statePath, []byte(`{"allocations": {}}`), 0600)).To(Succeed())
+
+			sess := startCommandInHost("CHECK", checkStdin)
+			Eventually(sess, cmdTimeout).Should(gexec.Exit(1))
+			Expect(sess.Out.Contents()).To(MatchJSON(`{
+				"code": 105,
+				"msg": "no ipam allocation for 10.255.30.2"
+			}`))
+		})
+
+		It("reports a container route that was removed", func() {
+			mustSucceedInContainer("ip", "route", "del", "default")
+
+			sess := startCommandInHost("CHECK", checkStdin)
+			Eventually(sess, cmdTimeout).Should(gexec.Exit(1))
+			Expect(sess.Out.Contents()).To(MatchJSON(`{
+				"code": 103,
+				"msg": "container device eth0 is missing route to 0.0.0.0/0"
+			}`))
+		})
+	})
+
+	Describe("Bandwidth", func() {
+		BeforeEach(func() {
+			cniStdin = cniConfigWithExtras(dataDir, datastorePath, daemonPort, map[string]interface{}{
+				"runtimeConfig": map[string]interface{}{
+					"bandwidth": map[string]interface{}{
+						"ingressRate":  8000000,
+						"ingressBurst": 800000,
+						"egressRate":   16000000,
+						"egressBurst":  1600000,
+					},
+				},
+			})
+		})
+
+		It("limits the traffic of the container and removes the limits on delete", func() {
+			sess := startCommandInHost("ADD", cniStdin)
+			Eventually(sess, cmdTimeout).Should(gexec.Exit(0))
+
+			By("checking the traffic to the container is limited on the host device")
+			Expect(mustSucceedInFakeHost("tc", "qdisc", "show", "dev", "s-010255030002")).To(ContainSubstring("qdisc tbf 1: root"))
+
+			By("checking the traffic from the container is limited on the ifb device")
+			Expect(mustSucceedInFakeHost("tc", "filter", "show", "dev", "s-010255030002", "parent", "ffff:")).To(ContainSubstring("i-010255030002"))
+			Expect(mustSucceedInFakeHost("tc", "qdisc", "show", "dev", "i-010255030002")).To(ContainSubstring("qdisc tbf 1: root"))
+
+			sess = startCommandInHost("DEL", cniStdin)
+			Eventually(sess, cmdTimeout).Should(gexec.Exit(0))
+
+			mustFailInHost("does not exist", "ip", "link", "list", "dev", "i-010255030002")
+		})
+
+		It("reads the limits from the metadata", func() {
+			cniStdin = cniConfigWithExtras(dataDir, datastorePath, daemonPort, map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"silk_egress_rate":  "16000000",
+					"silk_egress_burst": "1600000",
+				},
+			})
+
+			sess := startCommandInHost("ADD", cniStdin)
+			Eventually(sess, cmdTimeout).Should(gexec.Exit(0))
+
+			Expect(mustSucceedInFakeHost("tc", "qdisc", "show", "dev", "i-010255030002")).To(ContainSubstring("qdisc tbf 1: root"))
+			Expect(mustSucceedInFakeHost("tc", "qdisc", "show", "dev", "s-010255030002")).NotTo(ContainSubstring("tbf"))
+		})
+
+		It("rejects incomplete limits", func() {
+			cniStdin = cniConfigWithExtras(dataDir, datastorePath, daemonPort, map[string]interface{}{
+				"runtimeConfig": map[string]interface{}{
+					"bandwidth": map[string]interface{}{"ingressRate": 8000000},
+				},
+			})
+
+			sess := startCommandInHost("ADD", cniStdin)
+			Eventually(sess, cmdTimeout).Should(gexec.Exit(1))
+
+			var cniErr map[string]interface{}
+			Expect(json.Unmarshal(sess.Out.Contents(), &cniErr)).To(Succeed())
+			Expect(cniErr["code"]).To(BeEquivalentTo(7))
+			Expect(cniErr["msg"]).To(Equal("invalid bandwidth limits"))
+		})
+	})
+
+	Describe("GC", func() {
+		gcStdin := func(validAttachments ...map[string]string) string {
+			return cniConfigWithExtras(dataDir, datastorePath, daemonPort, map[string]interface{}{
+				"cniVersion":                "1.1.0",
+				"cni.dev/valid-attachments": validAttachments,
+			})
+		}
+
+		BeforeEach(func() {
+			cniStdin = cniConfig(dataDir, datastorePath, daemonPort)
+
+			sess := startCommandInHost("ADD", cniStdin)
+			Eventually(sess, cmdTimeout).Should(gexec.Exit(0))
+
+			By("moving the allocation out of its grace period")
+			statePath := filepath.Join(dataDir, "ipam/my-silk-network/allocations.json")
+			allocations := ipamAllocations(dataDir, "my-silk-network")
+			allocations["10.255.30.2"]["allocated_at"] = 0
+			state, err := json.Marshal(map[string]interface{}{"last_reserved": "10.255.30.2", "allocations": allocations})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.WriteFile(statePath, state, 0600)).To(Succeed())
+		})
+
+		It("keeps the valid attachments", func() {
+			sess := startCommandInHost("GC", gcStdin(map[string]string{"containerID": containerID, "ifname": "eth0"}))
+			Eventually(sess, cmdTimeout).Should(gexec.Exit(0))
+
+			Expect(ipamAllocations(dataDir, "my-silk-network")).To(HaveKey("10.255.30.2"))
+			containerMetadata, err := os.ReadFile(datastorePath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(containerMetadata)).To(ContainSubstring("10.255.30.2"))
+			mustSucceedInFakeHost("ip", "link", "list", "dev", "s-010255030002")
+		})
+
+		It("removes the allocation, the container metadata and the host device of other attachments", func() {
+			sess := startCommandInHost("GC", gcStdin())
+			Eventually(sess, cmdTimeout).Should(gexec.Exit(0))
+
+			Expect(ipamAllocations(dataDir, "my-silk-network")).NotTo(HaveKey("10.255.30.2"))
+			containerMetadata, err := os.ReadFile(datastorePath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(containerMetadata)).NotTo(ContainSubstring("10.255.30.2"))
+			mustFailInHost("does not exist", "ip", "link", "list", "dev", "s-010255030002")
+		})
+	})
+
+	Describe("STATUS", func() {
+		var statusStdin string
+
+		BeforeEach(func() {
+			statusStdin = cniConfigWithExtras(dataDir, datastorePath, daemonPort, map[string]interface{}{
+				"cniVersion": "1.1.0",
+			})
+		})
+
+		It("succeeds when silk-daemon is ready", func() {
+			sess := startCommandInHost("STATUS", statusStdin)
+			Eventually(sess, cmdTimeout).Should(gexec.Exit(0))
+			Expect(sess.Out.Contents()).To(BeEmpty())
+		})
+
+		It("reports that the plugin is not available when silk-daemon is not ready", func() {
+			fakeServer = startFakeDaemonInHost(daemonPort, http.StatusServiceUnavailable, `{"status": "not ready", "error": "no lease"}`)
+
+			sess := startCommandInHost("STATUS", statusStdin)
+			Eventually(sess, cmdTimeout).Should(gexec.Exit(1))
+
+			var cniErr map[string]interface{}
+			Expect(json.Unmarshal(sess.Out.Contents(), &cniErr)).To(Succeed())
+			Expect(cniErr["code"]).To(BeEquivalentTo(50))
+			Expect(cniErr["msg"]).To(Equal("silk-daemon is not ready"))
+			Expect(cniErr["details"]).To(ContainSubstring("503"))
+		})
+	})
+
 	Describe("Reserve all IPs", func() {
 		var (
 			containerNSList  []ns.NetNS
@@ -588,8 +954,8 @@ var _ = Describe("Silk CNI Integration", func() {
 			Eventually(sess, cmdTimeout).Should(gexec.Exit(1))
 			Expect(sess.Out.Contents()).To(MatchJSON(`{
 				"code": 100,
-				"msg": "run ipam plugin",
-				"details": "failed to allocate for range 0: no IP addresses available in range set: 10.255.30.1-10.255.30.6"
+				"msg": "allocate ip",
+				"details": "no ip addresses available in subnet 10.255.30.0/29"
 				}`))
 		})
 	})
@@ -638,6 +1004,17 @@ var _ = Describe("Silk CNI Integration", func() {
 	})
 })
 
+func ipamAllocations(dataDir, network string) map[string]map[string]interface{} {
+	bytes, err := os.ReadFile(filepath.Join(dataDir, "ipam", network, "allocations.json"))
+	Expect(err).NotTo(HaveOccurred())
+
+	var state struct {
+		Allocations map[string]map[string]interface{} `json:"allocations"`
+	}
+	Expect(json.Unmarshal(bytes, &state)).To(Succeed())
+	return state.Allocations
+}
+
 func writeSubnetEnvFile(subnet, fullNetwork string) string {
 	tempFile, err := os.CreateTemp("", "subnet.env")
 	Expect(err).NotTo(HaveOccurred())
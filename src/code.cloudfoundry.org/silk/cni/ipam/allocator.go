@@ -0,0 +1,373 @@
+package ipam
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/filelock"
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/silk/lib/datastore"
+	"code.cloudfoundry.org/silk/lib/serial"
+	current "github.com/containernetworking/cni/pkg/types/100"
+)
+
+const stateFileName = "allocations.json"
+
+//go:generate counterfeiter -o fakes/datastore_reader.go --fake-name DatastoreReader . datastoreReader
+type datastoreReader interface {
+	ReadAll(filePath string) (map[string]datastore.Container, error)
+}
+
+// Network identifies the IPAM state for one silk network. Allocations are
+// stored under DataDir/ipam/Name, next to any host-local state they replace.
+type Network struct {
+	Name      string
+	DataDir   string
+	Datastore string
+}
+
+// Networks returns the IPAM networks stored in dataDir for the silk network
+// with the given name, including the networks of its additional overlays.
+func Networks(dataDir, name string) ([]Network, error) {
+	entries, err := os.ReadDir(filepath.Join(dataDir, "ipam"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var networks []Network
+	for _, entry := range entries {
+		if entry.IsDir() && (entry.Name() == name || strings.HasPrefix(entry.Name(), name+"-")) {
+			networks = append(networks, Network{Name: entry.Name(), DataDir: dataDir})
+		}
+	}
+	return networks, nil
+}
+
+func (n Network) dir() string {
+	return filepath.Join(n.DataDir, "ipam", n.Name)
+}
+
+// Allocation is the container attachment that holds an IP.
+type Allocation struct {
+	ContainerID string `json:"container_id"`
+	IfName      string `json:"ifname"`
+	AllocatedAt int64  `json:"allocated_at"`
+}
+
+type pool struct {
+	LastReserved string                `json:"last_reserved"`
+	Allocations  map[string]Allocation `json:"allocations"`
+}
+
+// InvalidIPError is returned by Allocate when the requested IP is not a
+// container IP of the subnet.
+type InvalidIPError struct {
+	IP     net.IP
+	Reason string
+}
+
+func (e *InvalidIPError) Error() string {
+	return fmt.Sprintf("requested ip %s is %s", e.IP, e.Reason)
+}
+
+// UnavailableIPError is returned by Allocate when the requested IP is held
+// by another container, either in the IPAM state or in the container
+// datastore.
+type UnavailableIPError struct {
+	IP     net.IP
+	Reason string
+}
+
+func (e *UnavailableIPError) Error() string {
+	return fmt.Sprintf("requested ip %s is %s", e.IP, e.Reason)
+}
+
+// Allocator hands out container IPs from the overlay subnet of the cell.
+// IPs are handed out in order, starting after the one most recently
+// reserved, so that released IPs are not reused straight away.
+//
+// Allocations older than GracePeriod that have no entry in the container
+// datastore are garbage collected before each allocation.
+type Allocator struct {
+	Serializer  serial.Serializer
+	LockerNew   func(filePath string) filelock.FileLocker
+	Datastore   datastoreReader
+	GracePeriod time.Duration
+	Now         func() time.Time
+	Logger      lager.Logger
+}
+
+// Allocate reserves an IP in subnet for the container attachment. When
+// requested is set that IP is reserved, or an *InvalidIPError or
+// *UnavailableIPError is returned if it cannot be.
+func (a *Allocator) Allocate(network Network, subnet *net.IPNet, containerID, ifName string, requested net.IP) (*current.Result, error) {
+	first, last, gateway, err := addressRange(subnet)
+	if err != nil {
+		return nil, err
+	}
+
+	var result *current.Result
+	err = a.withPool(network, func(p *pool) error {
+		for ip, allocation := range p.Allocations {
+			if allocation.ContainerID == containerID && allocation.IfName == ifName && subnet.Contains(net.ParseIP(ip)) {
+				return fmt.Errorf("%s has been allocated to %s, duplicate allocation is not allowed", ip, containerID)
+			}
+		}
+
+		inUse, err := a.containerIPs(network)
+		if err != nil {
+			a.Logger.Error("read-datastore-for-gc-failed", err)
+			if requested != nil {
+				return fmt.Errorf("read container datastore: %s", err)
+			}
+		} else {
+			a.collectGarbage(network, p, inUse)
+		}
+
+		var ip net.IP
+		if requested != nil {
+			ip, err = reserveRequested(p, inUse, requested, subnet, first, last)
+		} else {
+			ip, err = reserveNext(p, subnet, first, last)
+		}
+		if err != nil {
+			return err
+		}
+
+		p.Allocations[ip.String()] = Allocation{
+			ContainerID: containerID,
+			IfName:      ifName,
+			AllocatedAt: a.Now().Unix(),
+		}
+		p.LastReserved = ip.String()
+
+		result = &current.Result{
+			CNIVersion: current.ImplementedSpecVersion,
+			IPs: []*current.IPConfig{{
+				Address: net.IPNet{IP: ip, Mask: subnet.Mask},
+				Gateway: gateway,
+			}},
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Release frees every IP held by the container attachment. Releasing an
+// attachment without allocations is not an error. Allocations migrated from
+// host-local without an interface name match any interface of the container.
+func (a *Allocator) Release(network Network, containerID, ifName string) error {
+	return a.withPool(network, func(p *pool) error {
+		for ip, allocation := range p.Allocations {
+			if allocation.ContainerID == containerID && (allocation.IfName == ifName || allocation.IfName == "") {
+				delete(p.Allocations, ip)
+			}
+		}
+		return nil
+	})
+}
+
+// Collect releases the allocations for which keep returns false and returns
+// the released and the kept allocations by IP. Allocations younger than
+// GracePeriod are always kept, as their container may still be being added.
+func (a *Allocator) Collect(network Network, keep func(Allocation) bool) (map[string]Allocation, map[string]Allocation, error) {
+	released := map[string]Allocation{}
+	kept := map[string]Allocation{}
+	err := a.withPool(network, func(p *pool) error {
+		cutoff := a.Now().Add(-a.GracePeriod).Unix()
+		for ip, allocation := range p.Allocations {
+			if keep(allocation) || allocation.AllocatedAt > cutoff {
+				kept[ip] = allocation
+				continue
+			}
+			delete(p.Allocations, ip)
+			released[ip] = allocation
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return released, kept, nil
+}
+
+// Lookup returns the allocation that holds ip, if any.
+func (a *Allocator) Lookup(network Network, ip net.IP) (Allocation, bool, error) {
+	var allocation Allocation
+	var found bool
+	err := a.withPool(network, func(p *pool) error {
+		allocation, found = p.Allocations[ip.String()]
+		return nil
+	})
+	return allocation, found, err
+}
+
+func (a *Allocator) withPool(network Network, update func(*pool) error) error {
+	dir := network.dir()
+	file, err := a.LockerNew(filepath.Join(dir, stateFileName)).Open()
+	if err != nil {
+		return fmt.Errorf("open lock: %s", err)
+	}
+	defer file.Close()
+
+	p := &pool{}
+	err = a.Serializer.DecodeAll(file, p)
+	if err != nil {
+		return fmt.Errorf("decoding file: %s", err)
+	}
+	if p.Allocations == nil {
+		p.Allocations = map[string]Allocation{}
+	}
+
+	migrated, hostLocalFiles, err := migrateHostLocal(dir, p)
+	if err != nil {
+		return fmt.Errorf("migrate host-local state: %s", err)
+	}
+	if len(hostLocalFiles) > 0 {
+		// The migrated pool is written before update runs, so that the
+		// migrated allocations are kept when update fails. The host-local
+		// files are only removed once the pool has been written.
+		err = a.Serializer.EncodeAndOverwrite(file, p)
+		if err != nil {
+			return fmt.Errorf("encode and overwrite: %s", err)
+		}
+		a.Logger.Info("migrated-host-local-allocations", lager.Data{"network": network.Name, "count": migrated})
+	}
+
+	err = update(p)
+	if err != nil {
+		return err
+	}
+
+	err = a.Serializer.EncodeAndOverwrite(file, p)
+	if err != nil {
+		return fmt.Errorf("encode and overwrite: %s", err)
+	}
+
+	err = removeFiles(hostLocalFiles)
+	if err != nil {
+		return fmt.Errorf("remove host-local state: %s", err)
+	}
+	return nil
+}
+
+// containerIPs returns the IPs of the containers in the datastore of the
+// network. It returns nil when the network has no datastore.
+func (a *Allocator) containerIPs(network Network) (map[string]bool, error) {
+	if network.Datastore == "" {
+		return nil, nil
+	}
+
+	containers, err := a.Datastore.ReadAll(network.Datastore)
+	if err != nil {
+		return nil, err
+	}
+
+	inUse := map[string]bool{}
+	for _, container := range containers {
+		inUse[container.IP] = true
+	}
+	return inUse, nil
+}
+
+func (a *Allocator) collectGarbage(network Network, p *pool, inUse map[string]bool) {
+	if inUse == nil {
+		return
+	}
+
+	cutoff := a.Now().Add(-a.GracePeriod).Unix()
+	for ip, allocation := range p.Allocations {
+		if inUse[ip] || allocation.AllocatedAt > cutoff {
+			continue
+		}
+		delete(p.Allocations, ip)
+		a.Logger.Info("released-orphaned-allocation", lager.Data{"network": network.Name, "ip": ip, "container_id": allocation.ContainerID})
+	}
+}
+
+func reserveRequested(p *pool, inUse map[string]bool, requested net.IP, subnet *net.IPNet, first, last net.IP) (net.IP, error) {
+	ip := requested.To4()
+	if ip == nil || !subnet.Contains(ip) {
+		return nil, &InvalidIPError{IP: requested, Reason: fmt.Sprintf("not in subnet %s", subnet)}
+	}
+	if compare(ip, first) < 0 || compare(ip, last) > 0 {
+		return nil, &InvalidIPError{IP: requested, Reason: fmt.Sprintf("reserved in subnet %s", subnet)}
+	}
+	if _, taken := p.Allocations[ip.String()]; taken {
+		return nil, &UnavailableIPError{IP: requested, Reason: "already allocated"}
+	}
+	if inUse[ip.String()] {
+		return nil, &UnavailableIPError{IP: requested, Reason: "held by a container in the datastore"}
+	}
+	return ip, nil
+}
+
+func reserveNext(p *pool, subnet *net.IPNet, first, last net.IP) (net.IP, error) {
+	start := first
+	if lastReserved := net.ParseIP(p.LastReserved).To4(); lastReserved != nil && compare(lastReserved, first) >= 0 && compare(lastReserved, last) < 0 {
+		start = add(lastReserved, 1)
+	}
+
+	ip := start
+	for {
+		if _, taken := p.Allocations[ip.String()]; !taken {
+			return ip, nil
+		}
+		if ip.Equal(last) {
+			ip = first
+		} else {
+			ip = add(ip, 1)
+		}
+		if ip.Equal(start) {
+			return nil, fmt.Errorf("no ip addresses available in subnet %s", subnet)
+		}
+	}
+}
+
+// addressRange returns the first and last allocatable IPs in subnet and the
+// gateway. As with host-local, the network and broadcast addresses and the
+// gateway, the first IP in the subnet, are never handed out.
+func addressRange(subnet *net.IPNet) (net.IP, net.IP, net.IP, error) {
+	network := subnet.IP.Mask(subnet.Mask).To4()
+	ones, bits := subnet.Mask.Size()
+	if network == nil || bits != 32 {
+		return nil, nil, nil, fmt.Errorf("invalid subnet: %s is not an ipv4 subnet", subnet)
+	}
+	if bits-ones < 2 {
+		return nil, nil, nil, fmt.Errorf("invalid subnet: %s is too small", subnet)
+	}
+
+	size := int64(1) << uint(bits-ones)
+	gateway := add(network, 1)
+	first := add(network, 2)
+	last := add(network, size-2)
+	if compare(first, last) > 0 {
+		return nil, nil, nil, fmt.Errorf("no ip addresses available in subnet %s", subnet)
+	}
+	return first, last, gateway, nil
+}
+
+func add(ip net.IP, n int64) net.IP {
+	i := new(big.Int).SetBytes(ip.To4())
+	i.Add(i, big.NewInt(n))
+	b := i.Bytes()
+	out := make(net.IP, net.IPv4len)
+	copy(out[net.IPv4len-len(b):], b)
+	return out
+}
+
+func compare(a, b net.IP) int {
+	return new(big.Int).SetBytes(a.To4()).Cmp(new(big.Int).SetBytes(b.To4()))
+}
@@ -0,0 +1,312 @@
+package ipam_test
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/filelock"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/silk/cni/ipam"
+	"code.cloudfoundry.org/silk/cni/ipam/fakes"
+	"code.cloudfoundry.org/silk/lib/datastore"
+	"code.cloudfoundry.org/silk/lib/serial"
+	"github.com/onsi/gomega/gbytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Allocator", func() {
+	var (
+		allocator     *ipam.Allocator
+		datastoreFake *fakes.DatastoreReader
+		network       ipam.Network
+		subnet        *net.IPNet
+		now           time.Time
+		logger        *lagertest.TestLogger
+	)
+
+	BeforeEach(func() {
+		dataDir, err := os.MkdirTemp("", "ipam")
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(os.RemoveAll, dataDir)
+
+		datastoreFake = &fakes.DatastoreReader{}
+		logger = lagertest.NewTestLogger("test")
+		now = time.Unix(1000000, 0)
+		allocator = &ipam.Allocator{
+			Serializer:  &serial.Serial{},
+			LockerNew:   filelock.NewLocker,
+			Datastore:   datastoreFake,
+			GracePeriod: time.Minute,
+			Now:         func() time.Time { return now },
+			Logger:      logger,
+		}
+		network = ipam.Network{
+			Name:      "my-network",
+			DataDir:   dataDir,
+			Datastore: "/some/datastore.json",
+		}
+		_, subnet, err = net.ParseCIDR("10.255.30.0/29")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	allocate := func(containerID string) net.IP {
+		result, err := allocator.Allocate(network, subnet, containerID, "eth0", nil)
+		Expect(err).NotTo(HaveOccurred())
+		return result.IPs[0].Address.IP
+	}
+
+	Describe("Allocate", func() {
+		It("allocates the first ip after the gateway", func() {
+			result, err := allocator.Allocate(network, subnet, "some-container", "eth0", nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.IPs).To(HaveLen(1))
+			Expect(result.IPs[0].Address.String()).To(Equal("10.255.30.2/29"))
+			Expect(result.IPs[0].Gateway.String()).To(Equal("10.255.30.1"))
+
+			allocation, found, err := allocator.Lookup(network, net.ParseIP("10.255.30.2"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(allocation).To(Equal(ipam.Allocation{
+				ContainerID: "some-container",
+				IfName:      "eth0",
+				AllocatedAt: now.Unix(),
+			}))
+		})
+
+		It("allocates in order and does not reuse released ips straight away", func() {
+			Expect(allocate("container-1").String()).To(Equal("10.255.30.2"))
+			Expect(allocate("container-2").String()).To(Equal("10.255.30.3"))
+			Expect(allocator.Release(network, "container-1", "eth0")).To(Succeed())
+			Expect(allocate("container-3").String()).To(Equal("10.255.30.4"))
+		})
+
+		It("wraps around to released ips at the end of the subnet", func() {
+			for i := 0; i < 5; i++ {
+				allocate("container")
+				Expect(allocator.Release(network, "container", "eth0")).To(Succeed())
+			}
+			Expect(allocate("container").String()).To(Equal("10.255.30.2"))
+		})
+
+		It("stores allocations per network", func() {
+			Expect(allocate("container-1").String()).To(Equal("10.255.30.2"))
+			network.Name = "my-network-isolated"
+			Expect(allocate("container-2").String()).To(Equal("10.255.30.2"))
+			Expect(filepath.Join(network.DataDir, "ipam", "my-network", "allocations.json")).To(BeAnExistingFile())
+			Expect(filepath.Join(network.DataDir, "ipam", "my-network-isolated", "allocations.json")).To(BeAnExistingFile())
+		})
+
+		Context("when the attachment already has an ip", func() {
+			It("returns an error", func() {
+				allocate("some-container")
+				_, err := allocator.Allocate(network, subnet, "some-container", "eth0", nil)
+				Expect(err).To(MatchError("10.255.30.2 has been allocated to some-container, duplicate allocation is not allowed"))
+			})
+		})
+
+		Context("when the subnet is exhausted", func() {
+			It("returns an error", func() {
+				for _, id := range []string{"a", "b", "c", "d", "e"} {
+					allocate(id)
+				}
+				datastoreFake.ReadAllReturns(map[string]datastore.Container{
+					"a": {IP: "10.255.30.2"}, "b": {IP: "10.255.30.3"}, "c": {IP: "10.255.30.4"},
+					"d": {IP: "10.255.30.5"}, "e": {IP: "10.255.30.6"},
+				}, nil)
+
+				_, err := allocator.Allocate(network, subnet, "f", "eth0", nil)
+				Expect(err).To(MatchError("no ip addresses available in subnet 10.255.30.0/29"))
+			})
+		})
+
+		Context("when the subnet has no allocatable ips", func() {
+			It("returns an error", func() {
+				_, subnet, _ = net.ParseCIDR("10.255.30.0/31")
+				_, err := allocator.Allocate(network, subnet, "some-container", "eth0", nil)
+				Expect(err).To(MatchError("invalid subnet: 10.255.30.0/31 is too small"))
+			})
+		})
+
+		Context("when an ip is requested", func() {
+			It("allocates that ip", func() {
+				result, err := allocator.Allocate(network, subnet, "some-container", "eth0", net.ParseIP("10.255.30.5"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.IPs[0].Address.String()).To(Equal("10.255.30.5/29"))
+			})
+
+			It("errors when the ip is outside the subnet", func() {
+				_, err := allocator.Allocate(network, subnet, "some-container", "eth0", net.ParseIP("10.255.31.5"))
+				Expect(err).To(MatchError("requested ip 10.255.31.5 is not in subnet 10.255.30.0/29"))
+			})
+
+			It("errors when the ip is the gateway", func() {
+				_, err := allocator.Allocate(network, subnet, "some-container", "eth0", net.ParseIP("10.255.30.1"))
+				Expect(err).To(MatchError("requested ip 10.255.30.1 is reserved in subnet 10.255.30.0/29"))
+			})
+
+			It("returns an InvalidIPError when the ip is outside the subnet", func() {
+				_, err := allocator.Allocate(network, subnet, "some-container", "eth0", net.ParseIP("10.255.31.5"))
+				Expect(err).To(BeAssignableToTypeOf(&ipam.InvalidIPError{}))
+			})
+
+			It("errors when the ip is already allocated", func() {
+				allocate("other-container")
+				_, err := allocator.Allocate(network, subnet, "some-container", "eth0", net.ParseIP("10.255.30.2"))
+				Expect(err).To(MatchError("requested ip 10.255.30.2 is already allocated"))
+				Expect(err).To(BeAssignableToTypeOf(&ipam.UnavailableIPError{}))
+			})
+
+			It("errors when the ip is held by a container in the datastore", func() {
+				datastoreFake.ReadAllReturns(map[string]datastore.Container{
+					"other-handle": {Handle: "other-handle", IP: "10.255.30.5"},
+				}, nil)
+
+				_, err := allocator.Allocate(network, subnet, "some-container", "eth0", net.ParseIP("10.255.30.5"))
+				Expect(err).To(MatchError("requested ip 10.255.30.5 is held by a container in the datastore"))
+				Expect(err).To(BeAssignableToTypeOf(&ipam.UnavailableIPError{}))
+				Expect(datastoreFake.ReadAllArgsForCall(0)).To(Equal("/some/datastore.json"))
+			})
+
+			It("errors when the datastore cannot be read", func() {
+				datastoreFake.ReadAllReturns(nil, errors.New("banana"))
+
+				_, err := allocator.Allocate(network, subnet, "some-container", "eth0", net.ParseIP("10.255.30.5"))
+				Expect(err).To(MatchError("read container datastore: banana"))
+			})
+		})
+
+		Describe("garbage collection", func() {
+			BeforeEach(func() {
+				allocate("orphaned-container")
+				allocate("running-container")
+				datastoreFake.ReadAllReturns(map[string]datastore.Container{
+					"running-handle": {Handle: "running-handle", IP: "10.255.30.3"},
+				}, nil)
+			})
+
+			It("keeps allocations without a datastore entry within the grace period", func() {
+				allocate("new-container")
+
+				_, found, err := allocator.Lookup(network, net.ParseIP("10.255.30.2"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(found).To(BeTrue())
+			})
+
+			It("releases allocations without a datastore entry after the grace period", func() {
+				now = now.Add(2 * time.Minute)
+				allocate("new-container")
+
+				Expect(datastoreFake.ReadAllArgsForCall(datastoreFake.ReadAllCallCount() - 1)).To(Equal("/some/datastore.json"))
+
+				_, found, err := allocator.Lookup(network, net.ParseIP("10.255.30.2"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(found).To(BeFalse())
+
+				_, found, err = allocator.Lookup(network, net.ParseIP("10.255.30.3"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(found).To(BeTrue())
+			})
+
+			Context("when the datastore cannot be read", func() {
+				BeforeEach(func() {
+					datastoreFake.ReadAllReturns(nil, errors.New("banana"))
+				})
+
+				It("logs the error and keeps all allocations", func() {
+					now = now.Add(2 * time.Minute)
+					allocate("new-container")
+
+					Expect(logger).To(gbytes.Say("read-datastore-for-gc-failed"))
+					_, found, err := allocator.Lookup(network, net.ParseIP("10.255.30.2"))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(found).To(BeTrue())
+				})
+			})
+		})
+	})
+
+	Describe("Release", func() {
+		It("releases only the ips of the attachment", func() {
+			allocate("container-1")
+			allocate("container-2")
+
+			Expect(allocator.Release(network, "container-1", "eth0")).To(Succeed())
+
+			_, found, err := allocator.Lookup(network, net.ParseIP("10.255.30.2"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeFalse())
+			_, found, err = allocator.Lookup(network, net.ParseIP("10.255.30.3"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeTrue())
+		})
+
+		It("succeeds when nothing is allocated", func() {
+			Expect(allocator.Release(network, "some-container", "eth0")).To(Succeed())
+		})
+	})
+
+	Describe("Collect", func() {
+		BeforeEach(func() {
+			network.Datastore = ""
+			allocate("valid-container")
+			allocate("invalid-container")
+			now = now.Add(2 * time.Minute)
+			allocate("new-container")
+		})
+
+		It("releases the allocations that are not kept once their grace period is over", func() {
+			released, kept, err := allocator.Collect(network, func(allocation ipam.Allocation) bool {
+				return allocation.ContainerID == "valid-container"
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(released).To(HaveLen(1))
+			Expect(released["10.255.30.3"].ContainerID).To(Equal("invalid-container"))
+			Expect(kept).To(HaveLen(2))
+			Expect(kept).To(HaveKey("10.255.30.2"))
+			Expect(kept).To(HaveKey("10.255.30.4"))
+
+			_, found, err := allocator.Lookup(network, net.ParseIP("10.255.30.3"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+	})
+
+	Describe("Networks", func() {
+		It("returns the network and its overlay networks", func() {
+			allocate("container-1")
+			network.Name = "my-network-isolated"
+			allocate("container-2")
+			network.Name = "other-network"
+			allocate("container-3")
+
+			networks, err := ipam.Networks(network.DataDir, "my-network")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(networks).To(ConsistOf(
+				ipam.Network{Name: "my-network", DataDir: network.DataDir},
+				ipam.Network{Name: "my-network-isolated", DataDir: network.DataDir},
+			))
+		})
+
+		It("returns no networks when nothing was allocated yet", func() {
+			networks, err := ipam.Networks(network.DataDir, "my-network")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(networks).To(BeEmpty())
+		})
+	})
+
+	Context("when the state file is corrupt", func() {
+		It("returns an error", func() {
+			dir := filepath.Join(network.DataDir, "ipam", "my-network")
+			Expect(os.MkdirAll(dir, 0700)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(dir, "allocations.json"), []byte("}{"), 0600)).To(Succeed())
+
+			_, err := allocator.Allocate(network, subnet, "some-container", "eth0", nil)
+			Expect(err).To(MatchError(HavePrefix("decoding file:")))
+		})
+	})
+})
@@ -0,0 +1,114 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/silk/lib/datastore"
+)
+
+type DatastoreReader struct {
+	ReadAllStub        func(string) (map[string]datastore.Container, error)
+	readAllMutex       sync.RWMutex
+	readAllArgsForCall []struct {
+		arg1 string
+	}
+	readAllReturns struct {
+		result1 map[string]datastore.Container
+		result2 error
+	}
+	readAllReturnsOnCall map[int]struct {
+		result1 map[string]datastore.Container
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *DatastoreReader) ReadAll(arg1 string) (map[string]datastore.Container, error) {
+	fake.readAllMutex.Lock()
+	ret, specificReturn := fake.readAllReturnsOnCall[len(fake.readAllArgsForCall)]
+	fake.readAllArgsForCall = append(fake.readAllArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.ReadAllStub
+	fakeReturns := fake.readAllReturns
+	fake.recordInvocation("ReadAll", []interface{}{arg1})
+	fake.readAllMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *DatastoreReader) ReadAllCallCount() int {
+	fake.readAllMutex.RLock()
+	defer fake.readAllMutex.RUnlock()
+	return len(fake.readAllArgsForCall)
+}
+
+func (fake *DatastoreReader) ReadAllCalls(stub func(string) (map[string]datastore.Container, error)) {
+	fake.readAllMutex.Lock()
+	defer fake.readAllMutex.Unlock()
+	fake.ReadAllStub = stub
+}
+
+func (fake *DatastoreReader) ReadAllArgsForCall(i int) string {
+	fake.readAllMutex.RLock()
+	defer fake.readAllMutex.RUnlock()
+	argsForCall := fake.readAllArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *DatastoreReader) ReadAllReturns(result1 map[string]datastore.Container, result2 error) {
+	fake.readAllMutex.Lock()
+	defer fake.readAllMutex.Unlock()
+	fake.ReadAllStub = nil
+	fake.readAllReturns = struct {
+		result1 map[string]datastore.Container
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *DatastoreReader) ReadAllReturnsOnCall(i int, result1 map[string]datastore.Container, result2 error) {
+	fake.readAllMutex.Lock()
+	defer fake.readAllMutex.Unlock()
+	fake.ReadAllStub = nil
+	if fake.readAllReturnsOnCall == nil {
+		fake.readAllReturnsOnCall = make(map[int]struct {
+			result1 map[string]datastore.Container
+			result2 error
+		})
+	}
+	fake.readAllReturnsOnCall[i] = struct {
+		result1 map[string]datastore.Container
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *DatastoreReader) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.readAllMutex.RLock()
+	defer fake.readAllMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *DatastoreReader) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
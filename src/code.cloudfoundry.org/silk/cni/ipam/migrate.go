@@ -0,0 +1,80 @@
+package ipam
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const hostLocalLastReservedPrefix = "last_reserved_ip."
+
+// migrateHostLocal copies the per-IP reservation files written by the
+// host-local plugin in dir into p, so that containers created before the
+// upgrade keep their IPs. It returns the files it read, which the caller
+// removes once p has been written.
+func migrateHostLocal(dir string, p *pool) (int, []string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	migrated := 0
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		path := filepath.Join(dir, name)
+
+		if strings.HasPrefix(name, hostLocalLastReservedPrefix) {
+			contents, err := os.ReadFile(path)
+			if err != nil {
+				return migrated, files, err
+			}
+			if p.LastReserved == "" {
+				p.LastReserved = strings.TrimSpace(string(contents))
+			}
+			files = append(files, path)
+			continue
+		}
+
+		ip := net.ParseIP(name)
+		if ip == nil || entry.IsDir() {
+			continue
+		}
+
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return migrated, files, err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return migrated, files, err
+		}
+
+		// host-local writes the container id and, since CNI 0.7, the interface name
+		lines := strings.Split(strings.ReplaceAll(string(contents), "\r\n", "\n"), "\n")
+		allocation := Allocation{
+			ContainerID: strings.TrimSpace(lines[0]),
+			AllocatedAt: info.ModTime().Unix(),
+		}
+		if len(lines) > 1 {
+			allocation.IfName = strings.TrimSpace(lines[1])
+		}
+		if _, exists := p.Allocations[ip.String()]; !exists {
+			p.Allocations[ip.String()] = allocation
+			migrated++
+		}
+		files = append(files, path)
+	}
+
+	return migrated, files, nil
+}
+
+func removeFiles(files []string) error {
+	for _, file := range files {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package ipam_test
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/filelock"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/silk/cni/ipam"
+	"code.cloudfoundry.org/silk/cni/ipam/fakes"
+	"code.cloudfoundry.org/silk/lib/serial"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("host-local migration", func() {
+	var (
+		allocator *ipam.Allocator
+		network   ipam.Network
+		dir       string
+		modTime   time.Time
+	)
+
+	BeforeEach(func() {
+		dataDir, err := os.MkdirTemp("", "ipam")
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(os.RemoveAll, dataDir)
+
+		allocator = &ipam.Allocator{
+			Serializer:  &serial.Serial{},
+			LockerNew:   filelock.NewLocker,
+			Datastore:   &fakes.DatastoreReader{},
+			GracePeriod: time.Minute,
+			Now:         time.Now,
+			Logger:      lagertest.NewTestLogger("test"),
+		}
+		network = ipam.Network{Name: "my-network", DataDir: dataDir}
+
+		dir = filepath.Join(dataDir, "ipam", "my-network")
+		Expect(os.MkdirAll(dir, 0700)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "10.255.30.4"), []byte("container-1\r\neth0"), 0600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "10.255.30.7"), []byte("container-2"), 0600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "last_reserved_ip.0"), []byte("10.255.30.7"), 0600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "lock"), []byte{}, 0600)).To(Succeed())
+
+		modTime = time.Unix(1000000, 0)
+		Expect(os.Chtimes(filepath.Join(dir, "10.255.30.4"), modTime, modTime)).To(Succeed())
+	})
+
+	It("imports the host-local reservations and removes their files", func() {
+		allocation, found, err := allocator.Lookup(network, net.ParseIP("10.255.30.4"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found).To(BeTrue())
+		Expect(allocation).To(Equal(ipam.Allocation{
+			ContainerID: "container-1",
+			IfName:      "eth0",
+			AllocatedAt: modTime.Unix(),
+		}))
+
+		allocation, found, err = allocator.Lookup(network, net.ParseIP("10.255.30.7"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found).To(BeTrue())
+		Expect(allocation.ContainerID).To(Equal("container-2"))
+		Expect(allocation.IfName).To(BeEmpty())
+
+		Expect(filepath.Join(dir, "10.255.30.4")).NotTo(BeAnExistingFile())
+		Expect(filepath.Join(dir, "10.255.30.7")).NotTo(BeAnExistingFile())
+		Expect(filepath.Join(dir, "last_reserved_ip.0")).NotTo(BeAnExistingFile())
+		Expect(filepath.Join(dir, "lock")).To(BeAnExistingFile())
+	})
+
+	It("continues allocating after the last host-local reservation", func() {
+		_, subnet, _ := net.ParseCIDR("10.255.30.0/24")
+		result, err := allocator.Allocate(network, subnet, "container-3", "eth0", nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.IPs[0].Address.IP.String()).To(Equal("10.255.30.8"))
+	})
+
+	Context("when the update fails", func() {
+		It("keeps the host-local files and the migrated allocations", func() {
+			_, subnet, _ := net.ParseCIDR("10.255.30.0/24")
+			_, err := allocator.Allocate(network, subnet, "container-1", "eth0", nil)
+			Expect(err).To(MatchError("10.255.30.4 has been allocated to container-1, duplicate allocation is not allowed"))
+
+			Expect(filepath.Join(dir, "10.255.30.4")).To(BeAnExistingFile())
+			Expect(filepath.Join(dir, "10.255.30.7")).To(BeAnExistingFile())
+			Expect(filepath.Join(dir, "last_reserved_ip.0")).To(BeAnExistingFile())
+
+			By("reading the pool without migrating again")
+			Expect(os.RemoveAll(filepath.Join(dir, "10.255.30.4"))).To(Succeed())
+			Expect(os.RemoveAll(filepath.Join(dir, "10.255.30.7"))).To(Succeed())
+			Expect(os.RemoveAll(filepath.Join(dir, "last_reserved_ip.0"))).To(Succeed())
+
+			allocation, found, err := allocator.Lookup(network, net.ParseIP("10.255.30.4"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(allocation.ContainerID).To(Equal("container-1"))
+
+			_, found, err = allocator.Lookup(network, net.ParseIP("10.255.30.7"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeTrue())
+		})
+	})
+
+	It("lets host-local containers release their ips", func() {
+		Expect(allocator.Release(network, "container-1", "eth0")).To(Succeed())
+
+		_, found, err := allocator.Lookup(network, net.ParseIP("10.255.30.4"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found).To(BeFalse())
+
+		By("matching reservations that were written without an interface name")
+		Expect(allocator.Release(network, "container-2", "eth0")).To(Succeed())
+
+		_, found, err = allocator.Lookup(network, net.ParseIP("10.255.30.7"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found).To(BeFalse())
+	})
+})
@@ -0,0 +1,34 @@
+package lib
+
+import (
+	"fmt"
+	"net"
+
+	"code.cloudfoundry.org/silk/cni/ipam"
+)
+
+//go:generate counterfeiter -o fakes/allocationLookup.go --fake-name AllocationLookup . allocationLookup
+type allocationLookup interface {
+	Lookup(network ipam.Network, ip net.IP) (ipam.Allocation, bool, error)
+}
+
+// AllocationChecker confirms that IPAM still has the container IP reserved
+// for the container.
+type AllocationChecker struct {
+	IPAM allocationLookup
+}
+
+func (a *AllocationChecker) Check(network ipam.Network, containerID string, ip net.IP) error {
+	allocation, found, err := a.IPAM.Lookup(network, ip)
+	if err != nil {
+		return drift(ErrAllocationDrift, fmt.Sprintf("read ipam allocation for %s", ip), err.Error())
+	}
+	if !found {
+		return drift(ErrAllocationDrift, fmt.Sprintf("no ipam allocation for %s", ip), "")
+	}
+
+	if allocation.ContainerID != containerID {
+		return drift(ErrAllocationDrift, fmt.Sprintf("ipam allocation for %s belongs to another container", ip), allocation.ContainerID)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package lib_test
+
+import (
+	"errors"
+	"net"
+
+	"code.cloudfoundry.org/silk/cni/ipam"
+	"code.cloudfoundry.org/silk/cni/lib"
+	"code.cloudfoundry.org/silk/cni/lib/fakes"
+	"github.com/containernetworking/cni/pkg/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AllocationChecker", func() {
+	var (
+		allocations *fakes.AllocationLookup
+		network     ipam.Network
+		checker     *lib.AllocationChecker
+	)
+
+	BeforeEach(func() {
+		allocations = &fakes.AllocationLookup{}
+		allocations.LookupReturns(ipam.Allocation{ContainerID: "some-container-id", IfName: "eth0"}, true, nil)
+		network = ipam.Network{Name: "my-network", DataDir: "/some/data/dir"}
+
+		checker = &lib.AllocationChecker{IPAM: allocations}
+	})
+
+	It("succeeds when the ip is reserved for the container", func() {
+		Expect(checker.Check(network, "some-container-id", net.ParseIP("10.255.30.4"))).To(Succeed())
+
+		Expect(allocations.LookupCallCount()).To(Equal(1))
+		lookupNetwork, ip := allocations.LookupArgsForCall(0)
+		Expect(lookupNetwork).To(Equal(network))
+		Expect(ip.String()).To(Equal("10.255.30.4"))
+	})
+
+	It("returns an allocation drift error when the ip is not reserved", func() {
+		allocations.LookupReturns(ipam.Allocation{}, false, nil)
+
+		err := checker.Check(network, "some-container-id", net.ParseIP("10.255.30.5"))
+		Expect(err).To(BeAssignableToTypeOf(&types.Error{}))
+		Expect(err.(*types.Error).Code).To(Equal(lib.ErrAllocationDrift))
+		Expect(err.(*types.Error).Msg).To(Equal("no ipam allocation for 10.255.30.5"))
+	})
+
+	It("returns an allocation drift error when the ip is reserved for another container", func() {
+		err := checker.Check(network, "other-container-id", net.ParseIP("10.255.30.4"))
+		Expect(err).To(BeAssignableToTypeOf(&types.Error{}))
+		Expect(err.(*types.Error).Code).To(Equal(lib.ErrAllocationDrift))
+		Expect(err.(*types.Error).Details).To(Equal("some-container-id"))
+	})
+
+	It("returns an allocation drift error when the allocations cannot be read", func() {
+		allocations.LookupReturns(ipam.Allocation{}, false, errors.New("banana"))
+
+		err := checker.Check(network, "some-container-id", net.ParseIP("10.255.30.4"))
+		Expect(err).To(BeAssignableToTypeOf(&types.Error{}))
+		Expect(err.(*types.Error).Msg).To(Equal("read ipam allocation for 10.255.30.4"))
+		Expect(err.(*types.Error).Details).To(Equal("banana"))
+	})
+})
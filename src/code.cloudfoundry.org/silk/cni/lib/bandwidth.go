@@ -0,0 +1,155 @@
+package lib
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/silk/cni/config"
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/vishvananda/netlink"
+)
+
+// latency of the token bucket filters, the same as the CNI bandwidth plugin
+const tbfLatencyInMillis = 25
+
+// Bandwidth limits the traffic of a container on the host side of its veth
+// pair. Traffic to the container is shaped by a TBF qdisc on the host device.
+// Traffic from the container is redirected from the ingress of the host
+// device to an IFB device and shaped by a TBF qdisc there.
+type Bandwidth struct {
+	NetlinkAdapter netlinkAdapter
+	LinkOperations linkOperations
+	Logger         lager.Logger
+}
+
+// Setup applies the bandwidth limits of the config. Host.Setup must have
+// been called first.
+func (b *Bandwidth) Setup(cfg *config.Config) error {
+	b.Logger.Debug("start")
+	defer b.Logger.Debug("done")
+
+	limits := cfg.Bandwidth
+	if limits.IngressRate == 0 && limits.EgressRate == 0 {
+		return nil
+	}
+
+	return cfg.Host.Namespace.Do(func(_ ns.NetNS) error {
+		hostLink, err := b.NetlinkAdapter.LinkByName(cfg.Host.DeviceName)
+		if err != nil {
+			return fmt.Errorf("failed to find link %q: %s", cfg.Host.DeviceName, err)
+		}
+
+		if limits.IngressRate > 0 {
+			if err := b.createTBF(limits.IngressRate, limits.IngressBurst, hostLink.Attrs().Index); err != nil {
+				return fmt.Errorf("limiting ingress on %s: %s", cfg.Host.DeviceName, err)
+			}
+		}
+
+		if limits.EgressRate > 0 {
+			ifbLink, err := b.createIFB(cfg.Host.IFBDeviceName, hostLink.Attrs().MTU)
+			if err != nil {
+				return fmt.Errorf("creating ifb device %s: %s", cfg.Host.IFBDeviceName, err)
+			}
+			if err := b.redirectIngress(hostLink.Attrs().Index, ifbLink.Attrs().Index); err != nil {
+				return fmt.Errorf("redirecting egress of %s to %s: %s", cfg.Host.DeviceName, cfg.Host.IFBDeviceName, err)
+			}
+			if err := b.createTBF(limits.EgressRate, limits.EgressBurst, ifbLink.Attrs().Index); err != nil {
+				return fmt.Errorf("limiting egress on %s: %s", cfg.Host.IFBDeviceName, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// Teardown deletes the IFB device of a container. The qdiscs on the host
+// device are removed together with the veth pair.
+func (b *Bandwidth) Teardown(hostNS ns.NetNS, ifbDeviceName string) error {
+	b.Logger.Debug("start")
+	defer b.Logger.Debug("done")
+	return hostNS.Do(func(_ ns.NetNS) error {
+		if err := b.LinkOperations.DeleteLinkByName(ifbDeviceName); err != nil {
+			return fmt.Errorf("deleting ifb device: %s", err)
+		}
+		return nil
+	})
+}
+
+func (b *Bandwidth) createIFB(name string, mtu int) (netlink.Link, error) {
+	err := b.NetlinkAdapter.LinkAdd(&netlink.Ifb{
+		LinkAttrs: netlink.LinkAttrs{
+			Name:  name,
+			Flags: net.FlagUp,
+			MTU:   mtu,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	link, err := b.NetlinkAdapter.LinkByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.NetlinkAdapter.LinkSetUp(link); err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+func (b *Bandwidth) redirectIngress(linkIndex, ifbIndex int) error {
+	err := b.NetlinkAdapter.QdiscAdd(&netlink.Ingress{
+		QdiscAttrs: netlink.QdiscAttrs{
+			LinkIndex: linkIndex,
+			Handle:    netlink.MakeHandle(0xffff, 0),
+			Parent:    netlink.HANDLE_INGRESS,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("adding ingress qdisc: %s", err)
+	}
+
+	err = b.NetlinkAdapter.FilterAdd(&netlink.U32{
+		FilterAttrs: netlink.FilterAttrs{
+			LinkIndex: linkIndex,
+			Parent:    netlink.MakeHandle(0xffff, 0),
+			Priority:  1,
+			Protocol:  syscall.ETH_P_ALL,
+		},
+		ClassId: netlink.MakeHandle(1, 1),
+		Actions: []netlink.Action{netlink.NewMirredAction(ifbIndex)},
+	})
+	if err != nil {
+		return fmt.Errorf("adding redirect filter: %s", err)
+	}
+	return nil
+}
+
+func (b *Bandwidth) createTBF(rateInBits, burstInBits uint64, linkIndex int) error {
+	rateInBytes := rateInBits / 8
+	burstInBytes := burstInBits / 8
+	if rateInBytes == 0 || burstInBytes == 0 {
+		return fmt.Errorf("rate and burst must be at least 8 bits")
+	}
+
+	bufferInBytes := b.time2Tick(uint32(float64(burstInBytes) * float64(netlink.TIME_UNITS_PER_SEC) / float64(rateInBytes)))
+	latency := float64(netlink.TIME_UNITS_PER_SEC) * (tbfLatencyInMillis / 1000.0)
+	limitInBytes := uint32(float64(rateInBytes)*latency/float64(netlink.TIME_UNITS_PER_SEC)) + bufferInBytes
+
+	return b.NetlinkAdapter.QdiscAdd(&netlink.Tbf{
+		QdiscAttrs: netlink.QdiscAttrs{
+			LinkIndex: linkIndex,
+			Handle:    netlink.MakeHandle(1, 0),
+			Parent:    netlink.HANDLE_ROOT,
+		},
+		Limit:  limitInBytes,
+		Rate:   rateInBytes,
+		Buffer: bufferInBytes,
+	})
+}
+
+func (b *Bandwidth) time2Tick(time uint32) uint32 {
+	return uint32(float64(time) * b.NetlinkAdapter.TickInUsec())
+}
@@ -0,0 +1,209 @@
+package lib_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/silk/cni/config"
+	"code.cloudfoundry.org/silk/cni/lib"
+	"code.cloudfoundry.org/silk/cni/lib/fakes"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/vishvananda/netlink"
+)
+
+var _ = Describe("Bandwidth", func() {
+	var (
+		hostNS             *fakes.NetNS
+		cfg                *config.Config
+		fakeNetlinkAdapter *fakes.NetlinkAdapter
+		fakeLinkOperations *fakes.LinkOperations
+		hostLink           *netlink.Veth
+		ifbLink            *netlink.Ifb
+		bandwidth          *lib.Bandwidth
+	)
+
+	BeforeEach(func() {
+		hostNS = &fakes.NetNS{}
+		hostNS.DoStub = lib.NetNsDoStub
+		fakeNetlinkAdapter = &fakes.NetlinkAdapter{}
+		fakeLinkOperations = &fakes.LinkOperations{}
+
+		hostLink = &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "s-010255030004", Index: 42, MTU: 1410}}
+		ifbLink = &netlink.Ifb{LinkAttrs: netlink.LinkAttrs{Name: "i-010255030004", Index: 43}}
+		fakeNetlinkAdapter.LinkByNameStub = func(name string) (netlink.Link, error) {
+			if name == ifbLink.Name {
+				return ifbLink, nil
+			}
+			return hostLink, nil
+		}
+		fakeNetlinkAdapter.TickInUsecReturns(1)
+
+		cfg = &config.Config{}
+		cfg.Host.DeviceName = "s-010255030004"
+		cfg.Host.IFBDeviceName = "i-010255030004"
+		cfg.Host.Namespace = hostNS
+		cfg.Bandwidth = config.BandwidthLimits{
+			IngressRate:  800000,
+			IngressBurst: 80000,
+			EgressRate:   1600000,
+			EgressBurst:  160000,
+		}
+
+		bandwidth = &lib.Bandwidth{
+			NetlinkAdapter: fakeNetlinkAdapter,
+			LinkOperations: fakeLinkOperations,
+			Logger:         lagertest.NewTestLogger("test"),
+		}
+	})
+
+	Describe("Setup", func() {
+		It("limits the traffic to the container with a tbf qdisc on the host device", func() {
+			Expect(bandwidth.Setup(cfg)).To(Succeed())
+
+			Expect(hostNS.DoCallCount()).To(Equal(1))
+			Expect(fakeNetlinkAdapter.QdiscAddCallCount()).To(Equal(3))
+			tbf, ok := fakeNetlinkAdapter.QdiscAddArgsForCall(0).(*netlink.Tbf)
+			Expect(ok).To(BeTrue())
+			Expect(tbf.LinkIndex).To(Equal(42))
+			Expect(tbf.Parent).To(Equal(uint32(netlink.HANDLE_ROOT)))
+			Expect(tbf.Rate).To(Equal(uint64(100000)))
+			Expect(tbf.Buffer).To(Equal(uint32(100000)))
+			Expect(tbf.Limit).To(Equal(uint32(102500)))
+		})
+
+		It("redirects the traffic from the container to an ifb device and limits it there", func() {
+			Expect(bandwidth.Setup(cfg)).To(Succeed())
+
+			Expect(fakeNetlinkAdapter.LinkAddCallCount()).To(Equal(1))
+			ifb, ok := fakeNetlinkAdapter.LinkAddArgsForCall(0).(*netlink.Ifb)
+			Expect(ok).To(BeTrue())
+			Expect(ifb.Name).To(Equal("i-010255030004"))
+			Expect(ifb.MTU).To(Equal(1410))
+			Expect(fakeNetlinkAdapter.LinkSetUpCallCount()).To(Equal(1))
+			Expect(fakeNetlinkAdapter.LinkSetUpArgsForCall(0)).To(Equal(ifbLink))
+
+			ingress, ok := fakeNetlinkAdapter.QdiscAddArgsForCall(1).(*netlink.Ingress)
+			Expect(ok).To(BeTrue())
+			Expect(ingress.LinkIndex).To(Equal(42))
+			Expect(ingress.Parent).To(Equal(uint32(netlink.HANDLE_INGRESS)))
+
+			Expect(fakeNetlinkAdapter.FilterAddCallCount()).To(Equal(1))
+			filter, ok := fakeNetlinkAdapter.FilterAddArgsForCall(0).(*netlink.U32)
+			Expect(ok).To(BeTrue())
+			Expect(filter.LinkIndex).To(Equal(42))
+			Expect(filter.Parent).To(Equal(ingress.Handle))
+			Expect(filter.Actions).To(Equal([]netlink.Action{netlink.NewMirredAction(43)}))
+
+			tbf, ok := fakeNetlinkAdapter.QdiscAddArgsForCall(2).(*netlink.Tbf)
+			Expect(ok).To(BeTrue())
+			Expect(tbf.LinkIndex).To(Equal(43))
+			Expect(tbf.Rate).To(Equal(uint64(200000)))
+		})
+
+		Context("when only ingress is limited", func() {
+			BeforeEach(func() {
+				cfg.Bandwidth.EgressRate = 0
+				cfg.Bandwidth.EgressBurst = 0
+			})
+
+			It("does not create an ifb device", func() {
+				Expect(bandwidth.Setup(cfg)).To(Succeed())
+
+				Expect(fakeNetlinkAdapter.LinkAddCallCount()).To(Equal(0))
+				Expect(fakeNetlinkAdapter.FilterAddCallCount()).To(Equal(0))
+				Expect(fakeNetlinkAdapter.QdiscAddCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when there are no limits", func() {
+			BeforeEach(func() {
+				cfg.Bandwidth = config.BandwidthLimits{}
+			})
+
+			It("does nothing", func() {
+				Expect(bandwidth.Setup(cfg)).To(Succeed())
+
+				Expect(hostNS.DoCallCount()).To(Equal(0))
+				Expect(fakeNetlinkAdapter.QdiscAddCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the rate is too small", func() {
+			BeforeEach(func() {
+				cfg.Bandwidth.IngressRate = 7
+			})
+
+			It("returns an error", func() {
+				err := bandwidth.Setup(cfg)
+				Expect(err).To(MatchError("limiting ingress on s-010255030004: rate and burst must be at least 8 bits"))
+			})
+		})
+
+		Context("when the host device cannot be found", func() {
+			BeforeEach(func() {
+				fakeNetlinkAdapter.LinkByNameStub = nil
+				fakeNetlinkAdapter.LinkByNameReturns(nil, errors.New("banana"))
+			})
+
+			It("returns an error", func() {
+				err := bandwidth.Setup(cfg)
+				Expect(err).To(MatchError(`failed to find link "s-010255030004": banana`))
+			})
+		})
+
+		Context("when adding a qdisc fails", func() {
+			BeforeEach(func() {
+				fakeNetlinkAdapter.QdiscAddReturns(errors.New("banana"))
+			})
+
+			It("returns an error", func() {
+				err := bandwidth.Setup(cfg)
+				Expect(err).To(MatchError("limiting ingress on s-010255030004: banana"))
+			})
+		})
+
+		Context("when creating the ifb device fails", func() {
+			BeforeEach(func() {
+				fakeNetlinkAdapter.LinkAddReturns(errors.New("banana"))
+			})
+
+			It("returns an error", func() {
+				err := bandwidth.Setup(cfg)
+				Expect(err).To(MatchError("creating ifb device i-010255030004: banana"))
+			})
+		})
+
+		Context("when adding the redirect filter fails", func() {
+			BeforeEach(func() {
+				fakeNetlinkAdapter.FilterAddReturns(errors.New("banana"))
+			})
+
+			It("returns an error", func() {
+				err := bandwidth.Setup(cfg)
+				Expect(err).To(MatchError("redirecting egress of s-010255030004 to i-010255030004: adding redirect filter: banana"))
+			})
+		})
+	})
+
+	Describe("Teardown", func() {
+		It("deletes the ifb device in the host namespace", func() {
+			Expect(bandwidth.Teardown(hostNS, "i-010255030004")).To(Succeed())
+
+			Expect(hostNS.DoCallCount()).To(Equal(1))
+			Expect(fakeLinkOperations.DeleteLinkByNameCallCount()).To(Equal(1))
+			Expect(fakeLinkOperations.DeleteLinkByNameArgsForCall(0)).To(Equal("i-010255030004"))
+		})
+
+		Context("when deleting the device fails", func() {
+			BeforeEach(func() {
+				fakeLinkOperations.DeleteLinkByNameReturns(errors.New("banana"))
+			})
+
+			It("returns an error", func() {
+				err := bandwidth.Teardown(hostNS, "i-010255030004")
+				Expect(err).To(MatchError("deleting ifb device: banana"))
+			})
+		})
+	})
+})
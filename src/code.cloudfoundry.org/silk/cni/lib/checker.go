@@ -0,0 +1,265 @@
+package lib
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/silk/cni/config"
+	"github.com/containernetworking/cni/pkg/types"
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/vishvananda/netlink"
+)
+
+// Error codes returned by CHECK for each kind of drift, in the CNI plugin specific range.
+const (
+	ErrLinkDrift       uint = 101
+	ErrAddressDrift    uint = 102
+	ErrRouteDrift      uint = 103
+	ErrNeighborDrift   uint = 104
+	ErrAllocationDrift uint = 105
+	ErrBandwidthDrift  uint = 106
+	ErrSysctlDrift     uint = 107
+)
+
+//go:generate counterfeiter -o fakes/checkNetlinkAdapter.go --fake-name CheckNetlinkAdapter . checkNetlinkAdapter
+type checkNetlinkAdapter interface {
+	LinkByName(string) (netlink.Link, error)
+	AddrList(link netlink.Link, family int) ([]netlink.Addr, error)
+	ARPList(linkIndex int) ([]netlink.Neigh, error)
+	NDPList(linkIndex int) ([]netlink.Neigh, error)
+	RouteList(link netlink.Link, family int) ([]netlink.Route, error)
+	QdiscList(link netlink.Link) ([]netlink.Qdisc, error)
+}
+
+// Checker confirms that the network stack set up by Host.Setup,
+// Container.Setup and Bandwidth.Setup still matches the config.
+type Checker struct {
+	NetlinkAdapter checkNetlinkAdapter
+	SysctlAdapter  sysctlAdapter
+	Logger         lager.Logger
+}
+
+func (c *Checker) Check(cfg *config.Config) error {
+	c.Logger.Debug("start")
+	defer c.Logger.Debug("done")
+
+	err := cfg.Host.Namespace.Do(func(_ ns.NetNS) error {
+		link, err := c.checkDevice("host", cfg.Host.DeviceName, cfg.Host.Address, cfg.Container.Address, cfg.Container.MTU)
+		if err != nil {
+			return err
+		}
+		routes := []*types.Route{{
+			Dst: net.IPNet{IP: cfg.Container.Address.IP, Mask: net.CIDRMask(32, 32)},
+		}}
+		if cfg.Container.Address.IPv6 != nil {
+			routes = append(routes, &types.Route{
+				Dst: net.IPNet{IP: cfg.Container.Address.IPv6, Mask: net.CIDRMask(128, 128)},
+			})
+		}
+		if err := c.checkRoutes("host", link, routes); err != nil {
+			return err
+		}
+		return c.checkBandwidth(link, cfg.Host.IFBDeviceName, cfg.Bandwidth)
+	})
+	if err != nil {
+		return err
+	}
+
+	return cfg.Container.Namespace.Do(func(_ ns.NetNS) error {
+		link, err := c.checkDevice("container", cfg.Container.DeviceName, cfg.Container.Address, cfg.Host.Address, cfg.Container.MTU)
+		if err != nil {
+			return err
+		}
+		if err := c.checkRoutes("container", link, cfg.Container.Routes); err != nil {
+			return err
+		}
+		return c.checkSysctls(cfg.Container.Sysctls)
+	})
+}
+
+// checkBandwidth confirms the qdiscs created by Bandwidth.Setup: a TBF with
+// the ingress rate on the host device, and for the egress rate an ingress
+// qdisc on the host device and a TBF on the IFB device.
+func (c *Checker) checkBandwidth(hostLink netlink.Link, ifbDeviceName string, limits config.BandwidthLimits) error {
+	hostName := hostLink.Attrs().Name
+	if limits.IngressRate > 0 {
+		if err := c.checkTBF(hostLink, limits.IngressRate); err != nil {
+			return err
+		}
+	}
+
+	if limits.EgressRate > 0 {
+		qdiscs, err := c.NetlinkAdapter.QdiscList(hostLink)
+		if err != nil {
+			return drift(ErrBandwidthDrift, fmt.Sprintf("list qdiscs of host device %s", hostName), err.Error())
+		}
+		if !hasIngressQdisc(qdiscs) {
+			return drift(ErrBandwidthDrift, fmt.Sprintf("host device %s is missing the ingress qdisc", hostName), "")
+		}
+
+		ifbLink, err := c.NetlinkAdapter.LinkByName(ifbDeviceName)
+		if err != nil {
+			return drift(ErrBandwidthDrift, fmt.Sprintf("ifb device %s not found", ifbDeviceName), err.Error())
+		}
+		if err := c.checkTBF(ifbLink, limits.EgressRate); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Checker) checkTBF(link netlink.Link, rateInBits uint64) error {
+	name := link.Attrs().Name
+	qdiscs, err := c.NetlinkAdapter.QdiscList(link)
+	if err != nil {
+		return drift(ErrBandwidthDrift, fmt.Sprintf("list qdiscs of device %s", name), err.Error())
+	}
+	for _, qdisc := range qdiscs {
+		if tbf, ok := qdisc.(*netlink.Tbf); ok && tbf.Parent == netlink.HANDLE_ROOT {
+			if tbf.Rate != rateInBits/8 {
+				return drift(ErrBandwidthDrift, fmt.Sprintf("device %s has wrong rate", name), fmt.Sprintf("expected %d, found %d bytes per second", rateInBits/8, tbf.Rate))
+			}
+			return nil
+		}
+	}
+	return drift(ErrBandwidthDrift, fmt.Sprintf("device %s is missing the tbf qdisc", name), "")
+}
+
+// checkSysctls confirms the sysctls set by Container.Setup in the current
+// namespace.
+func (c *Checker) checkSysctls(sysctls map[string]string) error {
+	for name, value := range sysctls {
+		current, err := c.SysctlAdapter.Sysctl(name)
+		if err != nil {
+			return drift(ErrSysctlDrift, fmt.Sprintf("read sysctl %s", name), err.Error())
+		}
+		if strings.TrimSpace(current) != value {
+			return drift(ErrSysctlDrift, fmt.Sprintf("sysctl %s has wrong value", name), fmt.Sprintf("expected %s, found %s", value, strings.TrimSpace(current)))
+		}
+	}
+	return nil
+}
+
+func (c *Checker) checkDevice(side, deviceName string, local, peer config.DualAddress, mtu int) (netlink.Link, error) {
+	link, err := c.NetlinkAdapter.LinkByName(deviceName)
+	if err != nil {
+		return nil, drift(ErrLinkDrift, fmt.Sprintf("%s device %s not found", side, deviceName), err.Error())
+	}
+
+	attrs := link.Attrs()
+	if link.Type() != "veth" {
+		return nil, drift(ErrLinkDrift, fmt.Sprintf("%s device %s is not a veth", side, deviceName), link.Type())
+	}
+	if attrs.MTU != mtu {
+		return nil, drift(ErrLinkDrift, fmt.Sprintf("%s device %s has wrong mtu", side, deviceName), fmt.Sprintf("expected %d, found %d", mtu, attrs.MTU))
+	}
+	if attrs.HardwareAddr.String() != local.Hardware.String() {
+		return nil, drift(ErrLinkDrift, fmt.Sprintf("%s device %s has wrong hardware address", side, deviceName), fmt.Sprintf("expected %s, found %s", local.Hardware, attrs.HardwareAddr))
+	}
+
+	addrs, err := c.NetlinkAdapter.AddrList(link, netlink.FAMILY_V4)
+	if err != nil {
+		return nil, drift(ErrAddressDrift, fmt.Sprintf("list addresses of %s device %s", side, deviceName), err.Error())
+	}
+	if !hasPointToPointAddress(addrs, local.IP, peer.IP) {
+		return nil, drift(ErrAddressDrift, fmt.Sprintf("%s device %s is missing address %s with peer %s", side, deviceName, local.IP, peer.IP), "")
+	}
+
+	neighs, err := c.NetlinkAdapter.ARPList(attrs.Index)
+	if err != nil {
+		return nil, drift(ErrNeighborDrift, fmt.Sprintf("list neighbors of %s device %s", side, deviceName), err.Error())
+	}
+	if !hasNeighbor(neighs, peer.IP, peer.Hardware) {
+		return nil, drift(ErrNeighborDrift, fmt.Sprintf("%s device %s is missing neighbor %s at %s", side, deviceName, peer.IP, peer.Hardware), "")
+	}
+
+	if local.IPv6 != nil {
+		addrs, err := c.NetlinkAdapter.AddrList(link, netlink.FAMILY_V6)
+		if err != nil {
+			return nil, drift(ErrAddressDrift, fmt.Sprintf("list IPv6 addresses of %s device %s", side, deviceName), err.Error())
+		}
+		if !hasPointToPointAddress(addrs, local.IPv6, peer.IPv6) {
+			return nil, drift(ErrAddressDrift, fmt.Sprintf("%s device %s is missing address %s with peer %s", side, deviceName, local.IPv6, peer.IPv6), "")
+		}
+
+		neighs, err := c.NetlinkAdapter.NDPList(attrs.Index)
+		if err != nil {
+			return nil, drift(ErrNeighborDrift, fmt.Sprintf("list IPv6 neighbors of %s device %s", side, deviceName), err.Error())
+		}
+		if !hasNeighbor(neighs, peer.IPv6, peer.Hardware) {
+			return nil, drift(ErrNeighborDrift, fmt.Sprintf("%s device %s is missing neighbor %s at %s", side, deviceName, peer.IPv6, peer.Hardware), "")
+		}
+	}
+
+	return link, nil
+}
+
+func (c *Checker) checkRoutes(side string, link netlink.Link, expected []*types.Route) error {
+	routes, err := c.NetlinkAdapter.RouteList(link, netlink.FAMILY_ALL)
+	if err != nil {
+		return drift(ErrRouteDrift, fmt.Sprintf("list routes of %s device %s", side, link.Attrs().Name), err.Error())
+	}
+	for _, route := range expected {
+		if !hasRoute(routes, route) {
+			return drift(ErrRouteDrift, fmt.Sprintf("%s device %s is missing route to %s", side, link.Attrs().Name, route.Dst.String()), "")
+		}
+	}
+	return nil
+}
+
+func hasPointToPointAddress(addrs []netlink.Addr, local, peer net.IP) bool {
+	for _, addr := range addrs {
+		if addr.IPNet != nil && addr.IP.Equal(local) && addr.Peer != nil && addr.Peer.IP.Equal(peer) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasIngressQdisc(qdiscs []netlink.Qdisc) bool {
+	for _, qdisc := range qdiscs {
+		if _, ok := qdisc.(*netlink.Ingress); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func hasNeighbor(neighs []netlink.Neigh, ip net.IP, hardwareAddr net.HardwareAddr) bool {
+	for _, neigh := range neighs {
+		if neigh.IP.Equal(ip) && neigh.HardwareAddr.String() == hardwareAddr.String() {
+			return true
+		}
+	}
+	return false
+}
+
+func hasRoute(routes []netlink.Route, expected *types.Route) bool {
+	for _, route := range routes {
+		dst := "0.0.0.0/0"
+		if route.Family == netlink.FAMILY_V6 {
+			dst = "::/0"
+		}
+		if route.Dst != nil {
+			dst = route.Dst.String()
+		}
+		if dst != expected.Dst.String() {
+			continue
+		}
+		if expected.GW == nil || route.Gw.Equal(expected.GW) {
+			return true
+		}
+	}
+	return false
+}
+
+func drift(code uint, msg, details string) *types.Error {
+	return &types.Error{
+		Code:    code,
+		Msg:     msg,
+		Details: details,
+	}
+}
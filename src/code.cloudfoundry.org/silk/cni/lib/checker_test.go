@@ -0,0 +1,383 @@
+package lib_test
+
+import (
+	"errors"
+	"net"
+
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/silk/cni/config"
+	"code.cloudfoundry.org/silk/cni/lib"
+	"code.cloudfoundry.org/silk/cni/lib/fakes"
+	"github.com/containernetworking/cni/pkg/types"
+	"github.com/containernetworking/plugins/pkg/ns"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/vishvananda/netlink"
+)
+
+var _ = Describe("Checker", func() {
+	var (
+		cfg           *config.Config
+		hostNS        *fakes.NetNS
+		containerNS   *fakes.NetNS
+		fakeNetlink   *fakes.CheckNetlinkAdapter
+		fakeSysctl    *fakes.SysctlAdapter
+		checker       *lib.Checker
+		hostLink      *netlink.Veth
+		containerLink *netlink.Veth
+		hostMAC       net.HardwareAddr
+		containerMAC  net.HardwareAddr
+		hostIP        net.IP
+		containerIP   net.IP
+		inHost        bool
+	)
+
+	driftCode := func(err error) uint {
+		typedErr, ok := err.(*types.Error)
+		Expect(ok).To(BeTrue())
+		return typedErr.Code
+	}
+
+	BeforeEach(func() {
+		hostMAC, _ = net.ParseMAC("aa:aa:0a:ff:1e:04")
+		containerMAC, _ = net.ParseMAC("ee:ee:0a:ff:1e:04")
+		hostIP = net.IP{169, 254, 0, 1}
+		containerIP = net.IP{10, 255, 30, 4}
+
+		hostNS = &fakes.NetNS{}
+		containerNS = &fakes.NetNS{}
+		hostNS.DoStub = func(f func(_ ns.NetNS) error) error {
+			inHost = true
+			return f(nil)
+		}
+		containerNS.DoStub = func(f func(_ ns.NetNS) error) error {
+			inHost = false
+			return f(nil)
+		}
+
+		cfg = &config.Config{}
+		cfg.Host.DeviceName = "s-010255030004"
+		cfg.Host.Namespace = hostNS
+		cfg.Host.Address = config.DualAddress{IP: hostIP, Hardware: hostMAC}
+		cfg.Container.DeviceName = "eth0"
+		cfg.Container.Namespace = containerNS
+		cfg.Container.Address = config.DualAddress{IP: containerIP, Hardware: containerMAC}
+		cfg.Container.MTU = 1410
+		cfg.Container.Routes = []*types.Route{{
+			Dst: net.IPNet{IP: net.IPv4zero, Mask: net.CIDRMask(0, 32)},
+			GW:  hostIP,
+		}}
+
+		hostLink = &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "s-010255030004", Index: 7, MTU: 1410, HardwareAddr: hostMAC}}
+		containerLink = &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "eth0", Index: 2, MTU: 1410, HardwareAddr: containerMAC}}
+
+		fakeNetlink = &fakes.CheckNetlinkAdapter{}
+		fakeNetlink.LinkByNameStub = func(name string) (netlink.Link, error) {
+			if inHost {
+				return hostLink, nil
+			}
+			return containerLink, nil
+		}
+		fakeNetlink.AddrListStub = func(_ netlink.Link, _ int) ([]netlink.Addr, error) {
+			if inHost {
+				return []netlink.Addr{{IPNet: &net.IPNet{IP: hostIP, Mask: net.CIDRMask(32, 32)}, Peer: &net.IPNet{IP: containerIP, Mask: net.CIDRMask(32, 32)}}}, nil
+			}
+			return []netlink.Addr{{IPNet: &net.IPNet{IP: containerIP, Mask: net.CIDRMask(32, 32)}, Peer: &net.IPNet{IP: hostIP, Mask: net.CIDRMask(32, 32)}}}, nil
+		}
+		fakeNetlink.ARPListStub = func(_ int) ([]netlink.Neigh, error) {
+			if inHost {
+				return []netlink.Neigh{{IP: containerIP, HardwareAddr: containerMAC}}, nil
+			}
+			return []netlink.Neigh{{IP: hostIP, HardwareAddr: hostMAC}}, nil
+		}
+		fakeNetlink.RouteListStub = func(_ netlink.Link, _ int) ([]netlink.Route, error) {
+			if inHost {
+				return []netlink.Route{{Dst: &net.IPNet{IP: containerIP, Mask: net.CIDRMask(32, 32)}}}, nil
+			}
+			return []netlink.Route{
+				{Dst: &net.IPNet{IP: hostIP, Mask: net.CIDRMask(32, 32)}},
+				{Gw: hostIP},
+			}, nil
+		}
+
+		fakeSysctl = &fakes.SysctlAdapter{}
+
+		checker = &lib.Checker{
+			NetlinkAdapter: fakeNetlink,
+			SysctlAdapter:  fakeSysctl,
+			Logger:         lagertest.NewTestLogger("test"),
+		}
+	})
+
+	It("succeeds when the host and container match the config", func() {
+		Expect(checker.Check(cfg)).To(Succeed())
+		Expect(hostNS.DoCallCount()).To(Equal(1))
+		Expect(containerNS.DoCallCount()).To(Equal(1))
+		Expect(fakeNetlink.LinkByNameArgsForCall(0)).To(Equal("s-010255030004"))
+		Expect(fakeNetlink.LinkByNameArgsForCall(1)).To(Equal("eth0"))
+	})
+
+	Context("when the config is dual-stack", func() {
+		var hostIPv6, containerIPv6 net.IP
+
+		BeforeEach(func() {
+			hostIPv6 = net.ParseIP("fe80::1")
+			containerIPv6 = net.ParseIP("fd00:5111::aff:1e04")
+			cfg.Host.Address.IPv6 = hostIPv6
+			cfg.Container.Address.IPv6 = containerIPv6
+			cfg.Container.Routes = append(cfg.Container.Routes, &types.Route{
+				Dst: net.IPNet{IP: net.IPv6zero, Mask: net.CIDRMask(0, 128)},
+				GW:  hostIPv6,
+			})
+
+			fakeNetlink.AddrListStub = func(_ netlink.Link, family int) ([]netlink.Addr, error) {
+				local, peer, bits := hostIP, containerIP, 32
+				if family == netlink.FAMILY_V6 {
+					local, peer, bits = hostIPv6, containerIPv6, 128
+				}
+				if !inHost {
+					local, peer = peer, local
+				}
+				return []netlink.Addr{{IPNet: &net.IPNet{IP: local, Mask: net.CIDRMask(bits, bits)}, Peer: &net.IPNet{IP: peer, Mask: net.CIDRMask(bits, bits)}}}, nil
+			}
+			fakeNetlink.NDPListStub = func(_ int) ([]netlink.Neigh, error) {
+				if inHost {
+					return []netlink.Neigh{{IP: containerIPv6, HardwareAddr: containerMAC}}, nil
+				}
+				return []netlink.Neigh{{IP: hostIPv6, HardwareAddr: hostMAC}}, nil
+			}
+			fakeNetlink.RouteListStub = func(_ netlink.Link, _ int) ([]netlink.Route, error) {
+				if inHost {
+					return []netlink.Route{
+						{Dst: &net.IPNet{IP: containerIP, Mask: net.CIDRMask(32, 32)}},
+						{Dst: &net.IPNet{IP: containerIPv6, Mask: net.CIDRMask(128, 128)}, Family: netlink.FAMILY_V6},
+					}, nil
+				}
+				return []netlink.Route{
+					{Gw: hostIP},
+					{Gw: hostIPv6, Family: netlink.FAMILY_V6},
+				}, nil
+			}
+		})
+
+		It("succeeds when both families match the config", func() {
+			Expect(checker.Check(cfg)).To(Succeed())
+		})
+
+		Context("when the container IPv6 neighbor is missing", func() {
+			BeforeEach(func() {
+				fakeNetlink.NDPListStub = nil
+				fakeNetlink.NDPListReturns(nil, nil)
+			})
+
+			It("returns a neighbor drift error", func() {
+				err := checker.Check(cfg)
+				Expect(driftCode(err)).To(Equal(lib.ErrNeighborDrift))
+				Expect(err.(*types.Error).Msg).To(Equal("host device s-010255030004 is missing neighbor fd00:5111::aff:1e04 at ee:ee:0a:ff:1e:04"))
+			})
+		})
+
+		Context("when the container IPv6 default route is missing", func() {
+			BeforeEach(func() {
+				fakeNetlink.RouteListStub = func(_ netlink.Link, _ int) ([]netlink.Route, error) {
+					return []netlink.Route{
+						{Dst: &net.IPNet{IP: containerIP, Mask: net.CIDRMask(32, 32)}},
+						{Dst: &net.IPNet{IP: containerIPv6, Mask: net.CIDRMask(128, 128)}, Family: netlink.FAMILY_V6},
+						{Gw: hostIP},
+					}, nil
+				}
+			})
+
+			It("returns a route drift error", func() {
+				err := checker.Check(cfg)
+				Expect(driftCode(err)).To(Equal(lib.ErrRouteDrift))
+				Expect(err.(*types.Error).Msg).To(Equal("container device eth0 is missing route to ::/0"))
+			})
+		})
+	})
+
+	Context("when the host device is missing", func() {
+		BeforeEach(func() {
+			fakeNetlink.LinkByNameStub = nil
+			fakeNetlink.LinkByNameReturns(nil, errors.New("banana"))
+		})
+
+		It("returns a link drift error", func() {
+			err := checker.Check(cfg)
+			Expect(driftCode(err)).To(Equal(lib.ErrLinkDrift))
+			Expect(err.(*types.Error).Msg).To(Equal("host device s-010255030004 not found"))
+		})
+	})
+
+	Context("when the container device has the wrong mtu", func() {
+		BeforeEach(func() {
+			containerLink.MTU = 1500
+		})
+
+		It("returns a link drift error", func() {
+			err := checker.Check(cfg)
+			Expect(driftCode(err)).To(Equal(lib.ErrLinkDrift))
+			Expect(err.(*types.Error).Msg).To(Equal("container device eth0 has wrong mtu"))
+			Expect(err.(*types.Error).Details).To(Equal("expected 1410, found 1500"))
+		})
+	})
+
+	Context("when the host device has the wrong hardware address", func() {
+		BeforeEach(func() {
+			hostLink.HardwareAddr = containerMAC
+		})
+
+		It("returns a link drift error", func() {
+			err := checker.Check(cfg)
+			Expect(driftCode(err)).To(Equal(lib.ErrLinkDrift))
+		})
+	})
+
+	Context("when the container address is missing", func() {
+		BeforeEach(func() {
+			fakeNetlink.AddrListStub = nil
+			fakeNetlink.AddrListReturns(nil, nil)
+		})
+
+		It("returns an address drift error", func() {
+			err := checker.Check(cfg)
+			Expect(driftCode(err)).To(Equal(lib.ErrAddressDrift))
+		})
+	})
+
+	Context("when the neighbor is missing", func() {
+		BeforeEach(func() {
+			fakeNetlink.ARPListStub = nil
+			fakeNetlink.ARPListReturns(nil, nil)
+		})
+
+		It("returns a neighbor drift error", func() {
+			err := checker.Check(cfg)
+			Expect(driftCode(err)).To(Equal(lib.ErrNeighborDrift))
+			Expect(err.(*types.Error).Msg).To(Equal("host device s-010255030004 is missing neighbor 10.255.30.4 at ee:ee:0a:ff:1e:04"))
+		})
+	})
+
+	Context("when the container default route is missing", func() {
+		BeforeEach(func() {
+			fakeNetlink.RouteListStub = func(_ netlink.Link, _ int) ([]netlink.Route, error) {
+				return []netlink.Route{{Dst: &net.IPNet{IP: containerIP, Mask: net.CIDRMask(32, 32)}}}, nil
+			}
+		})
+
+		It("returns a route drift error", func() {
+			err := checker.Check(cfg)
+			Expect(driftCode(err)).To(Equal(lib.ErrRouteDrift))
+			Expect(err.(*types.Error).Msg).To(Equal("container device eth0 is missing route to 0.0.0.0/0"))
+		})
+	})
+
+	Context("when the config has bandwidth limits", func() {
+		var ifbLink *netlink.Ifb
+
+		BeforeEach(func() {
+			cfg.Host.IFBDeviceName = "i-010255030004"
+			cfg.Bandwidth = config.BandwidthLimits{IngressRate: 8000, IngressBurst: 16000, EgressRate: 16000, EgressBurst: 16000}
+
+			ifbLink = &netlink.Ifb{LinkAttrs: netlink.LinkAttrs{Name: "i-010255030004", Index: 8}}
+			fakeNetlink.LinkByNameStub = func(name string) (netlink.Link, error) {
+				switch {
+				case name == "i-010255030004":
+					return ifbLink, nil
+				case inHost:
+					return hostLink, nil
+				}
+				return containerLink, nil
+			}
+			fakeNetlink.QdiscListStub = func(link netlink.Link) ([]netlink.Qdisc, error) {
+				if link == ifbLink {
+					return []netlink.Qdisc{&netlink.Tbf{QdiscAttrs: netlink.QdiscAttrs{Parent: netlink.HANDLE_ROOT}, Rate: 2000}}, nil
+				}
+				return []netlink.Qdisc{
+					&netlink.Tbf{QdiscAttrs: netlink.QdiscAttrs{Parent: netlink.HANDLE_ROOT}, Rate: 1000},
+					&netlink.Ingress{QdiscAttrs: netlink.QdiscAttrs{Parent: netlink.HANDLE_INGRESS}},
+				}, nil
+			}
+		})
+
+		It("checks the qdiscs of the host and ifb devices", func() {
+			Expect(checker.Check(cfg)).To(Succeed())
+			Expect(fakeNetlink.QdiscListCallCount()).To(Equal(3))
+			Expect(fakeNetlink.QdiscListArgsForCall(2)).To(Equal(ifbLink))
+		})
+
+		Context("when the ingress rate differs", func() {
+			BeforeEach(func() {
+				cfg.Bandwidth.IngressRate = 16000
+			})
+
+			It("returns a bandwidth drift error", func() {
+				err := checker.Check(cfg)
+				Expect(driftCode(err)).To(Equal(lib.ErrBandwidthDrift))
+				Expect(err.(*types.Error).Msg).To(Equal("device s-010255030004 has wrong rate"))
+				Expect(err.(*types.Error).Details).To(Equal("expected 2000, found 1000 bytes per second"))
+			})
+		})
+
+		Context("when the ifb device is missing", func() {
+			BeforeEach(func() {
+				fakeNetlink.LinkByNameStub = func(name string) (netlink.Link, error) {
+					if name == "i-010255030004" {
+						return nil, errors.New("banana")
+					}
+					if inHost {
+						return hostLink, nil
+					}
+					return containerLink, nil
+				}
+			})
+
+			It("returns a bandwidth drift error", func() {
+				err := checker.Check(cfg)
+				Expect(driftCode(err)).To(Equal(lib.ErrBandwidthDrift))
+				Expect(err.(*types.Error).Msg).To(Equal("ifb device i-010255030004 not found"))
+			})
+		})
+
+		Context("when the ingress qdisc is missing", func() {
+			BeforeEach(func() {
+				fakeNetlink.QdiscListStub = nil
+				fakeNetlink.QdiscListReturns([]netlink.Qdisc{&netlink.Tbf{QdiscAttrs: netlink.QdiscAttrs{Parent: netlink.HANDLE_ROOT}, Rate: 1000}}, nil)
+			})
+
+			It("returns a bandwidth drift error", func() {
+				err := checker.Check(cfg)
+				Expect(driftCode(err)).To(Equal(lib.ErrBandwidthDrift))
+				Expect(err.(*types.Error).Msg).To(Equal("host device s-010255030004 is missing the ingress qdisc"))
+			})
+		})
+	})
+
+	Context("when the config has sysctls", func() {
+		BeforeEach(func() {
+			cfg.Container.Sysctls = map[string]string{"net.ipv4.tcp_keepalive_time": "60"}
+			fakeSysctl.SysctlReturns("60\n", nil)
+		})
+
+		It("reads the sysctls in the container namespace", func() {
+			Expect(checker.Check(cfg)).To(Succeed())
+			Expect(fakeSysctl.SysctlCallCount()).To(Equal(1))
+			name, params := fakeSysctl.SysctlArgsForCall(0)
+			Expect(name).To(Equal("net.ipv4.tcp_keepalive_time"))
+			Expect(params).To(BeEmpty())
+		})
+
+		Context("when a sysctl has a different value", func() {
+			BeforeEach(func() {
+				fakeSysctl.SysctlReturns("7200", nil)
+			})
+
+			It("returns a sysctl drift error", func() {
+				err := checker.Check(cfg)
+				Expect(driftCode(err)).To(Equal(lib.ErrSysctlDrift))
+				Expect(err.(*types.Error).Msg).To(Equal("sysctl net.ipv4.tcp_keepalive_time has wrong value"))
+				Expect(err.(*types.Error).Details).To(Equal("expected 60, found 7200"))
+			})
+		})
+	})
+})
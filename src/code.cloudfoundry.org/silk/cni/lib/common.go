@@ -52,8 +52,12 @@ func (s *Common) BasicSetup(deviceName string, local, peer config.DualAddress) e
 		s.Logger.Debug("hardware-addr-set-correctly", lager.Data{"addr": l.Attrs().HardwareAddr.String()})
 	}
 
-	// #nosec G104 - we have tests explicitly checking that we ignore failures here, so don't handle it
-	s.LinkOperations.DisableIPv6(deviceName)
+	if local.IPv6 == nil {
+		// #nosec G104 - we have tests explicitly checking that we ignore failures here, so don't handle it
+		s.LinkOperations.DisableIPv6(deviceName)
+	} else if err := s.LinkOperations.EnableIPv6(deviceName); err != nil {
+		return fmt.Errorf("enable IPv6: %s", err)
+	}
 
 	if err := s.LinkOperations.StaticNeighborNoARP(link, peer.IP, peer.Hardware); err != nil {
 		return fmt.Errorf("replace ARP with permanent neighbor rule: %s", err)
@@ -63,6 +67,18 @@ func (s *Common) BasicSetup(deviceName string, local, peer config.DualAddress) e
 		return fmt.Errorf("setting point to point address: %s", err)
 	}
 
+	// on dual-stack networks the peers reach each other over IPv6 the same
+	// way, with neighbor discovery replaced by a permanent neighbor rule
+	if local.IPv6 != nil {
+		if err := s.LinkOperations.StaticNeighborNoARP(link, peer.IPv6, peer.Hardware); err != nil {
+			return fmt.Errorf("replace neighbor discovery with permanent neighbor rule: %s", err)
+		}
+
+		if err := s.LinkOperations.SetPointToPointAddress(link, local.IPv6, peer.IPv6); err != nil {
+			return fmt.Errorf("setting IPv6 point to point address: %s", err)
+		}
+	}
+
 	if err := s.LinkOperations.EnableReversePathFiltering(deviceName); err != nil {
 		return fmt.Errorf("enable reverse path filtering: %s", err)
 	}
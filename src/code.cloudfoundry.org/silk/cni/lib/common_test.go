@@ -88,6 +88,62 @@ var _ = Describe("Common", func() {
 			Expect(fakeNetlinkAdapter.LinkSetUpArgsForCall(0)).To(Equal(fakeLink))
 		})
 
+		Context("when the addresses are dual-stack", func() {
+			BeforeEach(func() {
+				local.IPv6 = net.ParseIP("fd00:5111::aff:1e04")
+				peer.IPv6 = net.ParseIP("fe80::1")
+			})
+
+			It("sets up IPv6 on the veth device as well", func() {
+				err := common.BasicSetup(deviceName, local, peer)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeLinkOperations.DisableIPv6CallCount()).To(Equal(0))
+				Expect(fakeLinkOperations.EnableIPv6CallCount()).To(Equal(1))
+				Expect(fakeLinkOperations.EnableIPv6ArgsForCall(0)).To(Equal("myDeviceName"))
+
+				Expect(fakeLinkOperations.StaticNeighborNoARPCallCount()).To(Equal(2))
+				_, peerIP, peerHardwareAddr := fakeLinkOperations.StaticNeighborNoARPArgsForCall(1)
+				Expect(peerIP).To(Equal(peer.IPv6))
+				Expect(peerHardwareAddr).To(Equal(peer.Hardware))
+
+				Expect(fakeLinkOperations.SetPointToPointAddressCallCount()).To(Equal(2))
+				_, localIP, peerIP := fakeLinkOperations.SetPointToPointAddressArgsForCall(1)
+				Expect(localIP).To(Equal(local.IPv6))
+				Expect(peerIP).To(Equal(peer.IPv6))
+			})
+
+			Context("when enabling IPv6 fails", func() {
+				BeforeEach(func() {
+					fakeLinkOperations.EnableIPv6Returns(errors.New("kiwi"))
+				})
+				It("wraps and returns the error", func() {
+					err := common.BasicSetup(deviceName, local, peer)
+					Expect(err).To(Equal(errors.New("enable IPv6: kiwi")))
+				})
+			})
+
+			Context("when replacing neighbor discovery with a permanent neighbor rule fails", func() {
+				BeforeEach(func() {
+					fakeLinkOperations.StaticNeighborNoARPReturnsOnCall(1, errors.New("raspberry"))
+				})
+				It("wraps and returns the error", func() {
+					err := common.BasicSetup(deviceName, local, peer)
+					Expect(err).To(Equal(errors.New("replace neighbor discovery with permanent neighbor rule: raspberry")))
+				})
+			})
+
+			Context("when setting the IPv6 point to point address fails", func() {
+				BeforeEach(func() {
+					fakeLinkOperations.SetPointToPointAddressReturnsOnCall(1, errors.New("dragonfruit"))
+				})
+				It("wraps and returns the error", func() {
+					err := common.BasicSetup(deviceName, local, peer)
+					Expect(err).To(Equal(errors.New("setting IPv6 point to point address: dragonfruit")))
+				})
+			})
+		})
+
 		Context("when the link cannot be found", func() {
 			BeforeEach(func() {
 				fakeNetlinkAdapter.LinkByNameReturns(nil, errors.New("strawberry"))
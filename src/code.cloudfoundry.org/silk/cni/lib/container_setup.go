@@ -2,6 +2,7 @@ package lib
 
 import (
 	"fmt"
+	"sort"
 
 	"code.cloudfoundry.org/lager/v3"
 	"code.cloudfoundry.org/silk/cni/config"
@@ -16,7 +17,8 @@ type Container struct {
 
 // Teardown deletes the named device from the container.
 // The kernel should automatically cleanup the other end of the veth pair
-// and any associated addresses, neighbor rules, etc
+// and any associated addresses, neighbor rules, bandwidth qdiscs, etc.
+// The IFB device used to limit egress is removed by Bandwidth.Teardown.
 func (c *Container) Teardown(containerNS ns.NetNS, deviceName string) error {
 	c.Logger.Debug("start")
 	defer c.Logger.Debug("done")
@@ -48,10 +50,21 @@ func (c *Container) Setup(cfg *config.Config) error {
 			return fmt.Errorf("setting up device in container: %s", err)
 		}
 
-		if err := c.LinkOperations.RouteAddAll(cfg.Container.Routes, cfg.Container.Address.IP); err != nil {
+		if err := c.LinkOperations.RouteAddAll(cfg.Container.Routes, deviceName, local); err != nil {
 			return fmt.Errorf("adding route in container: %s", err)
 		}
 
+		names := make([]string, 0, len(cfg.Container.Sysctls))
+		for name := range cfg.Container.Sysctls {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if err := c.LinkOperations.SetSysctl(name, cfg.Container.Sysctls[name]); err != nil {
+				return fmt.Errorf("setting sysctl in container: %s", err)
+			}
+		}
+
 		return nil
 	})
 }
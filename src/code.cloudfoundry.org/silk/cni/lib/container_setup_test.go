@@ -90,9 +90,10 @@ var _ = Describe("Container Setup", func() {
 
 			By("Adding all the routes")
 			Expect(fakeLinkOperations.RouteAddAllCallCount()).To(Equal(1))
-			routes, srcIP := fakeLinkOperations.RouteAddAllArgsForCall(0)
+			routes, device, local := fakeLinkOperations.RouteAddAllArgsForCall(0)
 			Expect(routes).To(Equal(cfg.Container.Routes))
-			Expect(srcIP).To(Equal(cfg.Container.Address.IP))
+			Expect(device).To(Equal("eth0"))
+			Expect(local).To(Equal(cfg.Container.Address))
 		})
 
 		Context("when renaming the link fails", func() {
@@ -115,6 +116,38 @@ var _ = Describe("Container Setup", func() {
 			})
 		})
 
+		Context("when sysctls are configured", func() {
+			BeforeEach(func() {
+				cfg.Container.Sysctls = map[string]string{
+					"net.ipv4.tcp_keepalive_time": "600",
+					"net.core.somaxconn":          "1024",
+				}
+			})
+
+			It("sets them in the container in order", func() {
+				err := containerSetup.Setup(cfg)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeLinkOperations.SetSysctlCallCount()).To(Equal(2))
+				name, value := fakeLinkOperations.SetSysctlArgsForCall(0)
+				Expect(name).To(Equal("net.core.somaxconn"))
+				Expect(value).To(Equal("1024"))
+				name, value = fakeLinkOperations.SetSysctlArgsForCall(1)
+				Expect(name).To(Equal("net.ipv4.tcp_keepalive_time"))
+				Expect(value).To(Equal("600"))
+			})
+
+			Context("when setting a sysctl fails", func() {
+				BeforeEach(func() {
+					fakeLinkOperations.SetSysctlReturns(errors.New("lettuce"))
+				})
+				It("returns a meaningful error", func() {
+					err := containerSetup.Setup(cfg)
+					Expect(err).To(MatchError("setting sysctl in container: lettuce"))
+				})
+			})
+		})
+
 		Context("when adding the routes fails", func() {
 			BeforeEach(func() {
 				fakeLinkOperations.RouteAddAllReturns(errors.New("lettuce"))
@@ -0,0 +1,121 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/silk/cni/ipam"
+)
+
+type AllocationCollector struct {
+	CollectStub        func(ipam.Network, func(ipam.Allocation) bool) (map[string]ipam.Allocation, map[string]ipam.Allocation, error)
+	collectMutex       sync.RWMutex
+	collectArgsForCall []struct {
+		arg1 ipam.Network
+		arg2 func(ipam.Allocation) bool
+	}
+	collectReturns struct {
+		result1 map[string]ipam.Allocation
+		result2 map[string]ipam.Allocation
+		result3 error
+	}
+	collectReturnsOnCall map[int]struct {
+		result1 map[string]ipam.Allocation
+		result2 map[string]ipam.Allocation
+		result3 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *AllocationCollector) Collect(arg1 ipam.Network, arg2 func(ipam.Allocation) bool) (map[string]ipam.Allocation, map[string]ipam.Allocation, error) {
+	fake.collectMutex.Lock()
+	ret, specificReturn := fake.collectReturnsOnCall[len(fake.collectArgsForCall)]
+	fake.collectArgsForCall = append(fake.collectArgsForCall, struct {
+		arg1 ipam.Network
+		arg2 func(ipam.Allocation) bool
+	}{arg1, arg2})
+	stub := fake.CollectStub
+	fakeReturns := fake.collectReturns
+	fake.recordInvocation("Collect", []interface{}{arg1, arg2})
+	fake.collectMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2, ret.result3
+	}
+	return fakeReturns.result1, fakeReturns.result2, fakeReturns.result3
+}
+
+func (fake *AllocationCollector) CollectCallCount() int {
+	fake.collectMutex.RLock()
+	defer fake.collectMutex.RUnlock()
+	return len(fake.collectArgsForCall)
+}
+
+func (fake *AllocationCollector) CollectCalls(stub func(ipam.Network, func(ipam.Allocation) bool) (map[string]ipam.Allocation, map[string]ipam.Allocation, error)) {
+	fake.collectMutex.Lock()
+	defer fake.collectMutex.Unlock()
+	fake.CollectStub = stub
+}
+
+func (fake *AllocationCollector) CollectArgsForCall(i int) (ipam.Network, func(ipam.Allocation) bool) {
+	fake.collectMutex.RLock()
+	defer fake.collectMutex.RUnlock()
+	argsForCall := fake.collectArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *AllocationCollector) CollectReturns(result1 map[string]ipam.Allocation, result2 map[string]ipam.Allocation, result3 error) {
+	fake.collectMutex.Lock()
+	defer fake.collectMutex.Unlock()
+	fake.CollectStub = nil
+	fake.collectReturns = struct {
+		result1 map[string]ipam.Allocation
+		result2 map[string]ipam.Allocation
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *AllocationCollector) CollectReturnsOnCall(i int, result1 map[string]ipam.Allocation, result2 map[string]ipam.Allocation, result3 error) {
+	fake.collectMutex.Lock()
+	defer fake.collectMutex.Unlock()
+	fake.CollectStub = nil
+	if fake.collectReturnsOnCall == nil {
+		fake.collectReturnsOnCall = make(map[int]struct {
+			result1 map[string]ipam.Allocation
+			result2 map[string]ipam.Allocation
+			result3 error
+		})
+	}
+	fake.collectReturnsOnCall[i] = struct {
+		result1 map[string]ipam.Allocation
+		result2 map[string]ipam.Allocation
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *AllocationCollector) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.collectMutex.RLock()
+	defer fake.collectMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *AllocationCollector) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
@@ -0,0 +1,122 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"net"
+	"sync"
+
+	"code.cloudfoundry.org/silk/cni/ipam"
+)
+
+type AllocationLookup struct {
+	LookupStub        func(ipam.Network, net.IP) (ipam.Allocation, bool, error)
+	lookupMutex       sync.RWMutex
+	lookupArgsForCall []struct {
+		arg1 ipam.Network
+		arg2 net.IP
+	}
+	lookupReturns struct {
+		result1 ipam.Allocation
+		result2 bool
+		result3 error
+	}
+	lookupReturnsOnCall map[int]struct {
+		result1 ipam.Allocation
+		result2 bool
+		result3 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *AllocationLookup) Lookup(arg1 ipam.Network, arg2 net.IP) (ipam.Allocation, bool, error) {
+	fake.lookupMutex.Lock()
+	ret, specificReturn := fake.lookupReturnsOnCall[len(fake.lookupArgsForCall)]
+	fake.lookupArgsForCall = append(fake.lookupArgsForCall, struct {
+		arg1 ipam.Network
+		arg2 net.IP
+	}{arg1, arg2})
+	stub := fake.LookupStub
+	fakeReturns := fake.lookupReturns
+	fake.recordInvocation("Lookup", []interface{}{arg1, arg2})
+	fake.lookupMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2, ret.result3
+	}
+	return fakeReturns.result1, fakeReturns.result2, fakeReturns.result3
+}
+
+func (fake *AllocationLookup) LookupCallCount() int {
+	fake.lookupMutex.RLock()
+	defer fake.lookupMutex.RUnlock()
+	return len(fake.lookupArgsForCall)
+}
+
+func (fake *AllocationLookup) LookupCalls(stub func(ipam.Network, net.IP) (ipam.Allocation, bool, error)) {
+	fake.lookupMutex.Lock()
+	defer fake.lookupMutex.Unlock()
+	fake.LookupStub = stub
+}
+
+func (fake *AllocationLookup) LookupArgsForCall(i int) (ipam.Network, net.IP) {
+	fake.lookupMutex.RLock()
+	defer fake.lookupMutex.RUnlock()
+	argsForCall := fake.lookupArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *AllocationLookup) LookupReturns(result1 ipam.Allocation, result2 bool, result3 error) {
+	fake.lookupMutex.Lock()
+	defer fake.lookupMutex.Unlock()
+	fake.LookupStub = nil
+	fake.lookupReturns = struct {
+		result1 ipam.Allocation
+		result2 bool
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *AllocationLookup) LookupReturnsOnCall(i int, result1 ipam.Allocation, result2 bool, result3 error) {
+	fake.lookupMutex.Lock()
+	defer fake.lookupMutex.Unlock()
+	fake.LookupStub = nil
+	if fake.lookupReturnsOnCall == nil {
+		fake.lookupReturnsOnCall = make(map[int]struct {
+			result1 ipam.Allocation
+			result2 bool
+			result3 error
+		})
+	}
+	fake.lookupReturnsOnCall[i] = struct {
+		result1 ipam.Allocation
+		result2 bool
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *AllocationLookup) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.lookupMutex.RLock()
+	defer fake.lookupMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *AllocationLookup) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
@@ -0,0 +1,513 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"sync"
+
+	"github.com/vishvananda/netlink"
+)
+
+type CheckNetlinkAdapter struct {
+	ARPListStub        func(int) ([]netlink.Neigh, error)
+	aRPListMutex       sync.RWMutex
+	aRPListArgsForCall []struct {
+		arg1 int
+	}
+	aRPListReturns struct {
+		result1 []netlink.Neigh
+		result2 error
+	}
+	aRPListReturnsOnCall map[int]struct {
+		result1 []netlink.Neigh
+		result2 error
+	}
+	AddrListStub        func(netlink.Link, int) ([]netlink.Addr, error)
+	addrListMutex       sync.RWMutex
+	addrListArgsForCall []struct {
+		arg1 netlink.Link
+		arg2 int
+	}
+	addrListReturns struct {
+		result1 []netlink.Addr
+		result2 error
+	}
+	addrListReturnsOnCall map[int]struct {
+		result1 []netlink.Addr
+		result2 error
+	}
+	LinkByNameStub        func(string) (netlink.Link, error)
+	linkByNameMutex       sync.RWMutex
+	linkByNameArgsForCall []struct {
+		arg1 string
+	}
+	linkByNameReturns struct {
+		result1 netlink.Link
+		result2 error
+	}
+	linkByNameReturnsOnCall map[int]struct {
+		result1 netlink.Link
+		result2 error
+	}
+	NDPListStub        func(int) ([]netlink.Neigh, error)
+	nDPListMutex       sync.RWMutex
+	nDPListArgsForCall []struct {
+		arg1 int
+	}
+	nDPListReturns struct {
+		result1 []netlink.Neigh
+		result2 error
+	}
+	nDPListReturnsOnCall map[int]struct {
+		result1 []netlink.Neigh
+		result2 error
+	}
+	QdiscListStub        func(netlink.Link) ([]netlink.Qdisc, error)
+	qdiscListMutex       sync.RWMutex
+	qdiscListArgsForCall []struct {
+		arg1 netlink.Link
+	}
+	qdiscListReturns struct {
+		result1 []netlink.Qdisc
+		result2 error
+	}
+	qdiscListReturnsOnCall map[int]struct {
+		result1 []netlink.Qdisc
+		result2 error
+	}
+	RouteListStub        func(netlink.Link, int) ([]netlink.Route, error)
+	routeListMutex       sync.RWMutex
+	routeListArgsForCall []struct {
+		arg1 netlink.Link
+		arg2 int
+	}
+	routeListReturns struct {
+		result1 []netlink.Route
+		result2 error
+	}
+	routeListReturnsOnCall map[int]struct {
+		result1 []netlink.Route
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *CheckNetlinkAdapter) ARPList(arg1 int) ([]netlink.Neigh, error) {
+	fake.aRPListMutex.Lock()
+	ret, specificReturn := fake.aRPListReturnsOnCall[len(fake.aRPListArgsForCall)]
+	fake.aRPListArgsForCall = append(fake.aRPListArgsForCall, struct {
+		arg1 int
+	}{arg1})
+	stub := fake.ARPListStub
+	fakeReturns := fake.aRPListReturns
+	fake.recordInvocation("ARPList", []interface{}{arg1})
+	fake.aRPListMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *CheckNetlinkAdapter) ARPListCallCount() int {
+	fake.aRPListMutex.RLock()
+	defer fake.aRPListMutex.RUnlock()
+	return len(fake.aRPListArgsForCall)
+}
+
+func (fake *CheckNetlinkAdapter) ARPListCalls(stub func(int) ([]netlink.Neigh, error)) {
+	fake.aRPListMutex.Lock()
+	defer fake.aRPListMutex.Unlock()
+	fake.ARPListStub = stub
+}
+
+func (fake *CheckNetlinkAdapter) ARPListArgsForCall(i int) int {
+	fake.aRPListMutex.RLock()
+	defer fake.aRPListMutex.RUnlock()
+	argsForCall := fake.aRPListArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *CheckNetlinkAdapter) ARPListReturns(result1 []netlink.Neigh, result2 error) {
+	fake.aRPListMutex.Lock()
+	defer fake.aRPListMutex.Unlock()
+	fake.ARPListStub = nil
+	fake.aRPListReturns = struct {
+		result1 []netlink.Neigh
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *CheckNetlinkAdapter) ARPListReturnsOnCall(i int, result1 []netlink.Neigh, result2 error) {
+	fake.aRPListMutex.Lock()
+	defer fake.aRPListMutex.Unlock()
+	fake.ARPListStub = nil
+	if fake.aRPListReturnsOnCall == nil {
+		fake.aRPListReturnsOnCall = make(map[int]struct {
+			result1 []netlink.Neigh
+			result2 error
+		})
+	}
+	fake.aRPListReturnsOnCall[i] = struct {
+		result1 []netlink.Neigh
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *CheckNetlinkAdapter) AddrList(arg1 netlink.Link, arg2 int) ([]netlink.Addr, error) {
+	fake.addrListMutex.Lock()
+	ret, specificReturn := fake.addrListReturnsOnCall[len(fake.addrListArgsForCall)]
+	fake.addrListArgsForCall = append(fake.addrListArgsForCall, struct {
+		arg1 netlink.Link
+		arg2 int
+	}{arg1, arg2})
+	stub := fake.AddrListStub
+	fakeReturns := fake.addrListReturns
+	fake.recordInvocation("AddrList", []interface{}{arg1, arg2})
+	fake.addrListMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *CheckNetlinkAdapter) AddrListCallCount() int {
+	fake.addrListMutex.RLock()
+	defer fake.addrListMutex.RUnlock()
+	return len(fake.addrListArgsForCall)
+}
+
+func (fake *CheckNetlinkAdapter) AddrListCalls(stub func(netlink.Link, int) ([]netlink.Addr, error)) {
+	fake.addrListMutex.Lock()
+	defer fake.addrListMutex.Unlock()
+	fake.AddrListStub = stub
+}
+
+func (fake *CheckNetlinkAdapter) AddrListArgsForCall(i int) (netlink.Link, int) {
+	fake.addrListMutex.RLock()
+	defer fake.addrListMutex.RUnlock()
+	argsForCall := fake.addrListArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *CheckNetlinkAdapter) AddrListReturns(result1 []netlink.Addr, result2 error) {
+	fake.addrListMutex.Lock()
+	defer fake.addrListMutex.Unlock()
+	fake.AddrListStub = nil
+	fake.addrListReturns = struct {
+		result1 []netlink.Addr
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *CheckNetlinkAdapter) AddrListReturnsOnCall(i int, result1 []netlink.Addr, result2 error) {
+	fake.addrListMutex.Lock()
+	defer fake.addrListMutex.Unlock()
+	fake.AddrListStub = nil
+	if fake.addrListReturnsOnCall == nil {
+		fake.addrListReturnsOnCall = make(map[int]struct {
+			result1 []netlink.Addr
+			result2 error
+		})
+	}
+	fake.addrListReturnsOnCall[i] = struct {
+		result1 []netlink.Addr
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *CheckNetlinkAdapter) LinkByName(arg1 string) (netlink.Link, error) {
+	fake.linkByNameMutex.Lock()
+	ret, specificReturn := fake.linkByNameReturnsOnCall[len(fake.linkByNameArgsForCall)]
+	fake.linkByNameArgsForCall = append(fake.linkByNameArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.LinkByNameStub
+	fakeReturns := fake.linkByNameReturns
+	fake.recordInvocation("LinkByName", []interface{}{arg1})
+	fake.linkByNameMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *CheckNetlinkAdapter) LinkByNameCallCount() int {
+	fake.linkByNameMutex.RLock()
+	defer fake.linkByNameMutex.RUnlock()
+	return len(fake.linkByNameArgsForCall)
+}
+
+func (fake *CheckNetlinkAdapter) LinkByNameCalls(stub func(string) (netlink.Link, error)) {
+	fake.linkByNameMutex.Lock()
+	defer fake.linkByNameMutex.Unlock()
+	fake.LinkByNameStub = stub
+}
+
+func (fake *CheckNetlinkAdapter) LinkByNameArgsForCall(i int) string {
+	fake.linkByNameMutex.RLock()
+	defer fake.linkByNameMutex.RUnlock()
+	argsForCall := fake.linkByNameArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *CheckNetlinkAdapter) LinkByNameReturns(result1 netlink.Link, result2 error) {
+	fake.linkByNameMutex.Lock()
+	defer fake.linkByNameMutex.Unlock()
+	fake.LinkByNameStub = nil
+	fake.linkByNameReturns = struct {
+		result1 netlink.Link
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *CheckNetlinkAdapter) LinkByNameReturnsOnCall(i int, result1 netlink.Link, result2 error) {
+	fake.linkByNameMutex.Lock()
+	defer fake.linkByNameMutex.Unlock()
+	fake.LinkByNameStub = nil
+	if fake.linkByNameReturnsOnCall == nil {
+		fake.linkByNameReturnsOnCall = make(map[int]struct {
+			result1 netlink.Link
+			result2 error
+		})
+	}
+	fake.linkByNameReturnsOnCall[i] = struct {
+		result1 netlink.Link
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *CheckNetlinkAdapter) NDPList(arg1 int) ([]netlink.Neigh, error) {
+	fake.nDPListMutex.Lock()
+	ret, specificReturn := fake.nDPListReturnsOnCall[len(fake.nDPListArgsForCall)]
+	fake.nDPListArgsForCall = append(fake.nDPListArgsForCall, struct {
+		arg1 int
+	}{arg1})
+	stub := fake.NDPListStub
+	fakeReturns := fake.nDPListReturns
+	fake.recordInvocation("NDPList", []interface{}{arg1})
+	fake.nDPListMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *CheckNetlinkAdapter) NDPListCallCount() int {
+	fake.nDPListMutex.RLock()
+	defer fake.nDPListMutex.RUnlock()
+	return len(fake.nDPListArgsForCall)
+}
+
+func (fake *CheckNetlinkAdapter) NDPListCalls(stub func(int) ([]netlink.Neigh, error)) {
+	fake.nDPListMutex.Lock()
+	defer fake.nDPListMutex.Unlock()
+	fake.NDPListStub = stub
+}
+
+func (fake *CheckNetlinkAdapter) NDPListArgsForCall(i int) int {
+	fake.nDPListMutex.RLock()
+	defer fake.nDPListMutex.RUnlock()
+	argsForCall := fake.nDPListArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *CheckNetlinkAdapter) NDPListReturns(result1 []netlink.Neigh, result2 error) {
+	fake.nDPListMutex.Lock()
+	defer fake.nDPListMutex.Unlock()
+	fake.NDPListStub = nil
+	fake.nDPListReturns = struct {
+		result1 []netlink.Neigh
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *CheckNetlinkAdapter) NDPListReturnsOnCall(i int, result1 []netlink.Neigh, result2 error) {
+	fake.nDPListMutex.Lock()
+	defer fake.nDPListMutex.Unlock()
+	fake.NDPListStub = nil
+	if fake.nDPListReturnsOnCall == nil {
+		fake.nDPListReturnsOnCall = make(map[int]struct {
+			result1 []netlink.Neigh
+			result2 error
+		})
+	}
+	fake.nDPListReturnsOnCall[i] = struct {
+		result1 []netlink.Neigh
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *CheckNetlinkAdapter) QdiscList(arg1 netlink.Link) ([]netlink.Qdisc, error) {
+	fake.qdiscListMutex.Lock()
+	ret, specificReturn := fake.qdiscListReturnsOnCall[len(fake.qdiscListArgsForCall)]
+	fake.qdiscListArgsForCall = append(fake.qdiscListArgsForCall, struct {
+		arg1 netlink.Link
+	}{arg1})
+	stub := fake.QdiscListStub
+	fakeReturns := fake.qdiscListReturns
+	fake.recordInvocation("QdiscList", []interface{}{arg1})
+	fake.qdiscListMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *CheckNetlinkAdapter) QdiscListCallCount() int {
+	fake.qdiscListMutex.RLock()
+	defer fake.qdiscListMutex.RUnlock()
+	return len(fake.qdiscListArgsForCall)
+}
+
+func (fake *CheckNetlinkAdapter) QdiscListCalls(stub func(netlink.Link) ([]netlink.Qdisc, error)) {
+	fake.qdiscListMutex.Lock()
+	defer fake.qdiscListMutex.Unlock()
+	fake.QdiscListStub = stub
+}
+
+func (fake *CheckNetlinkAdapter) QdiscListArgsForCall(i int) netlink.Link {
+	fake.qdiscListMutex.RLock()
+	defer fake.qdiscListMutex.RUnlock()
+	argsForCall := fake.qdiscListArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *CheckNetlinkAdapter) QdiscListReturns(result1 []netlink.Qdisc, result2 error) {
+	fake.qdiscListMutex.Lock()
+	defer fake.qdiscListMutex.Unlock()
+	fake.QdiscListStub = nil
+	fake.qdiscListReturns = struct {
+		result1 []netlink.Qdisc
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *CheckNetlinkAdapter) QdiscListReturnsOnCall(i int, result1 []netlink.Qdisc, result2 error) {
+	fake.qdiscListMutex.Lock()
+	defer fake.qdiscListMutex.Unlock()
+	fake.QdiscListStub = nil
+	if fake.qdiscListReturnsOnCall == nil {
+		fake.qdiscListReturnsOnCall = make(map[int]struct {
+			result1 []netlink.Qdisc
+			result2 error
+		})
+	}
+	fake.qdiscListReturnsOnCall[i] = struct {
+		result1 []netlink.Qdisc
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *CheckNetlinkAdapter) RouteList(arg1 netlink.Link, arg2 int) ([]netlink.Route, error) {
+	fake.routeListMutex.Lock()
+	ret, specificReturn := fake.routeListReturnsOnCall[len(fake.routeListArgsForCall)]
+	fake.routeListArgsForCall = append(fake.routeListArgsForCall, struct {
+		arg1 netlink.Link
+		arg2 int
+	}{arg1, arg2})
+	stub := fake.RouteListStub
+	fakeReturns := fake.routeListReturns
+	fake.recordInvocation("RouteList", []interface{}{arg1, arg2})
+	fake.routeListMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *CheckNetlinkAdapter) RouteListCallCount() int {
+	fake.routeListMutex.RLock()
+	defer fake.routeListMutex.RUnlock()
+	return len(fake.routeListArgsForCall)
+}
+
+func (fake *CheckNetlinkAdapter) RouteListCalls(stub func(netlink.Link, int) ([]netlink.Route, error)) {
+	fake.routeListMutex.Lock()
+	defer fake.routeListMutex.Unlock()
+	fake.RouteListStub = stub
+}
+
+func (fake *CheckNetlinkAdapter) RouteListArgsForCall(i int) (netlink.Link, int) {
+	fake.routeListMutex.RLock()
+	defer fake.routeListMutex.RUnlock()
+	argsForCall := fake.routeListArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *CheckNetlinkAdapter) RouteListReturns(result1 []netlink.Route, result2 error) {
+	fake.routeListMutex.Lock()
+	defer fake.routeListMutex.Unlock()
+	fake.RouteListStub = nil
+	fake.routeListReturns = struct {
+		result1 []netlink.Route
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *CheckNetlinkAdapter) RouteListReturnsOnCall(i int, result1 []netlink.Route, result2 error) {
+	fake.routeListMutex.Lock()
+	defer fake.routeListMutex.Unlock()
+	fake.RouteListStub = nil
+	if fake.routeListReturnsOnCall == nil {
+		fake.routeListReturnsOnCall = make(map[int]struct {
+			result1 []netlink.Route
+			result2 error
+		})
+	}
+	fake.routeListReturnsOnCall[i] = struct {
+		result1 []netlink.Route
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *CheckNetlinkAdapter) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.aRPListMutex.RLock()
+	defer fake.aRPListMutex.RUnlock()
+	fake.addrListMutex.RLock()
+	defer fake.addrListMutex.RUnlock()
+	fake.linkByNameMutex.RLock()
+	defer fake.linkByNameMutex.RUnlock()
+	fake.nDPListMutex.RLock()
+	defer fake.nDPListMutex.RUnlock()
+	fake.qdiscListMutex.RLock()
+	defer fake.qdiscListMutex.RUnlock()
+	fake.routeListMutex.RLock()
+	defer fake.routeListMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *CheckNetlinkAdapter) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
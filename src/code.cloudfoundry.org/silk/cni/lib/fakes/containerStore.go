@@ -0,0 +1,195 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/silk/lib/datastore"
+)
+
+type ContainerStore struct {
+	DeleteStub        func(string, string) (datastore.Container, error)
+	deleteMutex       sync.RWMutex
+	deleteArgsForCall []struct {
+		arg1 string
+		arg2 string
+	}
+	deleteReturns struct {
+		result1 datastore.Container
+		result2 error
+	}
+	deleteReturnsOnCall map[int]struct {
+		result1 datastore.Container
+		result2 error
+	}
+	ReadAllStub        func(string) (map[string]datastore.Container, error)
+	readAllMutex       sync.RWMutex
+	readAllArgsForCall []struct {
+		arg1 string
+	}
+	readAllReturns struct {
+		result1 map[string]datastore.Container
+		result2 error
+	}
+	readAllReturnsOnCall map[int]struct {
+		result1 map[string]datastore.Container
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *ContainerStore) Delete(arg1 string, arg2 string) (datastore.Container, error) {
+	fake.deleteMutex.Lock()
+	ret, specificReturn := fake.deleteReturnsOnCall[len(fake.deleteArgsForCall)]
+	fake.deleteArgsForCall = append(fake.deleteArgsForCall, struct {
+		arg1 string
+		arg2 string
+	}{arg1, arg2})
+	stub := fake.DeleteStub
+	fakeReturns := fake.deleteReturns
+	fake.recordInvocation("Delete", []interface{}{arg1, arg2})
+	fake.deleteMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *ContainerStore) DeleteCallCount() int {
+	fake.deleteMutex.RLock()
+	defer fake.deleteMutex.RUnlock()
+	return len(fake.deleteArgsForCall)
+}
+
+func (fake *ContainerStore) DeleteCalls(stub func(string, string) (datastore.Container, error)) {
+	fake.deleteMutex.Lock()
+	defer fake.deleteMutex.Unlock()
+	fake.DeleteStub = stub
+}
+
+func (fake *ContainerStore) DeleteArgsForCall(i int) (string, string) {
+	fake.deleteMutex.RLock()
+	defer fake.deleteMutex.RUnlock()
+	argsForCall := fake.deleteArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *ContainerStore) DeleteReturns(result1 datastore.Container, result2 error) {
+	fake.deleteMutex.Lock()
+	defer fake.deleteMutex.Unlock()
+	fake.DeleteStub = nil
+	fake.deleteReturns = struct {
+		result1 datastore.Container
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *ContainerStore) DeleteReturnsOnCall(i int, result1 datastore.Container, result2 error) {
+	fake.deleteMutex.Lock()
+	defer fake.deleteMutex.Unlock()
+	fake.DeleteStub = nil
+	if fake.deleteReturnsOnCall == nil {
+		fake.deleteReturnsOnCall = make(map[int]struct {
+			result1 datastore.Container
+			result2 error
+		})
+	}
+	fake.deleteReturnsOnCall[i] = struct {
+		result1 datastore.Container
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *ContainerStore) ReadAll(arg1 string) (map[string]datastore.Container, error) {
+	fake.readAllMutex.Lock()
+	ret, specificReturn := fake.readAllReturnsOnCall[len(fake.readAllArgsForCall)]
+	fake.readAllArgsForCall = append(fake.readAllArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.ReadAllStub
+	fakeReturns := fake.readAllReturns
+	fake.recordInvocation("ReadAll", []interface{}{arg1})
+	fake.readAllMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *ContainerStore) ReadAllCallCount() int {
+	fake.readAllMutex.RLock()
+	defer fake.readAllMutex.RUnlock()
+	return len(fake.readAllArgsForCall)
+}
+
+func (fake *ContainerStore) ReadAllCalls(stub func(string) (map[string]datastore.Container, error)) {
+	fake.readAllMutex.Lock()
+	defer fake.readAllMutex.Unlock()
+	fake.ReadAllStub = stub
+}
+
+func (fake *ContainerStore) ReadAllArgsForCall(i int) string {
+	fake.readAllMutex.RLock()
+	defer fake.readAllMutex.RUnlock()
+	argsForCall := fake.readAllArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *ContainerStore) ReadAllReturns(result1 map[string]datastore.Container, result2 error) {
+	fake.readAllMutex.Lock()
+	defer fake.readAllMutex.Unlock()
+	fake.ReadAllStub = nil
+	fake.readAllReturns = struct {
+		result1 map[string]datastore.Container
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *ContainerStore) ReadAllReturnsOnCall(i int, result1 map[string]datastore.Container, result2 error) {
+	fake.readAllMutex.Lock()
+	defer fake.readAllMutex.Unlock()
+	fake.ReadAllStub = nil
+	if fake.readAllReturnsOnCall == nil {
+		fake.readAllReturnsOnCall = make(map[int]struct {
+			result1 map[string]datastore.Container
+			result2 error
+		})
+	}
+	fake.readAllReturnsOnCall[i] = struct {
+		result1 map[string]datastore.Container
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *ContainerStore) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.deleteMutex.RLock()
+	defer fake.deleteMutex.RUnlock()
+	fake.readAllMutex.RLock()
+	defer fake.readAllMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *ContainerStore) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
@@ -0,0 +1,192 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"net"
+	"sync"
+)
+
+type HostDeviceNamer struct {
+	GenerateForHostStub        func(net.IP) (string, error)
+	generateForHostMutex       sync.RWMutex
+	generateForHostArgsForCall []struct {
+		arg1 net.IP
+	}
+	generateForHostReturns struct {
+		result1 string
+		result2 error
+	}
+	generateForHostReturnsOnCall map[int]struct {
+		result1 string
+		result2 error
+	}
+	GenerateForHostIFBStub        func(net.IP) (string, error)
+	generateForHostIFBMutex       sync.RWMutex
+	generateForHostIFBArgsForCall []struct {
+		arg1 net.IP
+	}
+	generateForHostIFBReturns struct {
+		result1 string
+		result2 error
+	}
+	generateForHostIFBReturnsOnCall map[int]struct {
+		result1 string
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *HostDeviceNamer) GenerateForHost(arg1 net.IP) (string, error) {
+	fake.generateForHostMutex.Lock()
+	ret, specificReturn := fake.generateForHostReturnsOnCall[len(fake.generateForHostArgsForCall)]
+	fake.generateForHostArgsForCall = append(fake.generateForHostArgsForCall, struct {
+		arg1 net.IP
+	}{arg1})
+	stub := fake.GenerateForHostStub
+	fakeReturns := fake.generateForHostReturns
+	fake.recordInvocation("GenerateForHost", []interface{}{arg1})
+	fake.generateForHostMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *HostDeviceNamer) GenerateForHostCallCount() int {
+	fake.generateForHostMutex.RLock()
+	defer fake.generateForHostMutex.RUnlock()
+	return len(fake.generateForHostArgsForCall)
+}
+
+func (fake *HostDeviceNamer) GenerateForHostCalls(stub func(net.IP) (string, error)) {
+	fake.generateForHostMutex.Lock()
+	defer fake.generateForHostMutex.Unlock()
+	fake.GenerateForHostStub = stub
+}
+
+func (fake *HostDeviceNamer) GenerateForHostArgsForCall(i int) net.IP {
+	fake.generateForHostMutex.RLock()
+	defer fake.generateForHostMutex.RUnlock()
+	argsForCall := fake.generateForHostArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *HostDeviceNamer) GenerateForHostReturns(result1 string, result2 error) {
+	fake.generateForHostMutex.Lock()
+	defer fake.generateForHostMutex.Unlock()
+	fake.GenerateForHostStub = nil
+	fake.generateForHostReturns = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *HostDeviceNamer) GenerateForHostReturnsOnCall(i int, result1 string, result2 error) {
+	fake.generateForHostMutex.Lock()
+	defer fake.generateForHostMutex.Unlock()
+	fake.GenerateForHostStub = nil
+	if fake.generateForHostReturnsOnCall == nil {
+		fake.generateForHostReturnsOnCall = make(map[int]struct {
+			result1 string
+			result2 error
+		})
+	}
+	fake.generateForHostReturnsOnCall[i] = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *HostDeviceNamer) GenerateForHostIFB(arg1 net.IP) (string, error) {
+	fake.generateForHostIFBMutex.Lock()
+	ret, specificReturn := fake.generateForHostIFBReturnsOnCall[len(fake.generateForHostIFBArgsForCall)]
+	fake.generateForHostIFBArgsForCall = append(fake.generateForHostIFBArgsForCall, struct {
+		arg1 net.IP
+	}{arg1})
+	stub := fake.GenerateForHostIFBStub
+	fakeReturns := fake.generateForHostIFBReturns
+	fake.recordInvocation("GenerateForHostIFB", []interface{}{arg1})
+	fake.generateForHostIFBMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *HostDeviceNamer) GenerateForHostIFBCallCount() int {
+	fake.generateForHostIFBMutex.RLock()
+	defer fake.generateForHostIFBMutex.RUnlock()
+	return len(fake.generateForHostIFBArgsForCall)
+}
+
+func (fake *HostDeviceNamer) GenerateForHostIFBCalls(stub func(net.IP) (string, error)) {
+	fake.generateForHostIFBMutex.Lock()
+	defer fake.generateForHostIFBMutex.Unlock()
+	fake.GenerateForHostIFBStub = stub
+}
+
+func (fake *HostDeviceNamer) GenerateForHostIFBArgsForCall(i int) net.IP {
+	fake.generateForHostIFBMutex.RLock()
+	defer fake.generateForHostIFBMutex.RUnlock()
+	argsForCall := fake.generateForHostIFBArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *HostDeviceNamer) GenerateForHostIFBReturns(result1 string, result2 error) {
+	fake.generateForHostIFBMutex.Lock()
+	defer fake.generateForHostIFBMutex.Unlock()
+	fake.GenerateForHostIFBStub = nil
+	fake.generateForHostIFBReturns = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *HostDeviceNamer) GenerateForHostIFBReturnsOnCall(i int, result1 string, result2 error) {
+	fake.generateForHostIFBMutex.Lock()
+	defer fake.generateForHostIFBMutex.Unlock()
+	fake.GenerateForHostIFBStub = nil
+	if fake.generateForHostIFBReturnsOnCall == nil {
+		fake.generateForHostIFBReturnsOnCall = make(map[int]struct {
+			result1 string
+			result2 error
+		})
+	}
+	fake.generateForHostIFBReturnsOnCall[i] = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *HostDeviceNamer) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.generateForHostMutex.RLock()
+	defer fake.generateForHostMutex.RUnlock()
+	fake.generateForHostIFBMutex.RLock()
+	defer fake.generateForHostIFBMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *HostDeviceNamer) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
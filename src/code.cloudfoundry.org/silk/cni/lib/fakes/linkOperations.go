@@ -5,6 +5,7 @@ import (
 	"net"
 	"sync"
 
+	"code.cloudfoundry.org/silk/cni/config"
 	"github.com/containernetworking/cni/pkg/types"
 	"github.com/vishvananda/netlink"
 )
@@ -42,6 +43,27 @@ type LinkOperations struct {
 	enableIPv4ForwardingReturnsOnCall map[int]struct {
 		result1 error
 	}
+	EnableIPv6Stub        func(string) error
+	enableIPv6Mutex       sync.RWMutex
+	enableIPv6ArgsForCall []struct {
+		arg1 string
+	}
+	enableIPv6Returns struct {
+		result1 error
+	}
+	enableIPv6ReturnsOnCall map[int]struct {
+		result1 error
+	}
+	EnableIPv6ForwardingStub        func() error
+	enableIPv6ForwardingMutex       sync.RWMutex
+	enableIPv6ForwardingArgsForCall []struct {
+	}
+	enableIPv6ForwardingReturns struct {
+		result1 error
+	}
+	enableIPv6ForwardingReturnsOnCall map[int]struct {
+		result1 error
+	}
 	EnableReversePathFilteringStub        func(string) error
 	enableReversePathFilteringMutex       sync.RWMutex
 	enableReversePathFilteringArgsForCall []struct {
@@ -65,11 +87,12 @@ type LinkOperations struct {
 	renameLinkReturnsOnCall map[int]struct {
 		result1 error
 	}
-	RouteAddAllStub        func([]*types.Route, net.IP) error
+	RouteAddAllStub        func([]*types.Route, string, config.DualAddress) error
 	routeAddAllMutex       sync.RWMutex
 	routeAddAllArgsForCall []struct {
 		arg1 []*types.Route
-		arg2 net.IP
+		arg2 string
+		arg3 config.DualAddress
 	}
 	routeAddAllReturns struct {
 		result1 error
@@ -90,6 +113,18 @@ type LinkOperations struct {
 	setPointToPointAddressReturnsOnCall map[int]struct {
 		result1 error
 	}
+	SetSysctlStub        func(string, string) error
+	setSysctlMutex       sync.RWMutex
+	setSysctlArgsForCall []struct {
+		arg1 string
+		arg2 string
+	}
+	setSysctlReturns struct {
+		result1 error
+	}
+	setSysctlReturnsOnCall map[int]struct {
+		result1 error
+	}
 	StaticNeighborNoARPStub        func(netlink.Link, net.IP, net.HardwareAddr) error
 	staticNeighborNoARPMutex       sync.RWMutex
 	staticNeighborNoARPArgsForCall []struct {
@@ -282,6 +317,120 @@ func (fake *LinkOperations) EnableIPv4ForwardingReturnsOnCall(i int, result1 err
 	}{result1}
 }
 
+func (fake *LinkOperations) EnableIPv6(arg1 string) error {
+	fake.enableIPv6Mutex.Lock()
+	ret, specificReturn := fake.enableIPv6ReturnsOnCall[len(fake.enableIPv6ArgsForCall)]
+	fake.enableIPv6ArgsForCall = append(fake.enableIPv6ArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.EnableIPv6Stub
+	fakeReturns := fake.enableIPv6Returns
+	fake.recordInvocation("EnableIPv6", []interface{}{arg1})
+	fake.enableIPv6Mutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *LinkOperations) EnableIPv6CallCount() int {
+	fake.enableIPv6Mutex.RLock()
+	defer fake.enableIPv6Mutex.RUnlock()
+	return len(fake.enableIPv6ArgsForCall)
+}
+
+func (fake *LinkOperations) EnableIPv6Calls(stub func(string) error) {
+	fake.enableIPv6Mutex.Lock()
+	defer fake.enableIPv6Mutex.Unlock()
+	fake.EnableIPv6Stub = stub
+}
+
+func (fake *LinkOperations) EnableIPv6ArgsForCall(i int) string {
+	fake.enableIPv6Mutex.RLock()
+	defer fake.enableIPv6Mutex.RUnlock()
+	argsForCall := fake.enableIPv6ArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *LinkOperations) EnableIPv6Returns(result1 error) {
+	fake.enableIPv6Mutex.Lock()
+	defer fake.enableIPv6Mutex.Unlock()
+	fake.EnableIPv6Stub = nil
+	fake.enableIPv6Returns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *LinkOperations) EnableIPv6ReturnsOnCall(i int, result1 error) {
+	fake.enableIPv6Mutex.Lock()
+	defer fake.enableIPv6Mutex.Unlock()
+	fake.EnableIPv6Stub = nil
+	if fake.enableIPv6ReturnsOnCall == nil {
+		fake.enableIPv6ReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.enableIPv6ReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *LinkOperations) EnableIPv6Forwarding() error {
+	fake.enableIPv6ForwardingMutex.Lock()
+	ret, specificReturn := fake.enableIPv6ForwardingReturnsOnCall[len(fake.enableIPv6ForwardingArgsForCall)]
+	fake.enableIPv6ForwardingArgsForCall = append(fake.enableIPv6ForwardingArgsForCall, struct {
+	}{})
+	stub := fake.EnableIPv6ForwardingStub
+	fakeReturns := fake.enableIPv6ForwardingReturns
+	fake.recordInvocation("EnableIPv6Forwarding", []interface{}{})
+	fake.enableIPv6ForwardingMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *LinkOperations) EnableIPv6ForwardingCallCount() int {
+	fake.enableIPv6ForwardingMutex.RLock()
+	defer fake.enableIPv6ForwardingMutex.RUnlock()
+	return len(fake.enableIPv6ForwardingArgsForCall)
+}
+
+func (fake *LinkOperations) EnableIPv6ForwardingCalls(stub func() error) {
+	fake.enableIPv6ForwardingMutex.Lock()
+	defer fake.enableIPv6ForwardingMutex.Unlock()
+	fake.EnableIPv6ForwardingStub = stub
+}
+
+func (fake *LinkOperations) EnableIPv6ForwardingReturns(result1 error) {
+	fake.enableIPv6ForwardingMutex.Lock()
+	defer fake.enableIPv6ForwardingMutex.Unlock()
+	fake.EnableIPv6ForwardingStub = nil
+	fake.enableIPv6ForwardingReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *LinkOperations) EnableIPv6ForwardingReturnsOnCall(i int, result1 error) {
+	fake.enableIPv6ForwardingMutex.Lock()
+	defer fake.enableIPv6ForwardingMutex.Unlock()
+	fake.EnableIPv6ForwardingStub = nil
+	if fake.enableIPv6ForwardingReturnsOnCall == nil {
+		fake.enableIPv6ForwardingReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.enableIPv6ForwardingReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *LinkOperations) EnableReversePathFiltering(arg1 string) error {
 	fake.enableReversePathFilteringMutex.Lock()
 	ret, specificReturn := fake.enableReversePathFilteringReturnsOnCall[len(fake.enableReversePathFilteringArgsForCall)]
@@ -405,7 +554,7 @@ func (fake *LinkOperations) RenameLinkReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
-func (fake *LinkOperations) RouteAddAll(arg1 []*types.Route, arg2 net.IP) error {
+func (fake *LinkOperations) RouteAddAll(arg1 []*types.Route, arg2 string, arg3 config.DualAddress) error {
 	var arg1Copy []*types.Route
 	if arg1 != nil {
 		arg1Copy = make([]*types.Route, len(arg1))
@@ -415,14 +564,15 @@ func (fake *LinkOperations) RouteAddAll(arg1 []*types.Route, arg2 net.IP) error
 	ret, specificReturn := fake.routeAddAllReturnsOnCall[len(fake.routeAddAllArgsForCall)]
 	fake.routeAddAllArgsForCall = append(fake.routeAddAllArgsForCall, struct {
 		arg1 []*types.Route
-		arg2 net.IP
-	}{arg1Copy, arg2})
+		arg2 string
+		arg3 config.DualAddress
+	}{arg1Copy, arg2, arg3})
 	stub := fake.RouteAddAllStub
 	fakeReturns := fake.routeAddAllReturns
-	fake.recordInvocation("RouteAddAll", []interface{}{arg1Copy, arg2})
+	fake.recordInvocation("RouteAddAll", []interface{}{arg1Copy, arg2, arg3})
 	fake.routeAddAllMutex.Unlock()
 	if stub != nil {
-		return stub(arg1, arg2)
+		return stub(arg1, arg2, arg3)
 	}
 	if specificReturn {
 		return ret.result1
@@ -436,17 +586,17 @@ func (fake *LinkOperations) RouteAddAllCallCount() int {
 	return len(fake.routeAddAllArgsForCall)
 }
 
-func (fake *LinkOperations) RouteAddAllCalls(stub func([]*types.Route, net.IP) error) {
+func (fake *LinkOperations) RouteAddAllCalls(stub func([]*types.Route, string, config.DualAddress) error) {
 	fake.routeAddAllMutex.Lock()
 	defer fake.routeAddAllMutex.Unlock()
 	fake.RouteAddAllStub = stub
 }
 
-func (fake *LinkOperations) RouteAddAllArgsForCall(i int) ([]*types.Route, net.IP) {
+func (fake *LinkOperations) RouteAddAllArgsForCall(i int) ([]*types.Route, string, config.DualAddress) {
 	fake.routeAddAllMutex.RLock()
 	defer fake.routeAddAllMutex.RUnlock()
 	argsForCall := fake.routeAddAllArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
 }
 
 func (fake *LinkOperations) RouteAddAllReturns(result1 error) {
@@ -535,6 +685,68 @@ func (fake *LinkOperations) SetPointToPointAddressReturnsOnCall(i int, result1 e
 	}{result1}
 }
 
+func (fake *LinkOperations) SetSysctl(arg1 string, arg2 string) error {
+	fake.setSysctlMutex.Lock()
+	ret, specificReturn := fake.setSysctlReturnsOnCall[len(fake.setSysctlArgsForCall)]
+	fake.setSysctlArgsForCall = append(fake.setSysctlArgsForCall, struct {
+		arg1 string
+		arg2 string
+	}{arg1, arg2})
+	stub := fake.SetSysctlStub
+	fakeReturns := fake.setSysctlReturns
+	fake.recordInvocation("SetSysctl", []interface{}{arg1, arg2})
+	fake.setSysctlMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *LinkOperations) SetSysctlCallCount() int {
+	fake.setSysctlMutex.RLock()
+	defer fake.setSysctlMutex.RUnlock()
+	return len(fake.setSysctlArgsForCall)
+}
+
+func (fake *LinkOperations) SetSysctlCalls(stub func(string, string) error) {
+	fake.setSysctlMutex.Lock()
+	defer fake.setSysctlMutex.Unlock()
+	fake.SetSysctlStub = stub
+}
+
+func (fake *LinkOperations) SetSysctlArgsForCall(i int) (string, string) {
+	fake.setSysctlMutex.RLock()
+	defer fake.setSysctlMutex.RUnlock()
+	argsForCall := fake.setSysctlArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *LinkOperations) SetSysctlReturns(result1 error) {
+	fake.setSysctlMutex.Lock()
+	defer fake.setSysctlMutex.Unlock()
+	fake.SetSysctlStub = nil
+	fake.setSysctlReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *LinkOperations) SetSysctlReturnsOnCall(i int, result1 error) {
+	fake.setSysctlMutex.Lock()
+	defer fake.setSysctlMutex.Unlock()
+	fake.SetSysctlStub = nil
+	if fake.setSysctlReturnsOnCall == nil {
+		fake.setSysctlReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.setSysctlReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *LinkOperations) StaticNeighborNoARP(arg1 netlink.Link, arg2 net.IP, arg3 net.HardwareAddr) error {
 	fake.staticNeighborNoARPMutex.Lock()
 	ret, specificReturn := fake.staticNeighborNoARPReturnsOnCall[len(fake.staticNeighborNoARPArgsForCall)]
@@ -607,6 +819,10 @@ func (fake *LinkOperations) Invocations() map[string][][]interface{} {
 	defer fake.disableIPv6Mutex.RUnlock()
 	fake.enableIPv4ForwardingMutex.RLock()
 	defer fake.enableIPv4ForwardingMutex.RUnlock()
+	fake.enableIPv6Mutex.RLock()
+	defer fake.enableIPv6Mutex.RUnlock()
+	fake.enableIPv6ForwardingMutex.RLock()
+	defer fake.enableIPv6ForwardingMutex.RUnlock()
 	fake.enableReversePathFilteringMutex.RLock()
 	defer fake.enableReversePathFilteringMutex.RUnlock()
 	fake.renameLinkMutex.RLock()
@@ -615,6 +831,8 @@ func (fake *LinkOperations) Invocations() map[string][][]interface{} {
 	defer fake.routeAddAllMutex.RUnlock()
 	fake.setPointToPointAddressMutex.RLock()
 	defer fake.setPointToPointAddressMutex.RUnlock()
+	fake.setSysctlMutex.RLock()
+	defer fake.setSysctlMutex.RUnlock()
 	fake.staticNeighborNoARPMutex.RLock()
 	defer fake.staticNeighborNoARPMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
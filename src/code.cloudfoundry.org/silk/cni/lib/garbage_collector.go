@@ -0,0 +1,118 @@
+package lib
+
+import (
+	"fmt"
+	"net"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/silk/cni/ipam"
+	"code.cloudfoundry.org/silk/lib/datastore"
+	"github.com/containernetworking/cni/pkg/types"
+	"github.com/hashicorp/go-multierror"
+)
+
+//go:generate counterfeiter -o fakes/allocationCollector.go --fake-name AllocationCollector . allocationCollector
+type allocationCollector interface {
+	Collect(network ipam.Network, keep func(ipam.Allocation) bool) (map[string]ipam.Allocation, map[string]ipam.Allocation, error)
+}
+
+//go:generate counterfeiter -o fakes/containerStore.go --fake-name ContainerStore . containerStore
+type containerStore interface {
+	ReadAll(filePath string) (map[string]datastore.Container, error)
+	Delete(filePath, handle string) (datastore.Container, error)
+}
+
+//go:generate counterfeiter -o fakes/hostDeviceNamer.go --fake-name HostDeviceNamer . hostDeviceNamer
+type hostDeviceNamer interface {
+	GenerateForHost(containerIP net.IP) (string, error)
+	GenerateForHostIFB(containerIP net.IP) (string, error)
+}
+
+// GarbageCollector removes the state left behind by container attachments
+// that the runtime no longer considers valid: IPAM allocations, datastore
+// entries, and host side veth and IFB devices.
+type GarbageCollector struct {
+	IPAM                allocationCollector
+	Store               containerStore
+	LinkOperations      linkOperations
+	DeviceNameGenerator hostDeviceNamer
+	Logger              lager.Logger
+}
+
+// Collect releases the allocations in networks that do not belong to one of
+// the valid attachments, then removes the datastore entries and host devices
+// of every container IP that is no longer allocated. Once the allocations
+// are known, failures do not stop the cleanup and are returned together.
+func (g *GarbageCollector) Collect(networks []ipam.Network, datastorePath string, valid []types.GCAttachment) error {
+	g.Logger.Debug("start")
+	defer g.Logger.Debug("done")
+
+	validAttachments := map[types.GCAttachment]bool{}
+	validContainers := map[string]bool{}
+	for _, attachment := range valid {
+		validAttachments[attachment] = true
+		validContainers[attachment.ContainerID] = true
+	}
+	keep := func(allocation ipam.Allocation) bool {
+		// allocations migrated from host-local may not know their interface
+		if allocation.IfName == "" {
+			return validContainers[allocation.ContainerID]
+		}
+		return validAttachments[types.GCAttachment{ContainerID: allocation.ContainerID, IfName: allocation.IfName}]
+	}
+
+	allocated := map[string]bool{}
+	released := map[string]bool{}
+	for _, network := range networks {
+		releasedAllocations, keptAllocations, err := g.IPAM.Collect(network, keep)
+		if err != nil {
+			// without the allocations it is unknown which containers are still in use
+			return fmt.Errorf("collect ipam allocations in %s: %s", network.Name, err)
+		}
+		for ip, allocation := range releasedAllocations {
+			g.Logger.Info("released-allocation", lager.Data{"network": network.Name, "ip": ip, "container_id": allocation.ContainerID, "ifname": allocation.IfName})
+			released[ip] = true
+		}
+		for ip := range keptAllocations {
+			allocated[ip] = true
+		}
+	}
+
+	var result error
+	containers, err := g.Store.ReadAll(datastorePath)
+	if err != nil {
+		result = multierror.Append(result, fmt.Errorf("read container metadata: %s", err))
+	}
+	for handle, container := range containers {
+		if allocated[container.IP] {
+			continue
+		}
+		_, err := g.Store.Delete(datastorePath, handle)
+		if err != nil {
+			result = multierror.Append(result, fmt.Errorf("delete container metadata for %s: %s", handle, err))
+			continue
+		}
+		g.Logger.Info("deleted-container-metadata", lager.Data{"handle": handle, "ip": container.IP})
+		released[container.IP] = true
+	}
+
+	for ip := range released {
+		for _, generate := range []func(net.IP) (string, error){
+			g.DeviceNameGenerator.GenerateForHost,
+			g.DeviceNameGenerator.GenerateForHostIFB,
+		} {
+			deviceName, err := generate(net.ParseIP(ip))
+			if err != nil {
+				result = multierror.Append(result, fmt.Errorf("generate host device name for %s: %s", ip, err))
+				continue
+			}
+			// a missing device is not an error, it is removed with the container namespace
+			err = g.LinkOperations.DeleteLinkByName(deviceName)
+			if err != nil {
+				result = multierror.Append(result, fmt.Errorf("delete host device %s: %s", deviceName, err))
+			}
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,151 @@
+package lib_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/silk/cni/config"
+	"code.cloudfoundry.org/silk/cni/ipam"
+	"code.cloudfoundry.org/silk/cni/lib"
+	"code.cloudfoundry.org/silk/cni/lib/fakes"
+	"code.cloudfoundry.org/silk/lib/datastore"
+	"github.com/containernetworking/cni/pkg/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GarbageCollector", func() {
+	var (
+		allocations    *fakes.AllocationCollector
+		store          *fakes.ContainerStore
+		linkOperations *fakes.LinkOperations
+		collector      *lib.GarbageCollector
+		networks       []ipam.Network
+		valid          []types.GCAttachment
+	)
+
+	BeforeEach(func() {
+		allocations = &fakes.AllocationCollector{}
+		store = &fakes.ContainerStore{}
+		linkOperations = &fakes.LinkOperations{}
+
+		allocations.CollectReturns(
+			map[string]ipam.Allocation{"10.255.30.3": {ContainerID: "invalid-container", IfName: "eth0"}},
+			map[string]ipam.Allocation{"10.255.30.2": {ContainerID: "valid-container", IfName: "eth0"}},
+			nil,
+		)
+		store.ReadAllReturns(map[string]datastore.Container{
+			"valid-handle":    {Handle: "valid-handle", IP: "10.255.30.2"},
+			"invalid-handle":  {Handle: "invalid-handle", IP: "10.255.30.3"},
+			"orphaned-handle": {Handle: "orphaned-handle", IP: "10.255.30.9"},
+		}, nil)
+
+		networks = []ipam.Network{
+			{Name: "my-network", DataDir: "/some/data/dir"},
+			{Name: "my-network-isolated", DataDir: "/some/data/dir"},
+		}
+		valid = []types.GCAttachment{{ContainerID: "valid-container", IfName: "eth0"}}
+
+		collector = &lib.GarbageCollector{
+			IPAM:                allocations,
+			Store:               store,
+			LinkOperations:      linkOperations,
+			DeviceNameGenerator: &config.DeviceNameGenerator{},
+			Logger:              lagertest.NewTestLogger("test"),
+		}
+	})
+
+	It("collects the allocations of every network, keeping the valid attachments", func() {
+		Expect(collector.Collect(networks, "/some/datastore.json", valid)).To(Succeed())
+
+		Expect(allocations.CollectCallCount()).To(Equal(2))
+		network, keep := allocations.CollectArgsForCall(0)
+		Expect(network).To(Equal(networks[0]))
+		Expect(keep(ipam.Allocation{ContainerID: "valid-container", IfName: "eth0"})).To(BeTrue())
+		Expect(keep(ipam.Allocation{ContainerID: "valid-container", IfName: "eth1"})).To(BeFalse())
+		Expect(keep(ipam.Allocation{ContainerID: "invalid-container", IfName: "eth0"})).To(BeFalse())
+		network, _ = allocations.CollectArgsForCall(1)
+		Expect(network).To(Equal(networks[1]))
+	})
+
+	It("keeps allocations without an interface name when their container is valid", func() {
+		Expect(collector.Collect(networks, "/some/datastore.json", valid)).To(Succeed())
+
+		_, keep := allocations.CollectArgsForCall(0)
+		Expect(keep(ipam.Allocation{ContainerID: "valid-container"})).To(BeTrue())
+		Expect(keep(ipam.Allocation{ContainerID: "invalid-container"})).To(BeFalse())
+	})
+
+	It("deletes the datastore entries of ips that are no longer allocated", func() {
+		Expect(collector.Collect(networks, "/some/datastore.json", valid)).To(Succeed())
+
+		Expect(store.ReadAllCallCount()).To(Equal(1))
+		Expect(store.ReadAllArgsForCall(0)).To(Equal("/some/datastore.json"))
+
+		Expect(store.DeleteCallCount()).To(Equal(2))
+		var handles []string
+		for i := 0; i < store.DeleteCallCount(); i++ {
+			path, handle := store.DeleteArgsForCall(i)
+			Expect(path).To(Equal("/some/datastore.json"))
+			handles = append(handles, handle)
+		}
+		Expect(handles).To(ConsistOf("invalid-handle", "orphaned-handle"))
+	})
+
+	It("deletes the host veth and ifb devices of the released ips", func() {
+		Expect(collector.Collect(networks, "/some/datastore.json", valid)).To(Succeed())
+
+		var devices []string
+		for i := 0; i < linkOperations.DeleteLinkByNameCallCount(); i++ {
+			devices = append(devices, linkOperations.DeleteLinkByNameArgsForCall(i))
+		}
+		Expect(devices).To(ConsistOf("s-010255030003", "i-010255030003", "s-010255030009", "i-010255030009"))
+	})
+
+	Context("when collecting the allocations fails", func() {
+		BeforeEach(func() {
+			allocations.CollectReturns(nil, nil, errors.New("potato"))
+		})
+
+		It("returns the error without touching the containers", func() {
+			err := collector.Collect(networks, "/some/datastore.json", valid)
+			Expect(err).To(MatchError("collect ipam allocations in my-network: potato"))
+
+			Expect(store.ReadAllCallCount()).To(Equal(0))
+			Expect(linkOperations.DeleteLinkByNameCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when reading the datastore fails", func() {
+		BeforeEach(func() {
+			store.ReadAllReturns(nil, errors.New("potato"))
+		})
+
+		It("still deletes the devices of the released allocations and returns the error", func() {
+			err := collector.Collect(networks, "/some/datastore.json", valid)
+			Expect(err).To(MatchError(ContainSubstring("read container metadata: potato")))
+
+			Expect(linkOperations.DeleteLinkByNameCallCount()).To(Equal(2))
+			Expect(linkOperations.DeleteLinkByNameArgsForCall(0)).To(Equal("s-010255030003"))
+			Expect(linkOperations.DeleteLinkByNameArgsForCall(1)).To(Equal("i-010255030003"))
+		})
+	})
+
+	Context("when deleting an entry or a device fails", func() {
+		BeforeEach(func() {
+			store.DeleteReturns(datastore.Container{}, errors.New("banana"))
+			linkOperations.DeleteLinkByNameReturns(errors.New("kiwi"))
+		})
+
+		It("keeps going and returns every error", func() {
+			err := collector.Collect(networks, "/some/datastore.json", valid)
+			Expect(err).To(MatchError(ContainSubstring("delete container metadata for invalid-handle: banana")))
+			Expect(err).To(MatchError(ContainSubstring("delete container metadata for orphaned-handle: banana")))
+			Expect(err).To(MatchError(ContainSubstring("delete host device s-010255030003: kiwi")))
+			Expect(err).To(MatchError(ContainSubstring("delete host device i-010255030003: kiwi")))
+
+			Expect(store.DeleteCallCount()).To(Equal(2))
+			Expect(linkOperations.DeleteLinkByNameCallCount()).To(Equal(2))
+		})
+	})
+})
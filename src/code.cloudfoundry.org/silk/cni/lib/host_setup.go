@@ -31,6 +31,12 @@ func (h *Host) Setup(cfg *config.Config) error {
 		if err := h.LinkOperations.EnableIPv4Forwarding(); err != nil {
 			return fmt.Errorf("enabling packet forwarding on host: %s", err)
 		}
+
+		if local.IPv6 != nil {
+			if err := h.LinkOperations.EnableIPv6Forwarding(); err != nil {
+				return fmt.Errorf("enabling IPv6 packet forwarding on host: %s", err)
+			}
+		}
 		return nil
 	})
 }
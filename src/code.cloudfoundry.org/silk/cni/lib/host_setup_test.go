@@ -66,6 +66,30 @@ var _ = Describe("Host Setup", func() {
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(fakeLinkOperations.EnableIPv4ForwardingCallCount()).To(Equal(1))
+			Expect(fakeLinkOperations.EnableIPv6ForwardingCallCount()).To(Equal(0))
+		})
+
+		Context("when the host address is dual-stack", func() {
+			BeforeEach(func() {
+				cfg.Host.Address.IPv6 = net.ParseIP("fe80::1")
+			})
+
+			It("enables IPv6 forwarding on the host", func() {
+				err := hostSetup.Setup(cfg)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeLinkOperations.EnableIPv6ForwardingCallCount()).To(Equal(1))
+			})
+
+			Context("when enabling IPv6 packet forwarding fails", func() {
+				BeforeEach(func() {
+					fakeLinkOperations.EnableIPv6ForwardingReturns(errors.New("beans"))
+				})
+				It("returns a meaningful error", func() {
+					err := hostSetup.Setup(cfg)
+					Expect(err).To(MatchError("enabling IPv6 packet forwarding on host: beans"))
+				})
+			})
 		})
 
 		Context("when the basic device setup fails", func() {
@@ -12,13 +12,16 @@ import (
 //go:generate counterfeiter -o fakes/linkOperations.go --fake-name LinkOperations . linkOperations
 type linkOperations interface {
 	DisableIPv6(deviceName string) error
+	EnableIPv6(deviceName string) error
 	StaticNeighborNoARP(link netlink.Link, dstIP net.IP, mac net.HardwareAddr) error
 	SetPointToPointAddress(link netlink.Link, localIPAddr, peerIPAddr net.IP) error
 	RenameLink(oldName, newName string) error
 	DeleteLinkByName(deviceName string) error
-	RouteAddAll(route []*types.Route, sourceIP net.IP) error
+	RouteAddAll(route []*types.Route, deviceName string, local config.DualAddress) error
 	EnableIPv4Forwarding() error
+	EnableIPv6Forwarding() error
 	EnableReversePathFiltering(deviceName string) error
+	SetSysctl(name, value string) error
 }
 
 //go:generate counterfeiter -o fakes/common.go --fake-name Common . common
@@ -33,6 +36,7 @@ type netlinkAdapter interface {
 	AddrAddScopeLink(netlink.Link, *netlink.Addr) error
 	LinkSetHardwareAddr(netlink.Link, net.HardwareAddr) error
 	NeighAddPermanentIPv4(index int, destIP net.IP, hwAddr net.HardwareAddr) error
+	NeighAddPermanentIPv6(index int, destIP net.IP, hwAddr net.HardwareAddr) error
 	LinkSetARPOff(netlink.Link) error
 	LinkSetName(netlink.Link, string) error
 	LinkSetUp(netlink.Link) error
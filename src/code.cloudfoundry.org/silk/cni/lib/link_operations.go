@@ -5,6 +5,7 @@ import (
 	"net"
 
 	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/silk/cni/config"
 
 	"github.com/containernetworking/cni/pkg/types"
 	"github.com/vishvananda/netlink"
@@ -26,6 +27,14 @@ func (s *LinkOperations) DisableIPv6(deviceName string) error {
 	return nil
 }
 
+func (s *LinkOperations) EnableIPv6(deviceName string) error {
+	_, err := s.SysctlAdapter.Sysctl(fmt.Sprintf("net.ipv6.conf.%s.disable_ipv6", deviceName), "0")
+	if err != nil {
+		return fmt.Errorf("sysctl for %s: %s", deviceName, err)
+	}
+	return nil
+}
+
 func (s *LinkOperations) EnableReversePathFiltering(deviceName string) error {
 	_, err := s.SysctlAdapter.Sysctl(fmt.Sprintf("net.ipv4.conf.%s.rp_filter", deviceName), "1")
 	if err != nil {
@@ -42,15 +51,36 @@ func (s *LinkOperations) EnableIPv4Forwarding() error {
 	return nil
 }
 
-// StaticNeighborNoARP disables ARP on the link and installs a single permanent neighbor rule
-// that resolves the given destIP to the given hardware address
+func (s *LinkOperations) EnableIPv6Forwarding() error {
+	_, err := s.SysctlAdapter.Sysctl("net.ipv6.conf.all.forwarding", "1")
+	if err != nil {
+		return fmt.Errorf("enabling IPv6 forwarding: %s", err)
+	}
+	return nil
+}
+
+// SetSysctl sets a sysctl of the current namespace.
+func (s *LinkOperations) SetSysctl(name, value string) error {
+	_, err := s.SysctlAdapter.Sysctl(name, value)
+	if err != nil {
+		return fmt.Errorf("sysctl %s: %s", name, err)
+	}
+	return nil
+}
+
+// StaticNeighborNoARP disables ARP and neighbor discovery on the link and installs a single
+// permanent neighbor rule that resolves the given destIP to the given hardware address
 func (s *LinkOperations) StaticNeighborNoARP(link netlink.Link, destIP net.IP, hwAddr net.HardwareAddr) error {
 	err := s.NetlinkAdapter.LinkSetARPOff(link)
 	if err != nil {
 		return fmt.Errorf("set ARP off: %s", err)
 	}
 
-	err = s.NetlinkAdapter.NeighAddPermanentIPv4(link.Attrs().Index, destIP, hwAddr)
+	if destIP.To4() == nil {
+		err = s.NetlinkAdapter.NeighAddPermanentIPv6(link.Attrs().Index, destIP, hwAddr)
+	} else {
+		err = s.NetlinkAdapter.NeighAddPermanentIPv4(link.Attrs().Index, destIP, hwAddr)
+	}
 	if err != nil {
 		return fmt.Errorf("neigh add: %s", err)
 	}
@@ -59,13 +89,17 @@ func (s *LinkOperations) StaticNeighborNoARP(link netlink.Link, destIP net.IP, h
 }
 
 func (s *LinkOperations) SetPointToPointAddress(link netlink.Link, localIPAddr, peerIPAddr net.IP) error {
+	mask := net.CIDRMask(32, 32)
+	if localIPAddr.To4() == nil {
+		mask = net.CIDRMask(128, 128)
+	}
 	localAddr := &net.IPNet{
 		IP:   localIPAddr,
-		Mask: []byte{255, 255, 255, 255},
+		Mask: mask,
 	}
 	peerAddr := &net.IPNet{
 		IP:   peerIPAddr,
-		Mask: []byte{255, 255, 255, 255},
+		Mask: mask,
 	}
 	addr, err := s.NetlinkAdapter.ParseAddr(localAddr.String())
 	if err != nil {
@@ -110,13 +144,25 @@ func (s *LinkOperations) DeleteLinkByName(deviceName string) error {
 	return s.NetlinkAdapter.LinkDel(link)
 }
 
-func (s *LinkOperations) RouteAddAll(routes []*types.Route, sourceIP net.IP) error {
+// RouteAddAll adds the routes through the named device. The source of each
+// route is the local address of the same family.
+func (s *LinkOperations) RouteAddAll(routes []*types.Route, deviceName string, local config.DualAddress) error {
+	link, err := s.NetlinkAdapter.LinkByName(deviceName)
+	if err != nil {
+		return fmt.Errorf("failed to find link %q: %s", deviceName, err)
+	}
+
 	for _, r := range routes {
 		dst := r.Dst
+		sourceIP := local.IP
+		if dst.IP.To4() == nil {
+			sourceIP = local.IPv6
+		}
 		err := s.NetlinkAdapter.RouteAdd(&netlink.Route{
-			Src: sourceIP,
-			Dst: &dst,
-			Gw:  r.GW,
+			LinkIndex: link.Attrs().Index,
+			Src:       sourceIP,
+			Dst:       &dst,
+			Gw:        r.GW,
 		})
 		if err != nil {
 			return fmt.Errorf("adding route: %s", err)
@@ -6,6 +6,7 @@ import (
 
 	"code.cloudfoundry.org/lager/v3/lagertest"
 
+	"code.cloudfoundry.org/silk/cni/config"
 	"code.cloudfoundry.org/silk/cni/lib"
 	"code.cloudfoundry.org/silk/cni/lib/fakes"
 	"github.com/containernetworking/cni/pkg/types"
@@ -23,6 +24,8 @@ var _ = Describe("Link Operations", func() {
 		fakeLink           netlink.Link
 		ipAddr             net.IP
 		peerIP             net.IP
+		ipv6Addr           net.IP
+		peerIPv6           net.IP
 		hwAddr             net.HardwareAddr
 		routes             []*types.Route
 		logger             *lagertest.TestLogger
@@ -47,6 +50,8 @@ var _ = Describe("Link Operations", func() {
 		var err error
 		ipAddr = net.IP{10, 255, 30, 4}
 		peerIP = net.IP{169, 254, 0, 1}
+		ipv6Addr = net.ParseIP("fd00:5111::aff:1e04")
+		peerIPv6 = net.ParseIP("fe80::1")
 		hwAddr, err = net.ParseMAC("aa:aa:12:34:56:78")
 		Expect(err).NotTo(HaveOccurred())
 
@@ -98,6 +103,28 @@ var _ = Describe("Link Operations", func() {
 		})
 	})
 
+	Describe("EnableIPv6", func() {
+		It("calls the sysctl adapter to enable IPv6", func() {
+			err := linkOperations.EnableIPv6("someDevice")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeSysctlAdapter.SysctlCallCount()).To(Equal(1))
+			name, params := fakeSysctlAdapter.SysctlArgsForCall(0)
+			Expect(name).To(Equal("net.ipv6.conf.someDevice.disable_ipv6"))
+			Expect(params).To(Equal([]string{"0"}))
+		})
+
+		Context("when the sysctl fails", func() {
+			BeforeEach(func() {
+				fakeSysctlAdapter.SysctlReturns("", errors.New("cuttlefish"))
+			})
+			It("returns a meaningful error", func() {
+				err := linkOperations.EnableIPv6("someDevice")
+				Expect(err).To(MatchError("sysctl for someDevice: cuttlefish"))
+			})
+		})
+	})
+
 	Describe("EnableReversePathFiltering", func() {
 		It("calls the sysctl adapter to set rp_filtering to strict mode", func() {
 			err := linkOperations.EnableReversePathFiltering("someDevice")
@@ -144,6 +171,50 @@ var _ = Describe("Link Operations", func() {
 		})
 	})
 
+	Describe("SetSysctl", func() {
+		It("calls the sysctl adapter to set the sysctl", func() {
+			err := linkOperations.SetSysctl("net.core.somaxconn", "1024")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeSysctlAdapter.SysctlCallCount()).To(Equal(1))
+			name, params := fakeSysctlAdapter.SysctlArgsForCall(0)
+			Expect(name).To(Equal("net.core.somaxconn"))
+			Expect(params).To(Equal([]string{"1024"}))
+		})
+
+		Context("when the sysctl command fails", func() {
+			BeforeEach(func() {
+				fakeSysctlAdapter.SysctlReturns("", errors.New("cuttlefish"))
+			})
+			It("returns a meaningful error", func() {
+				err := linkOperations.SetSysctl("net.core.somaxconn", "1024")
+				Expect(err).To(MatchError("sysctl net.core.somaxconn: cuttlefish"))
+			})
+		})
+	})
+
+	Describe("EnableIPv6Forwarding", func() {
+		It("calls the sysctl adapter to enable IPv6 forwarding", func() {
+			err := linkOperations.EnableIPv6Forwarding()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeSysctlAdapter.SysctlCallCount()).To(Equal(1))
+			name, params := fakeSysctlAdapter.SysctlArgsForCall(0)
+			Expect(name).To(Equal("net.ipv6.conf.all.forwarding"))
+			Expect(params).To(Equal([]string{"1"}))
+		})
+
+		Context("when the sysctl fails", func() {
+			BeforeEach(func() {
+				fakeSysctlAdapter.SysctlReturns("", errors.New("cuttlefish"))
+			})
+			It("returns a meaningful error", func() {
+				err := linkOperations.EnableIPv6Forwarding()
+				Expect(err).To(MatchError("enabling IPv6 forwarding: cuttlefish"))
+			})
+		})
+	})
+
 	Describe("StaticNeighborNoARP", func() {
 		It("calls the netlink adapter to disable ARP", func() {
 			err := linkOperations.StaticNeighborNoARP(fakeLink, ipAddr, hwAddr)
@@ -164,6 +235,20 @@ var _ = Describe("Link Operations", func() {
 			Expect(destHardwareAddr).To(Equal(hwAddr))
 		})
 
+		Context("when the destination is an IPv6 address", func() {
+			It("installs an IPv6 permanent neighbor rule", func() {
+				err := linkOperations.StaticNeighborNoARP(fakeLink, peerIPv6, hwAddr)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeNetlinkAdapter.NeighAddPermanentIPv4CallCount()).To(Equal(0))
+				Expect(fakeNetlinkAdapter.NeighAddPermanentIPv6CallCount()).To(Equal(1))
+				index, destIP, destHardwareAddr := fakeNetlinkAdapter.NeighAddPermanentIPv6ArgsForCall(0)
+				Expect(index).To(Equal(42))
+				Expect(destIP).To(Equal(peerIPv6))
+				Expect(destHardwareAddr).To(Equal(hwAddr))
+			})
+		})
+
 		Context("when disabling ARP fails", func() {
 			BeforeEach(func() {
 				fakeNetlinkAdapter.LinkSetARPOffReturns(errors.New("shrimp"))
@@ -211,6 +296,17 @@ var _ = Describe("Link Operations", func() {
 			Expect(addr).To(Equal(ptpAddr))
 		})
 
+		Context("when the addresses are IPv6 addresses", func() {
+			It("sets the peer IP address with a /128 prefix", func() {
+				err := linkOperations.SetPointToPointAddress(fakeLink, ipv6Addr, peerIPv6)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeNetlinkAdapter.ParseAddrArgsForCall(0)).To(Equal("fd00:5111::aff:1e04/128"))
+				_, addr := fakeNetlinkAdapter.AddrAddScopeLinkArgsForCall(0)
+				Expect(addr.Peer.String()).To(Equal("fe80::1/128"))
+			})
+		})
+
 		Context("when parsing the IP address fails", func() {
 			BeforeEach(func() {
 				fakeNetlinkAdapter.ParseAddrReturns(nil, errors.New("lobster"))
@@ -316,15 +412,17 @@ var _ = Describe("Link Operations", func() {
 
 	Describe("RouteAddAll", func() {
 		BeforeEach(func() {
+			fakeNetlinkAdapter.LinkByNameReturns(fakeLink, nil)
 			fakeNetlinkAdapter.RouteAddReturns(nil)
 		})
 		It("adds all routes", func() {
-			err := linkOperations.RouteAddAll(routes, ipAddr)
+			err := linkOperations.RouteAddAll(routes, "eth0", config.DualAddress{IP: ipAddr, IPv6: ipv6Addr})
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(fakeNetlinkAdapter.RouteAddCallCount()).To(Equal(3))
 			Expect(fakeNetlinkAdapter.RouteAddArgsForCall(0)).To(Equal(&netlink.Route{
-				Src: ipAddr,
+				LinkIndex: 42,
+				Src:       ipAddr,
 				Dst: &net.IPNet{
 					IP:   []byte{200, 201, 202, 203},
 					Mask: []byte{255, 255, 255, 255},
@@ -332,7 +430,8 @@ var _ = Describe("Link Operations", func() {
 				Gw: net.IP{10, 255, 30, 2},
 			}))
 			Expect(fakeNetlinkAdapter.RouteAddArgsForCall(1)).To(Equal(&netlink.Route{
-				Src: ipAddr,
+				LinkIndex: 42,
+				Src:       ipAddr,
 				Dst: &net.IPNet{
 					IP:   []byte{100, 101, 102, 103},
 					Mask: []byte{255, 255, 255, 255},
@@ -340,7 +439,8 @@ var _ = Describe("Link Operations", func() {
 				Gw: net.IP{10, 255, 30, 1},
 			}))
 			Expect(fakeNetlinkAdapter.RouteAddArgsForCall(2)).To(Equal(&netlink.Route{
-				Src: ipAddr,
+				LinkIndex: 42,
+				Src:       ipAddr,
 				Dst: &net.IPNet{
 					IP:   []byte{0, 1, 2, 3},
 					Mask: []byte{255, 255, 255, 255},
@@ -349,6 +449,44 @@ var _ = Describe("Link Operations", func() {
 			}))
 		})
 
+		Context("when there are IPv6 routes", func() {
+			BeforeEach(func() {
+				routes = append(routes, &types.Route{
+					Dst: net.IPNet{
+						IP:   net.IPv6zero,
+						Mask: net.CIDRMask(0, 128),
+					},
+					GW: peerIPv6,
+				})
+			})
+
+			It("adds them with the IPv6 source address", func() {
+				err := linkOperations.RouteAddAll(routes, "eth0", config.DualAddress{IP: ipAddr, IPv6: ipv6Addr})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeNetlinkAdapter.RouteAddCallCount()).To(Equal(4))
+				Expect(fakeNetlinkAdapter.RouteAddArgsForCall(3)).To(Equal(&netlink.Route{
+					LinkIndex: 42,
+					Src:       ipv6Addr,
+					Dst: &net.IPNet{
+						IP:   net.IPv6zero,
+						Mask: net.CIDRMask(0, 128),
+					},
+					Gw: peerIPv6,
+				}))
+			})
+		})
+
+		Context("when the device cannot be found", func() {
+			BeforeEach(func() {
+				fakeNetlinkAdapter.LinkByNameReturns(nil, errors.New("pickle"))
+			})
+			It("returns a meaningful error", func() {
+				err := linkOperations.RouteAddAll(routes, "eth0", config.DualAddress{IP: ipAddr})
+				Expect(err).To(MatchError(`failed to find link "eth0": pickle`))
+			})
+		})
+
 		Context("when adding one of the routes fails", func() {
 			BeforeEach(func() {
 				fakeNetlinkAdapter.RouteAddStub = func(route *netlink.Route) error {
@@ -359,7 +497,7 @@ var _ = Describe("Link Operations", func() {
 				}
 			})
 			It("returns a meaningful error", func() {
-				err := linkOperations.RouteAddAll(routes, ipAddr)
+				err := linkOperations.RouteAddAll(routes, "eth0", config.DualAddress{IP: ipAddr, IPv6: ipv6Addr})
 				Expect(err).To(MatchError("adding route: pickle"))
 
 				Expect(fakeNetlinkAdapter.RouteAddCallCount()).To(Equal(2))
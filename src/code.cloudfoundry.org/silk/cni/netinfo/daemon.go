@@ -20,3 +20,13 @@ func (d *Daemon) Get() (daemon.NetworkInfo, error) {
 
 	return *info, nil
 }
+
+// Ready returns an error when silk-daemon is unreachable or reports that it
+// is not ready.
+func (d *Daemon) Ready() error {
+	err := d.JSONClient.Do("GET", "/health/ready", nil, &struct{}{}, "")
+	if err != nil {
+		return fmt.Errorf("json client do: %s", err)
+	}
+	return nil
+}
@@ -53,4 +53,29 @@ var _ = Describe("Daemon Netinfo", func() {
 			Expect(err).To(MatchError("json client do: banana"))
 		})
 	})
+
+	Describe("Ready", func() {
+		BeforeEach(func() {
+			fakeJsonClient.DoStub = nil
+		})
+
+		It("gets the readiness endpoint of the daemon", func() {
+			Expect(daemonNetInfo.Ready()).To(Succeed())
+
+			Expect(fakeJsonClient.DoCallCount()).To(Equal(1))
+			method, route, reqData, _, _ := fakeJsonClient.DoArgsForCall(0)
+			Expect(method).To(Equal("GET"))
+			Expect(route).To(Equal("/health/ready"))
+			Expect(reqData).To(BeNil())
+		})
+
+		Context("when the daemon is not ready", func() {
+			BeforeEach(func() {
+				fakeJsonClient.DoReturns(errors.New("http status 503: not ready"))
+			})
+			It("returns the error", func() {
+				Expect(daemonNetInfo.Ready()).To(MatchError("json client do: http status 503: not ready"))
+			})
+		})
+	})
 })
@@ -15,12 +15,23 @@ type Discoverer struct {
 	NetInfo netInfo
 }
 
-func (d *Discoverer) Discover(mtu int) (daemon.NetworkInfo, error) {
+// Discover returns the network info of the named overlay, or of the default
+// overlay when the name is empty.
+func (d *Discoverer) Discover(mtu int, overlay string) (daemon.NetworkInfo, error) {
 	info, err := d.NetInfo.Get()
 	if err != nil {
 		return daemon.NetworkInfo{}, fmt.Errorf("get netinfo: %s", err)
 	}
 
+	if overlay != "" {
+		overlayInfo, ok := info.Overlays[overlay]
+		if !ok {
+			return daemon.NetworkInfo{}, fmt.Errorf("unknown overlay: %s", overlay)
+		}
+		info = overlayInfo
+	}
+	info.Overlays = nil
+
 	if mtu != 0 {
 		info.MTU = mtu
 	}
@@ -30,7 +30,7 @@ var _ = Describe("Discover", func() {
 
 	Context("when it is called with zero MTU", func() {
 		It("gets the netinfo and sets the MTU based on the netinfo", func() {
-			info, err := discoverer.Discover(0)
+			info, err := discoverer.Discover(0, "")
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(fakeNetInfo.GetCallCount()).To(Equal(1))
@@ -43,7 +43,7 @@ var _ = Describe("Discover", func() {
 
 	Context("when it is called with non-zero MTU", func() {
 		It("overrides the MTU from the netinfo", func() {
-			info, err := discoverer.Discover(42)
+			info, err := discoverer.Discover(42, "")
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(fakeNetInfo.GetCallCount()).To(Equal(1))
@@ -59,8 +59,48 @@ var _ = Describe("Discover", func() {
 			fakeNetInfo.GetReturns(daemon.NetworkInfo{}, errors.New("banana"))
 		})
 		It("returns an error", func() {
-			_, err := discoverer.Discover(42)
+			_, err := discoverer.Discover(42, "")
 			Expect(err).To(MatchError("get netinfo: banana"))
 		})
 	})
+
+	Context("when an overlay is named", func() {
+		BeforeEach(func() {
+			fakeNetInfo.GetReturns(daemon.NetworkInfo{
+				OverlaySubnet: "1.2.3.4/23",
+				MTU:           4321,
+				Overlays: map[string]daemon.NetworkInfo{
+					"isolated": {
+						OverlaySubnet: "5.6.7.8/24",
+						MTU:           1234,
+					},
+				},
+			}, nil)
+		})
+
+		It("returns the netinfo of that overlay", func() {
+			info, err := discoverer.Discover(0, "isolated")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info).To(Equal(daemon.NetworkInfo{
+				OverlaySubnet: "5.6.7.8/24",
+				MTU:           1234,
+			}))
+		})
+
+		It("does not include the other overlays in the default netinfo", func() {
+			info, err := discoverer.Discover(0, "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info).To(Equal(daemon.NetworkInfo{
+				OverlaySubnet: "1.2.3.4/23",
+				MTU:           4321,
+			}))
+		})
+
+		Context("when the overlay is unknown", func() {
+			It("returns an error", func() {
+				_, err := discoverer.Discover(0, "banana")
+				Expect(err).To(MatchError("unknown overlay: banana"))
+			})
+		})
+	})
 })
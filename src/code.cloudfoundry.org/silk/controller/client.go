@@ -19,9 +19,9 @@ type Client struct {
 }
 
 type Lease struct {
-	UnderlayIP          string `json:"underlay_ip"`
-	OverlaySubnet       string `json:"overlay_subnet"`
-	OverlayHardwareAddr string `json:"overlay_hardware_addr"`
+	UnderlayIP          string `json:"underlay_ip" yaml:"underlay_ip"`
+	OverlaySubnet       string `json:"overlay_subnet" yaml:"overlay_subnet"`
+	OverlayHardwareAddr string `json:"overlay_hardware_addr" yaml:"overlay_hardware_addr"`
 }
 
 type ReleaseLeaseRequest struct {
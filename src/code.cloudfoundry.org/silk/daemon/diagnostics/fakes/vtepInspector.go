@@ -0,0 +1,120 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/daemon/vtep"
+)
+
+type VTEPInspector struct {
+	DiffStub        func([]controller.Lease) (vtep.Diff, error)
+	diffMutex       sync.RWMutex
+	diffArgsForCall []struct {
+		arg1 []controller.Lease
+	}
+	diffReturns struct {
+		result1 vtep.Diff
+		result2 error
+	}
+	diffReturnsOnCall map[int]struct {
+		result1 vtep.Diff
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *VTEPInspector) Diff(arg1 []controller.Lease) (vtep.Diff, error) {
+	var arg1Copy []controller.Lease
+	if arg1 != nil {
+		arg1Copy = make([]controller.Lease, len(arg1))
+		copy(arg1Copy, arg1)
+	}
+	fake.diffMutex.Lock()
+	ret, specificReturn := fake.diffReturnsOnCall[len(fake.diffArgsForCall)]
+	fake.diffArgsForCall = append(fake.diffArgsForCall, struct {
+		arg1 []controller.Lease
+	}{arg1Copy})
+	stub := fake.DiffStub
+	fakeReturns := fake.diffReturns
+	fake.recordInvocation("Diff", []interface{}{arg1Copy})
+	fake.diffMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *VTEPInspector) DiffCallCount() int {
+	fake.diffMutex.RLock()
+	defer fake.diffMutex.RUnlock()
+	return len(fake.diffArgsForCall)
+}
+
+func (fake *VTEPInspector) DiffCalls(stub func([]controller.Lease) (vtep.Diff, error)) {
+	fake.diffMutex.Lock()
+	defer fake.diffMutex.Unlock()
+	fake.DiffStub = stub
+}
+
+func (fake *VTEPInspector) DiffArgsForCall(i int) []controller.Lease {
+	fake.diffMutex.RLock()
+	defer fake.diffMutex.RUnlock()
+	argsForCall := fake.diffArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *VTEPInspector) DiffReturns(result1 vtep.Diff, result2 error) {
+	fake.diffMutex.Lock()
+	defer fake.diffMutex.Unlock()
+	fake.DiffStub = nil
+	fake.diffReturns = struct {
+		result1 vtep.Diff
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *VTEPInspector) DiffReturnsOnCall(i int, result1 vtep.Diff, result2 error) {
+	fake.diffMutex.Lock()
+	defer fake.diffMutex.Unlock()
+	fake.DiffStub = nil
+	if fake.diffReturnsOnCall == nil {
+		fake.diffReturnsOnCall = make(map[int]struct {
+			result1 vtep.Diff
+			result2 error
+		})
+	}
+	fake.diffReturnsOnCall[i] = struct {
+		result1 vtep.Diff
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *VTEPInspector) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.diffMutex.RLock()
+	defer fake.diffMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *VTEPInspector) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
@@ -0,0 +1,107 @@
+package diagnostics
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/daemon/vtep"
+)
+
+//go:generate counterfeiter -o fakes/renewalTracker.go --fake-name RenewalTracker . renewalTracker
+type renewalTracker interface {
+	SuccessStreak() int
+	LastSuccess() time.Time
+}
+
+//go:generate counterfeiter -o fakes/vtepInspector.go --fake-name VTEPInspector . vtepInspector
+type vtepInspector interface {
+	Diff([]controller.Lease) (vtep.Diff, error)
+}
+
+// Handler serves the diagnostic views of the daemon. Every request must carry
+// the token as a bearer token.
+type Handler struct {
+	State    *State
+	Renewals renewalTracker
+	VTEP     vtepInspector
+	Token    string
+	Logger   lager.Logger
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/lease":
+		h.respond(w, struct {
+			Lease controller.Lease `json:"lease"`
+		}{h.State.Lease()})
+	case "/leases":
+		h.respond(w, struct {
+			Leases []controller.Lease `json:"leases"`
+		}{h.State.Leases()})
+	case "/converge":
+		h.serveConverge(w)
+	case "/renewals":
+		h.respond(w, struct {
+			SuccessStreak int       `json:"success_streak"`
+			LastSuccess   time.Time `json:"last_success_time"`
+		}{h.Renewals.SuccessStreak(), h.Renewals.LastSuccess()})
+	case "/vtep":
+		diff, err := h.VTEP.Diff(h.State.Leases())
+		if err != nil {
+			h.Logger.Error("vtep-diff", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			h.respond(w, struct {
+				Error string `json:"error"`
+			}{err.Error()})
+			return
+		}
+		h.respond(w, diff)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (h *Handler) serveConverge(w http.ResponseWriter) {
+	lastConvergeTime, lastConvergeError := h.State.LastConverge()
+	response := struct {
+		LastConvergeTime  *time.Time `json:"last_converge_time"`
+		LastConvergeError string     `json:"last_converge_error,omitempty"`
+	}{}
+	if !lastConvergeTime.IsZero() {
+		response.LastConvergeTime = &lastConvergeTime
+	}
+	if lastConvergeError != nil {
+		response.LastConvergeError = lastConvergeError.Error()
+	}
+	h.respond(w, response)
+}
+
+func (h *Handler) authorized(r *http.Request) bool {
+	expected := []byte("Bearer " + h.Token)
+	actual := []byte(r.Header.Get("Authorization"))
+	return h.Token != "" && subtle.ConstantTimeCompare(expected, actual) == 1
+}
+
+func (h *Handler) respond(w http.ResponseWriter, response interface{}) {
+	bytes, err := json.Marshal(response)
+	if err != nil {
+		h.Logger.Error("marshal-response", err) // not possible
+		return
+	}
+	// #nosec G104 - ignore errors when writing HTTP responses so we don't spam our logs during a DoS
+	w.Write(bytes)
+}
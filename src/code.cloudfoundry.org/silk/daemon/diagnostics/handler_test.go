@@ -0,0 +1,229 @@
+package diagnostics_test
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	clientConfig "code.cloudfoundry.org/silk/client/config"
+	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/daemon/diagnostics"
+	"code.cloudfoundry.org/silk/daemon/diagnostics/fakes"
+	"code.cloudfoundry.org/silk/daemon/vtep"
+	vtepfakes "code.cloudfoundry.org/silk/daemon/vtep/fakes"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Handler", func() {
+	var (
+		handler        *diagnostics.Handler
+		state          *diagnostics.State
+		renewals       *fakes.RenewalTracker
+		vtepInspector  *fakes.VTEPInspector
+		lease          controller.Lease
+		leases         []controller.Lease
+		lastSuccess    time.Time
+		request        *http.Request
+		responseWriter *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		state = &diagnostics.State{}
+		renewals = &fakes.RenewalTracker{}
+		vtepInspector = &fakes.VTEPInspector{}
+		handler = &diagnostics.Handler{
+			State:    state,
+			Renewals: renewals,
+			VTEP:     vtepInspector,
+			Token:    "some-token",
+			Logger:   lagertest.NewTestLogger("test"),
+		}
+
+		lease = controller.Lease{
+			UnderlayIP:          "10.10.0.4",
+			OverlaySubnet:       "10.255.32.0/24",
+			OverlayHardwareAddr: "ee:ee:0a:ff:20:00",
+		}
+		leases = []controller.Lease{lease, {
+			UnderlayIP:          "10.10.0.5",
+			OverlaySubnet:       "10.255.19.0/24",
+			OverlayHardwareAddr: "ee:ee:0a:ff:13:00",
+		}}
+		lastSuccess = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		renewals.SuccessStreakReturns(7)
+		renewals.LastSuccessReturns(lastSuccess)
+
+		responseWriter = httptest.NewRecorder()
+	})
+
+	serve := func(path string) {
+		request = httptest.NewRequest("GET", path, nil)
+		request.Header.Set("Authorization", "Bearer some-token")
+		handler.ServeHTTP(responseWriter, request)
+	}
+
+	It("serves the current lease", func() {
+		state.RecordConverge(lease, leases, nil)
+		serve("/lease")
+		Expect(responseWriter.Code).To(Equal(http.StatusOK))
+		Expect(responseWriter.Body.String()).To(MatchJSON(`{
+			"lease": {
+				"underlay_ip": "10.10.0.4",
+				"overlay_subnet": "10.255.32.0/24",
+				"overlay_hardware_addr": "ee:ee:0a:ff:20:00"
+			}
+		}`))
+	})
+
+	It("serves the peer leases", func() {
+		state.RecordConverge(lease, leases, nil)
+		serve("/leases")
+		Expect(responseWriter.Code).To(Equal(http.StatusOK))
+		Expect(responseWriter.Body.String()).To(MatchJSON(`{
+			"leases": [
+				{ "underlay_ip": "10.10.0.4", "overlay_subnet": "10.255.32.0/24", "overlay_hardware_addr": "ee:ee:0a:ff:20:00" },
+				{ "underlay_ip": "10.10.0.5", "overlay_subnet": "10.255.19.0/24", "overlay_hardware_addr": "ee:ee:0a:ff:13:00" }
+			]
+		}`))
+	})
+
+	Describe("/converge", func() {
+		It("serves a null time before the first converge", func() {
+			serve("/converge")
+			Expect(responseWriter.Body.String()).To(MatchJSON(`{ "last_converge_time": null }`))
+		})
+
+		It("serves the time and error of the last converge", func() {
+			state.RecordConverge(lease, leases, errors.New("banana"))
+			serve("/converge")
+			Expect(responseWriter.Code).To(Equal(http.StatusOK))
+			Expect(responseWriter.Body.String()).To(ContainSubstring(`"last_converge_error":"banana"`))
+
+			lastConvergeTime, _ := state.LastConverge()
+			Expect(lastConvergeTime).To(BeTemporally("~", time.Now(), time.Second))
+		})
+	})
+
+	It("serves the renewal success streak", func() {
+		serve("/renewals")
+		Expect(responseWriter.Code).To(Equal(http.StatusOK))
+		Expect(responseWriter.Body.String()).To(MatchJSON(`{
+			"success_streak": 7,
+			"last_success_time": "2024-01-02T03:04:05Z"
+		}`))
+	})
+
+	Describe("/vtep", func() {
+		BeforeEach(func() {
+			state.RecordConverge(lease, leases, nil)
+			vtepInspector.DiffReturns(vtep.Diff{
+				MissingRoutes: []vtep.Route{{Destination: "10.255.19.0/24", Gateway: "10.255.19.0", Source: "10.255.32.0"}},
+			}, nil)
+		})
+
+		It("serves the diff between the programmed and desired vtep state", func() {
+			serve("/vtep")
+			Expect(responseWriter.Code).To(Equal(http.StatusOK))
+			Expect(vtepInspector.DiffArgsForCall(0)).To(Equal(leases))
+			Expect(responseWriter.Body.String()).To(ContainSubstring(`"missing_routes":[{"destination":"10.255.19.0/24","gateway":"10.255.19.0","source":"10.255.32.0"}]`))
+		})
+
+		Context("when the vtep can not be inspected", func() {
+			BeforeEach(func() {
+				vtepInspector.DiffReturns(vtep.Diff{}, errors.New("banana"))
+			})
+
+			It("responds with an error", func() {
+				serve("/vtep")
+				Expect(responseWriter.Code).To(Equal(http.StatusInternalServerError))
+				Expect(responseWriter.Body.String()).To(MatchJSON(`{ "error": "banana" }`))
+			})
+		})
+
+		Context("when the lease is migrated while the vtep is inspected", func() {
+			var (
+				converger *vtep.Converger
+				migrator  *vtep.LeaseMigrator
+			)
+
+			BeforeEach(func() {
+				_, overlayNetwork, _ := net.ParseCIDR("10.255.0.0/16")
+				_, localSubnet, _ := net.ParseCIDR("10.255.32.0/24")
+				converger = &vtep.Converger{
+					OverlayNetwork: overlayNetwork,
+					LocalSubnet:    localSubnet,
+					LocalVTEP:      net.Interface{Index: 3, Name: "silk-vtep"},
+					NetlinkAdapter: &vtepfakes.NetlinkAdapter{},
+					Logger:         lagertest.NewTestLogger("test"),
+				}
+				handler.VTEP = converger
+
+				configCreator := &vtepfakes.ConfigCreator{}
+				configCreator.CreateReturns(&vtep.Config{VTEPName: "silk-vtep"}, nil)
+				netAdapter := &vtepfakes.NetAdapter{}
+				netAdapter.InterfaceByNameReturns(&net.Interface{Index: 7, Name: "silk-vtep"}, nil)
+				migrator = &vtep.LeaseMigrator{
+					ControllerClient: &vtepfakes.LeaseClient{},
+					ConfigCreator:    configCreator,
+					Factory:          &vtepfakes.VTEPFactory{},
+					NetAdapter:       netAdapter,
+					Converger:        converger,
+					ClientConfig:     clientConfig.Config{VTEPName: "silk-vtep"},
+					ContainerCount:   func() (int, error) { return 0, nil },
+					Logger:           lagertest.NewTestLogger("test"),
+				}
+			})
+
+			It("serves a consistent diff", func() {
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					defer close(done)
+					for i := 0; i < 50; i++ {
+						_, err := migrator.Migrate(lease, "10.10.0.9")
+						Expect(err).NotTo(HaveOccurred())
+					}
+				}()
+
+				for i := 0; i < 50; i++ {
+					responseWriter = httptest.NewRecorder()
+					serve("/vtep")
+					Expect(responseWriter.Code).To(Equal(http.StatusOK))
+				}
+				Eventually(done).Should(BeClosed())
+				Expect(converger.LocalVTEP.Index).To(Equal(7))
+			})
+		})
+	})
+
+	It("responds with not found for unknown views", func() {
+		serve("/banana")
+		Expect(responseWriter.Code).To(Equal(http.StatusNotFound))
+	})
+
+	Context("when the request does not carry the token", func() {
+		It("responds with unauthorized", func() {
+			request = httptest.NewRequest("GET", "/lease", nil)
+			request.Header.Set("Authorization", "Bearer wrong-token")
+			handler.ServeHTTP(responseWriter, request)
+			Expect(responseWriter.Code).To(Equal(http.StatusUnauthorized))
+		})
+	})
+
+	Context("when no token is configured", func() {
+		BeforeEach(func() {
+			handler.Token = ""
+		})
+
+		It("rejects every request", func() {
+			request = httptest.NewRequest("GET", "/lease", nil)
+			request.Header.Set("Authorization", "Bearer ")
+			handler.ServeHTTP(responseWriter, request)
+			Expect(responseWriter.Code).To(Equal(http.StatusUnauthorized))
+		})
+	})
+})
@@ -0,0 +1,47 @@
+package diagnostics
+
+import (
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/silk/controller"
+)
+
+// State holds what the daemon last saw while converging, so that it can be
+// served by the diagnostic API.
+type State struct {
+	lock              sync.Mutex
+	lease             controller.Lease
+	leases            []controller.Lease
+	lastConvergeTime  time.Time
+	lastConvergeError error
+}
+
+func (s *State) RecordConverge(lease controller.Lease, leases []controller.Lease, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.lease = lease
+	s.leases = leases
+	s.lastConvergeTime = time.Now()
+	s.lastConvergeError = err
+}
+
+func (s *State) Lease() controller.Lease {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.lease
+}
+
+func (s *State) Leases() []controller.Lease {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.leases
+}
+
+// LastConverge returns the time and the error of the last converge. The time
+// is zero when the daemon has not converged yet.
+func (s *State) LastConverge() (time.Time, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.lastConvergeTime, s.lastConvergeError
+}
@@ -0,0 +1,99 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"sync"
+	"time"
+)
+
+type RenewalTracker struct {
+	LastSuccessStub        func() time.Time
+	lastSuccessMutex       sync.RWMutex
+	lastSuccessArgsForCall []struct {
+	}
+	lastSuccessReturns struct {
+		result1 time.Time
+	}
+	lastSuccessReturnsOnCall map[int]struct {
+		result1 time.Time
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *RenewalTracker) LastSuccess() time.Time {
+	fake.lastSuccessMutex.Lock()
+	ret, specificReturn := fake.lastSuccessReturnsOnCall[len(fake.lastSuccessArgsForCall)]
+	fake.lastSuccessArgsForCall = append(fake.lastSuccessArgsForCall, struct {
+	}{})
+	stub := fake.LastSuccessStub
+	fakeReturns := fake.lastSuccessReturns
+	fake.recordInvocation("LastSuccess", []interface{}{})
+	fake.lastSuccessMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *RenewalTracker) LastSuccessCallCount() int {
+	fake.lastSuccessMutex.RLock()
+	defer fake.lastSuccessMutex.RUnlock()
+	return len(fake.lastSuccessArgsForCall)
+}
+
+func (fake *RenewalTracker) LastSuccessCalls(stub func() time.Time) {
+	fake.lastSuccessMutex.Lock()
+	defer fake.lastSuccessMutex.Unlock()
+	fake.LastSuccessStub = stub
+}
+
+func (fake *RenewalTracker) LastSuccessReturns(result1 time.Time) {
+	fake.lastSuccessMutex.Lock()
+	defer fake.lastSuccessMutex.Unlock()
+	fake.LastSuccessStub = nil
+	fake.lastSuccessReturns = struct {
+		result1 time.Time
+	}{result1}
+}
+
+func (fake *RenewalTracker) LastSuccessReturnsOnCall(i int, result1 time.Time) {
+	fake.lastSuccessMutex.Lock()
+	defer fake.lastSuccessMutex.Unlock()
+	fake.LastSuccessStub = nil
+	if fake.lastSuccessReturnsOnCall == nil {
+		fake.lastSuccessReturnsOnCall = make(map[int]struct {
+			result1 time.Time
+		})
+	}
+	fake.lastSuccessReturnsOnCall[i] = struct {
+		result1 time.Time
+	}{result1}
+}
+
+func (fake *RenewalTracker) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.lastSuccessMutex.RLock()
+	defer fake.lastSuccessMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *RenewalTracker) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
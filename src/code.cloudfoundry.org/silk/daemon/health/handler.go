@@ -0,0 +1,55 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"code.cloudfoundry.org/silk/daemon"
+)
+
+//go:generate counterfeiter -o fakes/readinessChecker.go --fake-name ReadinessChecker . readinessChecker
+type readinessChecker interface {
+	Ready() error
+}
+
+// Handler serves the health endpoints of the daemon. Any other path serves the
+// network info that silk-cni reads.
+type Handler struct {
+	NetworkInfo daemon.NetworkInfo
+	Readiness   []readinessChecker
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/health/live":
+		respond(w, http.StatusOK, struct {
+			Status string `json:"status"`
+		}{"live"})
+	case "/health/ready":
+		for _, readiness := range h.Readiness {
+			if err := readiness.Ready(); err != nil {
+				respond(w, http.StatusServiceUnavailable, struct {
+					Status string `json:"status"`
+					Error  string `json:"error"`
+				}{"not ready", err.Error()})
+				return
+			}
+		}
+		respond(w, http.StatusOK, struct {
+			Status string `json:"status"`
+		}{"ready"})
+	default:
+		respond(w, http.StatusOK, h.NetworkInfo)
+	}
+}
+
+func respond(w http.ResponseWriter, status int, response interface{}) {
+	bytes, err := json.Marshal(response)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError) // not possible
+		return
+	}
+	w.WriteHeader(status)
+	// #nosec G104 - ignore errors when writing HTTP responses so we don't spam our logs during a DoS
+	w.Write(bytes)
+}
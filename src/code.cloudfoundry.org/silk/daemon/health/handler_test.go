@@ -0,0 +1,69 @@
+package health_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/silk/daemon"
+	"code.cloudfoundry.org/silk/daemon/health"
+	"code.cloudfoundry.org/silk/daemon/health/fakes"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Handler", func() {
+	var (
+		handler        *health.Handler
+		readiness      *fakes.ReadinessChecker
+		otherReadiness *fakes.ReadinessChecker
+		responseWriter *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		readiness = &fakes.ReadinessChecker{}
+		otherReadiness = &fakes.ReadinessChecker{}
+		handler = &health.Handler{
+			NetworkInfo: daemon.NetworkInfo{OverlaySubnet: "10.255.32.0/24", MTU: 1410},
+		}
+		handler.Readiness = append(handler.Readiness, readiness, otherReadiness)
+		responseWriter = httptest.NewRecorder()
+	})
+
+	serve := func(path string) {
+		handler.ServeHTTP(responseWriter, httptest.NewRequest("GET", path, nil))
+	}
+
+	It("serves the network info", func() {
+		serve("/")
+		Expect(responseWriter.Code).To(Equal(http.StatusOK))
+		Expect(responseWriter.Body.String()).To(MatchJSON(`{ "overlay_subnet": "10.255.32.0/24", "mtu": 1410 }`))
+	})
+
+	It("is live regardless of readiness", func() {
+		readiness.ReadyReturns(errors.New("banana"))
+		serve("/health/live")
+		Expect(responseWriter.Code).To(Equal(http.StatusOK))
+		Expect(responseWriter.Body.String()).To(MatchJSON(`{ "status": "live" }`))
+	})
+
+	It("is ready when every poller is ready", func() {
+		serve("/health/ready")
+		Expect(responseWriter.Code).To(Equal(http.StatusOK))
+		Expect(responseWriter.Body.String()).To(MatchJSON(`{ "status": "ready" }`))
+		Expect(readiness.ReadyCallCount()).To(Equal(1))
+		Expect(otherReadiness.ReadyCallCount()).To(Equal(1))
+	})
+
+	Context("when a poller is not ready", func() {
+		BeforeEach(func() {
+			otherReadiness.ReadyReturns(errors.New("banana"))
+		})
+
+		It("responds with service unavailable", func() {
+			serve("/health/ready")
+			Expect(responseWriter.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(responseWriter.Body.String()).To(MatchJSON(`{ "status": "not ready", "error": "banana" }`))
+		})
+	})
+})
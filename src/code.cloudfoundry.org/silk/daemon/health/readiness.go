@@ -0,0 +1,56 @@
+package health
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/silk/controller"
+)
+
+//go:generate counterfeiter -o fakes/renewalTracker.go --fake-name RenewalTracker . renewalTracker
+type renewalTracker interface {
+	LastSuccess() time.Time
+}
+
+// Readiness tracks whether a VXLAN poller has recently renewed its lease and
+// converged the VTEP.
+type Readiness struct {
+	// MaxAge is how long a successful converge keeps the poller ready.
+	MaxAge time.Duration
+	// GraceDuration is the partition tolerance of the poller. The poller is
+	// not ready once its lease has not been renewed for this long.
+	GraceDuration time.Duration
+	Renewals      renewalTracker
+
+	lock             sync.Mutex
+	lastConvergeTime time.Time
+}
+
+func (r *Readiness) RecordConverge(_ controller.Lease, _ []controller.Lease, err error) {
+	if err != nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.lastConvergeTime = time.Now()
+}
+
+// Ready returns an error describing why the poller is not ready.
+func (r *Readiness) Ready() error {
+	r.lock.Lock()
+	lastConvergeTime := r.lastConvergeTime
+	r.lock.Unlock()
+
+	if lastConvergeTime.IsZero() {
+		return fmt.Errorf("not converged yet")
+	}
+	if time.Since(lastConvergeTime) > r.MaxAge {
+		return fmt.Errorf("last converged at %s", lastConvergeTime.Format(time.RFC3339))
+	}
+	lastRenewal := r.Renewals.LastSuccess()
+	if time.Since(lastRenewal) >= r.GraceDuration {
+		return fmt.Errorf("lease not renewed since %s", lastRenewal.Format(time.RFC3339))
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package health_test
+
+import (
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/daemon/health"
+	"code.cloudfoundry.org/silk/daemon/health/fakes"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Readiness", func() {
+	var (
+		readiness *health.Readiness
+		renewals  *fakes.RenewalTracker
+	)
+
+	BeforeEach(func() {
+		renewals = &fakes.RenewalTracker{}
+		renewals.LastSuccessReturns(time.Now())
+		readiness = &health.Readiness{
+			MaxAge:        time.Minute,
+			GraceDuration: time.Minute,
+			Renewals:      renewals,
+		}
+	})
+
+	It("is not ready before the first converge", func() {
+		Expect(readiness.Ready()).To(MatchError("not converged yet"))
+	})
+
+	It("is ready after a successful converge", func() {
+		readiness.RecordConverge(controller.Lease{}, nil, nil)
+		Expect(readiness.Ready()).To(Succeed())
+	})
+
+	It("ignores failed converges", func() {
+		readiness.RecordConverge(controller.Lease{}, nil, errors.New("banana"))
+		Expect(readiness.Ready()).To(MatchError("not converged yet"))
+	})
+
+	Context("when the last successful converge is too old", func() {
+		BeforeEach(func() {
+			readiness.MaxAge = 10 * time.Millisecond
+		})
+
+		It("is not ready", func() {
+			readiness.RecordConverge(controller.Lease{}, nil, nil)
+			Expect(readiness.Ready()).To(Succeed())
+			Eventually(readiness.Ready).Should(MatchError(ContainSubstring("last converged at")))
+		})
+	})
+
+	Context("when the lease has not been renewed within the grace duration", func() {
+		BeforeEach(func() {
+			renewals.LastSuccessReturns(time.Now().Add(-2 * time.Minute))
+		})
+
+		It("is not ready", func() {
+			readiness.RecordConverge(controller.Lease{}, nil, nil)
+			Expect(readiness.Ready()).To(MatchError(ContainSubstring("lease not renewed since")))
+		})
+	})
+})
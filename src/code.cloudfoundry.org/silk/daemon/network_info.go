@@ -1,6 +1,7 @@
 package daemon
 
 type NetworkInfo struct {
-	OverlaySubnet string `json:"overlay_subnet"`
-	MTU           int    `json:"mtu"`
+	OverlaySubnet string                 `json:"overlay_subnet"`
+	MTU           int                    `json:"mtu"`
+	Overlays      map[string]NetworkInfo `json:"overlays,omitempty"`
 }
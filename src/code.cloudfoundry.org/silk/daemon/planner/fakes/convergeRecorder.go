@@ -0,0 +1,83 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/silk/controller"
+)
+
+type ConvergeRecorder struct {
+	RecordConvergeStub        func(controller.Lease, []controller.Lease, error)
+	recordConvergeMutex       sync.RWMutex
+	recordConvergeArgsForCall []struct {
+		arg1 controller.Lease
+		arg2 []controller.Lease
+		arg3 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *ConvergeRecorder) RecordConverge(arg1 controller.Lease, arg2 []controller.Lease, arg3 error) {
+	var arg2Copy []controller.Lease
+	if arg2 != nil {
+		arg2Copy = make([]controller.Lease, len(arg2))
+		copy(arg2Copy, arg2)
+	}
+	fake.recordConvergeMutex.Lock()
+	fake.recordConvergeArgsForCall = append(fake.recordConvergeArgsForCall, struct {
+		arg1 controller.Lease
+		arg2 []controller.Lease
+		arg3 error
+	}{arg1, arg2Copy, arg3})
+	stub := fake.RecordConvergeStub
+	fake.recordInvocation("RecordConverge", []interface{}{arg1, arg2Copy, arg3})
+	fake.recordConvergeMutex.Unlock()
+	if stub != nil {
+		fake.RecordConvergeStub(arg1, arg2, arg3)
+	}
+}
+
+func (fake *ConvergeRecorder) RecordConvergeCallCount() int {
+	fake.recordConvergeMutex.RLock()
+	defer fake.recordConvergeMutex.RUnlock()
+	return len(fake.recordConvergeArgsForCall)
+}
+
+func (fake *ConvergeRecorder) RecordConvergeCalls(stub func(controller.Lease, []controller.Lease, error)) {
+	fake.recordConvergeMutex.Lock()
+	defer fake.recordConvergeMutex.Unlock()
+	fake.RecordConvergeStub = stub
+}
+
+func (fake *ConvergeRecorder) RecordConvergeArgsForCall(i int) (controller.Lease, []controller.Lease, error) {
+	fake.recordConvergeMutex.RLock()
+	defer fake.recordConvergeMutex.RUnlock()
+	argsForCall := fake.recordConvergeArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *ConvergeRecorder) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.recordConvergeMutex.RLock()
+	defer fake.recordConvergeMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *ConvergeRecorder) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
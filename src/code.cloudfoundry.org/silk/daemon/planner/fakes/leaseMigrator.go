@@ -0,0 +1,116 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/silk/controller"
+)
+
+type LeaseMigrator struct {
+	MigrateStub        func(controller.Lease, string) (controller.Lease, error)
+	migrateMutex       sync.RWMutex
+	migrateArgsForCall []struct {
+		arg1 controller.Lease
+		arg2 string
+	}
+	migrateReturns struct {
+		result1 controller.Lease
+		result2 error
+	}
+	migrateReturnsOnCall map[int]struct {
+		result1 controller.Lease
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *LeaseMigrator) Migrate(arg1 controller.Lease, arg2 string) (controller.Lease, error) {
+	fake.migrateMutex.Lock()
+	ret, specificReturn := fake.migrateReturnsOnCall[len(fake.migrateArgsForCall)]
+	fake.migrateArgsForCall = append(fake.migrateArgsForCall, struct {
+		arg1 controller.Lease
+		arg2 string
+	}{arg1, arg2})
+	stub := fake.MigrateStub
+	fakeReturns := fake.migrateReturns
+	fake.recordInvocation("Migrate", []interface{}{arg1, arg2})
+	fake.migrateMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *LeaseMigrator) MigrateCallCount() int {
+	fake.migrateMutex.RLock()
+	defer fake.migrateMutex.RUnlock()
+	return len(fake.migrateArgsForCall)
+}
+
+func (fake *LeaseMigrator) MigrateCalls(stub func(controller.Lease, string) (controller.Lease, error)) {
+	fake.migrateMutex.Lock()
+	defer fake.migrateMutex.Unlock()
+	fake.MigrateStub = stub
+}
+
+func (fake *LeaseMigrator) MigrateArgsForCall(i int) (controller.Lease, string) {
+	fake.migrateMutex.RLock()
+	defer fake.migrateMutex.RUnlock()
+	argsForCall := fake.migrateArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *LeaseMigrator) MigrateReturns(result1 controller.Lease, result2 error) {
+	fake.migrateMutex.Lock()
+	defer fake.migrateMutex.Unlock()
+	fake.MigrateStub = nil
+	fake.migrateReturns = struct {
+		result1 controller.Lease
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *LeaseMigrator) MigrateReturnsOnCall(i int, result1 controller.Lease, result2 error) {
+	fake.migrateMutex.Lock()
+	defer fake.migrateMutex.Unlock()
+	fake.MigrateStub = nil
+	if fake.migrateReturnsOnCall == nil {
+		fake.migrateReturnsOnCall = make(map[int]struct {
+			result1 controller.Lease
+			result2 error
+		})
+	}
+	fake.migrateReturnsOnCall[i] = struct {
+		result1 controller.Lease
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *LeaseMigrator) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.migrateMutex.RLock()
+	defer fake.migrateMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *LeaseMigrator) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
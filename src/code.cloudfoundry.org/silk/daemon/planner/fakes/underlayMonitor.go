@@ -0,0 +1,112 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"sync"
+)
+
+type UnderlayMonitor struct {
+	UnderlayIPStub        func(string) (string, error)
+	underlayIPMutex       sync.RWMutex
+	underlayIPArgsForCall []struct {
+		arg1 string
+	}
+	underlayIPReturns struct {
+		result1 string
+		result2 error
+	}
+	underlayIPReturnsOnCall map[int]struct {
+		result1 string
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *UnderlayMonitor) UnderlayIP(arg1 string) (string, error) {
+	fake.underlayIPMutex.Lock()
+	ret, specificReturn := fake.underlayIPReturnsOnCall[len(fake.underlayIPArgsForCall)]
+	fake.underlayIPArgsForCall = append(fake.underlayIPArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.UnderlayIPStub
+	fakeReturns := fake.underlayIPReturns
+	fake.recordInvocation("UnderlayIP", []interface{}{arg1})
+	fake.underlayIPMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *UnderlayMonitor) UnderlayIPCallCount() int {
+	fake.underlayIPMutex.RLock()
+	defer fake.underlayIPMutex.RUnlock()
+	return len(fake.underlayIPArgsForCall)
+}
+
+func (fake *UnderlayMonitor) UnderlayIPCalls(stub func(string) (string, error)) {
+	fake.underlayIPMutex.Lock()
+	defer fake.underlayIPMutex.Unlock()
+	fake.UnderlayIPStub = stub
+}
+
+func (fake *UnderlayMonitor) UnderlayIPArgsForCall(i int) string {
+	fake.underlayIPMutex.RLock()
+	defer fake.underlayIPMutex.RUnlock()
+	argsForCall := fake.underlayIPArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *UnderlayMonitor) UnderlayIPReturns(result1 string, result2 error) {
+	fake.underlayIPMutex.Lock()
+	defer fake.underlayIPMutex.Unlock()
+	fake.UnderlayIPStub = nil
+	fake.underlayIPReturns = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *UnderlayMonitor) UnderlayIPReturnsOnCall(i int, result1 string, result2 error) {
+	fake.underlayIPMutex.Lock()
+	defer fake.underlayIPMutex.Unlock()
+	fake.UnderlayIPStub = nil
+	if fake.underlayIPReturnsOnCall == nil {
+		fake.underlayIPReturnsOnCall = make(map[int]struct {
+			result1 string
+			result2 error
+		})
+	}
+	fake.underlayIPReturnsOnCall[i] = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *UnderlayMonitor) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.underlayIPMutex.RLock()
+	defer fake.underlayIPMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *UnderlayMonitor) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
@@ -1,6 +1,7 @@
 package planner
 
 import (
+	"sync"
 	"time"
 
 	"code.cloudfoundry.org/silk/controller"
@@ -12,26 +13,53 @@ type FatalErrorDetector interface {
 	IsFatal(error) bool
 }
 
-type gracefulDetector struct {
+// GracefulDetector treats renewal errors as fatal once no renewal has
+// succeeded for the grace duration. It also tracks the current streak of
+// successful renewals for diagnostics.
+type GracefulDetector struct {
 	graceDuration   time.Duration
 	lastSuccessTime time.Time
+	successStreak   int
+	lock            sync.Mutex
 }
 
-func NewGracefulDetector(gd time.Duration) FatalErrorDetector {
-	return &gracefulDetector{
+func NewGracefulDetector(gd time.Duration) *GracefulDetector {
+	return &GracefulDetector{
 		graceDuration:   gd,
 		lastSuccessTime: time.Now(),
 	}
 }
 
-func (fed *gracefulDetector) GotSuccess() {
+func (fed *GracefulDetector) GotSuccess() {
+	fed.lock.Lock()
+	defer fed.lock.Unlock()
 	fed.lastSuccessTime = time.Now()
+	fed.successStreak++
 }
 
-func (fed *gracefulDetector) IsFatal(err error) bool {
+func (fed *GracefulDetector) IsFatal(err error) bool {
+	fed.lock.Lock()
+	defer fed.lock.Unlock()
+	fed.successStreak = 0
+
 	_, isNonRetriable := err.(controller.NonRetriableError)
 	if isNonRetriable {
 		return true
 	}
 	return time.Since(fed.lastSuccessTime) >= fed.graceDuration
 }
+
+// SuccessStreak returns the number of successful renewals since the last failure.
+func (fed *GracefulDetector) SuccessStreak() int {
+	fed.lock.Lock()
+	defer fed.lock.Unlock()
+	return fed.successStreak
+}
+
+// LastSuccess returns the time of the last successful renewal, or the time the
+// detector was created when no renewal has succeeded yet.
+func (fed *GracefulDetector) LastSuccess() time.Time {
+	fed.lock.Lock()
+	defer fed.lock.Unlock()
+	return fed.lastSuccessTime
+}
@@ -63,4 +63,25 @@ var _ = Describe("FatalErrorDetector", func() {
 			})
 		})
 	})
+
+	Describe("SuccessStreak", func() {
+		var detector *planner.GracefulDetector
+
+		BeforeEach(func() {
+			detector = planner.NewGracefulDetector(graceDuration)
+		})
+
+		It("counts successes since the last failure", func() {
+			Expect(detector.SuccessStreak()).To(Equal(0))
+
+			detector.GotSuccess()
+			detector.GotSuccess()
+			Expect(detector.SuccessStreak()).To(Equal(2))
+			lastSuccess := detector.LastSuccess()
+
+			detector.IsFatal(fmt.Errorf("banana"))
+			Expect(detector.SuccessStreak()).To(Equal(0))
+			Expect(detector.LastSuccess()).To(Equal(lastSuccess))
+		})
+	})
 })
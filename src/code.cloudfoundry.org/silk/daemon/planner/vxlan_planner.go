@@ -25,17 +25,40 @@ type metricSender interface {
 	IncrementCounter(name string)
 }
 
+//go:generate counterfeiter -o fakes/underlayMonitor.go --fake-name UnderlayMonitor . underlayMonitor
+type underlayMonitor interface {
+	UnderlayIP(current string) (string, error)
+}
+
+//go:generate counterfeiter -o fakes/leaseMigrator.go --fake-name LeaseMigrator . leaseMigrator
+type leaseMigrator interface {
+	Migrate(lease controller.Lease, underlayIP string) (controller.Lease, error)
+}
+
+//go:generate counterfeiter -o fakes/convergeRecorder.go --fake-name ConvergeRecorder . convergeRecorder
+type convergeRecorder interface {
+	RecordConverge(lease controller.Lease, leases []controller.Lease, err error)
+}
+
 type VXLANPlanner struct {
-	Logger           lager.Logger
-	ControllerClient controllerClient
-	Converger        converger
-	Lease            controller.Lease
-	ErrorDetector    FatalErrorDetector
-	MetricSender     metricSender
+	Logger            lager.Logger
+	ControllerClient  controllerClient
+	Converger         converger
+	Lease             controller.Lease
+	ErrorDetector     FatalErrorDetector
+	MetricSender      metricSender
+	UnderlayMonitor   underlayMonitor
+	LeaseMigrator     leaseMigrator
+	ConvergeRecorders []convergeRecorder
 }
 
 func (v *VXLANPlanner) DoCycle() error {
-	err := v.ControllerClient.RenewSubnetLease(v.Lease)
+	err := v.followUnderlayIP()
+	if err != nil {
+		return err
+	}
+
+	err = v.ControllerClient.RenewSubnetLease(v.Lease)
 	if err != nil {
 		v.MetricSender.IncrementCounter("renewFailure")
 		if v.ErrorDetector.IsFatal(err) {
@@ -56,6 +79,9 @@ func (v *VXLANPlanner) DoCycle() error {
 	v.MetricSender.SendValue("numberLeases", float64(len(leases)), "")
 
 	err = v.Converger.Converge(leases)
+	for _, recorder := range v.ConvergeRecorders {
+		recorder.RecordConverge(v.Lease, leases, err)
+	}
 	if err != nil {
 		v.MetricSender.IncrementCounter("convergeFailure")
 		return fmt.Errorf("converge leases: %s", err)
@@ -65,3 +91,27 @@ func (v *VXLANPlanner) DoCycle() error {
 	v.Logger.Debug("converge-leases", lager.Data{"leases": leases})
 	return nil
 }
+
+func (v *VXLANPlanner) followUnderlayIP() error {
+	if v.UnderlayMonitor == nil || v.LeaseMigrator == nil {
+		return nil
+	}
+
+	underlayIP, err := v.UnderlayMonitor.UnderlayIP(v.Lease.UnderlayIP)
+	if err != nil {
+		return fmt.Errorf("find underlay ip: %s", err)
+	}
+	if underlayIP == v.Lease.UnderlayIP {
+		return nil
+	}
+
+	v.Logger.Info("underlay-ip-changed", lager.Data{"old_underlay_ip": v.Lease.UnderlayIP, "new_underlay_ip": underlayIP})
+	lease, err := v.LeaseMigrator.Migrate(v.Lease, underlayIP)
+	if err != nil {
+		v.MetricSender.IncrementCounter("migrateFailure")
+		return fmt.Errorf("migrate lease: %s", err)
+	}
+	v.MetricSender.IncrementCounter("migrateSuccess")
+	v.Lease = lease
+	return nil
+}
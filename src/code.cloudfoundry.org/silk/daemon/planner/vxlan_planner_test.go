@@ -229,5 +229,122 @@ var _ = Describe("VxlanPlanner", func() {
 				Expect(metricSender.IncrementCounterArgsForCall(1)).To(Equal("convergeFailure"))
 			})
 		})
+
+		Context("when converge recorders are configured", func() {
+			var convergeRecorder, otherConvergeRecorder *fakes.ConvergeRecorder
+
+			BeforeEach(func() {
+				convergeRecorder = &fakes.ConvergeRecorder{}
+				otherConvergeRecorder = &fakes.ConvergeRecorder{}
+				vxlanPlanner.ConvergeRecorders = append(vxlanPlanner.ConvergeRecorders, convergeRecorder, otherConvergeRecorder)
+			})
+
+			It("records the lease, the peer leases and the converge result", func() {
+				err := vxlanPlanner.DoCycle()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(otherConvergeRecorder.RecordConvergeCallCount()).To(Equal(1))
+				Expect(convergeRecorder.RecordConvergeCallCount()).To(Equal(1))
+				lease, recordedLeases, convergeErr := convergeRecorder.RecordConvergeArgsForCall(0)
+				Expect(lease).To(Equal(vxlanPlanner.Lease))
+				Expect(recordedLeases).To(Equal(leases))
+				Expect(convergeErr).NotTo(HaveOccurred())
+			})
+
+			Context("when the converger fails", func() {
+				BeforeEach(func() {
+					converger.ConvergeReturns(errors.New("banana"))
+				})
+
+				It("records the error", func() {
+					err := vxlanPlanner.DoCycle()
+					Expect(err).To(HaveOccurred())
+
+					_, _, convergeErr := convergeRecorder.RecordConvergeArgsForCall(0)
+					Expect(convergeErr).To(MatchError("banana"))
+				})
+			})
+		})
+
+		Context("when an underlay monitor and lease migrator are configured", func() {
+			var (
+				underlayMonitor *fakes.UnderlayMonitor
+				leaseMigrator   *fakes.LeaseMigrator
+				migratedLease   controller.Lease
+			)
+
+			BeforeEach(func() {
+				underlayMonitor = &fakes.UnderlayMonitor{}
+				leaseMigrator = &fakes.LeaseMigrator{}
+				vxlanPlanner.UnderlayMonitor = underlayMonitor
+				vxlanPlanner.LeaseMigrator = leaseMigrator
+
+				underlayMonitor.UnderlayIPReturns("172.244.17.0", nil)
+				migratedLease = controller.Lease{
+					UnderlayIP:          "172.244.99.0",
+					OverlaySubnet:       "10.244.17.0/24",
+					OverlayHardwareAddr: "ee:ee:0a:f4:11:00",
+				}
+				leaseMigrator.MigrateReturns(migratedLease, nil)
+			})
+
+			It("does not migrate the lease while the underlay ip is unchanged", func() {
+				err := vxlanPlanner.DoCycle()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(underlayMonitor.UnderlayIPCallCount()).To(Equal(1))
+				Expect(underlayMonitor.UnderlayIPArgsForCall(0)).To(Equal("172.244.17.0"))
+				Expect(leaseMigrator.MigrateCallCount()).To(Equal(0))
+			})
+
+			Context("when the underlay ip changes", func() {
+				BeforeEach(func() {
+					underlayMonitor.UnderlayIPReturns("172.244.99.0", nil)
+				})
+
+				It("migrates the lease and renews the migrated lease", func() {
+					err := vxlanPlanner.DoCycle()
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(leaseMigrator.MigrateCallCount()).To(Equal(1))
+					lease, underlayIP := leaseMigrator.MigrateArgsForCall(0)
+					Expect(lease.UnderlayIP).To(Equal("172.244.17.0"))
+					Expect(underlayIP).To(Equal("172.244.99.0"))
+
+					Expect(vxlanPlanner.Lease).To(Equal(migratedLease))
+					Expect(controllerClient.RenewSubnetLeaseArgsForCall(0)).To(Equal(migratedLease))
+					Expect(metricSender.IncrementCounterArgsForCall(0)).To(Equal("migrateSuccess"))
+				})
+
+				Context("when the migration fails", func() {
+					BeforeEach(func() {
+						leaseMigrator.MigrateReturns(controller.Lease{}, errors.New("kiwi"))
+					})
+
+					It("keeps the old lease and returns a non-fatal error", func() {
+						err := vxlanPlanner.DoCycle()
+						Expect(err).To(MatchError("migrate lease: kiwi"))
+						_, ok := err.(poller.FatalError)
+						Expect(ok).NotTo(BeTrue())
+
+						Expect(vxlanPlanner.Lease.UnderlayIP).To(Equal("172.244.17.0"))
+						Expect(controllerClient.RenewSubnetLeaseCallCount()).To(Equal(0))
+						Expect(metricSender.IncrementCounterArgsForCall(0)).To(Equal("migrateFailure"))
+					})
+				})
+			})
+
+			Context("when the underlay ip can not be found", func() {
+				BeforeEach(func() {
+					underlayMonitor.UnderlayIPReturns("", errors.New("mango"))
+				})
+
+				It("returns an error", func() {
+					err := vxlanPlanner.DoCycle()
+					Expect(err).To(MatchError("find underlay ip: mango"))
+					Expect(controllerClient.RenewSubnetLeaseCallCount()).To(Equal(0))
+				})
+			})
+		})
 	})
 })
@@ -0,0 +1,78 @@
+package static
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/silk/controller"
+	"gopkg.in/yaml.v3"
+)
+
+// Leases is the content of a static lease file. It replaces the silk-controller
+// as the source of the local lease and of the peer leases.
+type Leases struct {
+	LocalLease controller.Lease   `json:"local_lease" yaml:"local_lease"`
+	Leases     []controller.Lease `json:"leases" yaml:"leases"`
+}
+
+// LeaseFile reads leases from a JSON or YAML file. The format is chosen by
+// the file extension, defaulting to JSON.
+type LeaseFile struct {
+	Path string
+}
+
+func (f *LeaseFile) Read() (Leases, error) {
+	contents, err := os.ReadFile(f.Path)
+	if err != nil {
+		return Leases{}, fmt.Errorf("reading file %s: %s", f.Path, err)
+	}
+
+	var leases Leases
+	switch filepath.Ext(f.Path) {
+	case ".yml", ".yaml":
+		err = yaml.Unmarshal(contents, &leases)
+	default:
+		err = json.Unmarshal(contents, &leases)
+	}
+	if err != nil {
+		return Leases{}, fmt.Errorf("unmarshaling contents: %s", err)
+	}
+
+	if leases.LocalLease.OverlaySubnet == "" {
+		return Leases{}, fmt.Errorf("missing local lease")
+	}
+
+	return leases, nil
+}
+
+func (f *LeaseFile) LocalLease() (controller.Lease, error) {
+	leases, err := f.Read()
+	if err != nil {
+		return controller.Lease{}, err
+	}
+	return leases.LocalLease, nil
+}
+
+// GetActiveLeases returns every lease in the file, including the local one.
+func (f *LeaseFile) GetActiveLeases() ([]controller.Lease, error) {
+	leases, err := f.Read()
+	if err != nil {
+		return nil, err
+	}
+	return append([]controller.Lease{leases.LocalLease}, leases.Leases...), nil
+}
+
+// RenewSubnetLease checks that the file still assigns the given lease to this cell.
+// A changed local lease can not be applied in place, so it is reported as non-retriable.
+func (f *LeaseFile) RenewSubnetLease(lease controller.Lease) error {
+	leases, err := f.Read()
+	if err != nil {
+		return err
+	}
+	if leases.LocalLease != lease {
+		return controller.NonRetriableError("local lease changed in static lease file")
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package static_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/daemon/static"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LeaseFile", func() {
+	var (
+		dir        string
+		leaseFile  *static.LeaseFile
+		localLease controller.Lease
+		peerLease  controller.Lease
+	)
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		localLease = controller.Lease{
+			UnderlayIP:          "10.0.0.1",
+			OverlaySubnet:       "10.255.1.0/24",
+			OverlayHardwareAddr: "ee:ee:0a:ff:01:00",
+		}
+		peerLease = controller.Lease{
+			UnderlayIP:          "10.0.0.2",
+			OverlaySubnet:       "10.255.2.0/24",
+			OverlayHardwareAddr: "ee:ee:0a:ff:02:00",
+		}
+	})
+
+	writeFile := func(name, contents string) {
+		leaseFile = &static.LeaseFile{Path: filepath.Join(dir, name)}
+		Expect(os.WriteFile(leaseFile.Path, []byte(contents), 0600)).To(Succeed())
+	}
+
+	Context("when the file is JSON", func() {
+		BeforeEach(func() {
+			writeFile("leases.json", `{
+				"local_lease": {"underlay_ip": "10.0.0.1", "overlay_subnet": "10.255.1.0/24", "overlay_hardware_addr": "ee:ee:0a:ff:01:00"},
+				"leases": [
+					{"underlay_ip": "10.0.0.2", "overlay_subnet": "10.255.2.0/24", "overlay_hardware_addr": "ee:ee:0a:ff:02:00"}
+				]
+			}`)
+		})
+
+		It("reads the leases", func() {
+			leases, err := leaseFile.Read()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(leases).To(Equal(static.Leases{
+				LocalLease: localLease,
+				Leases:     []controller.Lease{peerLease},
+			}))
+		})
+	})
+
+	Context("when the file is YAML", func() {
+		BeforeEach(func() {
+			writeFile("leases.yml", `
+local_lease:
+  underlay_ip: 10.0.0.1
+  overlay_subnet: 10.255.1.0/24
+  overlay_hardware_addr: ee:ee:0a:ff:01:00
+leases:
+- underlay_ip: 10.0.0.2
+  overlay_subnet: 10.255.2.0/24
+  overlay_hardware_addr: ee:ee:0a:ff:02:00
+`)
+		})
+
+		It("reads the leases", func() {
+			leases, err := leaseFile.Read()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(leases).To(Equal(static.Leases{
+				LocalLease: localLease,
+				Leases:     []controller.Lease{peerLease},
+			}))
+		})
+
+		Describe("GetActiveLeases", func() {
+			It("returns the local and the peer leases", func() {
+				leases, err := leaseFile.GetActiveLeases()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(leases).To(Equal([]controller.Lease{localLease, peerLease}))
+			})
+		})
+
+		Describe("RenewSubnetLease", func() {
+			It("succeeds when the local lease is unchanged", func() {
+				Expect(leaseFile.RenewSubnetLease(localLease)).To(Succeed())
+			})
+
+			It("returns a non-retriable error when the local lease changed", func() {
+				err := leaseFile.RenewSubnetLease(peerLease)
+				Expect(err).To(Equal(controller.NonRetriableError("local lease changed in static lease file")))
+			})
+		})
+	})
+
+	Context("when the file does not exist", func() {
+		It("returns an error", func() {
+			leaseFile = &static.LeaseFile{Path: filepath.Join(dir, "missing.json")}
+			_, err := leaseFile.GetActiveLeases()
+			Expect(err).To(MatchError(HavePrefix("reading file")))
+		})
+	})
+
+	Context("when the file can not be parsed", func() {
+		It("returns an error", func() {
+			writeFile("leases.json", "{")
+			_, err := leaseFile.Read()
+			Expect(err).To(MatchError(HavePrefix("unmarshaling contents:")))
+		})
+	})
+
+	Context("when the file has no local lease", func() {
+		It("returns an error", func() {
+			writeFile("leases.json", `{"leases": []}`)
+			_, err := leaseFile.LocalLease()
+			Expect(err).To(MatchError("missing local lease"))
+		})
+	})
+})
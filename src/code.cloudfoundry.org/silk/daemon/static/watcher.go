@@ -0,0 +1,57 @@
+package static
+
+import (
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/cf-networking-helpers/poller"
+	"code.cloudfoundry.org/lager/v3"
+)
+
+// Watcher runs OnChange when the size or modification time of the file at
+// Path changes. Like the poller, it exits when OnChange returns a fatal error.
+// It stats the file instead of using inotify, so that files replaced by a
+// rename are still noticed.
+type Watcher struct {
+	Path          string
+	CheckInterval time.Duration
+	OnChange      func() error
+	Logger        lager.Logger
+}
+
+func (w *Watcher) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	lastModTime, lastSize := w.stat()
+	close(ready)
+
+	ticker := time.NewTicker(w.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-signals:
+			return nil
+		case <-ticker.C:
+			modTime, size := w.stat()
+			if modTime.Equal(lastModTime) && size == lastSize {
+				continue
+			}
+			lastModTime, lastSize = modTime, size
+
+			w.Logger.Info("lease-file-changed", lager.Data{"path": w.Path})
+			if err := w.OnChange(); err != nil {
+				w.Logger.Error("lease-file-changed", err)
+				if _, ok := err.(poller.FatalError); ok {
+					return err
+				}
+			}
+		}
+	}
+}
+
+func (w *Watcher) stat() (time.Time, int64) {
+	info, err := os.Stat(w.Path)
+	if err != nil {
+		return time.Time{}, -1
+	}
+	return info.ModTime(), info.Size()
+}
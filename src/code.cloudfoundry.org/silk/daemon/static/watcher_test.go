@@ -0,0 +1,89 @@
+package static_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/cf-networking-helpers/poller"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/silk/daemon/static"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+)
+
+var _ = Describe("Watcher", func() {
+	var (
+		path      string
+		changes   chan struct{}
+		changeErr error
+		watcher   *static.Watcher
+		process   ifrit.Process
+	)
+
+	BeforeEach(func() {
+		path = filepath.Join(GinkgoT().TempDir(), "leases.json")
+		Expect(os.WriteFile(path, []byte("{}"), 0600)).To(Succeed())
+
+		changes = make(chan struct{}, 10)
+		changeErr = nil
+		watcher = &static.Watcher{
+			Path:          path,
+			CheckInterval: 10 * time.Millisecond,
+			OnChange: func() error {
+				changes <- struct{}{}
+				return changeErr
+			},
+			Logger: lagertest.NewTestLogger("test"),
+		}
+	})
+
+	JustBeforeEach(func() {
+		process = ifrit.Invoke(watcher)
+	})
+
+	AfterEach(func() {
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive())
+	})
+
+	It("does not run OnChange while the file is unchanged", func() {
+		Consistently(changes, "100ms").ShouldNot(Receive())
+	})
+
+	It("runs OnChange when the file changes", func() {
+		Expect(os.WriteFile(path, []byte(`{"leases": []}`), 0600)).To(Succeed())
+		Eventually(changes).Should(Receive())
+		Consistently(changes, "100ms").ShouldNot(Receive())
+	})
+
+	It("runs OnChange when the file is removed", func() {
+		Expect(os.Remove(path)).To(Succeed())
+		Eventually(changes).Should(Receive())
+	})
+
+	Context("when OnChange fails", func() {
+		BeforeEach(func() {
+			changeErr = errors.New("banana")
+		})
+
+		It("keeps watching", func() {
+			Expect(os.WriteFile(path, []byte(`{"leases": []}`), 0600)).To(Succeed())
+			Eventually(changes).Should(Receive())
+			Consistently(process.Wait(), "100ms").ShouldNot(Receive())
+		})
+	})
+
+	Context("when OnChange fails fatally", func() {
+		BeforeEach(func() {
+			changeErr = poller.FatalError("banana")
+		})
+
+		It("exits with the error", func() {
+			Expect(os.WriteFile(path, []byte(`{"leases": []}`), 0600)).To(Succeed())
+			Eventually(process.Wait()).Should(Receive(MatchError("fatal: banana")))
+		})
+	})
+})
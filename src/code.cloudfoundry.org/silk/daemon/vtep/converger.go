@@ -3,6 +3,7 @@ package vtep
 import (
 	"fmt"
 	"net"
+	"sync"
 	"syscall"
 
 	"code.cloudfoundry.org/lager/v3"
@@ -10,15 +11,31 @@ import (
 	"github.com/vishvananda/netlink"
 )
 
+// Converger programs the routes and neighbors of peer leases on the local
+// VTEP. LocalSubnet and LocalVTEP may only be changed through SetLocal once
+// the converger is in use, as Diff reads them from other goroutines.
 type Converger struct {
 	OverlayNetwork *net.IPNet
 	LocalSubnet    *net.IPNet
 	LocalVTEP      net.Interface
 	NetlinkAdapter netlinkAdapter
 	Logger         lager.Logger
+
+	mu sync.RWMutex
+}
+
+// SetLocal points the converger at a new local VTEP and subnet.
+func (c *Converger) SetLocal(vtep net.Interface, subnet *net.IPNet) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.LocalVTEP = vtep
+	c.LocalSubnet = subnet
 }
 
 func (c *Converger) Converge(leases []controller.Lease) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	previousRoutes, previousNeighs, err := c.getPreviousState(c.LocalVTEP.Index)
 	if err != nil {
 		return err
@@ -155,14 +172,18 @@ func (c *Converger) getPreviousState(index int) ([]netlink.Route, []netlink.Neig
 	return previousRoutes, previousNeighs, nil
 }
 
-func (c *Converger) addRoute(destNet *net.IPNet, destAddr net.IP) (netlink.Route, error) {
-	route := netlink.Route{
+func (c *Converger) route(destNet *net.IPNet, destAddr net.IP) netlink.Route {
+	return netlink.Route{
 		LinkIndex: c.LocalVTEP.Index,
 		Scope:     netlink.SCOPE_UNIVERSE,
 		Dst:       destNet,
 		Gw:        destAddr,
 		Src:       c.LocalSubnet.IP,
 	}
+}
+
+func (c *Converger) addRoute(destNet *net.IPNet, destAddr net.IP) (netlink.Route, error) {
+	route := c.route(destNet, destAddr)
 
 	err := c.NetlinkAdapter.RouteReplace(&route)
 	if err != nil {
@@ -172,8 +193,8 @@ func (c *Converger) addRoute(destNet *net.IPNet, destAddr net.IP) (netlink.Route
 	return route, nil
 }
 
-func (c *Converger) addNeighs(underlayIP, destAddr net.IP, remoteMac net.HardwareAddr) ([]netlink.Neigh, error) {
-	neighs := []*netlink.Neigh{
+func (c *Converger) neighs(underlayIP, destAddr net.IP, remoteMac net.HardwareAddr) []*netlink.Neigh {
+	return []*netlink.Neigh{
 		{ // ARP
 			LinkIndex:    c.LocalVTEP.Index,
 			State:        netlink.NUD_PERMANENT,
@@ -190,9 +211,11 @@ func (c *Converger) addNeighs(underlayIP, destAddr net.IP, remoteMac net.Hardwar
 			HardwareAddr: remoteMac,
 		},
 	}
+}
 
+func (c *Converger) addNeighs(underlayIP, destAddr net.IP, remoteMac net.HardwareAddr) ([]netlink.Neigh, error) {
 	var currentNeighs []netlink.Neigh
-	for _, neigh := range neighs {
+	for _, neigh := range c.neighs(underlayIP, destAddr, remoteMac) {
 		err := c.NetlinkAdapter.NeighSet(neigh)
 		if err != nil {
 			return nil, fmt.Errorf("set neigh: %s", err)
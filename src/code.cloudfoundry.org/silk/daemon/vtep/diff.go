@@ -0,0 +1,135 @@
+package vtep
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+
+	"code.cloudfoundry.org/silk/controller"
+	"github.com/vishvananda/netlink"
+)
+
+type Route struct {
+	Destination string `json:"destination"`
+	Gateway     string `json:"gateway"`
+	Source      string `json:"source"`
+}
+
+type Neighbor struct {
+	Type         string `json:"type"`
+	IP           string `json:"ip"`
+	HardwareAddr string `json:"hardware_addr"`
+}
+
+// State is the set of routes and neighbors on the VTEP that belong to peer leases.
+type State struct {
+	Routes    []Route    `json:"routes"`
+	Neighbors []Neighbor `json:"neighbors"`
+}
+
+// Diff compares the routes and neighbors programmed on the VTEP with those the
+// converger would program for a set of leases.
+type Diff struct {
+	Programmed       State      `json:"programmed"`
+	Desired          State      `json:"desired"`
+	MissingRoutes    []Route    `json:"missing_routes"`
+	ExtraRoutes      []Route    `json:"extra_routes"`
+	MissingNeighbors []Neighbor `json:"missing_neighbors"`
+	ExtraNeighbors   []Neighbor `json:"extra_neighbors"`
+}
+
+// Diff reads the VTEP state without changing it.
+func (c *Converger) Diff(leases []controller.Lease) (Diff, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	programmedRoutes, programmedNeighs, err := c.getPreviousState(c.LocalVTEP.Index)
+	if err != nil {
+		return Diff{}, err
+	}
+
+	var desiredRoutes []netlink.Route
+	var desiredNeighs []netlink.Neigh
+	for _, lease := range leases {
+		destAddr, destNet, err := net.ParseCIDR(lease.OverlaySubnet)
+		if err != nil {
+			return Diff{}, fmt.Errorf("parse lease: %s", err)
+		}
+
+		if c.isLocal(destNet) || !c.OverlayNetwork.Contains(destNet.IP) {
+			continue
+		}
+
+		underlayIP := net.ParseIP(lease.UnderlayIP)
+		if underlayIP == nil {
+			return Diff{}, fmt.Errorf("invalid underlay ip: %s", lease.UnderlayIP)
+		}
+
+		remoteMac, err := net.ParseMAC(lease.OverlayHardwareAddr)
+		if err != nil {
+			return Diff{}, fmt.Errorf("invalid hardware addr: %s", lease.OverlayHardwareAddr)
+		}
+
+		desiredRoutes = append(desiredRoutes, c.route(destNet, destAddr))
+		for _, neigh := range c.neighs(underlayIP, destAddr, remoteMac) {
+			desiredNeighs = append(desiredNeighs, *neigh)
+		}
+	}
+
+	var peerRoutes []netlink.Route
+	for _, route := range programmedRoutes {
+		if route.LinkIndex == c.LocalVTEP.Index && c.OverlayNetwork.Contains(route.Gw) {
+			peerRoutes = append(peerRoutes, route)
+		}
+	}
+
+	var peerNeighs []netlink.Neigh
+	for _, neigh := range programmedNeighs {
+		if neigh.LinkIndex == c.LocalVTEP.Index {
+			peerNeighs = append(peerNeighs, neigh)
+		}
+	}
+
+	return Diff{
+		Programmed: State{
+			Routes:    routeViews(peerRoutes),
+			Neighbors: neighViews(peerNeighs),
+		},
+		Desired: State{
+			Routes:    routeViews(desiredRoutes),
+			Neighbors: neighViews(desiredNeighs),
+		},
+		MissingRoutes:    routeViews(getDeletedRoutes(desiredRoutes, peerRoutes)),
+		ExtraRoutes:      routeViews(getDeletedRoutes(peerRoutes, desiredRoutes)),
+		MissingNeighbors: neighViews(getDeletedNeighs(desiredNeighs, peerNeighs)),
+		ExtraNeighbors:   neighViews(getDeletedNeighs(peerNeighs, desiredNeighs)),
+	}, nil
+}
+
+func routeViews(routes []netlink.Route) []Route {
+	views := []Route{}
+	for _, route := range routes {
+		views = append(views, Route{
+			Destination: route.Dst.String(),
+			Gateway:     route.Gw.String(),
+			Source:      route.Src.String(),
+		})
+	}
+	return views
+}
+
+func neighViews(neighs []netlink.Neigh) []Neighbor {
+	views := []Neighbor{}
+	for _, neigh := range neighs {
+		neighType := "arp"
+		if neigh.Family == syscall.AF_BRIDGE {
+			neighType = "fdb"
+		}
+		views = append(views, Neighbor{
+			Type:         neighType,
+			IP:           neigh.IP.String(),
+			HardwareAddr: neigh.HardwareAddr.String(),
+		})
+	}
+	return views
+}
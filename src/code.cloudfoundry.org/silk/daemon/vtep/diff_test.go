@@ -0,0 +1,122 @@
+package vtep_test
+
+import (
+	"errors"
+	"net"
+	"syscall"
+
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/daemon/vtep"
+	"code.cloudfoundry.org/silk/daemon/vtep/fakes"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/vishvananda/netlink"
+)
+
+var _ = Describe("Converger", func() {
+	Describe("Diff", func() {
+		var (
+			fakeNetlink *fakes.NetlinkAdapter
+			converger   *vtep.Converger
+			leases      []controller.Lease
+			remoteMac   net.HardwareAddr
+			staleMac    net.HardwareAddr
+		)
+
+		BeforeEach(func() {
+			fakeNetlink = &fakes.NetlinkAdapter{}
+			_, localSubnet, _ := net.ParseCIDR("10.255.32.0/24")
+			_, overlayNet, _ := net.ParseCIDR("10.255.0.0/16")
+			converger = &vtep.Converger{
+				OverlayNetwork: overlayNet,
+				LocalSubnet:    localSubnet,
+				LocalVTEP:      net.Interface{Index: 42, Name: "silk-vtep"},
+				NetlinkAdapter: fakeNetlink,
+				Logger:         lagertest.NewTestLogger("test"),
+			}
+			remoteMac, _ = net.ParseMAC("ee:ee:aa:aa:aa:ff")
+			staleMac, _ = net.ParseMAC("ee:ee:aa:aa:aa:01")
+			leases = []controller.Lease{
+				{UnderlayIP: "10.10.0.4", OverlaySubnet: "10.255.32.0/24", OverlayHardwareAddr: "ee:ee:aa:bb:cc:dd"},
+				{UnderlayIP: "10.10.0.5", OverlaySubnet: "10.255.19.0/24", OverlayHardwareAddr: remoteMac.String()},
+			}
+
+			_, staleNet, _ := net.ParseCIDR("10.255.7.0/24")
+			fakeNetlink.RouteListReturns([]netlink.Route{
+				{
+					LinkIndex: 42,
+					Scope:     netlink.SCOPE_UNIVERSE,
+					Dst:       staleNet,
+					Gw:        net.ParseIP("10.255.7.0"),
+					Src:       net.ParseIP("10.255.32.0"),
+				},
+				{
+					LinkIndex: 42,
+					Scope:     netlink.SCOPE_LINK,
+					Dst:       overlayNet,
+				},
+			}, nil)
+			fakeNetlink.ARPListReturns([]netlink.Neigh{{
+				LinkIndex:    42,
+				State:        netlink.NUD_PERMANENT,
+				Type:         syscall.RTN_UNICAST,
+				IP:           net.ParseIP("10.255.19.0"),
+				HardwareAddr: remoteMac,
+			}}, nil)
+			fakeNetlink.FDBListReturns([]netlink.Neigh{{
+				LinkIndex:    42,
+				State:        netlink.NUD_PERMANENT,
+				Family:       syscall.AF_BRIDGE,
+				IP:           net.ParseIP("10.10.0.7"),
+				HardwareAddr: staleMac,
+			}}, nil)
+		})
+
+		It("reports the programmed and desired state and the differences", func() {
+			diff, err := converger.Diff(leases)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(diff.Programmed.Routes).To(Equal([]vtep.Route{
+				{Destination: "10.255.7.0/24", Gateway: "10.255.7.0", Source: "10.255.32.0"},
+			}))
+			Expect(diff.Desired.Routes).To(Equal([]vtep.Route{
+				{Destination: "10.255.19.0/24", Gateway: "10.255.19.0", Source: "10.255.32.0"},
+			}))
+			Expect(diff.MissingRoutes).To(Equal(diff.Desired.Routes))
+			Expect(diff.ExtraRoutes).To(Equal(diff.Programmed.Routes))
+
+			Expect(diff.Desired.Neighbors).To(ConsistOf(
+				vtep.Neighbor{Type: "arp", IP: "10.255.19.0", HardwareAddr: "ee:ee:aa:aa:aa:ff"},
+				vtep.Neighbor{Type: "fdb", IP: "10.10.0.5", HardwareAddr: "ee:ee:aa:aa:aa:ff"},
+			))
+			Expect(diff.MissingNeighbors).To(Equal([]vtep.Neighbor{
+				{Type: "fdb", IP: "10.10.0.5", HardwareAddr: "ee:ee:aa:aa:aa:ff"},
+			}))
+			Expect(diff.ExtraNeighbors).To(Equal([]vtep.Neighbor{
+				{Type: "fdb", IP: "10.10.0.7", HardwareAddr: "ee:ee:aa:aa:aa:01"},
+			}))
+		})
+
+		It("does not change the vtep", func() {
+			_, err := converger.Diff(leases)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeNetlink.RouteReplaceCallCount()).To(Equal(0))
+			Expect(fakeNetlink.RouteDelCallCount()).To(Equal(0))
+			Expect(fakeNetlink.NeighSetCallCount()).To(Equal(0))
+			Expect(fakeNetlink.NeighDelCallCount()).To(Equal(0))
+		})
+
+		Context("when listing routes fails", func() {
+			BeforeEach(func() {
+				fakeNetlink.RouteListReturns(nil, errors.New("banana"))
+			})
+
+			It("returns an error", func() {
+				_, err := converger.Diff(leases)
+				Expect(err).To(MatchError("list routes: banana"))
+			})
+		})
+	})
+})
@@ -0,0 +1,341 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/silk/controller"
+)
+
+type LeaseClient struct {
+	AcquireSingleOverlayIPLeaseStub        func(string) (controller.Lease, error)
+	acquireSingleOverlayIPLeaseMutex       sync.RWMutex
+	acquireSingleOverlayIPLeaseArgsForCall []struct {
+		arg1 string
+	}
+	acquireSingleOverlayIPLeaseReturns struct {
+		result1 controller.Lease
+		result2 error
+	}
+	acquireSingleOverlayIPLeaseReturnsOnCall map[int]struct {
+		result1 controller.Lease
+		result2 error
+	}
+	AcquireSubnetLeaseStub        func(string) (controller.Lease, error)
+	acquireSubnetLeaseMutex       sync.RWMutex
+	acquireSubnetLeaseArgsForCall []struct {
+		arg1 string
+	}
+	acquireSubnetLeaseReturns struct {
+		result1 controller.Lease
+		result2 error
+	}
+	acquireSubnetLeaseReturnsOnCall map[int]struct {
+		result1 controller.Lease
+		result2 error
+	}
+	ReleaseSubnetLeaseStub        func(string) error
+	releaseSubnetLeaseMutex       sync.RWMutex
+	releaseSubnetLeaseArgsForCall []struct {
+		arg1 string
+	}
+	releaseSubnetLeaseReturns struct {
+		result1 error
+	}
+	releaseSubnetLeaseReturnsOnCall map[int]struct {
+		result1 error
+	}
+	RenewSubnetLeaseStub        func(controller.Lease) error
+	renewSubnetLeaseMutex       sync.RWMutex
+	renewSubnetLeaseArgsForCall []struct {
+		arg1 controller.Lease
+	}
+	renewSubnetLeaseReturns struct {
+		result1 error
+	}
+	renewSubnetLeaseReturnsOnCall map[int]struct {
+		result1 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *LeaseClient) AcquireSingleOverlayIPLease(arg1 string) (controller.Lease, error) {
+	fake.acquireSingleOverlayIPLeaseMutex.Lock()
+	ret, specificReturn := fake.acquireSingleOverlayIPLeaseReturnsOnCall[len(fake.acquireSingleOverlayIPLeaseArgsForCall)]
+	fake.acquireSingleOverlayIPLeaseArgsForCall = append(fake.acquireSingleOverlayIPLeaseArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.AcquireSingleOverlayIPLeaseStub
+	fakeReturns := fake.acquireSingleOverlayIPLeaseReturns
+	fake.recordInvocation("AcquireSingleOverlayIPLease", []interface{}{arg1})
+	fake.acquireSingleOverlayIPLeaseMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *LeaseClient) AcquireSingleOverlayIPLeaseCallCount() int {
+	fake.acquireSingleOverlayIPLeaseMutex.RLock()
+	defer fake.acquireSingleOverlayIPLeaseMutex.RUnlock()
+	return len(fake.acquireSingleOverlayIPLeaseArgsForCall)
+}
+
+func (fake *LeaseClient) AcquireSingleOverlayIPLeaseCalls(stub func(string) (controller.Lease, error)) {
+	fake.acquireSingleOverlayIPLeaseMutex.Lock()
+	defer fake.acquireSingleOverlayIPLeaseMutex.Unlock()
+	fake.AcquireSingleOverlayIPLeaseStub = stub
+}
+
+func (fake *LeaseClient) AcquireSingleOverlayIPLeaseArgsForCall(i int) string {
+	fake.acquireSingleOverlayIPLeaseMutex.RLock()
+	defer fake.acquireSingleOverlayIPLeaseMutex.RUnlock()
+	argsForCall := fake.acquireSingleOverlayIPLeaseArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *LeaseClient) AcquireSingleOverlayIPLeaseReturns(result1 controller.Lease, result2 error) {
+	fake.acquireSingleOverlayIPLeaseMutex.Lock()
+	defer fake.acquireSingleOverlayIPLeaseMutex.Unlock()
+	fake.AcquireSingleOverlayIPLeaseStub = nil
+	fake.acquireSingleOverlayIPLeaseReturns = struct {
+		result1 controller.Lease
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *LeaseClient) AcquireSingleOverlayIPLeaseReturnsOnCall(i int, result1 controller.Lease, result2 error) {
+	fake.acquireSingleOverlayIPLeaseMutex.Lock()
+	defer fake.acquireSingleOverlayIPLeaseMutex.Unlock()
+	fake.AcquireSingleOverlayIPLeaseStub = nil
+	if fake.acquireSingleOverlayIPLeaseReturnsOnCall == nil {
+		fake.acquireSingleOverlayIPLeaseReturnsOnCall = make(map[int]struct {
+			result1 controller.Lease
+			result2 error
+		})
+	}
+	fake.acquireSingleOverlayIPLeaseReturnsOnCall[i] = struct {
+		result1 controller.Lease
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *LeaseClient) AcquireSubnetLease(arg1 string) (controller.Lease, error) {
+	fake.acquireSubnetLeaseMutex.Lock()
+	ret, specificReturn := fake.acquireSubnetLeaseReturnsOnCall[len(fake.acquireSubnetLeaseArgsForCall)]
+	fake.acquireSubnetLeaseArgsForCall = append(fake.acquireSubnetLeaseArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.AcquireSubnetLeaseStub
+	fakeReturns := fake.acquireSubnetLeaseReturns
+	fake.recordInvocation("AcquireSubnetLease", []interface{}{arg1})
+	fake.acquireSubnetLeaseMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *LeaseClient) AcquireSubnetLeaseCallCount() int {
+	fake.acquireSubnetLeaseMutex.RLock()
+	defer fake.acquireSubnetLeaseMutex.RUnlock()
+	return len(fake.acquireSubnetLeaseArgsForCall)
+}
+
+func (fake *LeaseClient) AcquireSubnetLeaseCalls(stub func(string) (controller.Lease, error)) {
+	fake.acquireSubnetLeaseMutex.Lock()
+	defer fake.acquireSubnetLeaseMutex.Unlock()
+	fake.AcquireSubnetLeaseStub = stub
+}
+
+func (fake *LeaseClient) AcquireSubnetLeaseArgsForCall(i int) string {
+	fake.acquireSubnetLeaseMutex.RLock()
+	defer fake.acquireSubnetLeaseMutex.RUnlock()
+	argsForCall := fake.acquireSubnetLeaseArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *LeaseClient) AcquireSubnetLeaseReturns(result1 controller.Lease, result2 error) {
+	fake.acquireSubnetLeaseMutex.Lock()
+	defer fake.acquireSubnetLeaseMutex.Unlock()
+	fake.AcquireSubnetLeaseStub = nil
+	fake.acquireSubnetLeaseReturns = struct {
+		result1 controller.Lease
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *LeaseClient) AcquireSubnetLeaseReturnsOnCall(i int, result1 controller.Lease, result2 error) {
+	fake.acquireSubnetLeaseMutex.Lock()
+	defer fake.acquireSubnetLeaseMutex.Unlock()
+	fake.AcquireSubnetLeaseStub = nil
+	if fake.acquireSubnetLeaseReturnsOnCall == nil {
+		fake.acquireSubnetLeaseReturnsOnCall = make(map[int]struct {
+			result1 controller.Lease
+			result2 error
+		})
+	}
+	fake.acquireSubnetLeaseReturnsOnCall[i] = struct {
+		result1 controller.Lease
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *LeaseClient) ReleaseSubnetLease(arg1 string) error {
+	fake.releaseSubnetLeaseMutex.Lock()
+	ret, specificReturn := fake.releaseSubnetLeaseReturnsOnCall[len(fake.releaseSubnetLeaseArgsForCall)]
+	fake.releaseSubnetLeaseArgsForCall = append(fake.releaseSubnetLeaseArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.ReleaseSubnetLeaseStub
+	fakeReturns := fake.releaseSubnetLeaseReturns
+	fake.recordInvocation("ReleaseSubnetLease", []interface{}{arg1})
+	fake.releaseSubnetLeaseMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *LeaseClient) ReleaseSubnetLeaseCallCount() int {
+	fake.releaseSubnetLeaseMutex.RLock()
+	defer fake.releaseSubnetLeaseMutex.RUnlock()
+	return len(fake.releaseSubnetLeaseArgsForCall)
+}
+
+func (fake *LeaseClient) ReleaseSubnetLeaseCalls(stub func(string) error) {
+	fake.releaseSubnetLeaseMutex.Lock()
+	defer fake.releaseSubnetLeaseMutex.Unlock()
+	fake.ReleaseSubnetLeaseStub = stub
+}
+
+func (fake *LeaseClient) ReleaseSubnetLeaseArgsForCall(i int) string {
+	fake.releaseSubnetLeaseMutex.RLock()
+	defer fake.releaseSubnetLeaseMutex.RUnlock()
+	argsForCall := fake.releaseSubnetLeaseArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *LeaseClient) ReleaseSubnetLeaseReturns(result1 error) {
+	fake.releaseSubnetLeaseMutex.Lock()
+	defer fake.releaseSubnetLeaseMutex.Unlock()
+	fake.ReleaseSubnetLeaseStub = nil
+	fake.releaseSubnetLeaseReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *LeaseClient) ReleaseSubnetLeaseReturnsOnCall(i int, result1 error) {
+	fake.releaseSubnetLeaseMutex.Lock()
+	defer fake.releaseSubnetLeaseMutex.Unlock()
+	fake.ReleaseSubnetLeaseStub = nil
+	if fake.releaseSubnetLeaseReturnsOnCall == nil {
+		fake.releaseSubnetLeaseReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.releaseSubnetLeaseReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *LeaseClient) RenewSubnetLease(arg1 controller.Lease) error {
+	fake.renewSubnetLeaseMutex.Lock()
+	ret, specificReturn := fake.renewSubnetLeaseReturnsOnCall[len(fake.renewSubnetLeaseArgsForCall)]
+	fake.renewSubnetLeaseArgsForCall = append(fake.renewSubnetLeaseArgsForCall, struct {
+		arg1 controller.Lease
+	}{arg1})
+	stub := fake.RenewSubnetLeaseStub
+	fakeReturns := fake.renewSubnetLeaseReturns
+	fake.recordInvocation("RenewSubnetLease", []interface{}{arg1})
+	fake.renewSubnetLeaseMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *LeaseClient) RenewSubnetLeaseCallCount() int {
+	fake.renewSubnetLeaseMutex.RLock()
+	defer fake.renewSubnetLeaseMutex.RUnlock()
+	return len(fake.renewSubnetLeaseArgsForCall)
+}
+
+func (fake *LeaseClient) RenewSubnetLeaseCalls(stub func(controller.Lease) error) {
+	fake.renewSubnetLeaseMutex.Lock()
+	defer fake.renewSubnetLeaseMutex.Unlock()
+	fake.RenewSubnetLeaseStub = stub
+}
+
+func (fake *LeaseClient) RenewSubnetLeaseArgsForCall(i int) controller.Lease {
+	fake.renewSubnetLeaseMutex.RLock()
+	defer fake.renewSubnetLeaseMutex.RUnlock()
+	argsForCall := fake.renewSubnetLeaseArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *LeaseClient) RenewSubnetLeaseReturns(result1 error) {
+	fake.renewSubnetLeaseMutex.Lock()
+	defer fake.renewSubnetLeaseMutex.Unlock()
+	fake.RenewSubnetLeaseStub = nil
+	fake.renewSubnetLeaseReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *LeaseClient) RenewSubnetLeaseReturnsOnCall(i int, result1 error) {
+	fake.renewSubnetLeaseMutex.Lock()
+	defer fake.renewSubnetLeaseMutex.Unlock()
+	fake.RenewSubnetLeaseStub = nil
+	if fake.renewSubnetLeaseReturnsOnCall == nil {
+		fake.renewSubnetLeaseReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.renewSubnetLeaseReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *LeaseClient) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.acquireSingleOverlayIPLeaseMutex.RLock()
+	defer fake.acquireSingleOverlayIPLeaseMutex.RUnlock()
+	fake.acquireSubnetLeaseMutex.RLock()
+	defer fake.acquireSubnetLeaseMutex.RUnlock()
+	fake.releaseSubnetLeaseMutex.RLock()
+	defer fake.releaseSubnetLeaseMutex.RUnlock()
+	fake.renewSubnetLeaseMutex.RLock()
+	defer fake.renewSubnetLeaseMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *LeaseClient) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
@@ -0,0 +1,183 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/silk/daemon/vtep"
+)
+
+type VTEPFactory struct {
+	CreateVTEPStub        func(*vtep.Config) error
+	createVTEPMutex       sync.RWMutex
+	createVTEPArgsForCall []struct {
+		arg1 *vtep.Config
+	}
+	createVTEPReturns struct {
+		result1 error
+	}
+	createVTEPReturnsOnCall map[int]struct {
+		result1 error
+	}
+	DeleteVTEPStub        func(string) error
+	deleteVTEPMutex       sync.RWMutex
+	deleteVTEPArgsForCall []struct {
+		arg1 string
+	}
+	deleteVTEPReturns struct {
+		result1 error
+	}
+	deleteVTEPReturnsOnCall map[int]struct {
+		result1 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *VTEPFactory) CreateVTEP(arg1 *vtep.Config) error {
+	fake.createVTEPMutex.Lock()
+	ret, specificReturn := fake.createVTEPReturnsOnCall[len(fake.createVTEPArgsForCall)]
+	fake.createVTEPArgsForCall = append(fake.createVTEPArgsForCall, struct {
+		arg1 *vtep.Config
+	}{arg1})
+	stub := fake.CreateVTEPStub
+	fakeReturns := fake.createVTEPReturns
+	fake.recordInvocation("CreateVTEP", []interface{}{arg1})
+	fake.createVTEPMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *VTEPFactory) CreateVTEPCallCount() int {
+	fake.createVTEPMutex.RLock()
+	defer fake.createVTEPMutex.RUnlock()
+	return len(fake.createVTEPArgsForCall)
+}
+
+func (fake *VTEPFactory) CreateVTEPCalls(stub func(*vtep.Config) error) {
+	fake.createVTEPMutex.Lock()
+	defer fake.createVTEPMutex.Unlock()
+	fake.CreateVTEPStub = stub
+}
+
+func (fake *VTEPFactory) CreateVTEPArgsForCall(i int) *vtep.Config {
+	fake.createVTEPMutex.RLock()
+	defer fake.createVTEPMutex.RUnlock()
+	argsForCall := fake.createVTEPArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *VTEPFactory) CreateVTEPReturns(result1 error) {
+	fake.createVTEPMutex.Lock()
+	defer fake.createVTEPMutex.Unlock()
+	fake.CreateVTEPStub = nil
+	fake.createVTEPReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *VTEPFactory) CreateVTEPReturnsOnCall(i int, result1 error) {
+	fake.createVTEPMutex.Lock()
+	defer fake.createVTEPMutex.Unlock()
+	fake.CreateVTEPStub = nil
+	if fake.createVTEPReturnsOnCall == nil {
+		fake.createVTEPReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.createVTEPReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *VTEPFactory) DeleteVTEP(arg1 string) error {
+	fake.deleteVTEPMutex.Lock()
+	ret, specificReturn := fake.deleteVTEPReturnsOnCall[len(fake.deleteVTEPArgsForCall)]
+	fake.deleteVTEPArgsForCall = append(fake.deleteVTEPArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.DeleteVTEPStub
+	fakeReturns := fake.deleteVTEPReturns
+	fake.recordInvocation("DeleteVTEP", []interface{}{arg1})
+	fake.deleteVTEPMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *VTEPFactory) DeleteVTEPCallCount() int {
+	fake.deleteVTEPMutex.RLock()
+	defer fake.deleteVTEPMutex.RUnlock()
+	return len(fake.deleteVTEPArgsForCall)
+}
+
+func (fake *VTEPFactory) DeleteVTEPCalls(stub func(string) error) {
+	fake.deleteVTEPMutex.Lock()
+	defer fake.deleteVTEPMutex.Unlock()
+	fake.DeleteVTEPStub = stub
+}
+
+func (fake *VTEPFactory) DeleteVTEPArgsForCall(i int) string {
+	fake.deleteVTEPMutex.RLock()
+	defer fake.deleteVTEPMutex.RUnlock()
+	argsForCall := fake.deleteVTEPArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *VTEPFactory) DeleteVTEPReturns(result1 error) {
+	fake.deleteVTEPMutex.Lock()
+	defer fake.deleteVTEPMutex.Unlock()
+	fake.DeleteVTEPStub = nil
+	fake.deleteVTEPReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *VTEPFactory) DeleteVTEPReturnsOnCall(i int, result1 error) {
+	fake.deleteVTEPMutex.Lock()
+	defer fake.deleteVTEPMutex.Unlock()
+	fake.DeleteVTEPStub = nil
+	if fake.deleteVTEPReturnsOnCall == nil {
+		fake.deleteVTEPReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.deleteVTEPReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *VTEPFactory) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.createVTEPMutex.RLock()
+	defer fake.createVTEPMutex.RUnlock()
+	fake.deleteVTEPMutex.RLock()
+	defer fake.deleteVTEPMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *VTEPFactory) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
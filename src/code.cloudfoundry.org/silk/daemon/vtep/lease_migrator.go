@@ -0,0 +1,146 @@
+package vtep
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	clientConfig "code.cloudfoundry.org/silk/client/config"
+	"code.cloudfoundry.org/silk/controller"
+)
+
+//go:generate counterfeiter -o fakes/leaseClient.go --fake-name LeaseClient . leaseClient
+type leaseClient interface {
+	ReleaseSubnetLease(underlayIP string) error
+	RenewSubnetLease(controller.Lease) error
+	AcquireSubnetLease(underlayIP string) (controller.Lease, error)
+	AcquireSingleOverlayIPLease(underlayIP string) (controller.Lease, error)
+}
+
+//go:generate counterfeiter -o fakes/configCreator.go --fake-name ConfigCreator . configCreator
+type configCreator interface {
+	Create(clientConfig.Config, controller.Lease) (*Config, error)
+}
+
+//go:generate counterfeiter -o fakes/vtepFactory.go --fake-name VTEPFactory . vtepFactory
+type vtepFactory interface {
+	CreateVTEP(*Config) error
+	DeleteVTEP(string) error
+}
+
+const (
+	defaultRenewAttempts = 3
+	defaultRenewBackoff  = time.Second
+)
+
+// LeaseMigrator moves the local lease and VTEP to a new underlay IP.
+// It keeps the overlay subnet of the lease when the silk-controller allows it,
+// so that running containers keep their overlay addresses. A failed renew is
+// retried RenewAttempts times, doubling RenewBackoff between attempts.
+type LeaseMigrator struct {
+	ControllerClient leaseClient
+	ConfigCreator    configCreator
+	Factory          vtepFactory
+	NetAdapter       netAdapter
+	Converger        *Converger
+	ClientConfig     clientConfig.Config
+	ContainerCount   func() (int, error)
+	RenewAttempts    int
+	RenewBackoff     time.Duration
+	Logger           lager.Logger
+}
+
+func (m *LeaseMigrator) Migrate(lease controller.Lease, underlayIP string) (controller.Lease, error) {
+	logger := m.Logger.Session("migrate-lease", lager.Data{"lease": lease, "underlay_ip": underlayIP})
+
+	// The controller only lets the subnet move to the new underlay IP once the
+	// lease of the old one is released. Until the renew succeeds, the subnet
+	// is unleased and another cell may acquire it, so the renew is retried
+	// before falling back to a new subnet.
+	err := m.ControllerClient.ReleaseSubnetLease(lease.UnderlayIP)
+	if err != nil {
+		return controller.Lease{}, fmt.Errorf("release lease: %s", err)
+	}
+
+	newLease := lease
+	newLease.UnderlayIP = underlayIP
+	err = m.renew(logger, newLease)
+	if err != nil {
+		logger.Error("keep-overlay-subnet", err)
+
+		containerCount, err := m.ContainerCount()
+		if err != nil {
+			return controller.Lease{}, fmt.Errorf("count containers: %s", err)
+		}
+		if containerCount != 0 {
+			return controller.Lease{}, fmt.Errorf("overlay subnet %s can not be kept and has containers: %d", lease.OverlaySubnet, containerCount)
+		}
+
+		newLease, err = m.acquire(underlayIP)
+		if err != nil {
+			return controller.Lease{}, fmt.Errorf("acquire lease: %s", err)
+		}
+	}
+
+	cfg := m.ClientConfig
+	cfg.UnderlayIP = underlayIP
+
+	err = m.Factory.DeleteVTEP(cfg.VTEPName)
+	if err != nil {
+		logger.Error("delete-vtep", err)
+	}
+
+	vtepConf, err := m.ConfigCreator.Create(cfg, newLease)
+	if err != nil {
+		return controller.Lease{}, fmt.Errorf("create vtep config: %s", err)
+	}
+
+	err = m.Factory.CreateVTEP(vtepConf)
+	if err != nil {
+		return controller.Lease{}, fmt.Errorf("create vtep: %s", err)
+	}
+
+	vtepInterface, err := m.NetAdapter.InterfaceByName(cfg.VTEPName)
+	if err != nil {
+		return controller.Lease{}, fmt.Errorf("find vtep: %s", err)
+	}
+
+	_, localSubnet, err := net.ParseCIDR(newLease.OverlaySubnet)
+	if err != nil {
+		return controller.Lease{}, fmt.Errorf("parse local subnet: %s", err)
+	}
+
+	m.Converger.SetLocal(*vtepInterface, localSubnet)
+
+	logger.Info("migrated", lager.Data{"new_lease": newLease})
+	return newLease, nil
+}
+
+func (m *LeaseMigrator) renew(logger lager.Logger, lease controller.Lease) error {
+	attempts := m.RenewAttempts
+	if attempts < 1 {
+		attempts = defaultRenewAttempts
+	}
+	backoff := m.RenewBackoff
+	if backoff == 0 {
+		backoff = defaultRenewBackoff
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := m.ControllerClient.RenewSubnetLease(lease)
+		if err == nil || attempt == attempts {
+			return err
+		}
+		logger.Error("renew-lease", err, lager.Data{"attempt": attempt})
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (m *LeaseMigrator) acquire(underlayIP string) (controller.Lease, error) {
+	if m.ClientConfig.SingleIPOnly {
+		return m.ControllerClient.AcquireSingleOverlayIPLease(underlayIP)
+	}
+	return m.ControllerClient.AcquireSubnetLease(underlayIP)
+}
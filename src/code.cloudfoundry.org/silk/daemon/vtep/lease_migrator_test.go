@@ -0,0 +1,180 @@
+package vtep_test
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	clientConfig "code.cloudfoundry.org/silk/client/config"
+	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/daemon/vtep"
+	"code.cloudfoundry.org/silk/daemon/vtep/fakes"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LeaseMigrator", func() {
+	var (
+		migrator         *vtep.LeaseMigrator
+		controllerClient *fakes.LeaseClient
+		configCreator    *fakes.ConfigCreator
+		factory          *fakes.VTEPFactory
+		netAdapter       *fakes.NetAdapter
+		converger        *vtep.Converger
+		containerCount   int
+		vtepConfig       *vtep.Config
+		lease            controller.Lease
+	)
+
+	BeforeEach(func() {
+		controllerClient = &fakes.LeaseClient{}
+		configCreator = &fakes.ConfigCreator{}
+		factory = &fakes.VTEPFactory{}
+		netAdapter = &fakes.NetAdapter{}
+		converger = &vtep.Converger{LocalVTEP: net.Interface{Index: 3}}
+		containerCount = 0
+
+		migrator = &vtep.LeaseMigrator{
+			ControllerClient: controllerClient,
+			ConfigCreator:    configCreator,
+			Factory:          factory,
+			NetAdapter:       netAdapter,
+			Converger:        converger,
+			ClientConfig: clientConfig.Config{
+				UnderlayIP: "172.244.17.0",
+				VTEPName:   "silk-vtep",
+			},
+			ContainerCount: func() (int, error) { return containerCount, nil },
+			RenewAttempts:  3,
+			RenewBackoff:   time.Millisecond,
+			Logger:         lagertest.NewTestLogger("test"),
+		}
+
+		lease = controller.Lease{
+			UnderlayIP:          "172.244.17.0",
+			OverlaySubnet:       "10.244.17.0/24",
+			OverlayHardwareAddr: "ee:ee:0a:f4:11:00",
+		}
+		vtepConfig = &vtep.Config{VTEPName: "silk-vtep"}
+		configCreator.CreateReturns(vtepConfig, nil)
+		netAdapter.InterfaceByNameReturns(&net.Interface{Index: 7, Name: "silk-vtep"}, nil)
+	})
+
+	It("moves the lease to the new underlay ip and keeps the overlay subnet", func() {
+		newLease, err := migrator.Migrate(lease, "172.244.99.0")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(newLease).To(Equal(controller.Lease{
+			UnderlayIP:          "172.244.99.0",
+			OverlaySubnet:       "10.244.17.0/24",
+			OverlayHardwareAddr: "ee:ee:0a:f4:11:00",
+		}))
+
+		Expect(controllerClient.ReleaseSubnetLeaseArgsForCall(0)).To(Equal("172.244.17.0"))
+		Expect(controllerClient.RenewSubnetLeaseArgsForCall(0)).To(Equal(newLease))
+		Expect(controllerClient.AcquireSubnetLeaseCallCount()).To(Equal(0))
+
+		By("recreating the vtep on the new underlay ip")
+		Expect(factory.DeleteVTEPArgsForCall(0)).To(Equal("silk-vtep"))
+		cfg, createdLease := configCreator.CreateArgsForCall(0)
+		Expect(cfg.UnderlayIP).To(Equal("172.244.99.0"))
+		Expect(createdLease).To(Equal(newLease))
+		Expect(factory.CreateVTEPArgsForCall(0)).To(Equal(vtepConfig))
+
+		By("pointing the converger at the new vtep")
+		Expect(converger.LocalVTEP.Index).To(Equal(7))
+		Expect(converger.LocalSubnet.String()).To(Equal("10.244.17.0/24"))
+	})
+
+	Context("when the renew fails after the old lease is released", func() {
+		BeforeEach(func() {
+			controllerClient.RenewSubnetLeaseReturnsOnCall(0, errors.New("banana"))
+			controllerClient.RenewSubnetLeaseReturnsOnCall(1, errors.New("banana"))
+		})
+
+		It("retries the renew and keeps the overlay subnet", func() {
+			newLease, err := migrator.Migrate(lease, "172.244.99.0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(newLease.OverlaySubnet).To(Equal("10.244.17.0/24"))
+
+			Expect(controllerClient.ReleaseSubnetLeaseCallCount()).To(Equal(1))
+			Expect(controllerClient.RenewSubnetLeaseCallCount()).To(Equal(3))
+			Expect(controllerClient.AcquireSubnetLeaseCallCount()).To(Equal(0))
+			Expect(converger.LocalSubnet.String()).To(Equal("10.244.17.0/24"))
+		})
+	})
+
+	Context("when the overlay subnet can not be kept", func() {
+		BeforeEach(func() {
+			controllerClient.RenewSubnetLeaseReturns(errors.New("banana"))
+			controllerClient.AcquireSubnetLeaseReturns(controller.Lease{
+				UnderlayIP:          "172.244.99.0",
+				OverlaySubnet:       "10.244.42.0/24",
+				OverlayHardwareAddr: "ee:ee:0a:f4:2a:00",
+			}, nil)
+		})
+
+		It("acquires a new lease once the renew attempts are used up", func() {
+			newLease, err := migrator.Migrate(lease, "172.244.99.0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(controllerClient.RenewSubnetLeaseCallCount()).To(Equal(3))
+			Expect(newLease.OverlaySubnet).To(Equal("10.244.42.0/24"))
+			Expect(controllerClient.AcquireSubnetLeaseArgsForCall(0)).To(Equal("172.244.99.0"))
+			Expect(converger.LocalSubnet.String()).To(Equal("10.244.42.0/24"))
+		})
+
+		Context("when single ip only is configured", func() {
+			BeforeEach(func() {
+				migrator.ClientConfig.SingleIPOnly = true
+				controllerClient.AcquireSingleOverlayIPLeaseReturns(controller.Lease{
+					UnderlayIP:    "172.244.99.0",
+					OverlaySubnet: "10.244.42.1/32",
+				}, nil)
+			})
+
+			It("acquires a single overlay ip lease", func() {
+				_, err := migrator.Migrate(lease, "172.244.99.0")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(controllerClient.AcquireSingleOverlayIPLeaseCallCount()).To(Equal(1))
+				Expect(controllerClient.AcquireSubnetLeaseCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when there are containers on the overlay subnet", func() {
+			BeforeEach(func() {
+				containerCount = 2
+			})
+
+			It("returns an error without touching the vtep", func() {
+				_, err := migrator.Migrate(lease, "172.244.99.0")
+				Expect(err).To(MatchError("overlay subnet 10.244.17.0/24 can not be kept and has containers: 2"))
+				Expect(controllerClient.AcquireSubnetLeaseCallCount()).To(Equal(0))
+				Expect(factory.DeleteVTEPCallCount()).To(Equal(0))
+				Expect(converger.LocalVTEP.Index).To(Equal(3))
+			})
+		})
+	})
+
+	Context("when releasing the old lease fails", func() {
+		BeforeEach(func() {
+			controllerClient.ReleaseSubnetLeaseReturns(errors.New("kiwi"))
+		})
+
+		It("returns an error", func() {
+			_, err := migrator.Migrate(lease, "172.244.99.0")
+			Expect(err).To(MatchError("release lease: kiwi"))
+			Expect(factory.DeleteVTEPCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when creating the vtep fails", func() {
+		BeforeEach(func() {
+			factory.CreateVTEPReturns(errors.New("mango"))
+		})
+
+		It("returns an error", func() {
+			_, err := migrator.Migrate(lease, "172.244.99.0")
+			Expect(err).To(MatchError("create vtep: mango"))
+		})
+	})
+})
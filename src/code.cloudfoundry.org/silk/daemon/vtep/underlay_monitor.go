@@ -0,0 +1,54 @@
+package vtep
+
+import (
+	"fmt"
+
+	"github.com/vishvananda/netlink"
+)
+
+// UnderlayMonitor finds the IPv4 address of the underlay device that the VTEP
+// is bound to, so that the daemon can notice when the address changes.
+type UnderlayMonitor struct {
+	NetlinkAdapter netlinkAdapter
+	VTEPName       string
+}
+
+// UnderlayIP returns the current underlay IP while it is still assigned to the
+// underlay device. Otherwise it returns the first global unicast IPv4 address
+// of the device.
+func (m *UnderlayMonitor) UnderlayIP(current string) (string, error) {
+	link, err := m.NetlinkAdapter.LinkByName(m.VTEPName)
+	if err != nil {
+		return "", fmt.Errorf("find link %s: %s", m.VTEPName, err)
+	}
+
+	vxlan, ok := link.(*netlink.Vxlan)
+	if !ok || vxlan.VtepDevIndex == 0 {
+		return "", fmt.Errorf("link %s is not bound to an underlay device", m.VTEPName)
+	}
+
+	underlay, err := m.NetlinkAdapter.LinkByIndex(vxlan.VtepDevIndex)
+	if err != nil {
+		return "", fmt.Errorf("find underlay link: %s", err)
+	}
+
+	addresses, err := m.NetlinkAdapter.AddrList(underlay, netlink.FAMILY_V4)
+	if err != nil {
+		return "", fmt.Errorf("list addresses: %s", err)
+	}
+
+	var candidate string
+	for _, address := range addresses {
+		if address.IP.String() == current {
+			return current, nil
+		}
+		if candidate == "" && address.IP.IsGlobalUnicast() {
+			candidate = address.IP.String()
+		}
+	}
+
+	if candidate == "" {
+		return "", fmt.Errorf("no ipv4 address on underlay link %s", underlay.Attrs().Name)
+	}
+	return candidate, nil
+}
@@ -0,0 +1,85 @@
+package vtep_test
+
+import (
+	"errors"
+	"net"
+
+	"code.cloudfoundry.org/silk/daemon/vtep"
+	"code.cloudfoundry.org/silk/daemon/vtep/fakes"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/vishvananda/netlink"
+)
+
+var _ = Describe("UnderlayMonitor", func() {
+	var (
+		monitor      *vtep.UnderlayMonitor
+		fakeNetlink  *fakes.NetlinkAdapter
+		underlayLink *netlink.Device
+	)
+
+	BeforeEach(func() {
+		fakeNetlink = &fakes.NetlinkAdapter{}
+		monitor = &vtep.UnderlayMonitor{
+			NetlinkAdapter: fakeNetlink,
+			VTEPName:       "silk-vtep",
+		}
+		underlayLink = &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "eth0", Index: 4}}
+
+		fakeNetlink.LinkByNameReturns(&netlink.Vxlan{VtepDevIndex: 4}, nil)
+		fakeNetlink.LinkByIndexReturns(underlayLink, nil)
+		fakeNetlink.AddrListReturns([]netlink.Addr{
+			{IPNet: &net.IPNet{IP: net.ParseIP("127.0.0.1"), Mask: net.CIDRMask(8, 32)}},
+			{IPNet: &net.IPNet{IP: net.ParseIP("10.0.0.5"), Mask: net.CIDRMask(24, 32)}},
+			{IPNet: &net.IPNet{IP: net.ParseIP("10.0.0.6"), Mask: net.CIDRMask(24, 32)}},
+		}, nil)
+	})
+
+	It("returns the current underlay ip while it is still on the underlay device", func() {
+		ip, err := monitor.UnderlayIP("10.0.0.6")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ip).To(Equal("10.0.0.6"))
+
+		Expect(fakeNetlink.LinkByNameArgsForCall(0)).To(Equal("silk-vtep"))
+		Expect(fakeNetlink.LinkByIndexArgsForCall(0)).To(Equal(4))
+		link, family := fakeNetlink.AddrListArgsForCall(0)
+		Expect(link).To(Equal(underlayLink))
+		Expect(family).To(Equal(netlink.FAMILY_V4))
+	})
+
+	It("returns the first global unicast ip when the current ip is gone", func() {
+		ip, err := monitor.UnderlayIP("10.0.0.9")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ip).To(Equal("10.0.0.5"))
+	})
+
+	Context("when the vtep can not be found", func() {
+		BeforeEach(func() {
+			fakeNetlink.LinkByNameReturns(nil, errors.New("banana"))
+		})
+		It("returns an error", func() {
+			_, err := monitor.UnderlayIP("10.0.0.6")
+			Expect(err).To(MatchError("find link silk-vtep: banana"))
+		})
+	})
+
+	Context("when the vtep is not bound to an underlay device", func() {
+		BeforeEach(func() {
+			fakeNetlink.LinkByNameReturns(&netlink.Vxlan{}, nil)
+		})
+		It("returns an error", func() {
+			_, err := monitor.UnderlayIP("10.0.0.6")
+			Expect(err).To(MatchError("link silk-vtep is not bound to an underlay device"))
+		})
+	})
+
+	Context("when the underlay device has no usable address", func() {
+		BeforeEach(func() {
+			fakeNetlink.AddrListReturns(nil, nil)
+		})
+		It("returns an error", func() {
+			_, err := monitor.UnderlayIP("10.0.0.6")
+			Expect(err).To(MatchError("no ipv4 address on underlay link eth0"))
+		})
+	})
+})
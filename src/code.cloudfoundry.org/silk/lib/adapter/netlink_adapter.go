@@ -44,6 +44,16 @@ func (*NetlinkAdapter) NeighAddPermanentIPv4(index int, destIP net.IP, hwAddr ne
 	})
 }
 
+func (*NetlinkAdapter) NeighAddPermanentIPv6(index int, destIP net.IP, hwAddr net.HardwareAddr) error {
+	return netlink.NeighAdd(&netlink.Neigh{
+		LinkIndex:    index,
+		Family:       netlink.FAMILY_V6,
+		State:        netlink.NUD_PERMANENT,
+		IP:           destIP,
+		HardwareAddr: hwAddr,
+	})
+}
+
 func (*NetlinkAdapter) NeighSet(neigh *netlink.Neigh) error {
 	return netlink.NeighSet(neigh)
 }
@@ -52,6 +62,10 @@ func (*NetlinkAdapter) ARPList(linkIndex int) ([]netlink.Neigh, error) {
 	return netlink.NeighList(linkIndex, netlink.FAMILY_V4)
 }
 
+func (*NetlinkAdapter) NDPList(linkIndex int) ([]netlink.Neigh, error) {
+	return netlink.NeighList(linkIndex, netlink.FAMILY_V6)
+}
+
 func (*NetlinkAdapter) FDBList(linkIndex int) ([]netlink.Neigh, error) {
 	return netlink.NeighList(linkIndex, syscall.AF_BRIDGE)
 }
@@ -108,6 +122,10 @@ func (*NetlinkAdapter) QdiscAdd(qdisc netlink.Qdisc) error {
 	return netlink.QdiscAdd(qdisc)
 }
 
+func (*NetlinkAdapter) QdiscList(link netlink.Link) ([]netlink.Qdisc, error) {
+	return netlink.QdiscList(link)
+}
+
 func (*NetlinkAdapter) FilterAdd(filter netlink.Filter) error {
 	return netlink.FilterAdd(filter)
 }
@@ -2,12 +2,14 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"sync"
 	"time"
 
 	"code.cloudfoundry.org/lib/rules"
 
+	"code.cloudfoundry.org/cf-networking-helpers/runner"
 	"code.cloudfoundry.org/filelock"
 	"github.com/coreos/go-iptables/iptables"
 )
@@ -22,9 +24,14 @@ const (
 
 func main() {
 	lockFilePath := flag.String("lock-file", "", "path to iptables file")
+	firewallBackend := flag.String("firewall-backend", rules.IPTablesBackend, "firewall backend: iptables or nftables")
 	flag.Parse()
 
-	ipTablesAdapter, err := createIpTablesAdapter(*lockFilePath)
+	if err := rules.ValidateBackend(*firewallBackend); err != nil {
+		log.Fatal(err)
+	}
+
+	ipTablesAdapter, err := createIpTablesAdapter(*lockFilePath, *firewallBackend)
 	if err != nil {
 		log.Fatalf("Could not initialize iptables adapter: %s", err)
 	}
@@ -59,17 +66,21 @@ COMMIT
 	return err
 }
 
-func createIpTablesAdapter(iptablesLockFile string) (rules.IPTablesAdapter, error) {
-	ipt, err := iptables.New()
-	if err != nil {
-		return nil, err
-	}
-
+func createIpTablesAdapter(iptablesLockFile, firewallBackend string) (rules.IPTablesAdapter, error) {
 	iptLocker := &filelock.Locker{
 		FileLocker: filelock.NewLocker(iptablesLockFile),
 		Mutex:      &sync.Mutex{},
 	}
 
+	if firewallBackend == rules.NFTablesBackend {
+		return createNFTablesAdapter(iptLocker)
+	}
+
+	ipt, err := iptables.New()
+	if err != nil {
+		return nil, err
+	}
+
 	tables := &rules.LockedIPTables{
 		IPTables: ipt,
 		Locker:   iptLocker,
@@ -78,3 +89,25 @@ func createIpTablesAdapter(iptablesLockFile string) (rules.IPTablesAdapter, erro
 
 	return tables, nil
 }
+
+// createNFTablesAdapter moves any rules left in iptables over to nftables,
+// which only happens the first time the nftables backend starts on a cell.
+func createNFTablesAdapter(locker *filelock.Locker) (rules.IPTablesAdapter, error) {
+	nftables, err := rules.NewNFTables(locker)
+	if err != nil {
+		return nil, err
+	}
+
+	saver, err := runner.NewCommandRunner("iptables-save", true)
+	if err != nil {
+		log.Printf("skipping migration of iptables rules: %s", err)
+		return nftables, nil
+	}
+
+	err = nftables.MigrateFromIPTables(saver, &rules.Restorer{})
+	if err != nil {
+		return nil, fmt.Errorf("migrate iptables rules: %s", err)
+	}
+
+	return nftables, nil
+}
@@ -4,6 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -23,6 +24,7 @@ import (
 	"code.cloudfoundry.org/vxlan-policy-agent/config"
 	"code.cloudfoundry.org/vxlan-policy-agent/converger"
 	"code.cloudfoundry.org/vxlan-policy-agent/enforcer"
+	"code.cloudfoundry.org/vxlan-policy-agent/fqdn"
 	"code.cloudfoundry.org/vxlan-policy-agent/handlers"
 	"code.cloudfoundry.org/vxlan-policy-agent/planner"
 
@@ -137,20 +139,27 @@ func main() {
 		CacheMutex:      new(sync.RWMutex),
 	}
 
-	ipt, err := iptables.New()
-	if err != nil {
-		die(logger, "iptables-new", err)
-	}
-
 	iptLocker := &filelock.Locker{
 		FileLocker: filelock.NewLocker(conf.IPTablesLockFile),
 		Mutex:      &sync.Mutex{},
 	}
-	restorer := &rules.Restorer{}
-	lockedIPTables := &rules.LockedIPTables{
-		IPTables: ipt,
-		Locker:   iptLocker,
-		Restorer: restorer,
+
+	var lockedIPTables rules.IPTablesAdapter
+	if conf.FirewallBackend == rules.NFTablesBackend {
+		lockedIPTables, err = rules.NewNFTables(iptLocker)
+		if err != nil {
+			die(logger, "nftables-new", err)
+		}
+	} else {
+		ipt, err := iptables.New()
+		if err != nil {
+			die(logger, "iptables-new", err)
+		}
+		lockedIPTables = &rules.LockedIPTables{
+			IPTables: ipt,
+			Locker:   iptLocker,
+			Restorer: &rules.Restorer{},
+		}
 	}
 
 	metricsSender := &metrics.MetricsSender{
@@ -171,9 +180,36 @@ func main() {
 		RatePerSec: conf.OutConn.RatePerSec,
 	}
 
+	// Sets are created and destroyed under the iptables lock, so that the
+	// CNI plugin can not lose a shared set between creating it and restoring
+	// the rules that match it.
+	var ipSets rules.IPSetAdapter
+	if conf.IPTablesASGIPSets {
+		ipSets = &rules.LockedIPSets{IPSets: &rules.IPSets{}, Locker: iptLocker}
+	}
+
+	var fqdnSets *fqdn.Sets
+	converter := &netrules.RuleConverter{Logger: logger, IPSets: ipSets}
+	if conf.EnableASGFQDNs {
+		resolver, err := fqdn.NewResolver("/etc/resolv.conf")
+		if err != nil {
+			die(logger, "fqdn-resolver", err)
+		}
+		fqdnSets = &fqdn.Sets{
+			Logger:          logger.Session("fqdn-sets"),
+			Resolver:        resolver,
+			IPSets:          &rules.LockedIPSets{IPSets: &rules.IPSets{}, Locker: iptLocker},
+			MetricsSender:   metricsSender,
+			Clock:           fqdn.SystemClock{},
+			TTL:             time.Duration(conf.ASGFQDNTTL) * time.Second,
+			ResolveInterval: time.Duration(conf.ASGFQDNResolveInterval) * time.Second,
+		}
+		converter.FQDNs = fqdnSets
+	}
+
 	netOutChain := &netrules.NetOutChain{
 		ChainNamer: chainNamer,
-		Converter:  &netrules.RuleConverter{Logger: logger},
+		Converter:  converter,
 		ASGLogging: conf.IPTablesASGLogging,
 		DenyNetworks: netrules.DenyNetworks{
 			Always:  conf.DenyNetworks.Always,
@@ -196,6 +232,8 @@ func main() {
 		EnableOverlayIngressRules:     conf.EnableOverlayIngressRules,
 		HostInterfaceNames:            interfaceNames,
 		NetOutChain:                   netOutChain,
+		OverlayNetwork:                conf.OverlayNetwork,
+		AdditionalOverlays:            conf.AdditionalOverlays,
 	}
 
 	timestamper := &enforcer.Timestamper{}
@@ -206,6 +244,7 @@ func main() {
 		enforcer.EnforcerConfig{
 			DisableContainerNetworkPolicy: conf.DisableContainerNetworkPolicy,
 			OverlayNetwork:                conf.OverlayNetwork,
+			IPSets:                        ipSets,
 		},
 	)
 
@@ -264,7 +303,10 @@ func main() {
 		},
 	}
 
-	forcePolicyPollCycleServer := createForceUpdateServer(forcePolicyPollCycleServerAddress, forceHandlers)
+	forcePolicyPollCycleServer, err := createForceUpdateServer(conf, forcePolicyPollCycleServerAddress, forceHandlers)
+	if err != nil {
+		die(logger, "force-policy-poll-cycle-server", err)
+	}
 
 	debugServerAddress := fmt.Sprintf("%s:%d", conf.DebugServerHost, conf.DebugServerPort)
 	debugServer := createCustomDebugServer(debugServerAddress, reconfigurableSink, iptablesLoggingState)
@@ -279,6 +321,14 @@ func main() {
 		members = append(members, grouper.Member{Name: "asg_poller", Runner: asgPoller})
 	}
 
+	if fqdnSets != nil {
+		members = append(members, grouper.Member{Name: "asg_fqdn_poller", Runner: &poller.Poller{
+			Logger:          logger,
+			PollInterval:    time.Duration(conf.ASGFQDNResolveInterval) * time.Second,
+			SingleCycleFunc: fqdnSets.Refresh,
+		}})
+	}
+
 	monitor := ifrit.Invoke(sigmon.New(grouper.NewOrdered(os.Interrupt, members)))
 	logger.Info("starting")
 	err = <-monitor.Wait()
@@ -302,12 +352,58 @@ func createCustomDebugServer(listenAddress string, sink *lager.ReconfigurableSin
 	return http_server.New(listenAddress, mux)
 }
 
-func createForceUpdateServer(listenAddress string, handlers map[string]http.Handler) ifrit.Runner {
+// createForceUpdateServer serves the handlers on the unix socket, which only
+// its owner can connect to, or on the address. Over the address, clients
+// must present a certificate when TLS is configured.
+func createForceUpdateServer(conf *config.VxlanPolicyAgent, listenAddress string, handlers map[string]http.Handler) (ifrit.Runner, error) {
 	mux := http.NewServeMux()
 
 	for url, handler := range handlers {
 		mux.Handle(url, handler)
 	}
 
-	return http_server.New(listenAddress, mux)
+	if conf.ForcePolicyPollCycleSocket != "" {
+		return createUnixSocketServer(conf.ForcePolicyPollCycleSocket, mux), nil
+	}
+
+	if conf.ForcePolicyPollCycleCACertFile != "" {
+		tlsConfig, err := mutualtls.NewServerTLSConfig(conf.ForcePolicyPollCycleCertFile, conf.ForcePolicyPollCycleKeyFile, conf.ForcePolicyPollCycleCACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls config: %s", err)
+		}
+		return http_server.NewTLSServer(listenAddress, mux, tlsConfig), nil
+	}
+
+	return http_server.New(listenAddress, mux), nil
+}
+
+func createUnixSocketServer(socketPath string, handler http.Handler) ifrit.Runner {
+	return ifrit.RunFunc(func(signals <-chan os.Signal, ready chan<- struct{}) error {
+		if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing stale socket: %s", err)
+		}
+		listener, err := net.Listen("unix", socketPath)
+		if err != nil {
+			return err
+		}
+		if err := os.Chmod(socketPath, 0600); err != nil {
+			// #nosec G104 - the chmod error is more important to return
+			listener.Close()
+			return fmt.Errorf("restricting socket: %s", err)
+		}
+
+		server := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+		serveErr := make(chan error, 1)
+		go func() {
+			serveErr <- server.Serve(listener)
+		}()
+		close(ready)
+
+		select {
+		case err := <-serveErr:
+			return err
+		case <-signals:
+			return server.Close()
+		}
+	})
 }
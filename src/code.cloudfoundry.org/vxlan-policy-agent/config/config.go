@@ -3,49 +3,108 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"os"
+	"slices"
 
 	cnilib "code.cloudfoundry.org/cni-wrapper-plugin/lib"
 	loggingclient "code.cloudfoundry.org/diego-logging-client"
+	"code.cloudfoundry.org/lib/rules"
 	validator "gopkg.in/validator.v2"
 )
 
 type VxlanPolicyAgent struct {
-	PollInterval                  int                       `json:"poll_interval" validate:"nonzero"`
-	EnableASGSyncing              bool                      `json:"enable_asg_syncing"`
-	ASGPollInterval               int                       `json:"asg_poll_interval" validate:"min=1"`
-	Datastore                     string                    `json:"cni_datastore_path" validate:"nonzero"`
-	PolicyServerURL               string                    `json:"policy_server_url" validate:"min=1"`
-	VNI                           int                       `json:"vni" validate:"nonzero"`
-	MetronAddress                 string                    `json:"metron_address" validate:"nonzero"`
-	ServerCACertFile              string                    `json:"ca_cert_file" validate:"nonzero"`
-	ClientCertFile                string                    `json:"client_cert_file" validate:"nonzero"`
-	ClientKeyFile                 string                    `json:"client_key_file" validate:"nonzero"`
-	ClientTimeoutSeconds          int                       `json:"client_timeout_seconds" validate:"nonzero"`
-	IPTablesLockFile              string                    `json:"iptables_lock_file" validate:"nonzero"`
-	DebugServerHost               string                    `json:"debug_server_host" validate:"nonzero"`
-	DebugServerPort               int                       `json:"debug_server_port" validate:"nonzero"`
-	LogLevel                      string                    `json:"log_level"`
-	LogPrefix                     string                    `json:"log_prefix" validate:"nonzero"`
-	IPTablesLogging               bool                      `json:"iptables_c2c_logging"`
-	IPTablesAcceptedUDPLogsPerSec int                       `json:"iptables_accepted_udp_logs_per_sec" validate:"min=1"`
-	EnableOverlayIngressRules     bool                      `json:"enable_overlay_ingress_rules"`
-	ForcePolicyPollCyclePort      int                       `json:"force_policy_poll_cycle_port" validate:"nonzero"`
-	ForcePolicyPollCycleHost      string                    `json:"force_policy_poll_cycle_host" validate:"nonzero"`
-	DisableContainerNetworkPolicy bool                      `json:"disable_container_network_policy"`
-	OverlayNetwork                string                    `json:"overlay_network"`
-	UnderlayIPs                   []string                  `json:"underlay_ips"`
-	IPTablesASGLogging            bool                      `json:"iptables_asg_logging"`
-	IPTablesDeniedLogsPerSec      int                       `json:"iptables_denied_logs_per_sec"`
-	DenyNetworks                  cnilib.DenyNetworksConfig `json:"deny_networks"`
-	OutConn                       cnilib.OutConnConfig      `json:"outbound_connections"`
-	LoggregatorConfig             loggingclient.Config      `json:"loggregator"`
+	PollInterval                   int                       `json:"poll_interval" validate:"nonzero"`
+	EnableASGSyncing               bool                      `json:"enable_asg_syncing"`
+	ASGPollInterval                int                       `json:"asg_poll_interval" validate:"min=1"`
+	Datastore                      string                    `json:"cni_datastore_path" validate:"nonzero"`
+	PolicyServerURL                string                    `json:"policy_server_url" validate:"min=1"`
+	VNI                            int                       `json:"vni" validate:"nonzero"`
+	MetronAddress                  string                    `json:"metron_address" validate:"nonzero"`
+	ServerCACertFile               string                    `json:"ca_cert_file" validate:"nonzero"`
+	ClientCertFile                 string                    `json:"client_cert_file" validate:"nonzero"`
+	ClientKeyFile                  string                    `json:"client_key_file" validate:"nonzero"`
+	ClientTimeoutSeconds           int                       `json:"client_timeout_seconds" validate:"nonzero"`
+	IPTablesLockFile               string                    `json:"iptables_lock_file" validate:"nonzero"`
+	FirewallBackend                string                    `json:"firewall_backend"`
+	DebugServerHost                string                    `json:"debug_server_host" validate:"nonzero"`
+	DebugServerPort                int                       `json:"debug_server_port" validate:"nonzero"`
+	LogLevel                       string                    `json:"log_level"`
+	LogPrefix                      string                    `json:"log_prefix" validate:"nonzero"`
+	IPTablesLogging                bool                      `json:"iptables_c2c_logging"`
+	IPTablesAcceptedUDPLogsPerSec  int                       `json:"iptables_accepted_udp_logs_per_sec" validate:"min=1"`
+	EnableOverlayIngressRules      bool                      `json:"enable_overlay_ingress_rules"`
+	ForcePolicyPollCyclePort       int                       `json:"force_policy_poll_cycle_port" validate:"nonzero"`
+	ForcePolicyPollCycleHost       string                    `json:"force_policy_poll_cycle_host" validate:"nonzero"`
+	ForcePolicyPollCycleSocket     string                    `json:"force_policy_poll_cycle_socket"`
+	ForcePolicyPollCycleCACertFile string                    `json:"force_policy_poll_cycle_ca_cert_file"`
+	ForcePolicyPollCycleCertFile   string                    `json:"force_policy_poll_cycle_cert_file"`
+	ForcePolicyPollCycleKeyFile    string                    `json:"force_policy_poll_cycle_key_file"`
+	DisableContainerNetworkPolicy  bool                      `json:"disable_container_network_policy"`
+	OverlayNetwork                 string                    `json:"overlay_network"`
+	AdditionalOverlays             []cnilib.OverlayConfig    `json:"additional_overlays"`
+	UnderlayIPs                    []string                  `json:"underlay_ips"`
+	IPTablesASGLogging             bool                      `json:"iptables_asg_logging"`
+	IPTablesASGIPSets              bool                      `json:"iptables_asg_ipsets"`
+	EnableASGFQDNs                 bool                      `json:"enable_asg_fqdns"`
+	ASGFQDNResolveInterval         int                       `json:"asg_fqdn_resolve_interval"`
+	ASGFQDNTTL                     int                       `json:"asg_fqdn_ttl"`
+	IPTablesDeniedLogsPerSec       int                       `json:"iptables_denied_logs_per_sec"`
+	DenyNetworks                   cnilib.DenyNetworksConfig `json:"deny_networks"`
+	OutConn                        cnilib.OutConnConfig      `json:"outbound_connections"`
+	LoggregatorConfig              loggingclient.Config      `json:"loggregator"`
 }
 
 func (c *VxlanPolicyAgent) Validate() error {
+	if err := rules.ValidateBackend(c.FirewallBackend); err != nil {
+		return err
+	}
+	if err := rules.ValidateIPSets(c.FirewallBackend, c.IPTablesASGIPSets || c.EnableASGFQDNs); err != nil {
+		return err
+	}
+	if c.EnableASGFQDNs && (c.ASGFQDNResolveInterval < 1 || c.ASGFQDNTTL <= c.ASGFQDNResolveInterval) {
+		return fmt.Errorf("asg_fqdn_ttl must be longer than asg_fqdn_resolve_interval, which must be at least 1")
+	}
+	tlsFiles := []string{c.ForcePolicyPollCycleCACertFile, c.ForcePolicyPollCycleCertFile, c.ForcePolicyPollCycleKeyFile}
+	if slices.Contains(tlsFiles, "") && slices.ContainsFunc(tlsFiles, func(f string) bool { return f != "" }) {
+		return fmt.Errorf("force policy poll cycle tls requires a ca cert, cert and key file")
+	}
+	if c.ForcePolicyPollCycleSocket != "" && c.ForcePolicyPollCycleCACertFile != "" {
+		return fmt.Errorf("force policy poll cycle socket cannot be combined with tls")
+	}
+	if err := c.validateOverlays(); err != nil {
+		return err
+	}
 	return validator.Validate(c)
 }
 
+func (c *VxlanPolicyAgent) validateOverlays() error {
+	if len(c.AdditionalOverlays) == 0 {
+		return nil
+	}
+	_, overlayNetwork, err := net.ParseCIDR(c.OverlayNetwork)
+	if err != nil {
+		return fmt.Errorf("additional overlays require a valid overlay network: %s", err)
+	}
+	networks := []*net.IPNet{overlayNetwork}
+	for _, overlay := range c.AdditionalOverlays {
+		_, network, err := net.ParseCIDR(overlay.Network)
+		if err != nil {
+			return fmt.Errorf("invalid network for overlay %s: %s", overlay.Name, err)
+		}
+		if overlay.VTEPName == "" {
+			return fmt.Errorf("missing vtep device name for overlay %s", overlay.Name)
+		}
+		for _, other := range networks {
+			if network.Contains(other.IP) || other.Contains(network.IP) {
+				return fmt.Errorf("network of overlay %s overlaps %s", overlay.Name, other)
+			}
+		}
+		networks = append(networks, network)
+	}
+	return nil
+}
+
 func New(configFilePath string) (*VxlanPolicyAgent, error) {
 	cfg := &VxlanPolicyAgent{}
 	if _, err := os.Stat(configFilePath); err != nil {
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 
+	cnilib "code.cloudfoundry.org/cni-wrapper-plugin/lib"
 	"code.cloudfoundry.org/vxlan-policy-agent/config"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -36,6 +37,7 @@ var _ = Describe("Config", func() {
 					"client_cert_file": "/some/client/cert/file",
 					"client_key_file": "/some/client/key/file",
 					"iptables_lock_file":  "/var/vcap/data/lock",
+					"firewall_backend": "nftables",
 					"debug_server_host": "http://5.6.7.8",
 					"debug_server_port": 5678,
 					"log_level": "debug",
@@ -47,6 +49,8 @@ var _ = Describe("Config", func() {
 					"force_policy_poll_cycle_port": 6789,
 					"force_policy_poll_cycle_host": "http://6.7.8.9",
 					"disable_container_network_policy": false,
+					"overlay_network": "10.255.0.0/16",
+					"additional_overlays": [{"name": "isolated", "network": "10.100.0.0/16", "vtep_name": "silk-vtep-iso"}],
 					"underlay_ips": ["123.1.2.3"],
 					"iptables_asg_logging": true,
 					"iptables_denied_logs_per_sec": 2,
@@ -74,6 +78,7 @@ var _ = Describe("Config", func() {
 				Expect(c.ClientCertFile).To(Equal("/some/client/cert/file"))
 				Expect(c.ClientKeyFile).To(Equal("/some/client/key/file"))
 				Expect(c.IPTablesLockFile).To(Equal("/var/vcap/data/lock"))
+				Expect(c.FirewallBackend).To(Equal("nftables"))
 				Expect(c.DebugServerHost).To(Equal("http://5.6.7.8"))
 				Expect(c.DebugServerPort).To(Equal(5678))
 				Expect(c.LogLevel).To(Equal("debug"))
@@ -85,6 +90,10 @@ var _ = Describe("Config", func() {
 				Expect(c.ForcePolicyPollCyclePort).To(Equal(6789))
 				Expect(c.ForcePolicyPollCycleHost).To(Equal("http://6.7.8.9"))
 				Expect(c.DisableContainerNetworkPolicy).To(BeFalse())
+				Expect(c.OverlayNetwork).To(Equal("10.255.0.0/16"))
+				Expect(c.AdditionalOverlays).To(Equal([]cnilib.OverlayConfig{
+					{Name: "isolated", Network: "10.100.0.0/16", VTEPName: "silk-vtep-iso"},
+				}))
 				Expect(c.UnderlayIPs).To(Equal([]string{"123.1.2.3"}))
 				Expect(c.IPTablesASGLogging).To(BeTrue())
 				Expect(c.IPTablesDeniedLogsPerSec).To(Equal(2))
@@ -120,6 +129,79 @@ var _ = Describe("Config", func() {
 			})
 		})
 
+		Context("when the firewall backend is not supported", func() {
+			It("returns the error", func() {
+				file.WriteString(`{"firewall_backend": "ebtables"}`)
+				_, err = config.New(file.Name())
+				Expect(err).To(MatchError(`invalid config: invalid firewall backend "ebtables": must be iptables or nftables`))
+			})
+		})
+
+		Context("when ASG ipsets are enabled with the nftables backend", func() {
+			It("returns the error", func() {
+				file.WriteString(`{"firewall_backend": "nftables", "iptables_asg_ipsets": true}`)
+				_, err = config.New(file.Name())
+				Expect(err).To(MatchError("invalid config: ASG ipsets require the iptables firewall backend"))
+			})
+		})
+
+		Context("when ASG FQDNs are enabled with the nftables backend", func() {
+			It("returns the error", func() {
+				file.WriteString(`{"firewall_backend": "nftables", "enable_asg_fqdns": true, "asg_fqdn_resolve_interval": 60, "asg_fqdn_ttl": 300}`)
+				_, err = config.New(file.Name())
+				Expect(err).To(MatchError("invalid config: ASG ipsets require the iptables firewall backend"))
+			})
+		})
+
+		Context("when the ASG FQDN TTL is not longer than the resolve interval", func() {
+			It("returns the error", func() {
+				file.WriteString(`{"enable_asg_fqdns": true, "asg_fqdn_resolve_interval": 60, "asg_fqdn_ttl": 60}`)
+				_, err = config.New(file.Name())
+				Expect(err).To(MatchError("invalid config: asg_fqdn_ttl must be longer than asg_fqdn_resolve_interval, which must be at least 1"))
+			})
+		})
+
+		Context("when the force policy poll cycle tls config is incomplete", func() {
+			It("returns the error", func() {
+				file.WriteString(`{"force_policy_poll_cycle_ca_cert_file": "/some/ca.crt"}`)
+				_, err = config.New(file.Name())
+				Expect(err).To(MatchError("invalid config: force policy poll cycle tls requires a ca cert, cert and key file"))
+			})
+		})
+
+		Context("when the force policy poll cycle socket is combined with tls", func() {
+			It("returns the error", func() {
+				file.WriteString(`{
+					"force_policy_poll_cycle_socket": "/some/agent.sock",
+					"force_policy_poll_cycle_ca_cert_file": "/some/ca.crt",
+					"force_policy_poll_cycle_cert_file": "/some/server.crt",
+					"force_policy_poll_cycle_key_file": "/some/server.key"
+				}`)
+				_, err = config.New(file.Name())
+				Expect(err).To(MatchError("invalid config: force policy poll cycle socket cannot be combined with tls"))
+			})
+		})
+
+		Context("when additional overlays are invalid", func() {
+			DescribeTable("returns the error",
+				func(overlays, errorMsg string) {
+					file.WriteString(fmt.Sprintf(`{"overlay_network": "10.255.0.0/16", "additional_overlays": %s}`, overlays))
+					_, err = config.New(file.Name())
+					Expect(err).To(MatchError(fmt.Sprintf("invalid config: %s", errorMsg)))
+				},
+				Entry("invalid network", `[{"name": "isolated", "network": "nope", "vtep_name": "silk-vtep-iso"}]`,
+					"invalid network for overlay isolated: invalid CIDR address: nope"),
+				Entry("missing vtep name", `[{"name": "isolated", "network": "10.100.0.0/16"}]`,
+					"missing vtep device name for overlay isolated"),
+				Entry("overlapping the overlay network", `[{"name": "isolated", "network": "10.255.128.0/17", "vtep_name": "silk-vtep-iso"}]`,
+					"network of overlay isolated overlaps 10.255.0.0/16"),
+				Entry("overlapping another overlay", `[
+					{"name": "isolated", "network": "10.100.0.0/16", "vtep_name": "silk-vtep-iso"},
+					{"name": "other", "network": "10.100.1.0/24", "vtep_name": "silk-vtep-other"}
+				]`, "network of overlay other overlaps 10.100.0.0/16"),
+			)
+		})
+
 		DescribeTable("when config file is missing a member",
 			func(missingFlag, errorMsg string) {
 				allData := map[string]interface{}{
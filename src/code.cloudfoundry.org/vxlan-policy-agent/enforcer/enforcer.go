@@ -50,6 +50,9 @@ func NewEnforcer(logger lager.Logger, timestamper TimeStamper, ipt rules.IPTable
 type EnforcerConfig struct {
 	DisableContainerNetworkPolicy bool
 	OverlayNetwork                string
+	// IPSets is optional. When set, deleting a chain also destroys the
+	// ipsets its rules matched.
+	IPSets rules.IPSetAdapter
 }
 
 const FilterTable = "filter"
@@ -119,15 +122,9 @@ func (r *RulesWithChain) Equals(other RulesWithChain) bool {
 	}
 
 	for i, rule := range r.Rules {
-		otherRule := other.Rules[i]
-		if len(rule) != len(otherRule) {
+		if !rules.EqualRules(rule, other.Rules[i]) {
 			return false
 		}
-		for j := range rule {
-			if rule[j] != otherRule[j] {
-				return false
-			}
-		}
 	}
 	return true
 }
@@ -201,6 +198,12 @@ func (e *Enforcer) EnforceRulesAndChain(rulesAndChain RulesWithChain) (string, e
 func (e *Enforcer) EnforceOnChain(c Chain, rulesSpec []rules.IPTablesRule) (string, error) {
 	if c.Timestamped {
 		// used for C2C
+		if liveChain, ok := e.liveTimestampedChain(c); ok {
+			if e.updateChainRules(e.Logger.Session(liveChain), c.Table, liveChain, rulesSpec) {
+				return liveChain, nil
+			}
+		}
+
 		newTime := e.timestamper.CurrentTime()
 		chainName := fmt.Sprintf("%s%d", c.Name, newTime)
 
@@ -250,9 +253,7 @@ func (e *Enforcer) enforce(logger lager.Logger, table string, parentChain string
 		return fmt.Errorf("creating chain: %s", err)
 	}
 
-	if e.conf.DisableContainerNetworkPolicy {
-		rulespec = append([]rules.IPTablesRule{rules.NewAcceptEverythingRule(e.conf.OverlayNetwork)}, rulespec...)
-	}
+	rulespec = e.chainRules(rulespec)
 
 	logger.Debug("insert-chain", lager.Data{"parent-chain": parentChain, "table": table, "index": 1, "rule": rules.IPTablesRule{"-j", chainName}})
 	err = e.iptables.BulkInsert(table, parentChain, 1, rules.IPTablesRule{"-j", chainName})
@@ -279,7 +280,9 @@ func (e *Enforcer) enforce(logger lager.Logger, table string, parentChain string
 	return nil
 }
 
-// Replaces chain if exists with provided rule spec:
+// Replaces chain if exists with provided rule spec. When only the original
+// chain exists and differs by a few rules, it is updated in place instead.
+// Otherwise:
 // 1. First it checks if candidate chain exists in case if previos run failed for any reason and cleans it up
 // 2. Creates a temporary candidate chain that is appended to the parent chain
 // 3. Deletes original chain
@@ -296,6 +299,12 @@ func (e *Enforcer) replaceChainRules(logger lager.Logger, c Chain, rulesSpec []r
 		return e.enforce(logger, c.Table, c.ParentChain, c.Name, rulesSpec...)
 	}
 
+	if originalChainJumpExists && !candidateChainJumpExists {
+		if e.updateChainRules(logger, c.Table, c.Name, rulesSpec) {
+			return nil
+		}
+	}
+
 	if candidateChainJumpExists {
 		if originalChainJumpExists {
 			err := e.cleanupOldChain(e.Logger, LiveChain{Table: c.Table, Name: candidateName}, c.ParentChain, "")
@@ -331,6 +340,79 @@ func (e *Enforcer) replaceChainRules(logger lager.Logger, c Chain, rulesSpec []r
 	return nil
 }
 
+// updateChainRules turns the live chain into the desired rules with the
+// fewest inserts and deletes. It returns false when the chain could not be
+// read or updated, or when the diff changes more rules than it keeps, and
+// the caller then replaces the chain instead.
+func (e *Enforcer) updateChainRules(logger lager.Logger, table, chain string, rulespec []rules.IPTablesRule) bool {
+	desired := e.chainRules(rulespec)
+
+	lines, err := e.iptables.List(table, chain)
+	if err != nil {
+		logger.Error("update-chain-list", err)
+		return false
+	}
+	live, err := rules.ChainRules(lines)
+	if err != nil {
+		logger.Error("update-chain-parse", err)
+		return false
+	}
+
+	changes := rules.DiffRules(live, desired)
+	var deleted []string
+	inserted := 0
+	for _, change := range changes {
+		if change.Delete {
+			deleted = append(deleted, strings.Join(change.Rule, " "))
+		} else {
+			inserted++
+		}
+	}
+	if kept := len(desired) - inserted; len(changes) > kept {
+		logger.Debug("update-chain-diff-too-large", lager.Data{"chain": chain, "table": table, "changes": len(changes), "kept": kept})
+		return false
+	}
+
+	logger.Debug("update-chain", lager.Data{"chain": chain, "table": table, "changes": changes})
+	err = rules.ApplyRuleChanges(e.iptables, table, chain, changes)
+	if err != nil {
+		logger.Error("update-chain", err)
+		return false
+	}
+
+	e.destroyIPSets(logger, deleted)
+
+	return true
+}
+
+// liveTimestampedChain returns the timestamped chain the parent chain jumps
+// to, unless there is none or more than one.
+func (e *Enforcer) liveTimestampedChain(c Chain) (string, bool) {
+	rulesList, err := e.iptables.List(c.Table, c.ParentChain)
+	if err != nil {
+		return "", false
+	}
+
+	reManagedChain := regexp.MustCompile(c.Name + TimestampedRegex)
+	var chains []string
+	for _, r := range rulesList {
+		if name := reManagedChain.FindString(r); name != "" {
+			chains = append(chains, name)
+		}
+	}
+	if len(chains) != 1 {
+		return "", false
+	}
+	return chains[0], true
+}
+
+func (e *Enforcer) chainRules(rulespec []rules.IPTablesRule) []rules.IPTablesRule {
+	if e.conf.DisableContainerNetworkPolicy {
+		return append([]rules.IPTablesRule{rules.NewAcceptEverythingRule(e.conf.OverlayNetwork)}, rulespec...)
+	}
+	return rulespec
+}
+
 func (e *Enforcer) cleanupOldRules(logger lager.Logger, table, parentChain, managedChainsRegex string, newTime int64) error {
 	rulesList, err := e.iptables.List(table, parentChain)
 	if err != nil {
@@ -426,9 +508,30 @@ func (e *Enforcer) deleteChain(logger lager.Logger, chain LiveChain, newChainNam
 		}
 	}
 
+	e.destroyIPSets(logger, rules)
+
 	return nil
 }
 
+// destroyIPSets destroys the ipsets matched by a deleted chain's rules. Sets
+// still used by other chains are kept, and failures are only logged since
+// the chain itself is already gone.
+func (e *Enforcer) destroyIPSets(logger lager.Logger, chainRules []string) {
+	if e.conf.IPSets == nil {
+		return
+	}
+
+	ipSets := rules.MatchedIPSets(chainRules)
+	if len(ipSets) == 0 {
+		return
+	}
+
+	logger.Debug("destroy-ipsets", lager.Data{"ipsets": ipSets})
+	if err := e.conf.IPSets.Destroy(ipSets...); err != nil {
+		logger.Error("destroy-ipsets", err)
+	}
+}
+
 func (e *Enforcer) candidateChainName(name string) string {
 	return fmt.Sprintf("c%s", name)
 }
@@ -189,18 +189,25 @@ var _ = Describe("Enforcer", func() {
 				})
 
 				Context("when old chain has jump targets", func() {
+					var candidateRules []string
+
 					BeforeEach(func() {
-						iptables.ListReturnsOnCall(0, []string{
-							"-A asg-handle -m state --state RELATED,ESTABLISHED -j ACCEPT",
-							"-A asg-handle -p tcp -m iprange --dst-range 10.0.1.19-10.0.1.19 -m tcp --dport 1:65535 -g netout--handle--log",
-						}, nil)
+						iptables.ListStub = func(table, chain string) ([]string, error) {
+							if chain == "casg-handle" {
+								return candidateRules, nil
+							}
+							return []string{
+								"-A asg-handle -m state --state RELATED,ESTABLISHED -j ACCEPT",
+								"-A asg-handle -p tcp -m iprange --dst-range 10.0.1.19-10.0.1.19 -m tcp --dport 1:65535 -g netout--handle--log",
+							}, nil
+						}
 					})
 
 					Context("when jump target is unused", func() {
 						BeforeEach(func() {
-							iptables.ListReturnsOnCall(1, []string{
+							candidateRules = []string{
 								"-A casg-handle -m state --state RELATED,ESTABLISHED -j ACCEPT",
-							}, nil)
+							}
 						})
 
 						It("cleans up jump target", func() {
@@ -217,10 +224,10 @@ var _ = Describe("Enforcer", func() {
 
 					Context("when jump target is in use", func() {
 						BeforeEach(func() {
-							iptables.ListReturnsOnCall(1, []string{
+							candidateRules = []string{
 								"-A casg-handle -m state --state RELATED,ESTABLISHED -j ACCEPT",
 								"-A casg-handle -p tcp -m iprange --dst-range 20.0.1.19-20.0.1.19 -m tcp --dport 1:65535 -g netout--handle--log",
-							}, nil)
+							}
 						})
 
 						It("does not clean up jump target", func() {
@@ -232,6 +239,94 @@ var _ = Describe("Enforcer", func() {
 					})
 				})
 
+				Context("when the old chain matches ipsets", func() {
+					var ipSets *libfakes.IPSetAdapter
+
+					BeforeEach(func() {
+						ipSets = &libfakes.IPSetAdapter{}
+						ruleEnforcer = enforcer.NewEnforcer(logger, timestamper, iptables, enforcer.EnforcerConfig{
+							OverlayNetwork: "10.10.0.0/16",
+							IPSets:         ipSets,
+						})
+						iptables.ListStub = func(table, chain string) ([]string, error) {
+							if chain != "asg-handle" {
+								return nil, nil
+							}
+							return []string{
+								"-A asg-handle -m set --match-set silk-abc dst -p tcp -m tcp --dport 443 -j ACCEPT",
+								"-A asg-handle -m iprange --dst-range 10.0.1.19-10.0.1.19 -j ACCEPT",
+							}, nil
+						}
+					})
+
+					It("destroys the ipsets after deleting the chain", func() {
+						Expect(iptables.DeleteChainCallCount()).To(Equal(1))
+						Expect(ipSets.DestroyCallCount()).To(Equal(1))
+						Expect(ipSets.DestroyArgsForCall(0)).To(Equal([]string{"silk-abc"}))
+					})
+
+					Context("when destroying the ipsets fails", func() {
+						BeforeEach(func() {
+							ipSets.DestroyReturns(errors.New("potato"))
+						})
+
+						It("logs the error without failing", func() {
+							Expect(enforceErr).NotTo(HaveOccurred())
+							Expect(logger).To(gbytes.Say("destroy-ipsets.*potato"))
+						})
+					})
+				})
+
+				Context("when the original chain differs from the desired rules by a few rules", func() {
+					var ipSets *libfakes.IPSetAdapter
+
+					BeforeEach(func() {
+						ipSets = &libfakes.IPSetAdapter{}
+						ruleEnforcer = enforcer.NewEnforcer(logger, timestamper, iptables, enforcer.EnforcerConfig{
+							OverlayNetwork: "10.10.0.0/16",
+							IPSets:         ipSets,
+						})
+						iptables.ListReturns([]string{
+							"-N asg-handle",
+							"-A asg-handle rule1",
+							"-A asg-handle -m set --match-set silk-abc dst -j ACCEPT",
+							"-A asg-handle rule2",
+						}, nil)
+					})
+
+					It("updates the original chain in place", func() {
+						Expect(enforceErr).NotTo(HaveOccurred())
+
+						Expect(iptables.ApplyTransactionCallCount()).To(Equal(1))
+						Expect(iptables.ApplyTransactionArgsForCall(0).String()).To(Equal("*some-table\n-D asg-handle 2\nCOMMIT\n"))
+
+						Expect(iptables.NewChainCallCount()).To(Equal(0))
+						Expect(iptables.BulkInsertCallCount()).To(Equal(0))
+						Expect(iptables.DeleteChainCallCount()).To(Equal(0))
+						Expect(iptables.RenameChainCallCount()).To(Equal(0))
+					})
+
+					It("destroys the ipsets of the deleted rules", func() {
+						Expect(ipSets.DestroyCallCount()).To(Equal(1))
+						Expect(ipSets.DestroyArgsForCall(0)).To(Equal([]string{"silk-abc"}))
+					})
+
+					Context("when updating the chain fails", func() {
+						BeforeEach(func() {
+							iptables.ApplyTransactionReturns(errors.New("banana"))
+						})
+
+						It("replaces the chain instead", func() {
+							Expect(enforceErr).NotTo(HaveOccurred())
+							Expect(logger).To(gbytes.Say("update-chain.*banana"))
+							Expect(iptables.NewChainCallCount()).To(Equal(1))
+							_, chain := iptables.NewChainArgsForCall(0)
+							Expect(chain).To(Equal("casg-handle"))
+							Expect(iptables.RenameChainCallCount()).To(Equal(1))
+						})
+					})
+				})
+
 				It("renames candidate chain to new chain", func() {
 					Expect(iptables.RenameChainCallCount()).To(Equal(1))
 					table, oldChain, newChain := iptables.RenameChainArgsForCall(0)
@@ -381,6 +476,55 @@ var _ = Describe("Enforcer", func() {
 				Expect(ruleSpec).To(Equal([]rules.IPTablesRule{{"-j", "foo42"}}))
 			})
 
+			Context("when the live timestamped chain differs from the desired rules by a few rules", func() {
+				BeforeEach(func() {
+					iptables.ListStub = func(table, chain string) ([]string, error) {
+						if chain == "some-chain" {
+							return []string{"-P some-chain ACCEPT", "-A some-chain -j foo1234567890"}, nil
+						}
+						return []string{"-N foo1234567890", "-A foo1234567890 rule1", "-A foo1234567890 rule3"}, nil
+					}
+				})
+
+				It("updates the live chain in place", func() {
+					chain, err := ruleEnforcer.EnforceOnChain(
+						enforcer.Chain{
+							Table:       "some-table",
+							ParentChain: "some-chain",
+							Name:        "foo",
+							Timestamped: true,
+						},
+						[]rules.IPTablesRule{fakeRule, fakeRule2, {"rule3"}},
+					)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(chain).To(Equal("foo1234567890"))
+
+					Expect(iptables.ApplyTransactionCallCount()).To(Equal(1))
+					Expect(iptables.ApplyTransactionArgsForCall(0).String()).To(Equal("*some-table\n" +
+						"-I foo1234567890 2 rule2\n" +
+						"COMMIT\n"))
+
+					Expect(iptables.NewChainCallCount()).To(Equal(0))
+					Expect(iptables.DeleteChainCallCount()).To(Equal(0))
+				})
+
+				It("replaces the chain when the diff changes more rules than it keeps", func() {
+					chain, err := ruleEnforcer.EnforceOnChain(
+						enforcer.Chain{
+							Table:       "some-table",
+							ParentChain: "some-chain",
+							Name:        "foo",
+							Timestamped: true,
+						},
+						[]rules.IPTablesRule{fakeRule2},
+					)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(chain).To(Equal("foo42"))
+					Expect(iptables.ApplyTransactionCallCount()).To(Equal(0))
+					Expect(iptables.NewChainCallCount()).To(Equal(1))
+				})
+			})
+
 			Context("when there is an older timestamped chain", func() {
 				BeforeEach(func() {
 					timestamper.CurrentTimeReturns(9999999999111111)
@@ -0,0 +1,122 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+type DNSResolver struct {
+	LookupIPv4Stub        func(context.Context, string) ([]net.IP, time.Duration, error)
+	lookupIPv4Mutex       sync.RWMutex
+	lookupIPv4ArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+	}
+	lookupIPv4Returns struct {
+		result1 []net.IP
+		result2 time.Duration
+		result3 error
+	}
+	lookupIPv4ReturnsOnCall map[int]struct {
+		result1 []net.IP
+		result2 time.Duration
+		result3 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *DNSResolver) LookupIPv4(arg1 context.Context, arg2 string) ([]net.IP, time.Duration, error) {
+	fake.lookupIPv4Mutex.Lock()
+	ret, specificReturn := fake.lookupIPv4ReturnsOnCall[len(fake.lookupIPv4ArgsForCall)]
+	fake.lookupIPv4ArgsForCall = append(fake.lookupIPv4ArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+	}{arg1, arg2})
+	stub := fake.LookupIPv4Stub
+	fakeReturns := fake.lookupIPv4Returns
+	fake.recordInvocation("LookupIPv4", []interface{}{arg1, arg2})
+	fake.lookupIPv4Mutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2, ret.result3
+	}
+	return fakeReturns.result1, fakeReturns.result2, fakeReturns.result3
+}
+
+func (fake *DNSResolver) LookupIPv4CallCount() int {
+	fake.lookupIPv4Mutex.RLock()
+	defer fake.lookupIPv4Mutex.RUnlock()
+	return len(fake.lookupIPv4ArgsForCall)
+}
+
+func (fake *DNSResolver) LookupIPv4Calls(stub func(context.Context, string) ([]net.IP, time.Duration, error)) {
+	fake.lookupIPv4Mutex.Lock()
+	defer fake.lookupIPv4Mutex.Unlock()
+	fake.LookupIPv4Stub = stub
+}
+
+func (fake *DNSResolver) LookupIPv4ArgsForCall(i int) (context.Context, string) {
+	fake.lookupIPv4Mutex.RLock()
+	defer fake.lookupIPv4Mutex.RUnlock()
+	argsForCall := fake.lookupIPv4ArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *DNSResolver) LookupIPv4Returns(result1 []net.IP, result2 time.Duration, result3 error) {
+	fake.lookupIPv4Mutex.Lock()
+	defer fake.lookupIPv4Mutex.Unlock()
+	fake.LookupIPv4Stub = nil
+	fake.lookupIPv4Returns = struct {
+		result1 []net.IP
+		result2 time.Duration
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *DNSResolver) LookupIPv4ReturnsOnCall(i int, result1 []net.IP, result2 time.Duration, result3 error) {
+	fake.lookupIPv4Mutex.Lock()
+	defer fake.lookupIPv4Mutex.Unlock()
+	fake.LookupIPv4Stub = nil
+	if fake.lookupIPv4ReturnsOnCall == nil {
+		fake.lookupIPv4ReturnsOnCall = make(map[int]struct {
+			result1 []net.IP
+			result2 time.Duration
+			result3 error
+		})
+	}
+	fake.lookupIPv4ReturnsOnCall[i] = struct {
+		result1 []net.IP
+		result2 time.Duration
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *DNSResolver) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.lookupIPv4Mutex.RLock()
+	defer fake.lookupIPv4Mutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *DNSResolver) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
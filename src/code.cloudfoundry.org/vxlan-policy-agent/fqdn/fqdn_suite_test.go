@@ -0,0 +1,13 @@
+package fqdn_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestFQDN(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "FQDN Suite")
+}
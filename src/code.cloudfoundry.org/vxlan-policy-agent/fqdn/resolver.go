@@ -0,0 +1,157 @@
+package fqdn
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Resolver looks up the IPv4 addresses of a DNS name along with the TTL of
+// the answer, which net.Resolver does not return.
+type Resolver struct {
+	// Servers are asked in order until one answers, as host:port.
+	Servers []string
+}
+
+// NewResolver asks the nameservers listed in the resolv.conf file.
+func NewResolver(resolvConfPath string) (*Resolver, error) {
+	file, err := os.Open(resolvConfPath)
+	if err != nil {
+		return nil, fmt.Errorf("open resolv.conf: %s", err)
+	}
+	defer file.Close()
+
+	var servers []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "nameserver" {
+			continue
+		}
+		if ip := net.ParseIP(fields[1]); ip != nil {
+			servers = append(servers, net.JoinHostPort(ip.String(), "53"))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read resolv.conf: %s", err)
+	}
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("no nameservers in %s", resolvConfPath)
+	}
+	return &Resolver{Servers: servers}, nil
+}
+
+// LookupIPv4 returns the addresses of the A records of the name and the
+// lowest TTL in the answer, which includes the CNAME records that led to
+// them.
+func (r *Resolver) LookupIPv4(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+	name, err := dnsmessage.NewName(strings.TrimSuffix(host, ".") + ".")
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid name %s: %s", host, err)
+	}
+
+	var lastErr error
+	for _, server := range r.Servers {
+		answer, err := r.exchange(ctx, server, name)
+		if err != nil {
+			lastErr = fmt.Errorf("lookup %s on %s: %s", host, server, err)
+			continue
+		}
+		return parseAnswer(host, answer)
+	}
+	return nil, 0, lastErr
+}
+
+func (r *Resolver) exchange(ctx context.Context, server string, name dnsmessage.Name) (*dnsmessage.Message, error) {
+	id := uint16(rand.Uint32())
+	query, err := (&dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET}},
+	}).Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	answer, err := exchangeOver(ctx, "udp", server, query, id)
+	if err == nil && answer.Truncated {
+		answer, err = exchangeOver(ctx, "tcp", server, query, id)
+	}
+	return answer, err
+}
+
+func exchangeOver(ctx context.Context, network, server string, query []byte, id uint16) (*dnsmessage.Message, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	var response []byte
+	if network == "tcp" {
+		framed := binary.BigEndian.AppendUint16(nil, uint16(len(query)))
+		if _, err := conn.Write(append(framed, query...)); err != nil {
+			return nil, err
+		}
+		var length uint16
+		if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+			return nil, err
+		}
+		response = make([]byte, length)
+		if _, err := io.ReadFull(conn, response); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := conn.Write(query); err != nil {
+			return nil, err
+		}
+		response = make([]byte, 512)
+		n, err := conn.Read(response)
+		if err != nil {
+			return nil, err
+		}
+		response = response[:n]
+	}
+
+	var answer dnsmessage.Message
+	if err := answer.Unpack(response); err != nil {
+		return nil, err
+	}
+	if answer.ID != id || !answer.Response {
+		return nil, fmt.Errorf("unexpected response")
+	}
+	return &answer, nil
+}
+
+func parseAnswer(host string, answer *dnsmessage.Message) ([]net.IP, time.Duration, error) {
+	if answer.RCode != dnsmessage.RCodeSuccess {
+		return nil, 0, fmt.Errorf("lookup %s: %s", host, answer.RCode)
+	}
+
+	var ips []net.IP
+	var ttl uint32
+	for i, resource := range answer.Answers {
+		if i == 0 || resource.Header.TTL < ttl {
+			ttl = resource.Header.TTL
+		}
+		if a, ok := resource.Body.(*dnsmessage.AResource); ok {
+			ips = append(ips, net.IP(a.A[:]))
+		}
+	}
+	if len(ips) == 0 {
+		return nil, 0, fmt.Errorf("lookup %s: no IPv4 addresses", host)
+	}
+	return ips, time.Duration(ttl) * time.Second, nil
+}
@@ -0,0 +1,125 @@
+package fqdn_test
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/vxlan-policy-agent/fqdn"
+	"golang.org/x/net/dns/dnsmessage"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Resolver", func() {
+	var (
+		server   net.PacketConn
+		answers  []dnsmessage.Resource
+		rcode    dnsmessage.RCode
+		resolver *fqdn.Resolver
+	)
+
+	BeforeEach(func() {
+		var err error
+		server, err = net.ListenPacket("udp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+
+		name := dnsmessage.MustNewName("api.example.com.")
+		cname := dnsmessage.MustNewName("lb.example.com.")
+		answers = []dnsmessage.Resource{
+			{
+				Header: dnsmessage.ResourceHeader{Name: name, Type: dnsmessage.TypeCNAME, Class: dnsmessage.ClassINET, TTL: 300},
+				Body:   &dnsmessage.CNAMEResource{CNAME: cname},
+			},
+			{
+				Header: dnsmessage.ResourceHeader{Name: cname, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60},
+				Body:   &dnsmessage.AResource{A: [4]byte{1, 1, 1, 1}},
+			},
+			{
+				Header: dnsmessage.ResourceHeader{Name: cname, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60},
+				Body:   &dnsmessage.AResource{A: [4]byte{2, 2, 2, 2}},
+			},
+		}
+		rcode = dnsmessage.RCodeSuccess
+
+		go func() {
+			defer GinkgoRecover()
+			buf := make([]byte, 512)
+			for {
+				n, addr, err := server.ReadFrom(buf)
+				if err != nil {
+					return
+				}
+				var query dnsmessage.Message
+				Expect(query.Unpack(buf[:n])).To(Succeed())
+
+				response, err := (&dnsmessage.Message{
+					Header:    dnsmessage.Header{ID: query.ID, Response: true, RCode: rcode},
+					Questions: query.Questions,
+					Answers:   answers,
+				}).Pack()
+				Expect(err).NotTo(HaveOccurred())
+				_, err = server.WriteTo(response, addr)
+				Expect(err).NotTo(HaveOccurred())
+			}
+		}()
+
+		resolver = &fqdn.Resolver{Servers: []string{server.LocalAddr().String()}}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("returns the addresses and the lowest TTL of the answer", func() {
+		ips, ttl, err := resolver.LookupIPv4(context.Background(), "api.example.com")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ips).To(Equal([]net.IP{net.IP{1, 1, 1, 1}, net.IP{2, 2, 2, 2}}))
+		Expect(ttl).To(Equal(time.Minute))
+	})
+
+	Context("when the name does not exist", func() {
+		BeforeEach(func() {
+			rcode = dnsmessage.RCodeNameError
+			answers = nil
+		})
+
+		It("returns an error", func() {
+			_, _, err := resolver.LookupIPv4(context.Background(), "api.example.com")
+			Expect(err).To(MatchError("lookup api.example.com: RCodeNameError"))
+		})
+	})
+
+	Context("when the name has no IPv4 addresses", func() {
+		BeforeEach(func() {
+			answers = answers[:1]
+		})
+
+		It("returns an error", func() {
+			_, _, err := resolver.LookupIPv4(context.Background(), "api.example.com")
+			Expect(err).To(MatchError("lookup api.example.com: no IPv4 addresses"))
+		})
+	})
+
+	Describe("NewResolver", func() {
+		It("asks the nameservers of resolv.conf", func() {
+			path := filepath.Join(GinkgoT().TempDir(), "resolv.conf")
+			Expect(os.WriteFile(path, []byte("search example.com\nnameserver 10.0.0.1\nnameserver 10.0.0.2\noptions ndots:1\n"), 0644)).To(Succeed())
+
+			resolver, err := fqdn.NewResolver(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resolver.Servers).To(Equal([]string{"10.0.0.1:53", "10.0.0.2:53"}))
+		})
+
+		It("returns an error when there are no nameservers", func() {
+			path := filepath.Join(GinkgoT().TempDir(), "resolv.conf")
+			Expect(os.WriteFile(path, []byte("search example.com\n"), 0644)).To(Succeed())
+
+			_, err := fqdn.NewResolver(path)
+			Expect(err).To(MatchError("no nameservers in " + path))
+		})
+	})
+})
@@ -0,0 +1,158 @@
+package fqdn
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lib/rules"
+	multierror "github.com/hashicorp/go-multierror"
+)
+
+const (
+	metricResolutionFailures = "asgFQDNResolutionFailures"
+	lookupTimeout            = 5 * time.Second
+)
+
+//go:generate counterfeiter -o fakes/dns_resolver.go --fake-name DNSResolver . dnsResolver
+type dnsResolver interface {
+	LookupIPv4(ctx context.Context, host string) ([]net.IP, time.Duration, error)
+}
+
+//go:generate counterfeiter -o fakes/metrics_sender.go --fake-name MetricsSender . metricsSender
+type metricsSender interface {
+	IncrementCounter(string)
+}
+
+//go:generate counterfeiter -o fakes/clock.go --fake-name Clock . Clock
+type Clock interface {
+	Now() time.Time
+}
+
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Sets keeps an ipset for every DNS name used as an ASG destination, filled
+// with the IPv4 addresses the name resolves to. Addresses expire from the
+// set after the TTL of their DNS record unless a later resolution returns
+// them again. Refresh must run every ResolveInterval, which the expiry never
+// undercuts, and the expiry never exceeds TTL.
+type Sets struct {
+	Logger          lager.Logger
+	Resolver        dnsResolver
+	IPSets          rules.IPSetAdapter
+	MetricsSender   metricsSender
+	Clock           Clock
+	TTL             time.Duration
+	ResolveInterval time.Duration
+
+	mutex    sync.Mutex
+	lastUsed map[string]time.Time
+}
+
+// Ensure returns the name of the set for the DNS name. A name is resolved
+// the first time it is used, so its set is filled before rules match it.
+// Later calls only create the set again in case it was destroyed.
+func (s *Sets) Ensure(fqdn string) (string, error) {
+	s.mutex.Lock()
+	if s.lastUsed == nil {
+		s.lastUsed = map[string]time.Time{}
+	}
+	_, known := s.lastUsed[fqdn]
+	s.lastUsed[fqdn] = s.Clock.Now()
+	s.mutex.Unlock()
+
+	if known {
+		set := rules.NewFQDNIPSet(fqdn, nil, s.timeout())
+		if err := s.IPSets.Ensure(set); err != nil {
+			return "", fmt.Errorf("ensure ipset %s for %s: %s", set.Name, fqdn, err)
+		}
+		return set.Name, nil
+	}
+	return s.update(fqdn)
+}
+
+// Refresh resolves the names used within the last TTL again. The sets of
+// names that were not used are destroyed unless rules still match them.
+func (s *Sets) Refresh() error {
+	now := s.Clock.Now()
+
+	s.mutex.Lock()
+	var names, unused []string
+	for fqdn, lastUsed := range s.lastUsed {
+		if now.Sub(lastUsed) > s.TTL {
+			delete(s.lastUsed, fqdn)
+			unused = append(unused, rules.NewFQDNIPSet(fqdn, nil, s.timeout()).Name)
+			continue
+		}
+		names = append(names, fqdn)
+	}
+	s.mutex.Unlock()
+
+	var result error
+	if len(unused) > 0 {
+		s.Logger.Debug("destroy-unused-fqdn-ipsets", lager.Data{"ipsets": unused})
+		if err := s.IPSets.Destroy(unused...); err != nil {
+			result = multierror.Append(result, fmt.Errorf("destroy unused ipsets: %s", err))
+		}
+	}
+
+	for _, fqdn := range names {
+		if _, err := s.update(fqdn); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+	return result
+}
+
+// update creates the set if needed and adds the addresses the name resolves
+// to. A failed resolution leaves the addresses in the set as they are, so
+// they keep working until they expire.
+func (s *Sets) update(fqdn string) (string, error) {
+	addresses, ttl, err := s.resolve(fqdn)
+	if err != nil {
+		s.Logger.Error("resolve-fqdn", err, lager.Data{"fqdn": fqdn})
+		s.MetricsSender.IncrementCounter(metricResolutionFailures)
+	}
+
+	set := rules.NewFQDNIPSet(fqdn, addresses, s.timeout())
+	set.MemberTimeout = s.memberTimeout(ttl)
+	if err := s.IPSets.Ensure(set); err != nil {
+		return "", fmt.Errorf("ensure ipset %s for %s: %s", set.Name, fqdn, err)
+	}
+	return set.Name, nil
+}
+
+func (s *Sets) resolve(fqdn string) ([]string, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+
+	ips, ttl, err := s.Resolver.LookupIPv4(ctx, fqdn)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var addresses []string
+	for _, ip := range ips {
+		addresses = append(addresses, ip.String())
+	}
+	return addresses, ttl, nil
+}
+
+func (s *Sets) timeout() int {
+	return int(s.TTL / time.Second)
+}
+
+// memberTimeout keeps addresses for the TTL of their record, but at least
+// until the next resolution has had time to add them again and at most for
+// the configured TTL.
+func (s *Sets) memberTimeout(recordTTL time.Duration) int {
+	ttl := max(recordTTL, s.ResolveInterval+lookupTimeout)
+	return int(min(ttl, s.TTL) / time.Second)
+}
@@ -0,0 +1,201 @@
+package fqdn_test
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	libfakes "code.cloudfoundry.org/lib/fakes"
+	"code.cloudfoundry.org/lib/rules"
+	"code.cloudfoundry.org/vxlan-policy-agent/fqdn"
+	"code.cloudfoundry.org/vxlan-policy-agent/fqdn/fakes"
+
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Sets", func() {
+	var (
+		sets          *fqdn.Sets
+		resolver      *fakes.DNSResolver
+		ipSets        *libfakes.IPSetAdapter
+		metricsSender *fakes.MetricsSender
+		clock         *fakes.Clock
+		logger        *lagertest.TestLogger
+		now           time.Time
+		setName       string
+	)
+
+	BeforeEach(func() {
+		resolver = &fakes.DNSResolver{}
+		ipSets = &libfakes.IPSetAdapter{}
+		metricsSender = &fakes.MetricsSender{}
+		clock = &fakes.Clock{}
+		logger = lagertest.NewTestLogger("test")
+
+		now = time.Unix(1000, 0)
+		clock.NowStub = func() time.Time { return now }
+		resolver.LookupIPv4Returns([]net.IP{net.ParseIP("1.1.1.1"), net.ParseIP("2.2.2.2")}, 2*time.Minute, nil)
+		setName = rules.NewFQDNIPSet("api.example.com", nil, 0).Name
+
+		sets = &fqdn.Sets{
+			Logger:          logger,
+			Resolver:        resolver,
+			IPSets:          ipSets,
+			MetricsSender:   metricsSender,
+			Clock:           clock,
+			TTL:             5 * time.Minute,
+			ResolveInterval: time.Minute,
+		}
+	})
+
+	Describe("Ensure", func() {
+		It("fills the set with the addresses of the name the first time it is used", func() {
+			name, err := sets.Ensure("api.example.com")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(name).To(Equal(setName))
+
+			Expect(resolver.LookupIPv4CallCount()).To(Equal(1))
+			_, host := resolver.LookupIPv4ArgsForCall(0)
+			Expect(host).To(Equal("api.example.com"))
+
+			Expect(ipSets.EnsureCallCount()).To(Equal(1))
+			Expect(ipSets.EnsureArgsForCall(0)).To(Equal(rules.IPSet{
+				Name:          setName,
+				Members:       []string{"1.1.1.1", "2.2.2.2"},
+				Timeout:       300,
+				MemberTimeout: 120,
+			}))
+		})
+
+		It("does not resolve the name again but creates the set in case it was destroyed", func() {
+			_, err := sets.Ensure("api.example.com")
+			Expect(err).NotTo(HaveOccurred())
+			name, err := sets.Ensure("api.example.com")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(name).To(Equal(setName))
+
+			Expect(resolver.LookupIPv4CallCount()).To(Equal(1))
+			Expect(ipSets.EnsureCallCount()).To(Equal(2))
+			Expect(ipSets.EnsureArgsForCall(1)).To(Equal(rules.IPSet{Name: setName, Timeout: 300}))
+		})
+
+		Context("when the TTL of the record is shorter than the resolve interval", func() {
+			BeforeEach(func() {
+				resolver.LookupIPv4Returns([]net.IP{net.ParseIP("1.1.1.1")}, 10*time.Second, nil)
+			})
+
+			It("keeps the addresses until the next resolution can add them again", func() {
+				_, err := sets.Ensure("api.example.com")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ipSets.EnsureArgsForCall(0).MemberTimeout).To(Equal(65))
+			})
+		})
+
+		Context("when the TTL of the record is longer than the configured TTL", func() {
+			BeforeEach(func() {
+				resolver.LookupIPv4Returns([]net.IP{net.ParseIP("1.1.1.1")}, time.Hour, nil)
+			})
+
+			It("keeps the addresses for the configured TTL", func() {
+				_, err := sets.Ensure("api.example.com")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ipSets.EnsureArgsForCall(0).MemberTimeout).To(Equal(300))
+			})
+		})
+
+		Context("when resolving the name fails", func() {
+			BeforeEach(func() {
+				resolver.LookupIPv4Returns(nil, 0, errors.New("banana"))
+			})
+
+			It("creates an empty set, logs and counts the failure", func() {
+				name, err := sets.Ensure("api.example.com")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(name).To(Equal(setName))
+
+				Expect(ipSets.EnsureArgsForCall(0).Members).To(BeEmpty())
+				Expect(logger).To(gbytes.Say("resolve-fqdn.*banana"))
+				Expect(metricsSender.IncrementCounterCallCount()).To(Equal(1))
+				Expect(metricsSender.IncrementCounterArgsForCall(0)).To(Equal("asgFQDNResolutionFailures"))
+			})
+		})
+
+		Context("when the set cannot be created", func() {
+			BeforeEach(func() {
+				ipSets.EnsureReturns(errors.New("banana"))
+			})
+
+			It("returns an error", func() {
+				_, err := sets.Ensure("api.example.com")
+				Expect(err).To(MatchError("ensure ipset " + setName + " for api.example.com: banana"))
+			})
+		})
+	})
+
+	Describe("Refresh", func() {
+		BeforeEach(func() {
+			_, err := sets.Ensure("api.example.com")
+			Expect(err).NotTo(HaveOccurred())
+			resolver.LookupIPv4Returns([]net.IP{net.ParseIP("3.3.3.3")}, 3*time.Minute, nil)
+		})
+
+		It("resolves the names again", func() {
+			now = now.Add(time.Minute)
+			Expect(sets.Refresh()).To(Succeed())
+
+			Expect(resolver.LookupIPv4CallCount()).To(Equal(2))
+			Expect(ipSets.EnsureCallCount()).To(Equal(2))
+			Expect(ipSets.EnsureArgsForCall(1)).To(Equal(rules.IPSet{
+				Name:          setName,
+				Members:       []string{"3.3.3.3"},
+				Timeout:       300,
+				MemberTimeout: 180,
+			}))
+			Expect(ipSets.DestroyCallCount()).To(Equal(0))
+		})
+
+		Context("when a name was not used within the TTL", func() {
+			BeforeEach(func() {
+				now = now.Add(6 * time.Minute)
+			})
+
+			It("destroys its set and stops resolving it", func() {
+				Expect(sets.Refresh()).To(Succeed())
+
+				Expect(resolver.LookupIPv4CallCount()).To(Equal(1))
+				Expect(ipSets.DestroyCallCount()).To(Equal(1))
+				Expect(ipSets.DestroyArgsForCall(0)).To(Equal([]string{setName}))
+
+				Expect(sets.Refresh()).To(Succeed())
+				Expect(ipSets.DestroyCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when resolving a name fails", func() {
+			BeforeEach(func() {
+				resolver.LookupIPv4Returns(nil, 0, errors.New("banana"))
+			})
+
+			It("still creates the set, logs and counts the failure", func() {
+				Expect(sets.Refresh()).To(Succeed())
+				Expect(ipSets.EnsureArgsForCall(1).Name).To(Equal(setName))
+				Expect(ipSets.EnsureArgsForCall(1).Timeout).To(Equal(300))
+				Expect(ipSets.EnsureArgsForCall(1).Members).To(BeEmpty())
+				Expect(metricsSender.IncrementCounterCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when updating a set fails", func() {
+			BeforeEach(func() {
+				ipSets.EnsureReturns(errors.New("banana"))
+			})
+
+			It("returns an error", func() {
+				Expect(sets.Refresh()).To(MatchError(ContainSubstring("for api.example.com: banana")))
+			})
+		})
+	})
+})
@@ -5,6 +5,7 @@ import (
 	"sort"
 	"time"
 
+	cnilib "code.cloudfoundry.org/cni-wrapper-plugin/lib"
 	"code.cloudfoundry.org/cni-wrapper-plugin/netrules"
 	"code.cloudfoundry.org/executor"
 	"code.cloudfoundry.org/lager/v3"
@@ -21,6 +22,7 @@ type container struct {
 	Ports     string
 	IP        string
 	Purpose   string
+	VTEPName  string
 	LogConfig executor.LogConfig
 }
 
@@ -36,6 +38,10 @@ type VxlanPolicyPlanner struct {
 	EnableOverlayIngressRules     bool
 	HostInterfaceNames            []string
 	NetOutChain                   netOutChain
+	// OverlayNetwork and AdditionalOverlays are the overlays of the cell.
+	// Traffic between them is dropped before any policy is applied.
+	OverlayNetwork     string
+	AdditionalOverlays []cnilib.OverlayConfig
 }
 
 //go:generate counterfeiter -o fakes/dstore.go --fake-name Dstore . dstore
@@ -112,6 +118,12 @@ func (p *VxlanPolicyPlanner) readFile(specifiedContainers ...string) ([]containe
 			purpose = ""
 		}
 
+		// only set for containers on an additional overlay
+		vtepName, ok := containerMeta.Metadata["vtep_name"].(string)
+		if !ok {
+			vtepName = ""
+		}
+
 		var logConfig executor.LogConfig
 		logConfigStr, ok := containerMeta.Metadata["log_config"].(string)
 		if ok {
@@ -128,6 +140,7 @@ func (p *VxlanPolicyPlanner) readFile(specifiedContainers ...string) ([]containe
 			Ports:     ports,
 			IP:        containerMeta.IP,
 			Purpose:   purpose,
+			VTEPName:  vtepName,
 			LogConfig: logConfig,
 		})
 	}
@@ -6,6 +6,7 @@ package planner
 import (
 	"encoding/json"
 	"fmt"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -61,6 +62,7 @@ type destination struct {
 	SourceGUID         string
 	Protocol           string
 	SourceTag          string
+	VTEPName           string
 }
 
 type destinationSlice []destination
@@ -92,6 +94,7 @@ type ingress struct {
 	IP         string
 	Protocol   string
 	Port       int
+	VTEPName   string
 }
 
 type ingressSlice []ingress
@@ -213,6 +216,38 @@ func (p *VxlanPolicyPlanner) GetASGRulesAndChains(specifiedContainers ...string)
 	return rulesWithChains, nil
 }
 
+// onVTEP restricts the rule to traffic arriving from the VTEP of the destination
+// container's overlay, so that tagged traffic from other overlays is not accepted.
+// Containers on the default overlay have no VTEP name and keep unrestricted rules.
+func onVTEP(rule rules.IPTablesRule, vtepName string) rules.IPTablesRule {
+	if vtepName == "" {
+		return rule
+	}
+	return rules.MatchInputInterface(rule, vtepName)
+}
+
+// overlayIsolationRules drop traffic between the overlays of the cell. They
+// come first in the chain, so misconfigured policies cannot accept it.
+func (p *VxlanPolicyPlanner) overlayIsolationRules() []rules.IPTablesRule {
+	if len(p.AdditionalOverlays) == 0 {
+		return nil
+	}
+
+	networks := []string{p.OverlayNetwork}
+	for _, overlay := range p.AdditionalOverlays {
+		networks = append(networks, overlay.Network)
+	}
+	others := func(network string) []string {
+		return slices.DeleteFunc(slices.Clone(networks), func(n string) bool { return n == network })
+	}
+
+	isolation := rules.NewOverlayIsolationRules(p.OverlayNetwork, "", others(p.OverlayNetwork))
+	for _, overlay := range p.AdditionalOverlays {
+		isolation = append(isolation, rules.NewOverlayIsolationRules(overlay.Network, overlay.VTEPName, others(overlay.Network))...)
+	}
+	return isolation
+}
+
 func reverseOrderIptablesRules(iptablesRules, defaultRules []rules.IPTablesRule) []rules.IPTablesRule {
 	allRules := []rules.IPTablesRule{}
 	for i := len(iptablesRules) - 1; i >= 0; i-- {
@@ -220,7 +255,8 @@ func reverseOrderIptablesRules(iptablesRules, defaultRules []rules.IPTablesRule)
 	}
 
 	// default rules come in the correct order. we need to reverse-append the others above, so that the 'insert at 1'
-	// logic puts them above the default rules in the right order. then we can add default rules in the normal order
+	// logic puts them above the default rules in the right order. then we can add default rules in the normal order.
+	// the asg rules come lowest priority first, so reversing them also puts higher priorities and denies first.
 	allRules = append(allRules, defaultRules...)
 	return allRules
 }
@@ -291,6 +327,7 @@ func (p *VxlanPolicyPlanner) getContainerPolicies(allContainers []container) (co
 					SourceTag:  policy.Source.Tag,
 					GUID:       policy.Destination.ID,
 					SourceGUID: policy.Source.ID,
+					VTEPName:   container.VTEPName,
 				}
 				containerPolicySet.Destination = append(containerPolicySet.Destination, containerPolicy)
 			}
@@ -308,6 +345,7 @@ func (p *VxlanPolicyPlanner) getContainerPolicies(allContainers []container) (co
 						IP:         container.IP,
 						Protocol:   "tcp",
 						Port:       convPort,
+						VTEPName:   container.VTEPName,
 					})
 				}
 			}
@@ -322,7 +360,7 @@ func (p *VxlanPolicyPlanner) getContainerPolicies(allContainers []container) (co
 }
 
 func (p *VxlanPolicyPlanner) planIPTableRules(containerPolicySet containerPolicySet) []rules.IPTablesRule {
-	var ruleset []rules.IPTablesRule
+	ruleset := p.overlayIsolationRules()
 	for _, c2cSource := range containerPolicySet.Source {
 		ruleset = append(ruleset, rules.NewMarkSetRule(
 			c2cSource.IP,
@@ -332,7 +370,7 @@ func (p *VxlanPolicyPlanner) planIPTableRules(containerPolicySet containerPolicy
 
 	for _, c2cDestination := range containerPolicySet.Destination {
 		if p.LoggingState.IsEnabled() {
-			ruleset = append(ruleset, rules.NewMarkAllowLogRule(
+			ruleset = append(ruleset, onVTEP(rules.NewMarkAllowLogRule(
 				c2cDestination.IP,
 				c2cDestination.Protocol,
 				c2cDestination.StartPort,
@@ -340,9 +378,9 @@ func (p *VxlanPolicyPlanner) planIPTableRules(containerPolicySet containerPolicy
 				c2cDestination.SourceTag,
 				c2cDestination.GUID,
 				p.IPTablesAcceptedUDPLogsPerSec,
-			))
+			), c2cDestination.VTEPName))
 		}
-		ruleset = append(ruleset, rules.NewMarkAllowRule(
+		ruleset = append(ruleset, onVTEP(rules.NewMarkAllowRule(
 			c2cDestination.IP,
 			c2cDestination.Protocol,
 			c2cDestination.StartPort,
@@ -350,16 +388,16 @@ func (p *VxlanPolicyPlanner) planIPTableRules(containerPolicySet containerPolicy
 			c2cDestination.SourceTag,
 			c2cDestination.SourceGUID,
 			c2cDestination.GUID,
-		))
+		), c2cDestination.VTEPName))
 	}
 
 	for _, ingressSource := range containerPolicySet.Ingress {
-		ruleset = append(ruleset, rules.NewMarkAllowRuleNoComment(
+		ruleset = append(ruleset, onVTEP(rules.NewMarkAllowRuleNoComment(
 			ingressSource.IP,
 			ingressSource.Protocol,
 			ingressSource.Port,
 			ingressSource.IngressTag,
-		))
+		), ingressSource.VTEPName))
 	}
 
 	return ruleset
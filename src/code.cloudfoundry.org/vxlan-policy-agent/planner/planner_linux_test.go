@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 
+	cnilib "code.cloudfoundry.org/cni-wrapper-plugin/lib"
 	"code.cloudfoundry.org/cni-wrapper-plugin/netrules"
 	"code.cloudfoundry.org/executor"
 	"code.cloudfoundry.org/lib/datastore"
@@ -217,58 +218,58 @@ var _ = Describe("Planner", func() {
 						{
 							"-d", "10.255.1.3",
 							"-p", "udp",
-							"--dport", "5555:5555",
-							"-m", "mark", "--mark", "0xBB",
-							"--jump", "ACCEPT",
+							"-m", "udp", "--dport", "5555",
+							"-m", "mark", "--mark", "0xbb",
 							"-m", "comment", "--comment", "src:another-app-guid_dst:some-other-app-guid",
+							"-j", "ACCEPT",
 						},
 						{
 							"-d", "10.255.1.3",
 							"-p", "tcp",
-							"--dport", "1234:1234",
-							"-m", "mark", "--mark", "0xAA",
-							"--jump", "ACCEPT",
+							"-m", "tcp", "--dport", "1234",
+							"-m", "mark", "--mark", "0xaa",
 							"-m", "comment", "--comment", "src:some-app-guid_dst:some-other-app-guid",
+							"-j", "ACCEPT",
 						},
 						{
 							"-d", "10.255.1.2",
 							"-p", "tcp",
-							"--dport", "8080:8080",
-							"-m", "mark", "--mark", "0xAA",
-							"--jump", "ACCEPT",
+							"-m", "tcp", "--dport", "8080",
+							"-m", "mark", "--mark", "0xaa",
 							"-m", "comment", "--comment", "src:some-app-guid_dst:some-app-guid",
+							"-j", "ACCEPT",
 						},
 						{
 							"-d", "10.255.1.2",
 							"-p", "tcp",
 							"-m", "tcp", "--dport", "8080",
 							"-m", "mark", "--mark", "0x5476",
-							"--jump", "ACCEPT",
+							"-j", "ACCEPT",
 						},
 						{
 							"-d", "10.255.1.3",
 							"-p", "tcp",
 							"-m", "tcp", "--dport", "9090",
 							"-m", "mark", "--mark", "0x5476",
-							"--jump", "ACCEPT",
+							"-j", "ACCEPT",
 						},
 						{
 							"-d", "10.255.1.3",
 							"-p", "tcp",
 							"-m", "tcp", "--dport", "8181",
 							"-m", "mark", "--mark", "0x5476",
-							"--jump", "ACCEPT",
+							"-j", "ACCEPT",
 						},
 						// set tags on all outgoing packets, regardless of local vs remote
 						{
-							"--source", "10.255.1.2",
-							"--jump", "MARK", "--set-xmark", "0xAA",
+							"-s", "10.255.1.2",
 							"-m", "comment", "--comment", "src:some-app-guid",
+							"-j", "MARK", "--set-xmark", "0xaa",
 						},
 						{
-							"--source", "10.255.1.3",
-							"--jump", "MARK", "--set-xmark", "0xCC",
+							"-s", "10.255.1.3",
 							"-m", "comment", "--comment", "src:some-other-app-guid",
+							"-j", "MARK", "--set-xmark", "0xcc",
 						},
 					}))
 				})
@@ -303,22 +304,19 @@ var _ = Describe("Planner", func() {
 					{
 						"-d", "10.255.1.3",
 						"-p", "udp",
-						"--dport", "5555:5555",
-						"-m", "mark",
-						"--mark", "0xBB",
-						"-m", "limit",
-						"--limit", "3/s",
-						"--limit-burst", "3",
-						"--jump", "LOG", "--log-prefix", `"OK_BB_some-other-app-guid "`,
+						"-m", "udp", "--dport", "5555",
+						"-m", "mark", "--mark", "0xbb",
+						"-m", "limit", "--limit", "3/sec", "--limit-burst", "3",
+						"-j", "LOG", "--log-prefix", `"OK_BB_some-other-app-guid "`,
 					},
 					// allow bb based on mark
 					{
 						"-d", "10.255.1.3",
 						"-p", "udp",
-						"--dport", "5555:5555",
-						"-m", "mark", "--mark", "0xBB",
-						"--jump", "ACCEPT",
+						"-m", "udp", "--dport", "5555",
+						"-m", "mark", "--mark", "0xbb",
 						"-m", "comment", "--comment", "src:another-app-guid_dst:some-other-app-guid",
+						"-j", "ACCEPT",
 					},
 				}))
 				Expect(rulesWithChain.Rules).To(gomegamatchers.ContainSequence([]rules.IPTablesRule{
@@ -326,31 +324,30 @@ var _ = Describe("Planner", func() {
 					{
 						"-d", "10.255.1.3",
 						"-p", "tcp",
-						"--dport", "1234:1234",
-						"-m", "mark", "--mark", "0xAA",
+						"-m", "tcp", "--dport", "1234",
 						"-m", "conntrack", "--ctstate", "INVALID,NEW,UNTRACKED",
-						"--jump", "LOG", "--log-prefix", `"OK_AA_some-other-app-guid "`,
+						"-m", "mark", "--mark", "0xaa",
+						"-j", "LOG", "--log-prefix", `"OK_AA_some-other-app-guid "`,
 					},
 					// allow aa based on mark
 					{
 						"-d", "10.255.1.3",
 						"-p", "tcp",
-						"--dport", "1234:1234",
-						"-m", "mark", "--mark", "0xAA",
-						"--jump", "ACCEPT",
+						"-m", "tcp", "--dport", "1234",
+						"-m", "mark", "--mark", "0xaa",
 						"-m", "comment", "--comment", "src:some-app-guid_dst:some-other-app-guid",
+						"-j", "ACCEPT",
 					},
 				}))
 				Expect(rulesWithChain.Rules).To(ContainElement(rules.IPTablesRule{
-					// set tags on all outgoing packets, regardless of local vs remote
-					"--source", "10.255.1.2",
-					"--jump", "MARK", "--set-xmark", "0xAA",
+					"-s", "10.255.1.2",
 					"-m", "comment", "--comment", "src:some-app-guid",
+					"-j", "MARK", "--set-xmark", "0xaa",
 				}))
 				Expect(rulesWithChain.Rules).To(ContainElement(rules.IPTablesRule{
-					"--source", "10.255.1.3",
-					"--jump", "MARK", "--set-xmark", "0xCC",
+					"-s", "10.255.1.3",
 					"-m", "comment", "--comment", "src:some-other-app-guid",
+					"-j", "MARK", "--set-xmark", "0xcc",
 				}))
 			})
 		})
@@ -374,6 +371,70 @@ var _ = Describe("Planner", func() {
 			Expect(name).To(Equal("policyServerPollTime"))
 		})
 
+		Context("when a container is on an additional overlay", func() {
+			BeforeEach(func() {
+				loggingStateGetter.IsEnabledReturns(false)
+				data["container-id-1"].Metadata["vtep_name"] = "silk-vtep-iso"
+			})
+
+			It("only allows traffic to that container from the overlay's vtep", func() {
+				rulesWithChain, err := policyPlanner.GetPolicyRulesAndChain()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(rulesWithChain.Rules).To(ContainElements(
+					rules.IPTablesRule{
+						"-d", "10.255.1.2",
+						"-i", "silk-vtep-iso",
+						"-p", "tcp",
+						"-m", "tcp", "--dport", "8080",
+						"-m", "mark", "--mark", "0xaa",
+						"-m", "comment", "--comment", "src:some-app-guid_dst:some-app-guid",
+						"-j", "ACCEPT",
+					},
+					rules.IPTablesRule{
+						"-d", "10.255.1.2",
+						"-i", "silk-vtep-iso",
+						"-p", "tcp",
+						"-m", "tcp", "--dport", "8080",
+						"-m", "mark", "--mark", "0x5476",
+						"-j", "ACCEPT",
+					},
+					rules.IPTablesRule{
+						"-d", "10.255.1.3",
+						"-p", "tcp",
+						"-m", "tcp", "--dport", "1234",
+						"-m", "mark", "--mark", "0xaa",
+						"-m", "comment", "--comment", "src:some-app-guid_dst:some-other-app-guid",
+						"-j", "ACCEPT",
+					},
+					rules.IPTablesRule{
+						"-s", "10.255.1.2",
+						"-m", "comment", "--comment", "src:some-app-guid",
+						"-j", "MARK", "--set-xmark", "0xaa",
+					},
+				))
+			})
+		})
+
+		Context("when the cell has additional overlays", func() {
+			BeforeEach(func() {
+				policyPlanner.OverlayNetwork = "10.255.0.0/16"
+				policyPlanner.AdditionalOverlays = []cnilib.OverlayConfig{
+					{Name: "isolated", Network: "10.100.0.0/16", VTEPName: "silk-vtep-iso"},
+				}
+			})
+
+			It("drops traffic between the overlays before any policy rule", func() {
+				rulesWithChain, err := policyPlanner.GetPolicyRulesAndChain()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(rulesWithChain.Rules[:4]).To(Equal([]rules.IPTablesRule{
+					{"-s", "10.255.0.0/16", "-d", "10.100.0.0/16", "-j", "DROP"},
+					{"!", "-d", "10.100.0.0/16", "-i", "silk-vtep-iso", "-j", "DROP"},
+					{"!", "-s", "10.100.0.0/16", "-o", "silk-vtep-iso", "-j", "DROP"},
+					{"-s", "10.100.0.0/16", "-d", "10.255.0.0/16", "-j", "DROP"},
+				}))
+			})
+		})
+
 		Context("when the policies are returned from the server in a different order", func() {
 			var reversed []policy_client.Policy
 			BeforeEach(func() {
@@ -515,9 +576,9 @@ var _ = Describe("Planner", func() {
 					Expect(err).NotTo(HaveOccurred())
 
 					Expect(rulesWithChain.Rules).To(ConsistOf(
-						rules.IPTablesRule{"--source", "10.255.1.2", "--jump", "MARK", "--set-xmark", "0xAA", "-m", "comment", "--comment", "src:some-app-guid"},
-						rules.IPTablesRule{"-d", "10.255.1.2", "-p", "tcp", "--dport", "8080:8080", "-m", "mark", "--mark", "0xAA", "--jump", "ACCEPT", "-m", "comment", "--comment", "src:some-app-guid_dst:some-app-guid"},
-						rules.IPTablesRule{"-d", "10.255.1.2", "-p", "tcp", "-m", "tcp", "--dport", "8080", "-m", "mark", "--mark", "0x5476", "--jump", "ACCEPT"},
+						rules.IPTablesRule{"-s", "10.255.1.2", "-m", "comment", "--comment", "src:some-app-guid", "-j", "MARK", "--set-xmark", "0xaa"},
+						rules.IPTablesRule{"-d", "10.255.1.2", "-p", "tcp", "-m", "tcp", "--dport", "8080", "-m", "mark", "--mark", "0xaa", "-m", "comment", "--comment", "src:some-app-guid_dst:some-app-guid", "-j", "ACCEPT"},
+						rules.IPTablesRule{"-d", "10.255.1.2", "-p", "tcp", "-m", "tcp", "--dport", "8080", "-m", "mark", "--mark", "0x5476", "-j", "ACCEPT"},
 					))
 				})
 			})
@@ -542,9 +603,9 @@ var _ = Describe("Planner", func() {
 					Expect(err).NotTo(HaveOccurred())
 
 					Expect(rulesWithChain.Rules).To(ConsistOf(
-						rules.IPTablesRule{"--source", "10.255.1.2", "--jump", "MARK", "--set-xmark", "0xAA", "-m", "comment", "--comment", "src:some-app-guid"},
-						rules.IPTablesRule{"-d", "10.255.1.2", "-p", "tcp", "--dport", "8080:8080", "-m", "mark", "--mark", "0xAA", "--jump", "ACCEPT", "-m", "comment", "--comment", "src:some-app-guid_dst:some-app-guid"},
-						rules.IPTablesRule{"-d", "10.255.1.2", "-p", "tcp", "-m", "tcp", "--dport", "8080", "-m", "mark", "--mark", "0x5476", "--jump", "ACCEPT"},
+						rules.IPTablesRule{"-s", "10.255.1.2", "-m", "comment", "--comment", "src:some-app-guid", "-j", "MARK", "--set-xmark", "0xaa"},
+						rules.IPTablesRule{"-d", "10.255.1.2", "-p", "tcp", "-m", "tcp", "--dport", "8080", "-m", "mark", "--mark", "0xaa", "-m", "comment", "--comment", "src:some-app-guid_dst:some-app-guid", "-j", "ACCEPT"},
+						rules.IPTablesRule{"-d", "10.255.1.2", "-p", "tcp", "-m", "tcp", "--dport", "8080", "-m", "mark", "--mark", "0x5476", "-j", "ACCEPT"},
 					))
 				})
 			})
@@ -569,9 +630,9 @@ var _ = Describe("Planner", func() {
 					Expect(err).NotTo(HaveOccurred())
 
 					Expect(rulesWithChain.Rules).To(ConsistOf(
-						rules.IPTablesRule{"--source", "10.255.1.2", "--jump", "MARK", "--set-xmark", "0xAA", "-m", "comment", "--comment", "src:some-app-guid"},
-						rules.IPTablesRule{"-d", "10.255.1.2", "-p", "tcp", "--dport", "8080:8080", "-m", "mark", "--mark", "0xAA", "--jump", "ACCEPT", "-m", "comment", "--comment", "src:some-app-guid_dst:some-app-guid"},
-						rules.IPTablesRule{"-d", "10.255.1.2", "-p", "tcp", "-m", "tcp", "--dport", "8080", "-m", "mark", "--mark", "0x5476", "--jump", "ACCEPT"},
+						rules.IPTablesRule{"-s", "10.255.1.2", "-m", "comment", "--comment", "src:some-app-guid", "-j", "MARK", "--set-xmark", "0xaa"},
+						rules.IPTablesRule{"-d", "10.255.1.2", "-p", "tcp", "-m", "tcp", "--dport", "8080", "-m", "mark", "--mark", "0xaa", "-m", "comment", "--comment", "src:some-app-guid_dst:some-app-guid", "-j", "ACCEPT"},
+						rules.IPTablesRule{"-d", "10.255.1.2", "-p", "tcp", "-m", "tcp", "--dport", "8080", "-m", "mark", "--mark", "0x5476", "-j", "ACCEPT"},
 					))
 				})
 			})
@@ -595,9 +656,9 @@ var _ = Describe("Planner", func() {
 					Expect(err).NotTo(HaveOccurred())
 
 					Expect(rulesWithChain.Rules).To(ConsistOf(
-						rules.IPTablesRule{"--source", "10.255.1.2", "--jump", "MARK", "--set-xmark", "0xAA", "-m", "comment", "--comment", "src:some-app-guid"},
-						rules.IPTablesRule{"-d", "10.255.1.2", "-p", "tcp", "--dport", "8080:8080", "-m", "mark", "--mark", "0xAA", "--jump", "ACCEPT", "-m", "comment", "--comment", "src:some-app-guid_dst:some-app-guid"},
-						rules.IPTablesRule{"-d", "10.255.1.2", "-p", "tcp", "-m", "tcp", "--dport", "8080", "-m", "mark", "--mark", "0x5476", "--jump", "ACCEPT"},
+						rules.IPTablesRule{"-s", "10.255.1.2", "-m", "comment", "--comment", "src:some-app-guid", "-j", "MARK", "--set-xmark", "0xaa"},
+						rules.IPTablesRule{"-d", "10.255.1.2", "-p", "tcp", "-m", "tcp", "--dport", "8080", "-m", "mark", "--mark", "0xaa", "-m", "comment", "--comment", "src:some-app-guid_dst:some-app-guid", "-j", "ACCEPT"},
+						rules.IPTablesRule{"-d", "10.255.1.2", "-p", "tcp", "-m", "tcp", "--dport", "8080", "-m", "mark", "--mark", "0x5476", "-j", "ACCEPT"},
 					))
 				})
 			})
@@ -619,21 +680,21 @@ var _ = Describe("Planner", func() {
 						"-p", "tcp",
 						"-m", "tcp", "--dport", "8080",
 						"-m", "mark", "--mark", "0x5476",
-						"--jump", "ACCEPT",
+						"-j", "ACCEPT",
 					},
 					{
 						"-d", "10.255.1.3",
 						"-p", "tcp",
 						"-m", "tcp", "--dport", "8181",
 						"-m", "mark", "--mark", "0x5476",
-						"--jump", "ACCEPT",
+						"-j", "ACCEPT",
 					},
 					{
 						"-d", "10.255.1.3",
 						"-p", "tcp",
 						"-m", "tcp", "--dport", "9090",
 						"-m", "mark", "--mark", "0x5476",
-						"--jump", "ACCEPT",
+						"-j", "ACCEPT",
 					},
 				}))
 			})